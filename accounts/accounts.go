@@ -0,0 +1,289 @@
+// Package accounts resolves the SIDs in events to account names. A Resolver
+// looks each SID up once with LookupAccountSid and remembers the result,
+// including SIDs which couldn't be resolved, and Annotate adds the names of
+// the accounts in an event to its EventData:
+//
+//	resolver := accounts.NewResolver()
+//	for ev := range watcher.Event() {
+//		resolver.Annotate(ev)
+//		log.Printf("%v logged on", ev.EventData["TargetUserAccount"])
+//	}
+//
+// SIDs of local accounts on other computers, as in forwarded events, are
+// looked up on the computer which logged the event if this computer can't
+// resolve them.
+package accounts
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Defaults for the Resolver's settings
+const (
+	DefaultTTL         = time.Hour
+	DefaultNegativeTTL = 5 * time.Minute
+	DefaultTimeout     = 2 * time.Second
+	DefaultMaxEntries  = 10000
+)
+
+// Suffix of the EventData fields Annotate adds, after the name of the SID
+// field without "Sid", such as TargetAccount for TargetSid
+const AccountSuffix = "Account"
+
+// EventData field Annotate sets to the account of the event's UserSid
+const UserAccountField = "User" + AccountSuffix
+
+// Returned when a SID doesn't belong to an account known to this computer,
+// its domain, or the computer which logged the event
+var ErrNotFound = errors.New("No account found for SID")
+
+// Returned when a lookup takes longer than the Resolver's Timeout. The
+// lookup continues in the background, and its result is remembered.
+var ErrTimeout = errors.New("Timed out looking up SID")
+
+// SIDs in string form. Security events sometimes wrap them as %{S-1-...}.
+var sidPattern = regexp.MustCompile(`^(%\{)?(S-1-\d+(-\d+)+)\}?$`)
+
+// Account is the account a SID belongs to.
+type Account struct {
+	Sid    string
+	Name   string
+	Domain string
+	// The SID_NAME_USE, such as 1 for a user or 5 for a well-known group
+	Type uint32
+}
+
+// String returns the account as DOMAIN\name, or just the name for accounts
+// without a domain, such as Everyone.
+func (a Account) String() string {
+	if a.Domain == "" {
+		return a.Name
+	}
+	return a.Domain + `\` + a.Name
+}
+
+// Resolver looks up and caches the accounts of SIDs. Safe for concurrent
+// use.
+type Resolver struct {
+	// How long to remember resolved accounts. DefaultTTL if zero.
+	TTL time.Duration
+	// How long to remember SIDs which couldn't be resolved.
+	// DefaultNegativeTTL if zero.
+	NegativeTTL time.Duration
+	// Longest to wait for a lookup, which can block on an unreachable
+	// domain controller. DefaultTimeout if zero.
+	Timeout time.Duration
+	// Most SIDs to remember. DefaultMaxEntries if zero.
+	MaxEntries int
+	// Looks up a SID on a computer, or on this one if `system` is "".
+	// LookupAccountSid by default.
+	Lookup func(system, sid string) (Account, error)
+
+	mutex sync.Mutex
+	cache map[string]*entry
+	// Names of this computer, in lower case
+	localNames map[string]bool
+}
+
+type entry struct {
+	account Account
+	err     error
+	expires time.Time
+	// When the lookup started, and closed when it finishes
+	started time.Time
+	done    chan struct{}
+}
+
+// NewResolver creates a Resolver with the default settings.
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// Resolve returns the account of a SID. `computer` is the computer which
+// logged the event the SID came from, such as its ComputerName, for local
+// accounts on other computers.
+func (r *Resolver) Resolve(sid, computer string) (Account, error) {
+	now := time.Now()
+	r.mutex.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]*entry)
+	}
+	e, ok := r.cache[sid]
+	if !ok || (isDone(e) && now.After(e.expires)) {
+		e = &entry{started: now, done: make(chan struct{})}
+		r.evict(now)
+		r.cache[sid] = e
+		go r.lookupEntry(e, sid, computer)
+	}
+	r.mutex.Unlock()
+
+	if isDone(e) {
+		return e.account, e.err
+	}
+	wait := time.NewTimer(r.timeout() - now.Sub(e.started))
+	defer wait.Stop()
+	select {
+	case <-e.done:
+		return e.account, e.err
+	case <-wait.C:
+		return Account{}, ErrTimeout
+	}
+}
+
+func isDone(e *entry) bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Resolver) lookupEntry(e *entry, sid, computer string) {
+	account, err := r.lookup(sid, computer)
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if err != nil {
+		if ttl = r.NegativeTTL; ttl <= 0 {
+			ttl = DefaultNegativeTTL
+		}
+	}
+	r.mutex.Lock()
+	e.account, e.err = account, err
+	e.expires = time.Now().Add(ttl)
+	close(e.done)
+	r.mutex.Unlock()
+}
+
+// lookup resolves the SID on this computer, which knows well-known SIDs and
+// those of its domain, then on the computer which logged the event for
+// SIDs which may be its local accounts.
+func (r *Resolver) lookup(sid, computer string) (Account, error) {
+	lookup := r.Lookup
+	if lookup == nil {
+		lookup = lookupAccountSid
+	}
+	account, err := lookup("", sid)
+	if err == ErrNotFound && strings.HasPrefix(sid, "S-1-5-21-") && computer != "" && !r.isLocal(computer) {
+		account, err = lookup(computer, sid)
+	}
+	if err == nil {
+		account.Sid = sid
+	}
+	return account, err
+}
+
+// isLocal returns whether `computer`, a host name or FQDN, is this computer.
+func (r *Resolver) isLocal(computer string) bool {
+	r.mutex.Lock()
+	if r.localNames == nil {
+		r.localNames = make(map[string]bool)
+		if host, err := os.Hostname(); err == nil {
+			host = strings.ToLower(host)
+			r.localNames[host] = true
+			r.localNames[strings.SplitN(host, ".", 2)[0]] = true
+		}
+		r.localNames["localhost"] = true
+	}
+	names := r.localNames
+	r.mutex.Unlock()
+	computer = strings.ToLower(computer)
+	return names[computer] || names[strings.SplitN(computer, ".", 2)[0]]
+}
+
+func (r *Resolver) timeout() time.Duration {
+	if r.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return r.Timeout
+}
+
+// evict makes room for a new entry, removing expired entries first. Must be
+// called with the mutex held.
+func (r *Resolver) evict(now time.Time) {
+	max := r.MaxEntries
+	if max <= 0 {
+		max = DefaultMaxEntries
+	}
+	if len(r.cache) < max {
+		return
+	}
+	for sid, e := range r.cache {
+		if isDone(e) && now.After(e.expires) {
+			delete(r.cache, sid)
+		}
+	}
+	for sid, e := range r.cache {
+		if len(r.cache) < max {
+			break
+		}
+		if isDone(e) {
+			delete(r.cache, sid)
+		}
+	}
+}
+
+// Len returns the number of SIDs remembered, including those being looked
+// up.
+func (r *Resolver) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.cache)
+}
+
+// Annotate adds the accounts of the SIDs in an event to its EventData: its
+// UserSid as UserAccountField, and each EventData field ending in "Sid",
+// such as TargetUserSid, as the field's name with AccountSuffix in place of
+// "Sid", such as TargetUserAccount. Existing fields aren't replaced, and
+// SIDs which can't be resolved are skipped. Returns the number of SIDs
+// resolved.
+func (r *Resolver) Annotate(ev *winlog.WinLogEvent) int {
+	type sidField struct{ field, sid string }
+	var fields []sidField
+	if sid := parseSid(ev.UserSid); sid != "" {
+		fields = append(fields, sidField{UserAccountField, sid})
+	}
+	for name, value := range ev.EventData {
+		if !strings.HasSuffix(name, "Sid") {
+			continue
+		}
+		if sid := parseSid(value); sid != "" {
+			fields = append(fields, sidField{strings.TrimSuffix(name, "Sid") + AccountSuffix, sid})
+		}
+	}
+	resolved := 0
+	for _, f := range fields {
+		if _, exists := ev.EventData[f.field]; exists {
+			continue
+		}
+		account, err := r.Resolve(f.sid, ev.ComputerName)
+		if err != nil {
+			continue
+		}
+		if ev.EventData == nil {
+			ev.EventData = make(map[string]string)
+		}
+		ev.EventData[f.field] = account.String()
+		resolved++
+	}
+	return resolved
+}
+
+// parseSid returns the SID in a value, or "" if it isn't one. The null SID,
+// S-1-0-0, which events use when there's no account, isn't returned.
+func parseSid(value string) string {
+	m := sidPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil || m[2] == "S-1-0-0" {
+		return ""
+	}
+	return m[2]
+}
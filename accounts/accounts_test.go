@@ -0,0 +1,154 @@
+package accounts
+
+import (
+	"errors"
+	"sync"
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+// fakeDirectory resolves SIDs from a table per computer, counting lookups.
+type fakeDirectory struct {
+	mutex    sync.Mutex
+	accounts map[string]map[string]Account
+	lookups  []string
+	block    chan struct{}
+}
+
+func (d *fakeDirectory) lookup(system, sid string) (Account, error) {
+	if d.block != nil {
+		<-d.block
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.lookups = append(d.lookups, system+"/"+sid)
+	if account, ok := d.accounts[system][sid]; ok {
+		return account, nil
+	}
+	return Account{}, ErrNotFound
+}
+
+func (d *fakeDirectory) count() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.lookups)
+}
+
+func newDirectory() *fakeDirectory {
+	return &fakeDirectory{accounts: map[string]map[string]Account{
+		"": {
+			"S-1-5-18":                  {Name: "SYSTEM", Domain: "NT AUTHORITY"},
+			"S-1-5-21-100-200-300-1104": {Name: "alice", Domain: "CORP"},
+			"S-1-1-0":                   {Name: "Everyone"},
+		},
+		"ws07.corp.example.com": {
+			"S-1-5-21-700-800-900-1001": {Name: "localadmin", Domain: "WS07"},
+		},
+	}}
+}
+
+func TestResolveCaches(t *T) {
+	directory := newDirectory()
+	resolver := &Resolver{Lookup: directory.lookup}
+
+	for i := 0; i < 3; i++ {
+		account, err := resolver.Resolve("S-1-5-21-100-200-300-1104", "dc01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testutil.AssertEqual(account.String(), `CORP\alice`, t)
+		testutil.AssertEqual(account.Sid, "S-1-5-21-100-200-300-1104", t)
+	}
+	testutil.AssertEqual(directory.count(), 1, t)
+
+	// Failures are remembered too
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.Resolve("S-1-5-32-999", "dc01"); err != ErrNotFound {
+			t.Fatalf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	testutil.AssertEqual(directory.count(), 2, t)
+	testutil.AssertEqual(resolver.Len(), 2, t)
+}
+
+func TestResolveExpires(t *T) {
+	directory := newDirectory()
+	resolver := &Resolver{Lookup: directory.lookup, TTL: time.Nanosecond, NegativeTTL: time.Nanosecond}
+	resolver.Resolve("S-1-5-18", "")
+	time.Sleep(time.Millisecond)
+	resolver.Resolve("S-1-5-18", "")
+	testutil.AssertEqual(directory.count(), 2, t)
+}
+
+func TestResolveOnEventComputer(t *T) {
+	directory := newDirectory()
+	resolver := &Resolver{Lookup: directory.lookup}
+	account, err := resolver.Resolve("S-1-5-21-700-800-900-1001", "ws07.corp.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(account.String(), `WS07\localadmin`, t)
+	testutil.AssertEqual(directory.lookups[1], "ws07.corp.example.com/S-1-5-21-700-800-900-1001", t)
+
+	// Well-known SIDs aren't looked up remotely
+	resolver.Resolve("S-1-5-32-999", "ws07.corp.example.com")
+	testutil.AssertEqual(directory.count(), 3, t)
+}
+
+func TestResolveTimeout(t *T) {
+	directory := newDirectory()
+	directory.block = make(chan struct{})
+	resolver := &Resolver{Lookup: directory.lookup, Timeout: 10 * time.Millisecond}
+	if _, err := resolver.Resolve("S-1-5-18", ""); err != ErrTimeout {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	// The lookup finishes in the background and is remembered
+	close(directory.block)
+	for i := 0; i < 100 && directory.count() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	account, err := resolver.Resolve("S-1-5-18", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(account.Name, "SYSTEM", t)
+	testutil.AssertEqual(directory.count(), 1, t)
+}
+
+func TestResolveEvicts(t *T) {
+	resolver := &Resolver{Lookup: func(system, sid string) (Account, error) {
+		return Account{}, errors.New("failed")
+	}, MaxEntries: 2}
+	for _, sid := range []string{"S-1-5-1", "S-1-5-2", "S-1-5-3"} {
+		resolver.Resolve(sid, "")
+	}
+	testutil.AssertEqual(resolver.Len(), 2, t)
+}
+
+func TestAnnotate(t *T) {
+	resolver := &Resolver{Lookup: newDirectory().lookup}
+	ev := &winlog.WinLogEvent{
+		ComputerName: "dc01",
+		UserSid:      "S-1-5-18",
+		EventData: map[string]string{
+			"SubjectUserSid":     "S-1-5-18",
+			"TargetUserSid":      "%{S-1-5-21-100-200-300-1104}",
+			"TargetLogonId":      "0x3e7",
+			"MemberSid":          "S-1-0-0",
+			"TargetSid":          "S-1-5-21-100-200-300-9999",
+			"TargetUserName":     "alice",
+			"SubjectUserAccount": "kept",
+		},
+	}
+	testutil.AssertEqual(resolver.Annotate(ev), 2, t)
+	testutil.AssertEqual(ev.EventData[UserAccountField], `NT AUTHORITY\SYSTEM`, t)
+	testutil.AssertEqual(ev.EventData["TargetUserAccount"], `CORP\alice`, t)
+	testutil.AssertEqual(ev.EventData["SubjectUserAccount"], "kept", t)
+	_, ok := ev.EventData["MemberAccount"]
+	testutil.AssertEqual(ok, false, t)
+	_, ok = ev.EventData["TargetAccount"]
+	testutil.AssertEqual(ok, false, t)
+}
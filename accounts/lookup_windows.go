@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package accounts
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// lookupAccountSid wraps LookupAccountSid.
+func lookupAccountSid(system, sid string) (Account, error) {
+	s, err := windows.StringToSid(sid)
+	if err != nil {
+		return Account{}, err
+	}
+	name, domain, accountType, err := s.LookupAccount(system)
+	if err == windows.ERROR_NONE_MAPPED {
+		return Account{}, ErrNotFound
+	} else if err != nil {
+		return Account{}, err
+	}
+	return Account{Name: name, Domain: domain, Type: accountType}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package accounts
+
+import (
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func lookupAccountSid(system, sid string) (Account, error) {
+	return Account{}, winlog.ErrUnsupportedPlatform
+}
@@ -0,0 +1,181 @@
+// Package applocker converts AppLocker EXE, DLL, MSI and script events into
+// typed structs:
+//
+//	decision, err := applocker.Parse(ev)
+//	if err == nil && decision.Action != applocker.Allowed {
+//		log.Printf("%v %v by %v", decision.Action, decision.FullFilePath, decision.RuleName)
+//	}
+//
+// AppLocker records its fields as UserData rather than EventData, so they
+// are read from UserData, or parsed from Xml if it's empty, unless the
+// watcher filled EventData in with them.
+package applocker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider and channels of AppLocker events
+const (
+	ProviderName     = "Microsoft-Windows-AppLocker"
+	ExeChannel       = "Microsoft-Windows-AppLocker/EXE and DLL"
+	MsiScriptChannel = "Microsoft-Windows-AppLocker/MSI and Script"
+)
+
+// Event IDs parsed by this package
+const (
+	EventExeAllowed       = 8002
+	EventExeAudited       = 8003
+	EventExeBlocked       = 8004
+	EventMsiScriptAllowed = 8005
+	EventMsiScriptAudited = 8006
+	EventMsiScriptBlocked = 8007
+)
+
+// ErrUnsupportedEvent is returned by Parse for events this package doesn't
+// know how to parse.
+var ErrUnsupportedEvent = errors.New("Not a supported AppLocker event")
+
+// Action is what AppLocker did, or would have done, with a file.
+type Action int
+
+const (
+	Allowed Action = iota
+	// The file was allowed because the policy is in audit mode, but would
+	// have been blocked if it were enforced
+	Audited
+	Blocked
+)
+
+var actionNames = map[Action]string{
+	Allowed: "Allowed",
+	Audited: "Audited",
+	Blocked: "Blocked",
+}
+
+func (a Action) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+var eventActions = map[uint64]Action{
+	EventExeAllowed:       Allowed,
+	EventExeAudited:       Audited,
+	EventExeBlocked:       Blocked,
+	EventMsiScriptAllowed: Allowed,
+	EventMsiScriptAudited: Audited,
+	EventMsiScriptBlocked: Blocked,
+}
+
+// Decision is AppLocker's decision to allow or block a file.
+type Decision struct {
+	Event  *winlog.WinLogEvent
+	Action Action
+	// Rule collection which made the decision: "EXE", "DLL", "MSI" or
+	// "SCRIPT"
+	PolicyName string
+	RuleId     string
+	// Name of the matching rule, or for files matched by no rule, a note
+	// that the default action applied
+	RuleName string
+	RuleSddl string
+
+	// SID of the user who ran the file
+	TargetUser      string
+	TargetProcessId uint64
+	TargetLogonId   uint64
+	// Path using AppLocker's variables, such as %OSDRIVE%\USERS\ALICE\A.EXE
+	FilePath string
+	// Path as the user would see it, such as C:\Users\alice\a.exe
+	FullFilePath string
+	// SHA256 of the file, as used by hash rules
+	FileHash string
+	// Fully qualified binary name of a signed file, used by publisher
+	// rules: publisher, product, file name and version
+	Fqbn string
+}
+
+// Parse parses an 8002 to 8007 event.
+func Parse(ev *winlog.WinLogEvent) (*Decision, error) {
+	action, ok := eventActions[ev.EventId]
+	if !ok || (ev.ProviderName != "" && ev.ProviderName != ProviderName) {
+		return nil, ErrUnsupportedEvent
+	}
+	data := ev.EventData
+	if data["PolicyName"] == "" {
+		data = ev.UserData
+	}
+	if data["PolicyName"] == "" && len(ev.Xml) > 0 {
+		var err error
+		if data, err = ParseUserData(ev.Xml); err != nil {
+			return nil, err
+		}
+	}
+
+	f := fields{data: data}
+	decision := &Decision{
+		Event:           ev,
+		Action:          action,
+		PolicyName:      f.str("PolicyName"),
+		RuleId:          f.str("RuleId"),
+		RuleName:        f.str("RuleName"),
+		RuleSddl:        f.str("RuleSddl"),
+		TargetUser:      f.str("TargetUser"),
+		TargetProcessId: f.uint("TargetProcessId"),
+		TargetLogonId:   f.uint("TargetLogonId"),
+		FilePath:        f.str("FilePath"),
+		FullFilePath:    f.str("FullFilePath"),
+		FileHash:        f.str("FileHash"),
+		Fqbn:            f.str("Fqbn"),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return decision, nil
+}
+
+// ParseUserData extracts the fields of an event's <UserData>, keyed by
+// element name. Returns a nil map if the event has no UserData.
+func ParseUserData(eventXml []byte) (map[string]string, error) {
+	data, err := winlog.ParseUserData(eventXml)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse UserData: %v", err)
+	}
+	return data, nil
+}
+
+// fields reads UserData values, remembering the first malformed one.
+type fields struct {
+	data map[string]string
+	err  error
+}
+
+// str returns a field, with "-", which AppLocker uses for a missing value,
+// as "".
+func (f *fields) str(name string) string {
+	value := strings.TrimSpace(f.data[name])
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// uint parses a decimal or 0x-prefixed hexadecimal field, as 0 if missing.
+func (f *fields) uint(name string) uint64 {
+	value := f.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 0, 64)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("Failed to parse %v %q: %v", name, value, err)
+	}
+	return n
+}
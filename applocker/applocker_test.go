@@ -0,0 +1,62 @@
+package applocker
+
+import (
+	. "testing"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+func TestAllowed(t *T) {
+	d, err := Parse(testutil.EventFixture(t, EventExeAllowed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(d.Action, Allowed, t)
+	testutil.AssertEqual(d.Action.String(), "Allowed", t)
+	testutil.AssertEqual(d.PolicyName, "EXE", t)
+	testutil.AssertEqual(d.RuleId, "{921cc481-6e17-4653-8f75-050b80acca20}", t)
+	testutil.AssertEqual(d.RuleName, "(Default Rule) All files located in the Program Files folder", t)
+	testutil.AssertEqual(d.TargetUser, "S-1-5-21-3623811015-3361044348-30300820-1013", t)
+	testutil.AssertEqual(d.TargetProcessId, uint64(4120), t)
+	testutil.AssertEqual(d.TargetLogonId, uint64(0x1a2b3c), t)
+	testutil.AssertEqual(d.FilePath, `%PROGRAMFILES%\MOZILLA FIREFOX\FIREFOX.EXE`, t)
+	testutil.AssertEqual(d.FullFilePath, `C:\Program Files\Mozilla Firefox\firefox.exe`, t)
+	testutil.AssertEqual(d.Fqbn, `O=MOZILLA CORPORATION, L=MOUNTAIN VIEW, S=CALIFORNIA, C=US\FIREFOX\FIREFOX.EXE\86.0.0.7723`, t)
+}
+
+func TestBlocked(t *T) {
+	d, err := Parse(testutil.EventFixture(t, EventMsiScriptBlocked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(d.Action, Blocked, t)
+	testutil.AssertEqual(d.PolicyName, "SCRIPT", t)
+	testutil.AssertEqual(d.RuleName, "", t)
+	testutil.AssertEqual(d.RuleSddl, "", t)
+	testutil.AssertEqual(d.Fqbn, "", t)
+	testutil.AssertEqual(d.FullFilePath, `C:\Users\alice\Downloads\install.ps1`, t)
+	testutil.AssertEqual(d.FileHash, "9A8B7C6D5E4F30211A2B3C4D5E6F708192A3B4C5D6E7F8091A2B3C4D5E6F7081", t)
+
+	// Fields already in EventData are used as they are
+	d, err = Parse(&winlog.WinLogEvent{EventId: EventExeAudited, EventData: map[string]string{"PolicyName": "DLL", "TargetProcessId": "12"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(d.Action, Audited, t)
+	testutil.AssertEqual(d.PolicyName, "DLL", t)
+	testutil.AssertEqual(d.TargetProcessId, uint64(12), t)
+
+	if _, err := Parse(&winlog.WinLogEvent{ProviderName: ProviderName, EventId: 8001}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+}
+
+func TestParseUserData(t *T) {
+	data, err := ParseUserData([]byte(`<Event><System/><EventData><Data Name='a'>1</Data></EventData></Event>`))
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(data == nil, true, t)
+	if _, err := ParseUserData([]byte(`<Event><UserData>`)); err == nil {
+		t.Fatal("Expected an error for truncated XML")
+	}
+}
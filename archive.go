@@ -0,0 +1,58 @@
+package winlog
+
+import (
+	"path/filepath"
+)
+
+// ArchiveAPI is an EventLogAPI for formatting events read from an archived
+// .evtx file, such as one copied from another computer, with the provider
+// metadata saved alongside it. Providers are opened from the archive's
+// metadata first, then from those installed on this computer.
+//
+//	watcher, err := NewWinLogWatcherWithAPI(NewArchiveAPI(`D:\cases\dc01\Security.evtx`))
+type ArchiveAPI struct {
+	EventLogAPI
+	// The archived log file
+	LogFilePath string
+	// Directory holding the archive's LocaleMetaData directory, with the
+	// .MTA files "wevtutil archive-log" writes for each locale, if they
+	// aren't next to the log file
+	MetadataDir string
+
+	openArchived func(providerName, logFilePath string) (PublisherHandle, error)
+}
+
+// NewArchiveAPI returns an ArchiveAPI for the log file, making its other
+// calls through SystemEventLogAPI.
+func NewArchiveAPI(logFilePath string) *ArchiveAPI {
+	return &ArchiveAPI{EventLogAPI: SystemEventLogAPI, LogFilePath: logFilePath}
+}
+
+// metadataPath is the path passed to EvtOpenPublisherMetadata, which looks
+// for the metadata in the LocaleMetaData directory next to it.
+func (a *ArchiveAPI) metadataPath() string {
+	if a.MetadataDir == "" {
+		return a.LogFilePath
+	}
+	return filepath.Join(a.MetadataDir, filepath.Base(a.LogFilePath))
+}
+
+func (a *ArchiveAPI) OpenPublisherMetadata(providerName string) (PublisherHandle, error) {
+	open := a.openArchived
+	if open == nil {
+		open = OpenArchivedPublisherMetadata
+	}
+	if handle, err := open(providerName, a.metadataPath()); err == nil {
+		return handle, nil
+	}
+	return a.EventLogAPI.OpenPublisherMetadata(providerName)
+}
+
+// OpenLocalizedPublisherMetadata opens the archive's metadata for the
+// default locale, and that of installed providers for other locales.
+func (a *ArchiveAPI) OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error) {
+	if locale == "" {
+		return a.OpenPublisherMetadata(providerName)
+	}
+	return a.EventLogAPI.OpenLocalizedPublisherMetadata(providerName, locale)
+}
@@ -0,0 +1,37 @@
+package winlog
+
+import (
+	"errors"
+	"path/filepath"
+	. "testing"
+)
+
+func TestArchiveAPIOpenPublisherMetadata(t *T) {
+	var opened []string
+	archive := &ArchiveAPI{
+		EventLogAPI: newFakeAPI(),
+		LogFilePath: filepath.Join("cases", "Security.evtx"),
+		openArchived: func(providerName, logFilePath string) (PublisherHandle, error) {
+			opened = append(opened, logFilePath)
+			if providerName == "Archived" {
+				return 1000, nil
+			}
+			return 0, errors.New("The publisher metadata cannot be found in the resource")
+		},
+	}
+	handle, err := archive.OpenPublisherMetadata("Archived")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(handle, PublisherHandle(1000), t)
+	assertEqual(opened[0], filepath.Join("cases", "Security.evtx"), t)
+
+	// Providers missing from the archive are opened from this computer
+	archive.MetadataDir = "metadata"
+	handle, err = archive.OpenPublisherMetadata("Installed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(handle != 1000, true, t)
+	assertEqual(opened[1], filepath.Join("metadata", "Security.evtx"), t)
+}
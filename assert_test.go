@@ -0,0 +1,12 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func assertEqual(a, b interface{}, t *T) {
+	t.Helper()
+	if a != b {
+		t.Fatalf("%v != %v", a, b)
+	}
+}
@@ -0,0 +1,64 @@
+package winlog
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// BinaryFormat is how an event's Binary data is represented in its
+// EventData.
+type BinaryFormat int
+
+const (
+	// Binary data isn't added to EventData
+	BinaryOmit BinaryFormat = iota
+	// Uppercase hex, as in the event's XML
+	BinaryHex
+	// Standard base64, which is shorter for large blobs
+	BinaryBase64
+)
+
+// Key of the Binary data in EventData
+const binaryDataName = "Binary"
+
+func (f BinaryFormat) String() string {
+	switch f {
+	case BinaryOmit:
+		return "omit"
+	case BinaryHex:
+		return "hex"
+	case BinaryBase64:
+		return "base64"
+	}
+	return "unknown"
+}
+
+// encode returns the data in the format, or false if it's omitted.
+func (f BinaryFormat) encode(data []byte, maxSize int) (string, bool) {
+	if len(data) == 0 || (maxSize > 0 && len(data) > maxSize) {
+		return "", false
+	}
+	switch f {
+	case BinaryHex:
+		return strings.ToUpper(hex.EncodeToString(data)), true
+	case BinaryBase64:
+		return base64.StdEncoding.EncodeToString(data), true
+	}
+	return "", false
+}
+
+// addBinaryData adds the event's Binary data to its EventData under
+// "Binary", unless a value already has that name.
+func (ev *WinLogEvent) addBinaryData(format BinaryFormat, maxSize int) {
+	value, ok := format.encode(ev.Binary, maxSize)
+	if !ok {
+		return
+	}
+	if ev.EventData == nil {
+		ev.EventData = make(map[string]string, 1)
+	} else if _, exists := ev.EventData[binaryDataName]; exists {
+		return
+	}
+	ev.EventData[binaryDataName] = value
+}
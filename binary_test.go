@@ -0,0 +1,51 @@
+package winlog
+
+import (
+	"bytes"
+	. "testing"
+)
+
+func TestParseBinaryData(t *T) {
+	parsed, err := parseEventXml([]byte(`<Event><EventData><Data>disk full</Data><Binary>DEADBEEF</Binary></EventData></Event>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parsed.binary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(bytes.Equal(data, []byte{0xde, 0xad, 0xbe, 0xef}), true, t)
+	assertEqual(parsed.eventData()["Data0"], "disk full", t)
+
+	parsed, _ = parseEventXml([]byte(`<Event><EventData><Binary>XYZ</Binary></EventData></Event>`))
+	if _, err := parsed.binary(); err == nil {
+		t.Fatal("Expected an error for invalid hex")
+	}
+	parsed, _ = parseEventXml([]byte(`<Event><EventData><Data Name="a">b</Data></EventData></Event>`))
+	data, err = parsed.binary()
+	assertEqual(data == nil && err == nil, true, t)
+}
+
+func TestAddBinaryData(t *T) {
+	binary := []byte{0xde, 0xad, 0xbe, 0xef}
+	for _, c := range []struct {
+		format  BinaryFormat
+		maxSize int
+		want    string
+	}{
+		{BinaryOmit, 0, ""},
+		{BinaryHex, 0, "DEADBEEF"},
+		{BinaryBase64, 0, "3q2+7w=="},
+		{BinaryHex, 4, "DEADBEEF"},
+		{BinaryHex, 3, ""},
+	} {
+		ev := &WinLogEvent{Binary: binary}
+		ev.addBinaryData(c.format, c.maxSize)
+		assertEqual(ev.EventData[binaryDataName], c.want, t)
+	}
+
+	// Values named Binary aren't replaced
+	ev := &WinLogEvent{Binary: binary, EventData: map[string]string{"Binary": "value"}}
+	ev.addBinaryData(BinaryHex, 0)
+	assertEqual(ev.EventData["Binary"], "value", t)
+}
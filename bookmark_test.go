@@ -7,17 +7,6 @@ import (
 	. "testing"
 )
 
-type bookmarkListXml struct {
-	XMLName   xml.Name      `xml:"BookmarkList"`
-	Bookmarks []bookmarkXml `xml:"Bookmark"`
-}
-
-type bookmarkXml struct {
-	XMLName  xml.Name `xml:"Bookmark"`
-	RecordId uint64   `xml:"RecordId,attr"`
-	Channel  string   `xml:"Channel,attr"`
-}
-
 func TestSerializeBookmark(t *T) {
 	testBookmarkXml := "<BookmarkList>\r\n  <Bookmark Channel='Application' RecordId='10811' IsCurrent='true'/>\r\n</BookmarkList>"
 	bookmark, err := CreateBookmarkFromXml(testBookmarkXml)
@@ -0,0 +1,137 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+type bookmarkListXml struct {
+	XMLName   xml.Name      `xml:"BookmarkList"`
+	Bookmarks []bookmarkXml `xml:"Bookmark"`
+}
+
+type bookmarkXml struct {
+	XMLName   xml.Name `xml:"Bookmark"`
+	RecordId  uint64   `xml:"RecordId,attr"`
+	Channel   string   `xml:"Channel,attr"`
+	IsCurrent bool     `xml:"IsCurrent,attr"`
+}
+
+func parseBookmarkXml(bookmark string) (*bookmarkListXml, error) {
+	var list bookmarkListXml
+	if bookmark == "" {
+		return &list, nil
+	}
+	if err := xml.Unmarshal([]byte(bookmark), &list); err != nil {
+		return nil, fmt.Errorf("Failed to parse bookmark: %v", err)
+	}
+	return &list, nil
+}
+
+// recordIds returns the RecordId each channel in the bookmark is at.
+func (list *bookmarkListXml) recordIds() map[string]uint64 {
+	ids := make(map[string]uint64, len(list.Bookmarks))
+	for _, b := range list.Bookmarks {
+		if b.RecordId >= ids[b.Channel] {
+			ids[b.Channel] = b.RecordId
+		}
+	}
+	return ids
+}
+
+// CompareBookmarks reports, for each channel in either bookmark, -1 if `a`
+// is behind `b`, 0 if they are at the same record and 1 if `a` is ahead. A
+// channel missing from one of the bookmarks counts as behind. An empty
+// string is treated as a bookmark with no channels.
+func CompareBookmarks(a, b string) (map[string]int, error) {
+	listA, err := parseBookmarkXml(a)
+	if err != nil {
+		return nil, err
+	}
+	listB, err := parseBookmarkXml(b)
+	if err != nil {
+		return nil, err
+	}
+	idsA, idsB := listA.recordIds(), listB.recordIds()
+	result := make(map[string]int)
+	for channel, idA := range idsA {
+		idB, ok := idsB[channel]
+		switch {
+		case !ok || idA > idB:
+			result[channel] = 1
+		case idA < idB:
+			result[channel] = -1
+		default:
+			result[channel] = 0
+		}
+	}
+	for channel := range idsB {
+		if _, ok := idsA[channel]; !ok {
+			result[channel] = -1
+		}
+	}
+	return result, nil
+}
+
+// MergeLatest returns a bookmark which is at the later of the two records
+// for every channel in `a` or `b`, for reconciling the bookmarks of a pair
+// of collectors reading the same logs.
+func MergeLatest(a, b string) (string, error) {
+	listA, err := parseBookmarkXml(a)
+	if err != nil {
+		return "", err
+	}
+	listB, err := parseBookmarkXml(b)
+	if err != nil {
+		return "", err
+	}
+	merged := make(map[string]bookmarkXml)
+	for _, list := range []*bookmarkListXml{listA, listB} {
+		for _, entry := range list.Bookmarks {
+			if existing, ok := merged[entry.Channel]; !ok || entry.RecordId > existing.RecordId {
+				merged[entry.Channel] = entry
+			}
+		}
+	}
+	channels := make([]string, 0, len(merged))
+	for channel := range merged {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	// Keep a single current entry, as the event log does
+	current := ""
+	for _, channel := range channels {
+		if merged[channel].IsCurrent && current == "" {
+			current = channel
+		}
+	}
+	if current == "" && len(channels) > 0 {
+		current = channels[0]
+	}
+
+	// Render in the same layout as EvtRender
+	var buf bytes.Buffer
+	buf.WriteString("<BookmarkList>\r\n")
+	for _, channel := range channels {
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(channel))
+		fmt.Fprintf(&buf, "  <Bookmark Channel='%s' RecordId='%d'", escaped.String(), merged[channel].RecordId)
+		if channel == current {
+			buf.WriteString(" IsCurrent='true'")
+		}
+		buf.WriteString("/>\r\n")
+	}
+	buf.WriteString("</BookmarkList>")
+	return buf.String(), nil
+}
+
+// channelBookmark renders a bookmark at a RecordId of a single channel, in
+// the same layout as EvtRender.
+func channelBookmark(channel string, recordId uint64) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(channel))
+	return fmt.Sprintf("<BookmarkList>\r\n  <Bookmark Channel='%s' RecordId='%d' IsCurrent='true'/>\r\n</BookmarkList>", escaped.String(), recordId)
+}
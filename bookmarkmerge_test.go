@@ -0,0 +1,50 @@
+package winlog
+
+import (
+	"reflect"
+	. "testing"
+)
+
+const (
+	primaryBookmark   = "<BookmarkList>\r\n  <Bookmark Channel='Application' RecordId='20'/>\r\n  <Bookmark Channel='System' RecordId='5' IsCurrent='true'/>\r\n</BookmarkList>"
+	secondaryBookmark = "<BookmarkList>\r\n  <Bookmark Channel='Security' RecordId='7'/>\r\n  <Bookmark Channel='System' RecordId='9' IsCurrent='true'/>\r\n  <Bookmark Channel='Application' RecordId='20'/>\r\n</BookmarkList>"
+)
+
+func TestCompareBookmarks(t *T) {
+	result, err := CompareBookmarks(primaryBookmark, secondaryBookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{"Application": 0, "System": -1, "Security": -1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("%v != %v", result, expected)
+	}
+
+	result, err = CompareBookmarks(primaryBookmark, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(result["System"], 1, t)
+
+	if _, err := CompareBookmarks("<BookmarkList>", primaryBookmark); err == nil {
+		t.Fatal("Expected an error for invalid XML")
+	}
+}
+
+func TestMergeLatest(t *T) {
+	merged, err := MergeLatest(primaryBookmark, secondaryBookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(merged, "<BookmarkList>\r\n  <Bookmark Channel='Application' RecordId='20'/>\r\n  <Bookmark Channel='Security' RecordId='7'/>\r\n  <Bookmark Channel='System' RecordId='9' IsCurrent='true'/>\r\n</BookmarkList>", t)
+
+	result, err := CompareBookmarks(merged, primaryBookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for channel, cmp := range result {
+		if cmp < 0 {
+			t.Fatalf("Merged bookmark is behind on %v", channel)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package winlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BookmarkStore persists the bookmark of each subscription, keyed by channel,
+// so a consumer can resume with SubscribeFromBookmark after a restart. Save
+// an event's Bookmark once the event has been processed.
+type BookmarkStore interface {
+	// Load returns the saved bookmark XML for the channel, or "" if there
+	// is none.
+	Load(channel string) (string, error)
+	Save(channel, bookmark string) error
+	Close() error
+}
+
+// FileBookmarkStore keeps bookmarks in a JSON file. The store holds an
+// exclusive lock on Path+".lock" while open, so two processes can't consume
+// events and overwrite each other's bookmarks.
+//
+// Each save is written to Path+".tmp", synced and renamed over Path, and the
+// previous file is kept as Path+".bak". The file carries a checksum of its
+// contents; if Path is missing, truncated or fails its checksum (e.g. after
+// a power loss) the store falls back to the backup rather than failing.
+type FileBookmarkStore struct {
+	Path string
+	// Set when the store was opened from the backup, or empty, because
+	// the bookmark file couldn't be read.
+	RecoveryErr error
+
+	mutex     sync.Mutex
+	lock      *fileLock
+	bookmarks map[string]string
+	stats     map[string]PersistedStats
+}
+
+// OpenFileBookmarkStore opens or creates the bookmark file at `path`. If
+// another process has the store open, a *StoreLockedError is returned.
+func OpenFileBookmarkStore(path string) (*FileBookmarkStore, error) {
+	lock, err := lockFile(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	store := &FileBookmarkStore{Path: path, lock: lock}
+	store.bookmarks, store.stats, err = readBookmarkFile(path)
+	if err != nil {
+		store.RecoveryErr = err
+		var backupErr error
+		store.bookmarks, store.stats, backupErr = readBookmarkFile(path + ".bak")
+		if backupErr != nil {
+			lock.unlock()
+			return nil, fmt.Errorf("Failed to recover bookmarks from %v: %v", path+".bak", backupErr)
+		}
+	}
+	if store.bookmarks == nil {
+		store.bookmarks = make(map[string]string)
+	}
+	if store.stats == nil {
+		store.stats = make(map[string]PersistedStats)
+	}
+	return store, nil
+}
+
+// Version of the bookmark file written. Files without a version, written
+// before the stats were checksummed, are version 1.
+const bookmarkFileVersion = 2
+
+// Format of the bookmark file. The checksum covers everything else in it,
+// or only the bookmarks in version 1.
+type bookmarkFile struct {
+	Checksum  string
+	Version   int `json:",omitempty"`
+	Bookmarks map[string]string
+	Stats     map[string]PersistedStats `json:",omitempty"`
+}
+
+func (f bookmarkFile) checksum() string {
+	// Map keys are marshalled in sorted order, so this is stable
+	var data []byte
+	if f.Version < 2 {
+		data, _ = json.Marshal(f.Bookmarks)
+	} else {
+		f.Checksum = ""
+		data, _ = json.Marshal(f)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readBookmarkFile returns nil bookmarks and no error if the file is missing
+// and neither it nor the backup has ever been written.
+func readBookmarkFile(path string) (map[string]string, map[string]PersistedStats, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if _, backupErr := os.Stat(path + ".bak"); os.IsNotExist(backupErr) {
+				return nil, nil, nil
+			}
+		}
+		return nil, nil, err
+	}
+	var file bookmarkFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("Failed to read bookmarks from %v: %v", path, err)
+	}
+	if file.Checksum != file.checksum() {
+		return nil, nil, fmt.Errorf("Bookmark file %v failed its checksum", path)
+	}
+	return file.Bookmarks, file.Stats, nil
+}
+
+// writeBookmarkFile replaces the file at `path` without leaving it partially
+// written, keeping the previous version as the backup. The directory is
+// synced after the renames, so they survive a power loss too.
+func writeBookmarkFile(path string, bookmarks map[string]string, stats map[string]PersistedStats) error {
+	contents := bookmarkFile{Version: bookmarkFileVersion, Bookmarks: bookmarks, Stats: stats}
+	contents.Checksum = contents.checksum()
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Failed to write bookmarks to %v: %v", tmp, err)
+	}
+	// Only a file that verifies is worth keeping as the backup
+	if _, _, err := readBookmarkFile(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("Failed to sync %v: %v", filepath.Dir(path), err)
+	}
+	return nil
+}
+
+func (s *FileBookmarkStore) Load(channel string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.lock == nil {
+		return "", fmt.Errorf("Bookmark store %v is closed", s.Path)
+	}
+	return s.bookmarks[channel], nil
+}
+
+func (s *FileBookmarkStore) Save(channel, bookmark string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.lock == nil {
+		return fmt.Errorf("Bookmark store %v is closed", s.Path)
+	}
+	s.bookmarks[channel] = bookmark
+	return writeBookmarkFile(s.Path, s.bookmarks, s.stats)
+}
+
+func (s *FileBookmarkStore) LoadStats(channel string) (PersistedStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.lock == nil {
+		return PersistedStats{}, fmt.Errorf("Bookmark store %v is closed", s.Path)
+	}
+	return s.stats[channel], nil
+}
+
+func (s *FileBookmarkStore) SaveStats(channel string, stats PersistedStats) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.lock == nil {
+		return fmt.Errorf("Bookmark store %v is closed", s.Path)
+	}
+	s.stats[channel] = stats
+	return writeBookmarkFile(s.Path, s.bookmarks, s.stats)
+}
+
+// Close releases the store's lock.
+func (s *FileBookmarkStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.lock == nil {
+		return nil
+	}
+	err := s.lock.unlock()
+	s.lock = nil
+	return err
+}
@@ -0,0 +1,152 @@
+package winlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	. "testing"
+)
+
+func TestFileBookmarkStore(t *T) {
+	dir, err := ioutil.TempDir("", "bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bookmarks.json")
+
+	store, err := OpenFileBookmarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmark, err := store.Load("Security")
+	assertEqual(err, nil, t)
+	assertEqual(bookmark, "", t)
+	if err := store.Save("Security", "<BookmarkList/>"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenFileBookmarkStore(path)
+	locked, ok := err.(*StoreLockedError)
+	if !ok {
+		t.Fatalf("Expected StoreLockedError, got %v", err)
+	}
+	assertEqual(locked.PID, os.Getpid(), t)
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = OpenFileBookmarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	bookmark, err = store.Load("Security")
+	assertEqual(err, nil, t)
+	assertEqual(bookmark, "<BookmarkList/>", t)
+}
+
+func TestFileBookmarkStoreRecovery(t *T) {
+	dir, err := ioutil.TempDir("", "bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bookmarks.json")
+
+	store, err := OpenFileBookmarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bookmark := range []string{"first", "second"} {
+		if err := store.Save("Security", bookmark); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// A torn write leaves the current file truncated
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err = OpenFileBookmarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.RecoveryErr == nil {
+		t.Fatal("Expected RecoveryErr to be set")
+	}
+	bookmark, _ := store.Load("Security")
+	assertEqual(bookmark, "first", t)
+	store.Close()
+
+	// A file that parses but fails its checksum is also rejected
+	if err := ioutil.WriteFile(path, []byte(`{"Checksum": "0", "Bookmarks": {"Security": "third"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path + ".bak")
+	store, err = OpenFileBookmarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if store.RecoveryErr == nil {
+		t.Fatal("Expected RecoveryErr to be set")
+	}
+	bookmark, _ = store.Load("Security")
+	assertEqual(bookmark, "", t)
+}
+
+func TestFileBookmarkStoreChecksum(t *T) {
+	dir, err := ioutil.TempDir("", "bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bookmarks.json")
+
+	store, err := OpenFileBookmarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("Security", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveStats("Security", PersistedStats{Delivered: 5}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// The checksum covers the stats as well as the bookmarks
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Delivered": 5`) {
+		t.Fatalf("No stats in %s", data)
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(string(data), `"Delivered": 5`, `"Delivered": 6`, 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readBookmarkFile(path); err == nil {
+		t.Fatal("Expected the changed stats to fail the checksum")
+	}
+
+	// Files from before the version was added only checksum the bookmarks
+	legacy := bookmarkFile{Bookmarks: map[string]string{"Security": "old"}, Stats: map[string]PersistedStats{"Security": {Delivered: 1}}}
+	legacy.Checksum = legacy.checksum()
+	data, _ = json.Marshal(legacy)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, stats, err := readBookmarkFile(path)
+	assertEqual(err, nil, t)
+	assertEqual(bookmarks["Security"], "old", t)
+	assertEqual(stats["Security"].Delivered, uint64(1), t)
+}
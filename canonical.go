@@ -0,0 +1,45 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Timestamp format used by CanonicalJSON, with the 100ns precision of the
+// event log
+const canonicalTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+// CanonicalJSON renders the event as indented JSON with sorted keys, for
+// golden-file tests and diffing events across library versions. It contains
+// the fields of CreateMap plus any render errors. Xml is a string rather
+// than base64, and Created, ReceivedAt and CollectedTime are in UTC with a
+// fixed number of fractional digits.
+func CanonicalJSON(ev *WinLogEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(canonicalMap(ev)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalMap returns the map CanonicalJSON renders.
+func canonicalMap(ev *WinLogEvent) map[string]interface{} {
+	m := ev.CreateMap()
+	m["Xml"] = string(ev.Xml)
+	Timestamps{Layout: canonicalTimeFormat}.formatTimes(m, ev)
+	errs := map[string]error{
+		"XmlErr":             ev.XmlErr,
+		"RenderedFieldsErr":  ev.RenderedFieldsErr,
+		"PublisherHandleErr": ev.PublisherHandleErr,
+		"EventDataErr":       ev.EventDataErr,
+	}
+	for name, err := range errs {
+		if err != nil {
+			m[name] = err.Error()
+		}
+	}
+	return m
+}
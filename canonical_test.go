@@ -0,0 +1,56 @@
+package winlog
+
+import (
+	"errors"
+	. "testing"
+	"time"
+)
+
+func TestCanonicalJSON(t *T) {
+	ev := &WinLogEvent{
+		Xml:          []byte("<Event/>"),
+		ProviderName: "Provider",
+		EventId:      4624,
+		Created:      time.Date(2021, 3, 4, 5, 6, 7, 100, time.FixedZone("EST", -5*3600)),
+		EventData:    map[string]string{"b": "<2>", "a": "1"},
+		XmlErr:       errors.New("Failed"),
+	}
+	got, err := CanonicalJSON(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "Bookmark": "",
+  "Channel": "",
+  "ChannelText": "",
+  "ComputerName": "",
+  "Created": "2021-03-04T10:06:07.0000001Z",
+  "EventData": {
+    "a": "1",
+    "b": "<2>"
+  },
+  "EventId": 4624,
+  "IdText": "",
+  "Keywords": "",
+  "Level": 0,
+  "LevelText": "",
+  "Msg": "",
+  "Opcode": 0,
+  "OpcodeText": "",
+  "ProcessId": 0,
+  "ProviderName": "Provider",
+  "ProviderText": "",
+  "Qualifiers": 0,
+  "RecordId": 0,
+  "SchemaVersion": 2,
+  "SubscribedChannel": "",
+  "Task": 0,
+  "TaskText": "",
+  "ThreadId": 0,
+  "Version": 0,
+  "Xml": "<Event/>",
+  "XmlErr": "Failed"
+}
+`
+	assertEqual(string(got), want, t)
+}
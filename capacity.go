@@ -0,0 +1,107 @@
+package winlog
+
+import (
+	"time"
+)
+
+// ChannelCapacity estimates whether a channel will overwrite events before
+// a subscription reads them, or for channels which retain events when
+// full, whether it will start rejecting new ones. It's reported in Stats if
+// the watcher's CapacityWarningHorizon is set.
+type ChannelCapacity struct {
+	FileSize   uint64
+	MaxSize    uint64
+	Retention  bool
+	AutoBackup bool
+	// Estimated number of records the channel holds when full, from the
+	// average size of the records in it
+	CapacityRecords uint64
+	// Records written to the channel, and read by the subscription, per
+	// second since the previous check
+	WriteRate float64
+	ReadRate  float64
+	// Whether the channel is expected to lose events, and how soon. A
+	// TimeToLoss of zero with LossExpected means events are being lost
+	// now.
+	LossExpected bool
+	TimeToLoss   time.Duration
+}
+
+// capacitySample is a subscription's position in its channel at one check,
+// to measure the rates of writing and reading between checks.
+type capacitySample struct {
+	time         time.Time
+	headRecordId uint64
+	lastRecordId uint64
+}
+
+// estimateCapacity estimates when the channel will lose events. Once full,
+// a channel which overwrites old events loses unread ones when the records
+// it has room for beyond the subscription's lag are used up, which only
+// happens if events are written faster than they're read. A channel which
+// retains events loses new ones once it's full, unless it's backed up and
+// cleared automatically.
+func estimateCapacity(info ChannelInfo, config ChannelConfig, prev, now capacitySample) ChannelCapacity {
+	c := ChannelCapacity{
+		FileSize:   info.FileSize,
+		MaxSize:    config.MaxSize,
+		Retention:  config.Retention,
+		AutoBackup: config.AutoBackup,
+	}
+	if info.NumberOfRecords > 0 && info.FileSize > 0 {
+		c.CapacityRecords = uint64(float64(config.MaxSize) * float64(info.NumberOfRecords) / float64(info.FileSize))
+	}
+	if elapsed := now.time.Sub(prev.time).Seconds(); !prev.time.IsZero() && elapsed > 0 {
+		// Positions which went backwards, as when the channel is cleared,
+		// don't give a rate
+		if now.headRecordId >= prev.headRecordId {
+			c.WriteRate = float64(now.headRecordId-prev.headRecordId) / elapsed
+		}
+		if now.lastRecordId >= prev.lastRecordId {
+			c.ReadRate = float64(now.lastRecordId-prev.lastRecordId) / elapsed
+		}
+	}
+	if c.CapacityRecords == 0 || (c.Retention && c.AutoBackup) {
+		return c
+	}
+
+	if c.Retention {
+		room := float64(c.CapacityRecords) - float64(info.NumberOfRecords)
+		if room <= 0 {
+			c.LossExpected = true
+		} else if c.WriteRate > 0 {
+			c.LossExpected = true
+			c.TimeToLoss = time.Duration(room / c.WriteRate * float64(time.Second))
+		}
+		return c
+	}
+
+	var lag uint64
+	if now.headRecordId > now.lastRecordId {
+		lag = now.headRecordId - now.lastRecordId
+	}
+	margin := float64(c.CapacityRecords) - float64(lag)
+	if margin <= 0 {
+		c.LossExpected = true
+	} else if shrink := c.WriteRate - c.ReadRate; shrink > 0 {
+		c.LossExpected = true
+		c.TimeToLoss = time.Duration(margin / shrink * float64(time.Second))
+	}
+	return c
+}
+
+// checkCapacity estimates when a subscription's channel will lose events,
+// warning if it's within the CapacityWarningHorizon.
+func (self *WinLogWatcher) checkCapacity(channel string, watch *channelWatcher, info ChannelInfo) {
+	config, err := self.api.ChannelConfig(channel)
+	if err != nil {
+		self.log(LogWarn, "Failed to get channel config", "channel", channel, "error", err)
+		return
+	}
+	capacity := watch.stats.recordCapacity(info, config, time.Now())
+	if capacity.LossExpected && capacity.TimeToLoss < self.CapacityWarningHorizon {
+		self.log(LogWarn, "Channel is expected to lose events before they're read", "channel", channel,
+			"timeToLoss", capacity.TimeToLoss, "writeRate", capacity.WriteRate, "readRate", capacity.ReadRate,
+			"capacityRecords", capacity.CapacityRecords, "retention", capacity.Retention)
+	}
+}
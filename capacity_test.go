@@ -0,0 +1,68 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestEstimateCapacityOverwrite(t *T) {
+	// 1000 records in 1 MB of a 2 MB channel
+	info := ChannelInfo{NumberOfRecords: 1000, FileSize: 1 << 20}
+	config := ChannelConfig{MaxSize: 2 << 20}
+	start := time.Now()
+	prev := capacitySample{time: start, headRecordId: 1000, lastRecordId: 900}
+
+	// Reading keeps up with writing
+	c := estimateCapacity(info, config, prev, capacitySample{time: start.Add(10 * time.Second), headRecordId: 1100, lastRecordId: 1000})
+	assertEqual(c.CapacityRecords, uint64(2000), t)
+	assertEqual(c.WriteRate, float64(10), t)
+	assertEqual(c.ReadRate, float64(10), t)
+	assertEqual(c.LossExpected, false, t)
+
+	// The lag of 190 records grows by 9 a second, leaving 1810 records
+	// before unread ones are overwritten
+	c = estimateCapacity(info, config, prev, capacitySample{time: start.Add(10 * time.Second), headRecordId: 1100, lastRecordId: 910})
+	assertEqual(c.LossExpected, true, t)
+	assertEqual(c.TimeToLoss.Round(time.Second), 201*time.Second, t)
+
+	// The lag exceeds the channel's capacity
+	c = estimateCapacity(info, config, prev, capacitySample{time: start.Add(10 * time.Second), headRecordId: 3000, lastRecordId: 900})
+	assertEqual(c.LossExpected, true, t)
+	assertEqual(c.TimeToLoss, time.Duration(0), t)
+}
+
+func TestEstimateCapacityRetention(t *T) {
+	info := ChannelInfo{NumberOfRecords: 1000, FileSize: 1 << 20}
+	start := time.Now()
+	prev := capacitySample{time: start, headRecordId: 1000, lastRecordId: 1000}
+	now := capacitySample{time: start.Add(10 * time.Second), headRecordId: 1100, lastRecordId: 1100}
+
+	c := estimateCapacity(info, ChannelConfig{MaxSize: 2 << 20, Retention: true}, prev, now)
+	assertEqual(c.LossExpected, true, t)
+	assertEqual(c.TimeToLoss, 100*time.Second, t)
+
+	c = estimateCapacity(info, ChannelConfig{MaxSize: 1 << 20, Retention: true}, prev, now)
+	assertEqual(c.LossExpected, true, t)
+	assertEqual(c.TimeToLoss, time.Duration(0), t)
+
+	c = estimateCapacity(info, ChannelConfig{MaxSize: 1 << 20, Retention: true, AutoBackup: true}, prev, now)
+	assertEqual(c.LossExpected, false, t)
+}
+
+func TestEstimateCapacityFirstSample(t *T) {
+	// Without rates, only a lag already beyond the capacity is a loss
+	info := ChannelInfo{NumberOfRecords: 10, FileSize: 10 * 1024}
+	now := capacitySample{time: time.Now(), headRecordId: 100, lastRecordId: 50}
+	c := estimateCapacity(info, ChannelConfig{MaxSize: 20 * 1024}, capacitySample{}, now)
+	assertEqual(c.WriteRate, float64(0), t)
+	assertEqual(c.LossExpected, true, t)
+
+	c = estimateCapacity(info, ChannelConfig{MaxSize: 100 * 1024}, capacitySample{}, now)
+	assertEqual(c.LossExpected, false, t)
+
+	s := (&WinLogWatcher{}).newSubscriptionStats()
+	s.recordHead(100)
+	s.recordPosition(50)
+	s.recordCapacity(info, ChannelConfig{MaxSize: 20 * 1024}, time.Now())
+	assertEqual(s.snapshot().Capacity.LossExpected, true, t)
+}
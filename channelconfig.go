@@ -0,0 +1,137 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// GetChannelConfig reads the configuration of a channel, including its
+// security descriptor. Wraps EvtOpenChannelConfig and
+// EvtGetChannelConfigProperty.
+func GetChannelConfig(channel string) (ChannelConfig, error) {
+	return GetSessionChannelConfig(0, channel)
+}
+
+// GetSessionChannelConfig reads the configuration of a channel on the
+// computer of a session opened with OpenSession.
+func GetSessionChannelConfig(session SessionHandle, channel string) (ChannelConfig, error) {
+	config, err := openChannelConfig(syscall.Handle(session), channel)
+	if err != nil {
+		return ChannelConfig{}, err
+	}
+	defer EvtClose(config)
+
+	var c ChannelConfig
+	var value EvtVariant
+	property := func(id uint32) error {
+		value, err = channelConfigProperty(config, id)
+		return err
+	}
+	boolean := func(id uint32) bool {
+		if err := property(id); err != nil {
+			return false
+		}
+		elem, err := value.elemAt(0)
+		return err == nil && elem.Type == EvtVarTypeBoolean && uint32(elem.Data) != 0
+	}
+	c.Enabled = boolean(EvtChannelConfigEnabled)
+	c.Classic = boolean(EvtChannelConfigClassicEventlog)
+	c.Retention = boolean(EvtChannelLoggingConfigRetention)
+	c.AutoBackup = boolean(EvtChannelLoggingConfigAutoBackup)
+	if err := property(EvtChannelConfigAccess); err != nil {
+		return ChannelConfig{}, fmt.Errorf("Failed to read access of channel %q: %v", channel, err)
+	}
+	if c.Access, err = value.String(0); err != nil {
+		return ChannelConfig{}, err
+	}
+	if property(EvtChannelLoggingConfigLogFilePath) == nil {
+		c.LogFilePath, _ = value.String(0)
+	}
+	if property(EvtChannelLoggingConfigMaxSize) == nil {
+		c.MaxSize, _ = value.Uint(0)
+	}
+	return c, nil
+}
+
+// SetChannelAccess replaces a channel's security descriptor, after checking
+// it with ValidateChannelSDDL and parsing it as Windows would. Needs
+// administrator rights.
+func SetChannelAccess(channel, sddl string) error {
+	if err := ValidateChannelSDDL(sddl); err != nil {
+		return err
+	}
+	if _, err := windows.SecurityDescriptorFromString(sddl); err != nil {
+		return fmt.Errorf("Invalid security descriptor %q: %v", sddl, err)
+	}
+	config, err := openChannelConfig(0, channel)
+	if err != nil {
+		return err
+	}
+	defer EvtClose(config)
+
+	wide, err := syscall.UTF16PtrFromString(sddl)
+	if err != nil {
+		return err
+	}
+	value := evtVariant{Data: uint64(uintptr(unsafe.Pointer(wide))), Type: EvtVarTypeString}
+	if err := EvtSetChannelConfigProperty(config, EvtChannelConfigAccess, 0, (*byte)(unsafe.Pointer(&value))); err != nil {
+		return fmt.Errorf("Failed to set access of channel %q: %v", channel, err)
+	}
+	if err := EvtSaveChannelConfig(config, 0); err != nil {
+		return fmt.Errorf("Failed to save configuration of channel %q: %v", channel, err)
+	}
+	return nil
+}
+
+// GrantChannelRead allows the account with the SID, such as a service
+// account, to read a channel, by adding an ACE to the channel's security
+// descriptor. It does nothing if the SID can already read the channel.
+// Needs administrator rights.
+func GrantChannelRead(channel, sid string) error {
+	if _, err := windows.StringToSid(sid); err != nil {
+		return fmt.Errorf("Invalid SID %q: %v", sid, err)
+	}
+	config, err := GetChannelConfig(channel)
+	if err != nil {
+		return err
+	}
+	sddl, changed, err := grantChannelRead(config.Access, sid)
+	if err != nil || !changed {
+		return err
+	}
+	return SetChannelAccess(channel, sddl)
+}
+
+func openChannelConfig(session syscall.Handle, channel string) (syscall.Handle, error) {
+	wide, err := syscall.UTF16PtrFromString(channel)
+	if err != nil {
+		return 0, err
+	}
+	config, err := EvtOpenChannelConfig(session, wide, 0)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to open configuration of channel %q: %v", channel, err)
+	}
+	return config, nil
+}
+
+// channelConfigProperty reads a property into an EVT_VARIANT buffer,
+// growing it as needed.
+func channelConfigProperty(config syscall.Handle, id uint32) (EvtVariant, error) {
+	buf := make([]byte, 256)
+	var used uint32
+	err := EvtGetChannelConfigProperty(config, id, 0, uint32(len(buf)), &buf[0], &used)
+	if err == windows.ERROR_INSUFFICIENT_BUFFER {
+		buf = make([]byte, used)
+		err = EvtGetChannelConfigProperty(config, id, 0, uint32(len(buf)), &buf[0], &used)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewEvtVariant(buf), nil
+}
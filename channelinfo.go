@@ -0,0 +1,62 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// GetChannelInfo reads the record counts and size of a channel. Wraps
+// EvtOpenLog and EvtGetLogInfo.
+func GetChannelInfo(channel string) (ChannelInfo, error) {
+	return getChannelInfo(0, channel, EvtOpenChannelPath)
+}
+
+// GetSessionChannelInfo reads the record counts and size of a channel on
+// the computer of a session opened with OpenSession.
+func GetSessionChannelInfo(session SessionHandle, channel string) (ChannelInfo, error) {
+	return getChannelInfo(syscall.Handle(session), channel, EvtOpenChannelPath)
+}
+
+func getChannelInfo(session syscall.Handle, path string, flags uint32) (ChannelInfo, error) {
+	widePath, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+	log, err := EvtOpenLog(session, widePath, flags)
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+	defer EvtClose(log)
+
+	property := func(id uint32) (uint64, error) {
+		var value evtVariant
+		var used uint32
+		buf := (*[unsafe.Sizeof(value)]byte)(unsafe.Pointer(&value))
+		if err := EvtGetLogInfo(log, id, uint32(len(buf)), &buf[0], &used); err != nil {
+			return 0, err
+		}
+		return value.Data, nil
+	}
+
+	var info ChannelInfo
+	if info.NumberOfRecords, err = property(EvtLogNumberOfLogRecords); err != nil {
+		return ChannelInfo{}, err
+	}
+	oldest, err := property(EvtLogOldestRecordNumber)
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+	if info.NumberOfRecords > 0 {
+		info.OldestRecordId = oldest
+		info.NewestRecordId = oldest + info.NumberOfRecords - 1
+	}
+	// Size and fullness are informational, so don't fail if they're missing
+	info.FileSize, _ = property(EvtLogFileSize)
+	if full, err := property(EvtLogFull); err == nil {
+		info.Full = full&0xff != 0
+	}
+	return info, nil
+}
@@ -0,0 +1,162 @@
+package winlog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Access rights in channel security descriptors
+const (
+	ChannelReadAccess  = 0x1
+	ChannelWriteAccess = 0x2
+	ChannelClearAccess = 0x4
+)
+
+// SIDs in string form, such as "S-1-5-32-573", or SDDL aliases such as "BA"
+var sidPattern = regexp.MustCompile(`^(S-1-\d+(-\d+)*|[A-Z]{2})$`)
+
+// ACE types allowed in a DACL
+var daclAceTypes = map[string]bool{"A": true, "D": true, "OA": true, "OD": true, "XA": true, "XD": true, "ZA": true}
+
+// sddlComponent is one of the "O:", "G:", "D:" or "S:" parts of a
+// security descriptor string, with its position.
+type sddlComponent struct {
+	kind       byte
+	start, end int
+	value      string
+}
+
+// splitSDDL splits a security descriptor string into its components.
+// Component markers inside ACEs, which are in parentheses, are ignored.
+func splitSDDL(sddl string) ([]sddlComponent, error) {
+	var components []sddlComponent
+	depth := 0
+	for i := 0; i < len(sddl); i++ {
+		switch c := sddl[i]; {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("Unbalanced ')' at offset %v", i)
+			}
+		case depth == 0 && i+1 < len(sddl) && sddl[i+1] == ':' && strings.IndexByte("OGDS", c) >= 0:
+			if len(components) > 0 {
+				components[len(components)-1].end = i
+			}
+			components = append(components, sddlComponent{kind: c, start: i})
+			i++
+		case depth == 0 && len(components) == 0:
+			return nil, fmt.Errorf("Unexpected character %q at offset %v", c, i)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("Unbalanced '(' in security descriptor")
+	}
+	for i := range components {
+		c := &components[i]
+		if c.end == 0 {
+			c.end = len(sddl)
+		}
+		c.value = sddl[c.start+2 : c.end]
+	}
+	return components, nil
+}
+
+// aces returns the ACEs of a DACL or SACL component, without parentheses.
+func (c sddlComponent) aces() ([]string, error) {
+	value := c.value
+	flagsEnd := strings.IndexByte(value, '(')
+	if flagsEnd < 0 {
+		return nil, nil
+	}
+	var aces []string
+	for rest := value[flagsEnd:]; rest != ""; {
+		end := strings.IndexByte(rest, ')')
+		if rest[0] != '(' || end < 0 {
+			return nil, fmt.Errorf("Malformed ACE list %q", value[flagsEnd:])
+		}
+		aces = append(aces, rest[1:end])
+		rest = rest[end+1:]
+	}
+	return aces, nil
+}
+
+// ValidateChannelSDDL checks a security descriptor is well formed before
+// it's applied to a channel. It must have a DACL, since a channel without
+// one is open to everyone, and each of the DACL's ACEs must have the six
+// fields of an ACE string, an allow or deny type, access rights and a SID.
+func ValidateChannelSDDL(sddl string) error {
+	components, err := splitSDDL(sddl)
+	if err != nil {
+		return fmt.Errorf("Invalid security descriptor %q: %v", sddl, err)
+	}
+	seen := make(map[byte]bool)
+	var dacl *sddlComponent
+	for i, c := range components {
+		if seen[c.kind] {
+			return fmt.Errorf("Invalid security descriptor %q: more than one %c: component", sddl, c.kind)
+		}
+		seen[c.kind] = true
+		if (c.kind == 'O' || c.kind == 'G') && !sidPattern.MatchString(c.value) {
+			return fmt.Errorf("Invalid security descriptor %q: invalid SID %q", sddl, c.value)
+		}
+		if c.kind == 'D' {
+			dacl = &components[i]
+		}
+	}
+	if dacl == nil {
+		return fmt.Errorf("Invalid security descriptor %q: no DACL", sddl)
+	}
+	aces, err := dacl.aces()
+	if err != nil {
+		return fmt.Errorf("Invalid security descriptor %q: %v", sddl, err)
+	}
+	for _, ace := range aces {
+		fields := strings.Split(ace, ";")
+		if len(fields) != 6 {
+			return fmt.Errorf("Invalid ACE %q: expected 6 fields, got %v", ace, len(fields))
+		}
+		if !daclAceTypes[fields[0]] {
+			return fmt.Errorf("Invalid ACE %q: type %q isn't allowed in a DACL", ace, fields[0])
+		}
+		if fields[2] == "" {
+			return fmt.Errorf("Invalid ACE %q: no access rights", ace)
+		}
+		if !sidPattern.MatchString(fields[5]) {
+			return fmt.Errorf("Invalid ACE %q: invalid SID %q", ace, fields[5])
+		}
+	}
+	return nil
+}
+
+// grantChannelRead adds an ACE allowing `sid` to read the channel to the
+// DACL of `sddl`. Returns false if an ACE already allows it.
+func grantChannelRead(sddl, sid string) (string, bool, error) {
+	if !sidPattern.MatchString(sid) {
+		return "", false, fmt.Errorf("Invalid SID %q", sid)
+	}
+	if err := ValidateChannelSDDL(sddl); err != nil {
+		return "", false, err
+	}
+	components, _ := splitSDDL(sddl)
+	for _, c := range components {
+		if c.kind != 'D' {
+			continue
+		}
+		aces, _ := c.aces()
+		for _, ace := range aces {
+			fields := strings.Split(ace, ";")
+			if fields[0] != "A" || !strings.EqualFold(fields[5], sid) {
+				continue
+			}
+			if rights, err := strconv.ParseUint(fields[2], 0, 32); err == nil && rights&ChannelReadAccess != 0 {
+				return sddl, false, nil
+			}
+		}
+		ace := fmt.Sprintf("(A;;0x%x;;;%v)", ChannelReadAccess, sid)
+		return sddl[:c.end] + ace + sddl[c.end:], true, nil
+	}
+	return "", false, fmt.Errorf("Invalid security descriptor %q: no DACL", sddl)
+}
@@ -0,0 +1,67 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+// Default security descriptor of the Security log
+const securitySDDL = "O:BAG:SYD:(A;;0xf0005;;;SY)(A;;0x5;;;BA)(A;;0x1;;;S-1-5-32-573)"
+
+func TestValidateChannelSDDL(t *T) {
+	for _, sddl := range []string{
+		securitySDDL,
+		"O:BAG:SYD:(A;;0x2;;;S-1-15-2-1)(A;;0xf0007;;;SY)S:(AU;FA;0x7;;;WD)",
+		"D:P(A;;0x1;;;AU)",
+	} {
+		if err := ValidateChannelSDDL(sddl); err != nil {
+			t.Fatalf("%v: %v", sddl, err)
+		}
+	}
+	for _, sddl := range []string{
+		"",
+		"O:BAG:SY",
+		"D:(A;;0x1;;;SY",
+		"D:(A;;0x1;;SY)",
+		"D:(AU;;0x1;;;SY)",
+		"D:(A;;;;;SY)",
+		"D:(A;;0x1;;;not a sid)",
+		"O:nobody D:(A;;0x1;;;SY)",
+		"D:(A;;0x1;;;SY)D:(A;;0x1;;;BA)",
+		"X D:(A;;0x1;;;SY)",
+	} {
+		if err := ValidateChannelSDDL(sddl); err == nil {
+			t.Fatalf("%q was valid", sddl)
+		}
+	}
+}
+
+func TestGrantChannelRead(t *T) {
+	sddl, changed, err := grantChannelRead(securitySDDL, "S-1-5-21-1-2-3-1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(changed, true, t)
+	assertEqual(sddl, securitySDDL+"(A;;0x1;;;S-1-5-21-1-2-3-1001)", t)
+
+	// The new ACE goes in the DACL, before the SACL
+	sddl, _, err = grantChannelRead("O:BAG:SYD:(A;;0x7;;;SY)S:(AU;FA;0x7;;;WD)", "NS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(sddl, "O:BAG:SYD:(A;;0x7;;;SY)(A;;0x1;;;NS)S:(AU;FA;0x7;;;WD)", t)
+
+	// Event Log Readers can already read the Security log
+	sddl, changed, err = grantChannelRead(securitySDDL, "S-1-5-32-573")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(changed, false, t)
+	assertEqual(sddl, securitySDDL, t)
+
+	if _, _, err := grantChannelRead(securitySDDL, "Administrator"); err == nil {
+		t.Fatal("Granted read to an account name")
+	}
+	if _, _, err := grantChannelRead("O:BAG:SY", "SY"); err == nil {
+		t.Fatal("Granted read without a DACL")
+	}
+}
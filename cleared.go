@@ -0,0 +1,86 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Events the event log service logs when a channel is cleared: 1102 in the
+// Security log when it's cleared, and 104 in the System log when any other
+// channel is
+const (
+	EventLogProviderName    = "Microsoft-Windows-Eventlog"
+	EventIdSecurityCleared  = 1102
+	EventIdLogCleared       = 104
+	clearedChannelDataField = "Channel"
+)
+
+// NotifyChannelCleared reports that a channel was cleared, found from the
+// event log's own event or from the channel's RecordIds going backwards.
+// Defenders treat clearing a log as a detection in itself.
+const NotifyChannelCleared NotificationKind = "channel_cleared"
+
+// clearedChannel returns the channel an event reports was cleared, if it's
+// one of the event log's clearing events.
+func clearedChannel(ev *WinLogEvent) (string, bool) {
+	if !strings.EqualFold(ev.ProviderName, EventLogProviderName) {
+		return "", false
+	}
+	switch {
+	case ev.EventId == EventIdSecurityCleared && strings.EqualFold(ev.Channel, "Security"):
+		return "Security", true
+	case ev.EventId == EventIdLogCleared && strings.EqualFold(ev.Channel, "System"):
+		// The cleared channel is in the UserData, or EventData if the
+		// provider's manifest named its fields differently
+		if channel := ev.UserData[clearedChannelDataField]; channel != "" {
+			return channel, true
+		}
+		if channel := ev.EventData[clearedChannelDataField]; channel != "" {
+			return channel, true
+		}
+		return "System", true
+	}
+	return "", false
+}
+
+// clearedBy returns the account which cleared a channel, from its clearing
+// event, or "" if the event doesn't say.
+func clearedBy(ev *WinLogEvent) string {
+	for _, data := range []map[string]string{ev.UserData, ev.EventData} {
+		if user := data["SubjectUserName"]; user != "" {
+			if domain := data["SubjectDomainName"]; domain != "" {
+				return domain + `\` + user
+			}
+			return user
+		}
+	}
+	return ""
+}
+
+// checkCleared notifies when an event shows a channel was cleared: it's the
+// event log's clearing event, or the subscription's RecordIds went back
+// from `previous`. RecordIds are only compared if `ordered`, when the
+// subscription reads one channel's records in order.
+func (self *WinLogWatcher) checkCleared(subscribedChannel string, ev *WinLogEvent, previous uint64, ordered bool) {
+	channel, logged := clearedChannel(ev)
+	regressed := ordered && previous != 0 && ev.RecordId != 0 && ev.RecordId < previous
+	if !logged && !regressed {
+		return
+	}
+	n := Notification{Kind: NotifyChannelCleared, Channel: channel, RecordId: ev.RecordId}
+	if regressed || strings.EqualFold(channel, subscribedChannel) {
+		// Where the subscription was in the channel before it was cleared
+		n.PreviousRecordId = previous
+	}
+	if logged {
+		n.Message = fmt.Sprintf("%v log cleared", channel)
+		if user := clearedBy(ev); user != "" {
+			n.Message += " by " + user
+		}
+	} else {
+		n.Channel = subscribedChannel
+		n.Message = fmt.Sprintf("RecordIds went back from %v to %v, the channel was cleared", previous, ev.RecordId)
+	}
+	self.log(LogWarn, "Channel cleared", "channel", n.Channel, "previousRecordId", n.PreviousRecordId, "recordId", n.RecordId, "message", n.Message)
+	self.notify(n)
+}
@@ -0,0 +1,117 @@
+package winlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	. "testing"
+	"time"
+)
+
+func TestClearedChannel(t *T) {
+	ev := &WinLogEvent{ProviderName: EventLogProviderName, Channel: "Security", EventId: EventIdSecurityCleared,
+		UserData: map[string]string{"SubjectUserName": "alice", "SubjectDomainName": "CORP"}}
+	channel, ok := clearedChannel(ev)
+	assertEqual(ok, true, t)
+	assertEqual(channel, "Security", t)
+	assertEqual(clearedBy(ev), `CORP\alice`, t)
+
+	ev = &WinLogEvent{ProviderName: EventLogProviderName, Channel: "System", EventId: EventIdLogCleared,
+		UserData: map[string]string{"Channel": "Application", "SubjectUserName": "bob"}}
+	channel, ok = clearedChannel(ev)
+	assertEqual(ok, true, t)
+	assertEqual(channel, "Application", t)
+	assertEqual(clearedBy(ev), "bob", t)
+
+	// The same IDs from other providers or channels aren't clearing events
+	_, ok = clearedChannel(&WinLogEvent{ProviderName: "Other", Channel: "Security", EventId: EventIdSecurityCleared})
+	assertEqual(ok, false, t)
+	_, ok = clearedChannel(&WinLogEvent{ProviderName: EventLogProviderName, Channel: "Application", EventId: EventIdLogCleared})
+	assertEqual(ok, false, t)
+}
+
+func TestCheckCleared(t *T) {
+	watcher, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	ev := &WinLogEvent{ProviderName: EventLogProviderName, Channel: "Security", EventId: EventIdSecurityCleared, RecordId: 1,
+		UserData: map[string]string{"SubjectUserName": "alice"}}
+	watcher.checkCleared("Security", ev, 500, true)
+	n := nextNotification(watcher, t)
+	assertEqual(n.Kind, NotifyChannelCleared, t)
+	assertEqual(n.Channel, "Security", t)
+	assertEqual(n.PreviousRecordId, uint64(500), t)
+	assertEqual(n.RecordId, uint64(1), t)
+	assertEqual(n.Message, "Security log cleared by alice", t)
+
+	// Events out of order aren't a regression unless the subscription is
+	// ordered
+	watcher.checkCleared("Application", &WinLogEvent{RecordId: 3}, 10, false)
+	watcher.checkCleared("Application", &WinLogEvent{RecordId: 11}, 10, true)
+	select {
+	case n := <-watcher.Notifications():
+		t.Fatalf("Unexpected notification %v", n)
+	default:
+	}
+}
+
+func TestChannelClearedRecordIdRegression(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(nextNotification(watcher, t).Kind, NotifySubscribed, t)
+	go func() {
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(50)})
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(3)})
+	}()
+	<-watcher.Event()
+	<-watcher.Event()
+	n := nextNotification(watcher, t)
+	assertEqual(n.Kind, NotifyChannelCleared, t)
+	assertEqual(n.Channel, "Application", t)
+	assertEqual(n.PreviousRecordId, uint64(50), t)
+	assertEqual(n.RecordId, uint64(3), t)
+}
+
+func TestChannelClearedWhileDown(t *T) {
+	dir, err := ioutil.TempDir("", "cleared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := OpenFileBookmarkStore(filepath.Join(dir, "bookmarks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.SaveStats("Application", PersistedStats{LastRecordId: 900, LastRun: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	api := headAPI{fakeAPI: newFakeAPI(), info: ChannelInfo{NumberOfRecords: 5, OldestRecordId: 1, NewestRecordId: 5}}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, BookmarkStore: store, PersistStatsInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[NotificationKind]Notification)
+	for i := 0; i < 2; i++ {
+		n := nextNotification(watcher, t)
+		kinds[n.Kind] = n
+	}
+	n, ok := kinds[NotifyChannelCleared]
+	assertEqual(ok, true, t)
+	assertEqual(n.PreviousRecordId, uint64(900), t)
+	assertEqual(n.RecordId, uint64(5), t)
+	assertEqual(watcher.Stats()["Application"].WrittenWhileDown, uint64(5), t)
+}
@@ -0,0 +1,145 @@
+package winlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Length of the windows over which the smallest and largest offsets of
+// each host's events are kept. Reported offsets cover the current and
+// previous window, so a corrected clock stops being reported as skewed
+// within two windows.
+const clockSkewWindow = 10 * time.Minute
+
+// HostClockSkew compares the TimeCreated of the events from one computer
+// with when the watcher received them, reported in Stats if the watcher's
+// ClockSkewThreshold is set.
+type HostClockSkew struct {
+	Host   string
+	Events uint64
+	// Receipt time minus TimeCreated, for the last event and the smallest
+	// and largest in the last 10 to 20 minutes. Offsets include the time
+	// events took to arrive, so the smallest is the best estimate of the
+	// skew: negative if the host's clock is ahead of this one, positive if
+	// it's behind. Reading a backlog looks like a clock which is behind.
+	LastOffset   time.Duration
+	MinOffset    time.Duration
+	MaxOffset    time.Duration
+	LastReceived time.Time
+	// Whether MinOffset is beyond the ClockSkewThreshold either way
+	Skewed bool
+}
+
+type hostSkew struct {
+	events       uint64
+	last         time.Duration
+	lastReceived time.Time
+	// Smallest and largest offsets in the current and previous windows
+	windowStart      time.Time
+	min, max         time.Duration
+	prevMin, prevMax time.Duration
+	hasPrev          bool
+	skewed           bool
+}
+
+// skewTracker tracks the clock skew of each computer events come from.
+type skewTracker struct {
+	threshold time.Duration
+
+	mutex sync.Mutex
+	hosts map[string]*hostSkew
+}
+
+func newSkewTracker(threshold time.Duration) *skewTracker {
+	return &skewTracker{threshold: threshold, hosts: make(map[string]*hostSkew)}
+}
+
+// observe records an event received at `now`, returning the host's skew
+// and whether it became skewed or stopped being skewed.
+func (t *skewTracker) observe(ev *WinLogEvent, now time.Time) (HostClockSkew, bool) {
+	if ev.Created.IsZero() {
+		return HostClockSkew{}, false
+	}
+	offset := now.Sub(ev.Created)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	h := t.hosts[ev.ComputerName]
+	if h == nil {
+		h = &hostSkew{}
+		t.hosts[ev.ComputerName] = h
+	}
+	if h.events == 0 || now.Sub(h.windowStart) >= 2*clockSkewWindow {
+		h.windowStart, h.min, h.max, h.hasPrev = now, offset, offset, false
+	} else if now.Sub(h.windowStart) >= clockSkewWindow {
+		h.prevMin, h.prevMax, h.hasPrev = h.min, h.max, true
+		h.windowStart, h.min, h.max = now, offset, offset
+	}
+	if offset < h.min {
+		h.min = offset
+	}
+	if offset > h.max {
+		h.max = offset
+	}
+	h.events++
+	h.last = offset
+	h.lastReceived = now
+
+	skew := t.report(ev.ComputerName, h)
+	changed := skew.Skewed != h.skewed
+	h.skewed = skew.Skewed
+	return skew, changed
+}
+
+func (t *skewTracker) report(host string, h *hostSkew) HostClockSkew {
+	skew := HostClockSkew{
+		Host:         host,
+		Events:       h.events,
+		LastOffset:   h.last,
+		MinOffset:    h.min,
+		MaxOffset:    h.max,
+		LastReceived: h.lastReceived,
+	}
+	if h.hasPrev {
+		if h.prevMin < skew.MinOffset {
+			skew.MinOffset = h.prevMin
+		}
+		if h.prevMax > skew.MaxOffset {
+			skew.MaxOffset = h.prevMax
+		}
+	}
+	skew.Skewed = skew.MinOffset > t.threshold || skew.MinOffset < -t.threshold
+	return skew
+}
+
+// snapshot returns the skew of each host, ordered by host.
+func (t *skewTracker) snapshot() []HostClockSkew {
+	t.mutex.Lock()
+	skews := make([]HostClockSkew, 0, len(t.hosts))
+	for host, h := range t.hosts {
+		skews = append(skews, t.report(host, h))
+	}
+	t.mutex.Unlock()
+	sort.Slice(skews, func(i, j int) bool {
+		return skews[i].Host < skews[j].Host
+	})
+	return skews
+}
+
+// checkClockSkew records how far an event's TimeCreated is from when it was
+// received, warning when its host's clock becomes skewed.
+func (self *WinLogWatcher) checkClockSkew(channel string, stats *subscriptionStats, ev *WinLogEvent, now time.Time) {
+	if stats.skew == nil {
+		return
+	}
+	skew, changed := stats.skew.observe(ev, now)
+	if !changed {
+		return
+	}
+	if skew.Skewed {
+		self.log(LogWarn, "Host's clock is skewed", "channel", channel, "host", skew.Host,
+			"minOffset", skew.MinOffset, "lastOffset", skew.LastOffset, "threshold", self.ClockSkewThreshold)
+	} else {
+		self.log(LogInfo, "Host's clock is no longer skewed", "channel", channel, "host", skew.Host, "minOffset", skew.MinOffset)
+	}
+}
@@ -0,0 +1,50 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestSkewTracker(t *T) {
+	tracker := newSkewTracker(time.Minute)
+	now := time.Now()
+	event := func(host string, offset time.Duration, at time.Time) (HostClockSkew, bool) {
+		return tracker.observe(&WinLogEvent{ComputerName: host, Created: at.Add(-offset)}, at)
+	}
+
+	// Transport delays don't make a host skewed
+	skew, changed := event("ws01", 2*time.Second, now)
+	assertEqual(skew.Skewed, false, t)
+	assertEqual(changed, false, t)
+	event("ws01", 90*time.Second, now)
+
+	// Events from the future mean the host's clock is ahead
+	skew, changed = event("ws02", -5*time.Minute, now)
+	assertEqual(skew.Skewed, true, t)
+	assertEqual(changed, true, t)
+	skew, changed = event("ws02", -5*time.Minute+time.Second, now)
+	assertEqual(changed, false, t)
+	assertEqual(skew.MinOffset, -5*time.Minute, t)
+	assertEqual(skew.LastOffset, -5*time.Minute+time.Second, t)
+
+	skews := tracker.snapshot()
+	assertEqual(len(skews), 2, t)
+	assertEqual(skews[0].Host, "ws01", t)
+	assertEqual(skews[0].Events, uint64(2), t)
+	assertEqual(skews[0].MinOffset, 2*time.Second, t)
+	assertEqual(skews[0].MaxOffset, 90*time.Second, t)
+	assertEqual(skews[0].Skewed, false, t)
+
+	// Once the clock is corrected, the skew is forgotten after two windows
+	skew, _ = event("ws02", time.Second, now.Add(clockSkewWindow))
+	assertEqual(skew.Skewed, true, t)
+	skew, changed = event("ws02", time.Second, now.Add(2*clockSkewWindow))
+	assertEqual(skew.Skewed, false, t)
+	assertEqual(changed, true, t)
+	assertEqual(skew.MinOffset, time.Second, t)
+
+	// Events without a creation time are ignored
+	_, changed = tracker.observe(&WinLogEvent{ComputerName: "ws03"}, now)
+	assertEqual(changed, false, t)
+	assertEqual(len(tracker.snapshot()), 2, t)
+}
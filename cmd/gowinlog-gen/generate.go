@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Config is what Generate generates.
+type Config struct {
+	Package  string
+	Provider string
+	// Templates of the provider's events
+	Templates winlog.EventTemplates
+	// Events to generate, every one in Templates if empty
+	EventIds []uint64
+}
+
+type genEvent struct {
+	EventId uint64
+	Version uint64
+	Name    string
+	Fields  []genField
+}
+
+type genField struct {
+	// Go name, EventData key, Go type and the decoder expression
+	Name   string
+	Key    string
+	Type   string
+	Decode string
+	// The manifest types, and how the value is kept if not by type
+	Comment string
+}
+
+// Generate returns the gofmt-ed source of a file declaring a struct for each
+// event and version in the config's templates, a function decoding each
+// from WinLogEvent.EventData, and Decode choosing between them.
+func Generate(config Config) ([]byte, error) {
+	keys := make([]winlog.TemplateKey, 0, len(config.Templates))
+	for key := range config.Templates {
+		if len(config.EventIds) == 0 || containsId(config.EventIds, key.EventId) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No templates for the events of %q", config.Provider)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].EventId != keys[j].EventId {
+			return keys[i].EventId < keys[j].EventId
+		}
+		return keys[i].Version < keys[j].Version
+	})
+	events := make([]genEvent, len(keys))
+	for i, key := range keys {
+		events[i] = genEvent{
+			EventId: key.EventId,
+			Version: key.Version,
+			Name:    fmt.Sprintf("Event%dV%d", key.EventId, key.Version),
+			Fields:  genFields(config.Templates[key]),
+		}
+	}
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, map[string]interface{}{
+		"Package":  config.Package,
+		"Provider": config.Provider,
+		"Events":   events,
+	})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to format generated source: %v", err)
+	}
+	return src, nil
+}
+
+func containsId(ids []uint64, id uint64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func genFields(fields []winlog.TemplateField) []genField {
+	used := make(map[string]bool, len(fields))
+	gen := make([]genField, len(fields))
+	for i, field := range fields {
+		key := field.Name
+		if key == "" {
+			// Matches the names given to unnamed <Data> elements
+			key = fmt.Sprintf("Data%d", i)
+		}
+		name := goName(key)
+		if used[name] {
+			name = fmt.Sprintf("%v%d", name, i)
+		}
+		used[name] = true
+		goType, decode := fieldType(field)
+		comment := strings.TrimSpace(field.InType + " " + field.OutType)
+		switch {
+		case len(field.Fields) > 0:
+			comment = "struct, as rendered"
+		case field.Count != "":
+			comment += ", array as rendered"
+		}
+		gen[i] = genField{
+			Name:    name,
+			Key:     key,
+			Type:    goType,
+			Decode:  fmt.Sprintf(decode, fmt.Sprintf("%q", key)),
+			Comment: comment,
+		}
+	}
+	return gen
+}
+
+// fieldType returns the Go type of a template parameter, and the decoder
+// call reading it, with %v for the EventData key. Arrays and structs are
+// kept as the text the event log renders them as.
+func fieldType(field winlog.TemplateField) (string, string) {
+	if field.Count != "" || len(field.Fields) > 0 {
+		return "string", "d.str(%v)"
+	}
+	switch field.InType {
+	case "win:Int8":
+		return "int8", "int8(d.int(%v, 8))"
+	case "win:Int16":
+		return "int16", "int16(d.int(%v, 16))"
+	case "win:Int32":
+		return "int32", "int32(d.int(%v, 32))"
+	case "win:Int64":
+		return "int64", "d.int(%v, 64)"
+	case "win:UInt8":
+		return "uint8", "uint8(d.uint(%v, 8))"
+	case "win:UInt16":
+		return "uint16", "uint16(d.uint(%v, 16))"
+	case "win:UInt32", "win:HexInt32":
+		return "uint32", "uint32(d.uint(%v, 32))"
+	case "win:UInt64", "win:HexInt64", "win:Pointer":
+		return "uint64", "d.uint(%v, 64)"
+	case "win:Float":
+		return "float32", "float32(d.float(%v, 32))"
+	case "win:Double":
+		return "float64", "d.float(%v, 64)"
+	case "win:Boolean":
+		return "bool", "d.bool(%v)"
+	case "win:FILETIME", "win:SYSTEMTIME":
+		return "time.Time", "d.time(%v)"
+	case "win:Binary":
+		return "[]byte", "d.bytes(%v)"
+	}
+	return "string", "d.str(%v)"
+}
+
+// goName makes an exported Go identifier of a parameter name, such as
+// "TargetUserName" or "Logon ID".
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "F" + s
+	}
+	return s
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by gowinlog-gen from the templates of {{.Provider}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// ProviderName is the provider whose events are decoded
+const ProviderName = {{printf "%q" .Provider}}
+{{range .Events}}
+// {{.Name}} is the EventData of event {{.EventId}} version {{.Version}}.
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+
+// Decode{{.Name}} reads the EventData of event {{.EventId}} version {{.Version}}.
+func Decode{{.Name}}(ev *winlog.WinLogEvent) (*{{.Name}}, error) {
+	d, err := newDecoder(ev)
+	if err != nil {
+		return nil, err
+	}
+	e := &{{.Name}}{}
+{{- range .Fields}}
+	e.{{.Name}} = {{.Decode}}
+{{- end}}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return e, nil
+}
+{{end}}
+// Decode reads the EventData of an event into the struct generated for its
+// ID and version, such as *{{(index .Events 0).Name}}, or returns nil if
+// there's none.
+func Decode(ev *winlog.WinLogEvent) (interface{}, error) {
+	if !strings.EqualFold(ev.ProviderName, ProviderName) {
+		return nil, nil
+	}
+	var e interface{}
+	var err error
+	switch {
+{{- range .Events}}
+	case ev.EventId&0xffff == {{.EventId}} && ev.Version == {{.Version}}:
+		e, err = Decode{{.Name}}(ev)
+{{- end}}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// decoder reads EventData values, remembering the first malformed one.
+type decoder struct {
+	data map[string]string
+	err  error
+}
+
+func newDecoder(ev *winlog.WinLogEvent) (*decoder, error) {
+	data := ev.EventData
+	if data == nil && len(ev.Xml) > 0 {
+		var err error
+		if data, err = winlog.ParseEventData(ev.Xml); err != nil {
+			return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+		}
+	}
+	return &decoder{data: data}, nil
+}
+
+func (d *decoder) fail(name, value string, err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("Failed to decode %v %q: %v", name, value, err)
+	}
+}
+
+// str returns a value, with "-", which the event log uses for a missing
+// value, as "".
+func (d *decoder) str(name string) string {
+	value := strings.TrimSpace(d.data[name])
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+func (d *decoder) int(name string, bits int) int64 {
+	value := d.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 0, bits)
+	if err != nil {
+		d.fail(name, value, err)
+	}
+	return n
+}
+
+// uint parses a decimal or 0x-prefixed hexadecimal value, as hexadecimal
+// types and pointers are rendered.
+func (d *decoder) uint(name string, bits int) uint64 {
+	value := d.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 0, bits)
+	if err != nil {
+		d.fail(name, value, err)
+	}
+	return n
+}
+
+func (d *decoder) float(name string, bits int) float64 {
+	value := d.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(value, bits)
+	if err != nil {
+		d.fail(name, value, err)
+	}
+	return n
+}
+
+func (d *decoder) bool(name string) bool {
+	value := d.str(name)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		d.fail(name, value, err)
+	}
+	return b
+}
+
+func (d *decoder) time(name string) time.Time {
+	value := d.str(name)
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		d.fail(name, value, err)
+	}
+	return t
+}
+
+// bytes decodes binary data, which is rendered as hexadecimal.
+func (d *decoder) bytes(name string) []byte {
+	value := d.str(name)
+	if value == "" {
+		return nil
+	}
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		d.fail(name, value, err)
+	}
+	return b
+}
+`))
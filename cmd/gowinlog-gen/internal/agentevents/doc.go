@@ -0,0 +1,6 @@
+// Package agentevents is generated by gowinlog-gen from the recorded
+// manifest in testdata, covering the template types the Security events
+// don't use.
+package agentevents
+
+//go:generate go run ../.. -manifest ../../testdata/security.man -provider Contoso-Agent -package agentevents -out events_gen.go
@@ -0,0 +1,38 @@
+package agentevents
+
+import (
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func TestDecodeTypes(t *T) {
+	ev := &winlog.WinLogEvent{
+		ProviderName: ProviderName,
+		EventId:      10,
+		EventData: map[string]string{
+			"Hash":        "0A0B",
+			"Quarantined": "true",
+			"Score":       "0.75",
+			"Delta":       "-3",
+			"Started":     "2021-03-04T05:06:07.1234567Z",
+			"RuleCount":   "2",
+			"Rules":       "a b",
+		},
+	}
+	scan, err := DecodeEvent10V0(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2021, 3, 4, 5, 6, 7, 123456700, time.UTC)
+	if string(scan.Hash) != "\x0a\x0b" || !scan.Quarantined || scan.Score != 0.75 || scan.Delta != -3 ||
+		!scan.Started.Equal(started) || scan.RuleCount != 2 || scan.Rules != "a b" {
+		t.Fatalf("Unexpected %+v", scan)
+	}
+
+	ev.EventData["Delta"] = "4294967296"
+	if _, err := DecodeEvent10V0(ev); err == nil {
+		t.Error("Expected an error for a value out of range")
+	}
+}
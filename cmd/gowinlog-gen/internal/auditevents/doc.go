@@ -0,0 +1,6 @@
+// Package auditevents is generated by gowinlog-gen from the recorded
+// manifest in testdata, as an example of its output and to check that it
+// compiles.
+package auditevents
+
+//go:generate go run ../.. -manifest ../../testdata/security.man -provider Microsoft-Windows-Security-Auditing -events 4616,4624,4625,4688 -package auditevents -out events_gen.go
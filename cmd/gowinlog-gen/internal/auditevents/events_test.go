@@ -0,0 +1,70 @@
+package auditevents
+
+import (
+	. "testing"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func TestDecodeLogon(t *T) {
+	ev := &winlog.WinLogEvent{
+		ProviderName: ProviderName,
+		EventId:      4624,
+		Version:      2,
+		EventData: map[string]string{
+			"TargetUserName":   "alice",
+			"TargetDomainName": "CORP",
+			"TargetLogonId":    "0x3e7",
+			"LogonType":        "10",
+			"ProcessId":        "0x2a8",
+			"IpAddress":        "-",
+			"LogonGuid":        "{00000000-0000-0000-0000-000000000000}",
+		},
+	}
+	decoded, err := Decode(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logon, ok := decoded.(*Event4624V2)
+	if !ok {
+		t.Fatalf("Decoded %T", decoded)
+	}
+	if logon.TargetUserName != "alice" || logon.TargetLogonId != 0x3e7 || logon.LogonType != 10 || logon.ProcessId != 0x2a8 {
+		t.Fatalf("Unexpected %+v", logon)
+	}
+	if logon.IpAddress != "" {
+		t.Errorf("Expected a missing value for -, got %q", logon.IpAddress)
+	}
+
+	ev.EventData["LogonType"] = "interactive"
+	if _, err := DecodeEvent4624V2(ev); err == nil {
+		t.Error("Expected an error for a malformed LogonType")
+	}
+
+	// Versions and providers without generated structs aren't decoded
+	for _, other := range []*winlog.WinLogEvent{
+		{ProviderName: ProviderName, EventId: 4624, Version: 1},
+		{ProviderName: "Other", EventId: 4624, Version: 2},
+	} {
+		if decoded, err := Decode(other); decoded != nil || err != nil {
+			t.Errorf("Expected nothing decoded, got %v, %v", decoded, err)
+		}
+	}
+}
+
+func TestDecodeFromXml(t *T) {
+	ev := &winlog.WinLogEvent{
+		ProviderName: ProviderName,
+		EventId:      4688,
+		Version:      2,
+		Xml: []byte(`<Event><EventData><Data Name="NewProcessId">0x1f4</Data>` +
+			`<Data Name="NewProcessName">C:\Windows\System32\cmd.exe</Data></EventData></Event>`),
+	}
+	process, err := DecodeEvent4688V2(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if process.NewProcessId != 0x1f4 || process.NewProcessName != `C:\Windows\System32\cmd.exe` {
+		t.Fatalf("Unexpected %+v", process)
+	}
+}
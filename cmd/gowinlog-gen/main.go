@@ -0,0 +1,90 @@
+// Command gowinlog-gen generates Go structs for a provider's events, with
+// functions decoding WinLogEvent.EventData into their typed fields, from the
+// provider's event templates:
+//
+//	gowinlog-gen -provider Microsoft-Windows-Security-Auditing -events 4624,4625 -package audit -out audit_gen.go
+//	gowinlog-gen -manifest agent.man -package agentevents -out agentevents_gen.go
+//
+// Templates are read from the provider's registered metadata, which needs
+// Windows, or from an instrumentation manifest. Use it from a go:generate
+// directive to keep the structs in step with the manifest. Each package
+// holds the events of one provider, as the generated file declares helpers
+// of its own.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func main() {
+	provider := flag.String("provider", "", "provider whose events to generate, such as Microsoft-Windows-Security-Auditing")
+	manifest := flag.String("manifest", "", "instrumentation manifest to read the templates from, instead of the provider's registered metadata")
+	events := flag.String("events", "", "comma-separated event IDs to generate, instead of every event with a template")
+	pkg := flag.String("package", "", "package name of the generated file")
+	outPath := flag.String("out", "", "file to write instead of stdout")
+	flag.Parse()
+	if err := run(*provider, *manifest, *events, *pkg, *outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "gowinlog-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(provider, manifest, events, pkg, outPath string) error {
+	if pkg == "" {
+		return fmt.Errorf("-package is required")
+	}
+	var templates winlog.EventTemplates
+	var err error
+	switch {
+	case manifest != "":
+		data, err := ioutil.ReadFile(manifest)
+		if err != nil {
+			return err
+		}
+		provider, templates, err = ParseManifest(data, provider)
+		if err != nil {
+			return err
+		}
+	case provider != "":
+		if templates, err = winlog.GetEventTemplates(provider); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("-provider or -manifest is required")
+	}
+	ids, err := parseEventIds(events)
+	if err != nil {
+		return err
+	}
+	src, err := Generate(Config{Package: pkg, Provider: provider, Templates: templates, EventIds: ids})
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(outPath, src, 0644)
+}
+
+func parseEventIds(s string) ([]uint64, error) {
+	var ids []uint64
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(field, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid event ID %q: %v", field, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	. "testing"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func readManifest(provider string, t *T) (string, winlog.EventTemplates) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "security.man"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, templates, err := ParseManifest(data, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name, templates
+}
+
+func TestParseManifest(t *T) {
+	name, templates := readManifest("microsoft-windows-security-auditing", t)
+	if name != "Microsoft-Windows-Security-Auditing" {
+		t.Fatalf("Unexpected provider %q", name)
+	}
+	// 1100 has no template
+	if len(templates) != 4 {
+		t.Fatalf("Expected 4 templates, got %v", len(templates))
+	}
+	fields := templates[winlog.TemplateKey{EventId: 4624, Version: 2}]
+	if len(fields) != 27 || fields[8].Name != "LogonType" || fields[8].InType != "win:UInt32" {
+		t.Fatalf("Unexpected 4624 fields %+v", fields)
+	}
+
+	data, _ := ioutil.ReadFile(filepath.Join("testdata", "security.man"))
+	if _, _, err := ParseManifest(data, ""); err == nil {
+		t.Error("Expected an error choosing between two providers")
+	}
+	if _, _, err := ParseManifest(data, "Missing"); err == nil {
+		t.Error("Expected an error for a provider the manifest doesn't define")
+	}
+}
+
+// The generated example packages must match the generator's output
+func TestGenerateGolden(t *T) {
+	cases := []struct {
+		provider string
+		pkg      string
+		ids      []uint64
+	}{
+		{"Microsoft-Windows-Security-Auditing", "auditevents", []uint64{4616, 4624, 4625, 4688}},
+		{"Contoso-Agent", "agentevents", nil},
+	}
+	for _, c := range cases {
+		name, templates := readManifest(c.provider, t)
+		src, err := Generate(Config{Package: c.pkg, Provider: name, Templates: templates, EventIds: c.ids})
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden, err := ioutil.ReadFile(filepath.Join("internal", c.pkg, "events_gen.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(src) != string(golden) {
+			t.Errorf("Output for %v differs from internal/%v/events_gen.go, run go generate", c.provider, c.pkg)
+		}
+	}
+}
+
+func TestGenerateErrors(t *T) {
+	name, templates := readManifest("Contoso-Agent", t)
+	if _, err := Generate(Config{Package: "p", Provider: name, Templates: templates, EventIds: []uint64{11}}); err == nil {
+		t.Error("Expected an error when none of the events have templates")
+	}
+	if _, err := parseEventIds("4624,x"); err == nil {
+		t.Error("Expected an error for an invalid event ID")
+	}
+	if err := run("", "", "", "p", ""); err == nil {
+		t.Error("Expected an error without -provider or -manifest")
+	}
+}
+
+func TestGoName(t *T) {
+	for name, expected := range map[string]string{
+		"TargetUserName": "TargetUserName",
+		"Logon ID":       "LogonID",
+		"param-1":        "Param1",
+		"2ndValue":       "F2ndValue",
+		"Data0":          "Data0",
+	} {
+		if got := goName(name); got != expected {
+			t.Errorf("goName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+type manifestXml struct {
+	Providers []manifestProviderXml `xml:"instrumentation>events>provider"`
+}
+
+type manifestProviderXml struct {
+	Name   string `xml:"name,attr"`
+	Events []struct {
+		Value    string `xml:"value,attr"`
+		Version  string `xml:"version,attr"`
+		Template string `xml:"template,attr"`
+	} `xml:"events>event"`
+	Templates []struct {
+		Tid   string `xml:"tid,attr"`
+		Inner string `xml:",innerxml"`
+	} `xml:"templates>template"`
+}
+
+// ParseManifest reads the event templates of a provider from an
+// instrumentation manifest. `provider` may be empty if the manifest defines
+// only one. Returns the provider's name, as the manifest gives it.
+func ParseManifest(data []byte, provider string) (string, winlog.EventTemplates, error) {
+	var manifest manifestXml
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return "", nil, fmt.Errorf("Failed to parse manifest: %v", err)
+	}
+	var found *manifestProviderXml
+	for i, p := range manifest.Providers {
+		if (provider == "" && len(manifest.Providers) == 1) || strings.EqualFold(p.Name, provider) {
+			found = &manifest.Providers[i]
+			break
+		}
+	}
+	if found == nil {
+		if provider == "" {
+			return "", nil, fmt.Errorf("The manifest defines %v providers, choose one with -provider", len(manifest.Providers))
+		}
+		return "", nil, fmt.Errorf("The manifest doesn't define provider %q", provider)
+	}
+
+	fieldsByTid := make(map[string][]winlog.TemplateField, len(found.Templates))
+	for _, t := range found.Templates {
+		fields, err := winlog.ParseEventTemplate("<template>" + t.Inner + "</template>")
+		if err != nil {
+			return "", nil, fmt.Errorf("Template %q: %v", t.Tid, err)
+		}
+		fieldsByTid[t.Tid] = fields
+	}
+	templates := make(winlog.EventTemplates)
+	for _, ev := range found.Events {
+		if ev.Template == "" {
+			continue
+		}
+		fields, ok := fieldsByTid[ev.Template]
+		if !ok {
+			return "", nil, fmt.Errorf("Event %v refers to undefined template %q", ev.Value, ev.Template)
+		}
+		id, err := strconv.ParseUint(ev.Value, 0, 16)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid event value %q: %v", ev.Value, err)
+		}
+		var version uint64
+		if ev.Version != "" {
+			if version, err = strconv.ParseUint(ev.Version, 0, 8); err != nil {
+				return "", nil, fmt.Errorf("Invalid version %q of event %v: %v", ev.Version, id, err)
+			}
+		}
+		if len(fields) > 0 {
+			templates[winlog.TemplateKey{EventId: id, Version: version}] = fields
+		}
+	}
+	return found.Name, templates, nil
+}
@@ -0,0 +1,597 @@
+// Command gowinlog follows, queries and exports the Windows Event Log.
+//
+//	gowinlog tail -channel Security -filter 'EventId == 4625'
+//	gowinlog tail -file synced\forwarded.evtx -interval 10s
+//	gowinlog query -file archive.evtx -query '*[System[Level<=2]]' -format text
+//	gowinlog query -channel MyCustomLog -legacy -format text
+//	gowinlog query -channel Security -query '*[System[EventID=4625]]' -reverse -max 50
+//	gowinlog query -where 'channel=Security id=4624,4625 last=24h' -format text
+//	gowinlog export -channel Application -out application.jsonl
+//	gowinlog export -file archive.evtx -out logons.csv -columns Created,EventId,EventData.TargetUserName
+//	gowinlog channels
+//	gowinlog publishers
+//	gowinlog schema -provider Microsoft-Windows-Security-Auditing -out security.schema.json
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+const usage = `Usage: gowinlog <command> [flags]
+
+Commands:
+  tail        Follow new events on a channel or growing .evtx file
+  query       Read past events from a channel or .evtx file
+  export      Write matching events to a .evtx, .jsonl or .csv file
+  channels    List the channels on this computer
+  publishers  List the providers on this computer
+  schema      Write a JSON Schema of a provider's EventData
+  check       Check the event log can be read, for troubleshooting
+
+Run "gowinlog <command> -h" for the command's flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	commands := map[string]func([]string) error{
+		"tail":       tail,
+		"query":      query,
+		"export":     export,
+		"channels":   func([]string) error { return list(winlog.ListChannels) },
+		"publishers": func([]string) error { return list(winlog.ListPublishers) },
+		"schema":     schema,
+		"check":      check,
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%v", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "gowinlog %v: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// Flags shared by the commands which read events
+type eventFlags struct {
+	channel string
+	file    string
+	query   string
+	where   string
+	filter  string
+	format  string
+	legacy  bool
+	reverse bool
+	// How long to wait for each event from the event log
+	timeout time.Duration
+	// Directory with the LocaleMetaData of an archived -file
+	metadataDir string
+	// JSON file of the Transform applied to json output, and the Transform
+	transformPath string
+	transform     *winlog.Transform
+	// How times are written: utc, local or epoch, or as the -transform says
+	timestamps string
+}
+
+func (f *eventFlags) register(set *flag.FlagSet, withFile bool) {
+	set.StringVar(&f.channel, "channel", "", "channel to read, such as Security")
+	if withFile {
+		set.StringVar(&f.file, "file", "", "exported .evtx file to read instead of a channel")
+		set.StringVar(&f.metadataDir, "metadata-dir", "", "directory with the LocaleMetaData of an archived -file, if it isn't next to the file")
+	}
+	set.StringVar(&f.query, "query", "*", "XPath query selecting events")
+	set.StringVar(&f.where, "where", "", "key=value terms selecting events instead of -query, such as 'id=4624,4625 level<=3 last=24h'")
+	set.StringVar(&f.filter, "filter", "", "filter expression applied after the query, such as 'EventId == 4625'")
+	set.StringVar(&f.transformPath, "transform", "", "JSON file renaming, dropping and deriving fields of json output")
+	set.StringVar(&f.timestamps, "timestamps", "", "how times are written: utc, local or epoch (milliseconds); RFC 3339 in the event's zone for text and UTC for json if unset")
+	set.DurationVar(&f.timeout, "timeout", 10*time.Second, "how long to wait for each event from the event log")
+}
+
+func (f *eventFlags) compileFilter() (*winlog.Filter, error) {
+	if f.filter == "" {
+		return nil, nil
+	}
+	return winlog.CompileFilter(f.filter)
+}
+
+func (f *eventFlags) checkSource() error {
+	if f.transformPath != "" {
+		transform, err := winlog.LoadTransform(f.transformPath)
+		if err != nil {
+			return err
+		}
+		f.transform = transform
+	}
+	if f.timestamps != "" {
+		ts, err := parseTimestamps(f.timestamps)
+		if err != nil {
+			return err
+		}
+		if f.transform == nil {
+			f.transform = &winlog.Transform{}
+		}
+		f.transform.Timestamps = ts
+	}
+	if f.where != "" {
+		if f.query != "*" {
+			return fmt.Errorf("-where can't be used with -query")
+		}
+		where, err := winlog.ParseSimpleQuery(f.where)
+		if err != nil {
+			return err
+		}
+		f.query = where.XPath
+		if len(where.Channels) > 1 {
+			return fmt.Errorf("-where can only name one channel")
+		} else if len(where.Channels) == 1 && f.channel == "" {
+			f.channel = where.Channels[0]
+		}
+	}
+	if (f.channel == "") == (f.file == "") {
+		return fmt.Errorf("Exactly one of -channel and -file is required")
+	}
+	return nil
+}
+
+// newWatcher creates a watcher which renders every localized field. Events
+// from a -file are formatted with the metadata archived with it, if any.
+func newWatcher(f *eventFlags) (*winlog.WinLogWatcher, error) {
+	api := winlog.SystemEventLogAPI
+	if f.file != "" {
+		archive := winlog.NewArchiveAPI(f.file)
+		archive.MetadataDir = f.metadataDir
+		api = archive
+	}
+	render := winlog.RenderAllFields
+	render.Keywords = false
+	return winlog.NewWinLogWatcherWithOptions(winlog.WatcherOptions{
+		API:           api,
+		Render:        render,
+		NameEventData: true,
+	})
+}
+
+// writeEvent writes the event as a line of JSON, shaped by `transform` if it
+// isn't nil, or in a short text form with the transform's Timestamps.
+func writeEvent(w io.Writer, ev *winlog.WinLogEvent, format string, transform *winlog.Transform) error {
+	var ts *winlog.Timestamps
+	if transform != nil {
+		ts = transform.Timestamps
+	}
+	switch format {
+	case "text":
+		text := ev.String()
+		if ts != nil {
+			text = ev.StringWith(*ts)
+		}
+		_, err := fmt.Fprintln(w, text)
+		return err
+	case "verbose":
+		text := ev.VerboseString()
+		if ts != nil {
+			text = ev.VerboseStringWith(*ts)
+		}
+		// Blank line between events
+		_, err := fmt.Fprintln(w, text)
+		return err
+	}
+	if transform != nil {
+		data, err := transform.JSON(ev)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	data, err := winlog.CanonicalJSON(ev)
+	if err != nil {
+		return err
+	}
+	var line bytes.Buffer
+	if err := json.Compact(&line, data); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+	_, err = w.Write(line.Bytes())
+	return err
+}
+
+func parseTimestamps(name string) (*winlog.Timestamps, error) {
+	switch name {
+	case "utc":
+		return &winlog.Timestamps{}, nil
+	case "local":
+		return &winlog.Timestamps{Local: true}, nil
+	case "epoch":
+		return &winlog.Timestamps{EpochMillis: true}, nil
+	}
+	return nil, fmt.Errorf("Unknown timestamps %q, expected utc, local or epoch", name)
+}
+
+func checkFormat(format string) error {
+	if format != "json" && format != "text" && format != "verbose" {
+		return fmt.Errorf("Unknown format %q, expected json, text or verbose", format)
+	}
+	return nil
+}
+
+func tail(args []string) error {
+	var f eventFlags
+	set := flag.NewFlagSet("tail", flag.ExitOnError)
+	f.register(set, true)
+	set.StringVar(&f.format, "format", "json", "output format, json, text or verbose")
+	fromBeginning := set.Bool("from-beginning", false, "start with the oldest event rather than the next one")
+	since := set.String("since", "", "start a -channel with the first event created at or after this RFC 3339 time, such as 2024-01-02T15:04:05Z")
+	interval := set.Duration("interval", winlog.DefaultFileTailInterval, "how often to check a -file for new events")
+	set.Parse(args)
+	if err := f.checkSource(); err != nil {
+		return err
+	}
+	if err := checkFormat(f.format); err != nil {
+		return err
+	}
+	filter, err := f.compileFilter()
+	if err != nil {
+		return err
+	}
+
+	watcher, err := newWatcher(&f)
+	if err != nil {
+		return err
+	}
+	defer watcher.Shutdown()
+	if f.file != "" {
+		return tailFile(watcher, &f, filter, *fromBeginning, *interval)
+	}
+	if filter != nil {
+		watcher.Filter = filter
+	}
+	start := winlog.SubscriptionStart{Mode: winlog.StartAtNow}
+	if *since != "" {
+		if start.Time, err = time.Parse(time.RFC3339, *since); err != nil {
+			return fmt.Errorf("Invalid -since: %v", err)
+		}
+		start.Mode = winlog.StartAtTime
+	} else if *fromBeginning {
+		start.Mode = winlog.StartAtOldest
+	}
+	if err := watcher.Subscribe(f.channel, f.query, start); err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for {
+		select {
+		case ev := <-watcher.Event():
+			if err := writeEvent(out, ev, f.format, f.transform); err != nil {
+				return err
+			}
+			if len(watcher.Event()) == 0 {
+				out.Flush()
+			}
+		case err := <-watcher.Error():
+			fmt.Fprintf(os.Stderr, "gowinlog tail: %v\n", err)
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// tailFile follows an .evtx file which is still being appended to.
+func tailFile(watcher *winlog.WinLogWatcher, f *eventFlags, filter *winlog.Filter, fromBeginning bool, interval time.Duration) error {
+	var after uint64
+	if !fromBeginning {
+		newest, err := newestRecordId(watcher, f.file)
+		if err != nil {
+			return err
+		}
+		after = newest
+	}
+	tail, err := watcher.TailFile(f.file, f.query, after)
+	if err != nil {
+		return err
+	}
+	defer tail.Close()
+	tail.Interval = interval
+	tail.Timeout = f.timeout
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	err = tail.FollowContext(ctx, func(ev *winlog.WinLogEvent) error {
+		if filter != nil && !filter.Match(ev) {
+			return nil
+		}
+		if err := writeEvent(out, ev, f.format, f.transform); err != nil {
+			return err
+		}
+		return out.Flush()
+	})
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// interruptContext returns a context which is cancelled on Ctrl-C, so reads
+// stop without waiting for their timeout.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupt)
+	}()
+	return ctx, cancel
+}
+
+// newestRecordId returns the RecordId of the last record in an .evtx file,
+// or 0 if it's empty.
+func newestRecordId(watcher *winlog.WinLogWatcher, path string) (uint64, error) {
+	result, err := winlog.QueryFileReverse(path, "*")
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+	handle, err := result.Next(time.Second)
+	if err == io.EOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer winlog.CloseEventHandle(uint64(handle))
+	ev, err := watcher.RenderEvent(handle)
+	if err != nil {
+		return 0, err
+	}
+	return ev.RecordId, nil
+}
+
+// readEvents calls `fn` with each event from the channel or file matching
+// the query and filter.
+func readEvents(f *eventFlags, fn func(*winlog.WinLogEvent) error) error {
+	filter, err := f.compileFilter()
+	if err != nil {
+		return err
+	}
+	if f.legacy {
+		return readLegacyEvents(f, filter, fn)
+	}
+	watcher, err := newWatcher(f)
+	if err != nil {
+		return err
+	}
+	defer watcher.Shutdown()
+
+	var result *winlog.QueryResult
+	switch {
+	case f.file != "" && f.reverse:
+		result, err = winlog.QueryFileReverse(f.file, f.query)
+	case f.file != "":
+		result, err = winlog.QueryFile(f.file, f.query)
+	case f.reverse:
+		result, err = winlog.QueryChannelReverse(f.channel, f.query)
+	default:
+		result, err = winlog.QueryChannel(f.channel, f.query)
+	}
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	ctx, cancel := interruptContext()
+	defer cancel()
+	for {
+		handle, err := result.NextContext(ctx, f.timeout)
+		if err == io.EOF {
+			return nil
+		} else if err == context.Canceled {
+			return fmt.Errorf("Interrupted")
+		} else if err != nil {
+			return err
+		}
+		ev, err := watcher.RenderEvent(handle)
+		winlog.CloseEventHandle(uint64(handle))
+		if err != nil {
+			return err
+		}
+		if filter != nil && !filter.Match(ev) {
+			continue
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// readLegacyEvents reads a classic log with the legacy Event Logging API,
+// which can't apply XPath queries.
+func readLegacyEvents(f *eventFlags, filter *winlog.Filter, fn func(*winlog.WinLogEvent) error) error {
+	if f.channel == "" || f.query != "*" || f.reverse {
+		return fmt.Errorf("-legacy needs -channel, and can't be used with -query or -reverse")
+	}
+	reader, err := winlog.OpenLegacyLog(f.channel)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if filter != nil && !filter.Match(ev) {
+			continue
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+}
+
+func query(args []string) error {
+	var f eventFlags
+	set := flag.NewFlagSet("query", flag.ExitOnError)
+	f.register(set, true)
+	set.StringVar(&f.format, "format", "json", "output format, json, text or verbose")
+	max := set.Int("max", 0, "stop after this many events, 0 for no limit")
+	set.BoolVar(&f.reverse, "reverse", false, "read the newest events first, to read the most recent ones with -max")
+	set.BoolVar(&f.legacy, "legacy", false, "read a classic log with the legacy Event Logging API, for logs wevtapi fails to render")
+	set.Parse(args)
+	if err := f.checkSource(); err != nil {
+		return err
+	}
+	if err := checkFormat(f.format); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	count := 0
+	err := readEvents(&f, func(ev *winlog.WinLogEvent) error {
+		if err := writeEvent(out, ev, f.format, f.transform); err != nil {
+			return err
+		}
+		count++
+		if *max > 0 && count >= *max {
+			return io.EOF
+		}
+		return nil
+	})
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func export(args []string) error {
+	var f eventFlags
+	set := flag.NewFlagSet("export", flag.ExitOnError)
+	f.register(set, true)
+	outPath := set.String("out", "", "file to write, .evtx, .jsonl or .csv")
+	columns := set.String("columns", strings.Join(winlog.DefaultExportColumns, ","), "comma separated columns for .csv output, such as EventId,EventData.TargetUserName")
+	set.Parse(args)
+	if err := f.checkSource(); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(*outPath)) {
+	case ".evtx":
+		// The event log writes the file itself, so only the XPath query
+		// can select events
+		if f.filter != "" {
+			return fmt.Errorf("-filter isn't supported for .evtx output, use -query")
+		}
+		if f.file != "" {
+			return winlog.ExportFile(f.file, f.query, *outPath)
+		}
+		return winlog.ExportChannel(f.channel, f.query, *outPath)
+	case ".jsonl", ".csv":
+		file, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		out := bufio.NewWriter(file)
+		if strings.ToLower(filepath.Ext(*outPath)) == ".csv" {
+			csv := winlog.NewCSVWriter(out, strings.Split(*columns, ","))
+			err = readEvents(&f, csv.Write)
+			if flushErr := csv.Flush(); err == nil {
+				err = flushErr
+			}
+		} else {
+			err = readEvents(&f, func(ev *winlog.WinLogEvent) error {
+				return writeEvent(out, ev, "json", f.transform)
+			})
+		}
+		if flushErr := out.Flush(); err == nil {
+			err = flushErr
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	default:
+		return fmt.Errorf("-out must be a .evtx, .jsonl or .csv file")
+	}
+}
+
+func list(lister func() ([]string, error)) error {
+	names, err := lister()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func schema(args []string) error {
+	set := flag.NewFlagSet("schema", flag.ExitOnError)
+	provider := set.String("provider", "", "provider whose events to describe, such as Microsoft-Windows-Security-Auditing")
+	outPath := set.String("out", "", "file to write instead of stdout")
+	set.Parse(args)
+	if *provider == "" {
+		return fmt.Errorf("-provider is required")
+	}
+
+	templates, err := winlog.GetEventTemplates(*provider)
+	if err != nil {
+		return err
+	}
+	out, err := winlog.JSONSchema(*provider, templates)
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if *outPath == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(*outPath, out, 0644)
+}
+
+func check(args []string) error {
+	set := flag.NewFlagSet("check", flag.ExitOnError)
+	channels := set.String("channels", "Application,System,Security", "comma-separated channels to check")
+	set.Parse(args)
+
+	report := winlog.CheckHealth(strings.Split(*channels, ",")...)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	if !report.Healthy {
+		return fmt.Errorf("unhealthy")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func TestWriteEvent(t *T) {
+	ev := &winlog.WinLogEvent{
+		Created:      time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Channel:      "Security",
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4625,
+		LevelText:    "Information",
+		Msg:          "An account failed to log on.\r\n\r\nSubject:",
+	}
+	var buf bytes.Buffer
+	if err := writeEvent(&buf, ev, "text", nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "2021-03-04T05:06:07Z Security Microsoft-Windows-Security-Auditing 4625 Information: An account failed to log on.\n"
+	if buf.String() != expected {
+		t.Fatalf("%q != %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := writeEvent(&buf, ev, "verbose", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\nMessage:\n  An account failed to log on.\n") {
+		t.Fatalf("Unexpected verbose text %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeEvent(&buf, ev, "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	line := buf.Bytes()
+	if bytes.Count(line, []byte("\n")) != 1 {
+		t.Fatalf("Expected a single line, got %q", line)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["EventId"] != float64(4625) {
+		t.Fatalf("Unexpected EventId %v", decoded["EventId"])
+	}
+
+	buf.Reset()
+	transform := &winlog.Transform{Rename: map[string]string{"EventId": "event.code"}, Drop: []string{"Xml"}}
+	if err := writeEvent(&buf, ev, "json", transform); err != nil {
+		t.Fatal(err)
+	}
+	decoded = nil
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded["Xml"]; ok || decoded["event.code"] != float64(4625) {
+		t.Fatalf("Unexpected transformed event %q", buf.String())
+	}
+
+	buf.Reset()
+	transform.Timestamps, _ = parseTimestamps("epoch")
+	if err := writeEvent(&buf, ev, "text", transform); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "1614834367000 Security") {
+		t.Fatalf("Unexpected text with epoch timestamps %q", buf.String())
+	}
+}
@@ -0,0 +1,158 @@
+package winlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default limit on the events kept in one ActivityBundle
+const DefaultMaxBundleEvents = 1000
+
+const nullActivityId = "{00000000-0000-0000-0000-000000000000}"
+
+// ActivityBundle is a group of correlated events: those sharing an
+// ActivityId, plus those of activities started from it, as recorded by
+// RelatedActivityId.
+type ActivityBundle struct {
+	// ActivityId of the first activity seen in the bundle
+	Key         string
+	ActivityIds []string
+	// In the order they were added
+	Events []*WinLogEvent
+	// Events left out after the bundle reached MaxEvents
+	Dropped int
+	// When the first and last events were added
+	First time.Time
+	Last  time.Time
+}
+
+// ActivityCorrelator groups events by ActivityId and RelatedActivityId, to
+// trace an RPC, COM or WinRM operation across the providers it touches.
+// Bundles are returned by Expire once no event has joined them for Window.
+type ActivityCorrelator struct {
+	Window    time.Duration
+	MaxEvents int
+
+	mutex sync.Mutex
+	// Key of the bundle each activity belongs to
+	keys    map[string]string
+	bundles map[string]*ActivityBundle
+}
+
+func NewActivityCorrelator(window time.Duration) *ActivityCorrelator {
+	return &ActivityCorrelator{
+		Window:    window,
+		MaxEvents: DefaultMaxBundleEvents,
+		keys:      make(map[string]string),
+		bundles:   make(map[string]*ActivityBundle),
+	}
+}
+
+func activityId(id string) string {
+	if id == nullActivityId {
+		return ""
+	}
+	return id
+}
+
+// Add puts the event in the bundle for its activity, returning the bundle's
+// Key as a correlation key for the event. Events without an ActivityId
+// aren't kept and return "". Activities joined by a RelatedActivityId after
+// both had events have their bundles merged, keeping the Key of the related
+// (parent) activity's bundle.
+func (c *ActivityCorrelator) Add(ev *WinLogEvent, now time.Time) string {
+	activity := activityId(ev.ActivityId)
+	if activity == "" {
+		return ""
+	}
+	related := activityId(ev.RelatedActivityId)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var bundle *ActivityBundle
+	if related != "" {
+		bundle = c.join(related, now)
+		if key, ok := c.keys[activity]; ok && key != bundle.Key {
+			c.merge(c.bundles[key], bundle)
+		}
+	}
+	if bundle == nil {
+		bundle = c.join(activity, now)
+	} else if _, ok := c.keys[activity]; !ok {
+		c.keys[activity] = bundle.Key
+		bundle.ActivityIds = append(bundle.ActivityIds, activity)
+	}
+
+	if c.MaxEvents > 0 && len(bundle.Events) >= c.MaxEvents {
+		bundle.Dropped++
+	} else {
+		bundle.Events = append(bundle.Events, ev)
+	}
+	bundle.Last = now
+	return bundle.Key
+}
+
+// join returns the bundle of the activity, starting one if needed. Must be
+// called with the mutex held.
+func (c *ActivityCorrelator) join(activity string, now time.Time) *ActivityBundle {
+	if key, ok := c.keys[activity]; ok {
+		return c.bundles[key]
+	}
+	bundle := &ActivityBundle{Key: activity, ActivityIds: []string{activity}, First: now, Last: now}
+	c.keys[activity] = activity
+	c.bundles[activity] = bundle
+	return bundle
+}
+
+// merge moves the events and activities of `from` into `into`. Must be
+// called with the mutex held.
+func (c *ActivityCorrelator) merge(from, into *ActivityBundle) {
+	for _, activity := range from.ActivityIds {
+		c.keys[activity] = into.Key
+	}
+	into.ActivityIds = append(into.ActivityIds, from.ActivityIds...)
+	into.Events = append(into.Events, from.Events...)
+	sort.SliceStable(into.Events, func(i, j int) bool {
+		return into.Events[i].Created.Before(into.Events[j].Created)
+	})
+	if c.MaxEvents > 0 && len(into.Events) > c.MaxEvents {
+		into.Dropped += len(into.Events) - c.MaxEvents
+		into.Events = into.Events[:c.MaxEvents]
+	}
+	into.Dropped += from.Dropped
+	if from.First.Before(into.First) {
+		into.First = from.First
+	}
+	delete(c.bundles, from.Key)
+}
+
+// Expire returns the bundles no event has joined for Window, oldest first,
+// and forgets them. Events for their activities which arrive later start
+// new bundles.
+func (c *ActivityCorrelator) Expire(now time.Time) []*ActivityBundle {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var expired []*ActivityBundle
+	for key, bundle := range c.bundles {
+		if now.Sub(bundle.Last) < c.Window {
+			continue
+		}
+		for _, activity := range bundle.ActivityIds {
+			delete(c.keys, activity)
+		}
+		delete(c.bundles, key)
+		expired = append(expired, bundle)
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].First.Before(expired[j].First)
+	})
+	return expired
+}
+
+// Pending returns the number of bundles still open.
+func (c *ActivityCorrelator) Pending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.bundles)
+}
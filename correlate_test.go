@@ -0,0 +1,57 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestActivityCorrelator(t *T) {
+	c := NewActivityCorrelator(time.Minute)
+	now := time.Now()
+	event := func(id uint64, activity, related string) *WinLogEvent {
+		return &WinLogEvent{EventId: id, ActivityId: activity, RelatedActivityId: related, Created: now.Add(time.Duration(id) * time.Millisecond)}
+	}
+
+	assertEqual(c.Add(event(1, "", ""), now), "", t)
+	assertEqual(c.Add(event(2, nullActivityId, ""), now), "", t)
+	assertEqual(c.Add(event(3, "{a}", ""), now), "{a}", t)
+	// A child activity started from {a}
+	assertEqual(c.Add(event(4, "{b}", "{a}"), now), "{a}", t)
+	assertEqual(c.Add(event(5, "{b}", ""), now), "{a}", t)
+	// Unrelated until a transfer event links {c} to {a}
+	assertEqual(c.Add(event(6, "{c}", ""), now), "{c}", t)
+	assertEqual(c.Pending(), 2, t)
+	assertEqual(c.Add(event(7, "{c}", "{a}"), now.Add(30*time.Second)), "{a}", t)
+	assertEqual(c.Pending(), 1, t)
+	assertEqual(c.Add(event(8, "{d}", ""), now.Add(45*time.Second)), "{d}", t)
+
+	expired := c.Expire(now.Add(90 * time.Second))
+	assertEqual(len(expired), 1, t)
+	bundle := expired[0]
+	assertEqual(bundle.Key, "{a}", t)
+	assertEqual(len(bundle.ActivityIds), 3, t)
+	assertEqual(len(bundle.Events), 5, t)
+	for i, ev := range bundle.Events {
+		assertEqual(ev.EventId, uint64(i+3), t)
+	}
+	assertEqual(bundle.Last, now.Add(30*time.Second), t)
+	assertEqual(c.Pending(), 1, t)
+
+	// Activities of expired bundles start again
+	assertEqual(c.Add(event(9, "{b}", ""), now), "{b}", t)
+}
+
+func TestActivityCorrelatorMaxEvents(t *T) {
+	c := NewActivityCorrelator(time.Minute)
+	c.MaxEvents = 2
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		c.Add(&WinLogEvent{ActivityId: "{a}"}, now)
+	}
+	c.Add(&WinLogEvent{ActivityId: "{b}"}, now)
+	c.Add(&WinLogEvent{ActivityId: "{b}", RelatedActivityId: "{a}"}, now)
+	bundles := c.Expire(now.Add(time.Minute))
+	assertEqual(len(bundles), 1, t)
+	assertEqual(len(bundles[0].Events), 2, t)
+	assertEqual(bundles[0].Dropped, 3, t)
+}
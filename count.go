@@ -0,0 +1,40 @@
+package winlog
+
+import (
+	"math"
+	"time"
+)
+
+// How long CountEvents waits for the event log to return the next batch
+const countTimeout = 10 * time.Second
+
+// CountEvents counts the events matching `query` in a channel, or in an
+// exported log file if `channelOrFile` ends in .evtx, without rendering
+// them. Counting stops after `limit` events, or is unbounded if `limit` is
+// zero, and `more` reports whether there are events beyond the limit, so a
+// UI can show "more than 10000" rather than reading the whole channel.
+func CountEvents(channelOrFile, query string, limit uint64) (count uint64, more bool, err error) {
+	return countEvents(SystemEventLogAPI, channelOrFile, query, limit)
+}
+
+func countEvents(api EventLogAPI, channelOrFile, query string, limit uint64) (count uint64, more bool, err error) {
+	result, err := queryChannelOrFile(api, channelOrFile, query)
+	if err != nil {
+		return 0, false, err
+	}
+	defer result.Close()
+
+	// Read one past the limit to tell if there are more
+	bound := uint64(math.MaxUint64)
+	if limit > 0 && limit < math.MaxUint64 {
+		bound = limit + 1
+	}
+	count, err = result.count(bound, countTimeout)
+	if err != nil {
+		return 0, false, err
+	}
+	if limit > 0 && count > limit {
+		return limit, true, nil
+	}
+	return count, false, nil
+}
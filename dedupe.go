@@ -0,0 +1,172 @@
+package winlog
+
+import (
+	"hash"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Deduplicator suppresses repeats of an event (same provider, event ID,
+// EventData and UserData) seen within Window of its first occurrence. When
+// the window closes, a summary is published: a copy of the last suppressed
+// event with SuppressedCount set to the number of repeats. The window is
+// fixed rather than restarted by each repeat, so an event logged without
+// pause is still summarized once per Window, and the next repeat after it
+// closes is published and opens a new window. Summaries are published after
+// newer events, so they have no Bookmark, which would move a checkpoint
+// back. Useful for flapping services which log the same error thousands of
+// times.
+type Deduplicator struct {
+	Window time.Duration
+
+	mutex sync.Mutex
+	// Open windows by eventHash. Events whose hashes collide share a
+	// bucket, and are told apart by their keys.
+	windows map[uint64][]*dedupeWindow
+}
+
+type dedupeWindow struct {
+	key dedupeKey
+	// When the first occurrence was admitted
+	opened     time.Time
+	suppressed uint64
+	last       *WinLogEvent
+}
+
+// dedupeKey is the fields of an event compared to find duplicates, copied so
+// later changes to the event don't affect them.
+type dedupeKey struct {
+	provider  string
+	eventId   uint64
+	eventData map[string]string
+	userData  map[string]string
+}
+
+func newDedupeKey(ev *WinLogEvent) dedupeKey {
+	return dedupeKey{provider: ev.ProviderName, eventId: ev.EventId, eventData: copyData(ev.EventData), userData: copyData(ev.UserData)}
+}
+
+func copyData(data map[string]string) map[string]string {
+	copied := make(map[string]string, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (k dedupeKey) matches(ev *WinLogEvent) bool {
+	return k.provider == ev.ProviderName && k.eventId == ev.EventId && dataEqual(k.eventData, ev.EventData) && dataEqual(k.userData, ev.UserData)
+}
+
+func dataEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range b {
+		if stored, ok := a[name]; !ok || stored != value {
+			return false
+		}
+	}
+	return true
+}
+
+// eventHash identifies likely duplicate events
+func eventHash(ev *WinLogEvent) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(ev.ProviderName))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatUint(ev.EventId, 10)))
+	hashData(h, ev.EventData)
+	// Separates EventData from UserData, so the same values in either
+	// don't hash alike
+	h.Write([]byte{1})
+	hashData(h, ev.UserData)
+	return h.Sum64()
+}
+
+func hashData(h hash.Hash64, data map[string]string) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(data[k]))
+	}
+}
+
+// Admit reports whether the event should be published, or is a duplicate
+// within an open window.
+func (d *Deduplicator) Admit(ev *WinLogEvent, now time.Time) bool {
+	hash := eventHash(ev)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.windows == nil {
+		d.windows = make(map[uint64][]*dedupeWindow)
+	}
+	for _, w := range d.windows[hash] {
+		// Expired windows are left for Flush to summarize
+		if now.Sub(w.opened) < d.Window && w.key.matches(ev) {
+			w.suppressed++
+			w.last = ev
+			return false
+		}
+	}
+	d.windows[hash] = append(d.windows[hash], &dedupeWindow{key: newDedupeKey(ev), opened: now})
+	return true
+}
+
+// Flush closes the windows which have expired at `now`, returning summary
+// events for those in which duplicates were suppressed.
+func (d *Deduplicator) Flush(now time.Time) []*WinLogEvent {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var summaries []*WinLogEvent
+	for hash, bucket := range d.windows {
+		open := bucket[:0]
+		for _, w := range bucket {
+			if now.Sub(w.opened) < d.Window {
+				open = append(open, w)
+				continue
+			}
+			if w.suppressed > 0 {
+				summary := *w.last
+				summary.SuppressedCount = w.suppressed
+				summary.Bookmark = ""
+				summaries = append(summaries, &summary)
+			}
+		}
+		if len(open) == 0 {
+			delete(d.windows, hash)
+		} else {
+			d.windows[hash] = open
+		}
+	}
+	return summaries
+}
+
+// Periodically publish summaries for closed windows until shutdown
+func (self *WinLogWatcher) flushDuplicates() {
+	interval := self.Deduplicator.Window / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, summary := range self.Deduplicator.Flush(now) {
+				self.deliver(summary, nil)
+			}
+		case <-self.shutdown:
+			return
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestDeduplicator(t *T) {
+	d := &Deduplicator{Window: time.Minute}
+	now := time.Now()
+	newEvent := func(user string) *WinLogEvent {
+		return &WinLogEvent{ProviderName: "Service Control Manager", EventId: 7031, EventData: map[string]string{"param1": user}}
+	}
+
+	assertEqual(d.Admit(newEvent("a"), now), true, t)
+	assertEqual(d.Admit(newEvent("a"), now.Add(time.Second)), false, t)
+	last := newEvent("a")
+	last.Bookmark = "2"
+	assertEqual(d.Admit(last, now.Add(2*time.Second)), false, t)
+	// Different EventData isn't a duplicate
+	assertEqual(d.Admit(newEvent("b"), now.Add(2*time.Second)), true, t)
+
+	assertEqual(len(d.Flush(now.Add(time.Minute-time.Second))), 0, t)
+	// The window closes a minute after the first occurrence
+	summaries := d.Flush(now.Add(time.Minute))
+	assertEqual(len(summaries), 1, t)
+	assertEqual(summaries[0].SuppressedCount, uint64(2), t)
+	assertEqual(summaries[0].EventData["param1"], "a", t)
+	assertEqual(last.SuppressedCount, uint64(0), t)
+	// The suppressed event's bookmark is behind the events published since
+	assertEqual(summaries[0].Bookmark, "", t)
+	assertEqual(last.Bookmark, "2", t)
+
+	// The window is closed, so the next occurrence is published again
+	assertEqual(d.Admit(newEvent("a"), now.Add(2*time.Minute)), true, t)
+}
+
+func TestDeduplicatorFlapping(t *T) {
+	d := &Deduplicator{Window: time.Minute}
+	now := time.Now()
+	ev := &WinLogEvent{ProviderName: "Service Control Manager", EventId: 7031}
+	assertEqual(d.Admit(ev, now), true, t)
+	// Repeats every 10 seconds don't hold the window open: it's summarized
+	// a minute after the first occurrence
+	for i := 1; i <= 5; i++ {
+		assertEqual(d.Admit(ev, now.Add(time.Duration(i)*10*time.Second)), false, t)
+	}
+	summaries := d.Flush(now.Add(time.Minute))
+	assertEqual(len(summaries), 1, t)
+	assertEqual(summaries[0].SuppressedCount, uint64(5), t)
+
+	// The next repeat opens a new window
+	assertEqual(d.Admit(ev, now.Add(70*time.Second)), true, t)
+	assertEqual(d.Admit(ev, now.Add(80*time.Second)), false, t)
+	assertEqual(len(d.Flush(now.Add(2*time.Minute))), 0, t)
+	summaries = d.Flush(now.Add(130 * time.Second))
+	assertEqual(len(summaries), 1, t)
+	assertEqual(summaries[0].SuppressedCount, uint64(1), t)
+}
+
+func TestDeduplicatorUnflushed(t *T) {
+	d := &Deduplicator{Window: time.Minute}
+	now := time.Now()
+	ev := &WinLogEvent{ProviderName: "Service Control Manager", EventId: 7031}
+	assertEqual(d.Admit(ev, now), true, t)
+	assertEqual(d.Admit(ev, now.Add(time.Second)), false, t)
+
+	// An expired window which hasn't been flushed yet isn't reopened
+	later := now.Add(time.Hour)
+	assertEqual(d.Admit(ev, later), true, t)
+	assertEqual(d.Admit(ev, later.Add(time.Second)), false, t)
+	summaries := d.Flush(later.Add(time.Second))
+	assertEqual(len(summaries), 1, t)
+	assertEqual(summaries[0].SuppressedCount, uint64(1), t)
+	summaries = d.Flush(later.Add(2 * time.Minute))
+	assertEqual(len(summaries), 1, t)
+	assertEqual(summaries[0].SuppressedCount, uint64(1), t)
+}
+
+func TestDeduplicatorUserData(t *T) {
+	d := &Deduplicator{Window: time.Minute}
+	now := time.Now()
+	newEvent := func(policy string) *WinLogEvent {
+		return &WinLogEvent{ProviderName: "Microsoft-Windows-AppLocker", EventId: 8004, UserData: map[string]string{"PolicyName": policy}}
+	}
+	assertEqual(d.Admit(newEvent("EXE"), now), true, t)
+	assertEqual(d.Admit(newEvent("EXE"), now), false, t)
+	// Events differing only in UserData aren't duplicates
+	assertEqual(d.Admit(newEvent("MSI"), now), true, t)
+	assertEqual(eventHash(newEvent("EXE")) == eventHash(newEvent("MSI")), false, t)
+
+	// Nor are the same values in EventData rather than UserData
+	inEventData := &WinLogEvent{ProviderName: "Microsoft-Windows-AppLocker", EventId: 8004, EventData: map[string]string{"PolicyName": "EXE"}}
+	assertEqual(eventHash(inEventData) == eventHash(newEvent("EXE")), false, t)
+	assertEqual(newDedupeKey(inEventData).matches(newEvent("EXE")), false, t)
+	assertEqual(d.Admit(inEventData, now), true, t)
+}
+
+func TestDeduplicatorHashCollision(t *T) {
+	d := &Deduplicator{Window: time.Minute}
+	now := time.Now()
+	a := &WinLogEvent{ProviderName: "a", EventId: 1, EventData: map[string]string{"x": "1"}}
+	b := &WinLogEvent{ProviderName: "b", EventId: 2}
+	// Pretend b's hash collides with a's
+	d.windows = map[uint64][]*dedupeWindow{eventHash(b): {{key: newDedupeKey(a), opened: now}}}
+	assertEqual(d.Admit(b, now), true, t)
+	assertEqual(d.Admit(b, now), false, t)
+	assertEqual(len(d.windows[eventHash(b)]), 2, t)
+
+	// Keys are copies, so changing an admitted event doesn't change them
+	c := &WinLogEvent{ProviderName: "c", EventData: map[string]string{"x": "1"}}
+	assertEqual(d.Admit(c, now), true, t)
+	c.EventData["x"] = "2"
+	assertEqual(d.Admit(&WinLogEvent{ProviderName: "c", EventData: map[string]string{"x": "1"}}, now), false, t)
+}
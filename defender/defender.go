@@ -0,0 +1,199 @@
+// Package defender converts Microsoft Defender Antivirus malware detection
+// events into typed structs:
+//
+//	detection, err := defender.ParseDetection(ev)
+//	if err == nil && !detection.ActionTaken {
+//		for _, r := range detection.Resources {
+//			log.Printf("%v found in %v", detection.ThreatName, r.Path)
+//		}
+//	}
+//
+// Events are read from WinLogEvent.EventData, or parsed from Xml when the
+// watcher didn't fill EventData in.
+package defender
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider and channel of Defender events
+const (
+	ProviderName = "Microsoft-Windows-Windows Defender"
+	Channel      = "Microsoft-Windows-Windows Defender/Operational"
+)
+
+// Event IDs parsed by this package
+const (
+	// Malware or potentially unwanted software was detected
+	EventDetected = 1116
+	// An action was taken to protect the system from a detection
+	EventActionTaken = 1117
+)
+
+// ErrUnsupportedEvent is returned by ParseDetection for events other than
+// 1116 and 1117.
+var ErrUnsupportedEvent = errors.New("Not a supported Defender event")
+
+// Resource is an item a threat was found in. Defender records the kind of
+// item before the path, as in "file:_C:\Users\alice\eicar.com".
+type Resource struct {
+	// Such as "file", "process", "regkey" or "containerfile"
+	Type string
+	Path string
+}
+
+// ParseResources parses a Path field, which lists the resources separated
+// by semicolons.
+func ParseResources(s string) []Resource {
+	var resources []Resource
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r Resource
+		if i := strings.Index(item, ":_"); i >= 0 {
+			r.Type = item[:i]
+			r.Path = item[i+2:]
+		} else {
+			r.Path = item
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+// Detection is a malware detection (1116) or the action taken on one
+// (1117). Both events carry the same fields; Action is only meaningful for
+// 1117.
+type Detection struct {
+	Event *winlog.WinLogEvent
+	// Whether this is a 1117 event, reporting the action taken
+	ActionTaken bool
+
+	// Identifies the detection across its 1116 and 1117 events
+	DetectionId   string
+	DetectionTime time.Time
+
+	ThreatId   uint64
+	ThreatName string
+	// Such as "Severe", "High", "Medium" or "Low"
+	Severity string
+	// Such as "Trojan", "Virus" or "Potentially Unwanted Software"
+	Category  string
+	Resources []Resource
+
+	// Process the threat was found in or, for files, which accessed it
+	ProcessName string
+	// Account of the user the detection is attributed to
+	User string
+	// How the threat was found, such as "Real-Time Protection"
+	Source string
+	// Where the threat came from, such as "Local machine" or "Internet"
+	Origin string
+
+	// Action taken, such as "Quarantine", "Remove" or "Allow"
+	Action   string
+	ActionId uint64
+	// Result of the action, zero on success
+	ErrorCode        uint32
+	ErrorDescription string
+	RemediationUser  string
+
+	SignatureVersion string
+	EngineVersion    string
+}
+
+// ParseDetection parses a 1116 or 1117 event.
+func ParseDetection(ev *winlog.WinLogEvent) (*Detection, error) {
+	if (ev.ProviderName != "" && ev.ProviderName != ProviderName) ||
+		(ev.EventId != EventDetected && ev.EventId != EventActionTaken) {
+		return nil, ErrUnsupportedEvent
+	}
+	data := ev.EventData
+	if data == nil && len(ev.Xml) > 0 {
+		var err error
+		if data, err = winlog.ParseEventData(ev.Xml); err != nil {
+			return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+		}
+	}
+
+	f := fields{data: data}
+	detection := &Detection{
+		Event:            ev,
+		ActionTaken:      ev.EventId == EventActionTaken,
+		DetectionId:      f.str("Detection ID"),
+		DetectionTime:    f.time("Detection Time"),
+		ThreatId:         f.uint("Threat ID"),
+		ThreatName:       f.str("Threat Name"),
+		Severity:         f.str("Severity Name"),
+		Category:         f.str("Category Name"),
+		Resources:        ParseResources(f.str("Path")),
+		ProcessName:      f.str("Process Name"),
+		User:             f.str("Detection User"),
+		Source:           f.str("Source Name"),
+		Origin:           f.str("Origin Name"),
+		Action:           f.str("Action Name"),
+		ActionId:         f.uint("Action ID"),
+		ErrorCode:        uint32(f.uint("Error Code")),
+		ErrorDescription: f.str("Error Description"),
+		RemediationUser:  f.str("Remediation User"),
+		SignatureVersion: f.str("Security intelligence Version"),
+		EngineVersion:    f.str("Engine Version"),
+	}
+	if detection.SignatureVersion == "" {
+		// Older versions of Defender call it the signature version
+		detection.SignatureVersion = f.str("Signature Version")
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return detection, nil
+}
+
+// fields reads EventData values, remembering the first malformed one.
+type fields struct {
+	data map[string]string
+	err  error
+}
+
+// str returns a field, with "-" and "Unknown", which Defender uses for
+// missing values, as "".
+func (f *fields) str(name string) string {
+	value := strings.TrimSpace(f.data[name])
+	if value == "-" || value == "Unknown" {
+		return ""
+	}
+	return value
+}
+
+// uint parses a decimal or 0x-prefixed hexadecimal field, as 0 if missing.
+func (f *fields) uint(name string) uint64 {
+	value := f.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 0, 64)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("Failed to parse %v %q: %v", name, value, err)
+	}
+	return n
+}
+
+func (f *fields) time(name string) time.Time {
+	value := f.str(name)
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("Failed to parse %v %q: %v", name, value, err)
+	}
+	return t
+}
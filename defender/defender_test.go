@@ -0,0 +1,64 @@
+package defender
+
+import (
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+func TestDetected(t *T) {
+	d, err := ParseDetection(testutil.EventFixture(t, EventDetected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(d.ActionTaken, false, t)
+	testutil.AssertEqual(d.DetectionId, "{3a4b5c6d-7e8f-4091-a2b3-c4d5e6f70819}", t)
+	testutil.AssertEqual(d.DetectionTime, time.Date(2021, 3, 4, 5, 6, 7, 123e6, time.UTC), t)
+	testutil.AssertEqual(d.ThreatId, uint64(2147519003), t)
+	testutil.AssertEqual(d.ThreatName, "Virus:DOS/EICAR_Test_File", t)
+	testutil.AssertEqual(d.Severity, "Severe", t)
+	testutil.AssertEqual(d.Category, "Virus", t)
+	testutil.AssertEqual(len(d.Resources), 2, t)
+	testutil.AssertEqual(d.Resources[0], Resource{Type: "file", Path: `C:\Users\alice\Downloads\eicar.com`}, t)
+	testutil.AssertEqual(d.Resources[1].Type, "webfile", t)
+	testutil.AssertEqual(d.ProcessName, `C:\Program Files\Mozilla Firefox\firefox.exe`, t)
+	testutil.AssertEqual(d.User, `CORP\alice`, t)
+	testutil.AssertEqual(d.Source, "Real-Time Protection", t)
+	testutil.AssertEqual(d.Origin, "Internet", t)
+	testutil.AssertEqual(d.Action, "Not Applicable", t)
+	testutil.AssertEqual(d.RemediationUser, "", t)
+	testutil.AssertEqual(d.SignatureVersion, "AV: 1.333.1040.0, AS: 1.333.1040.0, NIS: 1.333.1040.0", t)
+}
+
+func TestActionTaken(t *T) {
+	ev := testutil.EventFixture(t, EventActionTaken)
+	// Parsed from Xml when EventData wasn't filled in
+	ev.EventData = nil
+	d, err := ParseDetection(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(d.Event, ev, t)
+	testutil.AssertEqual(d.ActionTaken, true, t)
+	testutil.AssertEqual(d.Action, "Quarantine", t)
+	testutil.AssertEqual(d.ActionId, uint64(2), t)
+	testutil.AssertEqual(d.ErrorCode, uint32(0), t)
+	testutil.AssertEqual(d.RemediationUser, `NT AUTHORITY\SYSTEM`, t)
+
+	if _, err := ParseDetection(&winlog.WinLogEvent{ProviderName: ProviderName, EventId: 5007}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+	if _, err := ParseDetection(&winlog.WinLogEvent{EventId: EventDetected, EventData: map[string]string{"Threat ID": "x"}}); err == nil {
+		t.Fatal("Expected an error for a malformed Threat ID")
+	}
+}
+
+func TestParseResources(t *T) {
+	resources := ParseResources(`process:_pid:4120,ProcessStart:132591051671234567; regkey:_HKLM\Software\Run\\evil;C:\no-type.exe;`)
+	testutil.AssertEqual(len(resources), 3, t)
+	testutil.AssertEqual(resources[0], Resource{Type: "process", Path: "pid:4120,ProcessStart:132591051671234567"}, t)
+	testutil.AssertEqual(resources[1], Resource{Type: "regkey", Path: `HKLM\Software\Run\\evil`}, t)
+	testutil.AssertEqual(resources[2], Resource{Path: `C:\no-type.exe`}, t)
+}
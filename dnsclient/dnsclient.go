@@ -0,0 +1,249 @@
+// Package dnsclient converts events from the Windows DNS client's
+// operational log into typed structs, with answers parsed into record types
+// and addresses:
+//
+//	query, err := dnsclient.Parse(ev)
+//	if err == nil && ev.EventId == dnsclient.EventQueryCompleted {
+//		log.Printf("%v %v: %v", query.Name, query.Type, query.Addresses())
+//	}
+//
+// The channel is disabled by default; enable it with
+// wevtutil sl Microsoft-Windows-DNS-Client/Operational /e:true. Events are
+// read from WinLogEvent.EventData, or parsed from Xml when the watcher
+// didn't fill EventData in.
+package dnsclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider and channel of DNS client events
+const (
+	ProviderName = "Microsoft-Windows-DNS-Client"
+	Channel      = "Microsoft-Windows-DNS-Client/Operational"
+)
+
+// Event IDs parsed by this package
+const (
+	// A query was started, with the servers it will be sent to
+	EventQueryStarted = 3006
+	// A query completed, with its answers or error
+	EventQueryCompleted = 3008
+	// A server responded to a query sent over the network
+	EventQueryResponse = 3020
+)
+
+// ErrUnsupportedEvent is returned by Parse for events this package doesn't
+// know how to parse.
+var ErrUnsupportedEvent = errors.New("Not a supported DNS client event")
+
+// RecordType is a DNS resource record type, such as 1 for A.
+type RecordType uint16
+
+const (
+	TypeA     RecordType = 1
+	TypeNS    RecordType = 2
+	TypeCNAME RecordType = 5
+	TypeSOA   RecordType = 6
+	TypePTR   RecordType = 12
+	TypeMX    RecordType = 15
+	TypeTXT   RecordType = 16
+	TypeAAAA  RecordType = 28
+	TypeSRV   RecordType = 33
+	TypeHTTPS RecordType = 65
+	TypeANY   RecordType = 255
+)
+
+var recordTypeNames = map[RecordType]string{
+	TypeA:     "A",
+	TypeNS:    "NS",
+	TypeCNAME: "CNAME",
+	TypeSOA:   "SOA",
+	TypePTR:   "PTR",
+	TypeMX:    "MX",
+	TypeTXT:   "TXT",
+	TypeAAAA:  "AAAA",
+	TypeSRV:   "SRV",
+	TypeHTTPS: "HTTPS",
+	TypeANY:   "ANY",
+}
+
+func (t RecordType) String() string {
+	if name, ok := recordTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", uint16(t))
+}
+
+// Descriptions of common QueryStatus codes
+var statusText = map[uint32]string{
+	0:    "Success",
+	87:   "Invalid parameter",
+	1460: "Timeout",
+	9002: "Server failure",
+	9003: "Name does not exist",
+	9501: "No records found",
+	9560: "Invalid name",
+	9701: "No records of the requested type",
+}
+
+// StatusText describes a QueryStatus code, or returns "" for an unknown
+// code.
+func StatusText(status uint32) string {
+	return statusText[status]
+}
+
+// Answer is one record of a query's results.
+type Answer struct {
+	Type RecordType
+	// The record's data: the address of an A or AAAA record, or the target
+	// name of a CNAME
+	Data string
+	// For A and AAAA records, the address, in IPv4 form where possible
+	Address net.IP
+}
+
+// ParseResults parses a QueryResults field, such as
+// "type:  5 example.net;::ffff:93.184.216.34;". Addresses appear bare, while
+// other records are prefixed with their type.
+func ParseResults(s string) []Answer {
+	var answers []Answer
+	for _, result := range strings.Split(s, ";") {
+		result = strings.TrimSpace(result)
+		if result == "" {
+			continue
+		}
+		if strings.HasPrefix(result, "type:") {
+			parts := strings.Fields(strings.TrimPrefix(result, "type:"))
+			if len(parts) == 0 {
+				continue
+			}
+			n, err := strconv.ParseUint(parts[0], 10, 16)
+			if err != nil {
+				continue
+			}
+			answers = append(answers, Answer{Type: RecordType(n), Data: strings.Join(parts[1:], " ")})
+			continue
+		}
+		ip := net.ParseIP(result)
+		if ip == nil {
+			continue
+		}
+		answer := Answer{Type: TypeAAAA, Address: ip}
+		if ip4 := ip.To4(); ip4 != nil {
+			answer = Answer{Type: TypeA, Address: ip4}
+		}
+		answer.Data = answer.Address.String()
+		answers = append(answers, answer)
+	}
+	return answers
+}
+
+// Query is a DNS query started (3006), completed (3008) or answered by a
+// server (3020). Fields not recorded by an event are left empty.
+type Query struct {
+	Event *winlog.WinLogEvent
+	Name  string
+	// Not recorded for 3020
+	Type RecordType
+	// DNS_QUERY_OPTIONS flags passed to DnsQueryEx
+	Options uint64
+
+	// Only for 3006: the servers the query will be sent to, and whether it
+	// is sent over the network at all rather than answered from the cache
+	// or hosts file
+	Servers        []net.IP
+	IsNetworkQuery bool
+	InterfaceIndex uint64
+
+	// For 3008 and 3020: the result, zero on success, which StatusText
+	// describes, and the answers
+	Status  uint32
+	Answers []Answer
+}
+
+// Parse parses a 3006, 3008 or 3020 event.
+func Parse(ev *winlog.WinLogEvent) (*Query, error) {
+	if (ev.ProviderName != "" && ev.ProviderName != ProviderName) ||
+		(ev.EventId != EventQueryStarted && ev.EventId != EventQueryCompleted && ev.EventId != EventQueryResponse) {
+		return nil, ErrUnsupportedEvent
+	}
+	data := ev.EventData
+	if data == nil && len(ev.Xml) > 0 {
+		var err error
+		if data, err = winlog.ParseEventData(ev.Xml); err != nil {
+			return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+		}
+	}
+
+	f := fields{data: data}
+	query := &Query{
+		Event:   ev,
+		Name:    f.str("QueryName"),
+		Type:    RecordType(f.uint("QueryType")),
+		Options: f.uint("QueryOptions"),
+	}
+	switch ev.EventId {
+	case EventQueryStarted:
+		for _, server := range strings.Split(f.str("ServerList"), ";") {
+			if ip := net.ParseIP(strings.TrimSpace(server)); ip != nil {
+				if ip4 := ip.To4(); ip4 != nil {
+					ip = ip4
+				}
+				query.Servers = append(query.Servers, ip)
+			}
+		}
+		query.IsNetworkQuery = f.uint("IsNetworkQuery") != 0
+		query.InterfaceIndex = f.uint("InterfaceIndex")
+	case EventQueryCompleted:
+		query.Status = uint32(f.uint("QueryStatus"))
+		query.Answers = ParseResults(f.str("QueryResults"))
+	case EventQueryResponse:
+		query.Status = uint32(f.uint("Status"))
+		query.Answers = ParseResults(f.str("QueryResults"))
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return query, nil
+}
+
+// Addresses returns the addresses of the query's A and AAAA answers.
+func (q *Query) Addresses() []net.IP {
+	var addresses []net.IP
+	for _, answer := range q.Answers {
+		if answer.Address != nil {
+			addresses = append(addresses, answer.Address)
+		}
+	}
+	return addresses
+}
+
+// fields reads EventData values, remembering the first malformed one.
+type fields struct {
+	data map[string]string
+	err  error
+}
+
+func (f *fields) str(name string) string {
+	return strings.TrimSpace(f.data[name])
+}
+
+// uint parses a decimal or 0x-prefixed hexadecimal field, as 0 if missing.
+func (f *fields) uint(name string) uint64 {
+	value := f.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 0, 64)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("Failed to parse %v %q: %v", name, value, err)
+	}
+	return n
+}
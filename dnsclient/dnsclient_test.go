@@ -0,0 +1,77 @@
+package dnsclient
+
+import (
+	"net"
+	. "testing"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+// parseFixture parses testdata/<id>.xml.
+func parseFixture(t *T, id uint64) *Query {
+	t.Helper()
+	ev := testutil.EventFixture(t, id)
+	query, err := Parse(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(query.Event, ev, t)
+	return query
+}
+
+func TestQueryStarted(t *T) {
+	q := parseFixture(t, EventQueryStarted)
+	testutil.AssertEqual(q.Name, "www.example.com", t)
+	testutil.AssertEqual(q.Type, TypeA, t)
+	testutil.AssertEqual(q.Options, uint64(140737488355328), t)
+	testutil.AssertEqual(len(q.Servers), 2, t)
+	testutil.AssertEqual(q.Servers[0].String(), "192.168.10.1", t)
+	testutil.AssertEqual(len(q.Servers[0]), net.IPv4len, t)
+	testutil.AssertEqual(q.Servers[1].String(), "fe80::1", t)
+	testutil.AssertEqual(q.IsNetworkQuery, true, t)
+	testutil.AssertEqual(q.InterfaceIndex, uint64(12), t)
+	testutil.AssertEqual(len(q.Answers), 0, t)
+}
+
+func TestQueryCompleted(t *T) {
+	q := parseFixture(t, EventQueryCompleted)
+	testutil.AssertEqual(q.Status, uint32(0), t)
+	testutil.AssertEqual(StatusText(q.Status), "Success", t)
+	testutil.AssertEqual(len(q.Answers), 4, t)
+	testutil.AssertEqual(q.Answers[0].Type, TypeCNAME, t)
+	testutil.AssertEqual(q.Answers[0].Data, "www.example.com-v4.edgesuite.net", t)
+	testutil.AssertEqual(q.Answers[0].Address == nil, true, t)
+	testutil.AssertEqual(q.Answers[2].Type, TypeA, t)
+	testutil.AssertEqual(q.Answers[2].Data, "93.184.216.34", t)
+	addresses := q.Addresses()
+	testutil.AssertEqual(len(addresses), 2, t)
+	testutil.AssertEqual(len(addresses[0]), net.IPv4len, t)
+	testutil.AssertEqual(addresses[1].String(), "93.184.216.35", t)
+}
+
+func TestQueryResponse(t *T) {
+	q := parseFixture(t, EventQueryResponse)
+	testutil.AssertEqual(q.Name, "missing.example.com", t)
+	testutil.AssertEqual(q.Type, RecordType(0), t)
+	testutil.AssertEqual(q.Status, uint32(9003), t)
+	testutil.AssertEqual(StatusText(q.Status), "Name does not exist", t)
+	testutil.AssertEqual(len(q.Answers), 0, t)
+}
+
+func TestParseResults(t *T) {
+	answers := ParseResults("type:  16 v=spf1 -all;2606:2800:220:1:248:1893:25c8:1946;garbage;type: x;")
+	testutil.AssertEqual(len(answers), 2, t)
+	testutil.AssertEqual(answers[0].Type, TypeTXT, t)
+	testutil.AssertEqual(answers[0].Data, "v=spf1 -all", t)
+	testutil.AssertEqual(answers[1].Type, TypeAAAA, t)
+	testutil.AssertEqual(answers[1].Type.String(), "AAAA", t)
+	testutil.AssertEqual(RecordType(99).String(), "TYPE99", t)
+
+	if _, err := Parse(&winlog.WinLogEvent{ProviderName: ProviderName, EventId: 3009}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+	if _, err := Parse(&winlog.WinLogEvent{EventId: EventQueryCompleted, EventData: map[string]string{"QueryStatus": "x"}}); err == nil {
+		t.Fatal("Expected an error for a malformed QueryStatus")
+	}
+}
@@ -0,0 +1,158 @@
+package winlog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Time the watcher's enrichers may take for each event, if its EnrichBudget
+// is zero
+const DefaultEnrichBudget = 100 * time.Millisecond
+
+// Enricher adds fields to events from a source outside the event, such as an
+// asset database or threat intelligence on the hashes in Sysmon events. It
+// is added to a watcher with AddEnricher, which warms it up, and closed when
+// the watcher shuts down.
+type Enricher interface {
+	// Name identifies the enricher in logs and errors
+	Name() string
+	// WarmUp prepares the enricher before its first event, such as by
+	// connecting to its source or filling a cache
+	WarmUp(ctx context.Context) error
+	// Enrich returns the fields to add to the event's Enriched. It's called
+	// concurrently for events from different subscriptions, mustn't modify
+	// the event, and must return promptly once `ctx` is done: the watcher
+	// waits for it.
+	Enrich(ctx context.Context, ev *WinLogEvent) (map[string]string, error)
+	Close() error
+}
+
+// EnricherFactory creates an Enricher from its configuration, for
+// RegisterEnricher.
+type EnricherFactory func(config map[string]string) (Enricher, error)
+
+var (
+	enricherFactoriesMutex sync.Mutex
+	enricherFactories      = make(map[string]EnricherFactory)
+)
+
+// RegisterEnricher makes a kind of Enricher available to NewEnricher, so
+// collectors can choose enrichers by name in their configuration. It's
+// meant to be called from the init function of the package implementing
+// the enricher, and panics if `kind` is already registered.
+func RegisterEnricher(kind string, factory EnricherFactory) {
+	enricherFactoriesMutex.Lock()
+	defer enricherFactoriesMutex.Unlock()
+	if factory == nil {
+		panic("winlog: RegisterEnricher factory is nil")
+	}
+	if _, ok := enricherFactories[kind]; ok {
+		panic("winlog: RegisterEnricher called twice for " + kind)
+	}
+	enricherFactories[kind] = factory
+}
+
+// NewEnricher creates an Enricher of a kind registered with
+// RegisterEnricher.
+func NewEnricher(kind string, config map[string]string) (Enricher, error) {
+	enricherFactoriesMutex.Lock()
+	factory, ok := enricherFactories[kind]
+	enricherFactoriesMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown enricher %q, registered enrichers are %v", kind, strings.Join(EnricherKinds(), ", "))
+	}
+	return factory(config)
+}
+
+// EnricherKinds returns the sorted kinds of Enricher registered with
+// RegisterEnricher.
+func EnricherKinds() []string {
+	enricherFactoriesMutex.Lock()
+	defer enricherFactoriesMutex.Unlock()
+	kinds := make([]string, 0, len(enricherFactories))
+	for kind := range enricherFactories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// AddEnricher warms up the enricher and adds it to the watcher. Delivered
+// events are enriched by each enricher in the order they were added, after
+// filtering and redaction, within the watcher's EnrichBudget.
+func (self *WinLogWatcher) AddEnricher(ctx context.Context, enricher Enricher) error {
+	if err := enricher.WarmUp(ctx); err != nil {
+		return fmt.Errorf("Failed to warm up enricher %v: %v", enricher.Name(), err)
+	}
+	self.enrichMutex.Lock()
+	self.enrichers = append(self.enrichers, enricher)
+	self.enrichMutex.Unlock()
+	self.log(LogInfo, "Added enricher", "enricher", enricher.Name())
+	return nil
+}
+
+// enrich adds the fields from the watcher's enrichers to the event. An
+// enricher's failure doesn't stop the event being delivered: it's recorded
+// in the event's EnrichErr and counted, and the event is delivered with
+// the fields the other enrichers found.
+func (self *WinLogWatcher) enrich(event *WinLogEvent, stats *subscriptionStats) {
+	self.enrichMutex.RLock()
+	enrichers := self.enrichers
+	self.enrichMutex.RUnlock()
+	if len(enrichers) == 0 {
+		return
+	}
+	budget := self.EnrichBudget
+	if budget <= 0 {
+		budget = DefaultEnrichBudget
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	var failures []string
+	for i, enricher := range enrichers {
+		if ctx.Err() != nil {
+			atomic.AddUint64(&stats.enrichOverBudget, 1)
+			failures = append(failures, fmt.Sprintf("budget of %v spent before %v enrichers ran", budget, len(enrichers)-i))
+			break
+		}
+		fields, err := enricher.Enrich(ctx, event)
+		if err != nil {
+			atomic.AddUint64(&stats.enrichErrors, 1)
+			failures = append(failures, fmt.Sprintf("%v: %v", enricher.Name(), err))
+			self.log(LogDebug, "Failed to enrich event", "enricher", enricher.Name(), "channel", event.SubscribedChannel, "recordId", event.RecordId, "error", err)
+			continue
+		}
+		for name, value := range fields {
+			if _, ok := event.Enriched[name]; ok {
+				// Fields of earlier enrichers take precedence
+				continue
+			}
+			if event.Enriched == nil {
+				event.Enriched = make(map[string]string, len(fields))
+			}
+			event.Enriched[name] = value
+		}
+	}
+	if len(failures) > 0 {
+		event.EnrichErr = fmt.Errorf("Failed to enrich event: %v", strings.Join(failures, "; "))
+	}
+}
+
+// closeEnrichers closes the watcher's enrichers when it shuts down.
+func (self *WinLogWatcher) closeEnrichers() {
+	self.enrichMutex.Lock()
+	enrichers := self.enrichers
+	self.enrichers = nil
+	self.enrichMutex.Unlock()
+	for _, enricher := range enrichers {
+		if err := enricher.Close(); err != nil {
+			self.log(LogWarn, "Failed to close enricher", "enricher", enricher.Name(), "error", err)
+		}
+	}
+}
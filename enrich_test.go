@@ -0,0 +1,111 @@
+package winlog
+
+import (
+	"context"
+	"fmt"
+	. "testing"
+	"time"
+)
+
+type fakeEnricher struct {
+	name   string
+	fields map[string]string
+	err    error
+	delay  time.Duration
+	warm   bool
+	closed bool
+}
+
+func (e *fakeEnricher) Name() string { return e.name }
+
+func (e *fakeEnricher) WarmUp(ctx context.Context) error {
+	e.warm = true
+	return e.err
+}
+
+func (e *fakeEnricher) Enrich(ctx context.Context, ev *WinLogEvent) (map[string]string, error) {
+	if e.delay > 0 {
+		select {
+		case <-time.After(e.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if e.fields == nil {
+		return nil, fmt.Errorf("lookup failed")
+	}
+	return e.fields, nil
+}
+
+func (e *fakeEnricher) Close() error {
+	e.closed = true
+	return nil
+}
+
+func TestEnricherRegistry(t *T) {
+	// Registrations are global, so each run of the test uses its own kind
+	kind := fmt.Sprintf("test-assets-%v", time.Now().UnixNano())
+	RegisterEnricher(kind, func(config map[string]string) (Enricher, error) {
+		return &fakeEnricher{name: "assets", fields: map[string]string{"Owner": config["owner"]}}, nil
+	})
+	enricher, err := NewEnricher(kind, map[string]string{"owner": "it"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(enricher.Name(), "assets", t)
+	if _, err := NewEnricher("missing", nil); err == nil {
+		t.Error("Expected an error creating an unregistered enricher")
+	}
+	found := false
+	for _, k := range EnricherKinds() {
+		found = found || k == kind
+	}
+	assertEqual(found, true, t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering twice to panic")
+		}
+	}()
+	RegisterEnricher(kind, func(map[string]string) (Enricher, error) { return nil, nil })
+}
+
+func TestWatcherEnrich(t *T) {
+	watcher, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets := &fakeEnricher{name: "assets", fields: map[string]string{"Owner": "it", "Site": "hq"}}
+	intel := &fakeEnricher{name: "intel", fields: map[string]string{"Owner": "ignored", "Verdict": "clean"}}
+	broken := &fakeEnricher{name: "broken"}
+	for _, enricher := range []*fakeEnricher{assets, broken, intel} {
+		if err := watcher.AddEnricher(context.Background(), enricher); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(enricher.warm, true, t)
+	}
+	if err := watcher.AddEnricher(context.Background(), &fakeEnricher{name: "cold", err: fmt.Errorf("unreachable")}); err == nil {
+		t.Error("Expected an error adding an enricher which fails to warm up")
+	}
+
+	stats := &subscriptionStats{}
+	ev := &WinLogEvent{}
+	watcher.enrich(ev, stats)
+	assertEqual(len(ev.Enriched), 3, t)
+	assertEqual(ev.Enriched["Owner"], "it", t)
+	assertEqual(ev.Enriched["Verdict"], "clean", t)
+	assertEqual(ev.EnrichErr.Error(), "Failed to enrich event: broken: lookup failed", t)
+	assertEqual(stats.snapshot().EnrichErrors, uint64(1), t)
+
+	// A slow enricher spends the budget, and the rest are skipped
+	watcher.EnrichBudget = 10 * time.Millisecond
+	watcher.enrichers = []Enricher{&fakeEnricher{name: "slow", delay: time.Minute}, assets}
+	ev = &WinLogEvent{}
+	watcher.enrich(ev, stats)
+	assertEqual(len(ev.Enriched), 0, t)
+	assertEqual(stats.snapshot().EnrichErrors, uint64(2), t)
+	assertEqual(stats.snapshot().EnrichOverBudget, uint64(1), t)
+
+	watcher.Shutdown()
+	assertEqual(assets.closed, true, t)
+}
@@ -0,0 +1,79 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// ListChannels returns the names of the channels registered on this
+// computer. Wraps EvtOpenChannelEnum and EvtNextChannelPath.
+func ListChannels() ([]string, error) {
+	enum, err := EvtOpenChannelEnum(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer EvtClose(enum)
+	return enumStrings(enum, EvtNextChannelPath)
+}
+
+// ListPublishers returns the names of the providers registered on this
+// computer. Wraps EvtOpenPublisherEnum and EvtNextPublisherId.
+func ListPublishers() ([]string, error) {
+	enum, err := EvtOpenPublisherEnum(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer EvtClose(enum)
+	return enumStrings(enum, EvtNextPublisherId)
+}
+
+func enumStrings(enum syscall.Handle, next func(syscall.Handle, uint32, *uint16, *uint32) error) ([]string, error) {
+	var names []string
+	buf := make([]uint16, 256)
+	for {
+		var used uint32
+		err := next(enum, uint32(len(buf)), &buf[0], &used)
+		if errors.Is(err, windows.ERROR_INSUFFICIENT_BUFFER) {
+			buf = make([]uint16, used)
+			continue
+		} else if errors.Is(err, windows.ERROR_NO_MORE_ITEMS) {
+			return names, nil
+		} else if err != nil {
+			return nil, err
+		}
+		names = append(names, syscall.UTF16ToString(buf[:used]))
+	}
+}
+
+// ExportChannel writes the events on `channel` matching `query` to a new
+// .evtx file at `targetPath`. Wraps EvtExportLog.
+func ExportChannel(channel, query, targetPath string) error {
+	return exportLog(channel, query, targetPath, EvtQueryChannelPath)
+}
+
+// ExportFile writes the events in the .evtx file at `path` matching `query`
+// to a new .evtx file at `targetPath`.
+func ExportFile(path, query, targetPath string) error {
+	return exportLog(path, query, targetPath, EvtQueryFilePath)
+}
+
+func exportLog(path, query, targetPath string, flags uint32) error {
+	widePath, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	wideQuery, err := syscall.UTF16PtrFromString(query)
+	if err != nil {
+		return err
+	}
+	wideTarget, err := syscall.UTF16PtrFromString(targetPath)
+	if err != nil {
+		return err
+	}
+	return EvtExportLog(0, widePath, wideQuery, wideTarget, flags)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sync"
 	"syscall"
 	"time"
 	"unsafe"
@@ -15,7 +16,46 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-type evtCbFunction func(Action uint32, Context uintptr, handle syscall.Handle) uintptr
+// Subscriptions' callbacks, by the context passed to the event callback,
+// and the contexts of open subscription handles. Callbacks made with
+// syscall.NewCallback are never freed, and only about 2000 can be made, so
+// all subscriptions share one.
+var (
+	listenersMutex   sync.Mutex
+	listeners        = make(map[uintptr]*LogEventCallbackWrapper)
+	listenerContexts = make(map[ListenerHandle]uintptr)
+	nextListener     uintptr
+	callbackOnce     sync.Once
+	eventCallback    uintptr
+)
+
+func subscriptionCallback() uintptr {
+	callbackOnce.Do(func() {
+		eventCallback = syscall.NewCallback(onSubscriptionEvent)
+	})
+	return eventCallback
+}
+
+// addListener registers a subscription's callback, returning the context
+// its events are passed with.
+func addListener(watcher *LogEventCallbackWrapper) uintptr {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+	nextListener++
+	listeners[nextListener] = watcher
+	return nextListener
+}
+
+// removeListener forgets the callback of a subscription handle once it's
+// closed, when no more of its events will be passed.
+func removeListener(handle ListenerHandle) {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+	if context, ok := listenerContexts[handle]; ok {
+		delete(listeners, context)
+		delete(listenerContexts, handle)
+	}
+}
 
 /*Functionality related to events and listening to the event log*/
 
@@ -37,6 +77,24 @@ func GetSystemRenderContext() (SysRenderContext, error) {
 		The resulting handle must be closed with CloseEventHandle.
 */
 func CreateListener(channel, query string, startpos EVT_SUBSCRIBE_FLAGS, watcher *LogEventCallbackWrapper) (ListenerHandle, error) {
+	return CreateSessionListener(0, channel, query, startpos, 0, watcher)
+}
+
+/*
+Get a handle for an event log subscription on the given channel. Will begin at the
+
+	bookmarked event, or the closest possible event if the log has been truncated.
+	`query` is an XPath expression to filter the events on the channel - "*" allows all events.
+	The resulting handle must be closed with CloseEventHandle.
+*/
+func CreateListenerFromBookmark(channel, query string, watcher *LogEventCallbackWrapper, bookmarkHandle BookmarkHandle) (ListenerHandle, error) {
+	return CreateSessionListener(0, channel, query, EvtSubscribeStartAfterBookmark, bookmarkHandle, watcher)
+}
+
+// CreateSessionListener subscribes to a channel through a session opened
+// with OpenSession, or on this computer if `session` is 0. The bookmark is
+// only used with EvtSubscribeStartAfterBookmark.
+func CreateSessionListener(session SessionHandle, channel, query string, startpos EVT_SUBSCRIBE_FLAGS, bookmarkHandle BookmarkHandle, watcher *LogEventCallbackWrapper) (ListenerHandle, error) {
 	wideChan, err := syscall.UTF16PtrFromString(channel)
 	if err != nil {
 		return 0, err
@@ -45,21 +103,40 @@ func CreateListener(channel, query string, startpos EVT_SUBSCRIBE_FLAGS, watcher
 	if err != nil {
 		return 0, err
 	}
-	listenerHandle, err := EvtSubscribe(0, 0, wideChan, wideQuery, 0, uintptr(0), syscall.NewCallback(newEventCallback(watcher)), uint32(startpos))
+	if startpos != EvtSubscribeStartAfterBookmark {
+		bookmarkHandle = 0
+	}
+	context := addListener(watcher)
+	listenerHandle, err := EvtSubscribe(syscall.Handle(session), 0, wideChan, wideQuery, syscall.Handle(bookmarkHandle), context, subscriptionCallback(), uint32(startpos))
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
 	if err != nil {
+		delete(listeners, context)
 		return 0, err
 	}
+	listenerContexts[ListenerHandle(listenerHandle)] = context
 	return ListenerHandle(listenerHandle), nil
 }
 
-/*
-Get a handle for an event log subscription on the given channel. Will begin at the
+// Signals set by the event log when pull subscriptions have events to read,
+// by subscription handle
+var (
+	pullSignalsMutex sync.Mutex
+	pullSignals      = make(map[ListenerHandle]*pullSignal)
+)
 
-	bookmarked event, or the closest possible event if the log has been truncated.
-	`query` is an XPath expression to filter the events on the channel - "*" allows all events.
-	The resulting handle must be closed with CloseEventHandle.
-*/
-func CreateListenerFromBookmark(channel, query string, watcher *LogEventCallbackWrapper, bookmarkHandle BookmarkHandle) (ListenerHandle, error) {
+type pullSignal struct {
+	event windows.Handle
+	// Goroutines waiting on the event, which is closed when the last one
+	// returns if the subscription was closed under them
+	waiters int
+	closed  bool
+}
+
+// createPullListener subscribes like CreateSessionListener, but without a
+// callback: events are read with nextEvents once waitPullListener returns,
+// and stay valid until they're closed.
+func createPullListener(session SessionHandle, channel, query string, startpos EVT_SUBSCRIBE_FLAGS, bookmarkHandle BookmarkHandle) (ListenerHandle, error) {
 	wideChan, err := syscall.UTF16PtrFromString(channel)
 	if err != nil {
 		return 0, err
@@ -68,29 +145,85 @@ func CreateListenerFromBookmark(channel, query string, watcher *LogEventCallback
 	if err != nil {
 		return 0, err
 	}
-	listenerHandle, err := EvtSubscribe(0, 0, wideChan, wideQuery, syscall.Handle(bookmarkHandle), uintptr(0), syscall.NewCallback(newEventCallback(watcher)), uint32(EvtSubscribeStartAfterBookmark))
+	if startpos != EvtSubscribeStartAfterBookmark {
+		bookmarkHandle = 0
+	}
+	// Manual reset, and set to begin with so the first wait reads whatever
+	// the subscription starts with
+	signal, err := windows.CreateEvent(nil, 1, 1, nil)
+	if err != nil {
+		return 0, err
+	}
+	listenerHandle, err := EvtSubscribe(syscall.Handle(session), syscall.Handle(signal), wideChan, wideQuery, syscall.Handle(bookmarkHandle), 0, 0, uint32(startpos))
 	if err != nil {
+		windows.CloseHandle(signal)
 		return 0, err
 	}
+	pullSignalsMutex.Lock()
+	defer pullSignalsMutex.Unlock()
+	pullSignals[ListenerHandle(listenerHandle)] = &pullSignal{event: signal}
 	return ListenerHandle(listenerHandle), nil
 }
 
-/* Get the formatted string that represents this message. This method wraps EvtFormatMessage. */
-func FormatMessage(eventPublisherHandle PublisherHandle, eventHandle EventHandle, format EVT_FORMAT_MESSAGE_FLAGS) (string, error) {
-	var size uint32 = 0
-	err := EvtFormatMessage(syscall.Handle(eventPublisherHandle), syscall.Handle(eventHandle), 0, 0, nil, uint32(format), 0, nil, &size)
-	if err != nil {
-		if errno, ok := err.(syscall.Errno); !ok || errno != 122 {
-			// Check if the error is ERR_INSUFICIENT_BUFFER
-			return "", err
+// waitPullListener waits up to `timeout` for a pull subscription to have
+// events to read, returning io.EOF once it's closed.
+func waitPullListener(handle ListenerHandle, timeout time.Duration) error {
+	if timeout < 0 || timeout > math.MaxUint32 {
+		return errors.New("invalid timeout")
+	}
+	pullSignalsMutex.Lock()
+	signal, ok := pullSignals[handle]
+	if !ok {
+		pullSignalsMutex.Unlock()
+		return io.EOF
+	}
+	signal.waiters++
+	pullSignalsMutex.Unlock()
+
+	_, err := windows.WaitForSingleObject(signal.event, uint32(timeout.Milliseconds()))
+
+	pullSignalsMutex.Lock()
+	defer pullSignalsMutex.Unlock()
+	signal.waiters--
+	if signal.closed {
+		if signal.waiters == 0 {
+			windows.CloseHandle(signal.event)
 		}
+		return io.EOF
 	}
-	buf := make([]uint16, size)
-	err = EvtFormatMessage(syscall.Handle(eventPublisherHandle), syscall.Handle(eventHandle), 0, 0, nil, uint32(format), uint32(len(buf)), &buf[0], &size)
+	if err != nil {
+		return err
+	}
+	// Events logged from here on set it again, and are read by the next
+	// call to nextEvents along with any logged while waiting
+	return windows.ResetEvent(signal.event)
+}
+
+// closePullSignal wakes anything waiting on a pull subscription which was
+// closed, and closes its signal once they've returned.
+func closePullSignal(handle ListenerHandle) {
+	pullSignalsMutex.Lock()
+	defer pullSignalsMutex.Unlock()
+	signal, ok := pullSignals[handle]
+	if !ok {
+		return
+	}
+	delete(pullSignals, handle)
+	signal.closed = true
+	if signal.waiters == 0 {
+		windows.CloseHandle(signal.event)
+	} else {
+		windows.SetEvent(signal.event)
+	}
+}
+
+/* Get the formatted string that represents this message, however large. This method wraps EvtFormatMessage. */
+func FormatMessage(eventPublisherHandle PublisherHandle, eventHandle EventHandle, format EVT_FORMAT_MESSAGE_FLAGS) (string, error) {
+	msg, err := FormatMessageBuf(eventPublisherHandle, eventHandle, format, nil)
 	if err != nil {
 		return "", err
 	}
-	return syscall.UTF16ToString(buf), nil
+	return string(msg), nil
 }
 
 /* Get the formatted string for the last error which occurred. Wraps GetLastError and FormatMessage. */
@@ -105,40 +238,113 @@ Render the system properties from the event and returns an array of properties.
 	or RenderUIntField depending on type. This buffer must be freed after use.
 */
 func RenderEventValues(renderContext SysRenderContext, eventHandle EventHandle) (EvtVariant, error) {
-	var bufferUsed uint32 = 0
-	var propertyCount uint32 = 0
+	return RenderEventValuesBuf(renderContext, eventHandle, nil)
+}
+
+// RenderEventValuesBuf is RenderEventValues rendering into `buf`, for
+// callers managing their own memory. If buf is too small, a larger buffer
+// is allocated. The values point into the returned buffer, so it mustn't be
+// reused while they're read.
+func RenderEventValuesBuf(renderContext SysRenderContext, eventHandle EventHandle, buf []byte) (EvtVariant, error) {
+	buf = buf[:cap(buf)]
+	for {
+		var bufferUsed, propertyCount uint32
+		err := EvtRender(syscall.Handle(renderContext), syscall.Handle(eventHandle), EvtRenderEventValues, uint32(len(buf)), bufPtr(buf), &bufferUsed, &propertyCount)
+		if err == windows.ERROR_INSUFFICIENT_BUFFER && int(bufferUsed) > len(buf) {
+			buf = make([]byte, bufferUsed)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return NewEvtVariant(buf[:bufferUsed]), nil
+	}
+}
+
+// RenderEventXMLBuf is RenderEventXML rendering into `buf`, and returns the
+// part of it holding the XML. If buf is too small, a larger buffer is
+// allocated, so passing the result back in avoids allocating once it fits
+// the largest event. The UTF-16 XML is converted to UTF-8 in place, which
+// needs up to 1.5 times its size.
+func RenderEventXMLBuf(eventHandle EventHandle, buf []byte) ([]byte, error) {
+	buf = buf[:cap(buf)]
+	for {
+		start, tail := utf16Tail(buf)
+		var bufferUsed, propertyCount uint32
+		err := EvtRender(0, syscall.Handle(eventHandle), EvtRenderEventXml, uint32(len(tail)), bufPtr(tail), &bufferUsed, &propertyCount)
+		if err == windows.ERROR_INSUFFICIENT_BUFFER && utf16TailSize(int(bufferUsed)) > len(buf) {
+			buf = make([]byte, utf16TailSize(int(bufferUsed)))
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return utf16ToUTF8(buf, start, start+int(bufferUsed)), nil
+	}
+}
+
+// FormatMessageBuf is FormatMessage formatting into `buf`, and returns the
+// part of it holding the message as UTF-8. Buffers are handled as by
+// RenderEventXMLBuf.
+func FormatMessageBuf(eventPublisherHandle PublisherHandle, eventHandle EventHandle, format EVT_FORMAT_MESSAGE_FLAGS, buf []byte) ([]byte, error) {
+	buf = buf[:cap(buf)]
+	for {
+		start, tail := utf16Tail(buf)
+		var used uint32
+		err := EvtFormatMessage(syscall.Handle(eventPublisherHandle), syscall.Handle(eventHandle), 0, 0, nil, uint32(format), uint32(len(tail)/2), bufPtr(tail), &used)
+		if err == windows.ERROR_INSUFFICIENT_BUFFER && utf16TailSize(2*int(used)) > len(buf) {
+			buf = make([]byte, utf16TailSize(2*int(used)))
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return utf16ToUTF8(buf, start, start+2*int(used)), nil
+	}
+}
+
+// bufPtr returns a pointer to the start of buf, or nil if it's empty, to
+// ask how large a buffer is needed.
+func bufPtr(buf []byte) *uint16 {
+	if len(buf) == 0 {
+		return nil
+	}
+	return (*uint16)(unsafe.Pointer(&buf[0]))
+}
+
+// RenderRawEvent renders the System values and XML of an event, keeping the
+// buffers EvtRender returned.
+func RenderRawEvent(renderContext SysRenderContext, eventHandle EventHandle) (*RenderedEvent, error) {
+	var bufferUsed, propertyCount uint32
 	err := EvtRender(syscall.Handle(renderContext), syscall.Handle(eventHandle), EvtRenderEventValues, 0, nil, &bufferUsed, &propertyCount)
 	if bufferUsed == 0 {
 		return nil, err
 	}
-	buffer := make([]byte, bufferUsed)
-	bufSize := bufferUsed
-	err = EvtRender(syscall.Handle(renderContext), syscall.Handle(eventHandle), EvtRenderEventValues, bufSize, (*uint16)(unsafe.Pointer(&buffer[0])), &bufferUsed, &propertyCount)
+	values := make([]byte, bufferUsed)
+	err = EvtRender(syscall.Handle(renderContext), syscall.Handle(eventHandle), EvtRenderEventValues, uint32(len(values)), (*uint16)(unsafe.Pointer(&values[0])), &bufferUsed, &propertyCount)
 	if err != nil {
 		return nil, err
 	}
-	return NewEvtVariant(buffer), nil
-}
-
-// Render the event as XML.
-func RenderEventXML(eventHandle EventHandle) ([]byte, error) {
-	var bufferUsed, propertyCount uint32
-
-	err := EvtRender(0, syscall.Handle(eventHandle), EvtRenderEventXml, 0, nil, &bufferUsed, &propertyCount)
+	raw := &RenderedEvent{
+		Values:        values[:bufferUsed],
+		PropertyCount: propertyCount,
+		ValuesAddress: uint64(uintptr(unsafe.Pointer(&values[0]))),
+	}
 
+	err = EvtRender(0, syscall.Handle(eventHandle), EvtRenderEventXml, 0, nil, &bufferUsed, &propertyCount)
 	if bufferUsed == 0 {
 		return nil, err
 	}
-
-	buffer := make([]uint16, bufferUsed)
-	bufSize := bufferUsed
-
-	err = EvtRender(0, syscall.Handle(eventHandle), EvtRenderEventXml, bufSize, &buffer[0], &bufferUsed, &propertyCount)
+	xml := make([]byte, bufferUsed)
+	err = EvtRender(0, syscall.Handle(eventHandle), EvtRenderEventXml, uint32(len(xml)), (*uint16)(unsafe.Pointer(&xml[0])), &bufferUsed, &propertyCount)
 	if err != nil {
 		return nil, err
 	}
+	raw.XML = xml[:bufferUsed]
+	return raw, nil
+}
 
-	return []byte(syscall.UTF16ToString(buffer)), nil
+// Render the event as XML. The buffer is grown until the XML fits, so
+// events with very large EventData, such as script blocks, render whole.
+func RenderEventXML(eventHandle EventHandle) ([]byte, error) {
+	return RenderEventXMLBuf(eventHandle, nil)
 }
 
 /* Get a handle that represents the publisher of the event, given the rendered event values. */
@@ -147,11 +353,54 @@ func GetEventPublisherHandle(renderedFields EvtVariant) (PublisherHandle, error)
 	if err != nil {
 		return 0, err
 	}
-	widePublisher, err := syscall.UTF16PtrFromString(publisher)
+	return OpenPublisherMetadata(publisher)
+}
+
+/* Get a handle to the metadata of the named provider. The handle must be closed with CloseEventHandle. */
+func OpenPublisherMetadata(providerName string) (PublisherHandle, error) {
+	return OpenSessionPublisherMetadata(0, providerName, "")
+}
+
+// OpenLocalizedPublisherMetadata opens the metadata of a provider to format
+// messages in a locale, such as "en-US", rather than the computer's
+// default.
+func OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error) {
+	return OpenSessionPublisherMetadata(0, providerName, locale)
+}
+
+// OpenSessionPublisherMetadata opens the metadata of a provider installed
+// on the computer of a session opened with OpenSession, in a locale such as
+// "en-US", or the computer's default if `locale` is empty.
+func OpenSessionPublisherMetadata(session SessionHandle, providerName, locale string) (PublisherHandle, error) {
+	widePublisher, err := syscall.UTF16PtrFromString(providerName)
+	if err != nil {
+		return 0, err
+	}
+	lcid, err := localeId(locale)
+	if err != nil {
+		return 0, err
+	}
+	handle, err := EvtOpenPublisherMetadata(syscall.Handle(session), widePublisher, nil, lcid, 0)
 	if err != nil {
 		return 0, err
 	}
-	handle, err := EvtOpenPublisherMetadata(0, widePublisher, nil, 0, 0)
+	return PublisherHandle(handle), nil
+}
+
+// OpenArchivedPublisherMetadata opens the metadata of a provider saved with
+// an archived log file, for providers which aren't installed on this
+// computer. The metadata is read from the LocaleMetaData directory next to
+// `logFilePath`, which "wevtutil archive-log" writes.
+func OpenArchivedPublisherMetadata(providerName, logFilePath string) (PublisherHandle, error) {
+	widePublisher, err := syscall.UTF16PtrFromString(providerName)
+	if err != nil {
+		return 0, err
+	}
+	widePath, err := syscall.UTF16PtrFromString(logFilePath)
+	if err != nil {
+		return 0, err
+	}
+	handle, err := EvtOpenPublisherMetadata(0, widePublisher, widePath, 0, 0)
 	if err != nil {
 		return 0, err
 	}
@@ -160,7 +409,12 @@ func GetEventPublisherHandle(renderedFields EvtVariant) (PublisherHandle, error)
 
 /* Close an event handle. */
 func CloseEventHandle(handle uint64) error {
-	return EvtClose(syscall.Handle(handle))
+	err := EvtClose(syscall.Handle(handle))
+	// Subscriptions of a remote session which was lost may fail to close,
+	// but won't call back again, so their callbacks are forgotten anyway
+	removeListener(ListenerHandle(handle))
+	closePullSignal(ListenerHandle(handle))
+	return err
 }
 
 /* Cancel pending actions on the event handle. */
@@ -172,95 +426,68 @@ func CancelEventHandle(handle uint64) error {
 	return nil
 }
 
-type QueryResult struct {
-	handle syscall.Handle
-}
-
-func QueryChannel(channel, query string) (*QueryResult, error) {
-	wideChannel, err := syscall.UTF16PtrFromString(channel)
-	if err != nil {
-		return nil, err
+// queryEventLog runs `query` on the channel or file at `path`, which must be
+// empty for structured XML queries, on the computer of `session`, or this
+// one if it's 0.
+func queryEventLog(session SessionHandle, path, query string, flags uint32) (QueryHandle, error) {
+	var widePath *uint16
+	var err error
+	if path != "" {
+		if widePath, err = syscall.UTF16PtrFromString(path); err != nil {
+			return 0, err
+		}
 	}
 	wideQuery, err := syscall.UTF16PtrFromString(query)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	handle, err := EvtQuery(0, wideChannel, wideQuery, EvtQueryChannelPath)
+	handle, err := EvtQuery(syscall.Handle(session), widePath, wideQuery, flags)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return &QueryResult{handle: handle}, nil
+	return QueryHandle(handle), nil
 }
 
-func (qr *QueryResult) Close() error {
-	if qr.handle == 0 {
-		return nil
-	}
-	if err := EvtClose(qr.handle); err != nil {
-		return err
-	}
-	qr.handle = 0
-	return nil
-}
-
-func (qr *QueryResult) Next(timeout time.Duration) (EventHandle, error) {
-	var record syscall.Handle
-	var recordsReturned uint32
+// nextEvents reads up to len(events) events from a query with EvtNext,
+// returning io.EOF once every matching event has been read.
+func nextEvents(query QueryHandle, events []EventHandle, timeout time.Duration) (int, error) {
 	if timeout < 0 || timeout > math.MaxUint32 {
 		return 0, errors.New("invalid timeout")
 	}
-	if err := EvtNext(qr.handle, 1, &record, uint32(timeout.Milliseconds()), 0, &recordsReturned); err != nil {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	handles := make([]syscall.Handle, len(events))
+	var returned uint32
+	if err := EvtNext(syscall.Handle(query), uint32(len(handles)), &handles[0], uint32(timeout.Milliseconds()), 0, &returned); err != nil && returned == 0 {
 		if errors.Is(err, windows.ERROR_NO_MORE_ITEMS) {
 			return 0, io.EOF
 		}
 		return 0, err
 	}
-	return EventHandle(record), nil
+	for i, handle := range handles[:returned] {
+		events[i] = EventHandle(handle)
+	}
+	return int(returned), nil
 }
 
-// newEventCallback captures the context for use in the callback
-func newEventCallback(context *LogEventCallbackWrapper) evtCbFunction {
-	return func(action uint32, _ uintptr, handle syscall.Handle) uintptr {
-		if action == EvtSubscribeActionError {
-			// When the callback is called for an error, the error code is
-			// passed in the event handle parameter. See
-			// https://learn.microsoft.com/en-us/windows/win32/api/winevt/nc-winevt-evt_subscribe_callback.
-			err := syscall.Errno(uintptr(handle))
-			context.callback.PublishError(fmt.Errorf("Event log callback got error: %v", err))
-		} else {
-			context.callback.PublishEvent(EventHandle(handle), context.subscribedChannel)
-		}
+// onSubscriptionEvent passes an event, or error, to the callback of the
+// subscription registered with `context`
+func onSubscriptionEvent(action uint32, context uintptr, handle syscall.Handle) uintptr {
+	listenersMutex.Lock()
+	watcher := listeners[context]
+	listenersMutex.Unlock()
+	if watcher == nil {
 		return 0
 	}
-}
-
-// CreateMap converts the WinLogEvent to a map[string]interface{}
-func (ev *WinLogEvent) CreateMap() map[string]interface{} {
-	toReturn := make(map[string]interface{})
-	toReturn["Xml"] = ev.Xml
-	toReturn["ProviderName"] = ev.ProviderName
-	toReturn["EventId"] = ev.EventId
-	toReturn["Qualifiers"] = ev.Qualifiers
-	toReturn["Level"] = ev.Level
-	toReturn["Task"] = ev.Task
-	toReturn["Opcode"] = ev.Opcode
-	toReturn["Created"] = ev.Created
-	toReturn["RecordId"] = ev.RecordId
-	toReturn["ProcessId"] = ev.ProcessId
-	toReturn["ThreadId"] = ev.ThreadId
-	toReturn["Channel"] = ev.Channel
-	toReturn["ComputerName"] = ev.ComputerName
-	toReturn["Version"] = ev.Version
-	toReturn["Msg"] = ev.Msg
-	toReturn["LevelText"] = ev.LevelText
-	toReturn["TaskText"] = ev.TaskText
-	toReturn["OpcodeText"] = ev.OpcodeText
-	toReturn["Keywords"] = ev.Keywords
-	toReturn["ChannelText"] = ev.ChannelText
-	toReturn["ProviderText"] = ev.ProviderText
-	toReturn["IdText"] = ev.IdText
-	toReturn["Bookmark"] = ev.Bookmark
-	toReturn["SubscribedChannel"] = ev.SubscribedChannel
-	toReturn["Bookmark"] = ev.Bookmark
-	return toReturn
+	if action == EvtSubscribeActionError {
+		// When the callback is called for an error, the error code is
+		// passed in the event handle parameter. See
+		// https://learn.microsoft.com/en-us/windows/win32/api/winevt/nc-winevt-evt_subscribe_callback.
+		err := syscall.Errno(uintptr(handle))
+		watcher.callback.PublishError(fmt.Errorf("Event log callback got error: %v", err))
+	} else {
+		watcher.callback.PublishEvent(EventHandle(handle), watcher.subscribedChannel)
+	}
+	return 0
 }
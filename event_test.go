@@ -61,13 +61,6 @@ type WinLogEventXml struct {
 	RenderingInfo RenderingInfoXml
 }
 
-func assertEqual(a, b interface{}, t *T) {
-	t.Helper()
-	if a != b {
-		t.Fatalf("%v != %v", a, b)
-	}
-}
-
 func TestXmlRenderMatchesOurs(t *T) {
 	testEvent, err := getTestEventHandle()
 	if err != nil {
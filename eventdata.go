@@ -0,0 +1,98 @@
+package winlog
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type eventDataXml struct {
+	Correlation struct {
+		ActivityID        string `xml:"ActivityID,attr"`
+		RelatedActivityID string `xml:"RelatedActivityID,attr"`
+	} `xml:"System>Correlation"`
+	Security struct {
+		UserID string `xml:"UserID,attr"`
+	} `xml:"System>Security"`
+	Data []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+	// Hex encoded binary data, which classic providers log after the
+	// insertion strings
+	Binary string `xml:"EventData>Binary"`
+	// <UserData> has a single element, named by the provider, holding the
+	// fields
+	UserData struct {
+		Inner struct {
+			Fields []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:",any"`
+	}
+}
+
+// ParseEventData extracts the <EventData> values from a rendered event XML
+// document, keyed by the Name attribute of each <Data> element. Classic
+// providers often omit the names, in which case the values are keyed by
+// position as "Data0", "Data1" and so on. Returns a nil map if the event has
+// no EventData.
+func ParseEventData(eventXml []byte) (map[string]string, error) {
+	parsed, err := parseEventXml(eventXml)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.eventData(), nil
+}
+
+// ParseUserData extracts the fields of an event's <UserData>, keyed by
+// element name, the way ParseEventData does for <EventData>. Returns a nil
+// map if the event has no UserData.
+func ParseUserData(eventXml []byte) (map[string]string, error) {
+	parsed, err := parseEventXml(eventXml)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.userData(), nil
+}
+
+// parseEventXml reads the EventData, UserData, user SID and correlation IDs
+// of an event.
+func parseEventXml(eventXml []byte) (*eventDataXml, error) {
+	var parsed eventDataXml
+	if err := xml.Unmarshal(eventXml, &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func (parsed *eventDataXml) eventData() map[string]string {
+	return parsed.eventDataNamed(nil)
+}
+
+// binary decodes the <Binary> element, returning nil if there isn't one.
+func (parsed *eventDataXml) binary() ([]byte, error) {
+	value := strings.TrimSpace(parsed.Binary)
+	if value == "" {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid <Binary> data: %v", err)
+	}
+	return data, nil
+}
+
+func (parsed *eventDataXml) userData() map[string]string {
+	fields := parsed.UserData.Inner.Fields
+	if len(fields) == 0 {
+		return nil
+	}
+	data := make(map[string]string, len(fields))
+	for _, field := range fields {
+		data[field.XMLName.Local] = field.Value
+	}
+	return data
+}
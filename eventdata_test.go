@@ -0,0 +1,77 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	. "testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden files for the fixtures in testdata/evtx and testdata/xml")
+
+// The fields the watcher reads from an event's XML, compared against the
+// golden files of the XML fixtures
+type xmlGoldenEvent struct {
+	EventData         map[string]string
+	UserData          map[string]string `json:",omitempty"`
+	UserSid           string
+	ActivityId        string
+	RelatedActivityId string
+}
+
+// Parse each testdata/xml/*.xml event as the watcher does and compare the
+// result to the .golden.json file alongside it. The fixtures are rendered
+// events from the Security, Sysmon and PowerShell logs, so parsing is
+// checked on every platform. Run with -update to write the golden files
+// after adding a fixture.
+func TestXmlFixtures(t *T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "xml", "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("No XML fixtures in testdata/xml")
+	}
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *T) {
+			eventXml, err := ioutil.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := parseEventXml(bytes.TrimSpace(eventXml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(xmlGoldenEvent{
+				EventData:         parsed.eventData(),
+				UserData:          parsed.userData(),
+				UserSid:           parsed.Security.UserID,
+				ActivityId:        parsed.Correlation.ActivityID,
+				RelatedActivityId: parsed.Correlation.RelatedActivityID,
+			}, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(fixture, ".xml") + ".golden.json"
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, append(got, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if os.IsNotExist(err) {
+				t.Fatalf("No golden file for %v, run the tests with -update", fixture)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+				t.Fatalf("Parsed event doesn't match %v:\n%s", golden, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package winlog
+
+import (
+	"time"
+)
+
+// EventLogAPI is the set of event log calls made by WinLogWatcher. The
+// watcher uses SystemEventLogAPI, which wraps wevtapi.dll, unless it's
+// created with NewWinLogWatcherWithAPI. Substituting a fake allows code built
+// on the watcher to be tested without a Windows event log.
+//
+// Handles are opaque to the watcher, which only passes them back to the API
+// and closes them with Close. Implementations must be safe for concurrent use.
+type EventLogAPI interface {
+	// Create the context used to render the System properties of events
+	CreateRenderContext() (SysRenderContext, error)
+	// Subscribe to events on `channel` matching the XPath `query`, starting
+	// after `bookmark` if `flags` is EvtSubscribeStartAfterBookmark. Events
+	// and errors are passed to `callback` along with the channel name.
+	Subscribe(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle, callback LogEventCallback) (ListenerHandle, error)
+	// Create a bookmark from its XML, or an empty bookmark if `xml` is empty
+	CreateBookmark(xml string) (BookmarkHandle, error)
+	UpdateBookmark(bookmark BookmarkHandle, event EventHandle) error
+	RenderBookmark(bookmark BookmarkHandle) (string, error)
+	// Render the System properties of an event, indexed by
+	// EVT_SYSTEM_PROPERTY_ID
+	RenderValues(renderContext SysRenderContext, event EventHandle) (RenderedValues, error)
+	RenderXML(event EventHandle) ([]byte, error)
+	// Render the System values and XML, keeping the buffers EvtRender
+	// returned
+	RenderRaw(renderContext SysRenderContext, event EventHandle) (*RenderedEvent, error)
+	OpenPublisherMetadata(providerName string) (PublisherHandle, error)
+	// Open a provider's metadata to format messages in a locale, such as
+	// "en-US", or the default locale if `locale` is empty
+	OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error)
+	FormatMessage(publisher PublisherHandle, event EventHandle, flags EVT_FORMAT_MESSAGE_FLAGS) (string, error)
+	ChannelInfo(channel string) (ChannelInfo, error)
+	ChannelConfig(channel string) (ChannelConfig, error)
+	// Read the parameters of the events defined by a provider
+	EventTemplates(providerName string) (EventTemplates, error)
+	// Read the names of the levels, tasks, opcodes and keywords declared
+	// by a provider
+	PublisherTables(providerName string) (*PublisherTables, error)
+	// Run a query on the channel or log file at `path`, or a structured
+	// query if `path` is empty, with EvtQuery flags such as
+	// EvtQueryFilePath and EvtQueryReverseDirection
+	Query(path, query string, flags uint32) (QueryHandle, error)
+	// Read up to len(events) of a query's events into `events`, waiting up
+	// to `timeout`, returning how many were read, or io.EOF once they've
+	// all been read. The events must be closed.
+	NextEvents(query QueryHandle, events []EventHandle, timeout time.Duration) (int, error)
+	Cancel(handle uint64) error
+	Close(handle uint64) error
+}
+
+// RenderedValues holds the rendered properties of an event. EvtVariant
+// implements it.
+type RenderedValues interface {
+	String(index uint32) (string, error)
+	Uint(index uint32) (uint64, error)
+	FileTime(index uint32) (time.Time, error)
+}
+
+// SystemEventLogAPI implements EventLogAPI with the functions in this
+// package. On platforms other than Windows every call fails with
+// ErrUnsupportedPlatform.
+var SystemEventLogAPI EventLogAPI = systemEventLogAPI{}
+
+type systemEventLogAPI struct{}
+
+func (systemEventLogAPI) CreateRenderContext() (SysRenderContext, error) {
+	return GetSystemRenderContext()
+}
+
+func (systemEventLogAPI) Subscribe(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle, callback LogEventCallback) (ListenerHandle, error) {
+	wrapper := &LogEventCallbackWrapper{callback: callback, subscribedChannel: channel}
+	if flags == EvtSubscribeStartAfterBookmark {
+		return CreateListenerFromBookmark(channel, query, wrapper, bookmark)
+	}
+	return CreateListener(channel, query, flags, wrapper)
+}
+
+func (systemEventLogAPI) CreateBookmark(xml string) (BookmarkHandle, error) {
+	if xml == "" {
+		return CreateBookmark()
+	}
+	return CreateBookmarkFromXml(xml)
+}
+
+func (systemEventLogAPI) UpdateBookmark(bookmark BookmarkHandle, event EventHandle) error {
+	return UpdateBookmark(bookmark, event)
+}
+
+func (systemEventLogAPI) RenderBookmark(bookmark BookmarkHandle) (string, error) {
+	return RenderBookmark(bookmark)
+}
+
+func (systemEventLogAPI) RenderValues(renderContext SysRenderContext, event EventHandle) (RenderedValues, error) {
+	values, err := RenderEventValues(renderContext, event)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (systemEventLogAPI) RenderXML(event EventHandle) ([]byte, error) {
+	return RenderEventXML(event)
+}
+
+func (systemEventLogAPI) RenderRaw(renderContext SysRenderContext, event EventHandle) (*RenderedEvent, error) {
+	return RenderRawEvent(renderContext, event)
+}
+
+func (systemEventLogAPI) OpenPublisherMetadata(providerName string) (PublisherHandle, error) {
+	return OpenPublisherMetadata(providerName)
+}
+
+func (systemEventLogAPI) OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error) {
+	return OpenLocalizedPublisherMetadata(providerName, locale)
+}
+
+func (systemEventLogAPI) FormatMessage(publisher PublisherHandle, event EventHandle, flags EVT_FORMAT_MESSAGE_FLAGS) (string, error) {
+	return FormatMessage(publisher, event, flags)
+}
+
+func (systemEventLogAPI) ChannelInfo(channel string) (ChannelInfo, error) {
+	return GetChannelInfo(channel)
+}
+
+func (systemEventLogAPI) ChannelConfig(channel string) (ChannelConfig, error) {
+	return GetChannelConfig(channel)
+}
+
+func (systemEventLogAPI) EventTemplates(providerName string) (EventTemplates, error) {
+	return GetEventTemplates(providerName)
+}
+
+func (systemEventLogAPI) PublisherTables(providerName string) (*PublisherTables, error) {
+	return GetPublisherTables(providerName)
+}
+
+func (systemEventLogAPI) Query(path, query string, flags uint32) (QueryHandle, error) {
+	return queryEventLog(0, path, query, flags)
+}
+
+func (systemEventLogAPI) NextEvents(query QueryHandle, events []EventHandle, timeout time.Duration) (int, error) {
+	return nextEvents(query, events, timeout)
+}
+
+func (systemEventLogAPI) SubscribePull(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle) (ListenerHandle, error) {
+	return createPullListener(0, channel, query, flags, bookmark)
+}
+
+func (systemEventLogAPI) WaitEvents(subscription ListenerHandle, timeout time.Duration) error {
+	return waitPullListener(subscription, timeout)
+}
+
+func (systemEventLogAPI) Cancel(handle uint64) error {
+	return CancelEventHandle(handle)
+}
+
+func (systemEventLogAPI) Close(handle uint64) error {
+	return CloseEventHandle(handle)
+}
@@ -0,0 +1,388 @@
+package winlog
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+	. "testing"
+	"time"
+)
+
+// fakeAPI is an in-memory EventLogAPI. Events are published by calling
+// emit, and bookmarks are rendered as the last RecordId they were updated
+// with, or the one they were created at.
+type fakeAPI struct {
+	mutex     sync.Mutex
+	next      uint64
+	callbacks map[uint64]LogEventCallback
+	channels  map[uint64]string
+	queries   map[uint64]string
+	flags     map[uint64]EVT_SUBSCRIBE_FLAGS
+	// RecordIds of the bookmarks subscriptions started after
+	starts    map[uint64]uint64
+	events    map[uint64]fakeValues
+	bookmarks map[uint64]uint64
+	closed    map[uint64]bool
+	// Locales of publisher handles opened with a locale. Messages can't be
+	// formatted in missingLocale.
+	locales map[uint64]string
+	// Events emitted on each channel, oldest first, which queries read
+	logged map[string][]fakeValues
+	// Flags of each query, and the events it has yet to return
+	queryFlags map[uint64]uint32
+	results    map[uint64][]fakeValues
+	// Number of handles requested by each call to NextEvents
+	nextSizes []int
+}
+
+const missingLocale = "xx-XX"
+
+type fakeValues map[uint32]interface{}
+
+func (v fakeValues) String(index uint32) (string, error) {
+	if s, ok := v[index].(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("No string at %v", index)
+}
+
+func (v fakeValues) Uint(index uint32) (uint64, error) {
+	if n, ok := v[index].(uint64); ok {
+		return n, nil
+	}
+	return 0, fmt.Errorf("No uint at %v", index)
+}
+
+func (v fakeValues) FileTime(index uint32) (time.Time, error) {
+	if t, ok := v[index].(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("No time at %v", index)
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		callbacks:  make(map[uint64]LogEventCallback),
+		channels:   make(map[uint64]string),
+		queries:    make(map[uint64]string),
+		flags:      make(map[uint64]EVT_SUBSCRIBE_FLAGS),
+		starts:     make(map[uint64]uint64),
+		events:     make(map[uint64]fakeValues),
+		bookmarks:  make(map[uint64]uint64),
+		closed:     make(map[uint64]bool),
+		locales:    make(map[uint64]string),
+		logged:     make(map[string][]fakeValues),
+		queryFlags: make(map[uint64]uint32),
+		results:    make(map[uint64][]fakeValues),
+	}
+}
+
+func (f *fakeAPI) handle() uint64 {
+	f.next++
+	return f.next
+}
+
+// emit publishes an event to every open subscription on the channel.
+func (f *fakeAPI) emit(channel string, values fakeValues) {
+	f.mutex.Lock()
+	handle := f.handle()
+	f.events[handle] = values
+	f.logged[channel] = append(f.logged[channel], values)
+	var callbacks []LogEventCallback
+	for subscription, callback := range f.callbacks {
+		if f.channels[subscription] == channel && !f.closed[subscription] {
+			callbacks = append(callbacks, callback)
+		}
+	}
+	f.mutex.Unlock()
+	for _, callback := range callbacks {
+		callback.PublishEvent(EventHandle(handle), channel)
+	}
+}
+
+// emitTo publishes an event to one subscription, as when a query is split
+// across several subscriptions which each match different events.
+func (f *fakeAPI) emitTo(subscription ListenerHandle, channel string, values fakeValues) {
+	f.mutex.Lock()
+	handle := f.handle()
+	f.events[handle] = values
+	callback := f.callbacks[uint64(subscription)]
+	f.mutex.Unlock()
+	callback.PublishEvent(EventHandle(handle), channel)
+}
+
+// subscriptions returns the open subscriptions to a channel, oldest first.
+func (f *fakeAPI) subscriptions(channel string) []ListenerHandle {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var subscriptions []ListenerHandle
+	for handle := uint64(1); handle <= f.next; handle++ {
+		if _, ok := f.callbacks[handle]; ok && f.channels[handle] == channel && !f.closed[handle] {
+			subscriptions = append(subscriptions, ListenerHandle(handle))
+		}
+	}
+	return subscriptions
+}
+
+func (f *fakeAPI) CreateRenderContext() (SysRenderContext, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return SysRenderContext(f.handle()), nil
+}
+
+func (f *fakeAPI) Subscribe(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle, callback LogEventCallback) (ListenerHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	handle := f.handle()
+	f.callbacks[handle] = callback
+	f.channels[handle] = channel
+	f.queries[handle] = query
+	f.flags[handle] = flags
+	f.starts[handle] = f.bookmarks[uint64(bookmark)]
+	return ListenerHandle(handle), nil
+}
+
+// Query reads the events emitted on the channel `path`. XPath queries are
+// ignored.
+func (f *fakeAPI) Query(path, query string, flags uint32) (QueryHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	handle := f.handle()
+	f.queries[handle] = query
+	f.queryFlags[handle] = flags
+	results := append([]fakeValues(nil), f.logged[path]...)
+	if flags&EvtQueryReverseDirection != 0 {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+	f.results[handle] = results
+	return QueryHandle(handle), nil
+}
+
+func (f *fakeAPI) NextEvents(query QueryHandle, events []EventHandle, timeout time.Duration) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.nextSizes = append(f.nextSizes, len(events))
+	if f.closed[uint64(query)] {
+		return 0, fmt.Errorf("Invalid query handle %v", query)
+	}
+	results := f.results[uint64(query)]
+	if len(results) == 0 {
+		return 0, io.EOF
+	}
+	n := len(events)
+	if len(results) < n {
+		n = len(results)
+	}
+	for i, values := range results[:n] {
+		handle := f.handle()
+		f.events[handle] = values
+		events[i] = EventHandle(handle)
+	}
+	f.results[uint64(query)] = results[n:]
+	return n, nil
+}
+
+func (f *fakeAPI) CreateBookmark(xml string) (BookmarkHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	handle := f.handle()
+	if recordId, err := strconv.ParseUint(xml, 10, 64); err == nil {
+		f.bookmarks[handle] = recordId
+	} else {
+		f.bookmarks[handle], _ = bookmarkPosition(xml)
+	}
+	return BookmarkHandle(handle), nil
+}
+
+func (f *fakeAPI) UpdateBookmark(bookmark BookmarkHandle, event EventHandle) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.bookmarks[uint64(bookmark)], _ = f.events[uint64(event)].Uint(EvtSystemEventRecordId)
+	return nil
+}
+
+func (f *fakeAPI) RenderBookmark(bookmark BookmarkHandle) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return fmt.Sprint(f.bookmarks[uint64(bookmark)]), nil
+}
+
+func (f *fakeAPI) RenderValues(renderContext SysRenderContext, event EventHandle) (RenderedValues, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.events[uint64(event)], nil
+}
+
+func (f *fakeAPI) RenderXML(event EventHandle) ([]byte, error) {
+	return []byte(`<Event><EventData><Data Name="User">alice</Data></EventData></Event>`), nil
+}
+
+func (f *fakeAPI) RenderRaw(renderContext SysRenderContext, event EventHandle) (*RenderedEvent, error) {
+	xml, _ := f.RenderXML(event)
+	return &RenderedEvent{XML: encodeUTF16(string(xml))}, nil
+}
+
+func (f *fakeAPI) OpenPublisherMetadata(providerName string) (PublisherHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return PublisherHandle(f.handle()), nil
+}
+
+func (f *fakeAPI) OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	handle := f.handle()
+	if locale != "" {
+		f.locales[handle] = locale
+	}
+	return PublisherHandle(handle), nil
+}
+
+func (f *fakeAPI) FormatMessage(publisher PublisherHandle, event EventHandle, flags EVT_FORMAT_MESSAGE_FLAGS) (string, error) {
+	f.mutex.Lock()
+	locale := f.locales[uint64(publisher)]
+	f.mutex.Unlock()
+	switch locale {
+	case "":
+		return fmt.Sprintf("message %v", flags), nil
+	case missingLocale:
+		return "", fmt.Errorf("The locale specific resource for the desired message is not present")
+	}
+	return fmt.Sprintf("message %v in %v", flags, locale), nil
+}
+
+// ChannelInfo reports the RecordId of the last event logged on the channel
+// as its newest.
+func (f *fakeAPI) ChannelInfo(channel string) (ChannelInfo, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var info ChannelInfo
+	if logged := f.logged[channel]; len(logged) > 0 {
+		info.NewestRecordId, _ = logged[len(logged)-1].Uint(EvtSystemEventRecordId)
+	}
+	return info, nil
+}
+
+func (f *fakeAPI) ChannelConfig(channel string) (ChannelConfig, error) {
+	return ChannelConfig{}, nil
+}
+
+func (f *fakeAPI) EventTemplates(providerName string) (EventTemplates, error) {
+	return nil, nil
+}
+
+func (f *fakeAPI) PublisherTables(providerName string) (*PublisherTables, error) {
+	return nil, nil
+}
+
+func (f *fakeAPI) Cancel(handle uint64) error {
+	return nil
+}
+
+func (f *fakeAPI) Close(handle uint64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed[handle] = true
+	return nil
+}
+
+func TestWatcherWithFakeAPI(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.RenderMessage = true
+	watcher.RetainRawRender = true
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Unix(1600000000, 0)
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventID:       uint64(7),
+		EvtSystemEventRecordId: uint64(42),
+		EvtSystemTimeCreated:   created,
+		EvtSystemKeywords:      uint64(0x8010000000000000),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.ProviderName, "Provider", t)
+		assertEqual(ev.EventId, uint64(7), t)
+		assertEqual(ev.Created, created, t)
+		assertEqual(ev.KeywordsMask, uint64(0x8010000000000000), t)
+		assertEqual(ev.Outcome, OutcomeFailure, t)
+		assertEqual(ev.Msg, fmt.Sprintf("message %v", EvtFormatMessageEvent), t)
+		assertEqual(ev.EventData["User"], "alice", t)
+		assertEqual(ev.Bookmark, "42", t)
+		assertEqual(ev.SubscribedChannel, "Application", t)
+		assertEqual(string(ev.Raw.DecodeXML()), string(ev.Xml), t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published")
+	}
+
+	// Stats are updated after the event is received
+	stats := watcher.Stats()["Application"]
+	for i := 0; i < 100 && stats.Delivered == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		stats = watcher.Stats()["Application"]
+	}
+	assertEqual(stats.Delivered, uint64(1), t)
+	assertEqual(stats.LastRecordId, uint64(42), t)
+
+	if err := watcher.RemoveSubscription("Application"); err != nil {
+		t.Fatal(err)
+	}
+	watcher.Shutdown()
+	for handle := range api.callbacks {
+		if !api.closed[handle] {
+			t.Fatalf("Subscription %v wasn't closed", handle)
+		}
+	}
+}
+
+func TestUnsupportedPlatform(t *T) {
+	watcher, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Shutdown()
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if _, err := NewWinLogWatcher(); err != ErrUnsupportedPlatform {
+		t.Fatalf("Expected ErrUnsupportedPlatform, got %v", err)
+	}
+}
+
+func TestSequenceNumbers(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.Filter, _ = CompileFilter("EventId != 2")
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for id := uint64(1); id <= 3; id++ {
+			api.emit("Application", fakeValues{EvtSystemEventID: id, EvtSystemEventRecordId: id})
+		}
+		watcher.skipSequence()
+		api.emit("Application", fakeValues{EvtSystemEventID: uint64(4), EvtSystemEventRecordId: uint64(4)})
+	}()
+	for _, want := range []uint64{1, 2, 4} {
+		ev := <-watcher.Event()
+		assertEqual(ev.Sequence, want, t)
+	}
+}
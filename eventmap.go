@@ -0,0 +1,217 @@
+package winlog
+
+import (
+	"strings"
+	"unicode"
+)
+
+// KeyCase selects the case of the keys of CreateMapWith.
+type KeyCase int
+
+const (
+	// Keys as the WinLogEvent fields and EventData names are written, such
+	// as "EventId" and "TargetUserName"
+	KeyCaseOriginal KeyCase = iota
+	// Such as "eventId" and "targetUserName"
+	KeyCaseCamel
+	// Such as "event_id" and "target_user_name"
+	KeyCaseSnake
+)
+
+// MapOptions controls the keys of CreateMapWith.
+type MapOptions struct {
+	// Put the EventData, UserData, Extracted and Enriched values at the
+	// top level, keyed as "EventData.TargetUserName", instead of in nested
+	// maps
+	Flatten bool
+	// Separates the parts of flattened keys. "." if empty.
+	Separator string
+	// Case of every key, including the EventData and UserData names
+	KeyCase KeyCase
+	// How Created, ReceivedAt and CollectedTime are written. They're
+	// time.Time values if nil.
+	Timestamps *Timestamps
+}
+
+// CreateMapWith converts the WinLogEvent to a map the way CreateMap does,
+// with the keys shaped for a sink by the options.
+func (ev *WinLogEvent) CreateMapWith(options MapOptions) map[string]interface{} {
+	m := ev.CreateMap()
+	if options.Timestamps != nil {
+		options.Timestamps.formatTimes(m, ev)
+	}
+	if !options.Flatten && options.KeyCase == KeyCaseOriginal {
+		return m
+	}
+	separator := options.Separator
+	if separator == "" {
+		separator = "."
+	}
+	toReturn := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		data, ok := value.(map[string]string)
+		if !ok {
+			toReturn[options.KeyCase.apply(key)] = value
+			continue
+		}
+		if options.Flatten {
+			for name, v := range data {
+				toReturn[options.KeyCase.apply(key)+separator+options.KeyCase.apply(name)] = v
+			}
+			continue
+		}
+		nested := make(map[string]string, len(data))
+		for name, v := range data {
+			nested[options.KeyCase.apply(name)] = v
+		}
+		toReturn[options.KeyCase.apply(key)] = nested
+	}
+	return toReturn
+}
+
+func (c KeyCase) apply(key string) string {
+	switch c {
+	case KeyCaseCamel:
+		return camelCase(key)
+	case KeyCaseSnake:
+		return snakeCase(key)
+	}
+	return key
+}
+
+// camelCase lowers the first word of a PascalCase key, including a leading
+// acronym, so "EventId" becomes "eventId" and "IPAddress" "ipAddress".
+func camelCase(key string) string {
+	runes := []rune(key)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// Keep the capital starting the next word after an acronym
+	if upper > 1 && upper < len(runes) && unicode.IsLower(runes[upper]) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// snakeCase splits a PascalCase or camelCase key into lower case words
+// joined by underscores, keeping acronyms together, so "EventId" becomes
+// "event_id" and "IPAddress" "ip_address".
+func snakeCase(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// CreateMap converts the WinLogEvent to a map[string]interface{}. EventData,
+// UserData, Extracted and Enriched are included as nested map[string]string
+// values when the event has them, and the map is stamped with its
+// SchemaVersion.
+func (ev *WinLogEvent) CreateMap() map[string]interface{} {
+	toReturn := make(map[string]interface{})
+	toReturn[SchemaVersionKey] = SchemaVersion
+	toReturn["Xml"] = ev.Xml
+	toReturn["ProviderName"] = ev.ProviderName
+	toReturn["EventId"] = ev.EventId
+	toReturn["Qualifiers"] = ev.Qualifiers
+	toReturn["Level"] = ev.Level
+	toReturn["Task"] = ev.Task
+	toReturn["Opcode"] = ev.Opcode
+	toReturn["Created"] = ev.Created
+	if !ev.ReceivedAt.IsZero() {
+		toReturn["ReceivedAt"] = ev.ReceivedAt
+	}
+	toReturn["RecordId"] = ev.RecordId
+	toReturn["ProcessId"] = ev.ProcessId
+	toReturn["ThreadId"] = ev.ThreadId
+	toReturn["Channel"] = ev.Channel
+	toReturn["ComputerName"] = ev.ComputerName
+	toReturn["Version"] = ev.Version
+	toReturn["Msg"] = ev.Msg
+	toReturn["LevelText"] = ev.LevelText
+	toReturn["TaskText"] = ev.TaskText
+	toReturn["OpcodeText"] = ev.OpcodeText
+	toReturn["Keywords"] = ev.Keywords
+	toReturn["ChannelText"] = ev.ChannelText
+	toReturn["ProviderText"] = ev.ProviderText
+	toReturn["IdText"] = ev.IdText
+	toReturn["Bookmark"] = ev.Bookmark
+	toReturn["SubscribedChannel"] = ev.SubscribedChannel
+	if ev.LocalizedMsg != nil {
+		toReturn["LocalizedMsg"] = ev.LocalizedMsg
+	}
+	if ev.LocalizedLevelText != nil {
+		toReturn["LocalizedLevelText"] = ev.LocalizedLevelText
+	}
+	if ev.ActivityId != "" {
+		toReturn["ActivityId"] = ev.ActivityId
+	}
+	if ev.RelatedActivityId != "" {
+		toReturn["RelatedActivityId"] = ev.RelatedActivityId
+	}
+	if ev.UserSid != "" {
+		toReturn["UserSid"] = ev.UserSid
+	}
+	if ev.EventData != nil {
+		toReturn["EventData"] = ev.EventData
+	}
+	if ev.UserData != nil {
+		toReturn["UserData"] = ev.UserData
+	}
+	if ev.Extracted != nil {
+		toReturn["Extracted"] = ev.Extracted
+	}
+	if ev.Enriched != nil {
+		toReturn["Enriched"] = ev.Enriched
+	}
+	if ev.KeywordsMask != 0 {
+		toReturn["KeywordsMask"] = ev.KeywordsMask
+	}
+	if ev.Outcome != "" {
+		toReturn["Outcome"] = ev.Outcome
+	}
+	if ev.Severity != SeverityUnknown {
+		toReturn["Severity"] = ev.Severity.String()
+	}
+	if ev.Forwarded {
+		toReturn["Forwarded"] = true
+		toReturn["SourceComputer"] = ev.SourceComputer
+		toReturn["SourceChannel"] = ev.SourceChannel
+		toReturn["Collector"] = ev.Collector
+		toReturn["CollectedTime"] = ev.CollectedTime
+	}
+	if ev.Host != nil {
+		toReturn["Host"] = ev.Host
+	}
+	if ev.Remote != nil {
+		toReturn["Remote"] = ev.Remote
+	}
+	if ev.SampleRate > 1 {
+		toReturn["SampleRate"] = ev.SampleRate
+	}
+	if ev.SuppressedCount > 0 {
+		toReturn["SuppressedCount"] = ev.SuppressedCount
+	}
+	if ev.Sequence > 0 {
+		toReturn["Sequence"] = ev.Sequence
+	}
+	if ev.ChainHash != "" {
+		toReturn["PrevChainHash"] = ev.PrevChainHash
+		toReturn["ChainHash"] = ev.ChainHash
+	}
+	toReturn["Bookmark"] = ev.Bookmark
+	return toReturn
+}
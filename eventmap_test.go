@@ -0,0 +1,96 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestParseUserData(t *T) {
+	data, err := ParseUserData([]byte(`<Event><System><Security UserID='S-1-5-18'/></System><UserData><RuleAndFileData xmlns='http://schemas.microsoft.com/schemas/event/Microsoft.Windows/1.0.0.0'><PolicyName>EXE</PolicyName><RuleId>{1}</RuleId></RuleAndFileData></UserData></Event>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(data), 2, t)
+	assertEqual(data["PolicyName"], "EXE", t)
+	assertEqual(data["RuleId"], "{1}", t)
+
+	data, err = ParseUserData([]byte(`<Event><EventData><Data Name='a'>1</Data></EventData></Event>`))
+	assertEqual(err, nil, t)
+	assertEqual(data == nil, true, t)
+	if _, err := ParseUserData([]byte(`<Event><UserData>`)); err == nil {
+		t.Fatal("Expected an error for truncated XML")
+	}
+}
+
+func TestCreateMapData(t *T) {
+	ev := &WinLogEvent{
+		EventId:    4624,
+		ProcessId:  4,
+		ThreadId:   8,
+		ActivityId: "{6d7b1f3a-0c4e-4b8a-9f21-3c5d7e9a1b2c}",
+		UserSid:    "S-1-5-18",
+		EventData:  map[string]string{"TargetUserName": "alice"},
+		UserData:   map[string]string{"IPAddress": "10.0.0.1"},
+	}
+	m := ev.CreateMap()
+	assertEqual(m["ProcessId"], uint64(4), t)
+	assertEqual(m["ThreadId"], uint64(8), t)
+	assertEqual(m["ActivityId"], ev.ActivityId, t)
+	assertEqual(m["UserSid"], "S-1-5-18", t)
+	assertEqual(m["EventData"].(map[string]string)["TargetUserName"], "alice", t)
+	assertEqual(m["UserData"].(map[string]string)["IPAddress"], "10.0.0.1", t)
+
+	empty := (&WinLogEvent{}).CreateMap()
+	for _, key := range []string{"UserSid", "EventData", "UserData"} {
+		if _, ok := empty[key]; ok {
+			t.Fatalf("Unexpected key %v for an event without it", key)
+		}
+	}
+}
+
+func TestCreateMapWith(t *T) {
+	ev := &WinLogEvent{
+		EventId:   4624,
+		UserSid:   "S-1-5-18",
+		EventData: map[string]string{"TargetUserName": "alice"},
+		UserData:  map[string]string{"IPAddress": "10.0.0.1"},
+	}
+
+	m := ev.CreateMapWith(MapOptions{Flatten: true})
+	assertEqual(m["EventData.TargetUserName"], "alice", t)
+	assertEqual(m["UserData.IPAddress"], "10.0.0.1", t)
+	_, nested := m["EventData"]
+	assertEqual(nested, false, t)
+
+	m = ev.CreateMapWith(MapOptions{Flatten: true, Separator: "_", KeyCase: KeyCaseSnake})
+	assertEqual(m["event_id"], uint64(4624), t)
+	assertEqual(m["user_sid"], "S-1-5-18", t)
+	assertEqual(m["event_data_target_user_name"], "alice", t)
+	assertEqual(m["user_data_ip_address"], "10.0.0.1", t)
+
+	m = ev.CreateMapWith(MapOptions{KeyCase: KeyCaseCamel})
+	assertEqual(m["eventId"], uint64(4624), t)
+	assertEqual(m["eventData"].(map[string]string)["targetUserName"], "alice", t)
+	assertEqual(m["userData"].(map[string]string)["ipAddress"], "10.0.0.1", t)
+
+	m = ev.CreateMapWith(MapOptions{})
+	assertEqual(m["EventId"], uint64(4624), t)
+}
+
+func TestKeyCase(t *T) {
+	cases := []struct {
+		key, camel, snake string
+	}{
+		{"EventId", "eventId", "event_id"},
+		{"IPAddress", "ipAddress", "ip_address"},
+		{"ProcessID", "processID", "process_id"},
+		{"Xml", "xml", "xml"},
+		{"SID", "sid", "sid"},
+		{"Data0", "data0", "data0"},
+		{"param1", "param1", "param1"},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		assertEqual(camelCase(c.key), c.camel, t)
+		assertEqual(snakeCase(c.key), c.snake, t)
+	}
+}
@@ -1,5 +1,3 @@
-// +build windows
-
 package winlog
 
 import (
@@ -58,8 +56,14 @@ func NewEvtVariant(buffer []byte) EvtVariant {
 	return EvtVariant(buffer)
 }
 
-func (e EvtVariant) elemAt(index uint32) *evtVariant {
-	return (*evtVariant)(unsafe.Pointer(uintptr(16*index) + uintptr(unsafe.Pointer(&e[0]))))
+// Size of an EVT_VARIANT structure
+const evtVariantSize = 16
+
+func (e EvtVariant) elemAt(index uint32) (*evtVariant, error) {
+	if (uint64(index)+1)*evtVariantSize > uint64(len(e)) {
+		return nil, fmt.Errorf("EvtVariant index %v out of range, buffer has %v elements", index, len(e)/evtVariantSize)
+	}
+	return (*evtVariant)(unsafe.Pointer(&e[evtVariantSize*index])), nil
 }
 
 func UTF16ToString(s []uint16) string {
@@ -75,19 +79,40 @@ func UTF16ToString(s []uint16) string {
 /* Return the string value of the variable at `index`. If the
    variable isn't a string, an error is returned */
 func (e EvtVariant) String(index uint32) (string, error) {
-	elem := e.elemAt(index)
+	elem, err := e.elemAt(index)
+	if err != nil {
+		return "", err
+	}
 	if elem.Type != EvtVarTypeString {
 		return "", fmt.Errorf("EvtVariant at index %v was not of type string, type was %v", index, elem.Type)
 	}
-	wideString := (*[1 << 29]uint16)(unsafe.Pointer(uintptr(elem.Data)))
-	str := UTF16ToString(wideString[0 : elem.Count+1])
-	return str, nil
+	// Data points to the null-terminated string, which EvtRender places
+	// later in the same buffer. Don't follow it anywhere else.
+	start := uintptr(unsafe.Pointer(&e[0]))
+	ptr := uintptr(elem.Data)
+	if ptr < start || ptr >= start+uintptr(len(e)) {
+		return "", fmt.Errorf("EvtVariant at index %v points outside the buffer", index)
+	}
+	data := e[ptr-start:]
+	wideString := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := uint16(data[i]) | uint16(data[i+1])<<8
+		if c == 0 {
+			break
+		}
+		wideString = append(wideString, c)
+	}
+	return UTF16ToString(wideString), nil
 }
 
 /* Return the unsigned integer value at `index`. If the variable
-   isn't a Byte, UInt16, UInt32 or UInt64 an error is returned. */
+   isn't a Byte, UInt16, UInt32, UInt64, HexInt32 or HexInt64 an error
+   is returned. */
 func (e EvtVariant) Uint(index uint32) (uint64, error) {
-	elem := e.elemAt(index)
+	elem, err := e.elemAt(index)
+	if err != nil {
+		return 0, err
+	}
 	switch elem.Type {
 	case EvtVarTypeByte:
 		return uint64(byte(elem.Data)), nil
@@ -97,6 +122,10 @@ func (e EvtVariant) Uint(index uint32) (uint64, error) {
 		return uint64(uint32(elem.Data)), nil
 	case EvtVarTypeUInt64:
 		return uint64(elem.Data), nil
+	case EvtVarTypeHexInt32:
+		return uint64(uint32(elem.Data)), nil
+	case EvtVarTypeHexInt64:
+		return uint64(elem.Data), nil
 	default:
 		return 0, fmt.Errorf("EvtVariant at index %v was not an unsigned integer, type is %v", index, elem.Type)
 	}
@@ -105,7 +134,10 @@ func (e EvtVariant) Uint(index uint32) (uint64, error) {
 /* Return the integer value at `index`. If the variable
    isn't a SByte, Int16, Int32 or Int64 an error is returned. */
 func (e EvtVariant) Int(index uint32) (int64, error) {
-	elem := e.elemAt(index)
+	elem, err := e.elemAt(index)
+	if err != nil {
+		return 0, err
+	}
 	switch elem.Type {
 	case EvtVarTypeSByte:
 		return int64(byte(elem.Data)), nil
@@ -123,7 +155,10 @@ func (e EvtVariant) Int(index uint32) (int64, error) {
 /* Return the FileTime at `index`, converted to Time.time. If the
    variable isn't a FileTime an error is returned */
 func (e EvtVariant) FileTime(index uint32) (time.Time, error) {
-	elem := e.elemAt(index)
+	elem, err := e.elemAt(index)
+	if err != nil {
+		return time.Now(), err
+	}
 	if elem.Type != EvtVarTypeFileTime {
 		return time.Now(), fmt.Errorf("EvtVariant at index %v was not of type FileTime, type was %v", index, elem.Type)
 	}
@@ -134,7 +169,8 @@ func (e EvtVariant) FileTime(index uint32) (time.Time, error) {
 }
 
 /* Return whether the variable was actually set, or whether it
-   has null type */
+   has null type. Variables past the end of the buffer are null. */
 func (e EvtVariant) IsNull(index uint32) bool {
-	return e.elemAt(index).Type == EvtVarTypeNull
+	elem, err := e.elemAt(index)
+	return err != nil || elem.Type == EvtVarTypeNull
 }
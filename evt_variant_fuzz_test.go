@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package winlog
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func FuzzEvtVariant(f *testing.F) {
+	valid := variantBuffer("Provider", uint32(4624), nil, int64(-5), time.Unix(1600000000, 0))
+	// The seed's string pointer is meaningless once copied, so the fuzz
+	// function points the first variant at `offset` within its own buffer.
+	f.Add([]byte(valid), uint32(0), uint16(80))
+	f.Add([]byte(valid), uint32(4), uint16(0))
+	f.Add([]byte{}, uint32(0), uint16(0))
+	f.Add(make([]byte, 15), uint32(0), uint16(0))
+	f.Add(make([]byte, 17), uint32(1), uint16(16))
+	f.Fuzz(func(t *testing.T, data []byte, index uint32, offset uint16) {
+		v := NewEvtVariant(append([]byte(nil), data...))
+		if len(v) >= evtVariantSize {
+			binary.LittleEndian.PutUint64(v, uint64(uintptr(unsafe.Pointer(&v[0])))+uint64(offset))
+		}
+		for _, i := range []uint32{0, index, index + 1} {
+			v.String(i)
+			v.Uint(i)
+			v.Int(i)
+			v.FileTime(i)
+			v.IsNull(i)
+		}
+	})
+}
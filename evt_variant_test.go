@@ -0,0 +1,83 @@
+package winlog
+
+import (
+	"encoding/binary"
+	. "testing"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// variantBuffer builds an EvtRender values buffer. Strings are appended
+// after the array of variants, which point to them.
+func variantBuffer(values ...interface{}) EvtVariant {
+	buf := make([]byte, evtVariantSize*len(values), evtVariantSize*len(values)+256)
+	var stringOffsets = make(map[int]int)
+	for i, value := range values {
+		elem := buf[i*evtVariantSize:]
+		switch v := value.(type) {
+		case string:
+			stringOffsets[i] = len(buf)
+			for _, c := range utf16.Encode([]rune(v + "\x00")) {
+				buf = append(buf, byte(c), byte(c>>8))
+			}
+			binary.LittleEndian.PutUint32(elem[8:], uint32(len(v)))
+			binary.LittleEndian.PutUint32(elem[12:], EvtVarTypeString)
+		case uint32:
+			binary.LittleEndian.PutUint64(elem, uint64(v))
+			binary.LittleEndian.PutUint32(elem[12:], EvtVarTypeUInt32)
+		case int64:
+			binary.LittleEndian.PutUint64(elem, uint64(v))
+			binary.LittleEndian.PutUint32(elem[12:], EvtVarTypeInt64)
+		case time.Time:
+			binary.LittleEndian.PutUint64(elem, uint64(v.UnixNano()/100+116444736000000000))
+			binary.LittleEndian.PutUint32(elem[12:], EvtVarTypeFileTime)
+		}
+	}
+	// Pointers are only known once the buffer has stopped growing
+	for i, offset := range stringOffsets {
+		binary.LittleEndian.PutUint64(buf[i*evtVariantSize:], uint64(uintptr(unsafe.Pointer(&buf[offset]))))
+	}
+	return NewEvtVariant(buf)
+}
+
+func TestEvtVariant(t *T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 100, time.UTC)
+	v := variantBuffer("Provider", uint32(4624), nil, int64(-5), created, "")
+
+	s, err := v.String(0)
+	assertEqual(err, nil, t)
+	assertEqual(s, "Provider", t)
+	n, err := v.Uint(1)
+	assertEqual(err, nil, t)
+	assertEqual(n, uint64(4624), t)
+	assertEqual(v.IsNull(2), true, t)
+	i, err := v.Int(3)
+	assertEqual(err, nil, t)
+	assertEqual(i, int64(-5), t)
+	ft, err := v.FileTime(4)
+	assertEqual(err, nil, t)
+	assertEqual(ft.Equal(created), true, t)
+	s, err = v.String(5)
+	assertEqual(err, nil, t)
+	assertEqual(s, "", t)
+
+	if _, err := v.Uint(0); err == nil {
+		t.Fatal("No error reading a string as an integer")
+	}
+	if _, err := v.String(7); err == nil {
+		t.Fatal("No error reading past the end of the buffer")
+	}
+	assertEqual(v.IsNull(7), true, t)
+}
+
+func TestEvtVariantStringOutsideBuffer(t *T) {
+	v := variantBuffer("Provider")
+	binary.LittleEndian.PutUint64(v, 16)
+	if _, err := v.String(0); err == nil {
+		t.Fatal("Followed a pointer outside the buffer")
+	}
+	if _, err := NewEvtVariant(nil).String(0); err == nil {
+		t.Fatal("No error reading an empty buffer")
+	}
+}
@@ -0,0 +1,229 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	. "testing"
+	"time"
+)
+
+// The fields of an event compared against the golden files. Localized text
+// depends on the locale and the providers installed on the host running the
+// tests, so it's rendered but not compared.
+type goldenEvent struct {
+	ProviderName string
+	EventId      uint64
+	Qualifiers   uint64
+	Level        uint64
+	Task         uint64
+	Opcode       uint64
+	Created      time.Time
+	RecordId     uint64
+	ProcessId    uint64
+	ThreadId     uint64
+	Channel      string
+	ComputerName string
+	Version      uint64
+	EventData    map[string]string
+}
+
+// Render every event in each testdata/evtx/*.evtx file through the watcher's
+// conversion and compare them to the .golden.json file alongside it. Run with
+// -update to write the golden files after adding a fixture.
+func TestEvtxFixtures(t *T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "evtx", "*.evtx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Skip("No .evtx fixtures in testdata/evtx")
+	}
+	watcher, err := NewWinLogWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.RenderKeywords = true
+	watcher.RenderMessage = true
+	watcher.RenderLevel = true
+	watcher.RenderTask = true
+	watcher.RenderProvider = true
+	watcher.RenderOpcode = true
+	watcher.RenderChannel = true
+	watcher.RenderId = true
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *T) {
+			events := renderFixture(t, watcher, fixture)
+			got, err := json.MarshalIndent(events, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(fixture, ".evtx") + ".golden.json"
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, append(got, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if os.IsNotExist(err) {
+				t.Fatalf("No golden file for %v, run the tests with -update", fixture)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+				t.Fatalf("Rendered events don't match %v:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func renderFixture(t *T, watcher *WinLogWatcher, path string) []goldenEvent {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := QueryFile(absPath, "*")
+	if err != nil {
+		t.Fatalf("Failed to query %v: %v", path, err)
+	}
+	defer result.Close()
+
+	var events []goldenEvent
+	for {
+		handle, err := result.Next(time.Second)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		ev, err := watcher.convertEvent(handle, "")
+		CloseEventHandle(uint64(handle))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.RenderedFieldsErr != nil || ev.XmlErr != nil || ev.EventDataErr != nil {
+			t.Fatalf("Failed to render record %v: %v %v %v", ev.RecordId, ev.RenderedFieldsErr, ev.XmlErr, ev.EventDataErr)
+		}
+		events = append(events, goldenEvent{
+			ProviderName: ev.ProviderName,
+			EventId:      ev.EventId,
+			Qualifiers:   ev.Qualifiers,
+			Level:        ev.Level,
+			Task:         ev.Task,
+			Opcode:       ev.Opcode,
+			Created:      ev.Created.UTC(),
+			RecordId:     ev.RecordId,
+			ProcessId:    ev.ProcessId,
+			ThreadId:     ev.ThreadId,
+			Channel:      ev.Channel,
+			ComputerName: ev.ComputerName,
+			Version:      ev.Version,
+			EventData:    ev.EventData,
+		})
+	}
+	if len(events) == 0 {
+		t.Fatalf("No events in %v", path)
+	}
+	return events
+}
+
+// Reverse queries read a file's records newest first
+func TestQueryFileReverse(t *T) {
+	fixtures, _ := filepath.Glob(filepath.Join("testdata", "evtx", "*.evtx"))
+	if len(fixtures) == 0 {
+		t.Fatal("No .evtx fixtures in testdata/evtx")
+	}
+	watcher, err := NewWinLogWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *T) {
+			absPath, err := filepath.Abs(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forward := renderFixture(t, watcher, fixture)
+			if len(forward) < 2 {
+				t.Fatalf("Expected at least 2 records in %v, got %v", fixture, len(forward))
+			}
+
+			result, err := QueryFileReverse(absPath, "*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Close()
+			for i := len(forward) - 1; i >= 0; i-- {
+				handle, err := result.Next(time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ev, err := watcher.convertEvent(handle, "")
+				CloseEventHandle(uint64(handle))
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertEqual(ev.RecordId, forward[i].RecordId, t)
+			}
+			if _, err := result.Next(time.Second); err != io.EOF {
+				t.Fatalf("Expected io.EOF after the oldest record, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCountEvents(t *T) {
+	fixtures, _ := filepath.Glob(filepath.Join("testdata", "evtx", "*.evtx"))
+	if len(fixtures) == 0 {
+		t.Fatal("No .evtx fixtures in testdata/evtx")
+	}
+	watcher, err := NewWinLogWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *T) {
+			absPath, err := filepath.Abs(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total := uint64(len(renderFixture(t, watcher, fixture)))
+			if total < 2 {
+				t.Fatalf("Expected at least 2 records in %v, got %v", fixture, total)
+			}
+
+			count, more, err := CountEvents(absPath, "*", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(count, total, t)
+			assertEqual(more, false, t)
+
+			count, more, err = CountEvents(absPath, "*", total)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(count, total, t)
+			assertEqual(more, false, t)
+
+			count, more, err = CountEvents(absPath, "*", total-1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(count, total-1, t)
+			assertEqual(more, true, t)
+		})
+	}
+}
@@ -11,7 +11,7 @@ import (
 )
 
 func main() {
-	watcher, err := winlog.NewWinLogWatcher()
+	watcher, err := winlog.NewWinLogWatcherWithOptions(winlog.WatcherOptions{Render: winlog.RenderAllFields})
 	if err != nil {
 		fmt.Printf("Couldn't create watcher: %v\n", err)
 		return
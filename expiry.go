@@ -0,0 +1,46 @@
+package winlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ExpiryPolicy discards events which are too old to be worth delivering,
+// for monitoring where fresh events matter more than complete ones. Events
+// are checked before they're sent to the Event channel, and discarded if
+// their TTL passes while waiting for the consumer, unless the watcher has a
+// HashChain, which they've already been linked into by then. Discarded
+// events are counted in the Expired stat and use a Sequence number, like
+// dropped events.
+type ExpiryPolicy struct {
+	// How old an event may be when it's delivered
+	TTL time.Duration
+	// Measure age from when the watcher received the event rather than its
+	// TimeCreated, so old events read from a bookmark, or from computers
+	// with skewed clocks, are only discarded if they've waited in the
+	// watcher
+	FromReceived bool
+}
+
+// deadline returns when the event expires, or the zero time if it doesn't.
+func (p *ExpiryPolicy) deadline(ev *WinLogEvent) time.Time {
+	if p == nil || p.TTL <= 0 {
+		return time.Time{}
+	}
+	from := ev.Created
+	if p.FromReceived {
+		from = ev.ReceivedAt
+	}
+	if from.IsZero() {
+		return time.Time{}
+	}
+	return from.Add(p.TTL)
+}
+
+// expire counts an event discarded by the Expiry policy.
+func (self *WinLogWatcher) expire(event *WinLogEvent, stats *subscriptionStats) {
+	if stats != nil {
+		atomic.AddUint64(&stats.expired, 1)
+	}
+	self.log(LogDebug, "Discarded expired event", "channel", event.SubscribedChannel, "recordId", event.RecordId, "created", event.Created)
+}
@@ -0,0 +1,79 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestExpiryDiscardsOldEvents(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, Expiry: &ExpiryPolicy{TTL: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i, created := range []time.Time{time.Now().Add(-time.Hour), time.Now()} {
+			api.emit("Application", fakeValues{
+				EvtSystemProviderName:  "Provider",
+				EvtSystemChannel:       "Application",
+				EvtSystemEventRecordId: uint64(i + 1),
+				EvtSystemTimeCreated:   created,
+			})
+		}
+	}()
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.RecordId, uint64(2), t)
+		// The expired event used a sequence number
+		assertEqual(ev.Sequence, uint64(2), t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	assertEqual(watcher.Stats()["Application"].Expired, uint64(1), t)
+}
+
+func TestExpiryWhileWaitingForConsumer(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{
+		API:    api,
+		Expiry: &ExpiryPolicy{TTL: 20 * time.Millisecond, FromReceived: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	// Created long ago, but only its time in the watcher counts
+	api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+		EvtSystemTimeCreated:   time.Now().Add(-time.Hour),
+	})
+	// emit returned once the event expired without being read
+	stats := watcher.Stats()["Application"]
+	assertEqual(stats.Expired, uint64(1), t)
+	assertEqual(stats.Delivered, uint64(0), t)
+	select {
+	case ev := <-watcher.Event():
+		t.Fatalf("Expired event %v was delivered", ev.RecordId)
+	default:
+	}
+}
+
+func TestExpiryDeadline(t *T) {
+	created := time.Unix(1000, 0)
+	ev := &WinLogEvent{Created: created, ReceivedAt: created.Add(time.Hour)}
+	assertEqual((*ExpiryPolicy)(nil).deadline(ev).IsZero(), true, t)
+	assertEqual((&ExpiryPolicy{TTL: time.Minute}).deadline(ev), created.Add(time.Minute), t)
+	assertEqual((&ExpiryPolicy{TTL: time.Minute, FromReceived: true}).deadline(ev), created.Add(time.Hour+time.Minute), t)
+	assertEqual((&ExpiryPolicy{TTL: time.Minute}).deadline(&WinLogEvent{}).IsZero(), true, t)
+}
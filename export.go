@@ -0,0 +1,100 @@
+package winlog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prefix of export columns holding an EventData value, as in
+// "EventData.TargetUserName"
+const EventDataColumnPrefix = "EventData."
+
+// Columns exported when none are given
+var DefaultExportColumns = []string{"Created", "ComputerName", "Channel", "ProviderName", "EventId", "Level", "RecordId", "Msg"}
+
+// ExportValue returns the value of an export column for the event: one of
+// the keys of CreateMap, such as "EventId", or EventDataColumnPrefix followed
+// by an EventData name. Created is formatted as RFC 3339 in UTC, and missing
+// values are "".
+func ExportValue(ev *WinLogEvent, column string) string {
+	if strings.HasPrefix(column, EventDataColumnPrefix) {
+		return ev.EventData[strings.TrimPrefix(column, EventDataColumnPrefix)]
+	}
+	switch column {
+	case "Created":
+		if ev.Created.IsZero() {
+			return ""
+		}
+		return ev.Created.UTC().Format(time.RFC3339Nano)
+	case "Xml":
+		return string(ev.Xml)
+	}
+	value, ok := ev.CreateMap()[column]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// ExportRow returns the values of the columns for the event.
+func ExportRow(ev *WinLogEvent, columns []string) []string {
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		row[i] = ExportValue(ev, column)
+	}
+	return row
+}
+
+// EventDataColumns returns a sorted column for every EventData name in the
+// events, to append to the system columns when exporting events of one kind.
+func EventDataColumns(events []*WinLogEvent) []string {
+	names := make(map[string]bool)
+	for _, ev := range events {
+		for name := range ev.EventData {
+			names[name] = true
+		}
+	}
+	columns := make([]string, 0, len(names))
+	for name := range names {
+		columns = append(columns, EventDataColumnPrefix+name)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// CSVWriter writes events as CSV rows, with a header row of column names.
+type CSVWriter struct {
+	Columns []string
+
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSVWriter writes the columns of each event to `w`. If columns is nil,
+// DefaultExportColumns is used.
+func NewCSVWriter(w io.Writer, columns []string) *CSVWriter {
+	if columns == nil {
+		columns = DefaultExportColumns
+	}
+	return &CSVWriter{Columns: columns, writer: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) Write(ev *WinLogEvent) error {
+	if !c.wroteHeader {
+		if err := c.writer.Write(c.Columns); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	return c.writer.Write(ExportRow(ev, c.Columns))
+}
+
+// Flush writes any buffered rows.
+func (c *CSVWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
@@ -0,0 +1,32 @@
+package winlog
+
+import (
+	"bytes"
+	. "testing"
+	"time"
+)
+
+func TestCSVWriter(t *T) {
+	events := []*WinLogEvent{
+		{EventId: 4624, Created: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), EventData: map[string]string{"TargetUserName": "alice"}},
+		{EventId: 4625, Msg: "Failed, \"bad password\"", EventData: map[string]string{"TargetUserName": "bob", "Status": "0xc000006d"}},
+	}
+	columns := append([]string{"EventId", "Created", "Msg"}, EventDataColumns(events)...)
+	assertEqual(len(columns), 5, t)
+	assertEqual(columns[3], "EventData.Status", t)
+
+	var buf bytes.Buffer
+	writer := NewCSVWriter(&buf, columns)
+	for _, ev := range events {
+		if err := writer.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "EventId,Created,Msg,EventData.Status,EventData.TargetUserName\n" +
+		"4624,2021-03-04T05:06:07Z,,,alice\n" +
+		"4625,,\"Failed, \"\"bad password\"\"\",0xc000006d,bob\n"
+	assertEqual(buf.String(), expected, t)
+}
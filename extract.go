@@ -0,0 +1,111 @@
+package winlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ExtractionRule pulls fields out of the formatted message of the events it
+// applies to, for providers whose interesting values are only in the
+// message text. Each named capture group in Pattern which matches becomes
+// a field of the event's Extracted, keyed by the group's name:
+//
+//	ExtractionRule{
+//		Providers: []string{"Service Control Manager"},
+//		EventIds:  []uint64{7036},
+//		Pattern:   `The (?P<ServiceName>.+) service entered the (?P<State>\w+) state`,
+//	}
+type ExtractionRule struct {
+	// Providers and event IDs the rule applies to. Providers are matched
+	// case-insensitively, and the rule applies to any if either is empty.
+	Providers []string
+	EventIds  []uint64
+	// Regular expression with named capture groups
+	Pattern string
+}
+
+func (rule *ExtractionRule) appliesTo(ev *WinLogEvent) bool {
+	return (len(rule.Providers) == 0 || containsFold(rule.Providers, ev.ProviderName)) &&
+		(len(rule.EventIds) == 0 || containsUint(rule.EventIds, ev.EventId))
+}
+
+// Extractor applies ExtractionRules to events' Msg, which is only
+// populated when the watcher's RenderMessage is enabled. Every rule which
+// applies to an event is tried in order, and a field found by an earlier
+// rule isn't replaced by a later one.
+//
+// The rules are compiled the first time the Extractor is used and must not
+// be modified afterwards.
+type Extractor struct {
+	Rules []ExtractionRule
+
+	once       sync.Once
+	compileErr error
+	patterns   []*regexp.Regexp
+}
+
+func (x *Extractor) compile() error {
+	x.once.Do(func() {
+		for i, rule := range x.Rules {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				x.compileErr = fmt.Errorf("Invalid pattern in extraction rule %v: %v", i, err)
+				return
+			}
+			named := false
+			for _, name := range re.SubexpNames() {
+				named = named || name != ""
+			}
+			if !named {
+				x.compileErr = fmt.Errorf("Extraction rule %v has no named capture groups", i)
+				return
+			}
+			x.patterns = append(x.patterns, re)
+		}
+	})
+	return x.compileErr
+}
+
+// Validate reports whether every rule's Pattern compiles and has a named
+// capture group, so a configuration can be checked before subscribing.
+func (x *Extractor) Validate() error {
+	return x.compile()
+}
+
+// Extract adds the fields the rules find in the event's message to its
+// Extracted. An error is returned if a rule's Pattern is invalid, in which
+// case the event is left untouched.
+func (x *Extractor) Extract(ev *WinLogEvent) error {
+	if err := x.compile(); err != nil {
+		return err
+	}
+	if ev.Msg == "" {
+		return nil
+	}
+	for i := range x.Rules {
+		if !x.Rules[i].appliesTo(ev) {
+			continue
+		}
+		re := x.patterns[i]
+		match := re.FindStringSubmatchIndex(ev.Msg)
+		if match == nil {
+			continue
+		}
+		for group, name := range re.SubexpNames() {
+			start, end := match[2*group], match[2*group+1]
+			if name == "" || start < 0 {
+				continue
+			}
+			if _, ok := ev.Extracted[name]; ok {
+				continue
+			}
+			if ev.Extracted == nil {
+				ev.Extracted = make(map[string]string)
+			}
+			ev.Extracted[name] = strings.TrimSpace(ev.Msg[start:end])
+		}
+	}
+	return nil
+}
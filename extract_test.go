@@ -0,0 +1,98 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestExtractor(t *T) {
+	extractor := &Extractor{Rules: []ExtractionRule{
+		{
+			Providers: []string{"service control manager"},
+			EventIds:  []uint64{7036},
+			Pattern:   `The (?P<ServiceName>.+) service entered the (?P<State>\w+) state`,
+		},
+		{Pattern: `(?P<ServiceName>\w+) (?P<Other>\w+)?`},
+	}}
+	ev := &WinLogEvent{
+		ProviderName: "Service Control Manager",
+		EventId:      7036,
+		Msg:          "The Windows Update service entered the running state.",
+	}
+	if err := extractor.Extract(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.Extracted["ServiceName"], "Windows Update", t)
+	assertEqual(ev.Extracted["State"], "running", t)
+	assertEqual(ev.Extracted["Other"], "Windows", t)
+	assertEqual(len(ev.Extracted), 3, t)
+
+	// Only the second rule applies to other events
+	ev = &WinLogEvent{ProviderName: "Service Control Manager", EventId: 7040, Msg: "The start type changed."}
+	if err := extractor.Extract(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.Extracted["ServiceName"], "The", t)
+	assertEqual(len(ev.Extracted), 2, t)
+
+	ev = &WinLogEvent{}
+	if err := extractor.Extract(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.Extracted == nil, true, t)
+
+	redactor := &Redactor{MaskFields: []string{"State"}, MsgPatterns: []string{`Update`}}
+	ev = &WinLogEvent{Extracted: map[string]string{"State": "running", "ServiceName": "Windows Update"}}
+	if err := redactor.Redact(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.Extracted["State"], DefaultRedactionMask, t)
+	assertEqual(ev.Extracted["ServiceName"], "Windows "+DefaultRedactionMask, t)
+}
+
+func TestExtractorInvalidRules(t *T) {
+	for _, pattern := range []string{`(?P<Name>`, `no groups (\d+)`} {
+		extractor := &Extractor{Rules: []ExtractionRule{{Pattern: pattern}}}
+		if extractor.Validate() == nil {
+			t.Errorf("Expected an error validating %q", pattern)
+		}
+		ev := &WinLogEvent{Msg: "no groups 1"}
+		if extractor.Extract(ev) == nil {
+			t.Errorf("Expected an error extracting with %q", pattern)
+		}
+		assertEqual(ev.Extracted == nil, true, t)
+	}
+}
+
+func TestWatcherExtractsFields(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.RenderMessage = true
+	watcher.Extractor = &Extractor{Rules: []ExtractionRule{{Pattern: `message (?P<Flags>\d+)`}}}
+	watcher.Filter, err = CompileFilter(`Extracted.Flags == 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventID:       uint64(1),
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.Extracted["Flags"], "1", t)
+		assertEqual(ev.CreateMapWith(MapOptions{Flatten: true})["Extracted.Flags"], "1", t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
@@ -0,0 +1,58 @@
+package winlog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StoreLockedError is returned when opening a store which another process
+// has open.
+type StoreLockedError struct {
+	Path string
+	// Process holding the lock, or 0 if unknown
+	PID int
+}
+
+func (e *StoreLockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%v is already locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%v is already locked by PID %v", e.Path, e.PID)
+}
+
+// fileLock is an exclusive advisory lock on a file, released when the file
+// is closed or the process exits. The file holds the PID of the owner.
+type fileLock struct {
+	file *os.File
+}
+
+func lockFile(path string) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := tryLock(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Failed to lock %v: %v", path, err)
+	}
+	if !locked {
+		data, _ := ioutil.ReadAll(file)
+		file.Close()
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		return nil, &StoreLockedError{Path: path, PID: pid}
+	}
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	return &fileLock{file: file}, nil
+}
+
+func (l *fileLock) unlock() error {
+	// Leave the file in place: removing it would let another process lock
+	// a new file while a third still has the old one open
+	return l.file.Close()
+}
@@ -0,0 +1,12 @@
+//go:build !windows && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !windows,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package winlog
+
+import (
+	"os"
+)
+
+func tryLock(file *os.File) (bool, error) {
+	return false, ErrUnsupportedPlatform
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLock takes an exclusive lock without blocking, returning false if
+// another process holds it. A byte far beyond the PID is locked, since
+// locked ranges can't be read by other processes.
+func tryLock(file *os.File) (bool, error) {
+	overlapped := &windows.Overlapped{OffsetHigh: 0x7fffffff}
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, overlapped)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return false, nil
+	}
+	return err == nil, err
+}
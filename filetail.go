@@ -0,0 +1,170 @@
+package winlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How often a FileTail re-queries its file by default
+const DefaultFileTailInterval = 5 * time.Second
+
+// How long a FileTail waits for each event from the event log by default
+const DefaultFileTailTimeout = 10 * time.Second
+
+// FileTail follows an exported .evtx file which is still being appended to,
+// such as a saved or forwarded log being synced in, by re-querying it for
+// records past the last one read. Not safe for concurrent use.
+type FileTail struct {
+	Path  string
+	Query string
+	// How often Follow re-queries the file once it has read every event.
+	// DefaultFileTailInterval if zero.
+	Interval time.Duration
+	// How long each read waits for the event log to return the next event
+	// before failing. DefaultFileTailTimeout if zero.
+	Timeout time.Duration
+
+	watcher      *WinLogWatcher
+	result       *QueryResult
+	lastRecordId uint64
+}
+
+// TailFile follows the events matching the XPath `query` in an exported
+// .evtx file, starting after `afterRecordId`, or at the oldest record if
+// it's 0. Events are rendered with the watcher's Render settings but aren't
+// delivered to its Event channel or filtered. Persist LastRecordId to resume
+// after a restart.
+func (self *WinLogWatcher) TailFile(path, query string, afterRecordId uint64) (*FileTail, error) {
+	if strings.HasPrefix(strings.TrimSpace(query), "<") {
+		return nil, fmt.Errorf("TailFile needs an XPath query, not a structured query")
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileTail{Path: absPath, Query: query, watcher: self, lastRecordId: afterRecordId}, nil
+}
+
+// LastRecordId is the RecordId of the last event returned by Next.
+func (t *FileTail) LastRecordId() uint64 {
+	return t.lastRecordId
+}
+
+// Next returns the next event in the file, or io.EOF if it has no more
+// events yet. After io.EOF, Next queries the file again for events
+// appended since.
+func (t *FileTail) Next() (*WinLogEvent, error) {
+	return t.NextContext(context.Background())
+}
+
+// NextContext is Next, but returns ctx.Err() as soon as `ctx` is done
+// rather than waiting for the read to time out.
+func (t *FileTail) NextContext(ctx context.Context) (*WinLogEvent, error) {
+	if t.result == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := queryStructured(fileTailQuery(t.Path, t.Query, t.lastRecordId), EvtQueryFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to query %v: %v", t.Path, err)
+		}
+		t.result = result
+	}
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFileTailTimeout
+	}
+	handle, err := t.result.NextContext(ctx, timeout)
+	if err != nil {
+		// The query is finished, or cancelled and can't be read again
+		t.result.Close()
+		t.result = nil
+		return nil, err
+	}
+	defer t.watcher.api.Close(uint64(handle))
+	ev, err := t.watcher.RenderEvent(handle)
+	if err != nil {
+		return nil, err
+	}
+	if ev.RecordId > t.lastRecordId {
+		t.lastRecordId = ev.RecordId
+	}
+	return ev, nil
+}
+
+// Follow calls `fn` with each event, polling the file every Interval for
+// new ones, until `stop` is closed or `fn` returns an error.
+func (t *FileTail) Follow(stop <-chan struct{}, fn func(*WinLogEvent) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := t.FollowContext(ctx, fn)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// FollowContext calls `fn` with each event, polling the file every Interval
+// for new ones, until `ctx` is done, when it returns ctx.Err(), or `fn`
+// returns an error. A read in progress is cancelled rather than left to time
+// out.
+func (t *FileTail) FollowContext(ctx context.Context, fn func(*WinLogEvent) error) error {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = DefaultFileTailInterval
+	}
+	for {
+		ev, err := t.NextContext(ctx)
+		if err == io.EOF {
+			select {
+			case <-time.After(interval):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else if err != nil {
+			return err
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Close releases the current query, if any.
+func (t *FileTail) Close() error {
+	if t.result == nil {
+		return nil
+	}
+	err := t.result.Close()
+	t.result = nil
+	return err
+}
+
+// fileTailQuery builds a structured query selecting the events matching
+// `query` in the file, suppressing those up to `afterRecordId`.
+func fileTailQuery(path, query string, afterRecordId uint64) string {
+	escape := escapeXMLText
+	filePath := escape("file://" + path)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<QueryList><Query Id="0" Path="%v"><Select Path="%v">%v</Select>`, filePath, filePath, escape(query))
+	if afterRecordId > 0 {
+		fmt.Fprintf(&b, `<Suppress Path="%v">%v</Suppress>`, filePath, escape(fmt.Sprintf("*[System[EventRecordID<=%v]]", afterRecordId)))
+	}
+	b.WriteString(`</Query></QueryList>`)
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package winlog
+
+import (
+	"context"
+	"encoding/xml"
+	. "testing"
+)
+
+func TestFileTailQuery(t *T) {
+	assertEqual(fileTailQuery(`C:\logs\a.evtx`, "*", 0),
+		`<QueryList><Query Id="0" Path="file://C:\logs\a.evtx"><Select Path="file://C:\logs\a.evtx">*</Select></Query></QueryList>`, t)
+
+	query := fileTailQuery(`C:\logs\a&b.evtx`, "*[System[Level<=2]]", 42)
+	var parsed struct {
+		Query struct {
+			Select struct {
+				Path  string `xml:",attr"`
+				XPath string `xml:",chardata"`
+			}
+			Suppress struct {
+				XPath string `xml:",chardata"`
+			}
+		}
+	}
+	if err := xml.Unmarshal([]byte(query), &parsed); err != nil {
+		t.Fatalf("Invalid query %v: %v", query, err)
+	}
+	assertEqual(parsed.Query.Select.Path, `file://C:\logs\a&b.evtx`, t)
+	assertEqual(parsed.Query.Select.XPath, "*[System[Level<=2]]", t)
+	assertEqual(parsed.Query.Suppress.XPath, "*[System[EventRecordID<=42]]", t)
+}
+
+func TestTailFileRejectsStructuredQuery(t *T) {
+	if _, err := (&WinLogWatcher{}).TailFile("a.evtx", "<QueryList/>", 0); err == nil {
+		t.Fatal("Expected an error for a structured query")
+	}
+}
+
+func TestFollowContextReturnsWhenCancelled(t *T) {
+	tail, err := (&WinLogWatcher{}).TailFile("a.evtx", "*", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	err = tail.FollowContext(ctx, func(*WinLogEvent) error {
+		called = true
+		return nil
+	})
+	assertEqual(err, context.Canceled, t)
+	assertEqual(called, false, t)
+}
@@ -0,0 +1,513 @@
+package winlog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/* A small expression language for filtering rendered events, e.g.
+
+	EventId == 4688 && EventData.NewProcessName endsWith "powershell.exe"
+
+   Operands are event fields (any string or integer field of WinLogEvent,
+   EventData.<Name>, UserData.<Name> or Extracted.<Name>), double-quoted
+   strings and numbers, which may be hex or fractional. Supported operators, from lowest to highest
+   precedence: ||, &&, !, and the comparisons ==, !=, <, <=, >, >=,
+   contains, startsWith, endsWith and matches (regular expression).
+   Comparisons are numeric when both sides are numbers, otherwise they
+   compare strings. A field used on its own is true if it is non-empty and
+   non-zero. */
+
+// Filter is a compiled filter expression. It implements encoding.TextMarshaler
+// and encoding.TextUnmarshaler so it can be loaded directly from configuration.
+type Filter struct {
+	source string
+	root   filterNode
+}
+
+// CompileFilter parses a filter expression.
+func CompileFilter(expr string) (*Filter, error) {
+	p := &filterParser{}
+	if err := p.tokenize(expr); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("Unexpected %q at offset %v in filter", tok.text, tok.pos)
+	}
+	return &Filter{source: expr, root: root}, nil
+}
+
+// Match reports whether the event satisfies the filter.
+func (f *Filter) Match(ev *WinLogEvent) bool {
+	return f.root.eval(ev).truthy()
+}
+
+func (f *Filter) String() string {
+	return f.source
+}
+
+func (f *Filter) MarshalText() ([]byte, error) {
+	return []byte(f.source), nil
+}
+
+func (f *Filter) UnmarshalText(text []byte) error {
+	compiled, err := CompileFilter(string(text))
+	if err != nil {
+		return err
+	}
+	*f = *compiled
+	return nil
+}
+
+/* Values */
+
+// Numbers which are unsigned integers compare as uint64, since float64
+// can't hold all of a RecordId or KeywordsMask. Only fractional or negative
+// numbers compare as float64.
+type filterValue struct {
+	str      string
+	num      float64
+	unsigned uint64
+	isUint   bool
+	numeric  bool
+}
+
+func stringValue(s string) filterValue {
+	return filterValue{str: s}
+}
+
+func numberValue(n uint64) filterValue {
+	return filterValue{str: strconv.FormatUint(n, 10), num: float64(n), unsigned: n, isUint: true, numeric: true}
+}
+
+func floatValue(n float64) filterValue {
+	return filterValue{str: strconv.FormatFloat(n, 'g', -1, 64), num: n, numeric: true}
+}
+
+// severityValue compares as the severity's name with strings, such as
+// Severity == "warn", and as its rank with numbers, such as Severity >= 3.
+func severityValue(ev *WinLogEvent) filterValue {
+	return filterValue{str: ev.Severity.String(), num: float64(ev.Severity),
+		unsigned: uint64(ev.Severity), isUint: true, numeric: true}
+}
+
+func boolValue(b bool) filterValue {
+	if b {
+		return numberValue(1)
+	}
+	return numberValue(0)
+}
+
+func (v filterValue) truthy() bool {
+	if v.numeric {
+		return v.num != 0
+	}
+	return v.str != ""
+}
+
+// asNumber returns the value as a number, parsing strings where possible so
+// that EventData values can be compared numerically.
+func (v filterValue) asNumber() (filterValue, bool) {
+	if v.numeric {
+		return v, true
+	}
+	return parseNumber(strings.TrimSpace(v.str))
+}
+
+// parseNumber parses hex and decimal unsigned integers exactly, and other
+// decimal numbers as floats.
+func parseNumber(s string) (filterValue, bool) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, err := strconv.ParseUint(s[2:], 16, 64)
+		return numberValue(n), err == nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return numberValue(n), true
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return floatValue(n), err == nil
+}
+
+// compareNumbers compares as uint64 if both numbers are unsigned integers.
+func compareNumbers(left, right filterValue) int {
+	if left.isUint && right.isUint {
+		switch {
+		case left.unsigned < right.unsigned:
+			return -1
+		case left.unsigned > right.unsigned:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case left.num < right.num:
+		return -1
+	case left.num > right.num:
+		return 1
+	}
+	return 0
+}
+
+/* AST */
+
+type filterNode interface {
+	eval(ev *WinLogEvent) filterValue
+}
+
+type literalNode struct {
+	value filterValue
+}
+
+func (n literalNode) eval(*WinLogEvent) filterValue {
+	return n.value
+}
+
+type fieldNode struct {
+	name string
+	get  func(ev *WinLogEvent) filterValue
+}
+
+func (n fieldNode) eval(ev *WinLogEvent) filterValue {
+	return n.get(ev)
+}
+
+type notNode struct {
+	operand filterNode
+}
+
+func (n notNode) eval(ev *WinLogEvent) filterValue {
+	return boolValue(!n.operand.eval(ev).truthy())
+}
+
+type logicalNode struct {
+	and         bool
+	left, right filterNode
+}
+
+func (n logicalNode) eval(ev *WinLogEvent) filterValue {
+	left := n.left.eval(ev).truthy()
+	if n.and && !left {
+		return boolValue(false)
+	}
+	if !n.and && left {
+		return boolValue(true)
+	}
+	return boolValue(n.right.eval(ev).truthy())
+}
+
+type compareNode struct {
+	op          string
+	left, right filterNode
+	re          *regexp.Regexp
+}
+
+func (n compareNode) eval(ev *WinLogEvent) filterValue {
+	left, right := n.left.eval(ev), n.right.eval(ev)
+	switch n.op {
+	case "contains":
+		return boolValue(strings.Contains(left.str, right.str))
+	case "startsWith":
+		return boolValue(strings.HasPrefix(left.str, right.str))
+	case "endsWith":
+		return boolValue(strings.HasSuffix(left.str, right.str))
+	case "matches":
+		return boolValue(n.re.MatchString(left.str))
+	}
+
+	var cmp int
+	ln, lok := left.asNumber()
+	rn, rok := right.asNumber()
+	if lok && rok && (left.numeric || right.numeric) {
+		cmp = compareNumbers(ln, rn)
+	} else {
+		cmp = strings.Compare(left.str, right.str)
+	}
+
+	switch n.op {
+	case "==":
+		return boolValue(cmp == 0)
+	case "!=":
+		return boolValue(cmp != 0)
+	case "<":
+		return boolValue(cmp < 0)
+	case "<=":
+		return boolValue(cmp <= 0)
+	case ">":
+		return boolValue(cmp > 0)
+	default:
+		return boolValue(cmp >= 0)
+	}
+}
+
+// filterFields maps the field names usable in expressions to their accessors
+var filterFields = map[string]func(ev *WinLogEvent) filterValue{
+	"ProviderName":      func(ev *WinLogEvent) filterValue { return stringValue(ev.ProviderName) },
+	"EventId":           func(ev *WinLogEvent) filterValue { return numberValue(ev.EventId) },
+	"Qualifiers":        func(ev *WinLogEvent) filterValue { return numberValue(ev.Qualifiers) },
+	"Level":             func(ev *WinLogEvent) filterValue { return numberValue(ev.Level) },
+	"Task":              func(ev *WinLogEvent) filterValue { return numberValue(ev.Task) },
+	"Opcode":            func(ev *WinLogEvent) filterValue { return numberValue(ev.Opcode) },
+	"RecordId":          func(ev *WinLogEvent) filterValue { return numberValue(ev.RecordId) },
+	"ProcessId":         func(ev *WinLogEvent) filterValue { return numberValue(ev.ProcessId) },
+	"ThreadId":          func(ev *WinLogEvent) filterValue { return numberValue(ev.ThreadId) },
+	"Channel":           func(ev *WinLogEvent) filterValue { return stringValue(ev.Channel) },
+	"ComputerName":      func(ev *WinLogEvent) filterValue { return stringValue(ev.ComputerName) },
+	"Version":           func(ev *WinLogEvent) filterValue { return numberValue(ev.Version) },
+	"KeywordsMask":      func(ev *WinLogEvent) filterValue { return numberValue(ev.KeywordsMask) },
+	"Outcome":           func(ev *WinLogEvent) filterValue { return stringValue(ev.Outcome) },
+	"Severity":          severityValue,
+	"Msg":               func(ev *WinLogEvent) filterValue { return stringValue(ev.Msg) },
+	"LevelText":         func(ev *WinLogEvent) filterValue { return stringValue(ev.LevelText) },
+	"TaskText":          func(ev *WinLogEvent) filterValue { return stringValue(ev.TaskText) },
+	"OpcodeText":        func(ev *WinLogEvent) filterValue { return stringValue(ev.OpcodeText) },
+	"Keywords":          func(ev *WinLogEvent) filterValue { return stringValue(ev.Keywords) },
+	"ChannelText":       func(ev *WinLogEvent) filterValue { return stringValue(ev.ChannelText) },
+	"ProviderText":      func(ev *WinLogEvent) filterValue { return stringValue(ev.ProviderText) },
+	"IdText":            func(ev *WinLogEvent) filterValue { return stringValue(ev.IdText) },
+	"SubscribedChannel": func(ev *WinLogEvent) filterValue { return stringValue(ev.SubscribedChannel) },
+}
+
+/* Lexer */
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+var filterWordOps = map[string]bool{"contains": true, "startsWith": true, "endsWith": true, "matches": true}
+
+func (p *filterParser) tokenize(expr string) error {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			p.tokens = append(p.tokens, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			p.tokens = append(p.tokens, token{tokRParen, ")", i})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			start := i
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return fmt.Errorf("Unterminated string at offset %v in filter", start)
+			}
+			i++
+			p.tokens = append(p.tokens, token{tokString, sb.String(), start})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || unicode.IsLetter(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			p.tokens = append(p.tokens, token{tokNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.' || runes[i] == '-') {
+				i++
+			}
+			word := string(runes[start:i])
+			if filterWordOps[word] {
+				p.tokens = append(p.tokens, token{tokOp, word, start})
+			} else {
+				p.tokens = append(p.tokens, token{tokIdent, word, start})
+			}
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||":
+				p.tokens = append(p.tokens, token{tokOp, two, start})
+				i += 2
+				continue
+			}
+			switch r {
+			case '<', '>', '!':
+				p.tokens = append(p.tokens, token{tokOp, string(r), start})
+				i++
+			default:
+				return fmt.Errorf("Unexpected character %q at offset %v in filter", r, start)
+			}
+		}
+	}
+	p.tokens = append(p.tokens, token{tokEOF, "end of filter", len(runes)})
+	return nil
+}
+
+/* Recursive descent parser */
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "!" {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind != tokOp || tok.text == "&&" || tok.text == "||" || tok.text == "!" {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	node := compareNode{op: tok.text, left: left, right: right}
+	if tok.text == "matches" {
+		lit, ok := right.(literalNode)
+		if !ok {
+			return nil, fmt.Errorf("Right side of matches at offset %v must be a string", tok.pos)
+		}
+		if node.re, err = regexp.Compile(lit.value.str); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *filterParser) parseOperand() (filterNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("Expected ) at offset %v in filter, got %q", closing.pos, closing.text)
+		}
+		return inner, nil
+	case tokString:
+		return literalNode{stringValue(tok.text)}, nil
+	case tokNumber:
+		if n, err := strconv.ParseUint(tok.text, 0, 64); err == nil {
+			return literalNode{numberValue(n)}, nil
+		}
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q at offset %v in filter", tok.text, tok.pos)
+		}
+		return literalNode{floatValue(n)}, nil
+	case tokIdent:
+		return fieldFor(tok)
+	default:
+		return nil, fmt.Errorf("Unexpected %q at offset %v in filter", tok.text, tok.pos)
+	}
+}
+
+func fieldFor(tok token) (filterNode, error) {
+	if strings.HasPrefix(tok.text, "EventData.") {
+		name := strings.TrimPrefix(tok.text, "EventData.")
+		return fieldNode{name: tok.text, get: func(ev *WinLogEvent) filterValue {
+			return stringValue(ev.EventData[name])
+		}}, nil
+	}
+	if strings.HasPrefix(tok.text, "UserData.") {
+		name := strings.TrimPrefix(tok.text, "UserData.")
+		return fieldNode{name: tok.text, get: func(ev *WinLogEvent) filterValue {
+			return stringValue(ev.UserData[name])
+		}}, nil
+	}
+	if strings.HasPrefix(tok.text, "Extracted.") {
+		name := strings.TrimPrefix(tok.text, "Extracted.")
+		return fieldNode{name: tok.text, get: func(ev *WinLogEvent) filterValue {
+			return stringValue(ev.Extracted[name])
+		}}, nil
+	}
+	get, ok := filterFields[tok.text]
+	if !ok {
+		return nil, fmt.Errorf("Unknown field %q at offset %v in filter", tok.text, tok.pos)
+	}
+	return fieldNode{name: tok.text, get: get}, nil
+}
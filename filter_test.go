@@ -0,0 +1,119 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+)
+
+func TestFilterMatch(t *T) {
+	ev := &WinLogEvent{
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4688,
+		Level:        0,
+		Channel:      "Security",
+		EventData: map[string]string{
+			"NewProcessName":     `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`,
+			"TokenElevationType": "%%1936",
+			"ProcessId":          "0x1a4",
+		},
+		UserData: map[string]string{
+			"SubjectUserName": "testadmin",
+		},
+	}
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{`EventId == 4688`, true},
+		{`EventId != 4688`, false},
+		{`EventId == 4688 && EventData.NewProcessName endsWith "powershell.exe"`, true},
+		{`EventId == 4624 || EventData.NewProcessName contains "cmd.exe"`, false},
+		{`!(EventId >= 4000 && EventId < 5000)`, false},
+		{`Channel == "Security" && ProviderName startsWith "Microsoft-Windows-Security"`, true},
+		{`EventData.NewProcessName matches "(?i)POWERSHELL"`, true},
+		{`EventData.ProcessId == 420`, true},
+		{`EventData.Missing`, false},
+		{`EventData.NewProcessName`, true},
+		{`UserData.SubjectUserName == "testadmin"`, true},
+		{`UserData.NewProcessName`, false},
+		{`Level`, false},
+		{`Level <= 3 && Level > 0`, false},
+	}
+	for _, test := range tests {
+		f, err := CompileFilter(test.expr)
+		if err != nil {
+			t.Fatalf("%v: %v", test.expr, err)
+		}
+		if f.Match(ev) != test.match {
+			t.Fatalf("%v: expected match=%v", test.expr, test.match)
+		}
+	}
+}
+
+// Integers over 53 bits don't survive conversion to float64
+func TestFilterLargeIntegers(t *T) {
+	ev := &WinLogEvent{
+		RecordId:     1<<53 + 1,
+		KeywordsMask: 0x8020000000000001,
+		EventData: map[string]string{
+			"LogonId": "18446744073709551615",
+			"Ratio":   "0.75",
+			"Offset":  "-2",
+		},
+	}
+	for _, test := range []struct {
+		expr  string
+		match bool
+	}{
+		{`RecordId == 9007199254740993`, true},
+		{`RecordId > 9007199254740992`, true},
+		{`RecordId == 9007199254740992`, false},
+		{`KeywordsMask == 0x8020000000000001`, true},
+		{`KeywordsMask == 0x8020000000000000`, false},
+		{`EventData.LogonId == 0xffffffffffffffff`, true},
+		{`EventData.LogonId > 18446744073709551614`, true},
+		// Fractional and negative numbers compare as floats
+		{`EventData.Ratio < 1`, true},
+		{`EventData.Ratio >= 0.5`, true},
+		{`EventData.Ratio == 0.75`, true},
+		{`EventData.Offset < 0.5`, true},
+		{`RecordId > 1.5`, true},
+	} {
+		f, err := CompileFilter(test.expr)
+		if err != nil {
+			t.Fatalf("%v: %v", test.expr, err)
+		}
+		if f.Match(ev) != test.match {
+			t.Fatalf("%v: expected match=%v", test.expr, test.match)
+		}
+	}
+}
+
+func TestFilterCompileErrors(t *T) {
+	for _, expr := range []string{
+		``,
+		`EventId ==`,
+		`Bogus == 1`,
+		`(EventId == 1`,
+		`Msg == "unterminated`,
+		`Msg matches "("`,
+		`EventId == 1 EventId`,
+		`EventId = 1`,
+		`EventId == 1.2.3`,
+	} {
+		if _, err := CompileFilter(expr); err == nil {
+			t.Fatalf("No error compiling %q", expr)
+		}
+	}
+}
+
+func TestFilterFromConfig(t *T) {
+	var config struct {
+		Filter *Filter
+	}
+	if err := json.Unmarshal([]byte(`{"Filter": "EventId == 7036"}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(config.Filter.Match(&WinLogEvent{EventId: 7036}), true, t)
+	assertEqual(config.Filter.String(), "EventId == 7036", t)
+}
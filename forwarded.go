@@ -0,0 +1,56 @@
+package winlog
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Channel a Windows Event Collector's subscriptions write forwarded events
+// to by default
+const ForwardedEventsChannel = "ForwardedEvents"
+
+// isCollectorChannel reports whether events on a subscribed channel were
+// forwarded from other computers.
+func (self *WinLogWatcher) isCollectorChannel(channel string) bool {
+	if strings.EqualFold(channel, ForwardedEventsChannel) {
+		return true
+	}
+	for _, collectorChannel := range self.CollectorChannels {
+		if strings.EqualFold(channel, collectorChannel) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectorName returns CollectorName, or else the name of this computer.
+func (self *WinLogWatcher) collectorName() string {
+	if self.CollectorName != "" {
+		return self.CollectorName
+	}
+	self.hostnameOnce.Do(func() {
+		self.hostname, _ = os.Hostname()
+	})
+	return self.hostname
+}
+
+// markForwarded records where a forwarded event was logged, and where and
+// when it was collected.
+func (ev *WinLogEvent) markForwarded(collector string, collected time.Time) {
+	ev.Forwarded = true
+	ev.SourceComputer = ev.ComputerName
+	ev.SourceChannel = ev.Channel
+	ev.Collector = collector
+	ev.CollectedTime = collected
+}
+
+// ForwardingDelay returns how long after being logged a forwarded event was
+// collected, including any time it spent waiting to be read, or 0 for
+// events which weren't forwarded.
+func (ev *WinLogEvent) ForwardingDelay() time.Duration {
+	if !ev.Forwarded || ev.Created.IsZero() {
+		return 0
+	}
+	return ev.CollectedTime.Sub(ev.Created)
+}
@@ -0,0 +1,36 @@
+package winlog
+
+import (
+	"os"
+	. "testing"
+	"time"
+)
+
+func TestCollectorChannels(t *T) {
+	watcher := &WinLogWatcher{CollectorChannels: []string{"Collected-Servers"}}
+	assertEqual(watcher.isCollectorChannel("ForwardedEvents"), true, t)
+	assertEqual(watcher.isCollectorChannel("forwardedevents"), true, t)
+	assertEqual(watcher.isCollectorChannel("collected-servers"), true, t)
+	assertEqual(watcher.isCollectorChannel("Security"), false, t)
+
+	hostname, _ := os.Hostname()
+	assertEqual(watcher.collectorName(), hostname, t)
+	watcher.CollectorName = "wec01"
+	assertEqual(watcher.collectorName(), "wec01", t)
+}
+
+func TestMarkForwarded(t *T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	ev := &WinLogEvent{ComputerName: "ws1", Channel: "Security", Created: created}
+	assertEqual(ev.ForwardingDelay(), time.Duration(0), t)
+	ev.markForwarded("wec01", created.Add(3*time.Second))
+	assertEqual(ev.ForwardingDelay(), 3*time.Second, t)
+
+	m := ev.CreateMap()
+	assertEqual(m["Forwarded"], true, t)
+	assertEqual(m["SourceComputer"], "ws1", t)
+	assertEqual(m["SourceChannel"], "Security", t)
+	assertEqual(m["Collector"], "wec01", t)
+	_, ok := (&WinLogEvent{}).CreateMap()["Forwarded"]
+	assertEqual(ok, false, t)
+}
@@ -0,0 +1,195 @@
+package winlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Largest UDP chunk GELFSink sends by default, which fits in a typical LAN
+// datagram
+const DefaultGELFChunkSize = 8192
+
+// Graylog drops messages split into more chunks than this
+const maxGELFChunks = 128
+
+// GELF chunk header magic bytes
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// Syslog severity for each event level
+var gelfLevels = map[uint64]int{
+	LevelCritical:    2,
+	LevelError:       3,
+	LevelWarning:     4,
+	LevelInformation: 6,
+	LevelVerbose:     7,
+}
+
+// gelfFieldName replaces characters not allowed in GELF additional field
+// names.
+func gelfFieldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// FormatGELF renders the event as a GELF 1.1 message for Graylog. The event's
+// system fields are sent as additional fields such as _event_id and
+// _channel, and each EventData value as _event_data_<name>.
+func FormatGELF(ev *WinLogEvent) ([]byte, error) {
+	shortMessage := strings.TrimSpace(ev.Msg)
+	if i := strings.IndexAny(shortMessage, "\r\n"); i >= 0 {
+		shortMessage = strings.TrimSpace(shortMessage[:i])
+	}
+	if shortMessage == "" {
+		shortMessage = fmt.Sprintf("%v event %v", ev.ProviderName, ev.EventId)
+	}
+	host := ev.ComputerName
+	if host == "" {
+		host = "unknown"
+	}
+	level, ok := gelfLevels[ev.Level]
+	if !ok {
+		// Level 0 (LogAlways) and custom levels
+		level = 6
+	}
+
+	m := map[string]interface{}{
+		"version":        "1.1",
+		"host":           host,
+		"short_message":  shortMessage,
+		"level":          level,
+		"_event_id":      ev.EventId,
+		"_provider_name": ev.ProviderName,
+		"_channel":       ev.Channel,
+		"_record_id":     ev.RecordId,
+		"_process_id":    ev.ProcessId,
+		"_thread_id":     ev.ThreadId,
+		"_task":          ev.Task,
+		"_opcode":        ev.Opcode,
+		"_event_level":   ev.Level,
+	}
+	if ev.Msg != shortMessage && ev.Msg != "" {
+		m["full_message"] = ev.Msg
+	}
+	if !ev.Created.IsZero() {
+		m["timestamp"] = float64(ev.Created.UnixNano()/1e6) / 1e3
+	}
+	optional := map[string]string{
+		"_level_text":         ev.LevelText,
+		"_task_text":          ev.TaskText,
+		"_opcode_text":        ev.OpcodeText,
+		"_keywords":           ev.Keywords,
+		"_outcome":            ev.Outcome,
+		"_subscribed_channel": ev.SubscribedChannel,
+	}
+	for name, value := range optional {
+		if value != "" {
+			m[name] = value
+		}
+	}
+	for name, value := range ev.EventData {
+		m["_event_data_"+gelfFieldName(name)] = value
+	}
+	return json.Marshal(m)
+}
+
+// GELFSink sends events to a Graylog GELF input. Over UDP, messages are
+// gzipped and split into chunks when they don't fit in one datagram; over
+// TCP they are delimited by null bytes.
+type GELFSink struct {
+	// Largest UDP datagram to send. DefaultGELFChunkSize if it's too small
+	// to hold a chunk's header and some data.
+	ChunkSize int
+
+	network string
+	mutex   sync.Mutex
+	conn    net.Conn
+}
+
+// NewGELFSink connects to the GELF input at `address`. `network` is "udp" or
+// "tcp".
+func NewGELFSink(network, address string) (*GELFSink, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("Unsupported GELF network %q", network)
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &GELFSink{ChunkSize: DefaultGELFChunkSize, network: network, conn: conn}, nil
+}
+
+func (s *GELFSink) Write(ev *WinLogEvent) error {
+	message, err := FormatGELF(ev)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.network == "tcp" {
+		_, err := s.conn.Write(append(message, 0))
+		return err
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(message)
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	chunkSize := s.ChunkSize
+	if chunkSize <= gelfChunkHeaderSize {
+		chunkSize = DefaultGELFChunkSize
+	}
+	if compressed.Len() <= chunkSize {
+		_, err := s.conn.Write(compressed.Bytes())
+		return err
+	}
+	return s.writeChunks(compressed.Bytes(), chunkSize)
+}
+
+// Bytes of magic, message ID, sequence number and count before each
+// chunk's data
+const gelfChunkHeaderSize = 12
+
+// writeChunks sends a message too large for one datagram as GELF chunks of
+// up to `chunkSize` bytes.
+func (s *GELFSink) writeChunks(message []byte, chunkSize int) error {
+	dataSize := chunkSize - gelfChunkHeaderSize
+	count := (len(message) + dataSize - 1) / dataSize
+	if count > maxGELFChunks {
+		return fmt.Errorf("GELF message of %v bytes needs %v chunks, more than the maximum of %v", len(message), count, maxGELFChunks)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	chunk := make([]byte, 0, chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(message) {
+			end = len(message)
+		}
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, message[i*dataSize:end]...)
+		if _, err := s.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GELFSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,174 @@
+package winlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	. "testing"
+	"time"
+)
+
+func TestFormatGELF(t *T) {
+	data, err := FormatGELF(&WinLogEvent{
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4625,
+		Level:        LevelWarning,
+		Created:      time.Unix(1600000000, 250000000),
+		ComputerName: "host.example.com",
+		Msg:          "An account failed to log on.\r\n\r\nSubject:",
+		EventData:    map[string]string{"TargetUserName": "alice", "Logon Type": "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m["version"], "1.1", t)
+	assertEqual(m["host"], "host.example.com", t)
+	assertEqual(m["short_message"], "An account failed to log on.", t)
+	assertEqual(m["full_message"], "An account failed to log on.\r\n\r\nSubject:", t)
+	assertEqual(m["level"], float64(4), t)
+	assertEqual(m["timestamp"], 1600000000.25, t)
+	assertEqual(m["_event_id"], float64(4625), t)
+	assertEqual(m["_event_data_TargetUserName"], "alice", t)
+	assertEqual(m["_event_data_Logon_Type"], "3", t)
+	if _, ok := m["_id"]; ok {
+		t.Fatal("_id isn't allowed in GELF messages")
+	}
+}
+
+func TestGELFSinkChunks(t *T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink, err := NewGELFSink("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.ChunkSize = 512
+
+	// Random data doesn't compress, so the message needs several chunks
+	letters := make([]byte, 3000)
+	for i := range letters {
+		letters[i] = byte('a' + rand.Intn(26))
+	}
+	msg := string(letters)
+	if err := sink.Write(&WinLogEvent{Msg: msg}); err != nil {
+		t.Fatal(err)
+	}
+
+	var compressed []byte
+	buf := make([]byte, 1024)
+	for chunk, count := 0, 1; chunk < count; chunk++ {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 512 || !bytes.Equal(buf[:2], gelfChunkMagic) {
+			t.Fatalf("Invalid chunk of %v bytes", n)
+		}
+		assertEqual(int(buf[10]), chunk, t)
+		count = int(buf[11])
+		compressed = append(compressed, buf[12:n]...)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m["short_message"], msg, t)
+}
+
+func TestGELFSinkTCP(t *T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	sink, err := NewGELFSink("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for id := uint64(1); id <= 2; id++ {
+		if err := sink.Write(&WinLogEvent{EventId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := bytes.Split(bytes.TrimSuffix(data, []byte{0}), []byte{0})
+	assertEqual(len(messages), 2, t)
+	var m map[string]interface{}
+	if err := json.Unmarshal(messages[1], &m); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m["_event_id"], float64(2), t)
+}
+
+func TestGELFSinkInvalidChunkSize(t *T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink, err := NewGELFSink("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// Too small for any data once the header is written, so the default is
+	// used rather than dropping the message or dividing by zero
+	for _, chunkSize := range []int{0, gelfChunkHeaderSize} {
+		sink.ChunkSize = chunkSize
+		msg := fmt.Sprintf("Chunk size %v", chunkSize)
+		if err := sink.Write(&WinLogEvent{Msg: msg}); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, DefaultGELFChunkSize)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(buf[:n]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(m["short_message"], msg, t)
+	}
+}
@@ -2,4 +2,4 @@ module github.com/huntresslabs/gowinlog
 
 go 1.14
 
-require golang.org/x/sys v0.16.0 // indirect
+require golang.org/x/sys v0.16.0
@@ -0,0 +1,266 @@
+package winlog
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Event keys a Handoff remembers if its Window is zero
+const DefaultHandoffWindow = 1024
+
+// Handoff moves a watcher's events to a Sink, checkpointing each
+// subscription's bookmark in the watcher's BookmarkStore only once the sink
+// has acknowledged the event, so integrators don't have to assemble the
+// bookmark store, duplicate suppression and acks themselves:
+//
+//	handoff := &winlog.Handoff{Watcher: watcher}
+//	err := handoff.Deliver(ctx, sink)
+//
+// Its semantics are:
+//
+//   - Events are written one at a time, in the order the watcher delivers
+//     them. A nil error from the sink's Write acknowledges an event, and
+//     only then is its bookmark saved, so a restarted watcher subscribed
+//     from the saved bookmark resumes after the last acknowledged event.
+//     The sink must have stored the event by then: a FileSpool only has if
+//     it's Durable.
+//   - A failed write is retried per Retry without moving on, so no event is
+//     skipped. Deliver returns an error if Retry's MaxAttempts are used up
+//     or a bookmark can't be saved, leaving the saved bookmark at the last
+//     event acknowledged before it.
+//   - Events already acknowledged aren't written again: those at or before
+//     the bookmark last saved for their subscription, which the watcher
+//     delivers again if subscribed from an earlier position, and those whose
+//     EventKey is among the last Window acknowledged, such as a record read
+//     by two subscriptions. Events of a query split across several
+//     subscriptions interleave, so they're only compared by EventKey.
+//   - A cleared channel restarts its RecordIds, so once a subscription's
+//     RecordIds go back, or the event log's clearing event for its channel
+//     arrives, its saved bookmark and the channel's remembered keys are
+//     forgotten and the new records are written.
+//   - An event acknowledged just before the process stops, whose bookmark
+//     wasn't saved, is written again after a restart. The sink sees each
+//     event exactly once if it ignores writes of an EventKey it has already
+//     stored, and at least once otherwise.
+//
+// Deliver drains the watcher's Error channel, passing errors to OnError.
+// Deduplicator summaries, which have no bookmark, are written without
+// checking for duplicates.
+type Handoff struct {
+	// The watcher events are read from. Its BookmarkStore is required.
+	Watcher *WinLogWatcher
+	// Acknowledged event keys remembered, DefaultHandoffWindow if zero
+	Window int
+	// How failed writes are retried. Unlimited attempts if MaxAttempts is
+	// zero.
+	Retry ReconnectPolicy
+	// Called with the watcher's errors, and failed writes before they're
+	// retried. May be nil.
+	OnError func(error)
+
+	delivered  uint64
+	duplicates uint64
+	// RecordId of the bookmark last saved for each subscription
+	saved map[string]uint64
+	// RecordId of the last event handled from each ordered subscription
+	last map[string]uint64
+	// Keys of the last Window acknowledged events, oldest first from next
+	recent map[string]bool
+	ring   []string
+	next   int
+}
+
+// HandoffStats counts the events a Handoff has handled.
+type HandoffStats struct {
+	// Events acknowledged by the sink
+	Delivered uint64
+	// Events skipped for having been acknowledged already
+	Duplicates uint64
+}
+
+// EventKey identifies an event's record across runs and subscriptions, for
+// sinks which suppress duplicate writes: the computer, channel and RecordId.
+func EventKey(ev *WinLogEvent) string {
+	return ev.ComputerName + "/" + ev.Channel + "/" + strconv.FormatUint(ev.RecordId, 10)
+}
+
+// Stats returns the counts of events handled so far.
+func (h *Handoff) Stats() HandoffStats {
+	return HandoffStats{
+		Delivered:  atomic.LoadUint64(&h.delivered),
+		Duplicates: atomic.LoadUint64(&h.duplicates),
+	}
+}
+
+// Deliver writes the watcher's events to the sink until ctx is done, when
+// it returns ctx.Err(), or the watcher shuts down, when it returns nil. It
+// doesn't close the sink. Deliver mustn't be called again until it returns.
+func (h *Handoff) Deliver(ctx context.Context, sink Sink) error {
+	if h.Watcher.BookmarkStore == nil {
+		return fmt.Errorf("The watcher has no BookmarkStore")
+	}
+	if h.saved == nil {
+		h.saved = make(map[string]uint64)
+		h.last = make(map[string]uint64)
+		h.recent = make(map[string]bool)
+	}
+	errs := h.Watcher.Error()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			h.report(err)
+		case ev, ok := <-h.Watcher.Event():
+			if !ok {
+				return nil
+			}
+			if err := h.handle(ctx, sink, ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle writes an event unless it's a duplicate, and checkpoints it.
+func (h *Handoff) handle(ctx context.Context, sink Sink, ev *WinLogEvent) error {
+	summary := ev.SuppressedCount > 0
+	position, positioned := bookmarkPosition(ev.Bookmark)
+	if channel, ok := clearedChannel(ev); ok && !summary {
+		h.forget(channel, channel)
+	}
+	if positioned && !summary && h.ordered(ev.SubscribedChannel) {
+		if _, loaded := h.saved[ev.SubscribedChannel]; !loaded {
+			bookmark, err := h.Watcher.BookmarkStore.Load(ev.SubscribedChannel)
+			if err != nil {
+				return fmt.Errorf("Failed to load bookmark for %q: %v", ev.SubscribedChannel, err)
+			}
+			h.saved[ev.SubscribedChannel], _ = bookmarkPosition(bookmark)
+		}
+		if last, ok := h.last[ev.SubscribedChannel]; ok && position < last {
+			h.forget(ev.SubscribedChannel, ev.Channel)
+		}
+		h.last[ev.SubscribedChannel] = position
+		if position <= h.saved[ev.SubscribedChannel] {
+			atomic.AddUint64(&h.duplicates, 1)
+			return nil
+		}
+	}
+	key := EventKey(ev)
+	if !summary && h.recent[key] {
+		atomic.AddUint64(&h.duplicates, 1)
+		return nil
+	}
+
+	if err := h.write(ctx, sink, ev); err != nil {
+		return err
+	}
+	atomic.AddUint64(&h.delivered, 1)
+	if summary {
+		return nil
+	}
+	h.remember(key)
+	if ev.Bookmark == "" {
+		return nil
+	}
+	if err := h.Watcher.SaveBookmark(ev); err != nil {
+		return err
+	}
+	if positioned {
+		h.saved[ev.SubscribedChannel] = position
+	}
+	return nil
+}
+
+// write writes an event to the sink, retrying until it's acknowledged.
+func (h *Handoff) write(ctx context.Context, sink Sink, ev *WinLogEvent) error {
+	for attempt := 1; ; attempt++ {
+		err := sink.Write(ev)
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("Failed to write record %v from %q: %v", ev.RecordId, ev.SubscribedChannel, err)
+		if h.Retry.MaxAttempts > 0 && attempt >= h.Retry.MaxAttempts {
+			return err
+		}
+		h.report(err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(h.Retry.backoff(attempt)):
+		}
+	}
+}
+
+// remember adds an acknowledged event's key to the window, forgetting the
+// oldest once it's full.
+func (h *Handoff) remember(key string) {
+	window := h.Window
+	if window <= 0 {
+		window = DefaultHandoffWindow
+	}
+	if h.recent[key] {
+		return
+	}
+	if len(h.ring) < window {
+		h.ring = append(h.ring, key)
+	} else {
+		delete(h.recent, h.ring[h.next])
+		h.ring[h.next] = key
+		h.next = (h.next + 1) % len(h.ring)
+	}
+	h.recent[key] = true
+}
+
+// forget drops the saved bookmark position of a subscription to a cleared
+// channel, and the keys of the channel's records, whose RecordIds are now
+// reused.
+func (h *Handoff) forget(subscribedChannel, channel string) {
+	h.saved[subscribedChannel] = 0
+	ring := h.ring[:0:0]
+	for i := range h.ring {
+		key := h.ring[(h.next+i)%len(h.ring)]
+		if strings.Contains(key, "/"+channel+"/") {
+			delete(h.recent, key)
+		} else {
+			ring = append(ring, key)
+		}
+	}
+	h.ring = ring
+	h.next = 0
+}
+
+// ordered reports whether the events of a subscription arrive in record
+// order, so an event at or before the saved bookmark has been delivered.
+// Events from the subscriptions of a split query don't.
+func (h *Handoff) ordered(channel string) bool {
+	h.Watcher.watchMutex.Lock()
+	defer h.Watcher.watchMutex.Unlock()
+	watch, ok := h.Watcher.watches[channel]
+	return ok && len(splitQuery(watch.query)) == 1
+}
+
+func (h *Handoff) report(err error) {
+	if h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// bookmarkPosition returns the RecordId a bookmark of a single channel is
+// at. Bookmarks of several channels, as from a query across channels, have
+// no position, as their RecordIds can't be compared.
+func bookmarkPosition(bookmark string) (uint64, bool) {
+	list, err := parseBookmarkXml(bookmark)
+	if err != nil || len(list.Bookmarks) != 1 {
+		return 0, false
+	}
+	return list.Bookmarks[0].RecordId, true
+}
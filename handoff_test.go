@@ -0,0 +1,240 @@
+package winlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	. "testing"
+	"time"
+)
+
+// xmlBookmarkAPI renders bookmarks as the event log does, at the RecordId
+// of the last event
+type xmlBookmarkAPI struct {
+	*fakeAPI
+}
+
+func (a xmlBookmarkAPI) RenderBookmark(bookmark BookmarkHandle) (string, error) {
+	recordId, _ := a.fakeAPI.RenderBookmark(bookmark)
+	return fmt.Sprintf("<BookmarkList><Bookmark Channel='Application' RecordId='%v' IsCurrent='true'/></BookmarkList>", recordId), nil
+}
+
+// flakySink fails its first `failures` writes
+type flakySink struct {
+	mutex    sync.Mutex
+	failures int
+	written  []uint64
+	writes   chan uint64
+}
+
+func (s *flakySink) Write(ev *WinLogEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("Unavailable")
+	}
+	s.written = append(s.written, ev.RecordId)
+	s.writes <- ev.RecordId
+	return nil
+}
+
+func (s *flakySink) Close() error {
+	return nil
+}
+
+func runHandoff(api EventLogAPI, store BookmarkStore, sink Sink, emit func(), wait int, t *T) *Handoff {
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	handoff := &Handoff{Watcher: watcher, Retry: ReconnectPolicy{InitialBackoff: time.Millisecond}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- handoff.Deliver(ctx, sink) }()
+	go emit()
+	for i := 0; i < wait; i++ {
+		select {
+		case <-sink.(*flakySink).writes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for write")
+		}
+	}
+	cancel()
+	assertEqual(<-done, context.Canceled, t)
+	return handoff
+}
+
+func TestHandoff(t *T) {
+	store := mapBookmarkStore{}
+	sink := &flakySink{failures: 2, writes: make(chan uint64, 10)}
+	api := xmlBookmarkAPI{newFakeAPI()}
+	emit := func(recordIds ...uint64) func() {
+		return func() {
+			for _, recordId := range recordIds {
+				api.emit("Application", fakeValues{EvtSystemChannel: "Application", EvtSystemEventRecordId: recordId})
+			}
+		}
+	}
+	handoff := runHandoff(api, store, sink, emit(1, 2, 3), 3, t)
+	assertEqual(fmt.Sprint(sink.written), "[1 2 3]", t)
+	assertEqual(handoff.Stats(), HandoffStats{Delivered: 3}, t)
+	position, _ := bookmarkPosition(store["Application"])
+	assertEqual(position, uint64(3), t)
+
+	// After a restart, events already acknowledged are skipped
+	api = xmlBookmarkAPI{newFakeAPI()}
+	handoff = runHandoff(api, store, sink, emit(2, 3, 4), 1, t)
+	assertEqual(fmt.Sprint(sink.written), "[1 2 3 4]", t)
+	assertEqual(handoff.Stats(), HandoffStats{Delivered: 1, Duplicates: 2}, t)
+	position, _ = bookmarkPosition(store["Application"])
+	assertEqual(position, uint64(4), t)
+}
+
+func TestHandoffGivesUp(t *T) {
+	store := mapBookmarkStore{}
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	var failures []error
+	handoff := &Handoff{
+		Watcher: watcher,
+		Retry:   ReconnectPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		OnError: func(err error) { failures = append(failures, err) },
+	}
+	sink := &flakySink{failures: 10, writes: make(chan uint64, 10)}
+	go api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(1)})
+	if err := handoff.Deliver(context.Background(), sink); err == nil {
+		t.Fatal("Expected an error once the attempts were used up")
+	}
+	assertEqual(len(failures), 2, t)
+	assertEqual(len(store), 0, t)
+
+	if err := (&Handoff{Watcher: &WinLogWatcher{}}).Deliver(context.Background(), sink); err == nil {
+		t.Error("Expected an error without a BookmarkStore")
+	}
+}
+
+func TestHandoffWindow(t *T) {
+	store := mapBookmarkStore{}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: newFakeAPI(), BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	handoff := &Handoff{Watcher: watcher, Window: 2, saved: map[string]uint64{}, recent: map[string]bool{}}
+	sink := &flakySink{writes: make(chan uint64, 10)}
+	// Bookmarks without a position are only deduplicated by the window
+	for _, recordId := range []uint64{1, 2, 1, 3, 1} {
+		ev := &WinLogEvent{Channel: "Application", SubscribedChannel: "Application", RecordId: recordId, Bookmark: "x"}
+		if err := handoff.handle(context.Background(), sink, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(fmt.Sprint(sink.written), "[1 2 3 1]", t)
+	assertEqual(handoff.Stats(), HandoffStats{Delivered: 4, Duplicates: 1}, t)
+	assertEqual(EventKey(&WinLogEvent{ComputerName: "host", Channel: "Security", RecordId: 7}), "host/Security/7", t)
+}
+
+func TestHandoffSplitQuery(t *T) {
+	store := mapBookmarkStore{}
+	api := xmlBookmarkAPI{newFakeAPI()}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	ids := make([]string, 30)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("EventID=%v", i+1)
+	}
+	if err := watcher.SubscribeFromNow("Application", "*[System[("+strings.Join(ids, " or ")+")]]"); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions := api.subscriptions("Application")
+	assertEqual(len(subscriptions), 2, t)
+
+	handoff := &Handoff{Watcher: watcher}
+	sink := &flakySink{writes: make(chan uint64, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- handoff.Deliver(ctx, sink) }()
+	// The second subscription's record 5 arrives before the first's record
+	// 3, which mustn't be taken for a duplicate
+	go func() {
+		api.emitTo(subscriptions[1], "Application", fakeValues{EvtSystemChannel: "Application", EvtSystemEventRecordId: uint64(5)})
+		api.emitTo(subscriptions[0], "Application", fakeValues{EvtSystemChannel: "Application", EvtSystemEventRecordId: uint64(3)})
+		api.emitTo(subscriptions[1], "Application", fakeValues{EvtSystemChannel: "Application", EvtSystemEventRecordId: uint64(5)})
+		api.emitTo(subscriptions[0], "Application", fakeValues{EvtSystemChannel: "Application", EvtSystemEventRecordId: uint64(6)})
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sink.writes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for write")
+		}
+	}
+	cancel()
+	assertEqual(<-done, context.Canceled, t)
+	assertEqual(fmt.Sprint(sink.written), "[5 3 6]", t)
+	assertEqual(handoff.Stats(), HandoffStats{Delivered: 3, Duplicates: 1}, t)
+}
+
+func TestHandoffChannelCleared(t *T) {
+	store := mapBookmarkStore{}
+	sink := &flakySink{writes: make(chan uint64, 10)}
+	api := xmlBookmarkAPI{newFakeAPI()}
+	emit := func(values ...fakeValues) func() {
+		return func() {
+			for _, v := range values {
+				v[EvtSystemChannel] = "Application"
+				api.emit("Application", v)
+			}
+		}
+	}
+	record := func(recordId uint64) fakeValues {
+		return fakeValues{EvtSystemEventRecordId: recordId}
+	}
+	// The channel is cleared after record 3, and its RecordIds restart
+	handoff := runHandoff(api, store, sink, emit(record(2), record(3), record(1), record(2)), 4, t)
+	assertEqual(fmt.Sprint(sink.written), "[2 3 1 2]", t)
+	assertEqual(handoff.Stats(), HandoffStats{Delivered: 4}, t)
+	position, _ := bookmarkPosition(store["Application"])
+	assertEqual(position, uint64(2), t)
+
+	// A restarted handoff finds the clearing event rather than a regression
+	store = mapBookmarkStore{"Security": "<BookmarkList><Bookmark Channel='Security' RecordId='9' IsCurrent='true'/></BookmarkList>"}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: newFakeAPI(), BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Security", "*"); err != nil {
+		t.Fatal(err)
+	}
+	handoff = &Handoff{Watcher: watcher, saved: map[string]uint64{}, last: map[string]uint64{}, recent: map[string]bool{}}
+	sink.written = nil
+	for _, recordId := range []uint64{1, 2} {
+		ev := &WinLogEvent{Channel: "Security", SubscribedChannel: "Security", RecordId: recordId,
+			Bookmark: fmt.Sprintf("<BookmarkList><Bookmark Channel='Security' RecordId='%v' IsCurrent='true'/></BookmarkList>", recordId)}
+		if recordId == 1 {
+			ev.ProviderName, ev.EventId = EventLogProviderName, EventIdSecurityCleared
+		}
+		if err := handoff.handle(context.Background(), sink, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(fmt.Sprint(sink.written), "[1 2]", t)
+	assertEqual(handoff.Stats(), HandoffStats{Delivered: 2}, t)
+}
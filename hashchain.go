@@ -0,0 +1,166 @@
+package winlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version of the encoding of events hashed by HashChain. The encoding of a
+// version never changes, so chains stay verifiable across releases.
+const HashChainVersion = 1
+
+// HashChain links delivered events into a tamper-evident chain. Each event's
+// ChainHash is the SHA-256 of the previous event's ChainHash followed by a
+// fixed encoding of the event's fields, so downstream storage can check with
+// VerifyHashChain that no event was altered, removed or reordered. Dropped
+// events are detectable too, as Sequence is part of the hashed bytes.
+//
+// Hashes are prefixed with the version of the encoding, as in "v1:...".
+// Version 1 covers Sequence, the System fields, Msg, EventData, UserData,
+// Xml and SuppressedCount. Fields added to WinLogEvent later aren't hashed
+// until a new version covers them.
+type HashChain struct {
+	mutex sync.Mutex
+	last  string
+}
+
+// NewHashChain starts a chain after the hash `previous`, which is "" for a
+// new chain or the Last hash of a chain to continue after a restart.
+func NewHashChain(previous string) *HashChain {
+	return &HashChain{last: previous}
+}
+
+// Last returns the ChainHash of the most recent event, to be saved so the
+// chain can be continued with NewHashChain.
+func (c *HashChain) Last() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.last
+}
+
+// Link sets the event's PrevChainHash and ChainHash, advancing the chain.
+func (c *HashChain) Link(ev *WinLogEvent) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	hash, err := chainHash(c.last, ev)
+	if err != nil {
+		return err
+	}
+	ev.PrevChainHash = c.last
+	ev.ChainHash = hash
+	c.last = hash
+	return nil
+}
+
+// unlink rolls the chain back to before an event it was linked with, if it
+// hasn't advanced since, as when the event couldn't be delivered.
+func (c *HashChain) unlink(ev *WinLogEvent) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if ev.ChainHash != "" && c.last == ev.ChainHash {
+		c.last = ev.PrevChainHash
+	}
+}
+
+// chainHash hashes the event onto `previous` with the current encoding.
+func chainHash(previous string, ev *WinLogEvent) (string, error) {
+	return chainHashVersion(HashChainVersion, previous, ev)
+}
+
+func chainHashVersion(version int, previous string, ev *WinLogEvent) (string, error) {
+	if version != 1 {
+		return "", fmt.Errorf("Unsupported hash chain version %v", version)
+	}
+	h := sha256.New()
+	h.Write([]byte(previous))
+	writeChainFieldsV1(h, ev)
+	return "v" + strconv.Itoa(version) + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// chainHashVersionOf returns the encoding version of a ChainHash.
+func chainHashVersionOf(hash string) (int, error) {
+	i := strings.Index(hash, ":")
+	if i < 2 || hash[0] != 'v' {
+		return 0, fmt.Errorf("Hash %q has no version", hash)
+	}
+	return strconv.Atoi(hash[1:i])
+}
+
+// writeChainFieldsV1 writes the fields hashed by version 1, each as its
+// name and length-prefixed value. Maps are written as their size followed
+// by their entries in key order, and times in UTC as RFC 3339.
+func writeChainFieldsV1(h hash.Hash, ev *WinLogEvent) {
+	field := func(name, value string) {
+		fmt.Fprintf(h, "%s:%d:%s\n", name, len(value), value)
+	}
+	number := func(name string, value uint64) {
+		field(name, strconv.FormatUint(value, 10))
+	}
+	values := func(name string, m map[string]string) {
+		number(name, uint64(len(m)))
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field("", k)
+			field("", m[k])
+		}
+	}
+	number("Sequence", ev.Sequence)
+	field("ComputerName", ev.ComputerName)
+	field("Channel", ev.Channel)
+	field("ProviderName", ev.ProviderName)
+	number("EventId", ev.EventId)
+	number("Qualifiers", ev.Qualifiers)
+	number("Version", ev.Version)
+	number("Level", ev.Level)
+	number("Task", ev.Task)
+	number("Opcode", ev.Opcode)
+	number("KeywordsMask", ev.KeywordsMask)
+	field("Created", ev.Created.UTC().Format(time.RFC3339Nano))
+	number("RecordId", ev.RecordId)
+	number("ProcessId", ev.ProcessId)
+	number("ThreadId", ev.ThreadId)
+	field("ActivityId", ev.ActivityId)
+	field("RelatedActivityId", ev.RelatedActivityId)
+	field("UserSid", ev.UserSid)
+	field("Msg", ev.Msg)
+	values("EventData", ev.EventData)
+	values("UserData", ev.UserData)
+	field("Xml", string(ev.Xml))
+	number("SuppressedCount", ev.SuppressedCount)
+}
+
+// VerifyHashChain checks that the events form an unbroken chain starting
+// after the hash `previous`. It returns the index of the first event which
+// doesn't link to the one before or whose ChainHash doesn't match its
+// contents, and a descriptive error, or -1 and nil if the chain is intact.
+func VerifyHashChain(previous string, events []*WinLogEvent) (int, error) {
+	for i, ev := range events {
+		if ev.PrevChainHash != previous {
+			return i, fmt.Errorf("Event %v follows hash %q, expected %q", i, ev.PrevChainHash, previous)
+		}
+		version, err := chainHashVersionOf(ev.ChainHash)
+		if err != nil {
+			return i, fmt.Errorf("Event %v: %v", i, err)
+		}
+		hash, err := chainHashVersion(version, previous, ev)
+		if err != nil {
+			return i, fmt.Errorf("Event %v: %v", i, err)
+		}
+		if ev.ChainHash != hash {
+			return i, fmt.Errorf("Event %v has hash %q, but its contents hash to %q", i, ev.ChainHash, hash)
+		}
+		previous = hash
+	}
+	return -1, nil
+}
@@ -0,0 +1,156 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+	"time"
+)
+
+func TestHashChain(t *T) {
+	chain := NewHashChain("")
+	var events []*WinLogEvent
+	for i := uint64(1); i <= 3; i++ {
+		ev := &WinLogEvent{EventId: 4624, RecordId: i, Sequence: i, EventData: map[string]string{"TargetUserName": "alice"}}
+		if err := chain.Link(ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	assertEqual(events[0].PrevChainHash, "", t)
+	assertEqual(events[1].PrevChainHash, events[0].ChainHash, t)
+	assertEqual(chain.Last(), events[2].ChainHash, t)
+	if i, err := VerifyHashChain("", events); err != nil {
+		t.Fatalf("Chain broken at %v: %v", i, err)
+	}
+
+	// The chain survives a round trip through CanonicalJSON
+	var decoded []*WinLogEvent
+	for _, ev := range events {
+		data, err := CanonicalJSON(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m struct {
+			*WinLogEvent
+			Xml string
+		}
+		m.WinLogEvent = &WinLogEvent{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, m.WinLogEvent)
+	}
+	if i, err := VerifyHashChain("", decoded); err != nil {
+		t.Fatalf("Decoded chain broken at %v: %v", i, err)
+	}
+
+	// Continuing after a restart
+	next := &WinLogEvent{EventId: 4634, Sequence: 1}
+	NewHashChain(chain.Last()).Link(next)
+	if _, err := VerifyHashChain("", append(events, next)); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *events[1]
+	tampered.EventData = map[string]string{"TargetUserName": "mallory"}
+	if i, _ := VerifyHashChain("", []*WinLogEvent{events[0], &tampered, events[2]}); i != 1 {
+		t.Fatalf("Expected tampering at 1, got %v", i)
+	}
+	if i, _ := VerifyHashChain("", []*WinLogEvent{events[0], events[2]}); i != 1 {
+		t.Fatalf("Expected gap at 1, got %v", i)
+	}
+}
+
+func TestWatcherHashChain(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.HashChain = NewHashChain("")
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for id := uint64(1); id <= 2; id++ {
+			api.emit("Application", fakeValues{EvtSystemEventID: id, EvtSystemEventRecordId: id})
+		}
+	}()
+	var events []*WinLogEvent
+	for len(events) < 2 {
+		select {
+		case ev := <-watcher.Event():
+			events = append(events, ev)
+		case err := <-watcher.Error():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("No event published")
+		}
+	}
+	if i, err := VerifyHashChain("", events); err != nil {
+		t.Fatalf("Chain broken at %v: %v", i, err)
+	}
+	assertEqual(watcher.HashChain.Last(), events[1].ChainHash, t)
+}
+
+func TestWatcherHashChainShutdown(t *T) {
+	watcher, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.HashChain = NewHashChain("start")
+	delivered := make(chan bool)
+	go func() { delivered <- watcher.deliver(&WinLogEvent{RecordId: 1}, nil) }()
+	for watcher.HashChain.Last() == "start" {
+		time.Sleep(time.Millisecond)
+	}
+	// Nothing was subscribed, so there's nothing else to shut down
+	close(watcher.shutdown)
+	assertEqual(<-delivered, false, t)
+	// The undelivered event isn't left at the end of the chain
+	assertEqual(watcher.HashChain.Last(), "start", t)
+}
+
+// The version 1 encoding must never change, or chains written by earlier
+// releases would stop verifying
+func TestHashChainGolden(t *T) {
+	ev := &WinLogEvent{
+		Sequence:     7,
+		ComputerName: "dc01.contoso.com",
+		Channel:      "Security",
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4624,
+		Version:      2,
+		KeywordsMask: 0x8020000000000000,
+		Created:      time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.FixedZone("", 3600)),
+		RecordId:     1234,
+		ProcessId:    640,
+		ThreadId:     712,
+		ActivityId:   "{6A9F0F6E-8B1E-4C57-9A5E-000000000001}",
+		Msg:          "An account was successfully logged on.",
+		EventData:    map[string]string{"TargetUserName": "alice", "LogonType": "3"},
+		Xml:          []byte("<Event/>"),
+		// Not covered by version 1
+		Bookmark:   "<BookmarkList/>",
+		ReceivedAt: time.Now(),
+	}
+	first, err := chainHash("", ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(first, "v1:11aed23cb465103263a55595a180fd4ee38f776c1c63244aa73a669acfdd406b", t)
+	second, err := chainHash(first, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(second, "v1:a258ef019f7636c58906632cd9e8579e3935dd67303608be567e9dc3d0e65ee4", t)
+
+	if _, err := chainHashVersionOf("0123abcd"); err == nil {
+		t.Error("Expected an error for a hash without a version")
+	}
+	ev.PrevChainHash, ev.ChainHash = "", "v2:"+first[3:]
+	if i, err := VerifyHashChain("", []*WinLogEvent{ev}); i != 0 || err == nil {
+		t.Errorf("Expected an unsupported version at 0, got %v: %v", i, err)
+	}
+}
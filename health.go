@@ -0,0 +1,125 @@
+package winlog
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Subscription states reported in health reports
+const (
+	SubscriptionRunning = "running"
+	// Paused by its rate limit
+	SubscriptionPaused = "paused"
+)
+
+// HealthReport summarises the state of a watcher for agents' debug servers
+type HealthReport struct {
+	// False if any subscription isn't running, the watcher's remote
+	// session isn't connected, or the consumer is slow
+	Healthy       bool
+	Subscriptions map[string]SubscriptionHealth
+	// State of the watcher's remote session, if it uses a RemoteAPI
+	RemoteSession *RemoteSessionStats `json:",omitempty"`
+	// The consumer's current stall, if the watcher's
+	// SlowConsumerThreshold is set
+	SlowConsumer *SlowConsumer `json:",omitempty"`
+	// Last error published on the Error channel
+	LastError     string `json:",omitempty"`
+	LastErrorTime time.Time
+	// Notifications discarded because nothing read them from
+	// Notifications
+	NotificationsDiscarded uint64
+}
+
+type SubscriptionHealth struct {
+	State string
+	SubscriptionStats
+}
+
+// Health returns a report on the watcher and each of its subscriptions.
+func (self *WinLogWatcher) Health() HealthReport {
+	report := HealthReport{Healthy: true, Subscriptions: make(map[string]SubscriptionHealth)}
+	var session *RemoteSessionStats
+	if remote, ok := self.api.(remoteStateNotifier); ok {
+		stats := remote.SessionStats()
+		session = &stats
+		report.RemoteSession = session
+		report.Healthy = stats.State == RemoteConnected
+	}
+	self.watchMutex.Lock()
+	for channel, watch := range self.watches {
+		state := SubscriptionRunning
+		if watch.paused {
+			state = SubscriptionPaused
+			report.Healthy = false
+		}
+		stats := watch.stats.snapshot()
+		stats.SplitBookmarkLag = splitLag(watch)
+		stats.RemoteSession = session
+		report.Subscriptions[channel] = SubscriptionHealth{State: state, SubscriptionStats: stats}
+	}
+	self.watchMutex.Unlock()
+	if report.SlowConsumer = self.currentSlowConsumer(); report.SlowConsumer != nil {
+		report.Healthy = false
+	}
+
+	self.lastErrorMutex.Lock()
+	if self.lastError != nil {
+		report.LastError = self.lastError.Error()
+		report.LastErrorTime = self.lastErrorTime
+	}
+	self.lastErrorMutex.Unlock()
+	report.NotificationsDiscarded = atomic.LoadUint64(&self.notificationsDiscarded)
+	return report
+}
+
+func (self *WinLogWatcher) recordError(err error) {
+	self.lastErrorMutex.Lock()
+	self.lastError = err
+	self.lastErrorTime = time.Now()
+	self.lastErrorMutex.Unlock()
+}
+
+// HealthHandler returns an http.Handler serving the Health report as JSON,
+// with status 503 if the watcher is unhealthy.
+func (self *WinLogWatcher) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := self.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Watchers whose reports are published by PublishExpvar, by name
+var (
+	expvarMutex    sync.Mutex
+	expvarWatchers = make(map[string]*WinLogWatcher)
+)
+
+// PublishExpvar publishes the Health report as an expvar variable, so it is
+// served by the expvar handler at /debug/vars. Publishing another watcher
+// under the same name, such as one recreated after reconfiguration, replaces
+// the report, since expvar can't unpublish a variable. Like expvar.Publish,
+// it panics if the name is in use by a variable not published here.
+func (self *WinLogWatcher) PublishExpvar(name string) {
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+	if _, ok := expvarWatchers[name]; ok {
+		expvarWatchers[name] = self
+		return
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		expvarMutex.Lock()
+		watcher := expvarWatchers[name]
+		expvarMutex.Unlock()
+		return watcher.Health()
+	}))
+	expvarWatchers[name] = self
+}
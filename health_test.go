@@ -0,0 +1,84 @@
+package winlog
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	. "testing"
+)
+
+func newHealthWatcher(t *T) *WinLogWatcher {
+	watcher, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(watcher.Shutdown)
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	return watcher
+}
+
+func getHealth(t *T, handler http.Handler) (int, map[string]interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health", nil))
+	assertEqual(recorder.Header().Get("Content-Type"), "application/json", t)
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, body
+}
+
+func TestHealthHandler(t *T) {
+	watcher := newHealthWatcher(t)
+	code, body := getHealth(t, watcher.HealthHandler())
+	assertEqual(code, http.StatusOK, t)
+	assertEqual(body["Healthy"], true, t)
+	// Optional sections are omitted for a local watcher without errors
+	for _, key := range []string{"RemoteSession", "SlowConsumer", "LastError"} {
+		if _, ok := body[key]; ok {
+			t.Fatalf("Unexpected %v in %v", key, body)
+		}
+	}
+	subscriptions := body["Subscriptions"].(map[string]interface{})
+	assertEqual(len(subscriptions), 1, t)
+	application := subscriptions["Application"].(map[string]interface{})
+	assertEqual(application["State"], SubscriptionRunning, t)
+	// Stats are embedded alongside the state
+	assertEqual(application["Delivered"], float64(0), t)
+}
+
+func TestHealthHandlerUnhealthy(t *T) {
+	watcher := newHealthWatcher(t)
+	watcher.watchMutex.Lock()
+	watcher.watches["Application"].paused = true
+	watcher.watchMutex.Unlock()
+
+	code, body := getHealth(t, watcher.HealthHandler())
+	assertEqual(code, http.StatusServiceUnavailable, t)
+	assertEqual(body["Healthy"], false, t)
+	application := body["Subscriptions"].(map[string]interface{})["Application"].(map[string]interface{})
+	assertEqual(application["State"], SubscriptionPaused, t)
+}
+
+func TestPublishExpvarTwice(t *T) {
+	first := newHealthWatcher(t)
+	second := newHealthWatcher(t)
+	second.watchMutex.Lock()
+	second.watches["Application"].paused = true
+	second.watchMutex.Unlock()
+
+	first.PublishExpvar("gowinlog_test_health")
+	// Publishing again under the same name replaces the report rather
+	// than panicking
+	second.PublishExpvar("gowinlog_test_health")
+	var report HealthReport
+	if err := json.Unmarshal([]byte(expvar.Get("gowinlog_test_health").String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(report.Healthy, false, t)
+	assertEqual(report.Subscriptions["Application"].State, SubscriptionPaused, t)
+}
@@ -0,0 +1,33 @@
+package winlog
+
+// HostInfo identifies the computer the watcher runs on, which the
+// ComputerName of events often only gives as a short name.
+type HostInfo struct {
+	// Fully qualified DNS name, such as ws01.corp.example.com
+	FQDN string
+	// NetBIOS name, such as WS01
+	NetBIOSName string
+	// Domain the computer is joined to, or its workgroup if it isn't
+	Domain       string
+	DomainJoined bool
+	// Windows version, as major.minor.build, such as 10.0.19045, and the
+	// product name, such as "Windows 10 Enterprise"
+	OSVersion string
+	OSName    string
+}
+
+// host returns the HostInfo to stamp events with: Host, or if EnrichHost
+// is set, this computer's read once by GetHostInfo.
+func (self *WinLogWatcher) host() *HostInfo {
+	if self.Host != nil || !self.EnrichHost {
+		return self.Host
+	}
+	self.hostInfoOnce.Do(func() {
+		info, err := GetHostInfo()
+		if err != nil {
+			self.log(LogWarn, "Failed to get host info", "error", err)
+		}
+		self.hostInfo = info
+	})
+	return self.hostInfo
+}
@@ -0,0 +1,37 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestWatcherHostInfo(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := &HostInfo{FQDN: "ws01.corp.example.com", NetBIOSName: "WS01", Domain: "corp.example.com", DomainJoined: true, OSVersion: "10.0.19045"}
+	watcher.Host = host
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+		EvtSystemComputer:      "WS01",
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.Host, host, t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	watcher.Shutdown()
+
+	// Without Host or EnrichHost, events aren't stamped
+	assertEqual((&WinLogWatcher{}).host() == nil, true, t)
+}
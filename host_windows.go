@@ -0,0 +1,70 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// GetHostInfo reads this computer's names, domain or workgroup and Windows
+// version. Parts which can't be read are left empty, and the first error
+// is returned along with the rest.
+func GetHostInfo() (*HostInfo, error) {
+	info := &HostInfo{}
+	var firstErr error
+	check := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	var err error
+	info.FQDN, err = computerName(windows.ComputerNameDnsFullyQualified)
+	check(err)
+	info.NetBIOSName, err = computerName(windows.ComputerNameNetBIOS)
+	check(err)
+
+	var domain *uint16
+	var joinStatus uint32
+	if err := windows.NetGetJoinInformation(nil, &domain, &joinStatus); err != nil {
+		check(fmt.Errorf("Failed to get domain: %v", err))
+	} else {
+		info.Domain = windows.UTF16PtrToString(domain)
+		info.DomainJoined = joinStatus == windows.NetSetupDomainName
+		windows.NetApiBufferFree((*byte)(unsafe.Pointer(domain)))
+	}
+	if info.DomainJoined {
+		// The DNS name of the domain rather than its NetBIOS name
+		if dnsDomain, err := computerName(windows.ComputerNameDnsDomain); err == nil && dnsDomain != "" {
+			info.Domain = dnsDomain
+		}
+	}
+
+	version := windows.RtlGetVersion()
+	info.OSVersion = fmt.Sprintf("%v.%v.%v", version.MajorVersion, version.MinorVersion, version.BuildNumber)
+	if key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE); err == nil {
+		info.OSName, _, _ = key.GetStringValue("ProductName")
+		key.Close()
+	}
+	return info, firstErr
+}
+
+// computerName wraps GetComputerNameExW.
+func computerName(format uint32) (string, error) {
+	n := uint32(64)
+	for {
+		buf := make([]uint16, n)
+		err := windows.GetComputerNameEx(format, &buf[0], &n)
+		if err == nil {
+			return syscall.UTF16ToString(buf[:n]), nil
+		}
+		if err != windows.ERROR_MORE_DATA || int(n) <= len(buf) {
+			return "", fmt.Errorf("Failed to get computer name: %v", err)
+		}
+	}
+}
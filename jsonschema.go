@@ -0,0 +1,124 @@
+package winlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema describes the EventData of a provider's events as a JSON
+// Schema, with one definition under "$defs" for each event and version, such
+// as "Event_4624_v2". Properties have the JSON type matching the parameter's
+// template type, with the manifest types in "x-inType" and "x-outType".
+// WinLogEvent.EventData holds every value as the string rendered by the
+// event log, so integers, for example, need converting before the schema
+// applies to them.
+func JSONSchema(providerName string, templates EventTemplates) ([]byte, error) {
+	keys := make([]TemplateKey, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].EventId != keys[j].EventId {
+			return keys[i].EventId < keys[j].EventId
+		}
+		return keys[i].Version < keys[j].Version
+	})
+
+	defs := make(map[string]interface{}, len(keys))
+	events := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		name := fmt.Sprintf("Event_%d_v%d", key.EventId, key.Version)
+		def := objectSchema(templates[key])
+		def["title"] = fmt.Sprintf("Event %d version %d", key.EventId, key.Version)
+		def["x-eventId"] = key.EventId
+		def["x-version"] = key.Version
+		defs[name] = def
+		events = append(events, map[string]interface{}{"$ref": "#/$defs/" + name})
+	}
+	schema := map[string]interface{}{
+		"$schema": jsonSchemaDraft,
+		"title":   providerName + " EventData",
+		"$defs":   defs,
+	}
+	if len(events) > 0 {
+		schema["anyOf"] = events
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func objectSchema(fields []TemplateField) map[string]interface{} {
+	properties := make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		name := field.Name
+		if name == "" {
+			// Matches the names given to unnamed <Data> elements
+			name = fmt.Sprintf("Data%d", i)
+		}
+		properties[name] = fieldSchema(field)
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func fieldSchema(field TemplateField) map[string]interface{} {
+	var schema map[string]interface{}
+	if len(field.Fields) > 0 {
+		schema = objectSchema(field.Fields)
+	} else {
+		schema = valueSchema(field.InType, field.OutType)
+		if field.InType != "" {
+			schema["x-inType"] = field.InType
+		}
+		if field.OutType != "" {
+			schema["x-outType"] = field.OutType
+		}
+	}
+	if field.Count == "" {
+		return schema
+	}
+	array := map[string]interface{}{"type": "array", "items": schema}
+	if _, err := strconv.ParseUint(field.Count, 10, 32); err == nil {
+		array["x-count"] = field.Count
+	} else {
+		array["x-countField"] = field.Count
+	}
+	return array
+}
+
+const (
+	hexPattern  = "^0[xX][0-9a-fA-F]+$"
+	guidPattern = "^\\{[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\\}$"
+)
+
+// valueSchema returns the schema of a template type, as the event log
+// renders it in XML.
+func valueSchema(inType, outType string) map[string]interface{} {
+	// Hexadecimal output is rendered as a string whatever the input type
+	if strings.HasPrefix(outType, "win:HexInt") {
+		return map[string]interface{}{"type": "string", "pattern": hexPattern}
+	}
+	switch inType {
+	case "win:Int8", "win:UInt8", "win:Int16", "win:UInt16", "win:Int32", "win:UInt32", "win:Int64", "win:UInt64":
+		return map[string]interface{}{"type": "integer"}
+	case "win:HexInt32", "win:HexInt64", "win:Pointer":
+		return map[string]interface{}{"type": "string", "pattern": hexPattern}
+	case "win:Float", "win:Double":
+		return map[string]interface{}{"type": "number"}
+	case "win:Boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case "win:FILETIME", "win:SYSTEMTIME":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "win:GUID":
+		return map[string]interface{}{"type": "string", "pattern": guidPattern}
+	case "win:Binary":
+		return map[string]interface{}{"type": "string", "contentEncoding": "base16"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,89 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+)
+
+func TestJSONSchema(t *T) {
+	fields, err := ParseEventTemplate(`<template xmlns="http://schemas.microsoft.com/win/2004/08/events">
+  <data name="TargetUserName" inType="win:UnicodeString" outType="xs:string"/>
+  <data name="LogonType" inType="win:UInt32" outType="xs:unsignedInt"/>
+  <data name="TargetLogonId" inType="win:HexInt64" outType="win:HexInt64"/>
+  <data name="Status" inType="win:UInt32" outType="win:HexInt32"/>
+  <data name="Created" inType="win:FILETIME" outType="xs:dateTime"/>
+  <data name="Count" inType="win:UInt16" outType="xs:unsignedShort"/>
+  <struct name="Entries" count="Count">
+    <data name="Elevated" inType="win:Boolean" outType="xs:boolean"/>
+  </struct>
+</template>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := EventTemplates{
+		{EventId: 4624, Version: 2}: fields,
+		{EventId: 7036}:             {{Name: "param1", InType: "win:UnicodeString"}, {InType: "win:GUID"}},
+	}
+	out, err := JSONSchema("Test-Provider", templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Schema string `json:"$schema"`
+		Title  string
+		AnyOf  []map[string]string
+		Defs   map[string]struct {
+			XEventId   uint64 `json:"x-eventId"`
+			Properties map[string]struct {
+				Type    string
+				Pattern string
+				Format  string
+				XInType string `json:"x-inType"`
+				XCount  string `json:"x-countField"`
+				Items   struct {
+					Type       string
+					Properties map[string]struct{ Type string }
+				}
+			}
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(schema.Schema, jsonSchemaDraft, t)
+	assertEqual(schema.Title, "Test-Provider EventData", t)
+	assertEqual(len(schema.AnyOf), 2, t)
+	assertEqual(schema.AnyOf[0]["$ref"], "#/$defs/Event_4624_v2", t)
+	assertEqual(schema.AnyOf[1]["$ref"], "#/$defs/Event_7036_v0", t)
+
+	logon := schema.Defs["Event_4624_v2"]
+	assertEqual(logon.XEventId, uint64(4624), t)
+	assertEqual(logon.Properties["TargetUserName"].Type, "string", t)
+	assertEqual(logon.Properties["LogonType"].Type, "integer", t)
+	assertEqual(logon.Properties["LogonType"].XInType, "win:UInt32", t)
+	assertEqual(logon.Properties["TargetLogonId"].Pattern, hexPattern, t)
+	assertEqual(logon.Properties["Status"].Type, "string", t)
+	assertEqual(logon.Properties["Created"].Format, "date-time", t)
+	assertEqual(logon.Properties["Entries"].Type, "array", t)
+	assertEqual(logon.Properties["Entries"].XCount, "Count", t)
+	assertEqual(logon.Properties["Entries"].Items.Type, "object", t)
+	assertEqual(logon.Properties["Entries"].Items.Properties["Elevated"].Type, "boolean", t)
+
+	service := schema.Defs["Event_7036_v0"]
+	assertEqual(service.Properties["param1"].Type, "string", t)
+	assertEqual(service.Properties["Data1"].Pattern, guidPattern, t)
+}
+
+func TestJSONSchemaEmpty(t *T) {
+	out, err := JSONSchema("Classic", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatal(err)
+	}
+	_, ok := schema["anyOf"]
+	assertEqual(ok, false, t)
+}
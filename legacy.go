@@ -0,0 +1,279 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+/* Parsing of the EVENTLOGRECORD structures returned by the legacy Event
+   Logging API's ReadEventLogW, and their conversion to WinLogEvents. */
+
+// LegacyReader reads a classic event log, such as Application or a custom
+// log registered with RegisterEventSource, with the legacy Event Logging
+// API rather than wevtapi. It's a fallback for systems and logs where
+// wevtapi rendering fails. Its events have the same fields the watcher
+// renders, besides OpcodeText, ChannelText and IdText, with the insertion
+// strings in EventData as "Data0", "Data1" and so on.
+type LegacyReader struct {
+	Log string
+
+	handle  uintptr
+	buf     []byte
+	pending []*eventLogRecord
+	// Record number the next read starts at, or 0 to continue from the
+	// last record read
+	seek uint32
+	// Message files of each source, loaded when first needed
+	sources map[string]*legacySource
+}
+
+// legacySource is the message files of an event source, loaded as data
+// files to format its messages and categories.
+type legacySource struct {
+	eventFiles    []uintptr
+	categoryFiles []uintptr
+}
+
+// Size of the fixed part of an EVENTLOGRECORD
+const eventLogRecordHeaderSize = 56
+
+// Signature of an EVENTLOGRECORD: "LfLe"
+const eventLogRecordSignature = 0x654c664c
+
+// Keyword wevtapi gives events of classic providers
+const keywordClassic uint64 = 0x80000000000000
+
+// eventLogRecord is a decoded EVENTLOGRECORD.
+type eventLogRecord struct {
+	RecordNumber  uint32
+	TimeGenerated uint32
+	TimeWritten   uint32
+	EventID       uint32
+	EventType     uint16
+	EventCategory uint16
+	SourceName    string
+	ComputerName  string
+	UserSid       string
+	Strings       []string
+	Data          []byte
+}
+
+// parseEventLogRecords decodes the records in a buffer filled in by
+// ReadEventLogW.
+func parseEventLogRecords(buf []byte) ([]*eventLogRecord, error) {
+	var records []*eventLogRecord
+	for len(buf) > 0 {
+		record, length, err := parseEventLogRecord(buf)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+		buf = buf[length:]
+	}
+	return records, nil
+}
+
+func parseEventLogRecord(buf []byte) (*eventLogRecord, int, error) {
+	if len(buf) < eventLogRecordHeaderSize {
+		return nil, 0, fmt.Errorf("EVENTLOGRECORD is too short, %v bytes", len(buf))
+	}
+	le := binary.LittleEndian
+	// Lengths and offsets are compared as uint64, as converting them to int
+	// could overflow on 32-bit platforms
+	length := uint64(le.Uint32(buf[0:]))
+	if length < eventLogRecordHeaderSize || length > uint64(len(buf)) {
+		return nil, 0, fmt.Errorf("EVENTLOGRECORD has invalid length %v", length)
+	}
+	if le.Uint32(buf[4:]) != eventLogRecordSignature {
+		return nil, 0, fmt.Errorf("EVENTLOGRECORD has invalid signature 0x%x", le.Uint32(buf[4:]))
+	}
+	buf = buf[:length]
+	record := &eventLogRecord{
+		RecordNumber:  le.Uint32(buf[8:]),
+		TimeGenerated: le.Uint32(buf[12:]),
+		TimeWritten:   le.Uint32(buf[16:]),
+		EventID:       le.Uint32(buf[20:]),
+		EventType:     le.Uint16(buf[24:]),
+		EventCategory: le.Uint16(buf[28:]),
+	}
+	numStrings := int(le.Uint16(buf[26:]))
+	stringOffset := uint64(le.Uint32(buf[36:]))
+	sidLength := uint64(le.Uint32(buf[40:]))
+	sidOffset := uint64(le.Uint32(buf[44:]))
+	dataLength := uint64(le.Uint32(buf[48:]))
+	dataOffset := uint64(le.Uint32(buf[52:]))
+
+	// The source and computer names follow the fixed part
+	offset := eventLogRecordHeaderSize
+	record.SourceName, offset = wideStringAt(buf, offset)
+	record.ComputerName, _ = wideStringAt(buf, offset)
+
+	if sidLength > 0 {
+		if sidOffset+sidLength > length {
+			return nil, 0, fmt.Errorf("EVENTLOGRECORD %v has its SID outside the record", record.RecordNumber)
+		}
+		sid, err := sidString(buf[sidOffset : sidOffset+sidLength])
+		if err != nil {
+			return nil, 0, err
+		}
+		record.UserSid = sid
+	}
+	if numStrings > 0 {
+		if stringOffset > length {
+			return nil, 0, fmt.Errorf("EVENTLOGRECORD %v has its strings outside the record", record.RecordNumber)
+		}
+		offset := int(stringOffset)
+		for i := 0; i < numStrings; i++ {
+			var s string
+			s, offset = wideStringAt(buf, offset)
+			record.Strings = append(record.Strings, s)
+		}
+	}
+	if dataLength > 0 {
+		if dataOffset+dataLength > length {
+			return nil, 0, fmt.Errorf("EVENTLOGRECORD %v has its data outside the record", record.RecordNumber)
+		}
+		record.Data = append([]byte(nil), buf[dataOffset:dataOffset+dataLength]...)
+	}
+	return record, int(length), nil
+}
+
+// wideStringAt reads the NUL terminated UTF-16 string at `offset`, returning
+// it and the offset after its terminator.
+func wideStringAt(buf []byte, offset int) (string, int) {
+	var wide []uint16
+	for ; offset+1 < len(buf); offset += 2 {
+		c := binary.LittleEndian.Uint16(buf[offset:])
+		if c == 0 {
+			return string(utf16.Decode(wide)), offset + 2
+		}
+		wide = append(wide, c)
+	}
+	return string(utf16.Decode(wide)), len(buf)
+}
+
+// sidString converts a binary SID to its string form, such as "S-1-5-18".
+func sidString(sid []byte) (string, error) {
+	if len(sid) < 8 || len(sid) < 8+4*int(sid[1]) {
+		return "", fmt.Errorf("Invalid SID of %v bytes", len(sid))
+	}
+	var authority uint64
+	for _, b := range sid[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+	s := fmt.Sprintf("S-%d-%d", sid[0], authority)
+	for i := 0; i < int(sid[1]); i++ {
+		s += fmt.Sprintf("-%d", binary.LittleEndian.Uint32(sid[8+4*i:]))
+	}
+	return s, nil
+}
+
+// Inserts in message strings, with optional printf style format
+// specifications, such as "%1" and "%2!s!"
+var messageInsertPattern = regexp.MustCompile(`%(\d+)(?:![^!]*!)?`)
+
+// formatInserts replaces the inserts in a message with the event's strings.
+// Inserts for strings the event doesn't have are left as they are.
+func formatInserts(message string, inserts []string) string {
+	return messageInsertPattern.ReplaceAllStringFunc(message, func(insert string) string {
+		index, err := strconv.Atoi(messageInsertPattern.FindStringSubmatch(insert)[1])
+		if err != nil || index < 1 || index > len(inserts) {
+			return insert
+		}
+		return inserts[index-1]
+	})
+}
+
+// event converts the record to a WinLogEvent with the fields wevtapi would
+// render for a classic event. Audit events have level 0 and the audit
+// keywords, as they do in the Security log.
+func (r *eventLogRecord) event(log string) *WinLogEvent {
+	ev := &WinLogEvent{
+		ProviderName:      r.SourceName,
+		EventId:           uint64(r.EventID & 0xFFFF),
+		Qualifiers:        uint64(r.EventID >> 16),
+		Task:              uint64(r.EventCategory),
+		Created:           time.Unix(int64(r.TimeGenerated), 0),
+		RecordId:          uint64(r.RecordNumber),
+		Channel:           log,
+		ComputerName:      r.ComputerName,
+		KeywordsMask:      keywordClassic,
+		UserSid:           r.UserSid,
+		SubscribedChannel: log,
+	}
+	switch r.EventType {
+	case EventTypeError:
+		ev.Level, ev.LevelText = LevelError, "Error"
+	case EventTypeWarning:
+		ev.Level, ev.LevelText = LevelWarning, "Warning"
+	case EventTypeAuditSuccess:
+		ev.Level, ev.LevelText = LevelLogAlways, "Information"
+		ev.KeywordsMask |= KeywordAuditSuccess
+		ev.Keywords = "Audit Success"
+	case EventTypeAuditFailure:
+		ev.Level, ev.LevelText = LevelLogAlways, "Information"
+		ev.KeywordsMask |= KeywordAuditFailure
+		ev.Keywords = "Audit Failure"
+	default:
+		ev.Level, ev.LevelText = LevelInformation, "Information"
+	}
+	ev.Outcome = DeriveOutcome(ev.KeywordsMask, ev.Level)
+	if len(r.Strings) > 0 {
+		ev.EventData = make(map[string]string, len(r.Strings))
+		for i, s := range r.Strings {
+			ev.EventData["Data"+strconv.Itoa(i)] = s
+		}
+	}
+	if len(r.Data) > 0 {
+		ev.Binary = r.Data
+	}
+	ev.Xml = r.xml(ev)
+	return ev
+}
+
+// Format of SystemTime attributes in event XML
+const systemTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+// xml renders the record the way wevtapi renders classic events, with
+// unnamed <Data> elements and the binary data in <Binary>.
+func (r *eventLogRecord) xml(ev *WinLogEvent) []byte {
+	var buf bytes.Buffer
+	text := func(s string) string {
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(s))
+		return escaped.String()
+	}
+	buf.WriteString("<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System>")
+	fmt.Fprintf(&buf, "<Provider Name='%s'/>", text(ev.ProviderName))
+	fmt.Fprintf(&buf, "<EventID Qualifiers='%d'>%d</EventID>", ev.Qualifiers, ev.EventId)
+	fmt.Fprintf(&buf, "<Level>%d</Level><Task>%d</Task><Keywords>0x%x</Keywords>", ev.Level, ev.Task, ev.KeywordsMask)
+	fmt.Fprintf(&buf, "<TimeCreated SystemTime='%s'/>", ev.Created.UTC().Format(systemTimeFormat))
+	fmt.Fprintf(&buf, "<EventRecordID>%d</EventRecordID>", ev.RecordId)
+	fmt.Fprintf(&buf, "<Channel>%s</Channel><Computer>%s</Computer>", text(ev.Channel), text(ev.ComputerName))
+	if ev.UserSid != "" {
+		fmt.Fprintf(&buf, "<Security UserID='%s'/>", text(ev.UserSid))
+	} else {
+		buf.WriteString("<Security/>")
+	}
+	buf.WriteString("</System>")
+	if len(r.Strings) > 0 || len(r.Data) > 0 {
+		buf.WriteString("<EventData>")
+		for _, s := range r.Strings {
+			fmt.Fprintf(&buf, "<Data>%s</Data>", text(s))
+		}
+		if len(r.Data) > 0 {
+			fmt.Fprintf(&buf, "<Binary>%s</Binary>", strings.ToUpper(hex.EncodeToString(r.Data)))
+		}
+		buf.WriteString("</EventData>")
+	}
+	buf.WriteString("</Event>")
+	return buf.Bytes()
+}
@@ -0,0 +1,142 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	. "testing"
+	"time"
+	"unicode/utf16"
+)
+
+// eventLogRecordBytes builds an EVENTLOGRECORD as ReadEventLogW returns it.
+func eventLogRecordBytes(record *eventLogRecord, sid []byte) []byte {
+	wide := func(s string) []byte {
+		var b bytes.Buffer
+		binary.Write(&b, binary.LittleEndian, append(utf16.Encode([]rune(s)), 0))
+		return b.Bytes()
+	}
+	var body bytes.Buffer
+	body.Write(wide(record.SourceName))
+	body.Write(wide(record.ComputerName))
+	sidOffset := eventLogRecordHeaderSize + body.Len()
+	body.Write(sid)
+	stringOffset := eventLogRecordHeaderSize + body.Len()
+	for _, s := range record.Strings {
+		body.Write(wide(s))
+	}
+	dataOffset := eventLogRecordHeaderSize + body.Len()
+	body.Write(record.Data)
+	for body.Len()%4 != 0 {
+		body.WriteByte(0)
+	}
+	length := eventLogRecordHeaderSize + body.Len() + 4
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	for _, v := range []interface{}{
+		uint32(length), uint32(eventLogRecordSignature), record.RecordNumber,
+		record.TimeGenerated, record.TimeWritten, record.EventID,
+		record.EventType, uint16(len(record.Strings)), record.EventCategory, uint16(0),
+		uint32(0), uint32(stringOffset), uint32(len(sid)), uint32(sidOffset),
+		uint32(len(record.Data)), uint32(dataOffset),
+	} {
+		binary.Write(&buf, le, v)
+	}
+	buf.Write(body.Bytes())
+	binary.Write(&buf, le, uint32(length))
+	return buf.Bytes()
+}
+
+// S-1-5-21-1-2-3-1001
+var testSid = []byte{1, 5, 0, 0, 0, 0, 0, 5, 21, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 0xe9, 3, 0, 0}
+
+func TestParseEventLogRecords(t *T) {
+	first := &eventLogRecord{
+		RecordNumber:  7,
+		TimeGenerated: 1614834367,
+		TimeWritten:   1614834368,
+		EventID:       0x40000000 | 1000,
+		EventType:     EventTypeError,
+		EventCategory: 3,
+		SourceName:    "MyService",
+		ComputerName:  "host1",
+		Strings:       []string{"disk <C:>", "full"},
+		Data:          []byte{0xde, 0xad},
+	}
+	second := &eventLogRecord{RecordNumber: 8, EventID: 4624, EventType: EventTypeAuditSuccess, SourceName: "Security", ComputerName: "host1"}
+	buf := append(eventLogRecordBytes(first, testSid), eventLogRecordBytes(second, nil)...)
+
+	records, err := parseEventLogRecords(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(records), 2, t)
+	r := records[0]
+	assertEqual(r.RecordNumber, uint32(7), t)
+	assertEqual(r.SourceName, "MyService", t)
+	assertEqual(r.ComputerName, "host1", t)
+	assertEqual(r.UserSid, "S-1-5-21-1-2-3-1001", t)
+	assertEqual(len(r.Strings), 2, t)
+	assertEqual(r.Strings[1], "full", t)
+	assertEqual(bytes.Equal(r.Data, []byte{0xde, 0xad}), true, t)
+
+	ev := r.event("Application")
+	assertEqual(ev.EventId, uint64(1000), t)
+	assertEqual(ev.Qualifiers, uint64(0x4000), t)
+	assertEqual(ev.Level, uint64(LevelError), t)
+	assertEqual(ev.Task, uint64(3), t)
+	assertEqual(ev.RecordId, uint64(7), t)
+	assertEqual(ev.Channel, "Application", t)
+	assertEqual(ev.Created.Equal(time.Unix(1614834367, 0)), true, t)
+	assertEqual(ev.Outcome, OutcomeFailure, t)
+	assertEqual(ev.EventData["Data0"], "disk <C:>", t)
+
+	// The XML parses like a classic event rendered by wevtapi
+	data, err := ParseEventData(ev.Xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(data["Data0"], "disk <C:>", t)
+	assertEqual(data["Data1"], "full", t)
+	assertEqual(strings.Contains(string(ev.Xml), "<Binary>DEAD</Binary>"), true, t)
+	assertEqual(bytes.Equal(ev.Binary, []byte{0xde, 0xad}), true, t)
+	assertEqual(strings.Contains(string(ev.Xml), "<Security UserID='S-1-5-21-1-2-3-1001'/>"), true, t)
+
+	audit := records[1].event("Security")
+	assertEqual(audit.Level, uint64(LevelLogAlways), t)
+	assertEqual(audit.KeywordsMask, keywordClassic|KeywordAuditSuccess, t)
+	assertEqual(audit.Outcome, OutcomeSuccess, t)
+	assertEqual(audit.UserSid, "", t)
+}
+
+func TestParseEventLogRecordsInvalid(t *T) {
+	buf := eventLogRecordBytes(&eventLogRecord{RecordNumber: 1, SourceName: "a"}, nil)
+	for _, invalid := range [][]byte{
+		buf[:20],
+		buf[:len(buf)-4],
+		append([]byte{0, 0, 0, 0}, buf[4:]...),
+		append(append([]byte{}, buf[:4]...), append([]byte("XXXX"), buf[8:]...)...),
+	} {
+		if _, err := parseEventLogRecords(invalid); err == nil {
+			t.Fatalf("Parsed invalid record %x", invalid)
+		}
+	}
+}
+
+func TestFormatInserts(t *T) {
+	assertEqual(formatInserts("The %1 service entered the %2!s! state.", []string{"Spooler", "stopped"}), "The Spooler service entered the stopped state.", t)
+	assertEqual(formatInserts("Missing %3", []string{"a"}), "Missing %3", t)
+}
+
+func TestParseEventLogRecordsOffsets(t *T) {
+	// Offsets near 4GB, which wrap around if converted to int on 32-bit
+	// platforms, at the string, SID and data offsets
+	for _, field := range []int{36, 44, 52} {
+		buf := eventLogRecordBytes(&eventLogRecord{RecordNumber: 1, SourceName: "a", Strings: []string{"s"}, Data: []byte{1}}, testSid)
+		binary.LittleEndian.PutUint32(buf[field:], 0xfffffff0)
+		if _, err := parseEventLogRecords(buf); err == nil {
+			t.Errorf("Parsed record with the offset at %v out of bounds", field)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+/* Interop code for reading events with the legacy Event Logging API of
+   advapi32.dll */
+
+var (
+	openEventLog  = advapi32.NewProc("OpenEventLogW")
+	readEventLog  = advapi32.NewProc("ReadEventLogW")
+	closeEventLog = advapi32.NewProc("CloseEventLog")
+)
+
+// Flags of ReadEventLogW
+const (
+	eventLogSequentialRead = 0x1
+	eventLogSeekRead       = 0x2
+	eventLogForwardsRead   = 0x4
+)
+
+// Returned by ReadEventLogW when the log was cleared since it was opened
+const errorEventLogFileChanged = syscall.Errno(1503)
+
+// Flags of LoadLibraryExW for loading message files without running them
+const loadLibraryAsData = windows.LOAD_LIBRARY_AS_DATAFILE | windows.LOAD_LIBRARY_AS_IMAGE_RESOURCE
+
+// OpenLegacyLog opens a classic event log for reading with the legacy Event
+// Logging API, starting at its oldest record. Reading the Security log
+// needs administrator rights.
+func OpenLegacyLog(log string) (*LegacyReader, error) {
+	// OpenEventLogW opens Application instead of logs which don't exist
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, eventLogKey+`\`+log, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find event log %q: %v", log, err)
+	}
+	key.Close()
+
+	r := &LegacyReader{Log: log, buf: make([]byte, 64*1024), sources: make(map[string]*legacySource)}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *LegacyReader) open() error {
+	wideLog, err := syscall.UTF16PtrFromString(r.Log)
+	if err != nil {
+		return err
+	}
+	handle, _, err := openEventLog.Call(0, uintptr(unsafe.Pointer(wideLog)))
+	if handle == 0 {
+		return fmt.Errorf("Failed to open event log %q: %v", r.Log, err)
+	}
+	r.handle = handle
+	return nil
+}
+
+// Seek makes the next event read the one with the given RecordId, such as
+// the one after the last event processed before a restart. If the record
+// has been overwritten, Next fails with ERROR_INVALID_PARAMETER.
+func (r *LegacyReader) Seek(recordId uint64) {
+	r.pending = nil
+	r.seek = uint32(recordId)
+}
+
+// Next returns the next event, or io.EOF if there are no more events yet.
+// After io.EOF, Next can be called again to read events logged since.
+// If the log is cleared, reading continues from its new oldest record.
+func (r *LegacyReader) Next() (*WinLogEvent, error) {
+	if r.handle == 0 {
+		return nil, fmt.Errorf("Event log %q is closed", r.Log)
+	}
+	for len(r.pending) == 0 {
+		if err := r.read(); err != nil {
+			return nil, err
+		}
+	}
+	record := r.pending[0]
+	r.pending = r.pending[1:]
+	ev := record.event(r.Log)
+	r.format(record, ev)
+	return ev, nil
+}
+
+// read reads as many records as fit in the buffer.
+func (r *LegacyReader) read() error {
+	flags, offset := uintptr(eventLogSequentialRead|eventLogForwardsRead), uintptr(0)
+	if r.seek != 0 {
+		flags, offset = eventLogSeekRead|eventLogForwardsRead, uintptr(r.seek)
+	}
+	var read, needed uint32
+	ok, _, err := readEventLog.Call(r.handle, flags, offset, uintptr(unsafe.Pointer(&r.buf[0])), uintptr(len(r.buf)), uintptr(unsafe.Pointer(&read)), uintptr(unsafe.Pointer(&needed)))
+	if ok == 0 {
+		switch err {
+		case windows.ERROR_HANDLE_EOF:
+			return io.EOF
+		case windows.ERROR_INSUFFICIENT_BUFFER:
+			r.buf = make([]byte, needed)
+			return nil
+		case errorEventLogFileChanged:
+			// The log was cleared: reopen it to read from its start
+			closeEventLog.Call(r.handle)
+			r.handle = 0
+			r.seek = 0
+			return r.open()
+		}
+		return fmt.Errorf("Failed to read event log %q: %v", r.Log, err)
+	}
+	r.seek = 0
+	records, err := parseEventLogRecords(r.buf[:read])
+	r.pending = records
+	return err
+}
+
+// format renders the event's message and category from its source's
+// message files. The event keeps its insertion strings if they aren't
+// registered or don't have the message.
+func (r *LegacyReader) format(record *eventLogRecord, ev *WinLogEvent) {
+	source := r.source(record.SourceName)
+	if msg, ok := formatFromFiles(source.eventFiles, record.EventID); ok {
+		ev.Msg = strings.TrimSpace(formatInserts(msg, record.Strings))
+	} else {
+		ev.Msg = strings.Join(record.Strings, " ")
+	}
+	if record.EventCategory != 0 {
+		if category, ok := formatFromFiles(source.categoryFiles, uint32(record.EventCategory)); ok {
+			ev.TaskText = strings.TrimSpace(category)
+		}
+	}
+}
+
+// source loads the message files registered for an event source.
+func (r *LegacyReader) source(name string) *legacySource {
+	if source, ok := r.sources[name]; ok {
+		return source
+	}
+	source := &legacySource{}
+	r.sources[name] = source
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, eventLogKey+`\`+r.Log+`\`+name, registry.QUERY_VALUE)
+	if err != nil {
+		return source
+	}
+	defer key.Close()
+	load := func(value string) []uintptr {
+		paths, _, err := key.GetStringValue(value)
+		if err != nil {
+			return nil
+		}
+		var modules []uintptr
+		for _, path := range strings.Split(paths, ";") {
+			if path, err = registry.ExpandString(strings.TrimSpace(path)); err != nil || path == "" {
+				continue
+			}
+			if module, err := windows.LoadLibraryEx(path, 0, loadLibraryAsData); err == nil {
+				modules = append(modules, uintptr(module))
+			}
+		}
+		return modules
+	}
+	source.eventFiles = load("EventMessageFile")
+	source.categoryFiles = load("CategoryMessageFile")
+	return source
+}
+
+// formatFromFiles returns the message with the ID from the first message
+// file which has it, with its inserts left to fill in.
+func formatFromFiles(modules []uintptr, id uint32) (string, bool) {
+	buf := make([]uint16, 32*1024)
+	for _, module := range modules {
+		n, err := windows.FormatMessage(windows.FORMAT_MESSAGE_FROM_HMODULE|windows.FORMAT_MESSAGE_IGNORE_INSERTS, module, id, 0, buf, nil)
+		if err == nil && n > 0 {
+			return syscall.UTF16ToString(buf[:n]), true
+		}
+	}
+	return "", false
+}
+
+// Close closes the log and unloads the message files.
+func (r *LegacyReader) Close() error {
+	for _, source := range r.sources {
+		for _, module := range append(source.eventFiles, source.categoryFiles...) {
+			windows.FreeLibrary(windows.Handle(module))
+		}
+	}
+	r.sources = make(map[string]*legacySource)
+	if r.handle == 0 {
+		return nil
+	}
+	ok, _, err := closeEventLog.Call(r.handle)
+	r.handle = 0
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
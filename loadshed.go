@@ -0,0 +1,130 @@
+package winlog
+
+import (
+	"sync"
+	"time"
+)
+
+// Event levels, as found in WinLogEvent.Level
+const (
+	LevelLogAlways   = 0
+	LevelCritical    = 1
+	LevelError       = 2
+	LevelWarning     = 3
+	LevelInformation = 4
+	LevelVerbose     = 5
+)
+
+// LoadShedder drops low-severity events while the consumer of the watcher's
+// Event channel can't keep up. The watcher is considered overloaded once
+// publishing an event blocks for longer than OverloadAfter, and recovers
+// after RecoverAfter passes without another such stall, whether or not any
+// events were delivered in the meantime.
+//
+// Critical, Error and LogAlways events are never shed, nor are events with
+// one of the MustKeepEventIds.
+type LoadShedder struct {
+	// Events with this level or higher (less severe) may be shed. Defaults
+	// to LevelInformation, shedding Information and Verbose events.
+	MinShedLevel     uint64
+	MustKeepEventIds []uint64
+	// Defaults to 100ms
+	OverloadAfter time.Duration
+	// Defaults to 10s
+	RecoverAfter time.Duration
+
+	mutex        sync.Mutex
+	overloaded   bool
+	lastOverload time.Time
+	stats        ShedStats
+}
+
+// Counts of events dropped by a LoadShedder
+type ShedStats struct {
+	Total      uint64
+	ByLevel    map[uint64]uint64
+	ByProvider map[string]uint64
+	// Whether events are currently being shed
+	Overloaded bool
+}
+
+func (s *LoadShedder) sheddable(ev *WinLogEvent) bool {
+	minLevel := s.MinShedLevel
+	if minLevel == 0 {
+		minLevel = LevelInformation
+	}
+	if ev.Level < minLevel || ev.Level < LevelWarning {
+		return false
+	}
+	for _, id := range s.MustKeepEventIds {
+		if ev.EventId == id {
+			return false
+		}
+	}
+	return true
+}
+
+// shed reports whether the event should be dropped, recording it if so.
+func (s *LoadShedder) shed(ev *WinLogEvent, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recover(now)
+	if !s.overloaded || !s.sheddable(ev) {
+		return false
+	}
+	s.stats.Total++
+	if s.stats.ByLevel == nil {
+		s.stats.ByLevel = make(map[uint64]uint64)
+		s.stats.ByProvider = make(map[string]uint64)
+	}
+	s.stats.ByLevel[ev.Level]++
+	s.stats.ByProvider[ev.ProviderName]++
+	return true
+}
+
+// observe records how long publishing an event was blocked on the consumer.
+func (s *LoadShedder) observe(blocked time.Duration, now time.Time) {
+	overloadAfter := s.OverloadAfter
+	if overloadAfter == 0 {
+		overloadAfter = 100 * time.Millisecond
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if blocked >= overloadAfter {
+		s.overloaded = true
+		s.lastOverload = now
+	} else {
+		s.recover(now)
+	}
+}
+
+// recover leaves the overloaded state once RecoverAfter has passed since the
+// last stall. It's checked whenever the shedder is used rather than only
+// when events are delivered, since while every event is shed none are. Must
+// be called with mutex held.
+func (s *LoadShedder) recover(now time.Time) {
+	recoverAfter := s.RecoverAfter
+	if recoverAfter == 0 {
+		recoverAfter = 10 * time.Second
+	}
+	if s.overloaded && now.Sub(s.lastOverload) >= recoverAfter {
+		s.overloaded = false
+	}
+}
+
+// Stats returns a copy of the counts of shed events.
+func (s *LoadShedder) Stats() ShedStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recover(time.Now())
+	stats := ShedStats{Total: s.stats.Total, Overloaded: s.overloaded}
+	stats.ByLevel = make(map[uint64]uint64, len(s.stats.ByLevel))
+	for k, v := range s.stats.ByLevel {
+		stats.ByLevel[k] = v
+	}
+	stats.ByProvider = make(map[string]uint64, len(s.stats.ByProvider))
+	for k, v := range s.stats.ByProvider {
+		stats.ByProvider[k] = v
+	}
+	return stats
+}
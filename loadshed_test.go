@@ -0,0 +1,52 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestLoadShedder(t *T) {
+	s := &LoadShedder{MustKeepEventIds: []uint64{7036}}
+	info := &WinLogEvent{Level: LevelInformation, ProviderName: "Noisy"}
+	kept := &WinLogEvent{Level: LevelInformation, EventId: 7036}
+	critical := &WinLogEvent{Level: LevelCritical}
+
+	now := time.Now()
+	s.observe(0, now)
+	assertEqual(s.shed(info, now), false, t)
+
+	s.observe(time.Second, now)
+	assertEqual(s.shed(info, now), true, t)
+	assertEqual(s.shed(kept, now), false, t)
+	assertEqual(s.shed(critical, now), false, t)
+	assertEqual(s.shed(&WinLogEvent{Level: LevelWarning}, now), false, t)
+	assertEqual(s.shed(&WinLogEvent{Level: LevelLogAlways}, now), false, t)
+
+	// Still overloaded until RecoverAfter passes without a stall
+	s.observe(0, now.Add(time.Second))
+	assertEqual(s.shed(info, now.Add(time.Second)), true, t)
+	s.observe(0, now.Add(11*time.Second))
+	assertEqual(s.shed(info, now.Add(11*time.Second)), false, t)
+
+	stats := s.Stats()
+	assertEqual(stats.Total, uint64(2), t)
+	assertEqual(stats.ByLevel[LevelInformation], uint64(2), t)
+	assertEqual(stats.ByProvider["Noisy"], uint64(2), t)
+	assertEqual(stats.Overloaded, false, t)
+}
+
+func TestLoadShedderRecoversWhileShedding(t *T) {
+	s := &LoadShedder{RecoverAfter: time.Minute}
+	info := &WinLogEvent{Level: LevelInformation}
+	now := time.Now()
+	s.observe(time.Second, now)
+
+	// Every event is shed, so none are delivered to observe, but the
+	// shedder still recovers once RecoverAfter passes
+	assertEqual(s.shed(info, now.Add(time.Second)), true, t)
+	assertEqual(s.shed(info, now.Add(time.Minute)), false, t)
+
+	// And when idle, with no events at all
+	s.observe(time.Second, time.Now().Add(-time.Hour))
+	assertEqual(s.Stats().Overloaded, false, t)
+}
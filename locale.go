@@ -0,0 +1,111 @@
+package winlog
+
+// Formats in the computer's default locale
+var defaultLocales = []string{""}
+
+// eventPublishers holds the metadata of an event's provider, opened in
+// each locale tried while formatting the event.
+type eventPublishers struct {
+	api      EventLogAPI
+	provider string
+
+	handles      map[string]PublisherHandle
+	errs         map[string]error
+	firstErr     error
+	formatErrors int
+}
+
+func newEventPublishers(api EventLogAPI, provider string) *eventPublishers {
+	return &eventPublishers{
+		api:      api,
+		provider: provider,
+		handles:  make(map[string]PublisherHandle),
+		errs:     make(map[string]error),
+	}
+}
+
+// open opens the provider's metadata in a locale, or the default locale if
+// it's empty, once per event.
+func (p *eventPublishers) open(locale string) (PublisherHandle, error) {
+	if handle, ok := p.handles[locale]; ok {
+		return handle, nil
+	}
+	if err, ok := p.errs[locale]; ok {
+		return 0, err
+	}
+	var handle PublisherHandle
+	var err error
+	if locale == "" {
+		handle, err = p.api.OpenPublisherMetadata(p.provider)
+	} else {
+		handle, err = p.api.OpenLocalizedPublisherMetadata(p.provider, locale)
+	}
+	if err != nil {
+		p.errs[locale] = err
+		if p.firstErr == nil {
+			p.firstErr = err
+		}
+		p.formatErrors++
+		return 0, err
+	}
+	p.handles[locale] = handle
+	return handle, nil
+}
+
+// format formats a message in the first of the locales the provider has
+// resources for, falling back to the next when formatting fails or gives
+// no text.
+func (p *eventPublishers) format(event EventHandle, flags EVT_FORMAT_MESSAGE_FLAGS, locales []string) string {
+	if len(locales) == 0 {
+		locales = defaultLocales
+	}
+	failed := false
+	for _, locale := range locales {
+		publisher, err := p.open(locale)
+		if err != nil {
+			continue
+		}
+		text, err := p.api.FormatMessage(publisher, event, flags)
+		if err != nil {
+			failed = true
+			continue
+		}
+		if text != "" {
+			return text
+		}
+	}
+	if failed {
+		p.formatErrors++
+	}
+	return ""
+}
+
+// formatEach formats a message in each of the locales, leaving out those
+// the provider has no text for. Returns nil without locales.
+func (p *eventPublishers) formatEach(event EventHandle, flags EVT_FORMAT_MESSAGE_FLAGS, locales []string) map[string]string {
+	if len(locales) == 0 {
+		return nil
+	}
+	texts := make(map[string]string, len(locales))
+	for _, locale := range locales {
+		if text := p.format(event, flags, []string{locale}); text != "" {
+			texts[locale] = text
+		}
+	}
+	return texts
+}
+
+// openErr returns the first error opening the metadata if it couldn't be
+// opened in any locale.
+func (p *eventPublishers) openErr() error {
+	if len(p.handles) > 0 {
+		return nil
+	}
+	return p.firstErr
+}
+
+func (p *eventPublishers) close() {
+	for _, handle := range p.handles {
+		p.api.Close(uint64(handle))
+	}
+}
@@ -0,0 +1,129 @@
+package winlog
+
+import (
+	"fmt"
+	. "testing"
+	"time"
+)
+
+func TestEventPublishersFallback(t *T) {
+	api := newFakeAPI()
+	publishers := newEventPublishers(api, "Provider")
+
+	// The provider has no resources for the first locale
+	text := publishers.format(1, EvtFormatMessageEvent, []string{missingLocale, "en-US", ""})
+	assertEqual(text, fmt.Sprintf("message %v in en-US", EvtFormatMessageEvent), t)
+	assertEqual(publishers.formatErrors, 0, t)
+	assertEqual(len(publishers.handles), 2, t)
+
+	// Each locale is only opened once per event
+	publishers.format(1, EvtFormatMessageLevel, []string{missingLocale, "en-US"})
+	assertEqual(len(publishers.handles), 2, t)
+
+	// Formatting fails in every locale
+	assertEqual(publishers.format(1, EvtFormatMessageEvent, []string{missingLocale}), "", t)
+	assertEqual(publishers.formatErrors, 1, t)
+	assertEqual(publishers.openErr(), nil, t)
+
+	assertEqual(publishers.format(1, EvtFormatMessageEvent, nil), fmt.Sprintf("message %v", EvtFormatMessageEvent), t)
+	publishers.close()
+	for _, handle := range publishers.handles {
+		assertEqual(api.closed[uint64(handle)], true, t)
+	}
+}
+
+func TestWatcherLocales(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.RenderMessage = true
+	watcher.Locales = []string{missingLocale, "de-DE", "en-US"}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.Msg, fmt.Sprintf("message %v in de-DE", EvtFormatMessageEvent), t)
+		assertEqual(ev.PublisherHandleErr, nil, t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published")
+	}
+}
+
+// A secret masked in Msg mustn't leak through the other locales
+func TestRedactRenderLocales(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.RenderMessage = true
+	watcher.RenderLevel = true
+	watcher.RenderLocales = []string{"fr-FR", "en-US"}
+	watcher.Redactor = &Redactor{MsgPatterns: []string{`message \d+`}}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.Msg, DefaultRedactionMask, t)
+		assertEqual(ev.LocalizedMsg["fr-FR"], DefaultRedactionMask+" in fr-FR", t)
+		assertEqual(ev.LocalizedMsg["en-US"], DefaultRedactionMask+" in en-US", t)
+		assertEqual(ev.LocalizedLevelText["en-US"], DefaultRedactionMask+" in en-US", t)
+		localized := ev.CreateMap()["LocalizedMsg"].(map[string]string)
+		assertEqual(localized["fr-FR"], DefaultRedactionMask+" in fr-FR", t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published")
+	}
+}
+
+func TestWatcherRenderLocales(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.RenderMessage = true
+	watcher.RenderLevel = true
+	watcher.RenderLocales = []string{"fr-FR", "en-US", missingLocale}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.Msg, fmt.Sprintf("message %v", EvtFormatMessageEvent), t)
+		assertEqual(len(ev.LocalizedMsg), 2, t)
+		assertEqual(ev.LocalizedMsg["fr-FR"], fmt.Sprintf("message %v in fr-FR", EvtFormatMessageEvent), t)
+		assertEqual(ev.LocalizedMsg["en-US"], fmt.Sprintf("message %v in en-US", EvtFormatMessageEvent), t)
+		assertEqual(ev.LocalizedLevelText["en-US"], fmt.Sprintf("message %v in en-US", EvtFormatMessageLevel), t)
+		assertEqual(len(ev.LocalizedLevelText), 2, t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published")
+	}
+}
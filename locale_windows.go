@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32         = windows.NewLazySystemDLL("kernel32.dll")
+	localeNameToLCID = kernel32.NewProc("LocaleNameToLCID")
+)
+
+// localeId converts a locale name, such as "en-US", to the LCID
+// EvtOpenPublisherMetadata takes. An empty name is 0, the computer's
+// default locale.
+func localeId(name string) (uint32, error) {
+	if name == "" {
+		return 0, nil
+	}
+	wide, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	r1, _, err := localeNameToLCID.Call(uintptr(unsafe.Pointer(wide)), 0)
+	if r1 == 0 {
+		return 0, fmt.Errorf("Unknown locale %q: %v", name, err)
+	}
+	return uint32(r1), nil
+}
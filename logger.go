@@ -0,0 +1,72 @@
+package winlog
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// Logger receives the watcher's internal diagnostics: subscription lifecycle,
+// pauses and resumes, and failed syscalls which would otherwise go unnoticed.
+// `keyvals` are alternating keys and values, as accepted by log/slog, so a
+// *slog.Logger can be adapted with a one-line wrapper.
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+type stdLogger struct {
+	logger   *log.Logger
+	minLevel LogLevel
+}
+
+// NewStdLogger adapts a *log.Logger, writing messages at or above minLevel
+// with their key/value pairs formatted as key=value.
+func NewStdLogger(logger *log.Logger, minLevel LogLevel) Logger {
+	return &stdLogger{logger: logger, minLevel: minLevel}
+}
+
+func (l *stdLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if level < l.minLevel {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(level.String())
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&sb, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&sb, " %v", keyvals[i])
+		}
+	}
+	l.logger.Print(sb.String())
+}
+
+func (self *WinLogWatcher) log(level LogLevel, msg string, keyvals ...interface{}) {
+	if self.Logger != nil {
+		self.Logger.Log(level, msg, keyvals...)
+	}
+}
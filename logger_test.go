@@ -0,0 +1,15 @@
+package winlog
+
+import (
+	"bytes"
+	"log"
+	. "testing"
+)
+
+func TestStdLogger(t *T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), LogInfo)
+	logger.Log(LogDebug, "hidden")
+	logger.Log(LogWarn, "Subscription paused", "channel", "Security", "delay", 3, "odd")
+	assertEqual(buf.String(), "WARN Subscription paused channel=Security delay=3 odd\n", t)
+}
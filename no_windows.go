@@ -1,3 +0,0 @@
-// +build !windows
-
-package winlog
@@ -0,0 +1,164 @@
+package winlog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Notifications buffered for the consumer of Notifications before new ones
+// are discarded
+const NotificationBufferSize = 100
+
+// How often the watcher checks its subscriptions for dropped events to
+// report
+const defaultDropNotificationInterval = time.Second
+
+// Kinds of Notification
+type NotificationKind string
+
+const (
+	// A subscription was made, or removed
+	NotifySubscribed   NotificationKind = "subscribed"
+	NotifyUnsubscribed NotificationKind = "unsubscribed"
+	// A subscription was paused by its RateLimit, and subscribed again
+	// from its bookmark after a pause or after a remote session was
+	// reopened
+	NotifyPaused       NotificationKind = "paused"
+	NotifyResubscribed NotificationKind = "resubscribed"
+	// SaveBookmark saved a subscription's bookmark
+	NotifyBookmarkSaved NotificationKind = "bookmark_saved"
+	// Events were dropped by rate limiting or load shedding, or discarded
+	// by the Expiry policy, since the last notification
+	NotifyEventsDropped NotificationKind = "events_dropped"
+	NotifyEventsExpired NotificationKind = "events_expired"
+)
+
+// Notification reports a change in what the watcher is doing, rather than
+// an event or an error, so agents can log and report the collector's
+// behavior separately from event data.
+type Notification struct {
+	Kind NotificationKind
+	// The subscription the notification is about, if any
+	Channel string `json:",omitempty"`
+	Time    time.Time
+	// Events the notification counts, such as those dropped
+	Count uint64 `json:",omitempty"`
+	// The event the notification is about, such as the last one whose
+	// bookmark was saved or the first after a channel was cleared, and the
+	// one before it
+	RecordId         uint64 `json:",omitempty"`
+	PreviousRecordId uint64 `json:",omitempty"`
+	// The missing records, for NotifyRecordGap
+	Gap     *RecordGap `json:",omitempty"`
+	Message string
+}
+
+func (n Notification) String() string {
+	if n.Channel == "" {
+		return fmt.Sprintf("%v: %v", n.Kind, n.Message)
+	}
+	return fmt.Sprintf("%v %v: %v", n.Kind, n.Channel, n.Message)
+}
+
+// Notifications is the channel of lifecycle notifications, alongside Event
+// and Error. Reading it is optional: notifications are discarded, and
+// counted in Health, while NotificationBufferSize are waiting. It's closed
+// when the watcher shuts down.
+func (self *WinLogWatcher) Notifications() <-chan Notification {
+	return self.notifyChan
+}
+
+// notify sends a notification without blocking.
+func (self *WinLogWatcher) notify(n Notification) {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	self.notifyMutex.Lock()
+	defer self.notifyMutex.Unlock()
+	if self.notifyClosed {
+		return
+	}
+	select {
+	case self.notifyChan <- n:
+	default:
+		atomic.AddUint64(&self.notificationsDiscarded, 1)
+	}
+}
+
+func (self *WinLogWatcher) closeNotifications() {
+	self.notifyMutex.Lock()
+	defer self.notifyMutex.Unlock()
+	if !self.notifyClosed {
+		self.notifyClosed = true
+		close(self.notifyChan)
+	}
+}
+
+// SaveBookmark saves the bookmark of a delivered event to the watcher's
+// BookmarkStore, once the consumer has processed it, so a restarted watcher
+// resumes after it.
+func (self *WinLogWatcher) SaveBookmark(ev *WinLogEvent) error {
+	if self.BookmarkStore == nil {
+		return fmt.Errorf("The watcher has no BookmarkStore")
+	}
+	if ev.SubscribedChannel == "" || ev.Bookmark == "" {
+		return fmt.Errorf("The event has no bookmark")
+	}
+	if err := self.BookmarkStore.Save(ev.SubscribedChannel, ev.Bookmark); err != nil {
+		return fmt.Errorf("Failed to save bookmark for %q: %v", ev.SubscribedChannel, err)
+	}
+	self.notify(Notification{
+		Kind:     NotifyBookmarkSaved,
+		Channel:  ev.SubscribedChannel,
+		RecordId: ev.RecordId,
+		Message:  fmt.Sprintf("Saved bookmark after record %v", ev.RecordId),
+	})
+	return nil
+}
+
+func (self *WinLogWatcher) startDropNotifications() {
+	self.dropNotifyOnce.Do(func() { go self.notifyDrops() })
+}
+
+// notifyDrops reports the events each subscription dropped or expired every
+// dropNotificationInterval until shutdown, if there were any.
+func (self *WinLogWatcher) notifyDrops() {
+	interval := self.dropNotificationInterval
+	if interval <= 0 {
+		interval = defaultDropNotificationInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	type counts struct{ dropped, expired uint64 }
+	reported := make(map[*subscriptionStats]counts)
+	for {
+		select {
+		case <-ticker.C:
+		case <-self.shutdown:
+			return
+		}
+		self.watchMutex.Lock()
+		current := make(map[string]*subscriptionStats, len(self.watches))
+		for channel, watch := range self.watches {
+			current[channel] = watch.stats
+		}
+		self.watchMutex.Unlock()
+
+		seen := make(map[*subscriptionStats]counts, len(current))
+		for channel, stats := range current {
+			last := reported[stats]
+			now := counts{atomic.LoadUint64(&stats.dropped), atomic.LoadUint64(&stats.expired)}
+			if n := now.dropped - last.dropped; n > 0 {
+				self.notify(Notification{Kind: NotifyEventsDropped, Channel: channel, Count: n,
+					Message: fmt.Sprintf("Dropped %v events by rate limiting or load shedding", n)})
+			}
+			if n := now.expired - last.expired; n > 0 {
+				self.notify(Notification{Kind: NotifyEventsExpired, Channel: channel, Count: n,
+					Message: fmt.Sprintf("Discarded %v events older than the expiry policy allows", n)})
+			}
+			seen[stats] = now
+		}
+		reported = seen
+	}
+}
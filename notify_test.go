@@ -0,0 +1,79 @@
+package winlog
+
+import (
+	"sync/atomic"
+	. "testing"
+	"time"
+)
+
+func nextNotification(watcher *WinLogWatcher, t *T) Notification {
+	select {
+	case n := <-watcher.Notifications():
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for notification")
+	}
+	return Notification{}
+}
+
+func TestNotifications(t *T) {
+	store := mapBookmarkStore{}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: newFakeAPI(), BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.dropNotificationInterval = 10 * time.Millisecond
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	n := nextNotification(watcher, t)
+	assertEqual(n.Kind, NotifySubscribed, t)
+	assertEqual(n.Channel, "Application", t)
+
+	ev := &WinLogEvent{SubscribedChannel: "Application", Bookmark: "7", RecordId: 7}
+	if err := watcher.SaveBookmark(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(store["Application"], "7", t)
+	n = nextNotification(watcher, t)
+	assertEqual(n.Kind, NotifyBookmarkSaved, t)
+	assertEqual(n.RecordId, uint64(7), t)
+	if err := watcher.SaveBookmark(&WinLogEvent{}); err == nil {
+		t.Error("Expected an error saving an event without a bookmark")
+	}
+
+	watcher.watchMutex.Lock()
+	stats := watcher.watches["Application"].stats
+	watcher.watchMutex.Unlock()
+	atomic.AddUint64(&stats.dropped, 3)
+	n = nextNotification(watcher, t)
+	assertEqual(n.Kind, NotifyEventsDropped, t)
+	assertEqual(n.Count, uint64(3), t)
+	atomic.AddUint64(&stats.dropped, 2)
+	atomic.AddUint64(&stats.expired, 1)
+	counts := make(map[NotificationKind]uint64)
+	for i := 0; i < 2; i++ {
+		n = nextNotification(watcher, t)
+		counts[n.Kind] = n.Count
+	}
+	assertEqual(counts[NotifyEventsDropped], uint64(2), t)
+	assertEqual(counts[NotifyEventsExpired], uint64(1), t)
+
+	watcher.RemoveSubscription("Application")
+	assertEqual(nextNotification(watcher, t).Kind, NotifyUnsubscribed, t)
+	watcher.Shutdown()
+	_, open := <-watcher.Notifications()
+	assertEqual(open, false, t)
+}
+
+func TestNotificationsDiscarded(t *T) {
+	watcher, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for i := 0; i < NotificationBufferSize+2; i++ {
+		watcher.notify(Notification{Kind: NotifyPaused})
+	}
+	assertEqual(watcher.Health().NotificationsDiscarded, uint64(2), t)
+}
@@ -0,0 +1,121 @@
+package winlog
+
+import (
+	"time"
+)
+
+// RenderFields selects the localized fields of events rendered with
+// EvtFormatMessage. EvtFormatMessage is slow, so fields which aren't needed
+// are best left out.
+type RenderFields struct {
+	Keywords bool
+	Message  bool
+	Level    bool
+	Task     bool
+	Provider bool
+	Opcode   bool
+	Channel  bool
+	Id       bool
+}
+
+// RenderAllFields renders every localized field.
+var RenderAllFields = RenderFields{
+	Keywords: true,
+	Message:  true,
+	Level:    true,
+	Task:     true,
+	Provider: true,
+	Opcode:   true,
+	Channel:  true,
+	Id:       true,
+}
+
+// WatcherOptions configures a watcher created with
+// NewWinLogWatcherWithOptions. Each option sets the watcher field of the
+// same name, which can still be changed before subscribing; the zero value
+// of each is the watcher's default.
+type WatcherOptions struct {
+	// The event log calls to make, SystemEventLogAPI if nil
+	API EventLogAPI
+
+	// Formatting
+	Render             RenderFields
+	Locales            []string
+	RenderLocales      []string
+	UsePublisherTables bool
+	NameEventData      bool
+
+	// Buffering and sizes
+	ChannelBufferSize   int
+	Truncation          *TruncationPolicy
+	Expiry              *ExpiryPolicy
+	BinaryFormat        BinaryFormat
+	MaxBinarySize       int
+	RenderWorkers       int
+	SubscriptionWorkers map[string]int
+
+	// Where subscriptions resume from, and their counters are kept
+	BookmarkStore        BookmarkStore
+	PersistStatsInterval time.Duration
+
+	// Logging and metrics
+	Logger                 Logger
+	BookmarkLagInterval    time.Duration
+	CapacityWarningHorizon time.Duration
+	TopSourcesWindow       time.Duration
+	TopSources             int
+	ClockSkewThreshold     time.Duration
+	SlowConsumerThreshold  time.Duration
+	SlowConsumerWatermark  int64
+}
+
+// NewWinLogWatcherWithOptions creates a watcher configured by `opts`.
+//
+//	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{
+//		Render:  RenderFields{Message: true, Level: true},
+//		Locales: []string{"", "en-US"},
+//	})
+func NewWinLogWatcherWithOptions(opts WatcherOptions) (*WinLogWatcher, error) {
+	api := opts.API
+	if api == nil {
+		api = SystemEventLogAPI
+	}
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		return nil, err
+	}
+	watcher.setRenderFields(opts.Render)
+	watcher.Locales = opts.Locales
+	watcher.RenderLocales = opts.RenderLocales
+	watcher.UsePublisherTables = opts.UsePublisherTables
+	watcher.NameEventData = opts.NameEventData
+	watcher.ChannelBufferSize = opts.ChannelBufferSize
+	watcher.Truncation = opts.Truncation
+	watcher.Expiry = opts.Expiry
+	watcher.BinaryFormat = opts.BinaryFormat
+	watcher.MaxBinarySize = opts.MaxBinarySize
+	watcher.RenderWorkers = opts.RenderWorkers
+	watcher.SubscriptionWorkers = opts.SubscriptionWorkers
+	watcher.BookmarkStore = opts.BookmarkStore
+	watcher.PersistStatsInterval = opts.PersistStatsInterval
+	watcher.Logger = opts.Logger
+	watcher.BookmarkLagInterval = opts.BookmarkLagInterval
+	watcher.CapacityWarningHorizon = opts.CapacityWarningHorizon
+	watcher.TopSourcesWindow = opts.TopSourcesWindow
+	watcher.TopSources = opts.TopSources
+	watcher.ClockSkewThreshold = opts.ClockSkewThreshold
+	watcher.SlowConsumerThreshold = opts.SlowConsumerThreshold
+	watcher.SlowConsumerWatermark = opts.SlowConsumerWatermark
+	return watcher, nil
+}
+
+func (self *WinLogWatcher) setRenderFields(render RenderFields) {
+	self.RenderKeywords = render.Keywords
+	self.RenderMessage = render.Message
+	self.RenderLevel = render.Level
+	self.RenderTask = render.Task
+	self.RenderProvider = render.Provider
+	self.RenderOpcode = render.Opcode
+	self.RenderChannel = render.Channel
+	self.RenderId = render.Id
+}
@@ -0,0 +1,67 @@
+package winlog
+
+import (
+	"io/ioutil"
+	"log"
+	. "testing"
+	"time"
+)
+
+func TestNewWinLogWatcherWithOptions(t *T) {
+	api := newFakeAPI()
+	logger := NewStdLogger(log.New(ioutil.Discard, "", 0), LogInfo)
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{
+		API:                api,
+		Render:             RenderFields{Message: true, Level: true},
+		Locales:            []string{"en-US"},
+		ChannelBufferSize:  10,
+		Logger:             logger,
+		ClockSkewThreshold: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	assertEqual(watcher.api, EventLogAPI(api), t)
+	assertEqual(watcher.RenderMessage, true, t)
+	assertEqual(watcher.RenderLevel, true, t)
+	assertEqual(watcher.RenderKeywords, false, t)
+	assertEqual(watcher.Locales[0], "en-US", t)
+	assertEqual(watcher.ChannelBufferSize, 10, t)
+	assertEqual(watcher.Logger, logger, t)
+	assertEqual(watcher.ClockSkewThreshold, time.Minute, t)
+
+	watcher.setRenderFields(RenderAllFields)
+	assertEqual(watcher.RenderId && watcher.RenderKeywords && watcher.RenderChannel, true, t)
+}
+
+type mapBookmarkStore map[string]string
+
+func (s mapBookmarkStore) Load(channel string) (string, error) {
+	return s[channel], nil
+}
+
+func (s mapBookmarkStore) Save(channel, bookmark string) error {
+	s[channel] = bookmark
+	return nil
+}
+
+func (s mapBookmarkStore) Close() error {
+	return nil
+}
+
+func TestSubscribeFromBookmarkStore(t *T) {
+	store := mapBookmarkStore{"Security": "42"}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: newFakeAPI(), BookmarkStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for _, channel := range []string{"Security", "System"} {
+		if err := watcher.Subscribe(channel, "*", SubscriptionStart{Mode: StartAtBookmark}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(watcher.watches["Security"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAfterBookmark), t)
+	assertEqual(watcher.watches["System"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAtOldestRecord), t)
+}
@@ -0,0 +1,30 @@
+package winlog
+
+// Standard keywords marking Security log audit events, as found in
+// WinLogEvent.KeywordsMask
+const (
+	KeywordAuditFailure uint64 = 0x10000000000000
+	KeywordAuditSuccess uint64 = 0x20000000000000
+)
+
+// Values of WinLogEvent.Outcome
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// DeriveOutcome returns whether an event records a success or a failure:
+// OutcomeSuccess or OutcomeFailure for audit events with the AuditSuccess or
+// AuditFailure keyword, OutcomeFailure for other Critical and Error events,
+// and "" when the event doesn't say, as for most Information events.
+func DeriveOutcome(keywords, level uint64) string {
+	switch {
+	case keywords&KeywordAuditFailure != 0:
+		return OutcomeFailure
+	case keywords&KeywordAuditSuccess != 0:
+		return OutcomeSuccess
+	case level == LevelCritical || level == LevelError:
+		return OutcomeFailure
+	}
+	return ""
+}
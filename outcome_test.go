@@ -0,0 +1,32 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestDeriveOutcome(t *T) {
+	assertEqual(DeriveOutcome(0x8020000000000000, LevelLogAlways), OutcomeSuccess, t)
+	assertEqual(DeriveOutcome(0x8010000000000000, LevelLogAlways), OutcomeFailure, t)
+	// The audit keywords take precedence over the level
+	assertEqual(DeriveOutcome(0x8020000000000000, LevelError), OutcomeSuccess, t)
+	assertEqual(DeriveOutcome(0x80000000000000, LevelError), OutcomeFailure, t)
+	assertEqual(DeriveOutcome(0, LevelCritical), OutcomeFailure, t)
+	assertEqual(DeriveOutcome(0x80000000000000, LevelWarning), "", t)
+	assertEqual(DeriveOutcome(0, LevelInformation), "", t)
+}
+
+func TestOutcomeFields(t *T) {
+	ev := &WinLogEvent{KeywordsMask: 0x8010000000000000, Outcome: OutcomeFailure}
+	m := ev.CreateMap()
+	assertEqual(m["Outcome"], OutcomeFailure, t)
+	assertEqual(m["KeywordsMask"], uint64(0x8010000000000000), t)
+	_, ok := (&WinLogEvent{}).CreateMap()["Outcome"]
+	assertEqual(ok, false, t)
+
+	filter, err := CompileFilter(`Outcome == "failure"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(filter.Match(ev), true, t)
+	assertEqual(filter.Match(&WinLogEvent{Outcome: OutcomeSuccess}), false, t)
+}
@@ -0,0 +1,128 @@
+package winlog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Size of each pipe instance's output buffer
+const pipeBufferSize = 64 * 1024
+
+// PipeSink streams events over a Windows named pipe to every connected
+// client, as frames read by ReadFrame. Clients which disconnect are dropped.
+// A client which stops reading blocks Write once its pipe buffer is full.
+type PipeSink struct {
+	Name string
+
+	sa      *windows.SecurityAttributes
+	mutex   sync.Mutex
+	clients []*os.File
+	closed  bool
+	done    chan struct{}
+}
+
+// NewPipeSink creates the named pipe `name`, such as
+// `\\.\pipe\gowinlog-events`, and starts accepting clients. `sddl` is a
+// security descriptor controlling who can connect, such as
+// "D:P(A;;GA;;;SY)(A;;GA;;;BA)" for SYSTEM and administrators only; if it's
+// "" the default descriptor is used.
+func NewPipeSink(name, sddl string) (*PipeSink, error) {
+	sink := &PipeSink{Name: name, done: make(chan struct{})}
+	if sddl != "" {
+		sd, err := windows.SecurityDescriptorFromString(sddl)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid security descriptor %q: %v", sddl, err)
+		}
+		sink.sa = &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), SecurityDescriptor: sd}
+	}
+	// Create the first instance here, so a name which is already in use is
+	// reported to the caller
+	pipe, err := sink.createInstance(windows.FILE_FLAG_FIRST_PIPE_INSTANCE)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create pipe %v: %v", name, err)
+	}
+	go sink.accept(pipe)
+	return sink, nil
+}
+
+func (s *PipeSink) createInstance(flags uint32) (windows.Handle, error) {
+	wideName, err := windows.UTF16PtrFromString(s.Name)
+	if err != nil {
+		return 0, err
+	}
+	return windows.CreateNamedPipe(wideName, windows.PIPE_ACCESS_OUTBOUND|flags, windows.PIPE_TYPE_BYTE|windows.PIPE_WAIT,
+		windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, 0, 0, s.sa)
+}
+
+// accept waits for a client on each pipe instance in turn.
+func (s *PipeSink) accept(pipe windows.Handle) {
+	defer close(s.done)
+	for {
+		err := windows.ConnectNamedPipe(pipe, nil)
+		if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			windows.CloseHandle(pipe)
+			return
+		}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			windows.CloseHandle(pipe)
+			return
+		}
+		s.clients = append(s.clients, os.NewFile(uintptr(pipe), s.Name))
+		s.mutex.Unlock()
+
+		if pipe, err = s.createInstance(0); err != nil {
+			return
+		}
+	}
+}
+
+// Write sends the event to every connected client.
+func (s *PipeSink) Write(ev *WinLogEvent) error {
+	frame, err := marshalFrame(ev)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return fmt.Errorf("Pipe %v is closed", s.Name)
+	}
+	connected := s.clients[:0]
+	for _, client := range s.clients {
+		if _, err := client.Write(frame); err != nil {
+			client.Close()
+			continue
+		}
+		connected = append(connected, client)
+	}
+	s.clients = connected
+	return nil
+}
+
+// Close disconnects every client and stops accepting new ones.
+func (s *PipeSink) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, client := range s.clients {
+		client.Close()
+	}
+	s.clients = nil
+	s.mutex.Unlock()
+
+	// Connect to the waiting instance so accept returns
+	if client, err := os.OpenFile(s.Name, os.O_RDONLY, 0); err == nil {
+		client.Close()
+	}
+	<-s.done
+	return nil
+}
@@ -0,0 +1,50 @@
+package winlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	. "testing"
+	"time"
+)
+
+func TestPipeSink(t *T) {
+	name := fmt.Sprintf(`\\.\pipe\gowinlog-test-%v`, os.Getpid())
+	sink, err := NewPipeSink(name, "D:P(A;;GA;;;WD)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if _, err := NewPipeSink(name, ""); err == nil {
+		t.Fatal("Expected an error creating a pipe which already exists")
+	}
+
+	client, err := os.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// Wait for the sink to register the client
+	for i := 0; i < 100; i++ {
+		sink.mutex.Lock()
+		n := len(sink.clients)
+		sink.mutex.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := sink.Write(&WinLogEvent{EventId: 4624}); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := ReadFrame(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(frame, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(decoded["EventId"], float64(4624), t)
+}
@@ -0,0 +1,186 @@
+// Package powershell reassembles PowerShell script block logging events.
+// PowerShell splits a large script block across several 4104 events in the
+// Microsoft-Windows-PowerShell/Operational channel, each holding a fragment
+// of the text, which are only useful once put back together:
+//
+//	reassembler := powershell.NewReassembler(time.Minute)
+//	for ev := range watcher.Event() {
+//		block, err := reassembler.Add(ev, time.Now())
+//		if block != nil {
+//			analyze(block.Text)
+//		}
+//	}
+//
+// Call Expire periodically to collect blocks whose remaining fragments never
+// arrived.
+package powershell
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider and channel of script block logging events
+const (
+	ProviderName = "Microsoft-Windows-PowerShell"
+	Channel      = "Microsoft-Windows-PowerShell/Operational"
+)
+
+// Event ID of a script block fragment
+const EventScriptBlock = 4104
+
+// ErrNotScriptBlock is returned by Add for events other than 4104.
+var ErrNotScriptBlock = errors.New("Not a PowerShell script block event")
+
+// ScriptBlock is a reassembled script block.
+type ScriptBlock struct {
+	// Copy of the first fragment received, with the whole Text as its
+	// ScriptBlockText and MessageNumber and MessageTotal set to 1
+	Event *winlog.WinLogEvent
+	Id    string
+	// Script file the block came from, or "" if it was typed or generated
+	Path string
+	Text string
+	// Number of fragments PowerShell split the block into
+	Parts int
+	// For partial blocks returned by Expire, the 1-based numbers of the
+	// fragments which never arrived. Their text is left out.
+	Missing []int
+}
+
+// Complete reports whether all fragments of the block arrived.
+func (b *ScriptBlock) Complete() bool {
+	return len(b.Missing) == 0
+}
+
+type pendingBlock struct {
+	first     *winlog.WinLogEvent
+	data      map[string]string
+	total     int
+	fragments map[int]string
+	started   time.Time
+}
+
+// Reassembler buffers 4104 fragments by ScriptBlockId until all of a block's
+// fragments have arrived.
+type Reassembler struct {
+	// How long to wait for the rest of a block after its first fragment
+	Timeout time.Duration
+
+	mutex   sync.Mutex
+	pending map[string]*pendingBlock
+}
+
+func NewReassembler(timeout time.Duration) *Reassembler {
+	return &Reassembler{Timeout: timeout, pending: make(map[string]*pendingBlock)}
+}
+
+// fields reads the script block fields of a 4104 event, parsing the XML if
+// EventData wasn't filled in.
+func fields(ev *winlog.WinLogEvent) (map[string]string, error) {
+	if ev.EventId != EventScriptBlock || (ev.ProviderName != "" && ev.ProviderName != ProviderName) {
+		return nil, ErrNotScriptBlock
+	}
+	if ev.EventData != nil || len(ev.Xml) == 0 {
+		return ev.EventData, nil
+	}
+	data, err := winlog.ParseEventData(ev.Xml)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+	}
+	return data, nil
+}
+
+// Add buffers a fragment, returning the reassembled block if it was the
+// last one needed, or nil. Blocks which fit in one event are returned
+// straight away. Fragments which arrive after their block expired start a
+// new partial block.
+func (r *Reassembler) Add(ev *winlog.WinLogEvent, now time.Time) (*ScriptBlock, error) {
+	data, err := fields(ev)
+	if err != nil {
+		return nil, err
+	}
+	number, err := strconv.Atoi(data["MessageNumber"])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse MessageNumber %q: %v", data["MessageNumber"], err)
+	}
+	total, err := strconv.Atoi(data["MessageTotal"])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse MessageTotal %q: %v", data["MessageTotal"], err)
+	}
+	if number < 1 || number > total {
+		return nil, fmt.Errorf("Fragment %v of %v is out of range", number, total)
+	}
+	id := data["ScriptBlockId"]
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	block, ok := r.pending[id]
+	if !ok {
+		block = &pendingBlock{first: ev, data: data, total: total, fragments: make(map[int]string), started: now}
+		if total > 1 {
+			r.pending[id] = block
+		}
+	}
+	block.fragments[number] = data["ScriptBlockText"]
+	if len(block.fragments) < block.total {
+		return nil, nil
+	}
+	delete(r.pending, id)
+	return block.assemble(id), nil
+}
+
+// Expire returns the blocks which have waited longer than Timeout for their
+// remaining fragments, with the fragments which did arrive, and forgets
+// them.
+func (r *Reassembler) Expire(now time.Time) []*ScriptBlock {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var expired []*ScriptBlock
+	for id, block := range r.pending {
+		if now.Sub(block.started) >= r.Timeout {
+			expired = append(expired, block.assemble(id))
+			delete(r.pending, id)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Event.Created.Before(expired[j].Event.Created)
+	})
+	return expired
+}
+
+// Pending returns the number of blocks waiting for fragments.
+func (r *Reassembler) Pending() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.pending)
+}
+
+func (b *pendingBlock) assemble(id string) *ScriptBlock {
+	var text strings.Builder
+	var missing []int
+	for i := 1; i <= b.total; i++ {
+		fragment, ok := b.fragments[i]
+		if !ok {
+			missing = append(missing, i)
+		}
+		text.WriteString(fragment)
+	}
+
+	ev := *b.first
+	ev.EventData = make(map[string]string, len(b.data))
+	for name, value := range b.data {
+		ev.EventData[name] = value
+	}
+	ev.EventData["MessageNumber"] = "1"
+	ev.EventData["MessageTotal"] = "1"
+	ev.EventData["ScriptBlockText"] = text.String()
+	return &ScriptBlock{Event: &ev, Id: id, Path: b.data["Path"], Text: text.String(), Parts: b.total, Missing: missing}
+}
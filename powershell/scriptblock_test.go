@@ -0,0 +1,79 @@
+package powershell
+
+import (
+	"strconv"
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+func fragment(id string, number, total int, text string) *winlog.WinLogEvent {
+	return &winlog.WinLogEvent{
+		ProviderName: ProviderName,
+		EventId:      EventScriptBlock,
+		Channel:      Channel,
+		EventData: map[string]string{
+			"MessageNumber":   strconv.Itoa(number),
+			"MessageTotal":    strconv.Itoa(total),
+			"ScriptBlockText": text,
+			"ScriptBlockId":   id,
+			"Path":            `C:\scripts\deploy.ps1`,
+		},
+	}
+}
+
+func TestReassembler(t *T) {
+	r := NewReassembler(time.Minute)
+	now := time.Now()
+
+	// Out of order, interleaved with another block
+	block, err := r.Add(fragment("a", 3, 3, "Host 3"), now)
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(block == nil, true, t)
+	r.Add(fragment("a", 1, 3, "Write-Host 1; "), now)
+	single, err := r.Add(fragment("b", 1, 1, "Get-Date"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(single.Text, "Get-Date", t)
+	testutil.AssertEqual(r.Pending(), 1, t)
+	block, err = r.Add(fragment("a", 2, 3, "Write-Host 2; Write-"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(block.Text, "Write-Host 1; Write-Host 2; Write-Host 3", t)
+	testutil.AssertEqual(block.Id, "a", t)
+	testutil.AssertEqual(block.Path, `C:\scripts\deploy.ps1`, t)
+	testutil.AssertEqual(block.Parts, 3, t)
+	testutil.AssertEqual(block.Complete(), true, t)
+	testutil.AssertEqual(block.Event.EventData["ScriptBlockText"], block.Text, t)
+	testutil.AssertEqual(block.Event.EventData["MessageTotal"], "1", t)
+	testutil.AssertEqual(r.Pending(), 0, t)
+
+	if _, err := r.Add(&winlog.WinLogEvent{ProviderName: ProviderName, EventId: 4103}, now); err != ErrNotScriptBlock {
+		t.Fatalf("Expected ErrNotScriptBlock, got %v", err)
+	}
+	if _, err := r.Add(fragment("c", 4, 3, ""), now); err == nil {
+		t.Fatal("Expected an error for an out of range fragment")
+	}
+}
+
+func TestReassemblerExpire(t *T) {
+	r := NewReassembler(time.Minute)
+	now := time.Now()
+	r.Add(fragment("a", 1, 3, "one "), now)
+	r.Add(fragment("a", 3, 3, "three"), now)
+	r.Add(fragment("b", 1, 2, "later"), now.Add(30*time.Second))
+
+	testutil.AssertEqual(len(r.Expire(now.Add(59*time.Second))), 0, t)
+	expired := r.Expire(now.Add(time.Minute))
+	testutil.AssertEqual(len(expired), 1, t)
+	testutil.AssertEqual(expired[0].Id, "a", t)
+	testutil.AssertEqual(expired[0].Text, "one three", t)
+	testutil.AssertEqual(expired[0].Complete(), false, t)
+	testutil.AssertEqual(len(expired[0].Missing), 1, t)
+	testutil.AssertEqual(expired[0].Missing[0], 2, t)
+	testutil.AssertEqual(r.Pending(), 1, t)
+}
@@ -0,0 +1,350 @@
+// Package proctree correlates events with the processes that caused them.
+// A Tree learns parent/child relationships from process creation events,
+// Security 4688 and Sysmon 1, and annotates later events from the same
+// processes with a process GUID and their ancestry:
+//
+//	tree := proctree.NewTree(time.Hour)
+//	for ev := range watcher.Event() {
+//		tree.Add(ev)
+//		if p := tree.Annotate(ev); p != nil {
+//			log.Printf("%v: %v", ev.EventId, ev.EventData[proctree.AncestryField])
+//		}
+//	}
+//
+// Processes are remembered for a bounded window after they start, so
+// ancestry is only as deep as the processes created within it.
+package proctree
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/sysmon"
+)
+
+// Security log process creation and exit events
+const (
+	EventProcessCreation = 4688
+	EventProcessExit     = 4689
+)
+
+// EventData fields set by Annotate
+const (
+	GuidField     = "ProcessGuid"
+	AncestryField = "ProcessAncestry"
+)
+
+// Default limit on the number of processes remembered
+const DefaultMaxProcesses = 100000
+
+// Process is a process seen starting.
+type Process struct {
+	// Sysmon's ProcessGuid, or for processes seen only in 4688 events, a GUID
+	// derived from the computer, ProcessId and creation time
+	Guid            string
+	Computer        string
+	ProcessId       uint64
+	ParentProcessId uint64
+	Image           string
+	CommandLine     string
+	User            string
+	Created         time.Time
+	// Zero until an exit event is seen
+	Exited time.Time
+	// nil if the parent was created before the window, or isn't known
+	Parent *Process
+}
+
+// Ancestry returns the process's known ancestors, its parent first.
+func (p *Process) Ancestry() []*Process {
+	var ancestors []*Process
+	seen := map[*Process]bool{p: true}
+	for parent := p.Parent; parent != nil && !seen[parent]; parent = parent.Parent {
+		seen[parent] = true
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// processKey identifies a process ID on a computer. IDs are reused, so
+// several processes may share a key over time.
+type processKey struct {
+	computer  string
+	processId uint64
+}
+
+// Tree tracks the processes created within a window.
+type Tree struct {
+	// How long after starting a process is remembered
+	Window time.Duration
+	// Most processes remembered. The oldest are forgotten first.
+	MaxProcesses int
+
+	mutex  sync.Mutex
+	byGuid map[string]*Process
+	// Processes with each ID, oldest first
+	byId map[processKey][]*Process
+	// All processes, in the order they were added
+	order []*Process
+}
+
+func NewTree(window time.Duration) *Tree {
+	return &Tree{
+		Window:       window,
+		MaxProcesses: DefaultMaxProcesses,
+		byGuid:       make(map[string]*Process),
+		byId:         make(map[processKey][]*Process),
+	}
+}
+
+// Add learns from process creation and exit events, returning the process
+// created or exited, or nil for other events.
+func (t *Tree) Add(ev *winlog.WinLogEvent) (*Process, error) {
+	switch {
+	case ev.ProviderName == sysmon.ProviderName && ev.EventId == sysmon.EventProcessCreate:
+		parsed, err := sysmon.Parse(ev)
+		if err != nil {
+			return nil, err
+		}
+		created := parsed.(*sysmon.ProcessCreate)
+		p := &Process{
+			Guid:            created.ProcessGuid,
+			Computer:        ev.ComputerName,
+			ProcessId:       created.ProcessId,
+			ParentProcessId: created.ParentProcessId,
+			Image:           created.Image,
+			CommandLine:     created.CommandLine,
+			User:            created.User,
+			Created:         created.UtcTime,
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		return t.add(p, created.ParentProcessGuid), nil
+
+	case ev.ProviderName == sysmon.ProviderName && ev.EventId == sysmon.EventProcessTerminate:
+		parsed, err := sysmon.Parse(ev)
+		if err != nil {
+			return nil, err
+		}
+		terminated := parsed.(*sysmon.ProcessTerminate)
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		p := t.byGuid[terminated.ProcessGuid]
+		if p != nil {
+			p.Exited = terminated.UtcTime
+		}
+		return p, nil
+
+	case isSecurity(ev) && ev.EventId == EventProcessCreation:
+		data, err := eventData(ev)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := parseId(data, "NewProcessId")
+		if err != nil {
+			return nil, err
+		}
+		ppid, err := parseId(data, "ProcessId")
+		if err != nil {
+			return nil, err
+		}
+		p := &Process{
+			Computer:        ev.ComputerName,
+			ProcessId:       pid,
+			ParentProcessId: ppid,
+			Image:           data["NewProcessName"],
+			CommandLine:     data["CommandLine"],
+			Created:         ev.Created.UTC(),
+		}
+		p.Guid = derivedGuid(p)
+		if name := data["TargetUserName"]; name != "" && name != "-" {
+			p.User = data["TargetDomainName"] + `\` + name
+		} else {
+			p.User = data["SubjectDomainName"] + `\` + data["SubjectUserName"]
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		return t.add(p, ""), nil
+
+	case isSecurity(ev) && ev.EventId == EventProcessExit:
+		data, err := eventData(ev)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := parseId(data, "ProcessId")
+		if err != nil {
+			return nil, err
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		p := t.lookup(ev.ComputerName, pid, ev.Created)
+		if p != nil {
+			p.Exited = ev.Created.UTC()
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+// Lookup returns the process with the ID that was running on the computer
+// at the given time, or nil if none is known.
+func (t *Tree) Lookup(computer string, processId uint64, at time.Time) *Process {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.lookup(computer, processId, at)
+}
+
+// LookupGuid returns the process with the GUID, or nil if none is known.
+func (t *Tree) LookupGuid(guid string) *Process {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.byGuid[guid]
+}
+
+// Annotate finds the process which caused the event and records it in the
+// event's EventData: its GUID as ProcessGuid, unless the event already has
+// one, and its image and those of its known ancestors, root first, joined
+// by " > " as ProcessAncestry. The process is found by the event's
+// ProcessGuid, or a ProcessId field in its EventData, or the ID of the
+// process which logged it. Returns the process, or nil if it isn't known.
+func (t *Tree) Annotate(ev *winlog.WinLogEvent) *Process {
+	var p *Process
+	if guid := ev.EventData[GuidField]; guid != "" {
+		p = t.LookupGuid(guid)
+	} else if _, ok := ev.EventData["ProcessId"]; ok {
+		if pid, err := parseId(ev.EventData, "ProcessId"); err == nil {
+			p = t.Lookup(ev.ComputerName, pid, ev.Created)
+		}
+	} else {
+		p = t.Lookup(ev.ComputerName, ev.ProcessId, ev.Created)
+	}
+	if p == nil {
+		return nil
+	}
+
+	ancestry := p.Ancestry()
+	images := make([]string, 0, len(ancestry)+1)
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		images = append(images, ancestry[i].Image)
+	}
+	images = append(images, p.Image)
+	if ev.EventData == nil {
+		ev.EventData = make(map[string]string)
+	}
+	if ev.EventData[GuidField] == "" {
+		ev.EventData[GuidField] = p.Guid
+	}
+	ev.EventData[AncestryField] = strings.Join(images, " > ")
+	return p
+}
+
+// Len returns the number of processes remembered.
+func (t *Tree) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.byGuid)
+}
+
+// add remembers a new process, returning it, or the process with the
+// same GUID if one is already known, as when an event is delivered twice.
+// Must be called with the mutex held.
+func (t *Tree) add(p *Process, parentGuid string) *Process {
+	if existing, ok := t.byGuid[p.Guid]; ok {
+		return existing
+	}
+	// Forget processes from before the window first, so they aren't parents
+	t.evict(p.Created)
+	if parentGuid != "" {
+		p.Parent = t.byGuid[parentGuid]
+	}
+	if p.Parent == nil {
+		p.Parent = t.lookup(p.Computer, p.ParentProcessId, p.Created)
+	}
+	t.byGuid[p.Guid] = p
+	key := processKey{p.Computer, p.ProcessId}
+	processes := append(t.byId[key], p)
+	sort.SliceStable(processes, func(i, j int) bool {
+		return processes[i].Created.Before(processes[j].Created)
+	})
+	t.byId[key] = processes
+	t.order = append(t.order, p)
+	t.evict(p.Created)
+	return p
+}
+
+// lookup finds the latest process with the ID created at or before `at`.
+// Must be called with the mutex held.
+func (t *Tree) lookup(computer string, processId uint64, at time.Time) *Process {
+	processes := t.byId[processKey{computer, processId}]
+	for i := len(processes) - 1; i >= 0; i-- {
+		if !processes[i].Created.After(at) {
+			return processes[i]
+		}
+	}
+	return nil
+}
+
+// evict forgets processes created before the window, or beyond
+// MaxProcesses. Must be called with the mutex held.
+func (t *Tree) evict(now time.Time) {
+	n := 0
+	for n < len(t.order) {
+		p := t.order[n]
+		if now.Sub(p.Created) <= t.Window && (t.MaxProcesses <= 0 || len(t.order)-n <= t.MaxProcesses) {
+			break
+		}
+		delete(t.byGuid, p.Guid)
+		key := processKey{p.Computer, p.ProcessId}
+		processes := t.byId[key]
+		for i, other := range processes {
+			if other == p {
+				processes = append(processes[:i], processes[i+1:]...)
+				break
+			}
+		}
+		if len(processes) == 0 {
+			delete(t.byId, key)
+		} else {
+			t.byId[key] = processes
+		}
+		n++
+	}
+	t.order = append(t.order[:0:0], t.order[n:]...)
+}
+
+func isSecurity(ev *winlog.WinLogEvent) bool {
+	return ev.ProviderName == "" || ev.ProviderName == "Microsoft-Windows-Security-Auditing"
+}
+
+func eventData(ev *winlog.WinLogEvent) (map[string]string, error) {
+	if ev.EventData != nil || len(ev.Xml) == 0 {
+		return ev.EventData, nil
+	}
+	data, err := winlog.ParseEventData(ev.Xml)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+	}
+	return data, nil
+}
+
+// parseId parses a process ID field, which the Security log writes in hex.
+func parseId(data map[string]string, name string) (uint64, error) {
+	id, err := strconv.ParseUint(data[name], 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse %v %q: %v", name, data[name], err)
+	}
+	return id, nil
+}
+
+// derivedGuid makes a GUID for a process from its identity, so the same
+// process gets the same GUID when its events are processed again.
+func derivedGuid(p *Process) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v\x00%v\x00%v", p.Computer, p.ProcessId, p.Created.UnixNano())))
+	return fmt.Sprintf("{%x-%x-%x-%x-%x}", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,125 @@
+package proctree
+
+import (
+	"strconv"
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/sysmon"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+var start = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func processCreation(pid, ppid uint64, image string, created time.Time) *winlog.WinLogEvent {
+	return &winlog.WinLogEvent{
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      EventProcessCreation,
+		ComputerName: "ws01",
+		Created:      created,
+		EventData: map[string]string{
+			"SubjectUserName":   "alice",
+			"SubjectDomainName": "CORP",
+			"NewProcessId":      "0x" + strconv.FormatUint(pid, 16),
+			"NewProcessName":    image,
+			"ProcessId":         "0x" + strconv.FormatUint(ppid, 16),
+			"CommandLine":       image,
+			"TargetUserName":    "-",
+		},
+	}
+}
+
+func sysmonCreate(guid, parentGuid string, pid, ppid uint64, image string, created time.Time) *winlog.WinLogEvent {
+	return &winlog.WinLogEvent{
+		ProviderName: sysmon.ProviderName,
+		EventId:      sysmon.EventProcessCreate,
+		ComputerName: "ws01",
+		Created:      created,
+		EventData: map[string]string{
+			"UtcTime":           created.Format("2006-01-02 15:04:05.000"),
+			"ProcessGuid":       guid,
+			"ProcessId":         strconv.FormatUint(pid, 10),
+			"Image":             image,
+			"User":              `CORP\alice`,
+			"ParentProcessGuid": parentGuid,
+			"ParentProcessId":   strconv.FormatUint(ppid, 10),
+		},
+	}
+}
+
+func TestSecurityTree(t *T) {
+	tree := NewTree(time.Hour)
+	explorer, err := tree.Add(processCreation(100, 4, `C:\Windows\explorer.exe`, start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(explorer.User, `CORP\alice`, t)
+	testutil.AssertEqual(explorer.Parent == nil, true, t)
+	cmd, _ := tree.Add(processCreation(200, 100, `C:\Windows\System32\cmd.exe`, start.Add(time.Second)))
+	testutil.AssertEqual(cmd.Parent, explorer, t)
+	whoami, _ := tree.Add(processCreation(300, 200, `C:\Windows\System32\whoami.exe`, start.Add(2*time.Second)))
+	ancestry := whoami.Ancestry()
+	testutil.AssertEqual(len(ancestry), 2, t)
+	testutil.AssertEqual(ancestry[0], cmd, t)
+	testutil.AssertEqual(ancestry[1], explorer, t)
+
+	// The same event again doesn't add a process
+	again, _ := tree.Add(processCreation(300, 200, `C:\Windows\System32\whoami.exe`, start.Add(2*time.Second)))
+	testutil.AssertEqual(again, whoami, t)
+	testutil.AssertEqual(tree.Len(), 3, t)
+
+	// A later event from the process is annotated
+	ev := &winlog.WinLogEvent{EventId: 4663, ComputerName: "ws01", Created: start.Add(3 * time.Second), EventData: map[string]string{"ProcessId": "0x12c"}}
+	testutil.AssertEqual(tree.Annotate(ev), whoami, t)
+	testutil.AssertEqual(ev.EventData[GuidField], whoami.Guid, t)
+	testutil.AssertEqual(ev.EventData[AncestryField], `C:\Windows\explorer.exe > C:\Windows\System32\cmd.exe > C:\Windows\System32\whoami.exe`, t)
+
+	// Process IDs are reused after exit
+	tree.Add(&winlog.WinLogEvent{EventId: EventProcessExit, ComputerName: "ws01", Created: start.Add(4 * time.Second), EventData: map[string]string{"ProcessId": "0x12c"}})
+	testutil.AssertEqual(whoami.Exited, start.Add(4*time.Second), t)
+	notepad, _ := tree.Add(processCreation(300, 100, `C:\Windows\notepad.exe`, start.Add(5*time.Second)))
+	testutil.AssertEqual(tree.Lookup("ws01", 300, start.Add(3*time.Second)), whoami, t)
+	testutil.AssertEqual(tree.Lookup("ws01", 300, start.Add(6*time.Second)), notepad, t)
+	testutil.AssertEqual(tree.Lookup("ws02", 300, start.Add(6*time.Second)) == nil, true, t)
+	if whoami.Guid == notepad.Guid {
+		t.Fatal("Processes with the same ID have the same GUID")
+	}
+
+	// Unknown processes aren't annotated
+	ev = &winlog.WinLogEvent{EventId: 4663, ComputerName: "ws01", Created: start, ProcessId: 999}
+	testutil.AssertEqual(tree.Annotate(ev) == nil, true, t)
+	testutil.AssertEqual(ev.EventData == nil, true, t)
+}
+
+func TestSysmonTree(t *T) {
+	tree := NewTree(time.Hour)
+	parent, err := tree.Add(sysmonCreate("{p}", "{unknown}", 100, 4, `C:\Windows\explorer.exe`, start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, _ := tree.Add(sysmonCreate("{c}", "{p}", 200, 100, `C:\Windows\System32\cmd.exe`, start.Add(time.Second)))
+	testutil.AssertEqual(child.Parent, parent, t)
+	testutil.AssertEqual(tree.LookupGuid("{c}"), child, t)
+
+	network := &winlog.WinLogEvent{ProviderName: sysmon.ProviderName, EventId: sysmon.EventNetworkConnect, EventData: map[string]string{"ProcessGuid": "{c}"}}
+	testutil.AssertEqual(tree.Annotate(network), child, t)
+	testutil.AssertEqual(network.EventData[AncestryField], `C:\Windows\explorer.exe > C:\Windows\System32\cmd.exe`, t)
+
+	tree.Add(&winlog.WinLogEvent{ProviderName: sysmon.ProviderName, EventId: sysmon.EventProcessTerminate, EventData: map[string]string{"ProcessGuid": "{c}", "UtcTime": "2021-03-04 05:06:10.000"}})
+	testutil.AssertEqual(child.Exited, start.Add(3*time.Second), t)
+}
+
+func TestEviction(t *T) {
+	tree := NewTree(time.Minute)
+	tree.Add(processCreation(100, 4, "first", start))
+	tree.Add(processCreation(200, 100, "second", start.Add(30*time.Second)))
+	third, _ := tree.Add(processCreation(300, 100, "third", start.Add(61*time.Second)))
+	testutil.AssertEqual(tree.Len(), 2, t)
+	testutil.AssertEqual(third.Parent == nil, true, t)
+	testutil.AssertEqual(tree.Lookup("ws01", 100, start.Add(time.Minute)) == nil, true, t)
+
+	tree.MaxProcesses = 1
+	tree.Add(processCreation(400, 300, "fourth", start.Add(62*time.Second)))
+	testutil.AssertEqual(tree.Len(), 1, t)
+}
@@ -0,0 +1,152 @@
+package winlog
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PublisherTables holds the display names of the levels, tasks, opcodes and
+// keywords a provider declares, so events' numeric fields can be named
+// without calling EvtFormatMessage for each event.
+type PublisherTables struct {
+	Levels map[uint64]string
+	Tasks  map[uint64]string
+	// Keyed by OpcodeKey. Opcodes declared within a task apply only to that
+	// task's events.
+	Opcodes  map[OpcodeKey]string
+	Keywords []KeywordName
+}
+
+// OpcodeKey identifies an opcode, which a provider may declare globally,
+// with Task 0, or for one task.
+type OpcodeKey struct {
+	Task   uint64
+	Opcode uint64
+}
+
+// KeywordName is a keyword bit, or bits, and its display name.
+type KeywordName struct {
+	Mask uint64
+	Name string
+}
+
+// LevelText returns the name of a level, or "" if it isn't declared.
+func (p *PublisherTables) LevelText(level uint64) string {
+	return p.Levels[level]
+}
+
+// TaskText returns the name of a task, or "" if it isn't declared.
+func (p *PublisherTables) TaskText(task uint64) string {
+	return p.Tasks[task]
+}
+
+// OpcodeText returns the name of an opcode of a task's event, looking for
+// an opcode declared for the task before a global one, or "" if neither is
+// declared.
+func (p *PublisherTables) OpcodeText(task, opcode uint64) string {
+	if name, ok := p.Opcodes[OpcodeKey{Task: task, Opcode: opcode}]; ok {
+		return name
+	}
+	return p.Opcodes[OpcodeKey{Opcode: opcode}]
+}
+
+// KeywordsText returns the names of the keywords set in the mask, joined
+// with commas, and whether all the set bits were named.
+func (p *PublisherTables) KeywordsText(mask uint64) (string, bool) {
+	var names []string
+	var named uint64
+	for _, keyword := range p.Keywords {
+		if keyword.Mask != 0 && mask&keyword.Mask == keyword.Mask {
+			names = append(names, keyword.Name)
+			named |= keyword.Mask
+		}
+	}
+	return strings.Join(names, ","), mask&^named == 0
+}
+
+// sortKeywords orders keywords by mask, so KeywordsText is stable.
+func (p *PublisherTables) sortKeywords() {
+	sort.Slice(p.Keywords, func(i, j int) bool {
+		return p.Keywords[i].Mask < p.Keywords[j].Mask
+	})
+}
+
+// publisherTableCache remembers each provider's tables, including failures
+// to read them, so they're only read once, and the text formatted for
+// values missing from them.
+type publisherTableCache struct {
+	mutex      sync.Mutex
+	byProvider map[string]*providerNames
+}
+
+type providerNames struct {
+	// nil if the tables couldn't be read
+	tables    *PublisherTables
+	formatted map[formattedKey]string
+}
+
+type formattedKey struct {
+	flags EVT_FORMAT_MESSAGE_FLAGS
+	task  uint64
+	value uint64
+}
+
+// text returns the name of a level, task, opcode or keywords value from the
+// provider's tables, or else from `format`, which returns whether it
+// succeeded, remembering the result. An error reading the tables is only
+// returned the first time.
+func (c *publisherTableCache) text(api EventLogAPI, providerName string, flags EVT_FORMAT_MESSAGE_FLAGS, task, value uint64, format func() (string, bool)) (string, error) {
+	c.mutex.Lock()
+	var err error
+	names, ok := c.byProvider[providerName]
+	if !ok {
+		names = &providerNames{formatted: make(map[formattedKey]string)}
+		names.tables, err = api.PublisherTables(providerName)
+		if c.byProvider == nil {
+			c.byProvider = make(map[string]*providerNames)
+		}
+		c.byProvider[providerName] = names
+	}
+	if text := names.lookup(flags, task, value); text != "" {
+		c.mutex.Unlock()
+		return text, err
+	}
+	if flags != EvtFormatMessageOpcode {
+		task = 0
+	}
+	key := formattedKey{flags: flags, task: task, value: value}
+	text, ok := names.formatted[key]
+	c.mutex.Unlock()
+	if ok {
+		return text, err
+	}
+
+	// Format without holding the mutex, since EvtFormatMessage is slow
+	text, ok = format()
+	if ok {
+		c.mutex.Lock()
+		names.formatted[key] = text
+		c.mutex.Unlock()
+	}
+	return text, err
+}
+
+func (n *providerNames) lookup(flags EVT_FORMAT_MESSAGE_FLAGS, task, value uint64) string {
+	if n.tables == nil {
+		return ""
+	}
+	switch flags {
+	case EvtFormatMessageLevel:
+		return n.tables.LevelText(value)
+	case EvtFormatMessageTask:
+		return n.tables.TaskText(value)
+	case EvtFormatMessageOpcode:
+		return n.tables.OpcodeText(task, value)
+	case EvtFormatMessageKeyword:
+		if text, complete := n.tables.KeywordsText(value); complete {
+			return text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestPublisherTables(t *T) {
+	tables := &PublisherTables{
+		Levels: map[uint64]string{LevelInformation: "Information"},
+		Tasks:  map[uint64]string{12544: "Logon"},
+		Opcodes: map[OpcodeKey]string{
+			{Opcode: 1}:              "Start",
+			{Task: 12544, Opcode: 1}: "Logon started",
+		},
+		Keywords: []KeywordName{
+			{Mask: 0x10000000000000, Name: "Audit Failure"},
+			{Mask: 0x20000000000000, Name: "Audit Success"},
+			{Mask: 0x8000000000000000, Name: "Classic"},
+		},
+	}
+	assertEqual(tables.LevelText(LevelInformation), "Information", t)
+	assertEqual(tables.LevelText(LevelError), "", t)
+	assertEqual(tables.TaskText(12544), "Logon", t)
+	assertEqual(tables.OpcodeText(12544, 1), "Logon started", t)
+	assertEqual(tables.OpcodeText(12545, 1), "Start", t)
+	assertEqual(tables.OpcodeText(12545, 2), "", t)
+
+	text, complete := tables.KeywordsText(0x8020000000000000)
+	assertEqual(text, "Audit Success,Classic", t)
+	assertEqual(complete, true, t)
+	text, complete = tables.KeywordsText(0x8020000000000001)
+	assertEqual(text, "Audit Success,Classic", t)
+	assertEqual(complete, false, t)
+}
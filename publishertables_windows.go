@@ -0,0 +1,189 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Message ID of metadata without a localized name
+const noMessageId = 0xffffffff
+
+// GetPublisherTables reads the levels, tasks, opcodes and keywords a
+// provider declares, named with their localized display names where the
+// provider has them, or their symbolic names otherwise. Wraps
+// EvtGetPublisherMetadataProperty and the EvtGetObjectArray functions.
+func GetPublisherTables(providerName string) (*PublisherTables, error) {
+	return GetSessionPublisherTables(0, providerName)
+}
+
+// GetSessionPublisherTables reads the tables declared by a provider on the
+// computer of a session opened with OpenSession.
+func GetSessionPublisherTables(session SessionHandle, providerName string) (*PublisherTables, error) {
+	handle, err := OpenSessionPublisherMetadata(session, providerName, "")
+	if err != nil {
+		return nil, err
+	}
+	publisher := syscall.Handle(handle)
+	defer EvtClose(publisher)
+
+	tables := &PublisherTables{
+		Levels:  make(map[uint64]string),
+		Tasks:   make(map[uint64]string),
+		Opcodes: make(map[OpcodeKey]string),
+	}
+	err = publisherArray(publisher, EvtPublisherMetadataLevels, func(item *metadataItem) error {
+		value := item.uint(EvtPublisherMetadataLevelValue)
+		tables.Levels[value] = item.name(publisher, EvtPublisherMetadataLevelName, EvtPublisherMetadataLevelMessageID)
+		return item.err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read levels: %v", err)
+	}
+	err = publisherArray(publisher, EvtPublisherMetadataTasks, func(item *metadataItem) error {
+		value := item.uint(EvtPublisherMetadataTaskValue)
+		tables.Tasks[value] = item.name(publisher, EvtPublisherMetadataTaskName, EvtPublisherMetadataTaskMessageID)
+		return item.err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read tasks: %v", err)
+	}
+	err = publisherArray(publisher, EvtPublisherMetadataOpcodes, func(item *metadataItem) error {
+		// The opcode is in the high word and its task, or 0, in the low word
+		value := item.uint(EvtPublisherMetadataOpcodeValue)
+		key := OpcodeKey{Task: value & 0xffff, Opcode: value >> 16}
+		tables.Opcodes[key] = item.name(publisher, EvtPublisherMetadataOpcodeName, EvtPublisherMetadataOpcodeMessageID)
+		return item.err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read opcodes: %v", err)
+	}
+	err = publisherArray(publisher, EvtPublisherMetadataKeywords, func(item *metadataItem) error {
+		mask := item.uint(EvtPublisherMetadataKeywordValue)
+		name := item.name(publisher, EvtPublisherMetadataKeywordName, EvtPublisherMetadataKeywordMessageID)
+		tables.Keywords = append(tables.Keywords, KeywordName{Mask: mask, Name: name})
+		return item.err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read keywords: %v", err)
+	}
+	tables.sortKeywords()
+	return tables, nil
+}
+
+// metadataItem reads the properties of one element of an object array,
+// remembering the first error.
+type metadataItem struct {
+	array syscall.Handle
+	index uint32
+	buf   []byte
+	err   error
+}
+
+func (m *metadataItem) property(id uint32) EvtVariant {
+	if m.err != nil {
+		return nil
+	}
+	var used uint32
+	err := EvtGetObjectArrayProperty(m.array, id, m.index, 0, uint32(len(m.buf)), &m.buf[0], &used)
+	if errors.Is(err, windows.ERROR_INSUFFICIENT_BUFFER) {
+		m.buf = make([]byte, used)
+		err = EvtGetObjectArrayProperty(m.array, id, m.index, 0, uint32(len(m.buf)), &m.buf[0], &used)
+	}
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	return NewEvtVariant(m.buf[:used])
+}
+
+func (m *metadataItem) uint(id uint32) uint64 {
+	value := m.property(id)
+	if value == nil {
+		return 0
+	}
+	n, err := value.Uint(0)
+	if err != nil && m.err == nil {
+		m.err = err
+	}
+	return n
+}
+
+// name returns the localized name of the item, or its symbolic name if it
+// has none.
+func (m *metadataItem) name(publisher syscall.Handle, nameId, messageId uint32) string {
+	var name string
+	if value := m.property(nameId); value != nil && !value.IsNull(0) {
+		name, _ = value.String(0)
+	}
+	id := m.uint(messageId)
+	if m.err != nil || id == noMessageId {
+		return name
+	}
+	if text, err := formatMessageId(publisher, uint32(id)); err == nil && text != "" {
+		return text
+	}
+	return name
+}
+
+// publisherArray calls `each` for the elements of an object array property
+// of the publisher's metadata.
+func publisherArray(publisher syscall.Handle, property uint32, each func(item *metadataItem) error) error {
+	buf := make([]byte, 256)
+	var used uint32
+	err := EvtGetPublisherMetadataProperty(publisher, property, 0, uint32(len(buf)), &buf[0], &used)
+	if errors.Is(err, windows.ERROR_INSUFFICIENT_BUFFER) {
+		buf = make([]byte, used)
+		err = EvtGetPublisherMetadataProperty(publisher, property, 0, uint32(len(buf)), &buf[0], &used)
+	}
+	if err != nil {
+		return err
+	}
+	elem, err := NewEvtVariant(buf[:used]).elemAt(0)
+	if err != nil {
+		return err
+	}
+	if elem.Type == EvtVarTypeNull {
+		return nil
+	} else if elem.Type != EvtVarTypeEvtHandle {
+		return fmt.Errorf("Property %v is not an object array, type is %v", property, elem.Type)
+	}
+	array := syscall.Handle(elem.Data)
+	defer EvtClose(array)
+
+	var size uint32
+	if err := EvtGetObjectArraySize(array, &size); err != nil {
+		return err
+	}
+	item := &metadataItem{array: array, buf: make([]byte, 256)}
+	for i := uint32(0); i < size; i++ {
+		item.index = i
+		if err := each(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatMessageId formats one of the publisher's messages. Wraps
+// EvtFormatMessage with EvtFormatMessageId.
+func formatMessageId(publisher syscall.Handle, messageId uint32) (string, error) {
+	var size uint32
+	err := EvtFormatMessage(publisher, 0, messageId, 0, nil, EvtFormatMessageId, 0, nil, &size)
+	if err != nil && !errors.Is(err, windows.ERROR_INSUFFICIENT_BUFFER) {
+		return "", err
+	}
+	if size == 0 {
+		return "", nil
+	}
+	buf := make([]uint16, size)
+	if err := EvtFormatMessage(publisher, 0, messageId, 0, nil, EvtFormatMessageId, uint32(len(buf)), &buf[0], &size); err != nil {
+		return "", err
+	}
+	return syscall.UTF16ToString(buf), nil
+}
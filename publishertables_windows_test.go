@@ -0,0 +1,15 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestGetPublisherTables(t *T) {
+	tables, err := GetPublisherTables("Microsoft-Windows-Security-Auditing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables.TaskText(12544) == "" {
+		t.Fatalf("No name for the Logon task in %v", tables.Tasks)
+	}
+}
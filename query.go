@@ -0,0 +1,126 @@
+package winlog
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// QueryResult reads the events matching a query, made with QueryChannel,
+// QueryFile or their reverse variants.
+type QueryResult struct {
+	api    EventLogAPI
+	handle QueryHandle
+}
+
+func QueryChannel(channel, query string) (*QueryResult, error) {
+	return queryPath(SystemEventLogAPI, channel, query, EvtQueryChannelPath)
+}
+
+// QueryFile reads the events matching `query` from an exported .evtx file.
+func QueryFile(path, query string) (*QueryResult, error) {
+	return queryPath(SystemEventLogAPI, path, query, EvtQueryFilePath)
+}
+
+// QueryChannelReverse reads the events matching `query` newest first, so the
+// most recent N events can be read without scanning the whole channel.
+func QueryChannelReverse(channel, query string) (*QueryResult, error) {
+	return queryPath(SystemEventLogAPI, channel, query, EvtQueryChannelPath|EvtQueryReverseDirection)
+}
+
+// QueryFileReverse reads the events matching `query` from an exported .evtx
+// file, newest first.
+func QueryFileReverse(path, query string) (*QueryResult, error) {
+	return queryPath(SystemEventLogAPI, path, query, EvtQueryFilePath|EvtQueryReverseDirection)
+}
+
+// queryStructured runs a structured XML query, whose <Select> elements name
+// the channels or files they read.
+func queryStructured(query string, flags uint32) (*QueryResult, error) {
+	return queryPath(SystemEventLogAPI, "", query, flags)
+}
+
+// queryPath runs `query` through `api` on the channel or file at `path`,
+// which must be empty for structured XML queries.
+func queryPath(api EventLogAPI, path, query string, flags uint32) (*QueryResult, error) {
+	handle, err := api.Query(path, query, flags)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResult{api: api, handle: handle}, nil
+}
+
+func (qr *QueryResult) Close() error {
+	if qr.handle == 0 {
+		return nil
+	}
+	if err := qr.api.Close(uint64(qr.handle)); err != nil {
+		return err
+	}
+	qr.handle = 0
+	return nil
+}
+
+// Next waits up to `timeout` for the next event, returning io.EOF once every
+// matching event has been read. The handle must be closed with
+// CloseEventHandle.
+func (qr *QueryResult) Next(timeout time.Duration) (EventHandle, error) {
+	var events [1]EventHandle
+	if _, err := qr.api.NextEvents(qr.handle, events[:], timeout); err != nil {
+		return 0, err
+	}
+	return events[0], nil
+}
+
+// NextContext is Next, but returns ctx.Err() as soon as `ctx` is done
+// rather than when `timeout` expires, by cancelling the query. A cancelled
+// query can't be read any further, only closed.
+func (qr *QueryResult) NextContext(ctx context.Context, timeout time.Duration) (EventHandle, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if ctx.Done() == nil {
+		return qr.Next(timeout)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			qr.api.Cancel(uint64(qr.handle))
+		case <-done:
+		}
+	}()
+	handle, err := qr.Next(timeout)
+	if err != nil && ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	return handle, err
+}
+
+// Number of handles requested from EvtNext at a time when counting events
+const countBatchSize = 512
+
+// count reads up to `limit` events without rendering them, closing each
+// handle, and returns how many were read.
+func (qr *QueryResult) count(limit uint64, timeout time.Duration) (uint64, error) {
+	handles := make([]EventHandle, countBatchSize)
+	var total uint64
+	for total < limit {
+		batch := uint64(countBatchSize)
+		if remaining := limit - total; remaining < batch {
+			batch = remaining
+		}
+		returned, err := qr.api.NextEvents(qr.handle, handles[:batch], timeout)
+		for _, handle := range handles[:returned] {
+			qr.api.Close(uint64(handle))
+		}
+		total += uint64(returned)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,185 @@
+package winlog
+
+import (
+	"io"
+	. "testing"
+	"time"
+)
+
+// useFakeAPI makes the package-level query functions call `api` until the
+// test ends.
+func useFakeAPI(t *T, api EventLogAPI) {
+	system := SystemEventLogAPI
+	SystemEventLogAPI = api
+	t.Cleanup(func() { SystemEventLogAPI = system })
+}
+
+func emitRecords(api *fakeAPI, channel string, count int) {
+	for recordId := 1; recordId <= count; recordId++ {
+		api.emit(channel, fakeValues{EvtSystemEventRecordId: uint64(recordId)})
+	}
+}
+
+func readRecordIds(t *T, api *fakeAPI, result *QueryResult) []uint64 {
+	t.Helper()
+	var recordIds []uint64
+	for {
+		handle, err := result.Next(time.Second)
+		if err == io.EOF {
+			return recordIds
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		recordId, err := api.events[uint64(handle)].Uint(EvtSystemEventRecordId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recordIds = append(recordIds, recordId)
+		api.Close(uint64(handle))
+	}
+}
+
+func TestQueryReverse(t *T) {
+	api := newFakeAPI()
+	useFakeAPI(t, api)
+	emitRecords(api, "Application", 3)
+	emitRecords(api, `C:\logs\app.evtx`, 3)
+
+	for _, c := range []struct {
+		name  string
+		query func(path, query string) (*QueryResult, error)
+		path  string
+		flags uint32
+	}{
+		{"QueryChannel", QueryChannel, "Application", EvtQueryChannelPath},
+		{"QueryChannelReverse", QueryChannelReverse, "Application", EvtQueryChannelPath | EvtQueryReverseDirection},
+		{"QueryFile", QueryFile, `C:\logs\app.evtx`, EvtQueryFilePath},
+		{"QueryFileReverse", QueryFileReverse, `C:\logs\app.evtx`, EvtQueryFilePath | EvtQueryReverseDirection},
+	} {
+		t.Run(c.name, func(t *T) {
+			result, err := c.query(c.path, "*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Close()
+			assertEqual(api.queryFlags[uint64(result.handle)], c.flags, t)
+			assertEqual(api.queries[uint64(result.handle)], "*", t)
+
+			recordIds := readRecordIds(t, api, result)
+			assertEqual(len(recordIds), 3, t)
+			first, last := uint64(1), uint64(3)
+			if c.flags&EvtQueryReverseDirection != 0 {
+				first, last = last, first
+			}
+			assertEqual(recordIds[0], first, t)
+			assertEqual(recordIds[1], uint64(2), t)
+			assertEqual(recordIds[2], last, t)
+		})
+	}
+}
+
+func TestQueryResultClose(t *T) {
+	api := newFakeAPI()
+	result, err := queryPath(api, "Application", "*", EvtQueryChannelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := uint64(result.handle)
+	if err := result.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(api.closed[handle], true, t)
+	// Closing again doesn't close the handle, which may have been reused
+	if err := result.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCountEventsBatches(t *T) {
+	api := newFakeAPI()
+	total := 2*countBatchSize + 10
+	emitRecords(api, "Application", total)
+
+	count, more, err := countEvents(api, "Application", "*", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(count, uint64(total), t)
+	assertEqual(more, false, t)
+	// Two full batches, a partial one, then ERROR_NO_MORE_ITEMS
+	assertEqual(len(api.nextSizes), 4, t)
+	for _, size := range api.nextSizes {
+		assertEqual(size, countBatchSize, t)
+	}
+	// The query and every event handle it returned are closed. Emitting
+	// used the first `total` handles.
+	for handle := uint64(total) + 1; handle <= api.next; handle++ {
+		if !api.closed[handle] {
+			t.Fatalf("Handle %v wasn't closed", handle)
+		}
+	}
+	assertEqual(api.next, uint64(2*total+1), t)
+}
+
+func TestCountEventsLimit(t *T) {
+	api := newFakeAPI()
+	total := countBatchSize + 1
+	emitRecords(api, "Application", total)
+
+	for _, c := range []struct {
+		limit uint64
+		count uint64
+		more  bool
+	}{
+		{uint64(total - 1), uint64(total - 1), true},
+		{uint64(total), uint64(total), false},
+		{uint64(total + 1), uint64(total), false},
+		{1, 1, true},
+	} {
+		api.nextSizes = nil
+		count, more, err := countEvents(api, "Application", "*", c.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(count, c.count, t)
+		assertEqual(more, c.more, t)
+		// Only one past the limit is read
+		read := 0
+		for _, size := range api.nextSizes {
+			read += size
+		}
+		if c.limit < uint64(total) {
+			assertEqual(read, int(c.limit)+1, t)
+		}
+	}
+}
+
+func TestCountEventsFile(t *T) {
+	api := newFakeAPI()
+	emitRecords(api, `C:\logs\app.evtx`, 3)
+	count, more, err := countEvents(api, `C:\logs\app.evtx`, "*[System[EventID=1]]", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(count, uint64(3), t)
+	assertEqual(more, false, t)
+	for handle, flags := range api.queryFlags {
+		assertEqual(flags, uint32(EvtQueryFilePath), t)
+		assertEqual(api.queries[handle], "*[System[EventID=1]]", t)
+		assertEqual(api.closed[handle], true, t)
+	}
+}
+
+func TestCountEventsError(t *T) {
+	api := newFakeAPI()
+	emitRecords(api, "Application", 3)
+	result, err := queryPath(api, "Application", "*", EvtQueryChannelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A query closed under the count fails rather than counting zero
+	api.Close(uint64(result.handle))
+	if _, err := result.count(10, time.Second); err == nil {
+		t.Fatal("Expected an error reading a closed query")
+	}
+}
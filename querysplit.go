@@ -0,0 +1,153 @@
+package winlog
+
+import (
+	"strings"
+)
+
+/* Splitting of XPath queries which exceed MaxQueryExpressions. Queries of the
+   form *[System[A and (B or C or ...) and ...]] are split on the or-terms of
+   their largest clause, giving several queries which together select the same
+   events. Queries which can't be split are returned unchanged. */
+
+// countExpressions counts the comparisons in an XPath query, ignoring
+// anything inside string literals.
+func countExpressions(query string) int {
+	count := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '=':
+			count++
+		case c == '<' || c == '>':
+			if i+1 >= len(query) || query[i+1] != '=' {
+				count++
+			}
+		case c == '!':
+			// Counted by the following '='
+		}
+	}
+	return count
+}
+
+// splitTopLevel splits s on `sep` wherever it appears outside brackets,
+// parentheses and string literals.
+func splitTopLevel(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// stripParens removes a pair of parentheses enclosing the whole of s.
+func stripParens(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return s
+	}
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 && i < len(s)-1 {
+				// Closed before the end, as in "(a) or (b)"
+				return s
+			}
+		}
+	}
+	return s[1 : len(s)-1]
+}
+
+// splitQuery splits a query exceeding MaxQueryExpressions into several
+// queries within the limit, if its structure allows.
+func splitQuery(query string) []string {
+	if countExpressions(query) <= MaxQueryExpressions {
+		return []string{query}
+	}
+	trimmed := strings.TrimSpace(query)
+	const prefix, suffix = "*[System[", "]]"
+	if !strings.HasPrefix(trimmed, prefix) || !strings.HasSuffix(trimmed, suffix) {
+		return []string{query}
+	}
+	inner := trimmed[len(prefix) : len(trimmed)-len(suffix)]
+	clauses := splitTopLevel(inner, " and ")
+
+	// Pick the most expensive clause which is a disjunction
+	best, bestCost := -1, 0
+	var bestTerms []string
+	for i, clause := range clauses {
+		terms := splitTopLevel(stripParens(clause), " or ")
+		if cost := countExpressions(clause); len(terms) > 1 && cost > bestCost {
+			best, bestCost, bestTerms = i, cost, terms
+		}
+	}
+	if best < 0 {
+		return []string{query}
+	}
+	budget := MaxQueryExpressions - (countExpressions(inner) - bestCost)
+	if budget < 1 {
+		return []string{query}
+	}
+
+	var chunks [][]string
+	var current []string
+	cost := 0
+	for _, term := range bestTerms {
+		termCost := countExpressions(term)
+		if cost+termCost > budget && len(current) > 0 {
+			chunks = append(chunks, current)
+			current, cost = nil, 0
+		}
+		current = append(current, term)
+		cost += termCost
+	}
+	chunks = append(chunks, current)
+	if len(chunks) == 1 {
+		// A single term is over budget, nothing more can be done
+		return []string{query}
+	}
+
+	var queries []string
+	for _, chunk := range chunks {
+		replaced := append([]string(nil), clauses...)
+		replaced[best] = "(" + strings.Join(chunk, " or ") + ")"
+		queries = append(queries, splitQuery(prefix+strings.Join(replaced, " and ")+suffix)...)
+	}
+	return queries
+}
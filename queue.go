@@ -0,0 +1,28 @@
+package winlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// newQueue gives a subscription its own buffer and delivery goroutine if
+// ChannelBufferSize is set. The goroutine exits once the queue is closed.
+func (self *WinLogWatcher) newQueue(stats *subscriptionStats) chan *WinLogEvent {
+	if self.ChannelBufferSize <= 0 {
+		return nil
+	}
+	queue := make(chan *WinLogEvent, self.ChannelBufferSize)
+	self.drains.Add(1)
+	go self.drainQueue(queue, stats)
+	return queue
+}
+
+func (self *WinLogWatcher) drainQueue(queue chan *WinLogEvent, stats *subscriptionStats) {
+	defer self.drains.Done()
+	for event := range queue {
+		if self.deliver(event, stats) {
+			stats.recordDelivered(event, time.Now())
+		}
+		atomic.AddInt64(&stats.backlog, -1)
+	}
+}
@@ -0,0 +1,48 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestChannelBuffers(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.ChannelBufferSize = 10
+	for _, channel := range []string{"Security", "System"} {
+		if err := watcher.SubscribeFromNow(channel, "*"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Callbacks return without waiting for the consumer
+	emitted := make(chan struct{})
+	go func() {
+		for id := uint64(1); id <= 5; id++ {
+			api.emit("Security", fakeValues{EvtSystemEventID: id})
+		}
+		api.emit("System", fakeValues{EvtSystemEventID: uint64(6)})
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Callbacks blocked on the consumer")
+	}
+	assertEqual(watcher.Stats()["Security"].Backlog, int64(5), t)
+
+	received := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		ev := <-watcher.Event()
+		received[ev.SubscribedChannel]++
+	}
+	assertEqual(received["Security"], 5, t)
+	assertEqual(received["System"], 1, t)
+
+	// Shutting down with events still queued doesn't block
+	api.emit("System", fakeValues{EvtSystemEventID: uint64(7)})
+	watcher.Shutdown()
+}
@@ -0,0 +1,171 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeWindow is a daily period, such as 01:00 to 04:00, on some days of the
+// week. A window whose End is before its Start runs past midnight, and
+// belongs to the day it starts on.
+type TimeWindow struct {
+	// Wall clock times of day, as offsets from midnight
+	Start time.Duration
+	End   time.Duration
+	// Days the window starts on, or every day if empty
+	Days []time.Weekday
+	// Time zone of the window, or the local time zone if nil
+	Location *time.Location
+}
+
+// ParseTimeWindow parses a daily window written as "HH:MM-HH:MM", such as
+// "22:00-06:00", in the local time zone.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("Invalid time window %q, expected HH:MM-HH:MM", s)
+	}
+	var window TimeWindow
+	for i, offset := range []*time.Duration{&window.Start, &window.End} {
+		t, err := time.Parse("15:04", strings.TrimSpace(parts[i]))
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("Invalid time window %q: %v", s, err)
+		}
+		*offset = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return window, nil
+}
+
+// Contains reports whether `t` falls in the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	location := w.Location
+	if location == nil {
+		location = time.Local
+	}
+	t = t.In(location)
+	// The time on the clock rather than the time since midnight, which
+	// differs by an hour on the days daylight saving starts or ends
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End && w.onDay(t.Weekday())
+	}
+	// Past midnight: late on the day the window starts, or early on the
+	// day after
+	if offset >= w.Start {
+		return w.onDay(t.Weekday())
+	}
+	return offset < w.End && w.onDay((t.Weekday()+6)%7)
+}
+
+func (w TimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// QuietRule suppresses or samples events from some providers or with some
+// event IDs during time windows.
+type QuietRule struct {
+	// Events matching any of the providers and any of the IDs. Empty lists
+	// match everything.
+	Providers []string
+	EventIds  []uint64
+	Windows   []TimeWindow
+	// Keep one in this many matching events during the windows, with
+	// their SampleRate set, rather than suppressing them all
+	SampleRate uint64
+}
+
+func (r *QuietRule) matches(ev *WinLogEvent, t time.Time) bool {
+	if len(r.Providers) > 0 && !containsFold(r.Providers, ev.ProviderName) {
+		return false
+	}
+	if len(r.EventIds) > 0 && !containsUint(r.EventIds, ev.EventId) {
+		return false
+	}
+	for _, window := range r.Windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// QuietHours is an EventFilter applying QuietRules, such as to silence
+// expected noise from backup jobs at night. Events are placed in windows by
+// their TimeCreated. Set it as a subscription's filter with
+// SetSubscriptionFilter. Suppressed events are counted in the Filtered stat.
+type QuietHours struct {
+	Rules []QuietRule
+
+	mutex  sync.Mutex
+	counts map[quietKey]uint64
+}
+
+type quietKey struct {
+	rule     int
+	provider string
+	eventId  uint64
+}
+
+// Match reports whether the event is kept: it's outside every rule's
+// windows, or kept by the first matching rule's SampleRate.
+func (q *QuietHours) Match(ev *WinLogEvent) bool {
+	t := ev.Created
+	if t.IsZero() {
+		t = time.Now()
+	}
+	for i := range q.Rules {
+		rule := &q.Rules[i]
+		if !rule.matches(ev, t) {
+			continue
+		}
+		if rule.SampleRate <= 1 {
+			return rule.SampleRate == 1
+		}
+		key := quietKey{i, ev.ProviderName, ev.EventId}
+		q.mutex.Lock()
+		if q.counts == nil {
+			q.counts = make(map[quietKey]uint64)
+		}
+		n := q.counts[key]
+		q.counts[key] = n + 1
+		q.mutex.Unlock()
+		if n%rule.SampleRate != 0 {
+			return false
+		}
+		if ev.SampleRate == 0 {
+			ev.SampleRate = 1
+		}
+		ev.SampleRate *= rule.SampleRate
+		return true
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUint(list []uint64, n uint64) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
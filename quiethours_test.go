@@ -0,0 +1,103 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestTimeWindow(t *T) {
+	window, err := ParseTimeWindow("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.Location = time.UTC
+	window.Days = []time.Weekday{time.Friday}
+	at := func(day, hour int) time.Time {
+		// 2021-01-01 was a Friday
+		return time.Date(2021, 1, day, hour, 30, 0, 0, time.UTC)
+	}
+	assertEqual(window.Contains(at(1, 23)), true, t)
+	// Early on Saturday belongs to Friday's window
+	assertEqual(window.Contains(at(2, 3)), true, t)
+	assertEqual(window.Contains(at(2, 7)), false, t)
+	assertEqual(window.Contains(at(2, 23)), false, t)
+	// Early on Friday belongs to Thursday's window
+	assertEqual(window.Contains(at(1, 3)), false, t)
+
+	daytime := TimeWindow{Start: 9 * time.Hour, End: 17 * time.Hour, Location: time.UTC}
+	assertEqual(daytime.Contains(at(4, 12)), true, t)
+	assertEqual(daytime.Contains(at(4, 17)), false, t)
+
+	// On the day daylight saving starts, 09:30 is 8.5 hours after midnight
+	if newYork, err := time.LoadLocation("America/New_York"); err == nil {
+		daytime.Location = newYork
+		assertEqual(daytime.Contains(time.Date(2021, 3, 14, 9, 30, 0, 0, newYork)), true, t)
+		assertEqual(daytime.Contains(time.Date(2021, 11, 7, 16, 30, 0, 0, newYork)), true, t)
+	}
+
+	if _, err := ParseTimeWindow("22:00"); err == nil {
+		t.Fatal("Expected an error for a window without an end")
+	}
+	if _, err := ParseTimeWindow("25:00-06:00"); err == nil {
+		t.Fatal("Expected an error for an invalid time")
+	}
+}
+
+func TestQuietHours(t *T) {
+	night := TimeWindow{Start: 22 * time.Hour, End: 6 * time.Hour, Location: time.UTC}
+	quiet := &QuietHours{Rules: []QuietRule{
+		{Providers: []string{"Microsoft-Windows-Backup"}, Windows: []TimeWindow{night}},
+		{EventIds: []uint64{4663}, Windows: []TimeWindow{night}, SampleRate: 10},
+	}}
+	midnight := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	noon := midnight.Add(12 * time.Hour)
+
+	assertEqual(quiet.Match(&WinLogEvent{ProviderName: "microsoft-windows-backup", Created: midnight}), false, t)
+	assertEqual(quiet.Match(&WinLogEvent{ProviderName: "Microsoft-Windows-Backup", Created: noon}), true, t)
+	assertEqual(quiet.Match(&WinLogEvent{ProviderName: "Other", Created: midnight}), true, t)
+
+	kept := 0
+	for i := 0; i < 30; i++ {
+		ev := &WinLogEvent{ProviderName: "Security", EventId: 4663, Created: midnight}
+		if quiet.Match(ev) {
+			kept++
+			assertEqual(ev.SampleRate, uint64(10), t)
+		}
+	}
+	assertEqual(kept, 3, t)
+}
+
+func TestQuietHoursAsSubscriptionFilter(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	always := TimeWindow{Start: 0, End: 24 * time.Hour}
+	watcher.SetSubscriptionFilter("Application", &QuietHours{Rules: []QuietRule{
+		{EventIds: []uint64{1}, Windows: []TimeWindow{always}},
+	}})
+	go func() {
+		for id := uint64(1); id <= 2; id++ {
+			api.emit("Application", fakeValues{
+				EvtSystemProviderName:  "Provider",
+				EvtSystemChannel:       "Application",
+				EvtSystemEventID:       id,
+				EvtSystemEventRecordId: id,
+			})
+		}
+	}()
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.EventId, uint64(2), t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	assertEqual(watcher.Stats()["Application"].Filtered, uint64(1), t)
+}
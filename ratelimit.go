@@ -0,0 +1,189 @@
+package winlog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// What to do with events arriving faster than a subscription's rate limit
+type RateLimitPolicy int
+
+const (
+	// Drop excess events, counting them in the subscription's Dropped stats.
+	// The bookmark still advances past dropped events.
+	RateLimitDrop RateLimitPolicy = iota
+	// Stop the subscription until the limit allows another event, then
+	// resubscribe from the bookmark so no events are lost.
+	RateLimitPause
+)
+
+// RateLimit is a token bucket limit on the events published by a subscription.
+type RateLimit struct {
+	EventsPerSecond float64
+	// Number of events which may be published in a burst above the
+	// sustained rate. Values below 1 are treated as 1.
+	Burst  int
+	Policy RateLimitPolicy
+}
+
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit *RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.EventsPerSecond, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// take removes a token if one is available. Otherwise it returns false and
+// the time until the next token.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, time.Second
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limit the rate at which events are published from the subscription on
+// `channel`. Pass nil to remove the limit.
+func (self *WinLogWatcher) SetSubscriptionRateLimit(channel string, limit *RateLimit) error {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	watch, ok := self.watches[channel]
+	if !ok {
+		return fmt.Errorf("No watcher for channel %q", channel)
+	}
+	watch.rateLimit = limit
+	watch.bucket = nil
+	if limit != nil {
+		watch.bucket = newTokenBucket(limit)
+	}
+	return nil
+}
+
+// pauseSubscription closes the subscriptions for `channel` and resubscribes
+// from the bookmark after `delay`. `handle` is the event which exceeded the
+// limit, from the subscription `split` of a split query. Must be called with
+// watchMutex held.
+func (self *WinLogWatcher) pauseSubscription(channel string, watch *channelWatcher, split int, handle EventHandle, delay time.Duration) {
+	self.log(LogWarn, "Rate limit exceeded, pausing subscription", "channel", channel, "delay", delay)
+	self.notify(Notification{Kind: NotifyPaused, Channel: channel, Message: fmt.Sprintf("Rate limit exceeded, pausing for %v", delay)})
+	watch.paused = true
+	// Resubscribing without a bookmark would lose the events logged during
+	// the pause, or read the whole log again, so make sure there's one to
+	// resume from
+	watch.bookmarkMutex.Lock()
+	if err := self.bookmarkBefore(channel, watch, split, handle); err != nil {
+		self.log(LogWarn, "Failed to bookmark paused subscription, it will resume from where it started", "channel", channel, "error", err)
+	}
+	watch.bookmarkMutex.Unlock()
+	subscriptions := watch.subscriptions
+	watch.subscriptions = nil
+	go func() {
+		// Closing a subscription waits for its callbacks to return, so this
+		// can't happen on the callback goroutine or under watchMutex.
+		for _, subscription := range subscriptions {
+			self.api.Cancel(uint64(subscription))
+			self.api.Close(uint64(subscription))
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-self.shutdown:
+			return
+		}
+
+		self.watchMutex.Lock()
+		defer self.watchMutex.Unlock()
+		if self.watches[channel] != watch {
+			// Removed while paused
+			return
+		}
+		watch.bookmarkMutex.Lock()
+		bookmarked, err := self.resumeBookmark(watch)
+		splits := append([]splitBookmark(nil), watch.splits...)
+		watch.bookmarkMutex.Unlock()
+		if err != nil {
+			go self.PublishError(fmt.Errorf("Failed to resume rate limited subscription to %q: %v", channel, err))
+			return
+		}
+		flags := watch.flags
+		if bookmarked {
+			flags = EvtSubscribeStartAfterBookmark
+		}
+		subscriptions, err := self.createListeners(channel, watch.query, flags, watch.bookmark, watch.since, splits, watch.pulls)
+		if err != nil {
+			go self.PublishError(fmt.Errorf("Failed to resume rate limited subscription to %q: %v", channel, err))
+			return
+		}
+		self.log(LogInfo, "Resumed subscription", "channel", channel, "fromBookmark", bookmarked, "subscriptions", len(subscriptions))
+		self.notify(Notification{Kind: NotifyResubscribed, Channel: channel, Message: "Resumed after rate limit pause"})
+		watch.subscriptions = subscriptions
+		watch.paused = false
+	}()
+}
+
+// bookmarkBefore bookmarks a subscription just before an event it hasn't
+// published, unless it has a bookmark already. For a split query, only the
+// subscription `split` the event is from is bookmarked, as the others may
+// not have read their records before it yet. Must be called with
+// bookmarkMutex held.
+func (self *WinLogWatcher) bookmarkBefore(channel string, watch *channelWatcher, split int, handle EventHandle) error {
+	if watch.splits == nil && watch.bookmarked {
+		return nil
+	}
+	if watch.splits != nil && (split < 0 || split >= len(watch.splits) || watch.splits[split].positioned) {
+		return nil
+	}
+	values, err := self.api.RenderValues(self.renderContext, handle)
+	if err != nil {
+		return err
+	}
+	recordId, err := values.Uint(EvtSystemEventRecordId)
+	if err != nil {
+		return err
+	}
+	if recordId > 0 {
+		recordId--
+	}
+	bookmark, err := self.api.CreateBookmark(channelBookmark(channel, recordId))
+	if err != nil {
+		return err
+	}
+	if watch.splits == nil {
+		self.api.Close(uint64(watch.bookmark))
+		watch.bookmark = bookmark
+		watch.bookmarked = true
+		return nil
+	}
+	self.api.Close(uint64(watch.splits[split].bookmark))
+	watch.splits[split] = splitBookmark{bookmark: bookmark, recordId: recordId, positioned: true}
+	watch.bookmarked = true
+	for _, s := range watch.splits {
+		watch.bookmarked = watch.bookmarked && s.positioned
+	}
+	return nil
+}
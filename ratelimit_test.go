@@ -0,0 +1,81 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestPauseFromNow(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(nextNotification(watcher, t).Kind, NotifySubscribed, t)
+	limit := &RateLimit{EventsPerSecond: 100, Policy: RateLimitPause}
+	if err := watcher.SetSubscriptionRateLimit("Application", limit); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing has been bookmarked when the limit is exceeded by record 7
+	watcher.watches["Application"].bucket.tokens = 0
+	api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(7)})
+	assertEqual(nextNotification(watcher, t).Kind, NotifyPaused, t)
+	assertEqual(nextNotification(watcher, t).Kind, NotifyResubscribed, t)
+
+	// The subscription resumes before record 7, rather than from whatever
+	// is logged after the pause
+	subscriptions := api.subscriptions("Application")
+	assertEqual(len(subscriptions), 1, t)
+	api.mutex.Lock()
+	assertEqual(api.flags[uint64(subscriptions[0])], EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAfterBookmark), t)
+	assertEqual(api.starts[uint64(subscriptions[0])], uint64(6), t)
+	api.mutex.Unlock()
+	go api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(7)})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.RecordId, uint64(7), t)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
+
+func TestPauseSplitFromBeginning(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromBeginning("Application", splitQueryOf()); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(nextNotification(watcher, t).Kind, NotifySubscribed, t)
+	subscriptions := api.subscriptions("Application")
+	assertEqual(len(subscriptions), 2, t)
+	assertEqual(publishTo(api, watcher, subscriptions[1], 5), "0", t)
+	limit := &RateLimit{EventsPerSecond: 100, Policy: RateLimitPause}
+	if err := watcher.SetSubscriptionRateLimit("Application", limit); err != nil {
+		t.Fatal(err)
+	}
+	// The first subscription hasn't read an event when the limit is
+	// exceeded by its record 7, so it's still before the oldest record
+	watcher.watches["Application"].bucket.tokens = 0
+	api.emitTo(subscriptions[0], "Application", fakeValues{EvtSystemEventRecordId: uint64(7)})
+	assertEqual(nextNotification(watcher, t).Kind, NotifyPaused, t)
+	assertEqual(nextNotification(watcher, t).Kind, NotifyResubscribed, t)
+
+	// Each resumes after its own bookmark, so the second doesn't read its
+	// records from the oldest again
+	subscriptions = api.subscriptions("Application")
+	assertEqual(len(subscriptions), 2, t)
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	for i, start := range []uint64{0, 5} {
+		assertEqual(api.flags[uint64(subscriptions[i])], EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAfterBookmark), t)
+		assertEqual(api.starts[uint64(subscriptions[i])], start, t)
+	}
+}
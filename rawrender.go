@@ -0,0 +1,74 @@
+package winlog
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Set in an EVT_VARIANT's Type if it's an array of the type
+const evtVariantTypeArray = 0x80
+
+// RenderedEvent is the raw output of EvtRender for an event: the buffer of
+// System values, and the XML as UTF-16. It can be archived as the event log
+// returned it and decoded again later.
+type RenderedEvent struct {
+	// The EVT_VARIANT array of System values, indexed by
+	// EVT_SYSTEM_PROPERTY_ID, followed by the strings, GUIDs, SIDs and
+	// arrays they point to
+	Values []byte
+	// Number of EVT_VARIANTs at the start of Values
+	PropertyCount uint32
+	// Address of Values when it was rendered, which the variants'
+	// pointers are relative to
+	ValuesAddress uint64
+	// UTF-16LE XML, including the terminating null
+	XML []byte
+}
+
+// Whether an EVT_VARIANT's Data points into the render buffer
+func (v *evtVariant) isPointer() bool {
+	if v.Type&evtVariantTypeArray != 0 {
+		return true
+	}
+	switch v.Type {
+	case EvtVarTypeString, EvtVarTypeAnsiString, EvtVarTypeBinary, EvtVarTypeGuid,
+		EvtVarTypeSysTime, EvtVarTypeSid, EvtVarTypeEvtXml:
+		return true
+	}
+	return false
+}
+
+// SystemValues returns a copy of Values with the variants' pointers moved to
+// the copy, for reading with EvtVariant's methods. Fails if a pointer is
+// outside the buffer.
+func (r *RenderedEvent) SystemValues() (EvtVariant, error) {
+	if uint64(r.PropertyCount)*evtVariantSize > uint64(len(r.Values)) {
+		return nil, fmt.Errorf("Render buffer of %v bytes is too small for %v values", len(r.Values), r.PropertyCount)
+	}
+	values := make([]byte, len(r.Values))
+	copy(values, r.Values)
+	if len(values) == 0 {
+		return NewEvtVariant(values), nil
+	}
+	base := uint64(uintptr(unsafe.Pointer(&values[0])))
+	for i := uint32(0); i < r.PropertyCount; i++ {
+		elem := (*evtVariant)(unsafe.Pointer(&values[evtVariantSize*i]))
+		if !elem.isPointer() || elem.Data == 0 {
+			continue
+		}
+		if elem.Data < r.ValuesAddress || elem.Data >= r.ValuesAddress+uint64(len(values)) {
+			return nil, fmt.Errorf("Value %v points outside the render buffer", i)
+		}
+		elem.Data = elem.Data - r.ValuesAddress + base
+	}
+	return NewEvtVariant(values), nil
+}
+
+// DecodeXML converts the XML to UTF-8, as RenderXML returns it.
+func (r *RenderedEvent) DecodeXML() []byte {
+	wide := make([]uint16, len(r.XML)/2)
+	for i := range wide {
+		wide[i] = uint16(r.XML[2*i]) | uint16(r.XML[2*i+1])<<8
+	}
+	return []byte(UTF16ToString(wide))
+}
@@ -0,0 +1,62 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	. "testing"
+	"unicode/utf16"
+)
+
+// encodeUTF16 encodes a string as null-terminated UTF-16LE.
+func encodeUTF16(s string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, append(utf16.Encode([]rune(s)), 0))
+	return b.Bytes()
+}
+
+// rawValues builds a render buffer, as if rendered at `address`, of a
+// string and an unsigned integer.
+func rawValues(address uint64, s string, n uint64) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, evtVariant{Data: address + 2*evtVariantSize, Type: EvtVarTypeString})
+	binary.Write(&buf, le, evtVariant{Data: n, Type: EvtVarTypeUInt64})
+	buf.Write(encodeUTF16(s))
+	return buf.Bytes()
+}
+
+func TestRenderedEventSystemValues(t *T) {
+	const address = 0x7ff000001000
+	raw := &RenderedEvent{
+		Values:        rawValues(address, "Microsoft-Windows-Security-Auditing", 4624),
+		PropertyCount: 2,
+		ValuesAddress: address,
+		XML:           encodeUTF16("<Event/>"),
+	}
+	values, err := raw.SystemValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := values.String(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(provider, "Microsoft-Windows-Security-Auditing", t)
+	id, _ := values.Uint(1)
+	assertEqual(id, uint64(4624), t)
+	assertEqual(string(raw.DecodeXML()), "<Event/>", t)
+
+	// The archived buffer isn't modified
+	archived := rawValues(address, "Microsoft-Windows-Security-Auditing", 4624)
+	assertEqual(bytes.Equal(raw.Values, archived), true, t)
+
+	// Pointers outside the buffer aren't followed
+	raw.ValuesAddress = address + 0x1000
+	if _, err := raw.SystemValues(); err == nil {
+		t.Fatal("Expected an error for a pointer outside the buffer")
+	}
+	raw.PropertyCount = 100
+	if _, err := raw.SystemValues(); err == nil {
+		t.Fatal("Expected an error for a buffer too small for its values")
+	}
+}
@@ -0,0 +1,70 @@
+package winlog
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// NotifyRecordGap reports records a subscription skipped: RecordIds missing
+// between two consecutive events read from the channel.
+const NotifyRecordGap NotificationKind = "record_gap"
+
+// RecordGap is a range of RecordIds missing between two events read from a
+// channel.
+type RecordGap struct {
+	// The first and last missing RecordIds
+	First uint64
+	Last  uint64
+	// Of those, records the watcher dropped itself, by its RateLimit, before
+	// reading their RecordIds, and records it never received, which had been
+	// overwritten when the log wrapped before the subscription read them
+	Dropped uint64
+	Lost    uint64
+}
+
+// Count returns the number of missing records.
+func (g RecordGap) Count() uint64 {
+	return g.Last - g.First + 1
+}
+
+// recordGap returns the RecordIds missing between `previous` and
+// `recordId`, `skipped` of which the watcher dropped without reading.
+func recordGap(previous, recordId, skipped uint64) (RecordGap, bool) {
+	if previous == 0 || recordId <= previous+1 {
+		return RecordGap{}, false
+	}
+	gap := RecordGap{First: previous + 1, Last: recordId - 1}
+	gap.Dropped = skipped
+	if gap.Dropped > gap.Count() {
+		gap.Dropped = gap.Count()
+	}
+	gap.Lost = gap.Count() - gap.Dropped
+	return gap, true
+}
+
+// checkGap notifies when the RecordIds of a subscription skip from
+// `previous`. Gaps are only looked for if `complete`, when the subscription
+// reads every record of one channel in order, since a query selecting some
+// events skips the others.
+func (self *WinLogWatcher) checkGap(subscribedChannel string, stats *subscriptionStats, ev *WinLogEvent, previous, skipped uint64, complete bool) {
+	if !complete {
+		return
+	}
+	gap, ok := recordGap(previous, ev.RecordId, skipped)
+	if !ok {
+		return
+	}
+	atomic.AddUint64(&stats.lostRecords, gap.Lost)
+	n := Notification{Kind: NotifyRecordGap, Channel: subscribedChannel, Count: gap.Count(),
+		RecordId: ev.RecordId, PreviousRecordId: previous, Gap: &gap}
+	switch {
+	case gap.Lost == 0:
+		n.Message = fmt.Sprintf("Records %v-%v were dropped by the rate limit", gap.First, gap.Last)
+		self.log(LogDebug, "Record gap", "channel", subscribedChannel, "first", gap.First, "last", gap.Last, "dropped", gap.Dropped)
+	default:
+		n.Message = fmt.Sprintf("Records %v-%v were skipped, %v lost to the log wrapping and %v dropped by the rate limit",
+			gap.First, gap.Last, gap.Lost, gap.Dropped)
+		self.log(LogWarn, "Record gap", "channel", subscribedChannel, "first", gap.First, "last", gap.Last, "lost", gap.Lost, "dropped", gap.Dropped)
+	}
+	self.notify(n)
+}
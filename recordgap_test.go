@@ -0,0 +1,82 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestRecordGap(t *T) {
+	_, ok := recordGap(0, 10, 0)
+	assertEqual(ok, false, t)
+	_, ok = recordGap(9, 10, 0)
+	assertEqual(ok, false, t)
+	_, ok = recordGap(10, 3, 0)
+	assertEqual(ok, false, t)
+
+	gap, ok := recordGap(10, 16, 2)
+	assertEqual(ok, true, t)
+	assertEqual(gap.First, uint64(11), t)
+	assertEqual(gap.Last, uint64(15), t)
+	assertEqual(gap.Count(), uint64(5), t)
+	assertEqual(gap.Dropped, uint64(2), t)
+	assertEqual(gap.Lost, uint64(3), t)
+
+	// Drops counted before the last event read don't fall in the gap
+	gap, _ = recordGap(10, 12, 4)
+	assertEqual(gap.Dropped, uint64(1), t)
+	assertEqual(gap.Lost, uint64(0), t)
+}
+
+func TestRecordGapNotification(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(nextNotification(watcher, t).Kind, NotifySubscribed, t)
+	go func() {
+		for _, recordId := range []uint64{1, 2, 5} {
+			api.emit("Application", fakeValues{EvtSystemEventRecordId: recordId})
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		<-watcher.Event()
+	}
+	n := nextNotification(watcher, t)
+	assertEqual(n.Kind, NotifyRecordGap, t)
+	assertEqual(n.Channel, "Application", t)
+	assertEqual(n.Count, uint64(2), t)
+	assertEqual(n.PreviousRecordId, uint64(2), t)
+	assertEqual(n.RecordId, uint64(5), t)
+	assertEqual(*n.Gap, RecordGap{First: 3, Last: 4, Lost: 2}, t)
+	assertEqual(watcher.Stats()["Application"].LostRecords, uint64(2), t)
+}
+
+func TestRecordGapFilteredQuery(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	// A query selecting some events skips the others
+	if err := watcher.SubscribeFromNow("Application", "*[System[EventID=1]]"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(nextNotification(watcher, t).Kind, NotifySubscribed, t)
+	go func() {
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(1)})
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(7)})
+	}()
+	<-watcher.Event()
+	<-watcher.Event()
+	select {
+	case n := <-watcher.Notifications():
+		t.Fatalf("Unexpected notification %v", n)
+	default:
+	}
+	assertEqual(watcher.Stats()["Application"].LostRecords, uint64(0), t)
+}
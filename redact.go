@@ -0,0 +1,181 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const DefaultRedactionMask = "[REDACTED]"
+
+// RawErr of events whose render buffers were removed by a Redactor
+var errRawRedacted = errors.New("Raw render buffers removed by the Redactor")
+
+// Redactor masks or removes sensitive values from an event before it leaves
+// the process. It is applied to the parsed EventData and UserData, the
+// <Data> elements of the event XML, including unnamed ones by the key
+// EventData gives them, the fields of its <UserData>, the formatted message
+// in every render locale and the fields extracted from it, so the redacted
+// values can't leak through any of them.
+//
+// The fields are read the first time the Redactor is used and must not be
+// modified afterwards.
+type Redactor struct {
+	// EventData, UserData and Extracted fields whose values are replaced
+	// with Mask
+	MaskFields []string
+	// EventData, UserData and Extracted fields which are removed entirely
+	DropFields []string
+	// Regular expressions; every match in Msg, LocalizedMsg,
+	// LocalizedLevelText and Extracted is replaced with Mask
+	MsgPatterns []string
+	// Replacement text, DefaultRedactionMask if empty
+	Mask string
+
+	once       sync.Once
+	compileErr error
+	mask       string
+	maskFields map[string]bool
+	dropFields map[string]bool
+	msg        []*regexp.Regexp
+	// The fields of <UserData> which are masked or dropped
+	userFields *regexp.Regexp
+}
+
+// A <Data> element of the event XML, its attributes, and the <UserData>
+// element
+var (
+	dataElement  = regexp.MustCompile(`(?s)<Data(\s[^>]*?)?(?:/>|>.*?</Data>)`)
+	dataNameAttr = regexp.MustCompile(`\sName=['"]([^'"]*)['"]`)
+	userData     = regexp.MustCompile(`(?s)<UserData(?:\s[^>]*)?>.*?</UserData>`)
+)
+
+func (r *Redactor) compile() error {
+	r.once.Do(func() {
+		r.mask = r.Mask
+		if r.mask == "" {
+			r.mask = DefaultRedactionMask
+		}
+		r.maskFields = make(map[string]bool, len(r.MaskFields))
+		for _, name := range r.MaskFields {
+			r.maskFields[name] = true
+		}
+		r.dropFields = make(map[string]bool, len(r.DropFields))
+		for _, name := range r.DropFields {
+			r.dropFields[name] = true
+		}
+		// The fields are elements holding only text, inside the element
+		// named by the provider
+		var names []string
+		for _, name := range append(append([]string(nil), r.MaskFields...), r.DropFields...) {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+		if len(names) > 0 {
+			alternatives := strings.Join(names, "|")
+			r.userFields = regexp.MustCompile(`<(` + alternatives + `)(\s[^>]*?)?(?:/>|>[^<]*</(?:` + alternatives + `)>)`)
+		}
+		for _, pattern := range r.MsgPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				r.compileErr = err
+				return
+			}
+			r.msg = append(r.msg, re)
+		}
+	})
+	return r.compileErr
+}
+
+// redactXml masks and removes the <Data> elements of the event XML, finding
+// the EventData key of each by its position as the watcher does, so values
+// of unnamed elements are redacted too. Dropped unnamed elements are left
+// empty rather than removed, to keep the positions of the others.
+func (r *Redactor) redactXml(eventXml []byte, names []string) []byte {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(r.mask))
+	i := -1
+	return dataElement.ReplaceAllFunc(eventXml, func(element []byte) []byte {
+		i++
+		attrs := dataElement.FindSubmatch(element)[1]
+		var name string
+		if m := dataNameAttr.FindSubmatch(attrs); m != nil {
+			name = string(m[1])
+		}
+		key := dataKey(name, i, names)
+		switch {
+		case r.dropFields[key] && name == "":
+			return []byte("<Data/>")
+		case r.dropFields[key]:
+			return nil
+		case r.maskFields[key]:
+			return []byte("<Data" + string(attrs) + ">" + escaped.String() + "</Data>")
+		}
+		return element
+	})
+}
+
+// redactUserData masks and removes the fields of the <UserData> element of
+// the event XML.
+func (r *Redactor) redactUserData(eventXml []byte) []byte {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(r.mask))
+	return userData.ReplaceAllFunc(eventXml, func(section []byte) []byte {
+		return r.userFields.ReplaceAllFunc(section, func(element []byte) []byte {
+			m := r.userFields.FindSubmatch(element)
+			name := string(m[1])
+			if r.dropFields[name] {
+				return nil
+			}
+			return []byte("<" + name + string(m[2]) + ">" + escaped.String() + "</" + name + ">")
+		})
+	})
+}
+
+// Redact applies the configured masking and removal rules to the event in
+// place. An error is returned if one of the MsgPatterns is invalid, in which
+// case the event is left untouched.
+func (r *Redactor) Redact(ev *WinLogEvent) error {
+	if err := r.compile(); err != nil {
+		return err
+	}
+
+	for _, name := range r.MaskFields {
+		if _, ok := ev.EventData[name]; ok {
+			ev.EventData[name] = r.mask
+		}
+		if _, ok := ev.UserData[name]; ok {
+			ev.UserData[name] = r.mask
+		}
+		if _, ok := ev.Extracted[name]; ok {
+			ev.Extracted[name] = r.mask
+		}
+	}
+	for _, name := range r.DropFields {
+		delete(ev.EventData, name)
+		delete(ev.UserData, name)
+		delete(ev.Extracted, name)
+	}
+
+	// The render buffers hold every value unredacted
+	if ev.Raw != nil {
+		ev.Raw = nil
+		ev.RawErr = errRawRedacted
+	}
+
+	if len(ev.Xml) > 0 && (len(r.maskFields) > 0 || len(r.dropFields) > 0) {
+		ev.Xml = r.redactUserData(r.redactXml(ev.Xml, ev.dataNames))
+	}
+
+	for _, re := range r.msg {
+		ev.Msg = re.ReplaceAllLiteralString(ev.Msg, r.mask)
+		for _, texts := range []map[string]string{ev.LocalizedMsg, ev.LocalizedLevelText, ev.Extracted} {
+			for key, value := range texts {
+				texts[key] = re.ReplaceAllLiteralString(value, r.mask)
+			}
+		}
+	}
+	return nil
+}
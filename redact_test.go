@@ -0,0 +1,151 @@
+package winlog
+
+import (
+	"encoding/json"
+	"strings"
+	. "testing"
+)
+
+const testEventDataXml = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><EventID>4688</EventID></System>` +
+	`<EventData><Data Name='SubjectUserName'>alice</Data><Data Name='CommandLine'>cmd.exe /c secret &amp; more</Data><Data Name='TargetUserName'/></EventData></Event>`
+
+func TestParseEventData(t *T) {
+	data, err := ParseEventData([]byte(testEventDataXml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(data), 3, t)
+	assertEqual(data["SubjectUserName"], "alice", t)
+	assertEqual(data["CommandLine"], "cmd.exe /c secret & more", t)
+
+	data, err = ParseEventData([]byte(`<Event><EventData><Data>a</Data><Data>b</Data></EventData></Event>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(data["Data0"], "a", t)
+	assertEqual(data["Data1"], "b", t)
+}
+
+func TestRedact(t *T) {
+	data, _ := ParseEventData([]byte(testEventDataXml))
+	ev := &WinLogEvent{
+		Xml:       []byte(testEventDataXml),
+		EventData: data,
+		Msg:       "Password=hunter2 was used",
+	}
+	r := &Redactor{
+		MaskFields:  []string{"CommandLine"},
+		DropFields:  []string{"TargetUserName", "SubjectUserName"},
+		MsgPatterns: []string{`hunter\d`},
+	}
+	if err := r.Redact(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.EventData["CommandLine"], DefaultRedactionMask, t)
+	if _, ok := ev.EventData["SubjectUserName"]; ok {
+		t.Fatal("Dropped field still present in EventData")
+	}
+	assertEqual(ev.Msg, "Password="+DefaultRedactionMask+" was used", t)
+	if strings.Contains(string(ev.Xml), "secret") || strings.Contains(string(ev.Xml), "alice") || strings.Contains(string(ev.Xml), "TargetUserName") {
+		t.Fatalf("Sensitive data left in XML: %s", ev.Xml)
+	}
+
+	// The redacted XML should still parse to the same EventData
+	reparsed, err := ParseEventData(ev.Xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(reparsed), 1, t)
+	assertEqual(reparsed["CommandLine"], DefaultRedactionMask, t)
+}
+
+// Classic providers log unnamed <Data> elements, which EventData names by
+// template parameter or position
+func TestRedactUnnamedData(t *T) {
+	const eventXml = `<Event><EventData><Data>svc</Data><Data>hunter2</Data><Data>alice</Data><Data>4</Data></EventData></Event>`
+	parsed, err := parseEventXml([]byte(eventXml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Service", "Password", "User", ""}
+	ev := &WinLogEvent{Xml: []byte(eventXml), EventData: parsed.eventDataNamed(names), dataNames: names}
+	r := &Redactor{MaskFields: []string{"Password"}, DropFields: []string{"User"}}
+	if err := r.Redact(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.EventData["Password"], DefaultRedactionMask, t)
+	if strings.Contains(string(ev.Xml), "hunter2") || strings.Contains(string(ev.Xml), "alice") {
+		t.Fatalf("Sensitive data left in XML: %s", ev.Xml)
+	}
+	// The elements after a dropped one keep their positions
+	reparsed, err := parseEventXml(ev.Xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := reparsed.eventDataNamed(names)
+	assertEqual(data["Service"], "svc", t)
+	assertEqual(data["Password"], DefaultRedactionMask, t)
+	assertEqual(data["User"], "", t)
+	assertEqual(data["Data3"], "4", t)
+
+	// Without templates, they're named by position
+	ev = &WinLogEvent{Xml: []byte(eventXml)}
+	if err := (&Redactor{MaskFields: []string{"Data1"}}).Redact(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(strings.Contains(string(ev.Xml), "<Data>"+DefaultRedactionMask+"</Data>"), true, t)
+	assertEqual(strings.Contains(string(ev.Xml), "hunter2"), false, t)
+}
+
+func TestRedactUserData(t *T) {
+	eventXml := `<Event><System><EventID>104</EventID></System><UserData><LogFileCleared xmlns='http://manifests.microsoft.com/win/2004/08/windows/eventlog'>` +
+		`<SubjectUserName>alice</SubjectUserName><SubjectDomainName>CORP</SubjectDomainName><Channel>Application</Channel></LogFileCleared></UserData></Event>`
+	data, err := ParseUserData([]byte(eventXml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &WinLogEvent{Xml: []byte(eventXml), UserData: data}
+	r := &Redactor{MaskFields: []string{"SubjectUserName"}, DropFields: []string{"SubjectDomainName"}}
+	if err := r.Redact(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.UserData["SubjectUserName"], DefaultRedactionMask, t)
+	if _, ok := ev.UserData["SubjectDomainName"]; ok {
+		t.Fatal("Dropped field still present in UserData")
+	}
+	if strings.Contains(string(ev.Xml), "alice") || strings.Contains(string(ev.Xml), "CORP") {
+		t.Fatalf("Sensitive data left in XML: %s", ev.Xml)
+	}
+	reparsed, err := ParseUserData(ev.Xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(reparsed), 2, t)
+	assertEqual(reparsed["SubjectUserName"], DefaultRedactionMask, t)
+	assertEqual(reparsed["Channel"], "Application", t)
+}
+
+func TestRedactRaw(t *T) {
+	ev := &WinLogEvent{Raw: &RenderedEvent{XML: encodeUTF16(testEventDataXml)}}
+	if err := (&Redactor{MaskFields: []string{"CommandLine"}}).Redact(ev); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(ev.Raw, (*RenderedEvent)(nil), t)
+	assertEqual(ev.RawErr, errRawRedacted, t)
+
+	// Raw is never serialized
+	data, err := json.Marshal(&WinLogEvent{Raw: &RenderedEvent{XML: encodeUTF16("secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(strings.Contains(string(data), `"Raw"`), false, t)
+}
+
+func TestRedactInvalidPattern(t *T) {
+	r := &Redactor{MsgPatterns: []string{"("}}
+	ev := &WinLogEvent{Msg: "unchanged"}
+	if err := r.Redact(ev); err == nil {
+		t.Fatal("No error from invalid pattern")
+	}
+	assertEqual(ev.Msg, "unchanged", t)
+}
@@ -0,0 +1,112 @@
+package winlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// EventFilter decides whether an event is published. Both *Filter and
+// *RegexFilter implement it.
+type EventFilter interface {
+	Match(ev *WinLogEvent) bool
+}
+
+// RegexFilter matches regular expressions against the formatted message and
+// provider name of an event, for quick noise suppression when the data needed
+// isn't available in the System properties. Msg is only populated when the
+// watcher's RenderMessage is enabled.
+//
+// If any Include patterns are set for a field, the field must match at least
+// one of them. An event matching any Exclude pattern is always dropped.
+//
+// In configuration files the patterns are written as strings, and compiled
+// when the filter is unmarshalled:
+//
+//	{"IncludeProvider": ["^Microsoft-Windows-"], "ExcludeMsg": ["(?i)heartbeat"]}
+type RegexFilter struct {
+	IncludeMsg      []*regexp.Regexp
+	ExcludeMsg      []*regexp.Regexp
+	IncludeProvider []*regexp.Regexp
+	ExcludeProvider []*regexp.Regexp
+}
+
+// The patterns of a RegexFilter as they're written in configuration
+type regexFilterPatterns struct {
+	IncludeMsg      []string `json:",omitempty"`
+	ExcludeMsg      []string `json:",omitempty"`
+	IncludeProvider []string `json:",omitempty"`
+	ExcludeProvider []string `json:",omitempty"`
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q: %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func patternStrings(compiled []*regexp.Regexp) []string {
+	var patterns []string
+	for _, re := range compiled {
+		patterns = append(patterns, re.String())
+	}
+	return patterns
+}
+
+func (f *RegexFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(regexFilterPatterns{
+		IncludeMsg:      patternStrings(f.IncludeMsg),
+		ExcludeMsg:      patternStrings(f.ExcludeMsg),
+		IncludeProvider: patternStrings(f.IncludeProvider),
+		ExcludeProvider: patternStrings(f.ExcludeProvider),
+	})
+}
+
+func (f *RegexFilter) UnmarshalJSON(data []byte) error {
+	var patterns regexFilterPatterns
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		return err
+	}
+	var compiled RegexFilter
+	var err error
+	if compiled.IncludeMsg, err = compilePatterns(patterns.IncludeMsg); err != nil {
+		return err
+	}
+	if compiled.ExcludeMsg, err = compilePatterns(patterns.ExcludeMsg); err != nil {
+		return err
+	}
+	if compiled.IncludeProvider, err = compilePatterns(patterns.IncludeProvider); err != nil {
+		return err
+	}
+	if compiled.ExcludeProvider, err = compilePatterns(patterns.ExcludeProvider); err != nil {
+		return err
+	}
+	*f = compiled
+	return nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the event passes the include and exclude patterns.
+func (f *RegexFilter) Match(ev *WinLogEvent) bool {
+	if len(f.IncludeMsg) > 0 && !matchesAny(f.IncludeMsg, ev.Msg) {
+		return false
+	}
+	if len(f.IncludeProvider) > 0 && !matchesAny(f.IncludeProvider, ev.ProviderName) {
+		return false
+	}
+	return !matchesAny(f.ExcludeMsg, ev.Msg) && !matchesAny(f.ExcludeProvider, ev.ProviderName)
+}
@@ -0,0 +1,71 @@
+package winlog
+
+import (
+	"encoding/json"
+	"regexp"
+	. "testing"
+	"time"
+)
+
+func TestRegexFilter(t *T) {
+	f := &RegexFilter{
+		IncludeProvider: []*regexp.Regexp{regexp.MustCompile(`^Microsoft-Windows-`)},
+		ExcludeMsg:      []*regexp.Regexp{regexp.MustCompile(`(?i)heartbeat`)},
+	}
+	assertEqual(f.Match(&WinLogEvent{ProviderName: "Microsoft-Windows-Kernel-General", Msg: "Time changed"}), true, t)
+	assertEqual(f.Match(&WinLogEvent{ProviderName: "Microsoft-Windows-Kernel-General", Msg: "HEARTBEAT ok"}), false, t)
+	assertEqual(f.Match(&WinLogEvent{ProviderName: "VSS", Msg: "Time changed"}), false, t)
+	assertEqual((&RegexFilter{}).Match(&WinLogEvent{}), true, t)
+}
+
+func TestRegexFilterFromConfig(t *T) {
+	var config struct {
+		Filter *RegexFilter
+	}
+	data := `{"Filter": {"IncludeProvider": ["^Microsoft-Windows-"], "ExcludeMsg": ["(?i)heartbeat"]}}`
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(config.Filter.Match(&WinLogEvent{ProviderName: "Microsoft-Windows-Kernel-General", Msg: "Time changed"}), true, t)
+	assertEqual(config.Filter.Match(&WinLogEvent{ProviderName: "Microsoft-Windows-Kernel-General", Msg: "HEARTBEAT ok"}), false, t)
+
+	written, err := json.Marshal(config.Filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(string(written), `{"ExcludeMsg":["(?i)heartbeat"],"IncludeProvider":["^Microsoft-Windows-"]}`, t)
+
+	if err := json.Unmarshal([]byte(`{"Filter": {"ExcludeMsg": ["("]}}`), &config); err == nil {
+		t.Fatal("Expected an error for an invalid pattern")
+	}
+}
+
+func TestSubscriptionFilters(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	watcher.SubscriptionFilters = map[string]EventFilter{
+		"application": &RegexFilter{ExcludeProvider: []*regexp.Regexp{regexp.MustCompile(`^Noisy$`)}},
+	}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first event read is already filtered
+	go func() {
+		api.emit("Application", fakeValues{EvtSystemProviderName: "Noisy", EvtSystemEventRecordId: uint64(1)})
+		api.emit("Application", fakeValues{EvtSystemProviderName: "Quiet", EvtSystemEventRecordId: uint64(2)})
+	}()
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.ProviderName, "Quiet", t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	assertEqual(watcher.Stats()["Application"].Filtered, uint64(1), t)
+}
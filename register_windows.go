@@ -0,0 +1,69 @@
+package winlog
+
+import (
+	"fmt"
+	"os/exec"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Registry key under which classic event sources are registered
+const eventLogKey = `SYSTEM\CurrentControlSet\Services\EventLog`
+
+// RegisterEventSource registers `source` in the classic event log `log`
+// (usually "Application") so Event Viewer can render its messages from
+// `messageFile`. If messageFile is "", DefaultEventMessageFile is used,
+// which renders the first insertion string as the message for event IDs 1
+// to 1000. Registering an existing source updates it. Needs administrator
+// rights.
+func RegisterEventSource(log, source, messageFile string) error {
+	if messageFile == "" {
+		messageFile = DefaultEventMessageFile
+	}
+	key, _, err := registry.CreateKey(registry.LOCAL_MACHINE, eventLogKey+`\`+log+`\`+source, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("Failed to create registry key for event source %q: %v", source, err)
+	}
+	defer key.Close()
+	if err := key.SetExpandStringValue("EventMessageFile", messageFile); err != nil {
+		return err
+	}
+	return key.SetDWordValue("TypesSupported", EventTypeError|EventTypeWarning|EventTypeInformation)
+}
+
+// UnregisterEventSource removes the registration made by
+// RegisterEventSource. It's not an error if the source isn't registered.
+func UnregisterEventSource(log, source string) error {
+	err := registry.DeleteKey(registry.LOCAL_MACHINE, eventLogKey+`\`+log+`\`+source)
+	if err != nil && err != registry.ErrNotExist {
+		return fmt.Errorf("Failed to remove event source %q: %v", source, err)
+	}
+	return nil
+}
+
+// InstallManifest installs a provider's instrumentation manifest with
+// wevtutil, so events written with Provider render in Event Viewer.
+// `binary` is the file containing the compiled message and resource tables,
+// usually the provider's executable; if it's "", the paths in the manifest
+// are used. Needs administrator rights.
+func InstallManifest(manifest, binary string) error {
+	args := []string{"im", manifest}
+	if binary != "" {
+		args = append(args, "/rf:"+binary, "/mf:"+binary)
+	}
+	return wevtutil(args...)
+}
+
+// UninstallManifest removes the providers in the manifest installed by
+// InstallManifest.
+func UninstallManifest(manifest string) error {
+	return wevtutil("um", manifest)
+}
+
+func wevtutil(args ...string) error {
+	output, err := exec.Command("wevtutil.exe", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("wevtutil %v failed: %v: %s", args[0], err, output)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RemoteLogin is the computer whose event log is read, and the credentials
+// to read it with. An empty User logs on with the collector's own
+// credentials.
+type RemoteLogin struct {
+	Server   string
+	User     string
+	Domain   string
+	Password string
+	// How to authenticate: EvtRpcLoginAuthNegotiate, EvtRpcLoginAuthKerberos
+	// (which never falls back to NTLM), EvtRpcLoginAuthNTLM, or
+	// EvtRpcLoginAuthDefault for DefaultRemoteAuth
+	Auth EVT_RPC_LOGIN_FLAGS
+}
+
+// The authentication method of RemoteLogins whose Auth is
+// EvtRpcLoginAuthDefault. EvtRpcLoginAuthDefault lets Windows choose.
+var DefaultRemoteAuth EVT_RPC_LOGIN_FLAGS = EvtRpcLoginAuthDefault
+
+// Refuse to open sessions which would authenticate with anything but
+// Kerberos, for environments where NTLM is disabled or audited. Logins
+// which leave their Auth to the default use Kerberos.
+var RequireKerberos bool
+
+// authFlags returns the authentication method to open the login's session
+// with.
+func (l RemoteLogin) authFlags() (EVT_RPC_LOGIN_FLAGS, error) {
+	auth := l.Auth
+	if auth == EvtRpcLoginAuthDefault {
+		auth = DefaultRemoteAuth
+	}
+	if auth > EvtRpcLoginAuthNTLM {
+		return 0, fmt.Errorf("Invalid authentication method %v for %v", auth, l.Server)
+	}
+	if RequireKerberos {
+		if auth == EvtRpcLoginAuthDefault {
+			auth = EvtRpcLoginAuthKerberos
+		} else if auth != EvtRpcLoginAuthKerberos {
+			return 0, fmt.Errorf("Kerberos is required, but %v would authenticate with %v", l.Server, auth)
+		}
+	}
+	return auth, nil
+}
+
+func (f EVT_RPC_LOGIN_FLAGS) String() string {
+	switch f {
+	case EvtRpcLoginAuthDefault:
+		return "default"
+	case EvtRpcLoginAuthNegotiate:
+		return "negotiate"
+	case EvtRpcLoginAuthKerberos:
+		return "kerberos"
+	case EvtRpcLoginAuthNTLM:
+		return "ntlm"
+	}
+	return fmt.Sprintf("EVT_RPC_LOGIN_FLAGS(%d)", uint32(f))
+}
+
+// ParseRemoteAuth parses an authentication method as returned by String,
+// such as "kerberos", for configuration files and flags.
+func ParseRemoteAuth(s string) (EVT_RPC_LOGIN_FLAGS, error) {
+	for _, auth := range []EVT_RPC_LOGIN_FLAGS{EvtRpcLoginAuthDefault, EvtRpcLoginAuthNegotiate, EvtRpcLoginAuthKerberos, EvtRpcLoginAuthNTLM} {
+		if strings.EqualFold(s, auth.String()) {
+			return auth, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown authentication method %q", s)
+}
+
+// account is the login's account as domain\user.
+func (l RemoteLogin) account() string {
+	if l.User == "" || l.Domain == "" {
+		return l.User
+	}
+	return l.Domain + `\` + l.User
+}
+
+// RemoteSource identifies the session an event was read through, so
+// collectors reading many computers can attribute events, and problems
+// with them, to their source.
+type RemoteSource struct {
+	Server string
+	// The account the session logged on as, as domain\user, or empty for
+	// the collector's own
+	Account string
+	// Identifies the session, which changes each time it's opened
+	SessionId uint64
+	Opened    time.Time
+}
+
+// Sequence of the sessions opened by RemoteAPIs
+var remoteSessionId uint64
+
+// RemoteAPI is an EventLogAPI reading the event log of another computer
+// through a session opened with OpenSession. Subscriptions, queries, channels
+// and provider metadata are those of the remote computer; rendering and
+// bookmarks are handled by the embedded EventLogAPI. Events read by a
+// watcher using it have their Remote field set.
+//
+//	api, err := NewRemoteAPI(RemoteLogin{Server: "dc01.corp.example.com"})
+//	watcher, err := NewWinLogWatcherWithAPI(api)
+type RemoteAPI struct {
+	EventLogAPI
+	Login RemoteLogin
+
+	// Probe the session this often once StartKeepAlive is called, reading
+	// the log information of KeepAliveChannel (DefaultKeepAliveChannel if
+	// empty), and reopen it by the Reconnect policy when a probe fails
+	KeepAliveInterval time.Duration
+	KeepAliveChannel  string
+	Reconnect         ReconnectPolicy
+	// Optionally called when the session changes state. Mustn't block.
+	OnStateChange func(RemoteStateChange)
+
+	mu             sync.Mutex
+	session        SessionHandle
+	source         *RemoteSource
+	stats          RemoteSessionStats
+	stateListeners []func(RemoteStateChange)
+	stopKeepAlive  chan struct{}
+	openSession    func(login RemoteLogin) (SessionHandle, error)
+	probeSession   func(session SessionHandle, channel string) error
+}
+
+// NewRemoteAPI opens a session to the computer, making its other calls
+// through SystemEventLogAPI.
+func NewRemoteAPI(login RemoteLogin) (*RemoteAPI, error) {
+	a := &RemoteAPI{EventLogAPI: SystemEventLogAPI, Login: login}
+	if err := a.Open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Open opens a new session to the computer, replacing the current one.
+// Existing subscriptions keep the session they were made with.
+func (a *RemoteAPI) Open() error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	a.setState(RemoteConnected, false, nil)
+	return nil
+}
+
+func (a *RemoteAPI) open() error {
+	open := a.openSession
+	if open == nil {
+		open = OpenSession
+	}
+	session, err := open(a.Login)
+	if err != nil {
+		return err
+	}
+	source := &RemoteSource{
+		Server:    a.Login.Server,
+		Account:   a.Login.account(),
+		SessionId: atomic.AddUint64(&remoteSessionId, 1),
+		Opened:    time.Now(),
+	}
+	a.mu.Lock()
+	previous := a.session
+	a.session, a.source = session, source
+	a.stats.LastContact = source.Opened
+	a.stats.ConsecutiveFailures = 0
+	a.mu.Unlock()
+	if previous != 0 {
+		a.EventLogAPI.Close(uint64(previous))
+	}
+	return nil
+}
+
+// CloseSession closes the session and stops its keep-alive. Subscriptions
+// should be closed first.
+func (a *RemoteAPI) CloseSession() error {
+	a.mu.Lock()
+	session := a.session
+	a.session, a.source = 0, nil
+	if a.stopKeepAlive != nil {
+		close(a.stopKeepAlive)
+		a.stopKeepAlive = nil
+	}
+	a.mu.Unlock()
+	if session == 0 {
+		return nil
+	}
+	a.setState(RemoteDisconnected, false, nil)
+	return a.EventLogAPI.Close(uint64(session))
+}
+
+// RemoteSource returns the identity of the current session, or nil if it's
+// closed.
+func (a *RemoteAPI) RemoteSource() *RemoteSource {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.source
+}
+
+func (a *RemoteAPI) currentSession() SessionHandle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.session
+}
+
+func (a *RemoteAPI) Subscribe(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle, callback LogEventCallback) (ListenerHandle, error) {
+	wrapper := &LogEventCallbackWrapper{callback: callback, subscribedChannel: channel}
+	return CreateSessionListener(a.currentSession(), channel, query, flags, bookmark, wrapper)
+}
+
+func (a *RemoteAPI) SubscribePull(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle) (ListenerHandle, error) {
+	return createPullListener(a.currentSession(), channel, query, flags, bookmark)
+}
+
+func (a *RemoteAPI) WaitEvents(subscription ListenerHandle, timeout time.Duration) error {
+	return waitPullListener(subscription, timeout)
+}
+
+func (a *RemoteAPI) Query(path, query string, flags uint32) (QueryHandle, error) {
+	return queryEventLog(a.currentSession(), path, query, flags)
+}
+
+func (a *RemoteAPI) OpenPublisherMetadata(providerName string) (PublisherHandle, error) {
+	return OpenSessionPublisherMetadata(a.currentSession(), providerName, "")
+}
+
+func (a *RemoteAPI) OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error) {
+	return OpenSessionPublisherMetadata(a.currentSession(), providerName, locale)
+}
+
+func (a *RemoteAPI) ChannelInfo(channel string) (ChannelInfo, error) {
+	return GetSessionChannelInfo(a.currentSession(), channel)
+}
+
+func (a *RemoteAPI) ChannelConfig(channel string) (ChannelConfig, error) {
+	return GetSessionChannelConfig(a.currentSession(), channel)
+}
+
+func (a *RemoteAPI) EventTemplates(providerName string) (EventTemplates, error) {
+	return GetSessionEventTemplates(a.currentSession(), providerName)
+}
+
+func (a *RemoteAPI) PublisherTables(providerName string) (*PublisherTables, error) {
+	return GetSessionPublisherTables(a.currentSession(), providerName)
+}
+
+// remoteSourcer is implemented by EventLogAPIs reading another computer's
+// event log, such as RemoteAPI.
+type remoteSourcer interface {
+	RemoteSource() *RemoteSource
+}
@@ -0,0 +1,253 @@
+package winlog
+
+import (
+	"errors"
+	"sync"
+	. "testing"
+	"time"
+)
+
+func TestRemoteAPISessions(t *T) {
+	api := newFakeAPI()
+	var logins []RemoteLogin
+	remote := &RemoteAPI{
+		EventLogAPI: api,
+		Login:       RemoteLogin{Server: "dc01", User: "collector", Domain: "CORP", Password: "secret"},
+		openSession: func(login RemoteLogin) (SessionHandle, error) {
+			logins = append(logins, login)
+			return SessionHandle(1000 + len(logins)), nil
+		},
+	}
+	assertEqual(remote.RemoteSource() == nil, true, t)
+	if err := remote.Open(); err != nil {
+		t.Fatal(err)
+	}
+	first := remote.RemoteSource()
+	assertEqual(first.Server, "dc01", t)
+	assertEqual(first.Account, `CORP\collector`, t)
+	assertEqual(remote.currentSession(), SessionHandle(1001), t)
+
+	// Reopening replaces the session and its identity
+	if err := remote.Open(); err != nil {
+		t.Fatal(err)
+	}
+	second := remote.RemoteSource()
+	assertEqual(second.SessionId > first.SessionId, true, t)
+	assertEqual(api.closed[1001], true, t)
+
+	if err := remote.CloseSession(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(api.closed[1002], true, t)
+	assertEqual(remote.RemoteSource() == nil, true, t)
+	assertEqual(len(logins), 2, t)
+}
+
+// fakeRemoteAPI reads the fake event log as though through a remote session
+type fakeRemoteAPI struct {
+	*fakeAPI
+	source *RemoteSource
+}
+
+func (f fakeRemoteAPI) RemoteSource() *RemoteSource {
+	return f.source
+}
+
+func TestWatcherRemoteSource(t *T) {
+	api := fakeRemoteAPI{fakeAPI: newFakeAPI(), source: &RemoteSource{Server: "dc01", SessionId: 7}}
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.Remote, api.source, t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
+
+func TestReconnectBackoff(t *T) {
+	policy := ReconnectPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assertEqual(policy.backoff(1), time.Second, t)
+	assertEqual(policy.backoff(2), 2*time.Second, t)
+	assertEqual(policy.backoff(3), 4*time.Second, t)
+	assertEqual(policy.backoff(4), 5*time.Second, t)
+	assertEqual(ReconnectPolicy{}.backoff(1), DefaultReconnectInitialBackoff, t)
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(2)
+		if delay < time.Second || delay > 3*time.Second {
+			t.Fatalf("Backoff %v outside jitter", delay)
+		}
+	}
+}
+
+func TestRemoteKeepAliveReconnects(t *T) {
+	var mu sync.Mutex
+	failing := false
+	opens := 0
+	changes := make(chan RemoteStateChange, 10)
+	remote := &RemoteAPI{
+		EventLogAPI:       newFakeAPI(),
+		Login:             RemoteLogin{Server: "dc01"},
+		KeepAliveInterval: 10 * time.Millisecond,
+		Reconnect:         ReconnectPolicy{InitialBackoff: time.Millisecond},
+		OnStateChange:     func(change RemoteStateChange) { changes <- change },
+		openSession: func(login RemoteLogin) (SessionHandle, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			opens++
+			if opens == 2 {
+				// The first attempt to reconnect fails
+				return 0, errors.New("The RPC server is unavailable.")
+			}
+			return SessionHandle(1000 + opens), nil
+		},
+		probeSession: func(session SessionHandle, channel string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			assertEqual(channel, DefaultKeepAliveChannel, t)
+			if failing && session == 1001 {
+				return errors.New("The RPC server is unavailable.")
+			}
+			return nil
+		},
+	}
+	if err := remote.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer remote.CloseSession()
+	assertEqual((<-changes).To, RemoteConnected, t)
+	if err := remote.StartKeepAlive(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+
+	degraded := <-changes
+	assertEqual(degraded.From, RemoteConnected, t)
+	assertEqual(degraded.To, RemoteDegraded, t)
+	reconnected := <-changes
+	assertEqual(reconnected.To, RemoteConnected, t)
+	assertEqual(reconnected.Reconnected, true, t)
+	assertEqual(remote.currentSession(), SessionHandle(1003), t)
+
+	stats := remote.SessionStats()
+	assertEqual(stats.State, RemoteConnected, t)
+	assertEqual(stats.Reconnects, uint64(1), t)
+	assertEqual(stats.ReconnectFailures, uint64(1), t)
+	assertEqual(stats.ProbeFailures >= 1, true, t)
+}
+
+func TestRemoteKeepAliveDisconnects(t *T) {
+	changes := make(chan RemoteStateChange, 10)
+	opened := false
+	remote := &RemoteAPI{
+		EventLogAPI:       newFakeAPI(),
+		Login:             RemoteLogin{Server: "dc01"},
+		KeepAliveInterval: 10 * time.Millisecond,
+		Reconnect:         ReconnectPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		OnStateChange:     func(change RemoteStateChange) { changes <- change },
+		openSession: func(login RemoteLogin) (SessionHandle, error) {
+			if opened {
+				return 0, errors.New("Access is denied.")
+			}
+			opened = true
+			return 1, nil
+		},
+		probeSession: func(session SessionHandle, channel string) error {
+			return errors.New("The RPC server is unavailable.")
+		},
+	}
+	if err := remote.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer remote.CloseSession()
+	<-changes
+	if err := remote.StartKeepAlive(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual((<-changes).To, RemoteDegraded, t)
+	disconnected := <-changes
+	assertEqual(disconnected.To, RemoteDisconnected, t)
+	assertEqual(disconnected.Err != nil, true, t)
+	assertEqual(remote.SessionStats().ReconnectFailures, uint64(2), t)
+}
+
+func TestWatcherResubscribesAfterReconnecting(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	old := watcher.watches["Application"].subscriptions[0]
+	watcher.remoteStateChanged(RemoteStateChange{Server: "dc01", From: RemoteDegraded, To: RemoteConnected, Reconnected: true})
+	current := watcher.watches["Application"].subscriptions[0]
+	assertEqual(current != old, true, t)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		api.mutex.Lock()
+		closed := api.closed[uint64(old)]
+		api.mutex.Unlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Old subscription wasn't closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRemoteLoginAuth(t *T) {
+	defer func() { DefaultRemoteAuth, RequireKerberos = EvtRpcLoginAuthDefault, false }()
+
+	auth, err := RemoteLogin{Server: "dc01"}.authFlags()
+	assertEqual(err, nil, t)
+	assertEqual(auth, EVT_RPC_LOGIN_FLAGS(EvtRpcLoginAuthDefault), t)
+
+	DefaultRemoteAuth = EvtRpcLoginAuthNegotiate
+	auth, _ = RemoteLogin{Server: "dc01"}.authFlags()
+	assertEqual(auth, EVT_RPC_LOGIN_FLAGS(EvtRpcLoginAuthNegotiate), t)
+	auth, _ = RemoteLogin{Server: "dc01", Auth: EvtRpcLoginAuthNTLM}.authFlags()
+	assertEqual(auth, EVT_RPC_LOGIN_FLAGS(EvtRpcLoginAuthNTLM), t)
+
+	DefaultRemoteAuth, RequireKerberos = EvtRpcLoginAuthDefault, true
+	auth, _ = RemoteLogin{Server: "dc01"}.authFlags()
+	assertEqual(auth, EVT_RPC_LOGIN_FLAGS(EvtRpcLoginAuthKerberos), t)
+	if _, err := (RemoteLogin{Server: "dc01", Auth: EvtRpcLoginAuthNTLM}).authFlags(); err == nil {
+		t.Fatal("Expected NTLM to be refused")
+	}
+	if _, err := (RemoteLogin{Server: "dc01", Auth: 7}).authFlags(); err == nil {
+		t.Fatal("Expected an invalid method to be refused")
+	}
+}
+
+func TestParseRemoteAuth(t *T) {
+	auth, err := ParseRemoteAuth("Kerberos")
+	assertEqual(err, nil, t)
+	assertEqual(auth, EVT_RPC_LOGIN_FLAGS(EvtRpcLoginAuthKerberos), t)
+	assertEqual(auth.String(), "kerberos", t)
+	if _, err := ParseRemoteAuth("basic"); err == nil {
+		t.Fatal("Expected an error for an unknown method")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// EVT_RPC_LOGIN
+type evtRpcLogin struct {
+	Server   *uint16
+	User     *uint16
+	Domain   *uint16
+	Password *uint16
+	Flags    uint32
+}
+
+// OpenSession opens a session to the event log of another computer. The
+// session is passed to the Session functions, such as
+// CreateSessionListener, and must be closed with CloseEventHandle. Wraps
+// EvtOpenSession, authenticating by the login's Auth.
+func OpenSession(login RemoteLogin) (SessionHandle, error) {
+	auth, err := login.authFlags()
+	if err != nil {
+		return 0, err
+	}
+	rpcLogin := evtRpcLogin{Flags: uint32(auth)}
+	for _, field := range []struct {
+		value string
+		wide  **uint16
+	}{{login.Server, &rpcLogin.Server}, {login.User, &rpcLogin.User}, {login.Domain, &rpcLogin.Domain}, {login.Password, &rpcLogin.Password}} {
+		if field.value == "" {
+			continue
+		}
+		wide, err := syscall.UTF16PtrFromString(field.value)
+		if err != nil {
+			return 0, err
+		}
+		*field.wide = wide
+	}
+	session, err := EvtOpenSession(EvtRpcLogin, (*byte)(unsafe.Pointer(&rpcLogin)), 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to open session to %v: %v", login.Server, err)
+	}
+	return SessionHandle(session), nil
+}
@@ -0,0 +1,115 @@
+package winlog
+
+import (
+	"time"
+)
+
+// Seconds over which EventsPerSecond is averaged
+const rateWindow = 60
+
+// eventRate counts events in one-second slots over the last rateWindow
+// seconds. Not safe for concurrent use.
+type eventRate struct {
+	counts  [rateWindow]uint64
+	seconds [rateWindow]int64
+}
+
+func (r *eventRate) add(now time.Time) {
+	second := now.Unix()
+	i := second % rateWindow
+	if r.seconds[i] != second {
+		r.seconds[i], r.counts[i] = second, 0
+	}
+	r.counts[i]++
+}
+
+func (r *eventRate) perSecond(now time.Time) float64 {
+	second := now.Unix()
+	var total uint64
+	for i, slot := range r.seconds {
+		if slot <= second && second-slot < rateWindow {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// RemoteHostStats summarises collection from the computer a watcher reads
+// through a RemoteAPI, for fleet dashboards to find the hosts which aren't
+// being collected from.
+type RemoteHostStats struct {
+	Server string
+	// The session's state: RemoteConnected, RemoteDegraded or
+	// RemoteDisconnected
+	State string
+	// Events delivered per second over the last minute, from all
+	// subscriptions
+	EventsPerSecond float64
+	// The largest BookmarkLag of the subscriptions, if the watcher's
+	// BookmarkLagInterval is set, and the time from the creation of the
+	// newest event delivered until now
+	BookmarkLag uint64
+	EventLag    time.Duration
+	// When the host was last reached, by opening or probing the session or
+	// delivering an event from it, and the probes and attempts to reopen
+	// the session which have failed since
+	LastContact         time.Time
+	ConsecutiveFailures int
+	LastError           string `json:",omitempty"`
+	// The bookmark each subscription last published an event with, keyed
+	// by channel
+	Bookmarks map[string]string
+}
+
+// RemoteHostStats returns the collection metrics of the computer the
+// watcher reads, or nil if it doesn't use a RemoteAPI.
+func (self *WinLogWatcher) RemoteHostStats() *RemoteHostStats {
+	remote, ok := self.api.(remoteStateNotifier)
+	if !ok {
+		return nil
+	}
+	session := remote.SessionStats()
+	host := &RemoteHostStats{
+		Server:              session.Server,
+		State:               session.State,
+		LastContact:         session.LastContact,
+		ConsecutiveFailures: session.ConsecutiveFailures,
+		LastError:           session.LastError,
+	}
+	var newest time.Time
+	for _, stats := range self.Stats() {
+		host.EventsPerSecond += stats.EventsPerSecond
+		if stats.BookmarkLag > host.BookmarkLag {
+			host.BookmarkLag = stats.BookmarkLag
+		}
+		if stats.LastEventCreated.After(newest) {
+			newest = stats.LastEventCreated
+		}
+		if stats.LastEventDelivered.After(host.LastContact) {
+			host.LastContact = stats.LastEventDelivered
+		}
+	}
+	if !newest.IsZero() {
+		host.EventLag = time.Since(newest)
+	}
+	host.Bookmarks = self.lastBookmarks()
+	return host
+}
+
+// lastBookmarks returns the bookmark XML each subscription last published
+// an event with, keyed by channel. Unlike BookmarkSnapshot it renders
+// nothing, so it's cheap enough to call on every metrics scrape, but
+// doesn't include events skipped since.
+func (self *WinLogWatcher) lastBookmarks() map[string]string {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	bookmarks := make(map[string]string, len(self.watches))
+	for channel, watch := range self.watches {
+		watch.bookmarkMutex.Lock()
+		if watch.lastBookmark != "" {
+			bookmarks[channel] = watch.lastBookmark
+		}
+		watch.bookmarkMutex.Unlock()
+	}
+	return bookmarks
+}
@@ -0,0 +1,92 @@
+package winlog
+
+import (
+	"sync/atomic"
+	. "testing"
+	"time"
+)
+
+func TestEventRate(t *T) {
+	var rate eventRate
+	start := time.Unix(1000, 0)
+	for i := 0; i < 120; i++ {
+		rate.add(start.Add(time.Duration(i) * time.Second / 2))
+	}
+	// 120 events over the last minute
+	assertEqual(rate.perSecond(start.Add(59*time.Second)), 2.0, t)
+	// Half of them are older than a minute
+	assertEqual(rate.perSecond(start.Add(89*time.Second)), 1.0, t)
+	assertEqual(rate.perSecond(start.Add(time.Hour)), 0.0, t)
+}
+
+// fakeSessionAPI reads the fake event log as though through a remote
+// session in a fixed state
+type fakeSessionAPI struct {
+	*fakeAPI
+	stats RemoteSessionStats
+	// Number of bookmarks rendered
+	renders *int64
+}
+
+func (f fakeSessionAPI) RenderBookmark(bookmark BookmarkHandle) (string, error) {
+	atomic.AddInt64(f.renders, 1)
+	return f.fakeAPI.RenderBookmark(bookmark)
+}
+
+func (f fakeSessionAPI) SessionStats() RemoteSessionStats {
+	return f.stats
+}
+
+func (f fakeSessionAPI) addStateListener(func(RemoteStateChange)) {}
+
+func TestRemoteHostStats(t *T) {
+	contact := time.Now().Add(-time.Hour)
+	api := fakeSessionAPI{fakeAPI: newFakeAPI(), stats: RemoteSessionStats{
+		Server:              "dc01",
+		State:               RemoteDegraded,
+		LastContact:         contact,
+		ConsecutiveFailures: 3,
+		LastError:           "The RPC server is unavailable.",
+	}, renders: new(int64)}
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	assertEqual((&WinLogWatcher{api: newFakeAPI()}).RemoteHostStats() == nil, true, t)
+
+	for _, channel := range []string{"Application", "System"} {
+		if err := watcher.SubscribeFromNow(channel, "*"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	host := watcher.RemoteHostStats()
+	assertEqual(host.Server, "dc01", t)
+	assertEqual(host.State, RemoteDegraded, t)
+	assertEqual(host.LastContact, contact, t)
+	assertEqual(host.ConsecutiveFailures, 3, t)
+	// No events have been read to bookmark yet
+	assertEqual(len(host.Bookmarks), 0, t)
+
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(7),
+		EvtSystemTimeCreated:   time.Now(),
+	})
+	select {
+	case <-watcher.Event():
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	// Scrapes read the bookmark the event was published with rather than
+	// rendering it again
+	renders := atomic.LoadInt64(api.renders)
+	host = watcher.RemoteHostStats()
+	assertEqual(atomic.LoadInt64(api.renders), renders, t)
+	assertEqual(host.EventsPerSecond > 0, true, t)
+	assertEqual(host.LastContact.After(contact), true, t)
+	assertEqual(host.Bookmarks["Application"], "7", t)
+}
@@ -0,0 +1,324 @@
+package winlog
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// States of a remote session
+const (
+	// The session's last probe succeeded
+	RemoteConnected = "connected"
+	// A probe failed and the session is being reopened
+	RemoteDegraded = "degraded"
+	// The session couldn't be reopened within the ReconnectPolicy's
+	// MaxAttempts, or was closed
+	RemoteDisconnected = "disconnected"
+)
+
+// Defaults for the ReconnectPolicy and keep-alive of a RemoteAPI
+const (
+	DefaultReconnectInitialBackoff = time.Second
+	DefaultReconnectMaxBackoff     = time.Minute
+	DefaultKeepAliveChannel        = "System"
+)
+
+// ReconnectPolicy is how a RemoteAPI reopens its session when a keep-alive
+// probe fails. The wait before each attempt starts at InitialBackoff and
+// doubles up to MaxBackoff, varied by up to Jitter (a fraction, such as
+// 0.2) either way so collectors don't all reconnect at once.
+type ReconnectPolicy struct {
+	// Attempts before giving up and disconnecting. Unlimited if zero.
+	MaxAttempts int
+	// DefaultReconnectInitialBackoff and DefaultReconnectMaxBackoff if zero
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+}
+
+// backoff returns how long to wait before the `attempt`th attempt, from 1.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	if delay <= 0 {
+		delay = DefaultReconnectInitialBackoff
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = DefaultReconnectMaxBackoff
+	}
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// RemoteStateChange is passed to a RemoteAPI's OnStateChange when its
+// session changes state.
+type RemoteStateChange struct {
+	Server string
+	From   string
+	To     string
+	// Whether a new session was opened, so subscriptions made with the old
+	// one must be made again
+	Reconnected bool
+	// The error which caused the change, if any
+	Err  error
+	Time time.Time
+}
+
+// RemoteSessionStats describes the health of a RemoteAPI's session.
+type RemoteSessionStats struct {
+	Server string
+	State  string
+	// When the session entered its State
+	Since time.Time
+	// Keep-alive probes made and failed, and when the last was made
+	Probes        uint64
+	ProbeFailures uint64
+	LastProbe     time.Time
+	// Sessions reopened after a failed probe, and failed attempts to reopen
+	// them
+	Reconnects        uint64
+	ReconnectFailures uint64
+	// When the session was last opened or probed successfully, and the
+	// probes and attempts to reopen it which have failed since
+	LastContact         time.Time
+	ConsecutiveFailures int
+	LastError           string `json:",omitempty"`
+}
+
+// remoteStateNotifier is implemented by EventLogAPIs whose session can be
+// reopened, such as RemoteAPI, so the watcher can subscribe again.
+type remoteStateNotifier interface {
+	SessionStats() RemoteSessionStats
+	addStateListener(func(RemoteStateChange))
+}
+
+// StartKeepAlive probes the session every KeepAliveInterval by reading the
+// log information of KeepAliveChannel, a cheap call, and reopens it by the
+// Reconnect policy when a probe fails. A watcher using the API subscribes
+// again from its bookmarks once reconnected. Probing stops when the session
+// is closed, or when it's disconnected after the policy's MaxAttempts; call
+// Open and StartKeepAlive again to resume.
+func (a *RemoteAPI) StartKeepAlive() error {
+	if a.KeepAliveInterval <= 0 {
+		return fmt.Errorf("KeepAliveInterval must be set")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.session == 0 {
+		return fmt.Errorf("The session to %v isn't open", a.Login.Server)
+	}
+	if a.stopKeepAlive != nil {
+		return nil
+	}
+	stop := make(chan struct{})
+	a.stopKeepAlive = stop
+	go a.keepAlive(stop)
+	return nil
+}
+
+func (a *RemoteAPI) keepAlive(stop chan struct{}) {
+	ticker := time.NewTicker(a.KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		err := a.probe()
+		if err == nil {
+			a.setState(RemoteConnected, false, nil)
+			continue
+		}
+		a.setState(RemoteDegraded, false, err)
+		if !a.reconnect(stop) {
+			a.mu.Lock()
+			if a.stopKeepAlive == stop {
+				a.stopKeepAlive = nil
+			}
+			a.mu.Unlock()
+			return
+		}
+	}
+}
+
+// probe reads the log information of the keep-alive channel through the
+// session.
+func (a *RemoteAPI) probe() error {
+	channel := a.KeepAliveChannel
+	if channel == "" {
+		channel = DefaultKeepAliveChannel
+	}
+	probe := a.probeSession
+	if probe == nil {
+		probe = func(session SessionHandle, channel string) error {
+			_, err := GetSessionChannelInfo(session, channel)
+			return err
+		}
+	}
+	err := probe(a.currentSession(), channel)
+	a.mu.Lock()
+	a.stats.Probes++
+	a.stats.LastProbe = time.Now()
+	if err != nil {
+		a.stats.ProbeFailures++
+		a.stats.ConsecutiveFailures++
+	} else {
+		a.stats.LastContact = a.stats.LastProbe
+		a.stats.ConsecutiveFailures = 0
+	}
+	a.mu.Unlock()
+	return err
+}
+
+// reconnect reopens the session, returning false if every attempt failed or
+// keep-alive was stopped.
+func (a *RemoteAPI) reconnect(stop chan struct{}) bool {
+	var err error
+	for attempt := 1; a.Reconnect.MaxAttempts <= 0 || attempt <= a.Reconnect.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(a.Reconnect.backoff(attempt)):
+		case <-stop:
+			return false
+		}
+		opened := false
+		if err = a.open(); err == nil {
+			opened = true
+			err = a.probe()
+		}
+		if err == nil {
+			a.mu.Lock()
+			a.stats.Reconnects++
+			a.mu.Unlock()
+			a.setState(RemoteConnected, true, nil)
+			return true
+		}
+		a.mu.Lock()
+		a.stats.ReconnectFailures++
+		if !opened {
+			// Failed probes are counted by probe
+			a.stats.ConsecutiveFailures++
+		}
+		a.stats.LastError = err.Error()
+		a.mu.Unlock()
+	}
+	a.setState(RemoteDisconnected, false, fmt.Errorf("Failed to reconnect to %v after %v attempts: %v", a.Login.Server, a.Reconnect.MaxAttempts, err))
+	return false
+}
+
+// setState records the session's state, notifying OnStateChange and the
+// watchers using the API if it changed.
+func (a *RemoteAPI) setState(state string, reconnected bool, err error) {
+	now := time.Now()
+	a.mu.Lock()
+	from := a.stats.State
+	if err != nil {
+		a.stats.LastError = err.Error()
+	}
+	if from == state && !reconnected {
+		a.mu.Unlock()
+		return
+	}
+	a.stats.State, a.stats.Since = state, now
+	listeners := append([]func(RemoteStateChange){a.OnStateChange}, a.stateListeners...)
+	a.mu.Unlock()
+	change := RemoteStateChange{Server: a.Login.Server, From: from, To: state, Reconnected: reconnected, Err: err, Time: now}
+	for _, listener := range listeners {
+		if listener != nil {
+			listener(change)
+		}
+	}
+}
+
+// SessionStats returns the state of the session and its keep-alive counters.
+func (a *RemoteAPI) SessionStats() RemoteSessionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.stats
+	stats.Server = a.Login.Server
+	if stats.State == "" {
+		stats.State = RemoteDisconnected
+	}
+	return stats
+}
+
+func (a *RemoteAPI) addStateListener(listener func(RemoteStateChange)) {
+	a.mu.Lock()
+	a.stateListeners = append(a.stateListeners, listener)
+	a.mu.Unlock()
+}
+
+// remoteStateChanged logs changes to the state of the watcher's remote
+// session, publishing disconnections as errors, and subscribes again from
+// the bookmarks when a new session is opened.
+func (self *WinLogWatcher) remoteStateChanged(change RemoteStateChange) {
+	select {
+	case <-self.shutdown:
+		return
+	default:
+	}
+	level := LogInfo
+	if change.To != RemoteConnected {
+		level = LogWarn
+	}
+	self.log(level, "Remote session state changed", "server", change.Server, "from", change.From, "to", change.To, "error", change.Err)
+	if change.To == RemoteDisconnected && change.Err != nil {
+		go self.PublishError(change.Err)
+	}
+	if change.Reconnected {
+		self.resubscribeAll()
+	}
+}
+
+// resubscribeAll replaces every subscription with a new one starting after
+// its bookmark, or where it started if no event has been read yet.
+func (self *WinLogWatcher) resubscribeAll() {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	for channel, watch := range self.watches {
+		if watch.paused {
+			// Resumed with the new session when the pause ends
+			continue
+		}
+		old := watch.subscriptions
+		watch.bookmarkMutex.Lock()
+		bookmarked, err := self.resumeBookmark(watch)
+		splits := append([]splitBookmark(nil), watch.splits...)
+		watch.bookmarkMutex.Unlock()
+		if err != nil {
+			go self.PublishError(fmt.Errorf("Failed to resubscribe to %q after reconnecting: %v", channel, err))
+			continue
+		}
+		flags := watch.flags
+		if bookmarked {
+			flags = EvtSubscribeStartAfterBookmark
+		}
+		subscriptions, err := self.createListeners(channel, watch.query, flags, watch.bookmark, watch.since, splits, watch.pulls)
+		if err != nil {
+			go self.PublishError(fmt.Errorf("Failed to resubscribe to %q after reconnecting: %v", channel, err))
+			continue
+		}
+		watch.subscriptions = subscriptions
+		self.log(LogInfo, "Resubscribed after reconnecting", "channel", channel, "fromBookmark", bookmarked)
+		self.notify(Notification{Kind: NotifyResubscribed, Channel: channel, Message: "Resubscribed after reconnecting"})
+		// Closing a subscription waits for its callbacks to return, so this
+		// can't happen under watchMutex. It frees the subscription's entry
+		// in the callback table shared by all subscriptions, even if the
+		// lost session's handles fail to close.
+		go func() {
+			for _, subscription := range old {
+				self.api.Cancel(uint64(subscription))
+				self.api.Close(uint64(subscription))
+			}
+		}()
+	}
+}
@@ -0,0 +1,54 @@
+package winlog
+
+import (
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+/* Helpers for rendering UTF-16 text into a caller's buffer and converting
+   it to UTF-8 in place */
+
+// utf16Tail returns the part at the end of buf which UTF-16 text is
+// rendered into, and its offset, before utf16ToUTF8 converts it to the
+// start of buf. UTF-8 takes at most 3 bytes for each 2 byte code unit, so
+// writing it never overtakes the UTF-16 still to be read if that's at most
+// the last 2/3 of buf. The offset is even to align the UTF-16.
+func utf16Tail(buf []byte) (int, []byte) {
+	start := ((len(buf)+2)/3 + 1) &^ 1
+	if start > len(buf) {
+		return 0, nil
+	}
+	return start, buf[start : start+(len(buf)-start)&^1]
+}
+
+// utf16TailSize returns the size of a buffer whose utf16Tail holds `n`
+// bytes of UTF-16.
+func utf16TailSize(n int) int {
+	return n*3/2 + 6
+}
+
+// utf16ToUTF8 converts the UTF-16LE text in buf[start:end], up to any null,
+// to UTF-8 at the start of buf, and returns the UTF-8. `start` must be at
+// least half of end-start. Unpaired surrogates become U+FFFD.
+func utf16ToUTF8(buf []byte, start, end int) []byte {
+	w := 0
+	for r := start; r+1 < end; r += 2 {
+		c := rune(uint16(buf[r]) | uint16(buf[r+1])<<8)
+		if c == 0 {
+			break
+		}
+		if utf16.IsSurrogate(c) {
+			decoded := unicode.ReplacementChar
+			if r+3 < end {
+				decoded = utf16.DecodeRune(c, rune(uint16(buf[r+2])|uint16(buf[r+3])<<8))
+			}
+			if decoded != unicode.ReplacementChar {
+				r += 2
+			}
+			c = decoded
+		}
+		w += utf8.EncodeRune(buf[w:], c)
+	}
+	return buf[:w]
+}
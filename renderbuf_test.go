@@ -0,0 +1,51 @@
+package winlog
+
+import (
+	"strings"
+	. "testing"
+	"unicode/utf16"
+)
+
+// renderUTF16 places the UTF-16 text in the tail of a buffer just large
+// enough for it, as EvtRender would, and converts it in place.
+func renderUTF16(text []uint16, t *T) string {
+	utf16Bytes := 2 * (len(text) + 1)
+	buf := make([]byte, utf16TailSize(utf16Bytes))
+	start, tail := utf16Tail(buf)
+	if len(tail) < utf16Bytes {
+		t.Fatalf("Tail of %v bytes can't hold %v bytes", len(tail), utf16Bytes)
+	}
+	for i, c := range text {
+		tail[2*i] = byte(c)
+		tail[2*i+1] = byte(c >> 8)
+	}
+	return string(utf16ToUTF8(buf, start, start+utf16Bytes))
+}
+
+func TestUTF16ToUTF8InPlace(t *T) {
+	for _, s := range []string{
+		"",
+		"<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'/>",
+		strings.Repeat("日本", 500),
+		strings.Repeat("\U0001F600a", 300),
+		"mixed é 中 \U0001F512 text",
+	} {
+		assertEqual(renderUTF16(utf16.Encode([]rune(s)), t), s, t)
+	}
+	// Unpaired surrogates are replaced
+	assertEqual(renderUTF16([]uint16{'a', 0xd800, 'b', 0xdc00}, t), "a�b�", t)
+	// Text stops at a null
+	assertEqual(renderUTF16([]uint16{'a', 0, 'b'}, t), "a", t)
+}
+
+func TestUTF16Tail(t *T) {
+	for size := 0; size < 64; size++ {
+		buf := make([]byte, size)
+		start, tail := utf16Tail(buf)
+		assertEqual(start%2, 0, t)
+		assertEqual(len(tail)%2, 0, t)
+		if len(tail) > 0 && start < len(tail)/2 {
+			t.Fatalf("Tail of %v bytes at %v can be overtaken", len(tail), start)
+		}
+	}
+}
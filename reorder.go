@@ -0,0 +1,110 @@
+package winlog
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Reorderer holds events for up to Window before delivering them in order of
+// creation time, so events from several subscriptions aren't interleaved out
+// of order. Events are ordered by Created, then SubscribedChannel, then
+// RecordId. An event arriving more than Window later than an event created
+// after it is still delivered out of order.
+//
+// Subscription callbacks return without waiting for the consumer while events
+// are held, so at most MaxPending events are held at once. Beyond that, the
+// earliest event is delivered immediately.
+type Reorderer struct {
+	Window time.Duration
+	// Defaults to 1000
+	MaxPending int
+
+	mutex   sync.Mutex
+	pending pendingEvents
+}
+
+type pendingEvent struct {
+	event   *WinLogEvent
+	stats   *subscriptionStats
+	arrived time.Time
+}
+
+// pendingEvents is a heap of events in delivery order
+type pendingEvents []*pendingEvent
+
+func (p pendingEvents) Len() int      { return len(p) }
+func (p pendingEvents) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p pendingEvents) Less(i, j int) bool {
+	a, b := p[i].event, p[j].event
+	if !a.Created.Equal(b.Created) {
+		return a.Created.Before(b.Created)
+	}
+	if a.SubscribedChannel != b.SubscribedChannel {
+		return a.SubscribedChannel < b.SubscribedChannel
+	}
+	return a.RecordId < b.RecordId
+}
+func (p *pendingEvents) Push(x interface{}) { *p = append(*p, x.(*pendingEvent)) }
+func (p *pendingEvents) Pop() interface{} {
+	old := *p
+	last := old[len(old)-1]
+	old[len(old)-1] = nil
+	*p = old[:len(old)-1]
+	return last
+}
+
+// add holds an event, returning any events which must be delivered now
+// because too many are held.
+func (r *Reorderer) add(ev *WinLogEvent, stats *subscriptionStats, now time.Time) []*pendingEvent {
+	maxPending := r.MaxPending
+	if maxPending <= 0 {
+		maxPending = 1000
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	heap.Push(&r.pending, &pendingEvent{event: ev, stats: stats, arrived: now})
+	var ready []*pendingEvent
+	for r.pending.Len() > maxPending {
+		ready = append(ready, heap.Pop(&r.pending).(*pendingEvent))
+	}
+	return ready
+}
+
+// flush returns the events which have been held for Window, in order.
+func (r *Reorderer) flush(now time.Time) []*pendingEvent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var ready []*pendingEvent
+	for r.pending.Len() > 0 && now.Sub(r.pending[0].arrived) >= r.Window {
+		ready = append(ready, heap.Pop(&r.pending).(*pendingEvent))
+	}
+	return ready
+}
+
+func (self *WinLogWatcher) deliverPending(events []*pendingEvent) {
+	for _, p := range events {
+		if self.deliver(p.event, p.stats) {
+			p.stats.recordDelivered(p.event, time.Now())
+		}
+	}
+}
+
+// Periodically deliver events which have been held for the Reorderer's
+// window until shutdown
+func (self *WinLogWatcher) flushReordered() {
+	interval := self.Reorderer.Window / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			self.deliverPending(self.Reorderer.flush(now))
+		case <-self.shutdown:
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestReorderer(t *T) {
+	r := &Reorderer{Window: time.Second, MaxPending: 3}
+	now := time.Unix(1600000000, 0)
+	created := func(secs int, channel string, recordId uint64) *WinLogEvent {
+		return &WinLogEvent{Created: now.Add(time.Duration(secs) * time.Second), SubscribedChannel: channel, RecordId: recordId}
+	}
+	order := func(events []*pendingEvent) []uint64 {
+		var ids []uint64
+		for _, p := range events {
+			ids = append(ids, p.event.RecordId)
+		}
+		return ids
+	}
+
+	assertEqual(len(r.add(created(2, "System", 1), nil, now)), 0, t)
+	assertEqual(len(r.add(created(1, "Security", 2), nil, now.Add(100*time.Millisecond))), 0, t)
+	assertEqual(len(r.add(created(1, "Application", 3), nil, now.Add(200*time.Millisecond))), 0, t)
+	assertEqual(len(r.flush(now.Add(500*time.Millisecond))), 0, t)
+
+	// Held events come out in creation order, ties broken by channel, once
+	// the earliest has been held for the window
+	ready := order(r.flush(now.Add(1200 * time.Millisecond)))
+	assertEqual(len(ready), 3, t)
+	assertEqual(ready[0], uint64(3), t)
+	assertEqual(ready[1], uint64(2), t)
+	assertEqual(ready[2], uint64(1), t)
+
+	// Overflow releases the earliest event immediately
+	for i := uint64(10); i < 13; i++ {
+		r.add(created(int(i), "System", i), nil, now)
+	}
+	overflow := order(r.add(created(0, "System", 9), nil, now))
+	assertEqual(len(overflow), 1, t)
+	assertEqual(overflow[0], uint64(9), t)
+}
@@ -0,0 +1,57 @@
+package winlog
+
+import (
+	"sync"
+)
+
+// Sampler keeps one in every N events of each provider and event ID, for
+// metrics-style uses where volume matters more than completeness. Sampling is
+// deterministic: the first event of each provider and ID is kept, then every
+// Nth after it.
+type Sampler struct {
+	// Default N for all events. 0 or 1 keeps every event.
+	Rate uint64
+	// Per event ID overrides of Rate
+	EventIdRates map[uint64]uint64
+
+	mutex  sync.Mutex
+	counts map[samplerKey]uint64
+}
+
+type samplerKey struct {
+	provider string
+	eventId  uint64
+}
+
+func (s *Sampler) rateFor(eventId uint64) uint64 {
+	if rate, ok := s.EventIdRates[eventId]; ok {
+		return rate
+	}
+	return s.Rate
+}
+
+// Sample reports whether the event should be kept. Kept events have their
+// SampleRate set, so consumers can scale counts back up. Events already
+// sampled, as by QuietHours, have their SampleRate multiplied.
+func (s *Sampler) Sample(ev *WinLogEvent) bool {
+	rate := s.rateFor(ev.EventId)
+	if ev.SampleRate == 0 {
+		ev.SampleRate = 1
+	}
+	if rate <= 1 {
+		return true
+	}
+	key := samplerKey{ev.ProviderName, ev.EventId}
+	s.mutex.Lock()
+	if s.counts == nil {
+		s.counts = make(map[samplerKey]uint64)
+	}
+	n := s.counts[key]
+	s.counts[key] = n + 1
+	s.mutex.Unlock()
+	if n%rate != 0 {
+		return false
+	}
+	ev.SampleRate *= rate
+	return true
+}
@@ -0,0 +1,29 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestSampler(t *T) {
+	s := &Sampler{Rate: 3, EventIdRates: map[uint64]uint64{1: 1}}
+	kept := 0
+	for i := 0; i < 9; i++ {
+		ev := &WinLogEvent{ProviderName: "A", EventId: 2}
+		if s.Sample(ev) {
+			kept++
+			assertEqual(ev.SampleRate, uint64(3), t)
+		}
+	}
+	assertEqual(kept, 3, t)
+
+	// Counted separately per provider and ID
+	assertEqual(s.Sample(&WinLogEvent{ProviderName: "B", EventId: 2}), true, t)
+	assertEqual(s.Sample(&WinLogEvent{ProviderName: "A", EventId: 3}), true, t)
+
+	// Overridden rate keeps everything
+	for i := 0; i < 3; i++ {
+		ev := &WinLogEvent{ProviderName: "A", EventId: 1}
+		assertEqual(s.Sample(ev), true, t)
+		assertEqual(ev.SampleRate, uint64(1), t)
+	}
+}
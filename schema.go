@@ -0,0 +1,115 @@
+package winlog
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of the event maps CreateMap returns, stamped
+// in each under SchemaVersionKey, so data stored from gowinlog output can
+// be told apart and converted with ConvertMap as fields are added. Maps
+// written before versions were stamped are version 0.
+//
+// Version 1 adds Severity, and SchemaVersion itself. Version 2 adds
+// ReceivedAt.
+const SchemaVersion = 2
+
+// Key of the schema version in the maps of CreateMap
+const SchemaVersionKey = "SchemaVersion"
+
+// schemaMigration converts a map from one schema version to the next, and
+// back.
+type schemaMigration struct {
+	upgrade   func(m map[string]interface{})
+	downgrade func(m map[string]interface{})
+}
+
+// schemaMigrations[v] converts between versions v and v+1
+var schemaMigrations = []schemaMigration{
+	{
+		upgrade: func(m map[string]interface{}) {
+			if _, ok := m["Severity"]; ok {
+				return
+			}
+			level, _ := mapUint(m["Level"])
+			keywords, _ := mapUint(m["KeywordsMask"])
+			m["Severity"] = DefaultSeverity(level, keywords).String()
+		},
+		downgrade: func(m map[string]interface{}) {
+			delete(m, "Severity")
+		},
+	},
+	{
+		// When an event was received can't be derived
+		upgrade: func(m map[string]interface{}) {},
+		downgrade: func(m map[string]interface{}) {
+			delete(m, "ReceivedAt")
+		},
+	},
+}
+
+// MapSchemaVersion returns the schema version of an event map from
+// CreateMap, including one decoded from JSON, or 0 if it isn't stamped.
+func MapSchemaVersion(m map[string]interface{}) (int, error) {
+	value, ok := m[SchemaVersionKey]
+	if !ok {
+		return 0, nil
+	}
+	version, ok := mapUint(value)
+	if !ok {
+		return 0, fmt.Errorf("Invalid schema version %v", value)
+	}
+	return int(version), nil
+}
+
+// ConvertMap converts an event map from CreateMap, or decoded from its JSON,
+// to schema `version` in place, upgrading or downgrading it a version at a
+// time. Keys must be as CreateMap writes them, not reshaped by
+// CreateMapWith or a Transform. Fields added by an upgrade are derived from
+// the map's other fields, and those removed by a downgrade are lost.
+func ConvertMap(m map[string]interface{}, version int) error {
+	current, err := MapSchemaVersion(m)
+	if err != nil {
+		return err
+	}
+	if version < 0 || version > SchemaVersion {
+		return fmt.Errorf("Unknown schema version %v, the latest is %v", version, SchemaVersion)
+	}
+	if current > SchemaVersion {
+		return fmt.Errorf("Schema version %v is newer than this library's %v", current, SchemaVersion)
+	}
+	for ; current < version; current++ {
+		schemaMigrations[current].upgrade(m)
+	}
+	for ; current > version; current-- {
+		schemaMigrations[current-1].downgrade(m)
+	}
+	if version == 0 {
+		delete(m, SchemaVersionKey)
+	} else {
+		m[SchemaVersionKey] = version
+	}
+	return nil
+}
+
+// mapUint returns a number from an event map, as CreateMap stores it or as
+// decoded from JSON.
+func mapUint(value interface{}) (uint64, bool) {
+	switch n := value.(type) {
+	case uint64:
+		return n, true
+	case int:
+		if n >= 0 {
+			return uint64(n), true
+		}
+	case float64:
+		if n >= 0 && n == float64(uint64(n)) {
+			return uint64(n), true
+		}
+	case json.Number:
+		if parsed, err := n.Int64(); err == nil && parsed >= 0 {
+			return uint64(parsed), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+	"time"
+)
+
+func TestConvertMap(t *T) {
+	m := (&WinLogEvent{Level: LevelWarning, Severity: SeverityError, ReceivedAt: time.Now()}).CreateMap()
+	version, err := MapSchemaVersion(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(version, SchemaVersion, t)
+
+	if err := ConvertMap(m, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, received := m["ReceivedAt"]
+	assertEqual(received, false, t)
+	assertEqual(m["Severity"], "error", t)
+	assertEqual(m[SchemaVersionKey], 1, t)
+
+	if err := ConvertMap(m, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, severity := m["Severity"]
+	assertEqual(severity, false, t)
+	_, stamped := m[SchemaVersionKey]
+	assertEqual(stamped, false, t)
+
+	// Upgrading derives the fields added since, here from a map as decoded
+	// from JSON written before versions were stamped
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"EventId": 4625, "Level": 0, "KeywordsMask": 4503599627370496}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConvertMap(decoded, SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(decoded["Severity"], "warn", t)
+	assertEqual(decoded[SchemaVersionKey], SchemaVersion, t)
+
+	// Upgrading keeps fields the map already has
+	m = map[string]interface{}{"Level": uint64(LevelVerbose), "Severity": "critical"}
+	if err := ConvertMap(m, 1); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m["Severity"], "critical", t)
+
+	if err := ConvertMap(map[string]interface{}{}, SchemaVersion+1); err == nil {
+		t.Error("Expected an error converting to an unknown version")
+	}
+	if err := ConvertMap(map[string]interface{}{SchemaVersionKey: float64(SchemaVersion + 1)}, 0); err == nil {
+		t.Error("Expected an error converting from a newer version")
+	}
+	if _, err := MapSchemaVersion(map[string]interface{}{SchemaVersionKey: "one"}); err == nil {
+		t.Error("Expected an error for an invalid version")
+	}
+}
@@ -0,0 +1,225 @@
+package security
+
+import (
+	"net"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Names of the ImpersonationLevel insertion strings
+var impersonationLevels = map[string]string{
+	"%%1832": "Identification",
+	"%%1833": "Impersonation",
+	"%%1840": "Delegation",
+}
+
+// Descriptions of common Status and SubStatus codes of failed logons
+var logonStatusText = map[uint32]string{
+	0xc0000064: "User name does not exist",
+	0xc000006a: "Wrong password",
+	0xc000006d: "Bad user name or password",
+	0xc000006e: "Account restriction",
+	0xc000006f: "Logon outside authorized hours",
+	0xc0000070: "Logon from unauthorized workstation",
+	0xc0000071: "Password expired",
+	0xc0000072: "Account disabled",
+	0xc0000133: "Clock skew between client and domain controller",
+	0xc000015b: "Logon type not granted",
+	0xc0000192: "Netlogon service not started",
+	0xc0000193: "Account expired",
+	0xc0000224: "Password must be changed",
+	0xc0000234: "Account locked out",
+	0xc0000413: "Authentication firewall",
+}
+
+// StatusText describes a failed logon's Status or SubStatus, or returns ""
+// for an unknown code.
+func StatusText(status uint32) string {
+	return logonStatusText[status]
+}
+
+// Logon is a successful (4624) or failed (4625) logon.
+type Logon struct {
+	Event   *winlog.WinLogEvent
+	Success bool
+
+	// Account which requested the logon, often the computer account
+	SubjectUser User
+	// Account logged on to. For failed logons LogonId is 0.
+	TargetUser User
+	LogonType  LogonType
+
+	LogonProcessName      string
+	AuthenticationPackage string
+	LmPackageName         string
+	KeyLength             uint64
+	WorkstationName       string
+	LogonGuid             string
+
+	ProcessId   uint64
+	ProcessName string
+	// Source of a network logon, or nil if not recorded
+	IpAddress net.IP
+	IpPort    uint64
+
+	// Only for successful logons: the impersonation level, such as
+	// "Impersonation" or "Delegation", whether the logon has an elevated
+	// (administrator) token, and the linked logon of a split token.
+	ImpersonationLevel  string
+	ElevatedToken       bool
+	VirtualAccount      bool
+	RestrictedAdminMode bool
+	TargetLinkedLogonId uint64
+
+	// Only for failed logons: the NTSTATUS codes explaining the failure,
+	// which StatusText describes
+	Status    uint32
+	SubStatus uint32
+}
+
+// ParseLogon parses a 4624 or 4625 event.
+func ParseLogon(ev *winlog.WinLogEvent) (*Logon, error) {
+	f, err := newFields(ev, EventLogon, EventLogonFailed)
+	if err != nil {
+		return nil, err
+	}
+	logon := &Logon{
+		Event:                 ev,
+		Success:               ev.EventId == EventLogon,
+		SubjectUser:           f.user("Subject"),
+		TargetUser:            f.user("Target"),
+		LogonType:             LogonType(f.uint("LogonType")),
+		LogonProcessName:      f.str("LogonProcessName"),
+		AuthenticationPackage: f.str("AuthenticationPackageName"),
+		LmPackageName:         f.str("LmPackageName"),
+		KeyLength:             f.uint("KeyLength"),
+		WorkstationName:       f.str("WorkstationName"),
+		LogonGuid:             f.str("LogonGuid"),
+		ProcessId:             f.uint("ProcessId"),
+		ProcessName:           f.str("ProcessName"),
+		IpAddress:             f.ip("IpAddress"),
+		IpPort:                f.uint("IpPort"),
+	}
+	if logon.Success {
+		level := f.str("ImpersonationLevel")
+		if name, ok := impersonationLevels[level]; ok {
+			level = name
+		}
+		logon.ImpersonationLevel = level
+		logon.ElevatedToken = f.flag("ElevatedToken")
+		logon.VirtualAccount = f.flag("VirtualAccount")
+		logon.RestrictedAdminMode = f.flag("RestrictedAdminMode")
+		logon.TargetLinkedLogonId = f.uint("TargetLinkedLogonId")
+	} else {
+		logon.Status = uint32(f.uint("Status"))
+		logon.SubStatus = uint32(f.uint("SubStatus"))
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return logon, nil
+}
+
+// Logoff is the end of a logon session (4634).
+type Logoff struct {
+	Event      *winlog.WinLogEvent
+	TargetUser User
+	LogonType  LogonType
+}
+
+// ParseLogoff parses a 4634 event.
+func ParseLogoff(ev *winlog.WinLogEvent) (*Logoff, error) {
+	f, err := newFields(ev, EventLogoff)
+	if err != nil {
+		return nil, err
+	}
+	logoff := &Logoff{
+		Event:      ev,
+		TargetUser: f.user("Target"),
+		LogonType:  LogonType(f.uint("LogonType")),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return logoff, nil
+}
+
+// ExplicitLogon is a logon attempted with explicitly supplied credentials
+// (4648), as by runas or when connecting to a share as another user.
+type ExplicitLogon struct {
+	Event       *winlog.WinLogEvent
+	SubjectUser User
+	LogonGuid   string
+	// Account whose credentials were used. Sid and LogonId are not recorded.
+	TargetUser       User
+	TargetLogonGuid  string
+	TargetServerName string
+	TargetInfo       string
+
+	ProcessId   uint64
+	ProcessName string
+	IpAddress   net.IP
+	IpPort      uint64
+}
+
+// ParseExplicitLogon parses a 4648 event.
+func ParseExplicitLogon(ev *winlog.WinLogEvent) (*ExplicitLogon, error) {
+	f, err := newFields(ev, EventExplicitLogon)
+	if err != nil {
+		return nil, err
+	}
+	logon := &ExplicitLogon{
+		Event:            ev,
+		SubjectUser:      f.user("Subject"),
+		LogonGuid:        f.str("LogonGuid"),
+		TargetUser:       f.user("Target"),
+		TargetLogonGuid:  f.str("TargetLogonGuid"),
+		TargetServerName: f.str("TargetServerName"),
+		TargetInfo:       f.str("TargetInfo"),
+		ProcessId:        f.uint("ProcessId"),
+		ProcessName:      f.str("ProcessName"),
+		IpAddress:        f.ip("IpAddress"),
+		IpPort:           f.uint("IpPort"),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return logon, nil
+}
+
+// SpecialPrivileges records sensitive privileges, such as SeDebugPrivilege,
+// assigned to a new logon (4672), which usually means an administrator.
+type SpecialPrivileges struct {
+	Event       *winlog.WinLogEvent
+	SubjectUser User
+	Privileges  []string
+}
+
+// HasPrivilege reports whether the logon was assigned the named privilege.
+func (p *SpecialPrivileges) HasPrivilege(name string) bool {
+	for _, privilege := range p.Privileges {
+		if strings.EqualFold(privilege, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSpecialPrivileges parses a 4672 event.
+func ParseSpecialPrivileges(ev *winlog.WinLogEvent) (*SpecialPrivileges, error) {
+	f, err := newFields(ev, EventSpecialPrivileges)
+	if err != nil {
+		return nil, err
+	}
+	privileges := &SpecialPrivileges{
+		Event:       ev,
+		SubjectUser: f.user("Subject"),
+		// One per line, indented with tabs
+		Privileges: strings.Fields(f.str("PrivilegeList")),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return privileges, nil
+}
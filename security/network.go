@@ -0,0 +1,133 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Direction is which way a connection was made, relative to this computer.
+type Direction int
+
+const (
+	DirectionUnknown Direction = iota
+	Inbound
+	Outbound
+)
+
+var directionNames = map[Direction]string{
+	DirectionUnknown: "Unknown",
+	Inbound:          "Inbound",
+	Outbound:         "Outbound",
+}
+
+func (d Direction) String() string {
+	if name, ok := directionNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// Directions by insertion string or, when the message was rendered, name
+var directions = map[string]Direction{
+	"%%14592":  Inbound,
+	"%%14593":  Outbound,
+	"inbound":  Inbound,
+	"outbound": Outbound,
+}
+
+// Names of the LayerName insertion strings
+var layerNames = map[string]string{
+	"%%14608": "Resource Assignment",
+	"%%14609": "Listen",
+	"%%14610": "Receive/Accept",
+	"%%14611": "Connect",
+}
+
+// Names of common IP protocol numbers
+var protocolNames = map[uint8]string{
+	1:  "ICMP",
+	2:  "IGMP",
+	6:  "TCP",
+	17: "UDP",
+	47: "GRE",
+	50: "ESP",
+	58: "ICMPv6",
+}
+
+// ProtocolName returns the name of an IP protocol number, such as "TCP", or
+// the number itself for uncommon protocols.
+func ProtocolName(protocol uint8) string {
+	if name, ok := protocolNames[protocol]; ok {
+		return name
+	}
+	return fmt.Sprint(protocol)
+}
+
+// Connection is a connection allowed (5156) or blocked (5157) by the Windows
+// Filtering Platform. Source and destination are as the packet travelled, so
+// for inbound connections the destination is this computer.
+type Connection struct {
+	Event   *winlog.WinLogEvent
+	Allowed bool
+
+	ProcessId uint64
+	// Image of the process, as a device path such as
+	// \device\harddiskvolume2\windows\system32\svchost.exe
+	Application string
+	Direction   Direction
+
+	// Addresses are in IPv4 form where possible
+	SourceAddress      net.IP
+	SourcePort         uint16
+	DestinationAddress net.IP
+	DestinationPort    uint16
+	// IP protocol number, which ProtocolName names
+	Protocol uint8
+
+	FilterId uint64
+	// Such as "Connect" or "Receive/Accept"
+	LayerName string
+	LayerId   uint64
+}
+
+// ParseConnection parses a 5156 or 5157 event.
+func ParseConnection(ev *winlog.WinLogEvent) (*Connection, error) {
+	f, err := newFields(ev, EventConnectionAllowed, EventConnectionBlocked)
+	if err != nil {
+		return nil, err
+	}
+	layer := f.str("LayerName")
+	if name, ok := layerNames[layer]; ok {
+		layer = name
+	}
+	connection := &Connection{
+		Event:              ev,
+		Allowed:            ev.EventId == EventConnectionAllowed,
+		ProcessId:          f.uint("ProcessID"),
+		Application:        f.str("Application"),
+		Direction:          directions[strings.ToLower(f.str("Direction"))],
+		SourceAddress:      normalizeIP(f.ip("SourceAddress")),
+		SourcePort:         uint16(f.uint("SourcePort")),
+		DestinationAddress: normalizeIP(f.ip("DestAddress")),
+		DestinationPort:    uint16(f.uint("DestPort")),
+		Protocol:           uint8(f.uint("Protocol")),
+		FilterId:           f.uint("FilterRTID"),
+		LayerName:          layer,
+		LayerId:            f.uint("LayerRTID"),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return connection, nil
+}
+
+// normalizeIP converts IPv4-mapped IPv6 addresses to IPv4 form.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
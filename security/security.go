@@ -0,0 +1,186 @@
+// Package security converts logon and Windows Filtering Platform connection
+// events from the Windows Security log into typed structs, so consumers don't
+// each parse the same EventData strings:
+//
+//	parsed, err := security.Parse(ev)
+//	switch e := parsed.(type) {
+//	case *security.Logon:
+//		if !e.Success && e.LogonType == security.LogonNetwork {
+//			log.Printf("Failed network logon for %v from %v", e.TargetUser, e.IpAddress)
+//		}
+//	}
+//
+// Events are read from WinLogEvent.EventData, or parsed from Xml when the
+// watcher didn't fill EventData in.
+package security
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider of the events parsed by this package
+const ProviderName = "Microsoft-Windows-Security-Auditing"
+
+// Event IDs parsed by this package
+const (
+	EventLogon             = 4624
+	EventLogonFailed       = 4625
+	EventLogoff            = 4634
+	EventExplicitLogon     = 4648
+	EventSpecialPrivileges = 4672
+	EventConnectionAllowed = 5156
+	EventConnectionBlocked = 5157
+)
+
+// ErrUnsupportedEvent is returned by Parse for events this package doesn't
+// know how to parse.
+var ErrUnsupportedEvent = errors.New("Not a supported Security event")
+
+// LogonType is how a user logged on, from the LogonType field.
+type LogonType uint32
+
+const (
+	LogonSystem                  LogonType = 0
+	LogonInteractive             LogonType = 2
+	LogonNetwork                 LogonType = 3
+	LogonBatch                   LogonType = 4
+	LogonService                 LogonType = 5
+	LogonUnlock                  LogonType = 7
+	LogonNetworkCleartext        LogonType = 8
+	LogonNewCredentials          LogonType = 9
+	LogonRemoteInteractive       LogonType = 10
+	LogonCachedInteractive       LogonType = 11
+	LogonCachedRemoteInteractive LogonType = 12
+	LogonCachedUnlock            LogonType = 13
+)
+
+var logonTypeNames = map[LogonType]string{
+	LogonSystem:                  "System",
+	LogonInteractive:             "Interactive",
+	LogonNetwork:                 "Network",
+	LogonBatch:                   "Batch",
+	LogonService:                 "Service",
+	LogonUnlock:                  "Unlock",
+	LogonNetworkCleartext:        "NetworkCleartext",
+	LogonNewCredentials:          "NewCredentials",
+	LogonRemoteInteractive:       "RemoteInteractive",
+	LogonCachedInteractive:       "CachedInteractive",
+	LogonCachedRemoteInteractive: "CachedRemoteInteractive",
+	LogonCachedUnlock:            "CachedUnlock",
+}
+
+func (t LogonType) String() string {
+	if name, ok := logonTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogonType(%d)", uint32(t))
+}
+
+// User identifies the account in a Subject or Target group of fields.
+// Fields the event leaves out are empty.
+type User struct {
+	Sid     string
+	Name    string
+	Domain  string
+	LogonId uint64
+}
+
+func (u User) String() string {
+	if u.Domain == "" {
+		return u.Name
+	}
+	return u.Domain + `\` + u.Name
+}
+
+// Parse converts a supported Security event into a *Logon, *Logoff,
+// *ExplicitLogon, *SpecialPrivileges or *Connection. Other events return
+// ErrUnsupportedEvent.
+func Parse(ev *winlog.WinLogEvent) (interface{}, error) {
+	if ev.ProviderName != "" && ev.ProviderName != ProviderName {
+		return nil, ErrUnsupportedEvent
+	}
+	switch ev.EventId {
+	case EventLogon, EventLogonFailed:
+		return ParseLogon(ev)
+	case EventLogoff:
+		return ParseLogoff(ev)
+	case EventExplicitLogon:
+		return ParseExplicitLogon(ev)
+	case EventSpecialPrivileges:
+		return ParseSpecialPrivileges(ev)
+	case EventConnectionAllowed, EventConnectionBlocked:
+		return ParseConnection(ev)
+	}
+	return nil, ErrUnsupportedEvent
+}
+
+// fields reads EventData values, remembering the first malformed one.
+type fields struct {
+	data map[string]string
+	err  error
+}
+
+func newFields(ev *winlog.WinLogEvent, ids ...uint64) (*fields, error) {
+	supported := false
+	for _, id := range ids {
+		supported = supported || ev.EventId == id
+	}
+	if !supported {
+		return nil, fmt.Errorf("Event %v isn't one of %v", ev.EventId, ids)
+	}
+	data := ev.EventData
+	if data == nil && len(ev.Xml) > 0 {
+		var err error
+		if data, err = winlog.ParseEventData(ev.Xml); err != nil {
+			return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+		}
+	}
+	return &fields{data: data}, nil
+}
+
+// str returns a field, with "-", which the event log uses for a missing
+// value, as "".
+func (f *fields) str(name string) string {
+	value := strings.TrimSpace(f.data[name])
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// uint parses a decimal or 0x-prefixed hexadecimal field, as 0 if missing.
+func (f *fields) uint(name string) uint64 {
+	value := f.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 0, 64)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("Failed to parse %v %q: %v", name, value, err)
+	}
+	return n
+}
+
+// flag parses a %%1842 (Yes) or %%1843 (No) field.
+func (f *fields) flag(name string) bool {
+	return f.str(name) == "%%1842"
+}
+
+func (f *fields) ip(name string) net.IP {
+	return net.ParseIP(f.str(name))
+}
+
+func (f *fields) user(prefix string) User {
+	return User{
+		Sid:     f.str(prefix + "UserSid"),
+		Name:    f.str(prefix + "UserName"),
+		Domain:  f.str(prefix + "DomainName"),
+		LogonId: f.uint(prefix + "LogonId"),
+	}
+}
@@ -0,0 +1,150 @@
+package security
+
+import (
+	"net"
+	. "testing"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+func TestLogon(t *T) {
+	parsed, err := Parse(testutil.EventFixture(t, EventLogon))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logon := parsed.(*Logon)
+	testutil.AssertEqual(logon.Success, true, t)
+	testutil.AssertEqual(logon.SubjectUser.String(), `CORP\WS01$`, t)
+	testutil.AssertEqual(logon.SubjectUser.LogonId, uint64(0x3e7), t)
+	testutil.AssertEqual(logon.TargetUser.Sid, "S-1-5-21-3623811015-3361044348-30300820-1013", t)
+	testutil.AssertEqual(logon.TargetUser.String(), `CORP\alice`, t)
+	testutil.AssertEqual(logon.TargetUser.LogonId, uint64(0x1a2b3c), t)
+	testutil.AssertEqual(logon.LogonType, LogonRemoteInteractive, t)
+	testutil.AssertEqual(logon.LogonType.String(), "RemoteInteractive", t)
+	testutil.AssertEqual(logon.LogonProcessName, "User32", t)
+	testutil.AssertEqual(logon.AuthenticationPackage, "Negotiate", t)
+	testutil.AssertEqual(logon.LmPackageName, "", t)
+	testutil.AssertEqual(logon.ProcessId, uint64(0x2a0), t)
+	testutil.AssertEqual(logon.IpAddress.Equal(net.ParseIP("192.168.10.25")), true, t)
+	testutil.AssertEqual(logon.IpPort, uint64(0), t)
+	testutil.AssertEqual(logon.ImpersonationLevel, "Impersonation", t)
+	testutil.AssertEqual(logon.ElevatedToken, true, t)
+	testutil.AssertEqual(logon.VirtualAccount, false, t)
+	testutil.AssertEqual(logon.RestrictedAdminMode, false, t)
+	testutil.AssertEqual(logon.TargetLinkedLogonId, uint64(0x1a2b4d), t)
+	testutil.AssertEqual(logon.Status, uint32(0), t)
+}
+
+func TestFailedLogon(t *T) {
+	// Without EventData, the XML is parsed
+	ev := testutil.EventFixture(t, EventLogonFailed)
+	ev.EventData = nil
+	logon, err := ParseLogon(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(logon.Success, false, t)
+	testutil.AssertEqual(logon.SubjectUser.Name, "", t)
+	testutil.AssertEqual(logon.TargetUser.String(), `.\administrator`, t)
+	testutil.AssertEqual(logon.TargetUser.LogonId, uint64(0), t)
+	testutil.AssertEqual(logon.LogonType, LogonNetwork, t)
+	testutil.AssertEqual(logon.AuthenticationPackage, "NTLM", t)
+	testutil.AssertEqual(logon.WorkstationName, "KALI", t)
+	testutil.AssertEqual(logon.ProcessName, "", t)
+	testutil.AssertEqual(logon.IpAddress.String(), "fe80::1c2d:3e4f:5a6b:7c8d", t)
+	testutil.AssertEqual(logon.IpPort, uint64(49812), t)
+	testutil.AssertEqual(logon.Status, uint32(0xc000006d), t)
+	testutil.AssertEqual(StatusText(logon.SubStatus), "Wrong password", t)
+	testutil.AssertEqual(logon.ElevatedToken, false, t)
+}
+
+func TestLogoff(t *T) {
+	parsed, err := Parse(testutil.EventFixture(t, EventLogoff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logoff := parsed.(*Logoff)
+	testutil.AssertEqual(logoff.TargetUser.Name, "alice", t)
+	testutil.AssertEqual(logoff.TargetUser.LogonId, uint64(0x1a2b3c), t)
+	testutil.AssertEqual(logoff.LogonType, LogonRemoteInteractive, t)
+}
+
+func TestExplicitLogon(t *T) {
+	parsed, err := Parse(testutil.EventFixture(t, EventExplicitLogon))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logon := parsed.(*ExplicitLogon)
+	testutil.AssertEqual(logon.SubjectUser.String(), `CORP\alice`, t)
+	testutil.AssertEqual(logon.TargetUser.String(), `CORP\svc_backup`, t)
+	testutil.AssertEqual(logon.TargetUser.Sid, "", t)
+	testutil.AssertEqual(logon.TargetServerName, "fs01.corp.example.com", t)
+	testutil.AssertEqual(logon.ProcessName, `C:\Windows\System32\runas.exe`, t)
+	testutil.AssertEqual(logon.IpAddress.IsLoopback(), true, t)
+}
+
+func TestSpecialPrivileges(t *T) {
+	parsed, err := Parse(testutil.EventFixture(t, EventSpecialPrivileges))
+	if err != nil {
+		t.Fatal(err)
+	}
+	privileges := parsed.(*SpecialPrivileges)
+	testutil.AssertEqual(privileges.SubjectUser.LogonId, uint64(0x1a2b3c), t)
+	testutil.AssertEqual(len(privileges.Privileges), 4, t)
+	testutil.AssertEqual(privileges.Privileges[0], "SeSecurityPrivilege", t)
+	testutil.AssertEqual(privileges.HasPrivilege("sedebugprivilege"), true, t)
+	testutil.AssertEqual(privileges.HasPrivilege("SeTcbPrivilege"), false, t)
+}
+
+func TestConnection(t *T) {
+	parsed, err := Parse(testutil.EventFixture(t, EventConnectionAllowed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := parsed.(*Connection)
+	testutil.AssertEqual(c.Allowed, true, t)
+	testutil.AssertEqual(c.ProcessId, uint64(4120), t)
+	testutil.AssertEqual(c.Application, `\device\harddiskvolume2\program files\mozilla firefox\firefox.exe`, t)
+	testutil.AssertEqual(c.Direction, Outbound, t)
+	testutil.AssertEqual(c.Direction.String(), "Outbound", t)
+	testutil.AssertEqual(c.SourceAddress.String(), "192.168.10.25", t)
+	testutil.AssertEqual(len(c.SourceAddress), net.IPv4len, t)
+	testutil.AssertEqual(c.SourcePort, uint16(50123), t)
+	testutil.AssertEqual(c.DestinationAddress.String(), "93.184.216.34", t)
+	testutil.AssertEqual(c.DestinationPort, uint16(443), t)
+	testutil.AssertEqual(ProtocolName(c.Protocol), "TCP", t)
+	testutil.AssertEqual(c.FilterId, uint64(68731), t)
+	testutil.AssertEqual(c.LayerName, "Connect", t)
+
+	parsed, err = Parse(testutil.EventFixture(t, EventConnectionBlocked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = parsed.(*Connection)
+	testutil.AssertEqual(c.Allowed, false, t)
+	testutil.AssertEqual(c.Direction, Inbound, t)
+	// IPv4-mapped addresses are normalized
+	testutil.AssertEqual(len(c.SourceAddress), net.IPv4len, t)
+	testutil.AssertEqual(c.SourceAddress.String(), "10.0.0.9", t)
+	testutil.AssertEqual(c.DestinationAddress.String(), "fe80::2c1a:5bff:fe3d:1", t)
+	testutil.AssertEqual(ProtocolName(c.Protocol), "UDP", t)
+	testutil.AssertEqual(ProtocolName(115), "115", t)
+	testutil.AssertEqual(c.LayerName, "Receive/Accept", t)
+}
+
+func TestParseErrors(t *T) {
+	if _, err := Parse(&winlog.WinLogEvent{ProviderName: ProviderName, EventId: 4688}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+	if _, err := Parse(&winlog.WinLogEvent{ProviderName: "Application Error", EventId: 4624}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+	if _, err := ParseLogoff(&winlog.WinLogEvent{EventId: 4624}); err == nil {
+		t.Fatal("Expected an error parsing 4624 as a logoff")
+	}
+	ev := &winlog.WinLogEvent{EventId: 4634, EventData: map[string]string{"LogonType": "ten"}}
+	if _, err := ParseLogoff(ev); err == nil {
+		t.Fatal("Expected an error for a malformed LogonType")
+	}
+}
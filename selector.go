@@ -0,0 +1,200 @@
+package winlog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The event log rejects XPath queries with more than this many
+// expressions (comparisons) as invalid.
+const MaxQueryExpressions = 22
+
+// EventSelector is a simple allow/deny list of providers and event IDs. It is
+// compiled to XPath so the filtering happens in the event log service rather
+// than after events have been rendered.
+//
+// Empty allow lists allow everything. Deny lists are applied after the allow
+// lists.
+type EventSelector struct {
+	AllowProviders []string
+	DenyProviders  []string
+	AllowEventIds  []uint64
+	DenyEventIds   []uint64
+}
+
+type xpathTerm struct {
+	expr string
+	cost int
+}
+
+// xpathLiteral quotes a string for XPath, which has no escapes. A string
+// containing both kinds of quote is built with concat(), quoting each `'` in
+// double quotes and the rest in single quotes.
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	var parts []string
+	for i, part := range strings.Split(s, "'") {
+		if i > 0 {
+			parts = append(parts, `"'"`)
+		}
+		if part != "" {
+			parts = append(parts, "'"+part+"'")
+		}
+	}
+	return "concat(" + strings.Join(parts, ", ") + ")"
+}
+
+func providerTerms(providers []string) []xpathTerm {
+	terms := make([]xpathTerm, 0, len(providers))
+	for _, p := range providers {
+		terms = append(terms, xpathTerm{"Provider[@Name=" + xpathLiteral(p) + "]", 1})
+	}
+	return terms
+}
+
+// eventIdTerms collapses runs of consecutive IDs into ranges, which cost two
+// expressions instead of one per ID.
+func eventIdTerms(ids []uint64) []xpathTerm {
+	sorted := append([]uint64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var terms []xpathTerm
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		if sorted[j]-sorted[i] >= 2 {
+			terms = append(terms, xpathTerm{fmt.Sprintf("(EventID>=%d and EventID<=%d)", sorted[i], sorted[j]), 2})
+		} else {
+			for k := i; k <= j; k++ {
+				if k > i && sorted[k] == sorted[k-1] {
+					continue
+				}
+				terms = append(terms, xpathTerm{fmt.Sprintf("EventID=%d", sorted[k]), 1})
+			}
+		}
+		i = j + 1
+	}
+	return terms
+}
+
+func termsCost(terms []xpathTerm) int {
+	cost := 0
+	for _, t := range terms {
+		cost += t.cost
+	}
+	return cost
+}
+
+func joinTerms(terms []xpathTerm) string {
+	exprs := make([]string, len(terms))
+	for i, t := range terms {
+		exprs[i] = t.expr
+	}
+	return strings.Join(exprs, " or ")
+}
+
+// chunkTerms splits terms into groups costing at most `budget` each.
+func chunkTerms(terms []xpathTerm, budget int) [][]xpathTerm {
+	if len(terms) == 0 {
+		return [][]xpathTerm{nil}
+	}
+	var chunks [][]xpathTerm
+	var current []xpathTerm
+	cost := 0
+	for _, t := range terms {
+		if cost+t.cost > budget && len(current) > 0 {
+			chunks = append(chunks, current)
+			current, cost = nil, 0
+		}
+		current = append(current, t)
+		cost += t.cost
+	}
+	return append(chunks, current)
+}
+
+// Query compiles the selector to a single XPath query. Unlike Queries, the
+// result may exceed MaxQueryExpressions; the watcher's Subscribe methods
+// split such queries across several subscriptions automatically.
+func (s *EventSelector) Query() string {
+	var clauses []string
+	if len(s.AllowProviders) > 0 {
+		clauses = append(clauses, "("+joinTerms(providerTerms(s.AllowProviders))+")")
+	}
+	if len(s.AllowEventIds) > 0 {
+		clauses = append(clauses, "("+joinTerms(eventIdTerms(s.AllowEventIds))+")")
+	}
+	if deny := append(providerTerms(s.DenyProviders), eventIdTerms(s.DenyEventIds)...); len(deny) > 0 {
+		clauses = append(clauses, "not("+joinTerms(deny)+")")
+	}
+	if len(clauses) == 0 {
+		return "*"
+	}
+	return "*[System[" + strings.Join(clauses, " and ") + "]]"
+}
+
+// Queries compiles the selector to XPath queries. Together the queries select
+// exactly the events allowed by the selector; if everything fits within
+// MaxQueryExpressions a single query is returned, otherwise the allow lists
+// are split across several queries, each of which needs its own subscription.
+// An error is returned if the deny lists alone exceed the limit.
+func (s *EventSelector) Queries() ([]string, error) {
+	allowProviders := providerTerms(s.AllowProviders)
+	allowIds := eventIdTerms(s.AllowEventIds)
+	deny := append(providerTerms(s.DenyProviders), eventIdTerms(s.DenyEventIds)...)
+
+	budget := MaxQueryExpressions - termsCost(deny)
+	if len(allowProviders) > 0 && len(allowIds) > 0 {
+		budget--
+	}
+	if budget < 1 || (budget < 3 && len(allowProviders) > 0 && len(allowIds) > 0) {
+		return nil, fmt.Errorf("Deny lists need %v expressions, too many for a single query", termsCost(deny))
+	}
+
+	// Share the budget between the two allow lists in proportion to their size
+	providerCost, idCost := termsCost(allowProviders), termsCost(allowIds)
+	providerBudget, idBudget := budget, budget
+	if providerCost > 0 && idCost > 0 && providerCost+idCost > budget {
+		providerBudget = budget * providerCost / (providerCost + idCost)
+		if providerBudget < 1 {
+			providerBudget = 1
+		}
+		if budget-providerBudget < 2 {
+			providerBudget = budget - 2
+		}
+		idBudget = budget - providerBudget
+	}
+
+	var denyClause string
+	if len(deny) > 0 {
+		denyClause = "not(" + joinTerms(deny) + ")"
+	}
+
+	var queries []string
+	for _, providers := range chunkTerms(allowProviders, providerBudget) {
+		for _, ids := range chunkTerms(allowIds, idBudget) {
+			var clauses []string
+			if len(providers) > 0 {
+				clauses = append(clauses, "("+joinTerms(providers)+")")
+			}
+			if len(ids) > 0 {
+				clauses = append(clauses, "("+joinTerms(ids)+")")
+			}
+			if denyClause != "" {
+				clauses = append(clauses, denyClause)
+			}
+			if len(clauses) == 0 {
+				queries = append(queries, "*")
+			} else {
+				queries = append(queries, "*[System["+strings.Join(clauses, " and ")+"]]")
+			}
+		}
+	}
+	return queries, nil
+}
@@ -0,0 +1,121 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+	. "testing"
+)
+
+func TestSelectorSingleQuery(t *T) {
+	s := &EventSelector{
+		AllowProviders: []string{"Microsoft-Windows-Security-Auditing"},
+		AllowEventIds:  []uint64{4624, 4625, 4626, 4627, 4634},
+		DenyEventIds:   []uint64{4627},
+	}
+	queries, err := s.Queries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(queries), 1, t)
+	assertEqual(queries[0], "*[System[(Provider[@Name='Microsoft-Windows-Security-Auditing']) and "+
+		"((EventID>=4624 and EventID<=4627) or EventID=4634) and not(EventID=4627)]]", t)
+}
+
+func TestSelectorEmpty(t *T) {
+	queries, err := (&EventSelector{}).Queries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(queries), 1, t)
+	assertEqual(queries[0], "*", t)
+}
+
+func TestSelectorSplitsLargeLists(t *T) {
+	s := &EventSelector{DenyProviders: []string{"Noisy"}}
+	for id := uint64(0); id < 100; id += 2 {
+		s.AllowEventIds = append(s.AllowEventIds, id)
+	}
+	queries, err := s.Queries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) < 2 {
+		t.Fatalf("Expected the query to be split, got %v", queries)
+	}
+	seen := 0
+	for _, q := range queries {
+		expressions := strings.Count(q, "=")
+		if expressions > MaxQueryExpressions {
+			t.Fatalf("Query has %v expressions: %v", expressions, q)
+		}
+		if !strings.Contains(q, "not(Provider[@Name='Noisy'])") {
+			t.Fatalf("Deny clause missing from %v", q)
+		}
+		seen += strings.Count(q, "EventID=")
+	}
+	assertEqual(seen, 50, t)
+}
+
+func TestSelectorTooManyDenies(t *T) {
+	s := &EventSelector{}
+	for id := uint64(0); id < 60; id += 2 {
+		s.DenyEventIds = append(s.DenyEventIds, id)
+	}
+	if _, err := s.Queries(); err == nil {
+		t.Fatal("No error when deny lists exceed the expression limit")
+	}
+}
+
+func TestSplitQuery(t *T) {
+	small := "*[System[EventID=1 or EventID=2]]"
+	assertEqual(len(splitQuery(small)), 1, t)
+
+	s := &EventSelector{
+		AllowProviders: []string{"A", "B", "C"},
+		DenyEventIds:   []uint64{7, 9},
+	}
+	for id := uint64(100); id < 160; id += 2 {
+		s.AllowEventIds = append(s.AllowEventIds, id)
+	}
+	query := s.Query()
+	if countExpressions(query) <= MaxQueryExpressions {
+		t.Fatalf("Test query should be over the limit: %v", query)
+	}
+	queries := splitQuery(query)
+	if len(queries) < 2 {
+		t.Fatalf("Query wasn't split: %v", queries)
+	}
+	ids := 0
+	for _, q := range queries {
+		if n := countExpressions(q); n > MaxQueryExpressions {
+			t.Fatalf("Query has %v expressions: %v", n, q)
+		}
+		if !strings.Contains(q, "(Provider[@Name='A'] or Provider[@Name='B'] or Provider[@Name='C'])") ||
+			!strings.HasSuffix(q, "not(EventID=7 or EventID=9)]]") {
+			t.Fatalf("Split query lost a clause: %v", q)
+		}
+		ids += strings.Count(q, "EventID=") - 2
+	}
+	assertEqual(ids, 30, t)
+}
+
+func TestSplitQueryUnsplittable(t *T) {
+	var clauses []string
+	for i := 0; i < 30; i++ {
+		clauses = append(clauses, fmt.Sprintf("EventID!=%d", i))
+	}
+	query := "*[System[" + strings.Join(clauses, " and ") + "]]"
+	queries := splitQuery(query)
+	assertEqual(len(queries), 1, t)
+	assertEqual(queries[0], query, t)
+}
+
+func TestXpathLiteral(t *T) {
+	assertEqual(xpathLiteral("Contoso"), "'Contoso'", t)
+	assertEqual(xpathLiteral("O'Brien"), `"O'Brien"`, t)
+	assertEqual(xpathLiteral(`Say "hi"`), `'Say "hi"'`, t)
+	assertEqual(xpathLiteral(`O'Brien "Jr"`), `concat('O', "'", 'Brien "Jr"')`, t)
+	assertEqual(xpathLiteral(`'"'`), `concat("'", '"', "'")`, t)
+	selector := &EventSelector{AllowProviders: []string{`It's "quoted"`}}
+	assertEqual(selector.Query(), `*[System[(Provider[@Name=concat('It', "'", 's "quoted"')])]]`, t)
+}
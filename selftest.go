@@ -0,0 +1,184 @@
+package winlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// How long CheckHealth waits for the newest event of a channel
+const selfTestTimeout = 5 * time.Second
+
+// SelfTestReport is the result of CheckHealth, for agents to log at startup
+// and support tooling to show.
+type SelfTestReport struct {
+	// False if any check failed
+	Healthy bool
+	// Whether wevtapi.dll could be loaded, and why not
+	APIAvailable bool
+	APIError     string `json:",omitempty"`
+	// Number of channels registered on the computer, and why they couldn't
+	// be enumerated
+	RegisteredChannels int
+	ChannelsError      string `json:",omitempty"`
+	Channels           map[string]ChannelSelfTest
+	Time               time.Time
+}
+
+// ChannelSelfTest is the result of checking one channel. Each error is empty
+// if the check succeeded or wasn't reached.
+type ChannelSelfTest struct {
+	// Whether the channel is registered, if the channels could be enumerated
+	Registered bool
+	// Whether the channel's log could be opened, with its record count
+	Readable        bool
+	ReadError       string `json:",omitempty"`
+	NumberOfRecords uint64
+	// The newest event, as rendered for the trial. Empty channels are
+	// healthy but have no trial render.
+	NewestRecordId uint64
+	ProviderName   string `json:",omitempty"`
+	RenderError    string `json:",omitempty"`
+	XmlError       string `json:",omitempty"`
+	// Formatting the message is reported but doesn't make the channel
+	// unhealthy, since many providers have no message resources.
+	FormatError string `json:",omitempty"`
+}
+
+// CheckHealth checks that the event log can be used: that wevtapi.dll loads,
+// that channels can be enumerated, and that each of `channels` exists, can be
+// read, and that its newest event can be rendered and formatted. Every check
+// is run even if an earlier one fails, so the report shows all the problems.
+// It's meant to run when an agent starts and from support tooling, and
+// doesn't need a watcher.
+func CheckHealth(channels ...string) SelfTestReport {
+	return selfTest{
+		loadAPI:      loadWevtapi,
+		listChannels: ListChannels,
+		api:          SystemEventLogAPI,
+		newest:       newestEvent,
+	}.run(channels)
+}
+
+// selfTest holds the calls made by CheckHealth, so they can be replaced in
+// tests.
+type selfTest struct {
+	loadAPI      func() error
+	listChannels func() ([]string, error)
+	api          EventLogAPI
+	// Read the newest event on a channel, returning io.EOF if it's empty
+	newest func(channel string) (EventHandle, error)
+}
+
+func (s selfTest) run(channels []string) SelfTestReport {
+	report := SelfTestReport{Healthy: true, Channels: make(map[string]ChannelSelfTest), Time: time.Now()}
+	if err := s.loadAPI(); err != nil {
+		report.Healthy = false
+		report.APIError = err.Error()
+	} else {
+		report.APIAvailable = true
+	}
+
+	registered := make(map[string]bool)
+	all, err := s.listChannels()
+	if err != nil {
+		report.Healthy = false
+		report.ChannelsError = err.Error()
+	}
+	for _, channel := range all {
+		registered[strings.ToLower(channel)] = true
+	}
+	report.RegisteredChannels = len(all)
+
+	renderContext, contextErr := s.api.CreateRenderContext()
+	if contextErr == nil {
+		defer s.api.Close(uint64(renderContext))
+	}
+	for _, channel := range channels {
+		result := s.checkChannel(channel, renderContext, contextErr)
+		result.Registered = registered[strings.ToLower(channel)]
+		if (err == nil && !result.Registered) || !result.Readable || result.RenderError != "" || result.XmlError != "" {
+			report.Healthy = false
+		}
+		report.Channels[channel] = result
+	}
+	return report
+}
+
+func (s selfTest) checkChannel(channel string, renderContext SysRenderContext, contextErr error) ChannelSelfTest {
+	var result ChannelSelfTest
+	info, err := s.api.ChannelInfo(channel)
+	if err != nil {
+		result.ReadError = err.Error()
+		return result
+	}
+	result.Readable = true
+	result.NumberOfRecords = info.NumberOfRecords
+
+	event, err := s.newest(channel)
+	if err == io.EOF {
+		return result
+	} else if err != nil {
+		result.Readable = false
+		result.ReadError = fmt.Sprintf("Failed to read newest event: %v", err)
+		return result
+	}
+	defer s.api.Close(uint64(event))
+
+	if contextErr != nil {
+		result.RenderError = fmt.Sprintf("Failed to create render context: %v", contextErr)
+	} else if values, err := s.api.RenderValues(renderContext, event); err != nil {
+		result.RenderError = err.Error()
+	} else {
+		result.NewestRecordId, _ = values.Uint(EvtSystemEventRecordId)
+		result.ProviderName, _ = values.String(EvtSystemProviderName)
+	}
+	if _, err := s.api.RenderXML(event); err != nil {
+		result.XmlError = err.Error()
+	}
+	if result.ProviderName == "" {
+		return result
+	}
+	publisher, err := s.api.OpenPublisherMetadata(result.ProviderName)
+	if err != nil {
+		result.FormatError = fmt.Sprintf("Failed to open publisher metadata: %v", err)
+		return result
+	}
+	defer s.api.Close(uint64(publisher))
+	if _, err := s.api.FormatMessage(publisher, event, EvtFormatMessageEvent); err != nil {
+		result.FormatError = err.Error()
+	}
+	return result
+}
+
+// lazyProc is a DLL function found when it's first called, such as a
+// *windows.LazyProc. Calling one which can't be found panics.
+type lazyProc interface {
+	Find() error
+}
+
+// findProcs loads a DLL and finds each of its procs, returning the first
+// failure, so a missing DLL or function is reported instead of panicking
+// when it's called.
+func findProcs(load func() error, procs []lazyProc) error {
+	if err := load(); err != nil {
+		return err
+	}
+	for _, proc := range procs {
+		if err := proc.Find(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newestEvent reads the newest event on `channel` with a reverse query.
+func newestEvent(channel string) (EventHandle, error) {
+	result, err := QueryChannelReverse(channel, "*")
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+	return result.Next(selfTestTimeout)
+}
@@ -0,0 +1,77 @@
+package winlog
+
+import (
+	"errors"
+	"io"
+	. "testing"
+)
+
+func testSelfTest(api *fakeAPI, newest func(string) (EventHandle, error)) selfTest {
+	return selfTest{
+		loadAPI:      func() error { return nil },
+		listChannels: func() ([]string, error) { return []string{"Application", "System"}, nil },
+		api:          api,
+		newest:       newest,
+	}
+}
+
+func TestSelfTestRendersNewestEvent(t *T) {
+	api := newFakeAPI()
+	api.events[100] = fakeValues{EvtSystemProviderName: "Test", EvtSystemEventRecordId: uint64(42)}
+	report := testSelfTest(api, func(string) (EventHandle, error) { return 100, nil }).run([]string{"application"})
+	assertEqual(report.Healthy, true, t)
+	assertEqual(report.APIAvailable, true, t)
+	assertEqual(report.RegisteredChannels, 2, t)
+	result := report.Channels["application"]
+	assertEqual(result.Registered, true, t)
+	assertEqual(result.Readable, true, t)
+	assertEqual(result.NewestRecordId, uint64(42), t)
+	assertEqual(result.ProviderName, "Test", t)
+	assertEqual(result.FormatError, "", t)
+	assertEqual(api.closed[100], true, t)
+}
+
+func TestSelfTestEmptyChannel(t *T) {
+	report := testSelfTest(newFakeAPI(), func(string) (EventHandle, error) { return 0, io.EOF }).run([]string{"System"})
+	assertEqual(report.Healthy, true, t)
+	assertEqual(report.Channels["System"].Readable, true, t)
+	assertEqual(report.Channels["System"].NewestRecordId, uint64(0), t)
+}
+
+func TestSelfTestReportsFailures(t *T) {
+	s := testSelfTest(newFakeAPI(), func(string) (EventHandle, error) { return 0, errors.New("Access is denied.") })
+	s.loadAPI = func() error { return ErrUnsupportedPlatform }
+	report := s.run([]string{"Security", "Missing"})
+	assertEqual(report.Healthy, false, t)
+	assertEqual(report.APIAvailable, false, t)
+	assertEqual(report.APIError, ErrUnsupportedPlatform.Error(), t)
+	assertEqual(report.Channels["Missing"].Registered, false, t)
+	assertEqual(report.Channels["Security"].Readable, false, t)
+	assertEqual(report.Channels["Security"].ReadError, "Failed to read newest event: Access is denied.", t)
+}
+
+// fakeProc is a lazyProc which fails to be found with err
+type fakeProc struct {
+	err error
+}
+
+func (p fakeProc) Find() error {
+	return p.err
+}
+
+func TestFindProcs(t *T) {
+	missing := errors.New("Failed to find EvtSubscribe procedure in wevtapi.dll: The specified procedure could not be found.")
+	procs := []lazyProc{fakeProc{}, fakeProc{missing}, fakeProc{}}
+	assertEqual(findProcs(func() error { return nil }, procs), missing, t)
+	assertEqual(findProcs(func() error { return nil }, procs[:1]), nil, t)
+	notLoaded := errors.New("Failed to load wevtapi.dll: The specified module could not be found.")
+	assertEqual(findProcs(func() error { return notLoaded }, procs), notLoaded, t)
+
+	// CheckHealth reports the failure rather than panicking
+	s := testSelfTest(newFakeAPI(), func(string) (EventHandle, error) { return 0, io.EOF })
+	s.loadAPI = func() error { return findProcs(func() error { return nil }, procs) }
+	report := s.run(nil)
+	assertEqual(report.Healthy, false, t)
+	assertEqual(report.APIAvailable, false, t)
+	assertEqual(report.APIError, missing.Error(), t)
+}
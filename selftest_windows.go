@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+// loadWevtapi checks wevtapi.dll can be loaded from the system directory,
+// and has every function the package calls.
+func loadWevtapi() error {
+	return findProcs(wevtapi.Load, wevtapiProcs)
+}
@@ -0,0 +1,123 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is a provider-independent scale of how serious an event is,
+// ordered from SeverityDebug to SeverityCritical so events from mixed
+// providers sort and alert consistently. It's written as its name in JSON.
+type Severity int
+
+const (
+	// The event hasn't been assigned a severity
+	SeverityUnknown Severity = iota
+	SeverityDebug
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityCritical
+)
+
+var severityNames = []string{"", "debug", "info", "warn", "error", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses the name of a Severity, such as "warn".
+func ParseSeverity(name string) (Severity, error) {
+	for i, severityName := range severityNames {
+		if severityName != "" && strings.EqualFold(name, severityName) {
+			return Severity(i), nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("Unknown severity %q, expected debug, info, warn, error or critical", name)
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*s = SeverityUnknown
+		return nil
+	}
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// DefaultSeverity maps the standard levels to a Severity: Critical, Error
+// and Warning to their namesakes, Information and LogAlways to info, and
+// Verbose and providers' custom levels to debug. Failed audits are raised
+// to at least warn, since the Security log logs them as Information.
+func DefaultSeverity(level, keywords uint64) Severity {
+	severity := SeverityDebug
+	switch level {
+	case LevelCritical:
+		severity = SeverityCritical
+	case LevelError:
+		severity = SeverityError
+	case LevelWarning:
+		severity = SeverityWarn
+	case LevelInformation, LevelLogAlways:
+		severity = SeverityInfo
+	}
+	if keywords&KeywordAuditFailure != 0 && severity < SeverityWarn {
+		severity = SeverityWarn
+	}
+	return severity
+}
+
+// SeverityRule assigns a Severity to the events it matches. Empty lists
+// match any event, and Keywords matches events with any of its bits set.
+type SeverityRule struct {
+	// Matched case-insensitively
+	Providers []string `json:"providers,omitempty"`
+	EventIds  []uint64 `json:"eventIds,omitempty"`
+	Levels    []uint64 `json:"levels,omitempty"`
+	Keywords  uint64   `json:"keywords,omitempty"`
+	Severity  Severity `json:"severity"`
+}
+
+func (rule *SeverityRule) matches(ev *WinLogEvent) bool {
+	return (len(rule.Providers) == 0 || containsFold(rule.Providers, ev.ProviderName)) &&
+		(len(rule.EventIds) == 0 || containsUint(rule.EventIds, ev.EventId)) &&
+		(len(rule.Levels) == 0 || containsUint(rule.Levels, ev.Level)) &&
+		(rule.Keywords == 0 || ev.KeywordsMask&rule.Keywords != 0)
+}
+
+// SeverityMap assigns the Severity of events whose providers use levels and
+// keywords in their own way, such as one logging routine failures as
+// errors. The first rule matching an event gives its severity, and events
+// no rule matches get their DefaultSeverity. It can be loaded from JSON:
+//
+//	{"rules": [
+//		{"providers": ["Microsoft-Windows-DNS-Client"], "levels": [2, 3], "severity": "debug"},
+//		{"eventIds": [1102, 4719], "severity": "critical"}
+//	]}
+type SeverityMap struct {
+	Rules []SeverityRule `json:"rules"`
+}
+
+// Severity returns the severity of the event by the map's rules. A nil map
+// returns the event's DefaultSeverity.
+func (m *SeverityMap) Severity(ev *WinLogEvent) Severity {
+	if m != nil {
+		for i := range m.Rules {
+			if m.Rules[i].matches(ev) {
+				return m.Rules[i].Severity
+			}
+		}
+	}
+	return DefaultSeverity(ev.Level, ev.KeywordsMask)
+}
@@ -0,0 +1,66 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+)
+
+func TestDefaultSeverity(t *T) {
+	assertEqual(DefaultSeverity(LevelCritical, 0), SeverityCritical, t)
+	assertEqual(DefaultSeverity(LevelError, 0), SeverityError, t)
+	assertEqual(DefaultSeverity(LevelWarning, 0), SeverityWarn, t)
+	assertEqual(DefaultSeverity(LevelInformation, 0), SeverityInfo, t)
+	assertEqual(DefaultSeverity(LevelLogAlways, 0), SeverityInfo, t)
+	assertEqual(DefaultSeverity(LevelVerbose, 0), SeverityDebug, t)
+	assertEqual(DefaultSeverity(16, 0), SeverityDebug, t)
+	assertEqual(DefaultSeverity(LevelLogAlways, KeywordAuditFailure), SeverityWarn, t)
+	assertEqual(DefaultSeverity(LevelLogAlways, KeywordAuditSuccess), SeverityInfo, t)
+	assertEqual(DefaultSeverity(LevelError, KeywordAuditFailure), SeverityError, t)
+}
+
+func TestSeverityMap(t *T) {
+	var m SeverityMap
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"providers": ["microsoft-windows-dns-client"], "levels": [2, 3], "severity": "debug"},
+		{"eventIds": [1102], "severity": "critical"},
+		{"keywords": 4, "severity": "Warn"}
+	]}`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m.Severity(&WinLogEvent{ProviderName: "Microsoft-Windows-DNS-Client", Level: LevelError}), SeverityDebug, t)
+	assertEqual(m.Severity(&WinLogEvent{ProviderName: "Microsoft-Windows-DNS-Client", Level: LevelCritical}), SeverityCritical, t)
+	assertEqual(m.Severity(&WinLogEvent{EventId: 1102, Level: LevelInformation}), SeverityCritical, t)
+	assertEqual(m.Severity(&WinLogEvent{KeywordsMask: 0x6, Level: LevelVerbose}), SeverityWarn, t)
+	assertEqual(m.Severity(&WinLogEvent{Level: LevelVerbose}), SeverityDebug, t)
+	var none *SeverityMap
+	assertEqual(none.Severity(&WinLogEvent{Level: LevelWarning}), SeverityWarn, t)
+
+	if err := json.Unmarshal([]byte(`{"rules": [{"severity": "loud"}]}`), &m); err == nil {
+		t.Error("Expected an error for an unknown severity")
+	}
+	data, err := json.Marshal(SeverityRule{Severity: SeverityError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(string(data), `{"severity":"error"}`, t)
+}
+
+func TestSeverityFilter(t *T) {
+	ev := &WinLogEvent{Severity: SeverityWarn}
+	for expr, expected := range map[string]bool{
+		`Severity == "warn"`: true,
+		`Severity >= 3`:      true,
+		`Severity > 3`:       false,
+		`Severity == "info"`: false,
+	} {
+		filter, err := CompileFilter(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter.Match(ev) != expected {
+			t.Errorf("Expected %q to be %v", expr, expected)
+		}
+	}
+	assertEqual(ev.CreateMap()["Severity"], "warn", t)
+}
@@ -0,0 +1,328 @@
+package winlog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SimpleQuery is a filter written as space-separated key=value terms,
+// compiled to event log XPath, for users who don't know its dialect:
+//
+//	provider=Microsoft-Windows-Security-Auditing id=4624,4625 level<=3 last=24h
+//
+// Terms are combined with "and", and comma-separated values with "or".
+// Values containing spaces are double-quoted. The keys are:
+//
+//	channel   channels to read, for StructuredQuery
+//	provider  provider names
+//	id        event IDs or ranges, such as 4624-4634; also <, <=, > and >=
+//	level     levels by number or name (critical, error, warning,
+//	          information, verbose); also <, <=, > and >=
+//	task      task numbers; also <, <=, > and >=
+//	opcode    opcode numbers; also <, <=, > and >=
+//	computer  computer names
+//	user      SIDs of the users events were logged for
+//	last      events created within a duration, such as 90m, 24h or 7d
+//	since     events created at or after an RFC 3339 time
+//	until     events created before an RFC 3339 time
+//
+// Each key but channel, last, since and until also takes != to exclude
+// values. Information events are matched by Level 4 or 0, which classic
+// providers log them with, so level<=3 doesn't match them.
+type SimpleQuery struct {
+	// Channels named by the channel key, if any
+	Channels []string
+	// The XPath query selecting the events, "*" if there are no terms
+	XPath string
+}
+
+// Level names accepted by SimpleQuery
+var simpleQueryLevels = map[string]uint64{
+	"critical":    1,
+	"error":       2,
+	"warning":     3,
+	"information": 4,
+	"info":        4,
+	"verbose":     5,
+}
+
+// ParseSimpleQuery compiles a SimpleQuery.
+func ParseSimpleQuery(s string) (*SimpleQuery, error) {
+	terms, err := splitSimpleQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	q := &SimpleQuery{}
+	var clauses []string
+	for _, term := range terms {
+		key, op, value, err := parseSimpleTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		var clause string
+		switch key {
+		case "channel":
+			if op != "=" {
+				return nil, fmt.Errorf("channel only takes =, in %q", term)
+			}
+			q.Channels = append(q.Channels, splitValues(value)...)
+			continue
+		case "provider":
+			clause, err = stringClause(op, value, func(v string) string { return "Provider[@Name=" + xpathLiteral(v) + "]" })
+		case "computer":
+			clause, err = stringClause(op, value, func(v string) string { return "Computer=" + xpathLiteral(v) })
+		case "user":
+			clause, err = stringClause(op, value, func(v string) string { return "Security[@UserID=" + xpathLiteral(v) + "]" })
+		case "id":
+			clause, err = numberClause("EventID", op, value)
+		case "task":
+			clause, err = numberClause("Task", op, value)
+		case "opcode":
+			clause, err = numberClause("Opcode", op, value)
+		case "level":
+			clause, err = levelClause(op, value)
+		case "last", "since", "until":
+			clause, err = timeClause(key, op, value)
+		default:
+			return nil, fmt.Errorf("Unknown key %q in %q", key, term)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid term %q: %v", term, err)
+		}
+		if clause != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+	q.XPath = "*"
+	if len(clauses) > 0 {
+		q.XPath = "*[System[" + strings.Join(clauses, " and ") + "]]"
+	}
+	return q, nil
+}
+
+// StructuredQuery returns a structured XML query selecting the events from
+// each of the query's Channels, or of `channels` if it names none.
+func (q *SimpleQuery) StructuredQuery(channels ...string) string {
+	if len(q.Channels) > 0 {
+		channels = q.Channels
+	}
+	var b strings.Builder
+	b.WriteString(`<QueryList><Query Id="0">`)
+	for _, channel := range channels {
+		fmt.Fprintf(&b, `<Select Path="%v">%v</Select>`, escapeXMLText(channel), escapeXMLText(q.XPath))
+	}
+	b.WriteString(`</Query></QueryList>`)
+	return b.String()
+}
+
+// splitSimpleQuery splits a query into terms at spaces outside double quotes,
+// removing the quotes.
+func splitSimpleQuery(s string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted, inTerm := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inTerm = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inTerm {
+				terms = append(terms, term.String())
+				term.Reset()
+				inTerm = false
+			}
+		default:
+			term.WriteRune(r)
+			inTerm = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("Unterminated quote in %q", s)
+	}
+	if inTerm {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// parseSimpleTerm splits a term into its key, operator and value.
+func parseSimpleTerm(term string) (key, op, value string, err error) {
+	i := strings.IndexAny(term, "=!<>")
+	if i <= 0 {
+		return "", "", "", fmt.Errorf("Invalid term %q, expected key=value", term)
+	}
+	key = strings.ToLower(term[:i])
+	for _, candidate := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if strings.HasPrefix(term[i:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return "", "", "", fmt.Errorf("Invalid operator in %q", term)
+	}
+	value = term[i+len(op):]
+	if value == "" {
+		return "", "", "", fmt.Errorf("Missing value in %q", term)
+	}
+	return key, op, value, nil
+}
+
+func splitValues(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// anyOf joins expressions with "or", parenthesised, negated if `op` is !=.
+func anyOf(op string, exprs []string) string {
+	clause := "(" + strings.Join(exprs, " or ") + ")"
+	if op == "!=" {
+		return "not" + clause
+	}
+	return clause
+}
+
+func stringClause(op, value string, expr func(string) string) (string, error) {
+	if op != "=" && op != "!=" {
+		return "", fmt.Errorf("Only = and != compare names")
+	}
+	var exprs []string
+	for _, v := range splitValues(value) {
+		exprs = append(exprs, expr(v))
+	}
+	return anyOf(op, exprs), nil
+}
+
+func numberClause(field, op, value string) (string, error) {
+	if op != "=" && op != "!=" {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v%v%d", field, op, n), nil
+	}
+	var exprs []string
+	for _, v := range splitValues(value) {
+		if dash := strings.Index(v, "-"); dash > 0 {
+			low, err := strconv.ParseUint(v[:dash], 10, 64)
+			if err != nil {
+				return "", err
+			}
+			high, err := strconv.ParseUint(v[dash+1:], 10, 64)
+			if err != nil {
+				return "", err
+			}
+			if low > high {
+				return "", fmt.Errorf("Range %v is backwards", v)
+			}
+			exprs = append(exprs, fmt.Sprintf("(%v>=%d and %v<=%d)", field, low, field, high))
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, fmt.Sprintf("%v=%d", field, n))
+	}
+	return anyOf(op, exprs), nil
+}
+
+// levelClause selects levels by listing them, so Information matches the
+// Level 0 classic providers log it with, and comparisons don't match
+// Level 0 as the most severe.
+func levelClause(op, value string) (string, error) {
+	parseLevel := func(v string) (uint64, error) {
+		if level, ok := simpleQueryLevels[strings.ToLower(v)]; ok {
+			return level, nil
+		}
+		return strconv.ParseUint(v, 10, 64)
+	}
+	selected := make(map[uint64]bool)
+	if op == "=" || op == "!=" {
+		for _, v := range splitValues(value) {
+			level, err := parseLevel(v)
+			if err != nil {
+				return "", err
+			}
+			selected[level] = true
+		}
+	} else {
+		bound, err := parseLevel(value)
+		if err != nil {
+			return "", err
+		}
+		for level := uint64(1); level <= 5; level++ {
+			if (op == "<" && level < bound) || (op == "<=" && level <= bound) ||
+				(op == ">" && level > bound) || (op == ">=" && level >= bound) {
+				selected[level] = true
+			}
+		}
+	}
+	if selected[4] {
+		selected[0] = true
+	}
+	if len(selected) == 0 {
+		return "", fmt.Errorf("No levels match")
+	}
+	levels := make([]uint64, 0, len(selected))
+	for level := range selected {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	exprs := make([]string, len(levels))
+	for i, level := range levels {
+		exprs[i] = fmt.Sprintf("Level=%d", level)
+	}
+	return anyOf(op, exprs), nil
+}
+
+func timeClause(key, op, value string) (string, error) {
+	if op != "=" {
+		return "", fmt.Errorf("%v only takes =", key)
+	}
+	if key == "last" {
+		d, err := parseQueryDuration(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("TimeCreated[timediff(@SystemTime) <= %d]", d.Milliseconds()), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", err
+	}
+	compare := ">="
+	if key == "until" {
+		compare = "<"
+	}
+	return fmt.Sprintf("TimeCreated[@SystemTime%v'%v']", compare, t.UTC().Format(systemTimeFormat)), nil
+}
+
+// parseQueryDuration parses a duration as time.ParseDuration does, also
+// accepting whole days such as "7d".
+func parseQueryDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(value, "d"), 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("Duration must be positive")
+	}
+	return d, nil
+}
@@ -0,0 +1,34 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestParseSimpleQuery(t *T) {
+	q, err := ParseSimpleQuery("provider=Microsoft-Windows-Security-Auditing id=4624,4625 level<=3 last=24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(q.XPath, "*[System[(Provider[@Name='Microsoft-Windows-Security-Auditing']) and (EventID=4624 or EventID=4625) and (Level=1 or Level=2 or Level=3) and TimeCreated[timediff(@SystemTime) <= 86400000]]]", t)
+	assertEqual(len(q.Channels), 0, t)
+
+	q, err = ParseSimpleQuery(`channel=Security,System level=information id!=4600-4699 computer="host's pc" since=2021-01-01T02:00:00+02:00`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(q.XPath, `*[System[(Level=0 or Level=4) and not((EventID>=4600 and EventID<=4699)) and (Computer="host's pc") and TimeCreated[@SystemTime>='2021-01-01T00:00:00.0000000Z']]]`, t)
+	assertEqual(len(q.Channels), 2, t)
+	assertEqual(q.StructuredQuery(), `<QueryList><Query Id="0"><Select Path="Security">*[System[(Level=0 or Level=4) and not((EventID&gt;=4600 and EventID&lt;=4699)) and (Computer=&#34;host&#39;s pc&#34;) and TimeCreated[@SystemTime&gt;=&#39;2021-01-01T00:00:00.0000000Z&#39;]]]</Select><Select Path="System">*[System[(Level=0 or Level=4) and not((EventID&gt;=4600 and EventID&lt;=4699)) and (Computer=&#34;host&#39;s pc&#34;) and TimeCreated[@SystemTime&gt;=&#39;2021-01-01T00:00:00.0000000Z&#39;]]]</Select></Query></QueryList>`, t)
+
+	q, err = ParseSimpleQuery("  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(q.XPath, "*", t)
+
+	for _, invalid := range []string{"id", "colour=red", "id=abc", "level<=loud", "last=-1h", "provider<x", `computer="a`, "id=9-1", "level>5"} {
+		if _, err := ParseSimpleQuery(invalid); err == nil {
+			t.Errorf("Expected an error parsing %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A Sink forwards delivered events to another process or service.
+type Sink interface {
+	Write(ev *WinLogEvent) error
+	Close() error
+}
+
+// Largest frame ReadFrame accepts
+const maxFrameSize = 64 << 20
+
+// marshalFrame encodes the event as CanonicalJSON on a single line, prefixed
+// with its length as a little-endian uint32.
+func marshalFrame(ev *WinLogEvent) ([]byte, error) {
+	data, err := CanonicalJSON(ev)
+	if err != nil {
+		return nil, err
+	}
+	var frame bytes.Buffer
+	frame.Write(make([]byte, 4))
+	if err := json.Compact(&frame, data); err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint32(frame.Bytes(), uint32(frame.Len()-4))
+	return frame.Bytes(), nil
+}
+
+// ReadFrame reads one length-prefixed JSON event written by a sink such as
+// PipeSink. It returns io.EOF if the stream ends between frames.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("Frame of %v bytes is too large", n)
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
@@ -0,0 +1,39 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	. "testing"
+)
+
+func TestFrames(t *T) {
+	var stream bytes.Buffer
+	for id := uint64(1); id <= 2; id++ {
+		frame, err := marshalFrame(&WinLogEvent{EventId: id, Msg: "line one\r\nline two"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Write(frame)
+	}
+	for id := uint64(1); id <= 2; id++ {
+		frame, err := ReadFrame(&stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.IndexByte(frame, '\n') >= 0 {
+			t.Fatalf("Frame isn't a single line: %q", frame)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(frame, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(decoded["EventId"], float64(id), t)
+	}
+	if _, err := ReadFrame(&stream); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{10, 0, 0, 0, '{'})); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
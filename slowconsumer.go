@@ -0,0 +1,100 @@
+package winlog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SlowConsumer describes a consumer which has stopped reading the Event
+// channel while events wait for it. It's published on the Error channel,
+// logged, and reported in Health until the consumer catches up.
+type SlowConsumer struct {
+	// Events received from the event log but not yet delivered, in total
+	// and by subscription
+	Depth  int64
+	Depths map[string]int64
+	// How long the event waiting to be sent has waited since it was
+	// received, and since the consumer last read an event
+	OldestAge     time.Duration
+	SinceLastRead time.Duration
+	Detected      time.Time
+}
+
+func (s *SlowConsumer) Error() string {
+	return fmt.Sprintf("Slow consumer: %v events waiting, oldest for %v, no event read for %v", s.Depth, s.OldestAge.Round(time.Millisecond), s.SinceLastRead.Round(time.Millisecond))
+}
+
+func (self *WinLogWatcher) startSlowConsumerDetection() {
+	if self.SlowConsumerThreshold <= 0 {
+		return
+	}
+	self.slowOnce.Do(func() {
+		atomic.CompareAndSwapInt64(&self.lastRead, 0, time.Now().UnixNano())
+		go self.detectSlowConsumer()
+	})
+}
+
+// detectSlowConsumer checks whether the consumer has stalled twice every
+// SlowConsumerThreshold until shutdown.
+func (self *WinLogWatcher) detectSlowConsumer() {
+	ticker := time.NewTicker(self.SlowConsumerThreshold / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-self.shutdown:
+			return
+		}
+		self.checkSlowConsumer(time.Now())
+	}
+}
+
+func (self *WinLogWatcher) checkSlowConsumer(now time.Time) {
+	report := &SlowConsumer{Depths: make(map[string]int64), Detected: now}
+	self.watchMutex.Lock()
+	for channel, watch := range self.watches {
+		depth := atomic.LoadInt64(&watch.stats.backlog)
+		report.Depths[channel] = depth
+		report.Depth += depth
+	}
+	self.watchMutex.Unlock()
+	report.SinceLastRead = now.Sub(time.Unix(0, atomic.LoadInt64(&self.lastRead)))
+	if pending := atomic.LoadInt64(&self.pendingSince); pending != 0 {
+		report.OldestAge = now.Sub(time.Unix(0, pending))
+	}
+
+	// The consumer is only stalled if it has been offered an event, and
+	// hasn't taken it within the threshold
+	watermark := self.SlowConsumerWatermark
+	if watermark < 1 {
+		watermark = 1
+	}
+	offered := atomic.LoadInt64(&self.offeredAt)
+	slow := offered != 0 && now.Sub(time.Unix(0, offered)) > self.SlowConsumerThreshold &&
+		report.Depth >= watermark
+
+	self.slowMutex.Lock()
+	previous := self.slowConsumer
+	if slow && previous == nil {
+		self.slowConsumer = report
+	} else if !slow {
+		self.slowConsumer = nil
+	}
+	self.slowMutex.Unlock()
+
+	if slow && previous == nil {
+		self.log(LogWarn, "Slow consumer", "depth", report.Depth, "depths", report.Depths, "oldestAge", report.OldestAge, "sinceLastRead", report.SinceLastRead)
+		go self.PublishError(report)
+	} else if !slow && previous != nil {
+		self.log(LogInfo, "Consumer caught up", "stalledFor", now.Sub(previous.Detected))
+	}
+}
+
+// currentSlowConsumer returns the report of the consumer's current stall,
+// or nil if it's keeping up.
+func (self *WinLogWatcher) currentSlowConsumer() *SlowConsumer {
+	self.slowMutex.Lock()
+	defer self.slowMutex.Unlock()
+	return self.slowConsumer
+}
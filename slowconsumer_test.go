@@ -0,0 +1,52 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestSlowConsumer(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, SlowConsumerThreshold: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is waiting, so an idle consumer isn't slow
+	watcher.checkSlowConsumer(time.Now().Add(time.Hour))
+	assertEqual(watcher.Health().SlowConsumer == nil, true, t)
+
+	go api.emit("Application", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Application",
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case err := <-watcher.Error():
+		slow, ok := err.(*SlowConsumer)
+		if !ok {
+			t.Fatal(err)
+		}
+		assertEqual(slow.Depth, int64(1), t)
+		assertEqual(slow.Depths["Application"], int64(1), t)
+		assertEqual(slow.OldestAge >= 20*time.Millisecond, true, t)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for slow consumer warning")
+	}
+	health := watcher.Health()
+	assertEqual(health.Healthy, false, t)
+	assertEqual(health.SlowConsumer != nil, true, t)
+
+	// Caught up once the event is taken and nothing else is waiting
+	<-watcher.Event()
+	deadline := time.Now().Add(5 * time.Second)
+	for watcher.checkSlowConsumer(time.Now()); watcher.Health().SlowConsumer != nil; watcher.checkSlowConsumer(time.Now()) {
+		if time.Now().After(deadline) {
+			t.Fatal("Slow consumer wasn't cleared")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,230 @@
+package winlog
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// splitBookmark tracks one of the subscriptions a query is split across.
+// Each subscription publishes its own records in order, but the
+// subscriptions interleave, so a bookmark updated by all of them could move
+// past records one hasn't published yet. Instead each has its own bookmark,
+// and events are published with the bookmark furthest behind: resuming from
+// it may read some records again, but skips none. A subscription whose
+// query matches nothing for a while would hold that bookmark back, so if
+// the watcher's BookmarkLagInterval is set, idle subscriptions are moved up
+// to the channel's newest record of the interval before.
+type splitBookmark struct {
+	bookmark BookmarkHandle
+	// RecordId of the last event the subscription published, or the one it
+	// started after
+	recordId   uint64
+	positioned bool
+	// Events the subscription has read but not yet updated its bookmark
+	// with. Updated atomically.
+	inFlight int64
+}
+
+// splitCallback publishes the events of one of a split query's
+// subscriptions, so the watcher knows which bookmark they advance.
+type splitCallback struct {
+	watcher *WinLogWatcher
+	split   int
+}
+
+func (c splitCallback) PublishError(err error) {
+	c.watcher.PublishError(err)
+}
+
+func (c splitCallback) PublishEvent(handle EventHandle, channel string) {
+	c.watcher.publishEvent(handle, channel, c.split)
+}
+
+// newSplitBookmarks creates a bookmark for each subscription `query` is
+// split into, starting where the subscriptions start: after `startXml` when
+// starting after a bookmark, after the channel's newest record for future
+// events, or before its oldest otherwise, so there's always a bookmark to
+// publish events with. They only have no position if the channel's records
+// can't be read, until they publish an event. Returns nil if the query
+// isn't split.
+func (self *WinLogWatcher) newSplitBookmarks(channel, query string, flags EVT_SUBSCRIBE_FLAGS, startXml string) ([]splitBookmark, error) {
+	queries := splitQuery(query)
+	if len(queries) == 1 {
+		return nil, nil
+	}
+	var recordId uint64
+	positioned := false
+	switch flags {
+	case EvtSubscribeStartAfterBookmark:
+		recordId, positioned = bookmarkPosition(startXml)
+	case EvtSubscribeToFutureEvents:
+		if info, err := self.api.ChannelInfo(channel); err == nil && info.NewestRecordId > 0 {
+			recordId, positioned = info.NewestRecordId, true
+			startXml = channelBookmark(channel, recordId)
+		}
+	case EvtSubscribeStartAtOldestRecord:
+		// Subscriptions made with SubscribeFromTime resume with the same
+		// events suppressed, so they can start here too
+		if info, err := self.api.ChannelInfo(channel); err == nil {
+			if info.OldestRecordId > 0 {
+				recordId = info.OldestRecordId - 1
+			}
+			positioned = true
+			startXml = channelBookmark(channel, recordId)
+		}
+	}
+	if !positioned {
+		startXml = ""
+	}
+	splits := make([]splitBookmark, 0, len(queries))
+	for range queries {
+		bookmark, err := self.api.CreateBookmark(startXml)
+		if err != nil {
+			closeSplitBookmarks(self.api, splits)
+			return nil, fmt.Errorf("Failed to create new bookmark handle: %v", err)
+		}
+		splits = append(splits, splitBookmark{bookmark: bookmark, recordId: recordId, positioned: positioned})
+	}
+	return splits, nil
+}
+
+func closeSplitBookmarks(api EventLogAPI, splits []splitBookmark) {
+	for _, split := range splits {
+		api.Close(uint64(split.bookmark))
+	}
+}
+
+// advanceBookmark updates the bookmark of a subscription, or of one of the
+// subscriptions of a split query, with an event. Must be called with
+// bookmarkMutex held.
+func (self *WinLogWatcher) advanceBookmark(watch *channelWatcher, split int, handle EventHandle, recordId uint64) error {
+	if watch.splits == nil {
+		watch.bookmarked = true
+		return self.api.UpdateBookmark(watch.bookmark, handle)
+	}
+	if split < 0 || split >= len(watch.splits) {
+		return fmt.Errorf("No bookmark for subscription %v of %v", split, len(watch.splits))
+	}
+	if err := self.api.UpdateBookmark(watch.splits[split].bookmark, handle); err != nil {
+		return err
+	}
+	watch.splits[split].recordId = recordId
+	watch.splits[split].positioned = true
+	watch.bookmarked = true
+	for _, s := range watch.splits {
+		watch.bookmarked = watch.bookmarked && s.positioned
+	}
+	return nil
+}
+
+// readSplit counts an admitted event of one of a split query's
+// subscriptions as in flight until settleSplit is called for it.
+func readSplit(watch *channelWatcher, split int) {
+	if split >= 0 && split < len(watch.splits) {
+		atomic.AddInt64(&watch.splits[split].inFlight, 1)
+	}
+}
+
+// settleSplit stops counting an event as in flight once its subscription's
+// bookmark has been updated with it, or it has been discarded.
+func settleSplit(watch *channelWatcher, split int) {
+	if split >= 0 && split < len(watch.splits) {
+		atomic.AddInt64(&watch.splits[split].inFlight, -1)
+	}
+}
+
+// advanceIdleSplits moves the bookmarks of a split query's subscriptions up
+// to the newest record the channel had when this was last called, `head`
+// being its newest record now. A subscription with no events in flight
+// which is still behind that record is assumed to have read every record
+// its query matches up to it, since they were written at least an interval
+// ago. Without this a subscription whose query matches nothing would pin
+// the bookmark events are published with where it started.
+func (self *WinLogWatcher) advanceIdleSplits(channel string, watch *channelWatcher, head uint64) error {
+	watch.bookmarkMutex.Lock()
+	defer watch.bookmarkMutex.Unlock()
+	previous := watch.splitHead
+	watch.splitHead = head
+	// The log may have been cleared since
+	if previous == 0 || head < previous {
+		return nil
+	}
+	for i := range watch.splits {
+		split := &watch.splits[i]
+		if (split.positioned && split.recordId >= previous) || atomic.LoadInt64(&split.inFlight) > 0 {
+			continue
+		}
+		bookmark, err := self.api.CreateBookmark(channelBookmark(channel, previous))
+		if err != nil {
+			return fmt.Errorf("Failed to create new bookmark handle: %v", err)
+		}
+		self.api.Close(uint64(split.bookmark))
+		split.bookmark, split.recordId, split.positioned = bookmark, previous, true
+	}
+	watch.bookmarked = true
+	for _, s := range watch.splits {
+		watch.bookmarked = watch.bookmarked && s.positioned
+	}
+	return nil
+}
+
+// splitLag returns the records between the positions of the subscriptions
+// of a split query furthest behind and furthest ahead.
+func splitLag(watch *channelWatcher) uint64 {
+	if len(watch.splits) == 0 {
+		return 0
+	}
+	watch.bookmarkMutex.Lock()
+	defer watch.bookmarkMutex.Unlock()
+	earliest, latest := watch.splits[0].recordId, watch.splits[0].recordId
+	for _, split := range watch.splits {
+		if split.recordId < earliest {
+			earliest = split.recordId
+		}
+		if split.recordId > latest {
+			latest = split.recordId
+		}
+	}
+	return latest - earliest
+}
+
+// renderBookmark renders the bookmark events of a subscription are
+// published with: for a split query, that of the subscription furthest
+// behind, or "" while any of them has no position. Must be called with
+// bookmarkMutex held.
+func (self *WinLogWatcher) renderBookmark(watch *channelWatcher) (string, error) {
+	if watch.splits == nil {
+		return self.api.RenderBookmark(watch.bookmark)
+	}
+	if !watch.bookmarked {
+		return "", nil
+	}
+	earliest := 0
+	for i, split := range watch.splits {
+		if split.recordId < watch.splits[earliest].recordId {
+			earliest = i
+		}
+	}
+	return self.api.RenderBookmark(watch.splits[earliest].bookmark)
+}
+
+// resumeBookmark prepares a subscription's bookmark to resubscribe from,
+// returning whether it has one. For a split query it's replaced with one at
+// the position of the subscription furthest behind. Must be called with
+// bookmarkMutex held.
+func (self *WinLogWatcher) resumeBookmark(watch *channelWatcher) (bool, error) {
+	if watch.splits == nil || !watch.bookmarked {
+		return watch.bookmarked, nil
+	}
+	xml, err := self.renderBookmark(watch)
+	if err != nil {
+		return false, err
+	}
+	bookmark, err := self.api.CreateBookmark(xml)
+	if err != nil {
+		return false, err
+	}
+	self.api.Close(uint64(watch.bookmark))
+	watch.bookmark = bookmark
+	return true, nil
+}
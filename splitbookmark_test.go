@@ -0,0 +1,130 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	. "testing"
+)
+
+// splitQueryOf returns a query which is split across two subscriptions
+func splitQueryOf() string {
+	ids := make([]string, 30)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("EventID=%v", i+1)
+	}
+	return "*[System[(" + strings.Join(ids, " or ") + ")]]"
+}
+
+// publishTo emits a record to one subscription and returns the bookmark it's
+// published with
+func publishTo(api *fakeAPI, watcher *WinLogWatcher, subscription ListenerHandle, recordId uint64) string {
+	go api.emitTo(subscription, "Application", fakeValues{EvtSystemEventRecordId: recordId})
+	return (<-watcher.Event()).Bookmark
+}
+
+func TestSplitBookmarks(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromBeginning("Application", splitQueryOf()); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions := api.subscriptions("Application")
+	assertEqual(len(subscriptions), 2, t)
+
+	// A subscription which hasn't read an event is before the oldest
+	// record, so there's a position which doesn't skip its records
+	assertEqual(publishTo(api, watcher, subscriptions[1], 5), "0", t)
+	snapshot, err := watcher.BookmarkSnapshot()
+	assertEqual(err, nil, t)
+	assertEqual(snapshot["Application"], "0", t)
+	// Events are published with the bookmark furthest behind
+	assertEqual(publishTo(api, watcher, subscriptions[0], 3), "3", t)
+	assertEqual(publishTo(api, watcher, subscriptions[1], 6), "3", t)
+	assertEqual(publishTo(api, watcher, subscriptions[0], 8), "6", t)
+	snapshot, err = watcher.BookmarkSnapshot()
+	assertEqual(err, nil, t)
+	assertEqual(snapshot["Application"], "6", t)
+
+	// Resubscribing starts from there too
+	watch := watcher.watches["Application"]
+	watch.bookmarkMutex.Lock()
+	bookmarked, err := watcher.resumeBookmark(watch)
+	watch.bookmarkMutex.Unlock()
+	assertEqual(bookmarked, true, t)
+	assertEqual(err, nil, t)
+	bookmark, _ := api.RenderBookmark(watch.bookmark)
+	assertEqual(bookmark, "6", t)
+}
+
+func TestSplitBookmarksStart(t *T) {
+	api := headAPI{fakeAPI: newFakeAPI(), info: ChannelInfo{OldestRecordId: 1, NewestRecordId: 20}}
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+
+	// Subscriptions which haven't read an event are where they started
+	if err := watcher.SubscribeFromNow("Application", splitQueryOf()); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions := api.subscriptions("Application")
+	assertEqual(publishTo(api.fakeAPI, watcher, subscriptions[1], 25), "20", t)
+	assertEqual(publishTo(api.fakeAPI, watcher, subscriptions[0], 22), "22", t)
+
+	if err := watcher.SubscribeFromBookmark("System", splitQueryOf(), channelBookmark("System", 10)); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions = api.subscriptions("System")
+	go api.emitTo(subscriptions[0], "System", fakeValues{EvtSystemEventRecordId: uint64(12)})
+	assertEqual((<-watcher.Event()).Bookmark, "10", t)
+}
+
+func TestSplitBookmarksIdle(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromBeginning("Application", splitQueryOf()); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions := api.subscriptions("Application")
+	watch := watcher.watches["Application"]
+	advance := func(head uint64) {
+		t.Helper()
+		if err := watcher.advanceIdleSplits("Application", watch, head); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second subscription matches nothing, so it pins the bookmark
+	assertEqual(publishTo(api, watcher, subscriptions[0], 3), "0", t)
+	// The first check only records the channel's head
+	advance(10)
+	assertEqual(publishTo(api, watcher, subscriptions[0], 12), "0", t)
+	assertEqual(watcher.Stats()["Application"].SplitBookmarkLag, uint64(12), t)
+	// An interval later, the idle subscription has read everything it
+	// matches up to that head
+	advance(15)
+	assertEqual(watcher.Stats()["Application"].SplitBookmarkLag, uint64(2), t)
+	assertEqual(publishTo(api, watcher, subscriptions[0], 14), "10", t)
+
+	// Subscriptions with events in flight are left where they are
+	atomic.AddInt64(&watch.splits[1].inFlight, 1)
+	advance(20)
+	assertEqual(publishTo(api, watcher, subscriptions[0], 16), "10", t)
+	atomic.AddInt64(&watch.splits[1].inFlight, -1)
+	advance(20)
+	assertEqual(publishTo(api, watcher, subscriptions[0], 21), "20", t)
+	// Publishing settles the events it counted in flight
+	for _, split := range watch.splits {
+		assertEqual(atomic.LoadInt64(&split.inFlight), int64(0), t)
+	}
+}
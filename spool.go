@@ -0,0 +1,363 @@
+package winlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Uncompressed bytes written to a spool chunk file before starting another
+const DefaultSpoolChunkSize = 8 << 20
+
+const (
+	spoolExt        = ".jsonl"
+	spoolGzipExt    = ".jsonl.gz"
+	spoolPartialExt = ".partial"
+)
+
+// compressSpooled gzips a spooled event at `level`, or returns it unchanged
+// if level is gzip.NoCompression.
+func compressSpooled(data []byte, level int) ([]byte, error) {
+	if level == gzip.NoCompression {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressSpooled reverses compressSpooled. Spooled events are JSON
+// objects, so gzipped ones are recognised by the gzip magic number.
+func decompressSpooled(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// FileSpool is a Sink which spools events to files in a directory, for
+// store-and-forward when nothing downstream can accept them, or for
+// shipping later by another process. Events are written as JSON lines to
+// chunk files of about ChunkSize uncompressed bytes, gzipped by default, as
+// rendered XML compresses around tenfold. A chunk is readable once it's
+// complete; Flush completes the current one early.
+//
+// By default Write returns once an event is buffered, before it reaches
+// disk, and MaxBytes discards the oldest chunks, so a crash or a full spool
+// loses events Write accepted. Set Durable when a nil error must mean the
+// event is stored, as it does to a Handoff, which saves the event's
+// bookmark and never delivers it again.
+//
+//	spool, err := winlog.NewFileSpool(`C:\ProgramData\agent\spool`)
+//	...
+//	chunks, err := spool.Chunks()
+//	for _, chunk := range chunks {
+//		events, err := winlog.ReadSpoolChunk(chunk)
+//		// forward events, then
+//		spool.Remove(chunk)
+//	}
+type FileSpool struct {
+	Dir string
+	// gzip level for new chunks, such as gzip.BestSpeed. gzip.NoCompression
+	// writes plain JSON lines.
+	CompressionLevel int
+	ChunkSize        int64
+	// If the completed chunks take more than this many bytes on disk, the
+	// oldest are discarded, or if Durable, Write fails with ErrSpoolFull
+	// until some are removed. Zero means no limit.
+	MaxBytes int64
+	// If set, Write flushes and syncs the chunk before returning, so an
+	// event it accepted survives a crash, and chunks are never discarded
+	Durable bool
+
+	mutex   sync.Mutex
+	file    *os.File
+	gzip    *gzip.Writer
+	buf     *bufio.Writer
+	path    string
+	written int64
+	dropped uint64
+	// Chunks opened, to keep names unique when the clock is coarse
+	opened uint64
+}
+
+// ErrSpoolFull is returned by Write when a Durable spool's chunks take
+// MaxBytes.
+var ErrSpoolFull = errors.New("Spool is full")
+
+// NewFileSpool spools to `dir`, creating it if needed. Chunks left
+// incomplete by a crash are completed, so whatever they hold can be read.
+func NewFileSpool(dir string) (*FileSpool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	partials, err := filepath.Glob(filepath.Join(dir, "*"+spoolPartialExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, partial := range partials {
+		if err := os.Rename(partial, strings.TrimSuffix(partial, spoolPartialExt)); err != nil {
+			return nil, fmt.Errorf("Failed to recover spool chunk: %v", err)
+		}
+	}
+	return &FileSpool{Dir: dir, CompressionLevel: gzip.DefaultCompression, ChunkSize: DefaultSpoolChunkSize}, nil
+}
+
+func (s *FileSpool) Write(ev *WinLogEvent) error {
+	data, err := marshalSpooledEvent(ev)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		if s.Durable && s.MaxBytes > 0 {
+			size, err := s.size()
+			if err != nil {
+				return err
+			}
+			if size >= s.MaxBytes {
+				return ErrSpoolFull
+			}
+		}
+		if err := s.openChunk(); err != nil {
+			return err
+		}
+	}
+	data = append(data, '\n')
+	if _, err := s.buf.Write(data); err != nil {
+		return fmt.Errorf("Failed to spool event: %v", err)
+	}
+	s.written += int64(len(data))
+	if s.written >= s.ChunkSize {
+		return s.completeChunk()
+	}
+	if s.Durable {
+		return s.sync()
+	}
+	return nil
+}
+
+// sync writes the events buffered in the current chunk to disk, where a
+// chunk left partial by a crash is recovered with them. Must be called
+// with the mutex held.
+func (s *FileSpool) sync() error {
+	err := s.buf.Flush()
+	if s.gzip != nil && err == nil {
+		err = s.gzip.Flush()
+	}
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to spool event: %v", err)
+	}
+	return nil
+}
+
+// Flush completes the current chunk, so the events spooled so far can be
+// read.
+func (s *FileSpool) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.completeChunk()
+}
+
+// Close completes the current chunk. The chunks are kept.
+func (s *FileSpool) Close() error {
+	return s.Flush()
+}
+
+// Dropped returns the number of chunks discarded by MaxBytes since the
+// spool was created.
+func (s *FileSpool) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// Chunks returns the paths of the completed chunks, oldest first.
+func (s *FileSpool) Chunks() ([]string, error) {
+	var chunks []string
+	for _, ext := range []string{spoolExt, spoolGzipExt} {
+		matches, err := filepath.Glob(filepath.Join(s.Dir, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, matches...)
+	}
+	// Names start with a fixed-width timestamp
+	sort.Slice(chunks, func(i, j int) bool {
+		return filepath.Base(chunks[i]) < filepath.Base(chunks[j])
+	})
+	return chunks, nil
+}
+
+// Remove deletes a chunk once its events have been forwarded.
+func (s *FileSpool) Remove(chunk string) error {
+	return os.Remove(chunk)
+}
+
+// Must be called with the mutex held
+func (s *FileSpool) openChunk() error {
+	ext := spoolGzipExt
+	if s.CompressionLevel == gzip.NoCompression {
+		ext = spoolExt
+	}
+	path := filepath.Join(s.Dir, fmt.Sprintf("%020d-%010d%v%v", time.Now().UnixNano(), s.opened, ext, spoolPartialExt))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to create spool chunk: %v", err)
+	}
+	var w io.Writer = file
+	if ext == spoolGzipExt {
+		if s.gzip, err = gzip.NewWriterLevel(file, s.CompressionLevel); err != nil {
+			file.Close()
+			os.Remove(path)
+			return err
+		}
+		w = s.gzip
+	}
+	s.file, s.path, s.buf, s.written = file, path, bufio.NewWriter(w), 0
+	s.opened++
+	return nil
+}
+
+// completeChunk closes the current chunk, if any, and makes it readable.
+// Must be called with the mutex held.
+func (s *FileSpool) completeChunk() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.buf.Flush()
+	if s.gzip != nil {
+		if closeErr := s.gzip.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if syncErr := s.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	path := s.path
+	s.file, s.gzip, s.buf, s.path = nil, nil, nil, ""
+	if err != nil {
+		return fmt.Errorf("Failed to write spool chunk: %v", err)
+	}
+	if err := os.Rename(path, strings.TrimSuffix(path, spoolPartialExt)); err != nil {
+		return err
+	}
+	return s.applyRetention()
+}
+
+// size returns the bytes the completed chunks take on disk.
+func (s *FileSpool) size() (int64, error) {
+	chunks, err := s.Chunks()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, chunk := range chunks {
+		info, err := os.Stat(chunk)
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// applyRetention discards the oldest chunks until the spool is within
+// MaxBytes, unless it's Durable. Must be called with the mutex held.
+func (s *FileSpool) applyRetention() error {
+	if s.MaxBytes <= 0 || s.Durable {
+		return nil
+	}
+	chunks, err := s.Chunks()
+	if err != nil {
+		return err
+	}
+	sizes := make([]int64, len(chunks))
+	var total int64
+	for i, chunk := range chunks {
+		info, err := os.Stat(chunk)
+		if err != nil {
+			return err
+		}
+		sizes[i] = info.Size()
+		total += sizes[i]
+	}
+	for i := 0; total > s.MaxBytes && i < len(chunks); i++ {
+		if err := os.Remove(chunks[i]); err != nil {
+			return err
+		}
+		total -= sizes[i]
+		s.dropped++
+	}
+	return nil
+}
+
+// ReadSpoolChunk reads the events from a chunk written by FileSpool. If the
+// chunk was cut short by a crash, the events before the damage are returned
+// with io.ErrUnexpectedEOF.
+func ReadSpoolChunk(path string) ([]*WinLogEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, spoolGzipExt) {
+		reader, err := gzip.NewReader(file)
+		if err == io.EOF {
+			// Created but nothing written before a crash
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		r = reader
+	}
+
+	var events []*WinLogEvent
+	lines := bufio.NewReader(r)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return events, nil
+		} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return events, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return events, err
+		}
+		ev, err := unmarshalSpooledEvent(line)
+		if err != nil {
+			return events, fmt.Errorf("Failed to read spooled event %v of %v: %v", len(events)+1, path, err)
+		}
+		events = append(events, ev)
+	}
+}
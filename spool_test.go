@@ -0,0 +1,175 @@
+package winlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	. "testing"
+)
+
+func TestSpoolCompression(t *T) {
+	data, err := marshalSpooledEvent(&WinLogEvent{Xml: []byte(strings.Repeat("<Data>value</Data>", 100))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := compressSpooled(data, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(data)/5 {
+		t.Fatalf("Expected %v bytes to compress, got %v", len(data), len(compressed))
+	}
+	for _, stored := range [][]byte{compressed, data} {
+		decompressed, err := decompressSpooled(stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(bytes.Equal(decompressed, data), true, t)
+	}
+	plain, _ := compressSpooled(data, gzip.NoCompression)
+	assertEqual(bytes.Equal(plain, data), true, t)
+}
+
+func TestFileSpool(t *T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	spool, err := NewFileSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.ChunkSize = 200
+	for id := uint64(1); id <= 5; id++ {
+		if err := spool.Write(&WinLogEvent{RecordId: id, Msg: strings.Repeat("x", 50)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spool.CompressionLevel = gzip.NoCompression
+	spool.Write(&WinLogEvent{RecordId: 6})
+	if err := spool.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := spool.Chunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("Expected several chunks, got %v", chunks)
+	}
+	assertEqual(strings.HasSuffix(chunks[0], ".jsonl.gz"), true, t)
+	assertEqual(strings.HasSuffix(chunks[len(chunks)-1], ".jsonl"), true, t)
+	var ids []uint64
+	for _, chunk := range chunks {
+		events, err := ReadSpoolChunk(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			ids = append(ids, ev.RecordId)
+		}
+		if err := spool.Remove(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(len(ids), 6, t)
+	for i, id := range ids {
+		assertEqual(id, uint64(i+1), t)
+	}
+}
+
+func TestFileSpoolRecovery(t *T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A chunk cut short by a crash
+	spool, err := NewFileSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.CompressionLevel = gzip.NoCompression
+	spool.Write(&WinLogEvent{RecordId: 1})
+	spool.Write(&WinLogEvent{RecordId: 2})
+	spool.buf.Flush()
+	spool.file.Write([]byte(`{"RecordId":`))
+	spool.file.Close()
+
+	spool, err = NewFileSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, _ := spool.Chunks()
+	assertEqual(len(chunks), 1, t)
+	events, err := ReadSpoolChunk(chunks[0])
+	assertEqual(err, io.ErrUnexpectedEOF, t)
+	assertEqual(len(events), 2, t)
+
+	// Retention
+	spool.MaxBytes = 1
+	spool.Write(&WinLogEvent{RecordId: 3})
+	spool.Flush()
+	remaining, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assertEqual(len(remaining), 0, t)
+	assertEqual(spool.Dropped(), uint64(2), t)
+}
+
+func TestFileSpoolDurable(t *T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Events Write accepted survive a crash before the chunk is complete
+	spool, err := NewFileSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.Durable = true
+	for id := uint64(1); id <= 2; id++ {
+		if err := spool.Write(&WinLogEvent{RecordId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spool.file.Close()
+
+	spool, err = NewFileSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.Durable = true
+	chunks, _ := spool.Chunks()
+	assertEqual(len(chunks), 1, t)
+	events, err := ReadSpoolChunk(chunks[0])
+	assertEqual(err, io.ErrUnexpectedEOF, t)
+	assertEqual(len(events), 2, t)
+	assertEqual(events[1].RecordId, uint64(2), t)
+
+	// A full spool refuses events rather than discarding chunks
+	spool.MaxBytes = 1
+	assertEqual(spool.Write(&WinLogEvent{RecordId: 3}), ErrSpoolFull, t)
+	remaining, _ := spool.Chunks()
+	assertEqual(len(remaining), 1, t)
+	assertEqual(spool.Dropped(), uint64(0), t)
+	if err := spool.Remove(chunks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := spool.Write(&WinLogEvent{RecordId: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := spool.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	remaining, _ = spool.Chunks()
+	assertEqual(len(remaining), 1, t)
+}
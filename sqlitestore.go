@@ -0,0 +1,302 @@
+package winlog
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Number of spooled events deleted between VACUUMs
+const sqliteVacuumInterval = 10000
+
+// SQLiteStore keeps bookmarks, and a spool of events for store-and-forward
+// while a sink is unavailable, in a SQLite database. It works with any
+// database/sql SQLite driver; gowinlog doesn't import one, so open the
+// database with the driver of your choice:
+//
+//	db, err := sql.Open("sqlite3", `C:\ProgramData\agent\state.db`)
+//	store, err := winlog.NewSQLiteStore(db)
+//
+// The store closes the database when it's closed.
+type SQLiteStore struct {
+	// Retention limits for the spool. When either is exceeded the oldest
+	// events are discarded. Zero means no limit.
+	MaxSpoolEvents int64
+	MaxSpoolBytes  int64
+	// gzip level for newly spooled events. gzip.NoCompression stores plain
+	// JSON. Events are decompressed as needed when read, whatever the level.
+	CompressionLevel int
+
+	db      *sql.DB
+	mutex   sync.Mutex
+	deleted int64
+	dropped uint64
+	// Total size of the spooled events, read when the store is opened so
+	// the spool isn't scanned on every Spool
+	spoolBytes int64
+}
+
+// A SpooledEvent is an event read back from the spool. Pass ID to Ack once
+// the event has been forwarded.
+type SpooledEvent struct {
+	ID    int64
+	Event *WinLogEvent
+}
+
+// NewSQLiteStore creates the store's tables in `db` if they don't exist.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS bookmarks (channel TEXT PRIMARY KEY, bookmark TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS spool (id INTEGER PRIMARY KEY AUTOINCREMENT, size INTEGER NOT NULL, event BLOB NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS stats (channel TEXT PRIMARY KEY, stats TEXT NOT NULL)`,
+	}
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("Failed to create SQLite store tables: %v", err)
+		}
+	}
+	store := &SQLiteStore{CompressionLevel: gzip.DefaultCompression, db: db}
+	if err := db.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM spool`).Scan(&store.spoolBytes); err != nil {
+		return nil, fmt.Errorf("Failed to read SQLite store spool size: %v", err)
+	}
+	return store, nil
+}
+
+func (s *SQLiteStore) Load(channel string) (string, error) {
+	var bookmark string
+	err := s.db.QueryRow(`SELECT bookmark FROM bookmarks WHERE channel = ?`, channel).Scan(&bookmark)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return bookmark, err
+}
+
+func (s *SQLiteStore) Save(channel, bookmark string) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO bookmarks (channel, bookmark) VALUES (?, ?)`, channel, bookmark)
+	return err
+}
+
+func (s *SQLiteStore) LoadStats(channel string) (PersistedStats, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT stats FROM stats WHERE channel = ?`, channel).Scan(&data)
+	if err == sql.ErrNoRows {
+		return PersistedStats{}, nil
+	} else if err != nil {
+		return PersistedStats{}, err
+	}
+	var stats PersistedStats
+	if err := json.Unmarshal([]byte(data), &stats); err != nil {
+		return PersistedStats{}, fmt.Errorf("Failed to read stats for %q: %v", channel, err)
+	}
+	return stats, nil
+}
+
+func (s *SQLiteStore) SaveStats(channel string, stats PersistedStats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO stats (channel, stats) VALUES (?, ?)`, channel, string(data))
+	return err
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Dropped returns the number of spooled events discarded by the retention
+// limits since the store was created.
+func (s *SQLiteStore) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// Spool appends the event to the spool, then applies the retention limits.
+func (s *SQLiteStore) Spool(event *WinLogEvent) error {
+	data, err := marshalSpooledEvent(event)
+	if err != nil {
+		return err
+	}
+	if data, err = compressSpooled(data, s.CompressionLevel); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.db.Exec(`INSERT INTO spool (size, event) VALUES (?, ?)`, len(data), data); err != nil {
+		return fmt.Errorf("Failed to spool event: %v", err)
+	}
+	s.spoolBytes += int64(len(data))
+	return s.applyRetention()
+}
+
+// SpooledEvents returns up to `limit` of the oldest events in the spool.
+func (s *SQLiteStore) SpooledEvents(limit int) ([]SpooledEvent, error) {
+	rows, err := s.db.Query(`SELECT id, event FROM spool ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []SpooledEvent
+	for rows.Next() {
+		var spooled SpooledEvent
+		var data []byte
+		if err := rows.Scan(&spooled.ID, &data); err != nil {
+			return nil, err
+		}
+		if data, err = decompressSpooled(data); err == nil {
+			spooled.Event, err = unmarshalSpooledEvent(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read spooled event %v: %v", spooled.ID, err)
+		}
+		events = append(events, spooled)
+	}
+	return events, rows.Err()
+}
+
+// Ack removes the spooled event with the given ID and all events before it.
+func (s *SQLiteStore) Ack(id int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.deleteSpooled(id)
+	return err
+}
+
+// applyRetention discards the oldest events until the spool is within its
+// limits. The spool is only scanned when it's over MaxSpoolBytes. Must be
+// called with the mutex held.
+func (s *SQLiteStore) applyRetention() error {
+	if s.MaxSpoolEvents > 0 {
+		var cutoff int64
+		err := s.db.QueryRow(`SELECT id FROM spool ORDER BY id DESC LIMIT 1 OFFSET ?`, s.MaxSpoolEvents).Scan(&cutoff)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		} else if err == nil {
+			if err := s.dropSpooled(cutoff); err != nil {
+				return err
+			}
+		}
+	}
+	if s.MaxSpoolBytes > 0 && s.spoolBytes > s.MaxSpoolBytes {
+		// Find the newest event that has to go
+		rows, err := s.db.Query(`SELECT id, size FROM spool ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		total := s.spoolBytes
+		var cutoff int64
+		for total > s.MaxSpoolBytes && rows.Next() {
+			var size int64
+			if err := rows.Scan(&cutoff, &size); err != nil {
+				rows.Close()
+				return err
+			}
+			total -= size
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := s.dropSpooled(cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropSpooled discards the spooled events up to and including `id` for the
+// retention limits. Must be called with the mutex held.
+func (s *SQLiteStore) dropSpooled(id int64) error {
+	n, err := s.deleteSpooled(id)
+	s.dropped += uint64(n)
+	return err
+}
+
+// deleteSpooled deletes the spooled events up to and including `id`,
+// returning how many were deleted, and reclaims their space with VACUUM
+// every sqliteVacuumInterval rows. Must be called with the mutex held.
+func (s *SQLiteStore) deleteSpooled(id int64) (int64, error) {
+	var size int64
+	if err := s.db.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM spool WHERE id <= ?`, id).Scan(&size); err != nil {
+		return 0, err
+	}
+	result, err := s.db.Exec(`DELETE FROM spool WHERE id <= ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	s.spoolBytes -= size
+	s.deleted += n
+	if s.deleted < sqliteVacuumInterval {
+		return n, nil
+	}
+	s.deleted = 0
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return n, fmt.Errorf("Failed to vacuum SQLite store: %v", err)
+	}
+	return n, nil
+}
+
+// Spooled form of an event. Errors don't survive encoding/json, so they're
+// stored as their messages.
+type spooledEventJson struct {
+	*WinLogEvent
+	XmlErr             string `json:",omitempty"`
+	RenderedFieldsErr  string `json:",omitempty"`
+	PublisherHandleErr string `json:",omitempty"`
+	EventDataErr       string `json:",omitempty"`
+	BinaryErr          string `json:",omitempty"`
+	EnrichErr          string `json:",omitempty"`
+	RawErr             string `json:",omitempty"`
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func stringError(s string) error {
+	if s == "" {
+		return nil
+	}
+	return errors.New(s)
+}
+
+func marshalSpooledEvent(event *WinLogEvent) ([]byte, error) {
+	return json.Marshal(spooledEventJson{
+		WinLogEvent:        event,
+		XmlErr:             errorString(event.XmlErr),
+		RenderedFieldsErr:  errorString(event.RenderedFieldsErr),
+		PublisherHandleErr: errorString(event.PublisherHandleErr),
+		EventDataErr:       errorString(event.EventDataErr),
+		BinaryErr:          errorString(event.BinaryErr),
+		EnrichErr:          errorString(event.EnrichErr),
+		RawErr:             errorString(event.RawErr),
+	})
+}
+
+func unmarshalSpooledEvent(data []byte) (*WinLogEvent, error) {
+	spooled := spooledEventJson{WinLogEvent: &WinLogEvent{}}
+	if err := json.Unmarshal(data, &spooled); err != nil {
+		return nil, err
+	}
+	event := spooled.WinLogEvent
+	event.XmlErr = stringError(spooled.XmlErr)
+	event.RenderedFieldsErr = stringError(spooled.RenderedFieldsErr)
+	event.PublisherHandleErr = stringError(spooled.PublisherHandleErr)
+	event.EventDataErr = stringError(spooled.EventDataErr)
+	event.BinaryErr = stringError(spooled.BinaryErr)
+	event.EnrichErr = stringError(spooled.EnrichErr)
+	event.RawErr = stringError(spooled.RawErr)
+	return event, nil
+}
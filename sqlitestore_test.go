@@ -0,0 +1,430 @@
+package winlog
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	. "testing"
+	"time"
+)
+
+func TestSpooledEventEncoding(t *T) {
+	event := &WinLogEvent{
+		Xml:          []byte("<Event/>"),
+		EventId:      4624,
+		Created:      time.Date(2021, 3, 4, 5, 6, 7, 100, time.UTC),
+		EventData:    map[string]string{"User": "alice"},
+		EventDataErr: errors.New("Truncated"),
+		Bookmark:     "<BookmarkList/>",
+	}
+	data, err := marshalSpooledEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := unmarshalSpooledEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(string(decoded.Xml), "<Event/>", t)
+	assertEqual(decoded.EventId, uint64(4624), t)
+	assertEqual(decoded.Created.Equal(event.Created), true, t)
+	assertEqual(decoded.EventData["User"], "alice", t)
+	assertEqual(decoded.EventDataErr.Error(), "Truncated", t)
+	assertEqual(decoded.XmlErr, nil, t)
+	assertEqual(decoded.Bookmark, "<BookmarkList/>", t)
+}
+
+// Every error field must survive spooling, or the event can't be read back
+func TestSpooledEventErrors(t *T) {
+	event := &WinLogEvent{}
+	value := reflect.ValueOf(event).Elem()
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	var names []string
+	for i := 0; i < value.NumField(); i++ {
+		if field := value.Type().Field(i); field.Type == errorType {
+			names = append(names, field.Name)
+			value.Field(i).Set(reflect.ValueOf(errors.New("Failed " + field.Name)))
+		}
+	}
+	assertEqual(len(names) >= 7, true, t)
+	data, err := marshalSpooledEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := unmarshalSpooledEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedValue := reflect.ValueOf(decoded).Elem()
+	for _, name := range names {
+		err, _ := decodedValue.FieldByName(name).Interface().(error)
+		if err == nil || err.Error() != "Failed "+name {
+			t.Errorf("%v = %v after spooling", name, err)
+		}
+	}
+}
+
+// fakeSQLite is a database/sql driver which runs the statements
+// SQLiteStore makes against in-memory tables, so the store is tested
+// without a SQLite driver linked in. Databases are shared by name.
+type fakeSQLite struct {
+	mutex     sync.Mutex
+	databases map[string]*fakeSQLiteDB
+}
+
+type fakeSQLiteDB struct {
+	mutex     sync.Mutex
+	bookmarks map[string]string
+	stats     map[string]string
+	spool     []fakeSpoolRow
+	nextId    int64
+	vacuums   int
+	// Queries summing the size of the whole spool
+	scans int
+}
+
+type fakeSpoolRow struct {
+	id    int64
+	size  int64
+	event []byte
+}
+
+var fakeSQLiteDriver = &fakeSQLite{databases: make(map[string]*fakeSQLiteDB)}
+
+func init() {
+	sql.Register("winlogfakesqlite", fakeSQLiteDriver)
+}
+
+func (d *fakeSQLite) Open(name string) (driver.Conn, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	db, ok := d.databases[name]
+	if !ok {
+		db = &fakeSQLiteDB{bookmarks: make(map[string]string), stats: make(map[string]string)}
+		d.databases[name] = db
+	}
+	return &fakeSQLiteConn{db: db}, nil
+}
+
+type fakeSQLiteConn struct {
+	db *fakeSQLiteDB
+}
+
+func (c *fakeSQLiteConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLiteStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeSQLiteConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLiteConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("Transactions aren't supported")
+}
+
+type fakeSQLiteStmt struct {
+	db    *fakeSQLiteDB
+	query string
+}
+
+func (s *fakeSQLiteStmt) Close() error {
+	return nil
+}
+
+func (s *fakeSQLiteStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeSQLiteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.db
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS"):
+		return driver.RowsAffected(0), nil
+	case s.query == `INSERT OR REPLACE INTO bookmarks (channel, bookmark) VALUES (?, ?)`:
+		db.bookmarks[args[0].(string)] = args[1].(string)
+		return driver.RowsAffected(1), nil
+	case s.query == `INSERT OR REPLACE INTO stats (channel, stats) VALUES (?, ?)`:
+		db.stats[args[0].(string)] = args[1].(string)
+		return driver.RowsAffected(1), nil
+	case s.query == `INSERT INTO spool (size, event) VALUES (?, ?)`:
+		db.nextId++
+		db.spool = append(db.spool, fakeSpoolRow{id: db.nextId, size: args[0].(int64), event: args[1].([]byte)})
+		return driver.RowsAffected(1), nil
+	case s.query == `DELETE FROM spool WHERE id <= ?`:
+		return db.deleteThrough(args[0].(int64)), nil
+	case s.query == `VACUUM`:
+		db.vacuums++
+		return driver.RowsAffected(0), nil
+	}
+	return nil, fmt.Errorf("Unexpected statement %q", s.query)
+}
+
+func (db *fakeSQLiteDB) deleteThrough(id int64) driver.Result {
+	kept := db.spool[:0]
+	for _, row := range db.spool {
+		if row.id > id {
+			kept = append(kept, row)
+		}
+	}
+	deleted := len(db.spool) - len(kept)
+	db.spool = kept
+	return driver.RowsAffected(deleted)
+}
+
+func (s *fakeSQLiteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.db
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	rows := &fakeSQLiteRows{}
+	switch s.query {
+	case `SELECT bookmark FROM bookmarks WHERE channel = ?`:
+		rows.columns = []string{"bookmark"}
+		if bookmark, ok := db.bookmarks[args[0].(string)]; ok {
+			rows.values = append(rows.values, []driver.Value{bookmark})
+		}
+	case `SELECT stats FROM stats WHERE channel = ?`:
+		rows.columns = []string{"stats"}
+		if stats, ok := db.stats[args[0].(string)]; ok {
+			rows.values = append(rows.values, []driver.Value{stats})
+		}
+	case `SELECT id, event FROM spool ORDER BY id LIMIT ?`:
+		rows.columns = []string{"id", "event"}
+		for _, row := range db.spool {
+			if int64(len(rows.values)) < args[0].(int64) {
+				rows.values = append(rows.values, []driver.Value{row.id, row.event})
+			}
+		}
+	case `SELECT id FROM spool ORDER BY id DESC LIMIT 1 OFFSET ?`:
+		rows.columns = []string{"id"}
+		if offset := args[0].(int64); offset < int64(len(db.spool)) {
+			rows.values = append(rows.values, []driver.Value{db.spool[int64(len(db.spool))-1-offset].id})
+		}
+	case `SELECT COALESCE(SUM(size), 0) FROM spool`, `SELECT COALESCE(SUM(size), 0) FROM spool WHERE id <= ?`:
+		var total int64
+		for _, row := range db.spool {
+			if len(args) == 0 || row.id <= args[0].(int64) {
+				total += row.size
+			}
+		}
+		if len(args) == 0 {
+			db.scans++
+		}
+		rows.columns = []string{"total"}
+		rows.values = [][]driver.Value{{total}}
+	case `SELECT id, size FROM spool ORDER BY id`:
+		rows.columns = []string{"id", "size"}
+		for _, row := range db.spool {
+			rows.values = append(rows.values, []driver.Value{row.id, row.size})
+		}
+	default:
+		return nil, fmt.Errorf("Unexpected query %q", s.query)
+	}
+	return rows, nil
+}
+
+type fakeSQLiteRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeSQLiteRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeSQLiteRows) Close() error {
+	return nil
+}
+
+func (r *fakeSQLiteRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// openTestSQLite opens a database with whichever SQLite driver the test
+// binary was built with, or the fake driver if there is none.
+func openTestSQLite(t *T) *sql.DB {
+	for _, driver := range sql.Drivers() {
+		if driver != "sqlite" && driver != "sqlite3" {
+			continue
+		}
+		dir, err := ioutil.TempDir("", "sqlitestore")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		db, err := sql.Open(driver, filepath.Join(dir, "state.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	db, err := sql.Open("winlogfakesqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteStore(t *T) {
+	store, err := NewSQLiteStore(openTestSQLite(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.Save("Security", "<BookmarkList/>"); err != nil {
+		t.Fatal(err)
+	}
+	bookmark, err := store.Load("Security")
+	assertEqual(err, nil, t)
+	assertEqual(bookmark, "<BookmarkList/>", t)
+	bookmark, err = store.Load("System")
+	assertEqual(err, nil, t)
+	assertEqual(bookmark, "", t)
+
+	if err := store.SaveStats("Security", PersistedStats{Delivered: 5, LastRecordId: 42}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := store.LoadStats("Security")
+	assertEqual(err, nil, t)
+	assertEqual(stats.Delivered, uint64(5), t)
+	assertEqual(stats.LastRecordId, uint64(42), t)
+	stats, err = store.LoadStats("System")
+	assertEqual(err, nil, t)
+	assertEqual(stats.LastRun.IsZero(), true, t)
+
+	store.MaxSpoolEvents = 3
+	for id := uint64(1); id <= 5; id++ {
+		if err := store.Spool(&WinLogEvent{RecordId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(store.Dropped(), uint64(2), t)
+	events, err := store.SpooledEvents(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(events), 3, t)
+	assertEqual(events[0].Event.RecordId, uint64(3), t)
+
+	if err := store.Ack(events[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	events, err = store.SpooledEvents(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(events), 1, t)
+	assertEqual(events[0].Event.RecordId, uint64(5), t)
+}
+
+func TestSQLiteStoreRetention(t *T) {
+	store, err := NewSQLiteStore(openTestSQLite(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.CompressionLevel = gzip.NoCompression
+	event := &WinLogEvent{Msg: strings.Repeat("x", 100)}
+	data, _ := marshalSpooledEvent(event)
+
+	// Only the newest two events fit
+	store.MaxSpoolBytes = int64(2*len(data) + len(data)/2)
+	for id := uint64(1); id <= 4; id++ {
+		event.RecordId = id
+		if err := store.Spool(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(store.Dropped(), uint64(2), t)
+	events, err := store.SpooledEvents(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(events), 2, t)
+	assertEqual(events[0].Event.RecordId, uint64(3), t)
+	assertEqual(events[0].Event.Msg, event.Msg, t)
+
+	// Space is reclaimed once enough events have been deleted
+	store.deleted = sqliteVacuumInterval - 1
+	if err := store.Ack(events[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(store.deleted, int64(0), t)
+	fakeSQLiteDriver.mutex.Lock()
+	db := fakeSQLiteDriver.databases[t.Name()]
+	fakeSQLiteDriver.mutex.Unlock()
+	if db != nil {
+		assertEqual(db.vacuums, 1, t)
+	}
+}
+
+func TestSQLiteStoreSpoolBytes(t *T) {
+	db := openTestSQLite(t)
+	store, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.CompressionLevel = gzip.NoCompression
+	event := &WinLogEvent{Msg: strings.Repeat("x", 100)}
+	data, _ := marshalSpooledEvent(event)
+	for id := uint64(1); id <= 3; id++ {
+		event.RecordId = id
+		if err := store.Spool(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(store.spoolBytes, int64(3*len(data)), t)
+	events, err := store.SpooledEvents(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Ack(events[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(store.spoolBytes, int64(2*len(data)), t)
+
+	// A store opened on an existing spool starts from its size, and the
+	// spool is only summed then
+	reopened, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	assertEqual(reopened.spoolBytes, int64(2*len(data)), t)
+	reopened.CompressionLevel = gzip.NoCompression
+	reopened.MaxSpoolBytes = int64(2 * len(data))
+	event.RecordId = 4
+	if err := reopened.Spool(event); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(reopened.Dropped(), uint64(1), t)
+	assertEqual(reopened.spoolBytes, int64(2*len(data)), t)
+	events, err = reopened.SpooledEvents(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(events), 2, t)
+	assertEqual(events[0].Event.RecordId, uint64(3), t)
+	fakeSQLiteDriver.mutex.Lock()
+	fake := fakeSQLiteDriver.databases[t.Name()]
+	fakeSQLiteDriver.mutex.Unlock()
+	if fake != nil {
+		assertEqual(fake.scans, 2, t)
+	}
+}
@@ -0,0 +1,92 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StartMode is where a subscription starts reading its channel.
+type StartMode int
+
+const (
+	// With the next event that arrives
+	StartAtNow StartMode = iota
+	// With the oldest event in the channel
+	StartAtOldest
+	// After the event in SubscriptionStart.Bookmark
+	StartAtBookmark
+	// With the first event created at or after SubscriptionStart.Time
+	StartAtTime
+)
+
+// SubscriptionStart chooses where a subscription made with Subscribe
+// starts, so each subscription in a watcher can start differently.
+type SubscriptionStart struct {
+	Mode StartMode
+	// Bookmark XML, for StartAtBookmark. If it's empty, the bookmark is
+	// loaded from the watcher's BookmarkStore, starting with the oldest
+	// event if the store has none for the channel.
+	Bookmark string
+	// For StartAtTime
+	Time time.Time
+}
+
+// Subscribe to a Windows Event Log channel, starting where `start` chooses.
+// `query` is an XPath expression for filtering events: to recieve all
+// events on the channel, use "*" as the query.
+func (self *WinLogWatcher) Subscribe(channel, query string, start SubscriptionStart) error {
+	switch start.Mode {
+	case StartAtNow:
+		return self.SubscribeFromNow(channel, query)
+	case StartAtOldest:
+		return self.SubscribeFromBeginning(channel, query)
+	case StartAtBookmark:
+		bookmark := start.Bookmark
+		if bookmark == "" && self.BookmarkStore != nil {
+			var err error
+			if bookmark, err = self.BookmarkStore.Load(channel); err != nil {
+				return fmt.Errorf("Failed to load bookmark for %q: %v", channel, err)
+			}
+			if bookmark == "" {
+				return self.SubscribeFromBeginning(channel, query)
+			}
+		}
+		return self.SubscribeFromBookmark(channel, query, bookmark)
+	case StartAtTime:
+		return self.SubscribeFromTime(channel, query, start.Time)
+	}
+	return fmt.Errorf("Unknown start mode %v", start.Mode)
+}
+
+// Subscribe to a Windows Event Log channel, starting with the first event
+// created at or after `since`. The channel is read from its oldest record
+// with earlier events suppressed by the query, so reaching `since` in a
+// large channel can take a while. Structured queries aren't supported.
+func (self *WinLogWatcher) SubscribeFromTime(channel, query string, since time.Time) error {
+	if isStructuredQuery(query) {
+		return fmt.Errorf("Can't start a structured query at a time")
+	}
+	return self.subscribeWithoutBookmark(channel, query, EvtSubscribeStartAtOldestRecord, since)
+}
+
+func isStructuredQuery(query string) bool {
+	return strings.HasPrefix(strings.TrimSpace(query), "<")
+}
+
+// sinceQuery builds a structured query selecting the events matching
+// `query` on the channel, suppressing those created before `since`.
+func sinceQuery(channel, query string, since time.Time) string {
+	path := escapeXMLText(channel)
+	suppress := fmt.Sprintf("*[System[TimeCreated[@SystemTime<'%v']]]", since.UTC().Format(systemTimeFormat))
+	return fmt.Sprintf(`<QueryList><Query Id="0" Path="%v"><Select Path="%v">%v</Select><Suppress Path="%v">%v</Suppress></Query></QueryList>`,
+		path, path, escapeXMLText(query), path, escapeXMLText(suppress))
+}
+
+func escapeXMLText(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+	"time"
+)
+
+func TestSinceQuery(t *T) {
+	since := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("", 3600))
+	assertEqual(sinceQuery("Security", "*[System[EventID=4624]]", since),
+		`<QueryList><Query Id="0" Path="Security"><Select Path="Security">*[System[EventID=4624]]</Select>`+
+			`<Suppress Path="Security">*[System[TimeCreated[@SystemTime&lt;&#39;2024-01-02T14:04:05.0000000Z&#39;]]]</Suppress></Query></QueryList>`, t)
+}
+
+func TestSubscribeStartModes(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	since := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	starts := map[string]SubscriptionStart{
+		"Application": {Mode: StartAtNow},
+		"System":      {Mode: StartAtOldest},
+		"Security":    {Mode: StartAtTime, Time: since},
+		"Setup":       {Mode: StartAtBookmark, Bookmark: "7"},
+	}
+	for channel, start := range starts {
+		if err := watcher.Subscribe(channel, "*", start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for handle, channel := range api.channels {
+		query := api.queries[handle]
+		if channel == "Security" {
+			assertEqual(query, sinceQuery("Security", "*", since), t)
+		} else {
+			assertEqual(query, "*", t)
+		}
+	}
+	assertEqual(watcher.watches["System"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAtOldestRecord), t)
+	assertEqual(watcher.watches["Security"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAtOldestRecord), t)
+	assertEqual(watcher.watches["Setup"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAfterBookmark), t)
+
+	if err := watcher.Subscribe("Other", "<QueryList/>", SubscriptionStart{Mode: StartAtTime, Time: since}); err == nil {
+		t.Fatal("Structured query started at a time")
+	}
+	if err := watcher.Subscribe("Other", "*", SubscriptionStart{Mode: StartMode(99)}); err == nil {
+		t.Fatal("Unknown start mode accepted")
+	}
+
+	// The start time is kept in exported state until there's a bookmark
+	data, err := watcher.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state WatcherState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range state.Subscriptions {
+		assertEqual(sub.Since != nil, sub.Channel == "Security", t)
+	}
+	restored, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Shutdown()
+	if err := restored.ImportState(data); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(restored.watches["Security"].since.Equal(since), true, t)
+}
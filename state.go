@@ -0,0 +1,145 @@
+package winlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Version of the bundle written by ExportState
+const stateVersion = 1
+
+// WatcherState is the bundle written by ExportState.
+type WatcherState struct {
+	Version       int
+	Exported      time.Time
+	Subscriptions []SubscriptionState
+}
+
+// SubscriptionState records where a subscription had got to.
+type SubscriptionState struct {
+	Channel   string
+	Query     string
+	QueryHash string
+	// Bookmark XML of the last event published, or "" if no events had
+	// been published since subscribing.
+	Bookmark string
+	// Whether the subscription started at the oldest record. Only used
+	// when there is no Bookmark.
+	FromBeginning bool `json:",omitempty"`
+	// The time the subscription started at, if it was made with
+	// SubscribeFromTime. Events created before it stay suppressed when
+	// resuming from the Bookmark.
+	Since *time.Time `json:",omitempty"`
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExportState serializes the bookmarks and queries of all subscriptions to
+// JSON, so consumption can be resumed with ImportState on another watcher,
+// for example after moving an agent to a new host or reinstalling it.
+func (self *WinLogWatcher) ExportState() ([]byte, error) {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	bookmarks, err := self.bookmarkSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	state := WatcherState{Version: stateVersion, Exported: time.Now().UTC()}
+	for channel, watch := range self.watches {
+		sub := SubscriptionState{Channel: channel, Query: watch.query, QueryHash: queryHash(watch.query)}
+		if bookmark, ok := bookmarks[channel]; ok {
+			sub.Bookmark = bookmark
+		} else {
+			sub.FromBeginning = watch.flags == EvtSubscribeStartAtOldestRecord
+		}
+		if !watch.since.IsZero() {
+			since := watch.since
+			sub.Since = &since
+		}
+		state.Subscriptions = append(state.Subscriptions, sub)
+	}
+	sort.Slice(state.Subscriptions, func(i, j int) bool {
+		return state.Subscriptions[i].Channel < state.Subscriptions[j].Channel
+	})
+	return json.MarshalIndent(state, "", "  ")
+}
+
+// BookmarkSnapshot returns the bookmark XML of every subscription, keyed
+// by channel. Bookmark updates are held while they're rendered, so the
+// snapshot is consistent across channels and can be persisted as one
+// checkpoint. Subscriptions which haven't received an event yet are left
+// out.
+func (self *WinLogWatcher) BookmarkSnapshot() (map[string]string, error) {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	return self.bookmarkSnapshot()
+}
+
+// bookmarkSnapshot renders the bookmark of each subscription with all of
+// them locked. The caller must hold watchMutex.
+func (self *WinLogWatcher) bookmarkSnapshot() (map[string]string, error) {
+	for _, watch := range self.watches {
+		watch.bookmarkMutex.Lock()
+	}
+	defer func() {
+		for _, watch := range self.watches {
+			watch.bookmarkMutex.Unlock()
+		}
+	}()
+	bookmarks := make(map[string]string, len(self.watches))
+	for channel, watch := range self.watches {
+		if !watch.bookmarked {
+			continue
+		}
+		bookmark, err := self.renderBookmark(watch)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to render bookmark for %q: %v", channel, err)
+		}
+		bookmarks[channel] = bookmark
+	}
+	return bookmarks, nil
+}
+
+// ImportState subscribes to every channel in a bundle written by
+// ExportState, resuming from its bookmark. Channels which are already
+// subscribed are an error; subscriptions made before the error are kept.
+func (self *WinLogWatcher) ImportState(data []byte) error {
+	var state WatcherState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("Failed to read watcher state: %v", err)
+	}
+	if state.Version != stateVersion {
+		return fmt.Errorf("Unsupported watcher state version %v", state.Version)
+	}
+	for _, sub := range state.Subscriptions {
+		if sub.QueryHash != queryHash(sub.Query) {
+			return fmt.Errorf("Query for %q doesn't match its hash", sub.Channel)
+		}
+	}
+	for _, sub := range state.Subscriptions {
+		var err error
+		switch {
+		case sub.Bookmark != "" && sub.Since != nil:
+			err = self.subscribeFromBookmark(sub.Channel, sub.Query, sub.Bookmark, *sub.Since)
+		case sub.Bookmark != "":
+			err = self.SubscribeFromBookmark(sub.Channel, sub.Query, sub.Bookmark)
+		case sub.Since != nil:
+			err = self.SubscribeFromTime(sub.Channel, sub.Query, *sub.Since)
+		case sub.FromBeginning:
+			err = self.SubscribeFromBeginning(sub.Channel, sub.Query)
+		default:
+			err = self.SubscribeFromNow(sub.Channel, sub.Query)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package winlog
+
+import (
+	"encoding/json"
+	"strings"
+	. "testing"
+	"time"
+)
+
+func TestExportImportState(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.SubscribeFromBeginning("System", "*[System[Level=2]]"); err != nil {
+		t.Fatal(err)
+	}
+	go api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(42)})
+	<-watcher.Event()
+
+	data, err := watcher.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state WatcherState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(state.Subscriptions), 2, t)
+	assertEqual(state.Subscriptions[0].Channel, "Application", t)
+	assertEqual(state.Subscriptions[0].Bookmark, "42", t)
+	assertEqual(state.Subscriptions[1].Channel, "System", t)
+	assertEqual(state.Subscriptions[1].Bookmark, "", t)
+	assertEqual(state.Subscriptions[1].FromBeginning, true, t)
+
+	imported, err := NewWinLogWatcherWithAPI(newFakeAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Shutdown()
+	if err := imported.ImportState(data); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(imported.watches["Application"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAfterBookmark), t)
+	assertEqual(imported.watches["System"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAtOldestRecord), t)
+	assertEqual(imported.watches["System"].query, "*[System[Level=2]]", t)
+	if err := imported.ImportState(data); err == nil {
+		t.Fatal("Expected an error importing subscriptions which already exist")
+	}
+
+	state.Subscriptions[0].Query = "*[System[EventID=1]]"
+	data, _ = json.Marshal(state)
+	if err := watcher.ImportState(data); err == nil {
+		t.Fatal("Expected an error for a mismatched query hash")
+	}
+}
+
+func TestBookmarkSnapshot(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for _, channel := range []string{"Application", "Security", "System"} {
+		if err := watcher.SubscribeFromNow(channel, "*"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go func() {
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(7)})
+		api.emit("Security", fakeValues{EvtSystemEventRecordId: uint64(9)})
+	}()
+	<-watcher.Event()
+	<-watcher.Event()
+
+	bookmarks, err := watcher.BookmarkSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(bookmarks), 2, t)
+	assertEqual(bookmarks["Application"], "7", t)
+	assertEqual(bookmarks["Security"], "9", t)
+
+	// Snapshots taken while events arrive don't block them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := uint64(10); i < 20; i++ {
+			api.emit("System", fakeValues{EvtSystemEventRecordId: i})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := watcher.BookmarkSnapshot(); err != nil {
+			t.Fatal(err)
+		}
+		<-watcher.Event()
+	}
+	<-done
+	bookmarks, _ = watcher.BookmarkSnapshot()
+	assertEqual(bookmarks["System"], "19", t)
+}
+
+func TestExportStateSplitFromTime(t *T) {
+	api := headAPI{fakeAPI: newFakeAPI(), info: ChannelInfo{OldestRecordId: 11, NewestRecordId: 20}}
+	watcher, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := watcher.SubscribeFromTime("Application", splitQueryOf(), since); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before either subscription reads an event, the state resumes before
+	// the oldest record, still suppressing events before the time
+	data, err := watcher.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state WatcherState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(state.Subscriptions[0].Bookmark, "10", t)
+	assertEqual(state.Subscriptions[0].Since.Equal(since), true, t)
+
+	imported, err := NewWinLogWatcherWithAPI(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Shutdown()
+	if err := imported.ImportState(data); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(imported.watches["Application"].flags, EVT_SUBSCRIBE_FLAGS(EvtSubscribeStartAfterBookmark), t)
+	assertEqual(imported.watches["Application"].since.Equal(since), true, t)
+	// The imported subscriptions follow the exporting watcher's two
+	subscription := uint64(api.subscriptions("Application")[2])
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	assertEqual(api.starts[subscription], uint64(10), t)
+	assertEqual(strings.Contains(api.queries[subscription], "<Suppress"), true, t)
+}
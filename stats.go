@@ -0,0 +1,426 @@
+package winlog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters for a single subscription, returned by WinLogWatcher.Stats
+type SubscriptionStats struct {
+	// Events sent to the Event channel
+	Delivered uint64
+	// Events removed by filters, sampling or duplicate suppression
+	Filtered uint64
+	// Events dropped by rate limiting or load shedding
+	Dropped uint64
+	// Events discarded by the watcher's Expiry policy for being too old
+	Expired uint64
+	// Failed calls to the watcher's enrichers, and events which weren't
+	// enriched by all of them within its EnrichBudget
+	EnrichErrors     uint64
+	EnrichOverBudget uint64
+	// Events whose system values or XML failed to render
+	RenderErrors uint64
+	// Failed EvtFormatMessage calls, including failures to open the publisher
+	FormatErrors uint64
+	// Records the subscription never received, found from gaps in the
+	// RecordIds it read, which were overwritten when the log wrapped. Only
+	// counted for subscriptions whose query is "*".
+	LostRecords uint64
+	// Mean time taken to render and format an event, and the distribution
+	AverageRenderLatency time.Duration
+	RenderLatency        LatencyHistogram
+	// Time from each event's TimeCreated until it was sent to the Event
+	// channel, and its percentiles. High values indicate a slow consumer or
+	// slow rendering, or that old events are being read from a bookmark.
+	DeliveryLatency    LatencyHistogram
+	DeliveryLatencyP50 time.Duration
+	DeliveryLatencyP90 time.Duration
+	DeliveryLatencyP99 time.Duration
+	// Creation time of the last delivered event, and when it was delivered
+	LastEventCreated   time.Time
+	LastEventDelivered time.Time
+	// Events delivered per second over the last minute
+	EventsPerSecond float64
+	// Events received from the event log but not yet delivered
+	Backlog int64
+	// RecordId of the last event the bookmark was updated with, the newest
+	// RecordId in the channel, and the difference between them. The channel
+	// head is only tracked if the watcher's BookmarkLagInterval is set, and
+	// not for subscriptions reading records out of order: split queries and
+	// collector channels such as ForwardedEvents. Lag includes records the
+	// subscription's query doesn't match.
+	LastRecordId        uint64
+	ChannelHeadRecordId uint64
+	BookmarkLag         uint64
+	// For a split query, the records between the subscription furthest
+	// behind, whose bookmark events are published with, and the one
+	// furthest ahead. A subscription matching nothing holds the bookmark
+	// back until it's advanced every BookmarkLagInterval.
+	SplitBookmarkLag uint64
+	// Estimate of when the channel will lose events, if the watcher's
+	// CapacityWarningHorizon is set
+	Capacity *ChannelCapacity `json:",omitempty"`
+	// Noisiest sources in the watcher's TopSourcesWindow, if set
+	TopSources []SourceCount `json:",omitempty"`
+	// Clock skew of each computer events came from, if the watcher's
+	// ClockSkewThreshold is set
+	ClockSkew []HostClockSkew `json:",omitempty"`
+	// Counters saved by earlier runs, if the watcher's PersistStatsInterval
+	// is set, and the records written to the channel after the last one
+	// read while the watcher wasn't running, of which LostWhileDown had
+	// already been overwritten when subscribing. A subscription starting
+	// with the next event skips all of those written.
+	PreviousRuns     *PersistedStats `json:",omitempty"`
+	WrittenWhileDown uint64
+	LostWhileDown    uint64
+	// State of the remote session the subscription reads through, if the
+	// watcher uses a RemoteAPI
+	RemoteSession *RemoteSessionStats `json:",omitempty"`
+}
+
+// Upper bounds of the buckets used for latency histograms. Must not be modified.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Upper bounds of the buckets used for DeliveryLatency, which covers events
+// waiting in the event log as well as in the watcher. Must not be modified.
+var DeliveryLatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+}
+
+// LatencyHistogram counts observations in buckets with the upper Bounds.
+// Counts has one more entry than Bounds, for observations above the last bound.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Sum    time.Duration
+	Count  uint64
+}
+
+// Percentile estimates the duration below which the fraction p (0 to 1) of
+// observations fall, interpolating linearly within a bucket. Observations
+// above the last bound are reported as the last bound.
+func (h LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := p * float64(h.Count)
+	var seen float64
+	for i, count := range h.Counts {
+		if i == len(h.Bounds) {
+			break
+		}
+		if count > 0 && seen+float64(count) >= rank {
+			var lower time.Duration
+			if i > 0 {
+				lower = h.Bounds[i-1]
+			}
+			fraction := (rank - seen) / float64(count)
+			return lower + time.Duration(fraction*float64(h.Bounds[i]-lower))
+		}
+		seen += float64(count)
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+type latencyHistogram struct {
+	// Defaults to LatencyBuckets
+	bounds []time.Duration
+
+	mutex  sync.Mutex
+	counts []uint64
+	sum    time.Duration
+	count  uint64
+}
+
+func (h *latencyHistogram) buckets() []time.Duration {
+	if h.bounds == nil {
+		return LatencyBuckets
+	}
+	return h.bounds
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	bounds := h.buckets()
+	i := 0
+	for i < len(bounds) && d > bounds[i] {
+		i++
+	}
+	h.mutex.Lock()
+	if h.counts == nil {
+		h.counts = make([]uint64, len(bounds)+1)
+	}
+	h.counts[i]++
+	h.sum += d
+	h.count++
+	h.mutex.Unlock()
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	bounds := h.buckets()
+	counts := make([]uint64, len(bounds)+1)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	copy(counts, h.counts)
+	return LatencyHistogram{
+		Bounds: bounds,
+		Counts: counts,
+		Sum:    h.sum,
+		Count:  h.count,
+	}
+}
+
+func (self *WinLogWatcher) newSubscriptionStats() *subscriptionStats {
+	stats := &subscriptionStats{deliveryLatency: latencyHistogram{bounds: DeliveryLatencyBuckets}}
+	if self.TopSourcesWindow > 0 {
+		stats.sources = newSourceCounter(self.TopSourcesWindow, self.TopSources)
+	}
+	if self.ClockSkewThreshold > 0 {
+		stats.skew = newSkewTracker(self.ClockSkewThreshold)
+	}
+	return stats
+}
+
+type subscriptionStats struct {
+	// Updated atomically
+	delivered        uint64
+	filtered         uint64
+	dropped          uint64
+	expired          uint64
+	enrichErrors     uint64
+	enrichOverBudget uint64
+	renderErrors     uint64
+	formatErrors     uint64
+	lostRecords      uint64
+	backlog          int64
+	// Events dropped by the rate limit since the last one read, before
+	// their RecordIds were
+	skipped uint64
+
+	renderLatency   latencyHistogram
+	deliveryLatency latencyHistogram
+	sources         *sourceCounter
+	skew            *skewTracker
+
+	mutex              sync.Mutex
+	lastEventCreated   time.Time
+	lastEventDelivered time.Time
+	rate               eventRate
+	lastRecordId       uint64
+	headRecordId       uint64
+	capacitySample     capacitySample
+	capacity           *ChannelCapacity
+	previous           *PersistedStats
+	writtenWhileDown   uint64
+	lostWhileDown      uint64
+}
+
+// recordPosition records the RecordId of the last event read, returning the
+// one before it and the events dropped unread between them.
+func (s *subscriptionStats) recordPosition(recordId uint64) (previous, skipped uint64) {
+	skipped = atomic.SwapUint64(&s.skipped, 0)
+	s.mutex.Lock()
+	previous, s.lastRecordId = s.lastRecordId, recordId
+	s.mutex.Unlock()
+	return previous, skipped
+}
+
+func (s *subscriptionStats) recordHead(recordId uint64) {
+	s.mutex.Lock()
+	s.headRecordId = recordId
+	s.mutex.Unlock()
+}
+
+// recordCapacity estimates when the channel will lose events from the
+// rates of writing and reading since the last estimate.
+func (s *subscriptionStats) recordCapacity(info ChannelInfo, config ChannelConfig, now time.Time) ChannelCapacity {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sample := capacitySample{time: now, headRecordId: s.headRecordId, lastRecordId: s.lastRecordId}
+	capacity := estimateCapacity(info, config, s.capacitySample, sample)
+	s.capacitySample = sample
+	s.capacity = &capacity
+	return capacity
+}
+
+func (s *subscriptionStats) recordRender(ev *WinLogEvent, took time.Duration) {
+	s.renderLatency.observe(took)
+	if ev.RenderedFieldsErr != nil || ev.XmlErr != nil {
+		atomic.AddUint64(&s.renderErrors, 1)
+	}
+	if ev.formatErrors > 0 {
+		atomic.AddUint64(&s.formatErrors, uint64(ev.formatErrors))
+	}
+}
+
+func (s *subscriptionStats) recordSource(ev *WinLogEvent, now time.Time) {
+	if s.sources != nil {
+		s.sources.add(ev, now)
+	}
+}
+
+func (s *subscriptionStats) recordDelivered(ev *WinLogEvent, now time.Time) {
+	atomic.AddUint64(&s.delivered, 1)
+	latency := now.Sub(ev.Created)
+	if latency < 0 {
+		// Clock skew between the event's source and this host
+		latency = 0
+	}
+	s.deliveryLatency.observe(latency)
+	s.mutex.Lock()
+	s.lastEventCreated = ev.Created
+	s.lastEventDelivered = now
+	s.rate.add(now)
+	s.mutex.Unlock()
+}
+
+func (s *subscriptionStats) snapshot() SubscriptionStats {
+	stats := SubscriptionStats{
+		Delivered:        atomic.LoadUint64(&s.delivered),
+		Filtered:         atomic.LoadUint64(&s.filtered),
+		Dropped:          atomic.LoadUint64(&s.dropped),
+		Expired:          atomic.LoadUint64(&s.expired),
+		EnrichErrors:     atomic.LoadUint64(&s.enrichErrors),
+		EnrichOverBudget: atomic.LoadUint64(&s.enrichOverBudget),
+		RenderErrors:     atomic.LoadUint64(&s.renderErrors),
+		FormatErrors:     atomic.LoadUint64(&s.formatErrors),
+		LostRecords:      atomic.LoadUint64(&s.lostRecords),
+		Backlog:          atomic.LoadInt64(&s.backlog),
+	}
+	stats.RenderLatency = s.renderLatency.snapshot()
+	if stats.RenderLatency.Count > 0 {
+		stats.AverageRenderLatency = stats.RenderLatency.Sum / time.Duration(stats.RenderLatency.Count)
+	}
+	stats.DeliveryLatency = s.deliveryLatency.snapshot()
+	stats.DeliveryLatencyP50 = stats.DeliveryLatency.Percentile(0.5)
+	stats.DeliveryLatencyP90 = stats.DeliveryLatency.Percentile(0.9)
+	stats.DeliveryLatencyP99 = stats.DeliveryLatency.Percentile(0.99)
+	s.mutex.Lock()
+	stats.LastEventCreated = s.lastEventCreated
+	stats.LastEventDelivered = s.lastEventDelivered
+	stats.EventsPerSecond = s.rate.perSecond(time.Now())
+	stats.LastRecordId = s.lastRecordId
+	stats.ChannelHeadRecordId = s.headRecordId
+	if s.capacity != nil {
+		capacity := *s.capacity
+		stats.Capacity = &capacity
+	}
+	if s.previous != nil {
+		previous := *s.previous
+		stats.PreviousRuns = &previous
+	}
+	stats.WrittenWhileDown = s.writtenWhileDown
+	stats.LostWhileDown = s.lostWhileDown
+	s.mutex.Unlock()
+	if stats.ChannelHeadRecordId > stats.LastRecordId {
+		stats.BookmarkLag = stats.ChannelHeadRecordId - stats.LastRecordId
+	}
+	if s.sources != nil {
+		stats.TopSources = s.sources.top(time.Now())
+	}
+	if s.skew != nil {
+		stats.ClockSkew = s.skew.snapshot()
+	}
+	return stats
+}
+
+// Periodically record how far each subscription's bookmark is behind the
+// newest record in its channel, and check the channel's capacity, until
+// shutdown.
+func (self *WinLogWatcher) trackBookmarkLag() {
+	ticker := time.NewTicker(self.BookmarkLagInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-self.shutdown:
+			return
+		}
+		// The last RecordId read by an unordered subscription says nothing
+		// about how far its bookmark is behind, so its head is cleared.
+		// Split queries of a local channel still have their idle
+		// subscriptions advanced.
+		self.watchMutex.Lock()
+		watches := make(map[string]*channelWatcher, len(self.watches))
+		splitWatches := make(map[string]*channelWatcher)
+		for channel, watch := range self.watches {
+			if self.ordered(channel, watch) {
+				watches[channel] = watch
+				continue
+			}
+			watch.stats.recordHead(0)
+			if watch.splits != nil && !self.isCollectorChannel(channel) {
+				splitWatches[channel] = watch
+			}
+		}
+		self.watchMutex.Unlock()
+
+		for channel, watch := range splitWatches {
+			info, err := self.api.ChannelInfo(channel)
+			if err == nil {
+				err = self.advanceIdleSplits(channel, watch, info.NewestRecordId)
+			}
+			if err != nil {
+				self.log(LogWarn, "Failed to advance idle split bookmarks", "channel", channel, "error", err)
+			}
+		}
+
+		for channel, watch := range watches {
+			info, err := self.api.ChannelInfo(channel)
+			if err != nil {
+				self.log(LogWarn, "Failed to get channel info", "channel", channel, "error", err)
+				continue
+			}
+			watch.stats.recordHead(info.NewestRecordId)
+			if self.CapacityWarningHorizon > 0 {
+				self.checkCapacity(channel, watch, info)
+			}
+		}
+	}
+}
+
+// Stats returns the counters for each subscription, keyed by channel.
+func (self *WinLogWatcher) Stats() map[string]SubscriptionStats {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	stats := make(map[string]SubscriptionStats, len(self.watches))
+	for channel, watch := range self.watches {
+		stats[channel] = watch.stats.snapshot()
+		if watch.splits != nil {
+			s := stats[channel]
+			s.SplitBookmarkLag = splitLag(watch)
+			stats[channel] = s
+		}
+	}
+	if remote, ok := self.api.(remoteStateNotifier); ok {
+		session := remote.SessionStats()
+		for channel, subscription := range stats {
+			subscription.RemoteSession = &session
+			stats[channel] = subscription
+		}
+	}
+	return stats
+}
@@ -0,0 +1,82 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestLatencyPercentile(t *T) {
+	h := latencyHistogram{bounds: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}}
+	assertEqual(h.snapshot().Percentile(0.5), time.Duration(0), t)
+
+	for i := 0; i < 50; i++ {
+		h.observe(5 * time.Millisecond)
+		h.observe(15 * time.Millisecond)
+	}
+	snapshot := h.snapshot()
+	assertEqual(snapshot.Count, uint64(100), t)
+	assertEqual(snapshot.Percentile(0.5), 10*time.Millisecond, t)
+	assertEqual(snapshot.Percentile(0.75), 15*time.Millisecond, t)
+	assertEqual(snapshot.Percentile(1), 20*time.Millisecond, t)
+
+	h.observe(time.Hour)
+	assertEqual(h.snapshot().Percentile(1), 20*time.Millisecond, t)
+}
+
+func TestDeliveryLatency(t *T) {
+	s := (&WinLogWatcher{}).newSubscriptionStats()
+	now := time.Now()
+	s.recordDelivered(&WinLogEvent{Created: now.Add(-2 * time.Minute)}, now)
+	s.recordDelivered(&WinLogEvent{Created: now.Add(time.Minute)}, now)
+	stats := s.snapshot()
+	assertEqual(stats.Delivered, uint64(2), t)
+	assertEqual(stats.DeliveryLatency.Counts[0], uint64(1), t)
+	assertEqual(stats.DeliveryLatency.Counts[9], uint64(1), t)
+}
+
+func TestBookmarkLagOrderedOnly(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{API: api, BookmarkLagInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for channel, query := range map[string]string{
+		"Application":          "*",
+		"System":               splitQueryOf(),
+		ForwardedEventsChannel: "*",
+	} {
+		if err := watcher.SubscribeFromNow(channel, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(3)})
+	assertEqual((<-watcher.Event()).RecordId, uint64(3), t)
+	go api.emitTo(api.subscriptions("System")[0], "System", fakeValues{EvtSystemEventRecordId: uint64(3)})
+	assertEqual((<-watcher.Event()).RecordId, uint64(3), t)
+	go api.emit(ForwardedEventsChannel, fakeValues{EvtSystemEventRecordId: uint64(3)})
+	assertEqual((<-watcher.Event()).RecordId, uint64(3), t)
+	// Two more records are written to each channel without being read
+	api.mutex.Lock()
+	for _, channel := range []string{"Application", "System", ForwardedEventsChannel} {
+		api.logged[channel] = append(api.logged[channel], fakeValues{EvtSystemEventRecordId: uint64(5)})
+	}
+	api.mutex.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for watcher.Stats()["Application"].ChannelHeadRecordId != 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("Channel head wasn't tracked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := watcher.Stats()
+	assertEqual(stats["Application"].BookmarkLag, uint64(2), t)
+	// The last record read by a split query or from a collector channel
+	// isn't the bookmark's position, so no lag is reported
+	for _, channel := range []string{"System", ForwardedEventsChannel} {
+		assertEqual(stats[channel].LastRecordId, uint64(3), t)
+		assertEqual(stats[channel].ChannelHeadRecordId, uint64(0), t)
+		assertEqual(stats[channel].BookmarkLag, uint64(0), t)
+	}
+}
@@ -0,0 +1,173 @@
+package winlog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// PersistedStats are the cumulative counters of a subscription across runs
+// of the watcher, saved with its bookmark if the watcher's
+// PersistStatsInterval is set and its BookmarkStore is a StatsStore.
+type PersistedStats struct {
+	Delivered    uint64
+	Filtered     uint64
+	Dropped      uint64
+	LastRecordId uint64
+	// When the counters were last saved, the last time the watcher is
+	// known to have been running
+	LastRun time.Time
+}
+
+// StatsStore is implemented by BookmarkStores which can also keep the
+// counters of each subscription, such as FileBookmarkStore and SQLiteStore.
+type StatsStore interface {
+	// LoadStats returns the saved counters for the channel, or the zero
+	// value if there are none.
+	LoadStats(channel string) (PersistedStats, error)
+	SaveStats(channel string, stats PersistedStats) error
+}
+
+// statsStore returns the store to persist counters in, or nil if they
+// aren't persisted.
+func (self *WinLogWatcher) statsStore() StatsStore {
+	if self.PersistStatsInterval <= 0 {
+		return nil
+	}
+	store, _ := self.BookmarkStore.(StatsStore)
+	return store
+}
+
+// missedWhileDown estimates how many records were written to a channel
+// after the one last read, and how many of those have already been
+// overwritten. If the channel was cleared, every record in it is counted as
+// written.
+func missedWhileDown(lastRecordId uint64, info ChannelInfo) (written, lost uint64) {
+	if info.NumberOfRecords == 0 {
+		return 0, 0
+	}
+	if info.NewestRecordId < lastRecordId {
+		return info.NumberOfRecords, 0
+	}
+	written = info.NewestRecordId - lastRecordId
+	if info.OldestRecordId > lastRecordId+1 {
+		lost = info.OldestRecordId - lastRecordId - 1
+	}
+	return written, lost
+}
+
+// restoreStats loads the counters saved by an earlier run for a new
+// subscription, estimating the records it missed while the watcher wasn't
+// running from the channel's head.
+func (self *WinLogWatcher) restoreStats(channel string, stats *subscriptionStats) {
+	store := self.statsStore()
+	if store == nil {
+		return
+	}
+	previous, err := store.LoadStats(channel)
+	if err != nil {
+		self.log(LogWarn, "Failed to load subscription stats", "channel", channel, "error", err)
+		return
+	}
+	if previous.LastRun.IsZero() {
+		return
+	}
+	var written, lost, newest uint64
+	cleared := false
+	if previous.LastRecordId > 0 {
+		info, err := self.api.ChannelInfo(channel)
+		if err != nil {
+			self.log(LogWarn, "Failed to get channel info", "channel", channel, "error", err)
+		} else {
+			written, lost = missedWhileDown(previous.LastRecordId, info)
+			newest = info.NewestRecordId
+			cleared = info.NumberOfRecords > 0 && info.NewestRecordId < previous.LastRecordId
+		}
+	}
+	stats.restore(previous, written, lost)
+	if cleared {
+		self.log(LogWarn, "Channel was cleared while the watcher wasn't running", "channel", channel,
+			"lastRecordId", previous.LastRecordId, "newestRecordId", newest, "lastRun", previous.LastRun)
+		self.notify(Notification{Kind: NotifyChannelCleared, Channel: channel, RecordId: newest, PreviousRecordId: previous.LastRecordId,
+			Message: fmt.Sprintf("RecordIds went back from %v to %v while the watcher wasn't running, the channel was cleared", previous.LastRecordId, newest)})
+	} else if lost > 0 {
+		self.log(LogWarn, "Records were overwritten while the watcher wasn't running", "channel", channel,
+			"lost", lost, "written", written, "lastRun", previous.LastRun)
+	} else if written > 0 {
+		self.log(LogInfo, "Records were written while the watcher wasn't running", "channel", channel,
+			"written", written, "lastRun", previous.LastRun)
+	}
+}
+
+func (s *subscriptionStats) restore(previous PersistedStats, written, lost uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.previous = &previous
+	s.writtenWhileDown = written
+	s.lostWhileDown = lost
+}
+
+// persisted returns the counters to save: those of earlier runs plus this
+// one's.
+func (s *subscriptionStats) persisted(now time.Time) PersistedStats {
+	p := PersistedStats{
+		Delivered: atomic.LoadUint64(&s.delivered),
+		Filtered:  atomic.LoadUint64(&s.filtered),
+		Dropped:   atomic.LoadUint64(&s.dropped),
+		LastRun:   now,
+	}
+	s.mutex.Lock()
+	p.LastRecordId = s.lastRecordId
+	previous := s.previous
+	s.mutex.Unlock()
+	if previous != nil {
+		p.Delivered += previous.Delivered
+		p.Filtered += previous.Filtered
+		p.Dropped += previous.Dropped
+		if p.LastRecordId == 0 {
+			p.LastRecordId = previous.LastRecordId
+		}
+	}
+	return p
+}
+
+func (self *WinLogWatcher) startStatsPersistence() {
+	if self.statsStore() != nil {
+		self.persistOnce.Do(func() { go self.persistStats() })
+	}
+}
+
+// Periodically save the counters of each subscription until shutdown.
+func (self *WinLogWatcher) persistStats() {
+	ticker := time.NewTicker(self.PersistStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			self.saveStats()
+		case <-self.shutdown:
+			return
+		}
+	}
+}
+
+// saveStats saves the counters of each subscription, if they're persisted.
+func (self *WinLogWatcher) saveStats() {
+	store := self.statsStore()
+	if store == nil {
+		return
+	}
+	self.watchMutex.Lock()
+	watches := make(map[string]*channelWatcher, len(self.watches))
+	for channel, watch := range self.watches {
+		watches[channel] = watch
+	}
+	self.watchMutex.Unlock()
+
+	now := time.Now()
+	for channel, watch := range watches {
+		if err := store.SaveStats(channel, watch.stats.persisted(now)); err != nil {
+			self.log(LogWarn, "Failed to save subscription stats", "channel", channel, "error", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package winlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	. "testing"
+	"time"
+)
+
+func TestMissedWhileDown(t *T) {
+	info := ChannelInfo{NumberOfRecords: 100, OldestRecordId: 201, NewestRecordId: 300}
+	written, lost := missedWhileDown(250, info)
+	assertEqual(written, uint64(50), t)
+	assertEqual(lost, uint64(0), t)
+
+	// The channel wrapped past the last record read
+	written, lost = missedWhileDown(150, info)
+	assertEqual(written, uint64(150), t)
+	assertEqual(lost, uint64(50), t)
+
+	// The channel was cleared
+	written, _ = missedWhileDown(500, info)
+	assertEqual(written, uint64(100), t)
+
+	written, _ = missedWhileDown(250, ChannelInfo{})
+	assertEqual(written, uint64(0), t)
+}
+
+// headAPI reports a fixed channel head
+type headAPI struct {
+	*fakeAPI
+	info ChannelInfo
+}
+
+func (a headAPI) ChannelInfo(channel string) (ChannelInfo, error) {
+	return a.info, nil
+}
+
+func TestPersistStats(t *T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := OpenFileBookmarkStore(filepath.Join(dir, "bookmarks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	opts := WatcherOptions{BookmarkStore: store, PersistStatsInterval: time.Hour}
+
+	api := newFakeAPI()
+	opts.API = api
+	watcher, err := NewWinLogWatcherWithOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(9)})
+		api.emit("Application", fakeValues{EvtSystemEventRecordId: uint64(10)})
+	}()
+	<-watcher.Event()
+	<-watcher.Event()
+	for i := 0; i < 100 && watcher.Stats()["Application"].Delivered < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	watcher.Shutdown()
+
+	saved, err := store.LoadStats("Application")
+	assertEqual(err, nil, t)
+	assertEqual(saved.Delivered, uint64(2), t)
+	assertEqual(saved.LastRecordId, uint64(10), t)
+	assertEqual(saved.LastRun.IsZero(), false, t)
+
+	// The next run picks up the counters, and the records written since
+	opts.API = headAPI{fakeAPI: newFakeAPI(), info: ChannelInfo{NumberOfRecords: 20, OldestRecordId: 1, NewestRecordId: 20}}
+	watcher, err = NewWinLogWatcherWithOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	stats := watcher.Stats()["Application"]
+	assertEqual(stats.PreviousRuns.Delivered, uint64(2), t)
+	assertEqual(stats.WrittenWhileDown, uint64(10), t)
+	assertEqual(stats.LostWhileDown, uint64(0), t)
+	watcher.Shutdown()
+
+	saved, _ = store.LoadStats("Application")
+	assertEqual(saved.Delivered, uint64(2), t)
+	assertEqual(saved.LastRecordId, uint64(10), t)
+}
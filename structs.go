@@ -1,13 +1,16 @@
-//go:build windows
-// +build windows
-
 package winlog
 
 import (
+	"errors"
 	"sync"
 	"time"
 )
 
+// ErrUnsupportedPlatform is returned by every function which needs the
+// Windows Event Log when gowinlog is built for another platform. Filters,
+// selectors and the other event processing types work everywhere.
+var ErrUnsupportedPlatform = errors.New("The Windows Event Log is not supported on this platform")
+
 // Stores the common fields from a log event
 type WinLogEvent struct {
 	// XML
@@ -28,8 +31,28 @@ type WinLogEvent struct {
 	Channel           string
 	ComputerName      string
 	Version           uint64
+	KeywordsMask      uint64
 	RenderedFieldsErr error
 
+	// From the <Correlation> element of the XML: the GUIDs of the activity
+	// the event belongs to and of the activity which started it, in
+	// braces, or "" if the provider didn't set them
+	ActivityId        string
+	RelatedActivityId string
+
+	// From the <Security> element of the XML: the SID of the user the
+	// event was logged for, or "" if it has none
+	UserSid string
+
+	// Whether an audited action succeeded or failed, derived from the
+	// keywords and level by DeriveOutcome: OutcomeSuccess, OutcomeFailure
+	// or "" if the event doesn't say
+	Outcome string
+
+	// How serious the event is on a scale shared by every provider, from
+	// the watcher's SeverityMap, or DefaultSeverity if it has none
+	Severity Severity
+
 	// From EvtFormatMessage
 	Msg                string
 	LevelText          string
@@ -41,6 +64,53 @@ type WinLogEvent struct {
 	IdText             string
 	PublisherHandleErr error
 
+	// The message and level formatted in each of the watcher's
+	// RenderLocales, keyed by locale. Locales the provider has no
+	// resources for are left out.
+	LocalizedMsg       map[string]string `json:",omitempty"`
+	LocalizedLevelText map[string]string `json:",omitempty"`
+
+	// From the <EventData> element of the XML
+	EventData    map[string]string
+	EventDataErr error
+	// Template parameter names EventData's unnamed <Data> elements were
+	// given, by position, if the watcher's NameEventData is set
+	dataNames []string
+
+	// From the <Binary> element of the XML, which classic providers log
+	// after the insertion strings, or nil if the event has none. Left out
+	// of JSON: the watcher's BinaryFormat adds it to EventData instead.
+	Binary    []byte `json:"-"`
+	BinaryErr error
+
+	// From the <UserData> element of the XML, which providers use instead
+	// of <EventData> for structured payloads, keyed by element name
+	UserData map[string]string
+
+	// Fields the watcher's Extractor found in the formatted message, keyed
+	// by the names of its rules' capture groups, or nil if it found none
+	Extracted map[string]string `json:",omitempty"`
+
+	// Fields added by the watcher's enrichers, and their failures, if any
+	Enriched  map[string]string `json:",omitempty"`
+	EnrichErr error
+
+	// Whether the watcher's Truncation policy cut any of the event's
+	// fields, and which: "Msg", "Xml", or "EventData.", "UserData." or
+	// "Extracted." followed by the value's name
+	Truncated       bool
+	TruncatedFields []string
+
+	// If the watcher's RetainRawRender is set, the buffers EvtRender
+	// returned for the event, for archiving byte for byte. Left out of
+	// JSON, and removed by a Redactor, as they hold the unredacted values.
+	Raw    *RenderedEvent `json:"-"`
+	RawErr error
+
+	// When the watcher received the event from the event log and started
+	// rendering it, or the zero time for events it didn't subscribe to
+	ReceivedAt time.Time
+
 	// Serialied XML bookmark to
 	// restart at this event
 	Bookmark string
@@ -48,28 +118,129 @@ type WinLogEvent struct {
 	// Subscribed channel from which the event was retrieved,
 	// which may be different than the event's channel
 	SubscribedChannel string
+
+	// For events read from a collector channel, such as ForwardedEvents:
+	// the computer and channel the event was logged on, which are also in
+	// ComputerName and Channel, and the collector which read it and when.
+	// The event log doesn't record when an event was forwarded, so
+	// CollectedTime is when the watcher read it.
+	Forwarded      bool
+	SourceComputer string
+	SourceChannel  string
+	Collector      string
+	CollectedTime  time.Time
+
+	// The remote session the event was read through, if the watcher uses
+	// a RemoteAPI
+	Remote *RemoteSource
+
+	// The computer the watcher runs on, if the watcher's Host or
+	// EnrichHost is set. Shared between events, so mustn't be modified.
+	Host *HostInfo
+
+	// If sampling is enabled, the event stands for this many events
+	SampleRate uint64
+
+	// For duplicate suppression summaries, the number of repeats of this
+	// event which were suppressed
+	SuppressedCount uint64
+
+	// Assigned by the watcher to each delivered event, starting at 1 when
+	// the watcher is created. Events dropped by rate limiting or load
+	// shedding also use a number, so a gap means events were lost, and a
+	// lower number than the last one seen means the watcher was restarted.
+	// Filtered events don't use a number.
+	Sequence uint64
+
+	// If the watcher has a HashChain, the ChainHash of the event delivered
+	// before this one and this event's own
+	PrevChainHash string
+	ChainHash     string
+
+	// Number of failed EvtFormatMessage calls, for stats
+	formatErrors int
 }
 
 type channelWatcher struct {
-	subscription ListenerHandle
-	callback     *LogEventCallbackWrapper
-	bookmark     BookmarkHandle
+	// Queries over MaxQueryExpressions are split across several
+	// subscriptions, each with a bookmark in splits. The bookmark is then
+	// only where they're resubscribed from.
+	subscriptions []ListenerHandle
+	query         string
+	flags         EVT_SUBSCRIBE_FLAGS
+	// Events created before this are suppressed, if it's set
+	since         time.Time
+	bookmark      BookmarkHandle
+	splits        []splitBookmark
+	bookmarkMutex sync.Mutex
+	// Whether the bookmark has been updated with any event yet, or for a
+	// split query, whether all of the splits have a position
+	bookmarked bool
+	// The bookmark XML last published with an event, for reporting the
+	// subscription's position without rendering its bookmark
+	lastBookmark string
+	// For a split query, the channel's newest RecordId when idle splits
+	// were last advanced
+	splitHead uint64
+	filter    EventFilter
+
+	rateLimit *RateLimit
+	bucket    *tokenBucket
+	paused    bool
+
+	stats *subscriptionStats
+	// Events waiting to be delivered, if ChannelBufferSize is set
+	queue chan *WinLogEvent
+	// The subscription's own render workers, or nil to use the watcher's
+	workers renderPool
+	// Goroutines reading the subscriptions, if they're pull subscriptions
+	pulls *sync.WaitGroup
 }
 
 // Watches one or more event log channels
 // and publishes events and errors to Go
 // channels
 type WinLogWatcher struct {
+	// Last sequence number used, and in Unix nanoseconds, when the
+	// consumer last read an event, and when the event waiting to be sent to
+	// it was received and first offered. Updated atomically, so must be
+	// first for 64-bit alignment on 32-bit platforms.
+	sequence     uint64
+	lastRead     int64
+	pendingSince int64
+	offeredAt    int64
+	// Notifications discarded because the buffer was full. Updated
+	// atomically.
+	notificationsDiscarded uint64
+
 	errChan   chan error
 	eventChan chan *WinLogEvent
 
+	notifyChan     chan Notification
+	notifyMutex    sync.Mutex
+	notifyClosed   bool
+	dropNotifyOnce sync.Once
+	// How often dropped events are reported, defaultDropNotificationInterval
+	// if zero. Shortened by tests.
+	dropNotificationInterval time.Duration
+
+	api           EventLogAPI
 	renderContext SysRenderContext
 	watches       map[string]*channelWatcher
 	watchMutex    sync.Mutex
 	shutdown      chan interface{}
 
+	// Held while assigning a sequence number and sending the event, so
+	// events are received in sequence
+	deliverMutex sync.Mutex
+
+	lastErrorMutex sync.Mutex
+	lastError      error
+	lastErrorTime  time.Time
+
 	// Optionally render localized fields. EvtFormatMessage() is slow, so
-	// skipping these fields provides a big speedup.
+	// skipping these fields provides a big speedup. Set together by
+	// WatcherOptions.Render.
 	RenderKeywords bool
 	RenderMessage  bool
 	RenderLevel    bool
@@ -78,6 +249,224 @@ type WinLogWatcher struct {
 	RenderOpcode   bool
 	RenderChannel  bool
 	RenderId       bool
+
+	// Locales to format localized fields in, such as "en-US", in order of
+	// preference. If a provider has no resources for one, or formatting in
+	// it gives no text, the next is tried. An empty string is the
+	// computer's default locale, which is used if Locales is empty.
+	Locales []string
+
+	// Also format the message and level, if RenderMessage and RenderLevel
+	// are set, in each of these locales, into the LocalizedMsg and
+	// LocalizedLevelText of events, such as both the local language and
+	// "en-US". Locales aren't fallen back on.
+	RenderLocales []string
+
+	// Name <Data> elements without a Name attribute, as classic providers
+	// write them, after the parameters of the provider's event template,
+	// rather than Data0, Data1 and so on. Templates are read once per
+	// provider.
+	NameEventData bool
+	templates     templateCache
+
+	// How each event's Binary data is represented in its EventData, under
+	// "Binary": BinaryOmit (the default), BinaryHex or BinaryBase64. Data
+	// larger than MaxBinarySize bytes, if set, is omitted.
+	BinaryFormat  BinaryFormat
+	MaxBinarySize int
+
+	// Keep the buffers EvtRender returned in each event's Raw, so they can
+	// be archived and decoded again later. The event is rendered a second
+	// time to get them. They can't be redacted, so the Redactor removes
+	// them.
+	RetainRawRender bool
+
+	// Render the level, task, opcode and keywords from tables of the names
+	// each provider declares, read from its metadata the first time it's
+	// seen, rather than calling EvtFormatMessage for each event. Values a
+	// provider doesn't declare, such as the standard levels, are formatted
+	// once and remembered.
+	UsePublisherTables bool
+	publisherTables    publisherTableCache
+
+	// Optionally only publish events matching a filter expression
+	Filter *Filter
+	// Filters applied only to the events of the subscriptions on some
+	// channels, keyed by name and matched case-insensitively, in addition
+	// to Filter. It's read when subscribing, so the filter applies from the
+	// subscription's first event, and SetSubscriptionFilter changes it
+	// afterwards.
+	SubscriptionFilters map[string]EventFilter
+
+	// Optionally mask or drop sensitive values before events are published
+	Redactor *Redactor
+
+	// Optionally assign events' Severity by provider-specific rules instead
+	// of DefaultSeverity
+	SeverityMap *SeverityMap
+
+	// Optionally extract fields from the formatted message into
+	// Extracted. Applied after the event is rendered, before truncation
+	// and filtering.
+	Extractor *Extractor
+
+	// Time the enrichers added with AddEnricher may take for each event,
+	// DefaultEnrichBudget if zero
+	EnrichBudget time.Duration
+	enrichMutex  sync.RWMutex
+	enrichers    []Enricher
+
+	// Optionally limit the size of events' large fields. Applied after the
+	// event is rendered, before filtering.
+	Truncation *TruncationPolicy
+
+	// Optionally discard events which are too old by the time they'd be
+	// delivered
+	Expiry *ExpiryPolicy
+
+	// Optionally keep only a sample of events
+	Sampler *Sampler
+
+	// Optionally drop low-severity events when the consumer falls behind
+	LoadShedder *LoadShedder
+
+	// Optionally receive internal diagnostics
+	Logger Logger
+
+	// How often to check the newest record in each subscribed channel, to
+	// report bookmark lag in Stats and to advance the bookmarks of split
+	// queries' idle subscriptions. Disabled if zero.
+	BookmarkLagInterval time.Duration
+	lagOnce             sync.Once
+
+	// Warn when a subscribed channel is expected to lose events before
+	// they're read within this long, either because it will wrap over
+	// records the subscription hasn't reached or, if it retains events,
+	// because it will be full. Checked every BookmarkLagInterval, which
+	// must be set, for subscriptions whose bookmark lag is tracked.
+	// Disabled if zero.
+	CapacityWarningHorizon time.Duration
+
+	// Report the TopSources (default 10) providers and event IDs by volume
+	// over the last TopSourcesWindow in Stats. Counts include events which
+	// are later filtered, to help find sources worth suppressing. Disabled
+	// if zero. Must be set before subscribing.
+	TopSourcesWindow time.Duration
+	TopSources       int
+
+	// Report how far the TimeCreated of events from each computer is from
+	// when they're received in Stats, warning when a computer's clock is
+	// skewed by more than this. Disabled if zero. Must be set before
+	// subscribing.
+	ClockSkewThreshold time.Duration
+
+	// Warn when the consumer hasn't taken an event offered on the Event
+	// channel for longer than this while at least SlowConsumerWatermark
+	// (default 1) events are waiting to be delivered, publishing a
+	// *SlowConsumer on the Error channel and reporting it in Health.
+	// Disabled if zero. Must be set before subscribing.
+	SlowConsumerThreshold time.Duration
+	SlowConsumerWatermark int64
+	slowOnce              sync.Once
+	slowMutex             sync.Mutex
+	slowConsumer          *SlowConsumer
+
+	// Optionally suppress repeats of identical events
+	Deduplicator *Deduplicator
+	dedupeOnce   sync.Once
+
+	// Optionally deliver events from all subscriptions in creation order
+	Reorderer   *Reorderer
+	reorderOnce sync.Once
+
+	// If set, each subscription queues up to this many events to be sent to
+	// the Event channel by its own goroutine, so a busy channel doesn't
+	// delay events from the others. Otherwise, or with a Reorderer, events
+	// are sent by the event log's callback. Must be set before subscribing.
+	ChannelBufferSize int
+	drains            sync.WaitGroup
+
+	// How many events may be rendered and formatted at once by the
+	// subscriptions sharing the watcher's limit, to keep CPU use
+	// predictable. Unlimited if zero. These subscriptions render on the
+	// event log's callback, which is passed each subscription's events one
+	// at a time, so this caps concurrency across subscriptions rather than
+	// within one.
+	// SubscriptionWorkers gives the subscriptions on some channels, keyed by
+	// name, their own workers instead, so a busy channel such as Security
+	// can render that many of its events at once: with more than one, the
+	// subscription reads its events in batches of that size rather than
+	// through a callback, renders each batch on as many goroutines, and
+	// publishes them in the order they were read. It's read when
+	// subscribing, and SetSubscriptionWorkers changes it afterwards. Must be
+	// set before subscribing.
+	RenderWorkers       int
+	SubscriptionWorkers map[string]int
+	sharedWorkers       renderPool
+	workersOnce         sync.Once
+
+	// Bookmarks to resume from when subscribing with Subscribe and
+	// StartAtBookmark without a Bookmark. Consumers save each event's
+	// Bookmark to it once the event has been processed.
+	BookmarkStore BookmarkStore
+
+	// Save the cumulative counters of each subscription to the
+	// BookmarkStore this often, and at shutdown, if the store is a
+	// StatsStore. They're loaded when subscribing, and reported in Stats
+	// along with the records written while the watcher wasn't running.
+	// Disabled if zero. Must be set before subscribing.
+	PersistStatsInterval time.Duration
+	persistOnce          sync.Once
+
+	// Optionally link delivered events into a tamper-evident hash chain
+	HashChain *HashChain
+
+	// Channels, besides ForwardedEvents, which a collector's subscriptions
+	// write forwarded events to. Events subscribed to on these channels are
+	// marked Forwarded.
+	CollectorChannels []string
+	// Name of this computer reported as the Collector of forwarded events.
+	// The host name if empty.
+	CollectorName string
+	hostname      string
+	hostnameOnce  sync.Once
+
+	// Stamp each event with the identity of this computer, which for
+	// forwarded events is the collector: Host, or if it's nil and
+	// EnrichHost is set, as read once by GetHostInfo
+	Host         *HostInfo
+	EnrichHost   bool
+	hostInfo     *HostInfo
+	hostInfoOnce sync.Once
+}
+
+// ChannelInfo describes the records held by a channel or log file
+type ChannelInfo struct {
+	NumberOfRecords uint64
+	// RecordIds of the oldest and newest records in the log. Both are 0 if
+	// the log is empty.
+	OldestRecordId uint64
+	NewestRecordId uint64
+	FileSize       uint64
+	Full           bool
+}
+
+// ChannelConfig is the configuration of a channel, as shown by
+// "wevtutil gl".
+type ChannelConfig struct {
+	Enabled bool
+	// Whether the channel is a classic log, such as Application
+	Classic bool
+	// Security descriptor controlling who can read, write and clear the
+	// channel, in SDDL
+	Access      string
+	LogFilePath string
+	// Maximum size of the log file in bytes
+	MaxSize uint64
+	// Whether the log stops accepting events when it's full, rather than
+	// overwriting the oldest, and whether it's then backed up and cleared
+	Retention  bool
+	AutoBackup bool
 }
 
 type SysRenderContext uint64
@@ -85,6 +474,8 @@ type ListenerHandle uint64
 type PublisherHandle uint64
 type EventHandle uint64
 type BookmarkHandle uint64
+type SessionHandle uint64
+type QueryHandle uint64
 
 type LogEventCallback interface {
 	PublishError(error)
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package winlog
+
+// syncDir does nothing where directories can't be synced. NTFS journals
+// renames along with the rest of its metadata.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,385 @@
+package sysmon
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// Sysmon event IDs
+const (
+	EventProcessCreate              = 1
+	EventFileCreateTime             = 2
+	EventNetworkConnect             = 3
+	EventSysmonStateChange          = 4
+	EventProcessTerminate           = 5
+	EventDriverLoad                 = 6
+	EventImageLoad                  = 7
+	EventCreateRemoteThread         = 8
+	EventRawAccessRead              = 9
+	EventProcessAccess              = 10
+	EventFileCreate                 = 11
+	EventRegistryCreateDelete       = 12
+	EventRegistrySetValue           = 13
+	EventRegistryRename             = 14
+	EventFileCreateStreamHash       = 15
+	EventServiceConfigurationChange = 16
+	EventPipeCreate                 = 17
+	EventPipeConnect                = 18
+	EventWmiEventFilter             = 19
+	EventWmiEventConsumer           = 20
+	EventWmiEventConsumerToFilter   = 21
+	EventDNSQuery                   = 22
+	EventFileDelete                 = 23
+	EventClipboardChange            = 24
+	EventProcessTampering           = 25
+	EventFileDeleteDetected         = 26
+	EventFileBlockExecutable        = 27
+	EventFileBlockShredding         = 28
+	EventFileExecutableDetected     = 29
+	EventError                      = 255
+)
+
+// Constructors for the struct of each event ID
+var eventTypes = map[uint64]func() Event{
+	EventProcessCreate:              func() Event { return &ProcessCreate{} },
+	EventFileCreateTime:             func() Event { return &FileCreateTime{} },
+	EventNetworkConnect:             func() Event { return &NetworkConnect{} },
+	EventSysmonStateChange:          func() Event { return &SysmonStateChange{} },
+	EventProcessTerminate:           func() Event { return &ProcessTerminate{} },
+	EventDriverLoad:                 func() Event { return &DriverLoad{} },
+	EventImageLoad:                  func() Event { return &ImageLoad{} },
+	EventCreateRemoteThread:         func() Event { return &CreateRemoteThread{} },
+	EventRawAccessRead:              func() Event { return &RawAccessRead{} },
+	EventProcessAccess:              func() Event { return &ProcessAccess{} },
+	EventFileCreate:                 func() Event { return &FileCreate{} },
+	EventRegistryCreateDelete:       func() Event { return &RegistryEvent{} },
+	EventRegistrySetValue:           func() Event { return &RegistryEvent{} },
+	EventRegistryRename:             func() Event { return &RegistryEvent{} },
+	EventFileCreateStreamHash:       func() Event { return &FileCreateStreamHash{} },
+	EventServiceConfigurationChange: func() Event { return &ServiceConfigurationChange{} },
+	EventPipeCreate:                 func() Event { return &PipeEvent{} },
+	EventPipeConnect:                func() Event { return &PipeEvent{} },
+	EventWmiEventFilter:             func() Event { return &WmiEventFilter{} },
+	EventWmiEventConsumer:           func() Event { return &WmiEventConsumer{} },
+	EventWmiEventConsumerToFilter:   func() Event { return &WmiEventConsumerToFilter{} },
+	EventDNSQuery:                   func() Event { return &DNSQuery{} },
+	EventFileDelete:                 func() Event { return &FileDelete{} },
+	EventClipboardChange:            func() Event { return &ClipboardChange{} },
+	EventProcessTampering:           func() Event { return &ProcessTampering{} },
+	EventFileDeleteDetected:         func() Event { return &FileDeleteDetected{} },
+	EventFileBlockExecutable:        func() Event { return &FileBlockExecutable{} },
+	EventFileBlockShredding:         func() Event { return &FileBlockShredding{} },
+	EventFileExecutableDetected:     func() Event { return &FileExecutableDetected{} },
+	EventError:                      func() Event { return &Error{} },
+}
+
+// ProcessCreate is event 1.
+type ProcessCreate struct {
+	Header
+	Process
+	FileVersion       string
+	Description       string
+	Product           string
+	Company           string
+	OriginalFileName  string
+	CommandLine       string
+	CurrentDirectory  string
+	LogonGuid         string
+	LogonId           uint64
+	TerminalSessionId uint64
+	IntegrityLevel    string
+	Hashes            Hashes
+	ParentProcessGuid string
+	ParentProcessId   uint64
+	ParentImage       string
+	ParentCommandLine string
+	ParentUser        string
+}
+
+// FileCreateTime is event 2, a process changing a file's creation time.
+type FileCreateTime struct {
+	Header
+	Process
+	TargetFilename          string
+	CreationUtcTime         time.Time
+	PreviousCreationUtcTime time.Time
+}
+
+// NetworkConnect is event 3, a TCP or UDP connection.
+type NetworkConnect struct {
+	Header
+	Process
+	Protocol            string
+	Initiated           bool
+	SourceIsIpv6        bool
+	SourceIp            net.IP
+	SourceHostname      string
+	SourcePort          uint64
+	SourcePortName      string
+	DestinationIsIpv6   bool
+	DestinationIp       net.IP
+	DestinationHostname string
+	DestinationPort     uint64
+	DestinationPortName string
+}
+
+// SysmonStateChange is event 4, the Sysmon service starting or stopping.
+type SysmonStateChange struct {
+	Header
+	State         string
+	Version       string
+	SchemaVersion string
+}
+
+// ProcessTerminate is event 5.
+type ProcessTerminate struct {
+	Header
+	Process
+}
+
+// DriverLoad is event 6.
+type DriverLoad struct {
+	Header
+	ImageLoaded     string
+	Hashes          Hashes
+	Signed          bool
+	Signature       string
+	SignatureStatus string
+}
+
+// ImageLoad is event 7, a process loading a DLL.
+type ImageLoad struct {
+	Header
+	Process
+	ImageLoaded      string
+	FileVersion      string
+	Description      string
+	Product          string
+	Company          string
+	OriginalFileName string
+	Hashes           Hashes
+	Signed           bool
+	Signature        string
+	SignatureStatus  string
+}
+
+// CreateRemoteThread is event 8, a process starting a thread in another.
+type CreateRemoteThread struct {
+	Header
+	SourceProcessGuid string
+	SourceProcessId   uint64
+	SourceImage       string
+	SourceUser        string
+	TargetProcessGuid string
+	TargetProcessId   uint64
+	TargetImage       string
+	TargetUser        string
+	NewThreadId       uint64
+	StartAddress      uint64
+	StartModule       string
+	StartFunction     string
+}
+
+// RawAccessRead is event 9, a process reading a drive directly.
+type RawAccessRead struct {
+	Header
+	Process
+	Device string
+}
+
+// ProcessAccess is event 10, a process opening another.
+type ProcessAccess struct {
+	Header
+	SourceProcessGuid string `sysmon:"SourceProcessGUID"`
+	SourceProcessId   uint64
+	SourceThreadId    uint64
+	SourceImage       string
+	SourceUser        string
+	TargetProcessGuid string `sysmon:"TargetProcessGUID"`
+	TargetProcessId   uint64
+	TargetImage       string
+	TargetUser        string
+	GrantedAccess     uint64
+	CallTrace         string
+}
+
+// FileCreate is event 11.
+type FileCreate struct {
+	Header
+	Process
+	TargetFilename  string
+	CreationUtcTime time.Time
+}
+
+// RegistryEvent is events 12 (key or value created or deleted), 13 (value
+// set) and 14 (key or value renamed), told apart by EventType, such as
+// "CreateKey" or "SetValue".
+type RegistryEvent struct {
+	Header
+	Process
+	EventType    string
+	TargetObject string
+	// Value written, for event 13
+	Details string
+	// New name, for event 14
+	NewName string
+}
+
+// FileCreateStreamHash is event 15, an alternate data stream being created,
+// such as the Zone.Identifier of a download.
+type FileCreateStreamHash struct {
+	Header
+	Process
+	TargetFilename  string
+	CreationUtcTime time.Time
+	Hash            Hashes
+	Contents        string
+}
+
+// ServiceConfigurationChange is event 16, the Sysmon configuration changing.
+type ServiceConfigurationChange struct {
+	Header
+	Configuration         string
+	ConfigurationFileHash string
+}
+
+// PipeEvent is events 17 (named pipe created) and 18 (named pipe
+// connected), told apart by EventType.
+type PipeEvent struct {
+	Header
+	Process
+	EventType string
+	PipeName  string
+}
+
+// WmiEventFilter is event 19, a WMI event filter being registered.
+type WmiEventFilter struct {
+	Header
+	EventType      string
+	Operation      string
+	User           string
+	EventNamespace string
+	Name           string
+	Query          string
+}
+
+// WmiEventConsumer is event 20, a WMI event consumer being registered.
+type WmiEventConsumer struct {
+	Header
+	EventType   string
+	Operation   string
+	User        string
+	Name        string
+	Type        string
+	Destination string
+}
+
+// WmiEventConsumerToFilter is event 21, a WMI consumer being bound to a
+// filter.
+type WmiEventConsumerToFilter struct {
+	Header
+	EventType string
+	Operation string
+	User      string
+	Consumer  string
+	Filter    string
+}
+
+// DNSQuery is event 22.
+type DNSQuery struct {
+	Header
+	Process
+	QueryName string
+	// DNS status code, 0 for success
+	QueryStatus uint64
+	// Semicolon separated answers, such as
+	// "type:  5 example.net;::ffff:93.184.216.34;"
+	QueryResults string
+}
+
+// Addresses returns the IP addresses in QueryResults. IPv4 addresses, which
+// Sysmon logs mapped into IPv6, are returned in IPv4 form.
+func (q *DNSQuery) Addresses() []net.IP {
+	var addresses []net.IP
+	for _, result := range strings.Split(q.QueryResults, ";") {
+		if ip := net.ParseIP(strings.TrimSpace(result)); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			addresses = append(addresses, ip)
+		}
+	}
+	return addresses
+}
+
+// FileDelete is event 23, a file being deleted and archived.
+type FileDelete struct {
+	Header
+	Process
+	TargetFilename string
+	Hashes         Hashes
+	IsExecutable   bool
+	Archived       bool
+}
+
+// ClipboardChange is event 24.
+type ClipboardChange struct {
+	Header
+	Process
+	Session    uint64
+	ClientInfo string
+	Hashes     Hashes
+	Archived   bool
+}
+
+// ProcessTampering is event 25, a process image being replaced, as by
+// process hollowing.
+type ProcessTampering struct {
+	Header
+	Process
+	Type string
+}
+
+// FileDeleteDetected is event 26, a file being deleted without archiving.
+type FileDeleteDetected struct {
+	Header
+	Process
+	TargetFilename string
+	Hashes         Hashes
+	IsExecutable   bool
+}
+
+// FileBlockExecutable is event 27, Sysmon blocking an executable from being
+// written.
+type FileBlockExecutable struct {
+	Header
+	Process
+	TargetFilename string
+	Hashes         Hashes
+}
+
+// FileBlockShredding is event 28, Sysmon blocking a file from being
+// overwritten by a shredding tool.
+type FileBlockShredding struct {
+	Header
+	Process
+	TargetFilename string
+	Hashes         Hashes
+	IsExecutable   bool
+}
+
+// FileExecutableDetected is event 29, an executable being written.
+type FileExecutableDetected struct {
+	Header
+	Process
+	TargetFilename string
+	Hashes         Hashes
+}
+
+// Error is event 255, an error inside Sysmon.
+type Error struct {
+	Header
+	ID          string
+	Description string
+}
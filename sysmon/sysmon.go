@@ -0,0 +1,178 @@
+// Package sysmon converts Sysmon events into typed structs, one per event ID:
+//
+//	parsed, err := sysmon.Parse(ev)
+//	switch e := parsed.(type) {
+//	case *sysmon.ProcessCreate:
+//		log.Printf("%v started %v (%v)", e.ParentImage, e.CommandLine, e.Hashes["SHA256"])
+//	case *sysmon.NetworkConnect:
+//		log.Printf("%v connected to %v:%v", e.Image, e.DestinationIp, e.DestinationPort)
+//	}
+//
+// Events are read from WinLogEvent.EventData, or parsed from Xml when the
+// watcher didn't fill EventData in. Times are in UTC, as Sysmon logs them.
+package sysmon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider and channel of Sysmon events
+const (
+	ProviderName = "Microsoft-Windows-Sysmon"
+	Channel      = "Microsoft-Windows-Sysmon/Operational"
+)
+
+// Layout of Sysmon's UtcTime fields
+const timeLayout = "2006-01-02 15:04:05.999"
+
+// ErrUnsupportedEvent is returned by Parse for events which aren't from
+// Sysmon or have an event ID this package doesn't know.
+var ErrUnsupportedEvent = errors.New("Not a supported Sysmon event")
+
+// Event is implemented by all the event structs in this package.
+type Event interface {
+	// Common returns the fields shared by all Sysmon events.
+	Common() *Header
+}
+
+// Header holds the fields shared by all Sysmon events.
+type Header struct {
+	Event *winlog.WinLogEvent `sysmon:"-"`
+	// Name of the configuration rule which matched the event
+	RuleName string
+	UtcTime  time.Time
+}
+
+func (h *Header) Common() *Header {
+	return h
+}
+
+// Process identifies the process which caused an event.
+type Process struct {
+	ProcessGuid string
+	ProcessId   uint64
+	Image       string
+	User        string
+}
+
+// Hashes are the file hashes Sysmon was configured to compute, keyed by
+// algorithm: "MD5", "SHA1", "SHA256" or "IMPHASH".
+type Hashes map[string]string
+
+// ParseHashes parses a Hashes field such as "SHA1=...,MD5=...".
+func ParseHashes(s string) Hashes {
+	hashes := make(Hashes)
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			continue
+		}
+		hashes[strings.ToUpper(strings.TrimSpace(pair[:i]))] = strings.TrimSpace(pair[i+1:])
+	}
+	return hashes
+}
+
+// Parse converts a Sysmon event into the struct for its event ID, such as
+// *ProcessCreate for event 1.
+func Parse(ev *winlog.WinLogEvent) (Event, error) {
+	if ev.ProviderName != "" && ev.ProviderName != ProviderName {
+		return nil, ErrUnsupportedEvent
+	}
+	newEvent, ok := eventTypes[ev.EventId]
+	if !ok {
+		return nil, ErrUnsupportedEvent
+	}
+	data := ev.EventData
+	if data == nil && len(ev.Xml) > 0 {
+		var err error
+		if data, err = winlog.ParseEventData(ev.Xml); err != nil {
+			return nil, fmt.Errorf("Failed to parse EventData: %v", err)
+		}
+	}
+	parsed := newEvent()
+	if err := decode(data, reflect.ValueOf(parsed).Elem()); err != nil {
+		return nil, fmt.Errorf("Failed to parse Sysmon event %v: %v", ev.EventId, err)
+	}
+	parsed.Common().Event = ev
+	return parsed, nil
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	ipType     = reflect.TypeOf(net.IP{})
+	hashesType = reflect.TypeOf(Hashes{})
+)
+
+// decode sets each field of the struct `v` from the EventData value of the
+// same name, or the name in its `sysmon` tag. Embedded structs are decoded
+// into recursively. Missing values and "-" leave the zero value.
+func decode(data map[string]string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		if field.Anonymous {
+			if err := decode(data, value); err != nil {
+				return err
+			}
+			continue
+		}
+		name := field.Tag.Get("sysmon")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		raw, ok := data[name]
+		raw = strings.TrimSpace(raw)
+		if !ok || raw == "-" || raw == "" {
+			continue
+		}
+
+		var err error
+		switch field.Type {
+		case timeType:
+			var parsed time.Time
+			if parsed, err = time.Parse(timeLayout, raw); err == nil {
+				value.Set(reflect.ValueOf(parsed))
+			}
+		case ipType:
+			if ip := net.ParseIP(raw); ip != nil {
+				value.Set(reflect.ValueOf(ip))
+			} else {
+				err = errors.New("Invalid IP address")
+			}
+		case hashesType:
+			value.Set(reflect.ValueOf(ParseHashes(raw)))
+		default:
+			switch field.Type.Kind() {
+			case reflect.String:
+				value.SetString(raw)
+			case reflect.Uint64:
+				var n uint64
+				if n, err = strconv.ParseUint(raw, 0, 64); err == nil {
+					value.SetUint(n)
+				}
+			case reflect.Bool:
+				var b bool
+				if b, err = strconv.ParseBool(raw); err == nil {
+					value.SetBool(b)
+				}
+			default:
+				panic(fmt.Sprintf("Unsupported Sysmon field type %v", field.Type))
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to parse %v %q: %v", name, raw, err)
+		}
+	}
+	return nil
+}
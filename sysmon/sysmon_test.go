@@ -0,0 +1,114 @@
+package sysmon
+
+import (
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+// parseFixture parses testdata/<id>.xml.
+func parseFixture(t *T, id uint64) Event {
+	t.Helper()
+	ev := testutil.EventFixture(t, id)
+	parsed, err := Parse(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(parsed.Common().Event, ev, t)
+	return parsed
+}
+
+func TestProcessCreate(t *T) {
+	e := parseFixture(t, EventProcessCreate).(*ProcessCreate)
+	testutil.AssertEqual(e.RuleName, "technique_id=T1059.001,technique_name=PowerShell", t)
+	testutil.AssertEqual(e.UtcTime, time.Date(2021, 3, 4, 5, 6, 7, 123e6, time.UTC), t)
+	testutil.AssertEqual(e.ProcessGuid, "{a1b2c3d4-5e6f-6040-2a01-000000000d00}", t)
+	testutil.AssertEqual(e.ProcessId, uint64(7044), t)
+	testutil.AssertEqual(e.User, `CORP\alice`, t)
+	testutil.AssertEqual(e.CommandLine, "powershell.exe -NoProfile -EncodedCommand SQBFAFgA", t)
+	testutil.AssertEqual(e.LogonId, uint64(0x1a2b3c), t)
+	testutil.AssertEqual(e.IntegrityLevel, "Medium", t)
+	testutil.AssertEqual(e.Hashes["SHA256"], "DE96A6E69944335375DC1AC238336066889D9FFC7D73628EF4FE1B1B160AB32C", t)
+	testutil.AssertEqual(e.Hashes["IMPHASH"], "741776AACCFC5B71FF59832DCDCACE0F", t)
+	testutil.AssertEqual(len(e.Hashes), 4, t)
+	testutil.AssertEqual(e.ParentProcessId, uint64(5520), t)
+	testutil.AssertEqual(e.ParentImage, `C:\Windows\explorer.exe`, t)
+}
+
+func TestNetworkConnect(t *T) {
+	e := parseFixture(t, EventNetworkConnect).(*NetworkConnect)
+	testutil.AssertEqual(e.RuleName, "", t)
+	testutil.AssertEqual(e.Protocol, "tcp", t)
+	testutil.AssertEqual(e.Initiated, true, t)
+	testutil.AssertEqual(e.SourceIp.String(), "192.168.10.25", t)
+	testutil.AssertEqual(e.DestinationIp.String(), "93.184.216.34", t)
+	testutil.AssertEqual(e.DestinationHostname, "", t)
+	testutil.AssertEqual(e.DestinationPort, uint64(443), t)
+	testutil.AssertEqual(e.DestinationPortName, "https", t)
+}
+
+func TestProcessAccess(t *T) {
+	e := parseFixture(t, EventProcessAccess).(*ProcessAccess)
+	testutil.AssertEqual(e.SourceProcessGuid, "{a1b2c3d4-5e6f-6040-2a01-000000000d00}", t)
+	testutil.AssertEqual(e.TargetImage, `C:\Windows\system32\lsass.exe`, t)
+	testutil.AssertEqual(e.GrantedAccess, uint64(0x1010), t)
+	testutil.AssertEqual(e.TargetUser, `NT AUTHORITY\SYSTEM`, t)
+}
+
+func TestRegistryEvent(t *T) {
+	e := parseFixture(t, EventRegistrySetValue).(*RegistryEvent)
+	testutil.AssertEqual(e.EventType, "SetValue", t)
+	testutil.AssertEqual(e.Details, `C:\Users\alice\AppData\Local\Temp\updater.exe`, t)
+	testutil.AssertEqual(e.NewName, "", t)
+}
+
+func TestDNSQuery(t *T) {
+	e := parseFixture(t, EventDNSQuery).(*DNSQuery)
+	testutil.AssertEqual(e.QueryName, "www.example.com", t)
+	testutil.AssertEqual(e.QueryStatus, uint64(0), t)
+	addresses := e.Addresses()
+	testutil.AssertEqual(len(addresses), 2, t)
+	testutil.AssertEqual(addresses[0].String(), "93.184.216.34", t)
+	testutil.AssertEqual(len(addresses[0]), 4, t)
+	testutil.AssertEqual(addresses[1].String(), "2606:2800:220:1:248:1893:25c8:1946", t)
+}
+
+func TestParse(t *T) {
+	// Every event ID has a struct the decoder can fill
+	for id := range eventTypes {
+		ev := &winlog.WinLogEvent{EventId: id, EventData: map[string]string{"UtcTime": "2021-03-04 05:06:07.123", "ProcessId": "1"}}
+		parsed, err := Parse(ev)
+		if err != nil {
+			t.Fatalf("Event %v: %v", id, err)
+		}
+		testutil.AssertEqual(parsed.Common().UtcTime.Year(), 2021, t)
+	}
+
+	// Without EventData, the XML is parsed
+	xml := testutil.EventFixture(t, EventNetworkConnect).Xml
+	parsed, err := Parse(&winlog.WinLogEvent{EventId: EventNetworkConnect, Xml: xml})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(parsed.(*NetworkConnect).DestinationPort, uint64(443), t)
+
+	if _, err := Parse(&winlog.WinLogEvent{ProviderName: ProviderName, EventId: 30}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+	if _, err := Parse(&winlog.WinLogEvent{ProviderName: "Microsoft-Windows-Security-Auditing", EventId: 1}); err != ErrUnsupportedEvent {
+		t.Fatalf("Expected ErrUnsupportedEvent, got %v", err)
+	}
+	if _, err := Parse(&winlog.WinLogEvent{EventId: EventNetworkConnect, EventData: map[string]string{"DestinationPort": "https"}}); err == nil {
+		t.Fatal("Expected an error for a malformed port")
+	}
+}
+
+func TestParseHashes(t *T) {
+	hashes := ParseHashes("SHA1=AB,md5=CD, SHA256=EF")
+	testutil.AssertEqual(hashes["SHA1"], "AB", t)
+	testutil.AssertEqual(hashes["MD5"], "CD", t)
+	testutil.AssertEqual(hashes["SHA256"], "EF", t)
+	testutil.AssertEqual(len(ParseHashes("Unknown")), 0, t)
+}
@@ -0,0 +1,168 @@
+package winlog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TemplateKey identifies an event defined by a provider. Versions of an
+// event may have different templates.
+type TemplateKey struct {
+	EventId uint64
+	Version uint64
+}
+
+// EventTemplates holds the parameters of a provider's events, in the order
+// their values appear in <EventData>.
+type EventTemplates map[TemplateKey][]TemplateField
+
+// TemplateField is a parameter declared in an event template.
+type TemplateField struct {
+	Name string
+	// Types from the manifest, such as "win:UInt32" and "win:HexInt32".
+	// Empty for structs.
+	InType  string
+	OutType string
+	// For arrays, the fixed number of elements or the name of the parameter
+	// holding it
+	Count string
+	// For strings and binary data, the fixed length or the name of the
+	// parameter holding it
+	Length string
+	// For structs, their members
+	Fields []TemplateField
+}
+
+// Names returns the parameter names of an event, or nil if the provider
+// doesn't define a template for it. Only the low 16 bits of the event ID are
+// compared, since classic providers include the qualifiers.
+func (t EventTemplates) Names(eventId, version uint64) []string {
+	fields := t[TemplateKey{EventId: eventId & 0xffff, Version: version}]
+	if fields == nil {
+		return nil
+	}
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	return names
+}
+
+type templateItemXml struct {
+	XMLName xml.Name
+	Name    string            `xml:"name,attr"`
+	InType  string            `xml:"inType,attr"`
+	OutType string            `xml:"outType,attr"`
+	Count   string            `xml:"count,attr"`
+	Length  string            `xml:"length,attr"`
+	Items   []templateItemXml `xml:",any"`
+}
+
+// ParseEventTemplate reads the parameters from an event template, as
+// returned for EventMetadataEventTemplate:
+//
+//	<template xmlns="http://schemas.microsoft.com/win/2004/08/events">
+//	  <data name="ServiceName" inType="win:UnicodeString" outType="xs:string"/>
+//	</template>
+//
+// Each top-level <data> and <struct> element is one parameter.
+func ParseEventTemplate(template string) ([]TemplateField, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, nil
+	}
+	var parsed templateItemXml
+	if err := xml.Unmarshal([]byte(template), &parsed); err != nil {
+		return nil, fmt.Errorf("Failed to parse event template: %v", err)
+	}
+	return templateFields(parsed.Items), nil
+}
+
+func templateFields(items []templateItemXml) []TemplateField {
+	var fields []TemplateField
+	for _, item := range items {
+		switch item.XMLName.Local {
+		case "data", "struct":
+			fields = append(fields, TemplateField{
+				Name:    item.Name,
+				InType:  item.InType,
+				OutType: item.OutType,
+				Count:   item.Count,
+				Length:  item.Length,
+				Fields:  templateFields(item.Items),
+			})
+		}
+	}
+	return fields
+}
+
+// eventDataNamed is eventData, with the <Data> elements which have no Name
+// attribute given the parameter name at the same position in `names`.
+func (parsed *eventDataXml) eventDataNamed(names []string) map[string]string {
+	if len(parsed.Data) == 0 {
+		return nil
+	}
+	data := make(map[string]string, len(parsed.Data))
+	for i, key := range parsed.dataKeys(names) {
+		data[key] = parsed.Data[i].Value
+	}
+	return data
+}
+
+// dataKeys returns the EventData key of each <Data> element, in order: its
+// Name, the parameter name at the same position in `names`, or "Data" and
+// its position.
+func (parsed *eventDataXml) dataKeys(names []string) []string {
+	keys := make([]string, len(parsed.Data))
+	for i, d := range parsed.Data {
+		keys[i] = dataKey(d.Name, i, names)
+	}
+	return keys
+}
+
+func dataKey(name string, i int, names []string) string {
+	if name == "" && i < len(names) && names[i] != "" {
+		name = names[i]
+	}
+	if name == "" {
+		name = fmt.Sprintf("Data%d", i)
+	}
+	return name
+}
+
+// unnamedData reports whether any <Data> element has no Name attribute.
+func (parsed *eventDataXml) unnamedData() bool {
+	for _, d := range parsed.Data {
+		if d.Name == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// templateCache remembers each provider's templates, including failures to
+// read them, so they're only looked up once. The error is only returned
+// the first time.
+type templateCache struct {
+	mutex      sync.Mutex
+	byProvider map[string]EventTemplates
+}
+
+func (c *templateCache) names(api EventLogAPI, providerName string, eventId, version uint64) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	templates, ok := c.byProvider[providerName]
+	if !ok {
+		var err error
+		templates, err = api.EventTemplates(providerName)
+		if c.byProvider == nil {
+			c.byProvider = make(map[string]EventTemplates)
+		}
+		c.byProvider[providerName] = templates
+		if err != nil {
+			return nil, err
+		}
+	}
+	return templates.Names(eventId, version), nil
+}
@@ -0,0 +1,60 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+const serviceTemplate = `<template xmlns="http://schemas.microsoft.com/win/2004/08/events">
+  <data name="param1" inType="win:UnicodeString" outType="xs:string"/>
+  <data name="param2" inType="win:UnicodeString" outType="xs:string"/>
+  <struct name="Extra" count="1">
+    <data name="Nested" inType="win:UInt32" outType="xs:unsignedInt"/>
+  </struct>
+  <binary name="Binary"/>
+</template>`
+
+func TestParseEventTemplate(t *T) {
+	fields, err := ParseEventTemplate(serviceTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(fields), 3, t)
+	assertEqual(fields[0].Name, "param1", t)
+	assertEqual(fields[0].InType, "win:UnicodeString", t)
+	assertEqual(fields[0].OutType, "xs:string", t)
+	assertEqual(fields[2].Name, "Extra", t)
+	assertEqual(fields[2].Count, "1", t)
+	assertEqual(len(fields[2].Fields), 1, t)
+	assertEqual(fields[2].Fields[0].InType, "win:UInt32", t)
+
+	fields, err = ParseEventTemplate("")
+	assertEqual(err, nil, t)
+	assertEqual(fields == nil, true, t)
+	if _, err := ParseEventTemplate("<template><data"); err == nil {
+		t.Fatal("Expected an error for a truncated template")
+	}
+}
+
+func TestEventTemplatesNames(t *T) {
+	templates := EventTemplates{{EventId: 7036, Version: 0}: {{Name: "param1"}, {Name: "param2"}}}
+	// Classic event IDs include the qualifiers
+	assertEqual(len(templates.Names(0x40001b7c, 0)), 2, t)
+	assertEqual(templates.Names(7036, 1) == nil, true, t)
+	assertEqual(EventTemplates(nil).Names(7036, 0) == nil, true, t)
+}
+
+func TestEventDataNamed(t *T) {
+	parsed, err := parseEventXml([]byte(`<Event><EventData><Data>a</Data><Data Name='Keep'>b</Data><Data>c</Data><Data>d</Data></EventData></Event>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(parsed.unnamedData(), true, t)
+	data := parsed.eventDataNamed([]string{"First", "Second", ""})
+	assertEqual(len(data), 4, t)
+	assertEqual(data["First"], "a", t)
+	assertEqual(data["Keep"], "b", t)
+	// Without a name from the template, positional names are used
+	assertEqual(data["Data2"], "c", t)
+	assertEqual(data["Data3"], "d", t)
+	assertEqual(parsed.eventData()["Data0"], "a", t)
+}
@@ -0,0 +1,100 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// GetEventTemplates reads the parameters of the events defined by a
+// provider. Providers without an instrumentation manifest return no
+// templates. Wraps EvtOpenEventMetadataEnum, EvtNextEventMetadata and
+// EvtGetEventMetadataProperty.
+func GetEventTemplates(providerName string) (EventTemplates, error) {
+	return GetSessionEventTemplates(0, providerName)
+}
+
+// GetSessionEventTemplates reads the parameters of the events defined by a
+// provider on the computer of a session opened with OpenSession.
+func GetSessionEventTemplates(session SessionHandle, providerName string) (EventTemplates, error) {
+	publisher, err := OpenSessionPublisherMetadata(session, providerName, "")
+	if err != nil {
+		return nil, err
+	}
+	defer EvtClose(syscall.Handle(publisher))
+	enum, err := EvtOpenEventMetadataEnum(syscall.Handle(publisher), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer EvtClose(enum)
+
+	templates := make(EventTemplates)
+	buf := make([]byte, 4096)
+	for {
+		metadata, err := EvtNextEventMetadata(enum, 0)
+		if errors.Is(err, windows.ERROR_NO_MORE_ITEMS) {
+			return templates, nil
+		} else if err != nil {
+			return nil, err
+		}
+		property := func(id uint32) (EvtVariant, error) {
+			var used uint32
+			err := EvtGetEventMetadataProperty(metadata, id, 0, uint32(len(buf)), &buf[0], &used)
+			if errors.Is(err, windows.ERROR_INSUFFICIENT_BUFFER) {
+				buf = make([]byte, used)
+				err = EvtGetEventMetadataProperty(metadata, id, 0, uint32(len(buf)), &buf[0], &used)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return NewEvtVariant(buf[:used]), nil
+		}
+		key, fields, err := eventTemplate(property)
+		EvtClose(metadata)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			templates[key] = fields
+		}
+	}
+}
+
+func eventTemplate(property func(id uint32) (EvtVariant, error)) (TemplateKey, []TemplateField, error) {
+	var key TemplateKey
+	value, err := property(EventMetadataEventID)
+	if err != nil {
+		return key, nil, err
+	}
+	if key.EventId, err = value.Uint(0); err != nil {
+		return key, nil, err
+	}
+	key.EventId &= 0xffff
+	if value, err = property(EventMetadataEventVersion); err != nil {
+		return key, nil, err
+	}
+	if key.Version, err = value.Uint(0); err != nil {
+		return key, nil, err
+	}
+	if value, err = property(EventMetadataEventTemplate); err != nil {
+		return key, nil, err
+	}
+	// Events without parameters have a null template
+	if value.IsNull(0) {
+		return key, nil, nil
+	}
+	template, err := value.String(0)
+	if err != nil {
+		return key, nil, err
+	}
+	fields, err := ParseEventTemplate(template)
+	if err != nil {
+		return key, nil, fmt.Errorf("Event %v version %v: %v", key.EventId, key.Version, err)
+	}
+	return key, fields, nil
+}
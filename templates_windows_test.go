@@ -0,0 +1,24 @@
+package winlog
+
+import (
+	. "testing"
+)
+
+func TestGetEventTemplates(t *T) {
+	templates, err := GetEventTemplates("Microsoft-Windows-Security-Auditing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for key, fields := range templates {
+		if key.EventId != 4624 {
+			continue
+		}
+		for _, field := range fields {
+			found = found || (field.Name == "TargetUserName" && field.InType == "win:UnicodeString")
+		}
+	}
+	if !found {
+		t.Fatal("No 4624 template with TargetUserName")
+	}
+}
@@ -0,0 +1,573 @@
+//go:build ignore
+// +build ignore
+
+// mkevtx writes the .evtx fixtures in this directory from the sanitised event
+// XML in ../xml, along with the golden files TestEvtxFixtures compares them
+// to. Run it from the repository root with
+//
+//	go run testdata/evtx/mkevtx.go
+//
+// Each event is written as a record whose binary XML is a template instance,
+// as the event log service writes them, with the System values typed as they
+// are in exported logs. Records are numbered consecutively from the
+// EventRecordID of the first event in each file, so the records of a file
+// may not keep the EventRecordID of their XML fixture.
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// The XML fixtures each .evtx file is made of, in record order
+var fixtures = map[string][]string{
+	"powershell": {"powershell-4104.xml", "powershell-4103.xml"},
+	"security":   {"security-4688.xml", "security-1102.xml"},
+	"sysmon":     {"sysmon-1.xml", "sysmon-3.xml"},
+}
+
+const (
+	fileHeaderSize  = 4096
+	chunkSize       = 65536
+	chunkHeaderSize = 512
+)
+
+// Binary XML tokens
+const (
+	tokenEndOfStream       = 0x00
+	tokenOpenStartElement  = 0x01
+	tokenCloseStartElement = 0x02
+	tokenCloseEmptyElement = 0x03
+	tokenEndElement        = 0x04
+	tokenValue             = 0x05
+	tokenAttribute         = 0x06
+	tokenTemplateInstance  = 0x0c
+	tokenSubstitution      = 0x0d
+	tokenOptional          = 0x0e
+	tokenFragmentHeader    = 0x0f
+	tokenMore              = 0x40
+)
+
+// Value types
+const (
+	typeNull     = 0x00
+	typeString   = 0x01
+	typeUInt8    = 0x04
+	typeUInt16   = 0x06
+	typeUInt32   = 0x08
+	typeUInt64   = 0x0a
+	typeGuid     = 0x0f
+	typeFileTime = 0x11
+	typeSid      = 0x13
+	typeHexInt64 = 0x15
+)
+
+// Types of the System values, by element or element@attribute
+var systemTypes = map[string]byte{
+	"Provider@Name":                 typeString,
+	"Provider@Guid":                 typeGuid,
+	"Provider@EventSourceName":      typeString,
+	"EventID":                       typeUInt16,
+	"EventID@Qualifiers":            typeUInt16,
+	"Version":                       typeUInt8,
+	"Level":                         typeUInt8,
+	"Task":                          typeUInt16,
+	"Opcode":                        typeUInt8,
+	"Keywords":                      typeHexInt64,
+	"TimeCreated@SystemTime":        typeFileTime,
+	"EventRecordID":                 typeUInt64,
+	"Correlation@ActivityID":        typeGuid,
+	"Correlation@RelatedActivityID": typeGuid,
+	"Execution@ProcessID":           typeUInt32,
+	"Execution@ThreadID":            typeUInt32,
+	"Channel":                       typeString,
+	"Computer":                      typeString,
+	"Security@UserID":               typeSid,
+}
+
+// The fields of goldenEvent in evtx_test.go
+type goldenEvent struct {
+	ProviderName string
+	EventId      uint64
+	Qualifiers   uint64
+	Level        uint64
+	Task         uint64
+	Opcode       uint64
+	Created      time.Time
+	RecordId     uint64
+	ProcessId    uint64
+	ThreadId     uint64
+	Channel      string
+	ComputerName string
+	Version      uint64
+	EventData    map[string]string
+}
+
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []*node
+	text     string
+	system   bool
+}
+
+func parse(data []byte) (*node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*node
+	var root *node
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			if root != nil {
+				return root, nil
+			}
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: tok.Name.Local}
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: attr.Value})
+				} else {
+					n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: attr.Name.Local}, Value: attr.Value})
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				n.system = parent.system || parent.name == "System"
+				parent.children = append(parent.children, n)
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+		if found := c.child(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (n *node) attr(name string) string {
+	for _, attr := range n.attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+type value struct {
+	typ  byte
+	data []byte
+}
+
+// chunk builds a chunk, tracking offsets from its start as binary XML names
+// and templates are referenced by them
+type chunk struct {
+	buf    bytes.Buffer
+	values []value
+}
+
+func (c *chunk) offset() uint32 {
+	return uint32(c.buf.Len())
+}
+
+func (c *chunk) u8(v byte) { c.buf.WriteByte(v) }
+func (c *chunk) u16(v uint16) {
+	binary.Write(&c.buf, binary.LittleEndian, v)
+}
+func (c *chunk) u32(v uint32) {
+	binary.Write(&c.buf, binary.LittleEndian, v)
+}
+func (c *chunk) u64(v uint64) {
+	binary.Write(&c.buf, binary.LittleEndian, v)
+}
+
+func (c *chunk) patch32(at, v uint32) {
+	binary.LittleEndian.PutUint32(c.buf.Bytes()[at:], v)
+}
+
+func utf16le(s string) []byte {
+	var b bytes.Buffer
+	for _, u := range utf16.Encode([]rune(s)) {
+		binary.Write(&b, binary.LittleEndian, u)
+	}
+	return b.Bytes()
+}
+
+func nameHash(name string) uint16 {
+	var hash uint32
+	for _, u := range utf16.Encode([]rune(name)) {
+		hash = hash*65599 + uint32(u)
+	}
+	return uint16(hash)
+}
+
+// name writes the offset of a name followed by the name itself
+func (c *chunk) name(name string) {
+	c.u32(c.offset() + 4)
+	c.u32(0)
+	c.u16(nameHash(name))
+	c.u16(uint16(len(utf16.Encode([]rune(name)))))
+	c.buf.Write(utf16le(name))
+	c.u16(0)
+}
+
+// substitute writes a substitution of the next template value
+func (c *chunk) substitute(typ byte, s string) error {
+	v, err := encodeValue(typ, s)
+	if err != nil {
+		return err
+	}
+	token := byte(tokenSubstitution)
+	if v.typ == typeNull {
+		token = tokenOptional
+	}
+	c.u8(token)
+	c.u16(uint16(len(c.values)))
+	c.u8(typ)
+	c.values = append(c.values, v)
+	return nil
+}
+
+func (c *chunk) element(n *node) error {
+	token := byte(tokenOpenStartElement)
+	if len(n.attrs) > 0 {
+		token |= tokenMore
+	}
+	c.u8(token)
+	c.u16(0xffff)
+	sizeAt := c.offset()
+	c.u32(0)
+	c.name(n.name)
+	if len(n.attrs) > 0 {
+		listAt := c.offset()
+		c.u32(0)
+		for i, attr := range n.attrs {
+			token := byte(tokenAttribute)
+			if i < len(n.attrs)-1 {
+				token |= tokenMore
+			}
+			c.u8(token)
+			c.name(attr.Name.Local)
+			if attr.Name.Local == "xmlns" {
+				c.u8(tokenValue)
+				c.u8(typeString)
+				c.u16(uint16(len(utf16.Encode([]rune(attr.Value)))))
+				c.buf.Write(utf16le(attr.Value))
+				continue
+			}
+			typ := byte(typeString)
+			if n.system {
+				if t, ok := systemTypes[n.name+"@"+attr.Name.Local]; ok {
+					typ = t
+				}
+			}
+			if err := c.substitute(typ, attr.Value); err != nil {
+				return fmt.Errorf("%v@%v: %v", n.name, attr.Name.Local, err)
+			}
+		}
+		c.patch32(listAt, c.offset()-listAt-4)
+	}
+	switch {
+	case len(n.children) > 0:
+		c.u8(tokenCloseStartElement)
+		for _, child := range n.children {
+			if err := c.element(child); err != nil {
+				return err
+			}
+		}
+		c.u8(tokenEndElement)
+	case n.system && n.text == "":
+		c.u8(tokenCloseEmptyElement)
+	default:
+		c.u8(tokenCloseStartElement)
+		typ := byte(typeString)
+		if n.system {
+			if t, ok := systemTypes[n.name]; ok {
+				typ = t
+			}
+		}
+		if err := c.substitute(typ, n.text); err != nil {
+			return fmt.Errorf("%v: %v", n.name, err)
+		}
+		c.u8(tokenEndElement)
+	}
+	c.patch32(sizeAt, c.offset()-sizeAt-4)
+	return nil
+}
+
+func encodeValue(typ byte, s string) (value, error) {
+	var b bytes.Buffer
+	le := func(v interface{}) { binary.Write(&b, binary.LittleEndian, v) }
+	switch typ {
+	case typeString:
+		if s == "" {
+			return value{typ: typeNull}, nil
+		}
+		b.Write(utf16le(s))
+	case typeUInt8, typeUInt16, typeUInt32, typeUInt64, typeHexInt64:
+		n, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return value{}, err
+		}
+		switch typ {
+		case typeUInt8:
+			le(uint8(n))
+		case typeUInt16:
+			le(uint16(n))
+		case typeUInt32:
+			le(uint32(n))
+		default:
+			le(n)
+		}
+	case typeGuid:
+		var d1 uint32
+		var d2, d3 uint16
+		var d4 [8]byte
+		if _, err := fmt.Sscanf(strings.ToLower(s), "{%08x-%04x-%04x-%02x%02x-%02x%02x%02x%02x%02x%02x}",
+			&d1, &d2, &d3, &d4[0], &d4[1], &d4[2], &d4[3], &d4[4], &d4[5], &d4[6], &d4[7]); err != nil {
+			return value{}, err
+		}
+		le(d1)
+		le(d2)
+		le(d3)
+		b.Write(d4[:])
+	case typeFileTime:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return value{}, err
+		}
+		le(fileTime(t))
+	case typeSid:
+		parts := strings.Split(s, "-")
+		if len(parts) < 3 || parts[0] != "S" {
+			return value{}, fmt.Errorf("Invalid SID %q", s)
+		}
+		revision, _ := strconv.ParseUint(parts[1], 10, 8)
+		authority, _ := strconv.ParseUint(parts[2], 10, 48)
+		b.WriteByte(byte(revision))
+		b.WriteByte(byte(len(parts) - 3))
+		for shift := 40; shift >= 0; shift -= 8 {
+			b.WriteByte(byte(authority >> uint(shift)))
+		}
+		for _, part := range parts[3:] {
+			sub, err := strconv.ParseUint(part, 10, 32)
+			if err != nil {
+				return value{}, err
+			}
+			le(uint32(sub))
+		}
+	default:
+		return value{}, fmt.Errorf("Unsupported type %#x", typ)
+	}
+	return value{typ: typ, data: b.Bytes()}, nil
+}
+
+// 100ns intervals since 1601-01-01
+func fileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+// record writes an event record of the event, returning its golden event
+func (c *chunk) record(recordId uint64, event *node, templateId string) (goldenEvent, error) {
+	id := event.child("EventRecordID")
+	if id == nil {
+		return goldenEvent{}, fmt.Errorf("No EventRecordID")
+	}
+	id.text = strconv.FormatUint(recordId, 10)
+	created, err := time.Parse(time.RFC3339Nano, event.child("TimeCreated").attr("SystemTime"))
+	if err != nil {
+		return goldenEvent{}, err
+	}
+
+	start := c.offset()
+	c.buf.WriteString("**\x00\x00")
+	sizeAt := c.offset()
+	c.u32(0)
+	c.u64(recordId)
+	c.u64(fileTime(created))
+
+	c.u8(tokenFragmentHeader)
+	c.u8(1)
+	c.u8(1)
+	c.u8(0)
+	c.u8(tokenTemplateInstance)
+	c.u8(1)
+	guid := md5.Sum([]byte(templateId))
+	c.buf.Write(guid[:4])
+	c.u32(c.offset() + 4)
+	c.u32(0)
+	c.buf.Write(guid[:])
+	dataSizeAt := c.offset()
+	c.u32(0)
+	c.values = nil
+	c.u8(tokenFragmentHeader)
+	c.u8(1)
+	c.u8(1)
+	c.u8(0)
+	if err := c.element(event); err != nil {
+		return goldenEvent{}, err
+	}
+	c.u8(tokenEndOfStream)
+	c.patch32(dataSizeAt, c.offset()-dataSizeAt-4)
+
+	c.u32(uint32(len(c.values)))
+	for _, v := range c.values {
+		c.u16(uint16(len(v.data)))
+		c.u8(v.typ)
+		c.u8(0)
+	}
+	for _, v := range c.values {
+		c.buf.Write(v.data)
+	}
+	for (c.offset()-start+4)%8 != 0 {
+		c.u8(0)
+	}
+	size := c.offset() - start + 4
+	c.u32(size)
+	c.patch32(sizeAt, size)
+
+	return golden(event, recordId, created), nil
+}
+
+func golden(event *node, recordId uint64, created time.Time) goldenEvent {
+	text := func(name string) string {
+		if n := event.child(name); n != nil {
+			return n.text
+		}
+		return ""
+	}
+	number := func(s string) uint64 {
+		n, _ := strconv.ParseUint(s, 0, 64)
+		return n
+	}
+	g := goldenEvent{
+		ProviderName: event.child("Provider").attr("Name"),
+		EventId:      number(text("EventID")),
+		Qualifiers:   number(event.child("EventID").attr("Qualifiers")),
+		Level:        number(text("Level")),
+		Task:         number(text("Task")),
+		Opcode:       number(text("Opcode")),
+		Created:      created.UTC(),
+		RecordId:     recordId,
+		ProcessId:    number(event.child("Execution").attr("ProcessID")),
+		ThreadId:     number(event.child("Execution").attr("ThreadID")),
+		Channel:      text("Channel"),
+		ComputerName: text("Computer"),
+		Version:      number(text("Version")),
+	}
+	if data := event.child("EventData"); data != nil {
+		g.EventData = make(map[string]string)
+		for _, d := range data.children {
+			g.EventData[d.attr("Name")] = d.text
+		}
+	}
+	return g
+}
+
+func writeLog(name string, files []string) error {
+	var c chunk
+	c.buf.Write(make([]byte, chunkHeaderSize))
+	var events []goldenEvent
+	var firstId, lastId uint64
+	var lastRecord uint32
+	for i, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "xml", file))
+		if err != nil {
+			return err
+		}
+		event, err := parse(data)
+		if err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		if i == 0 {
+			firstId, _ = strconv.ParseUint(event.child("EventRecordID").text, 10, 64)
+		}
+		lastId = firstId + uint64(i)
+		lastRecord = c.offset()
+		g, err := c.record(lastId, event, file)
+		if err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		events = append(events, g)
+	}
+	free := c.offset()
+	if free > chunkSize {
+		return fmt.Errorf("%v doesn't fit in a chunk", name)
+	}
+	chunk := make([]byte, chunkSize)
+	copy(chunk, c.buf.Bytes())
+	copy(chunk, "ElfChnk\x00")
+	le := binary.LittleEndian
+	le.PutUint64(chunk[8:], firstId)
+	le.PutUint64(chunk[16:], lastId)
+	le.PutUint64(chunk[24:], firstId)
+	le.PutUint64(chunk[32:], lastId)
+	le.PutUint32(chunk[40:], 128)
+	le.PutUint32(chunk[44:], lastRecord)
+	le.PutUint32(chunk[48:], free)
+	le.PutUint32(chunk[52:], crc32.ChecksumIEEE(chunk[chunkHeaderSize:free]))
+	headerSum := crc32.NewIEEE()
+	headerSum.Write(chunk[:120])
+	headerSum.Write(chunk[128:chunkHeaderSize])
+	le.PutUint32(chunk[124:], headerSum.Sum32())
+
+	header := make([]byte, fileHeaderSize)
+	copy(header, "ElfFile\x00")
+	le.PutUint64(header[8:], 0)
+	le.PutUint64(header[16:], 0)
+	le.PutUint64(header[24:], lastId+1)
+	le.PutUint32(header[32:], 128)
+	le.PutUint16(header[36:], 1)
+	le.PutUint16(header[38:], 3)
+	le.PutUint16(header[40:], fileHeaderSize)
+	le.PutUint16(header[42:], 1)
+	le.PutUint32(header[124:], crc32.ChecksumIEEE(header[:120]))
+
+	evtx := filepath.Join("testdata", "evtx", name+".evtx")
+	if err := ioutil.WriteFile(evtx, append(header, chunk...), 0644); err != nil {
+		return err
+	}
+	golden, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join("testdata", "evtx", name+".golden.json"), append(golden, '\n'), 0644)
+}
+
+func main() {
+	for name, files := range fixtures {
+		if err := writeLog(name, files); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package testutil
+
+import (
+	"testing"
+)
+
+// AssertEqual fails the test if `a` and `b` aren't equal, as compared by ==.
+func AssertEqual(a, b interface{}, t testing.TB) {
+	t.Helper()
+	if a != b {
+		t.Fatalf("%v != %v", a, b)
+	}
+}
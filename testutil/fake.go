@@ -0,0 +1,508 @@
+// Package testutil provides an in-memory event log for testing code built on
+// gowinlog without a Windows event log. A FakeEventLog implements
+// winlog.EventLogAPI, so events emitted into it pass through a real
+// WinLogWatcher, with its filters, bookmarks and stats, and are delivered on
+// the watcher's Event channel:
+//
+//	fake := testutil.NewFakeEventLog()
+//	watcher, _ := winlog.NewWinLogWatcherWithAPI(fake)
+//	watcher.SubscribeFromNow("Application", "*")
+//	go fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Test", EventId: 1})
+//	event := <-watcher.Event()
+//
+// Tests of code parsing events can load them from fixtures of their XML with
+// EventFromXMLFile or EventFixture, and compare fields with AssertEqual.
+package testutil
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// FakeEventLog is an in-memory winlog.EventLogAPI. XPath queries are
+// ignored: every subscription receives all events on its channel.
+type FakeEventLog struct {
+	// If set, returned by Subscribe instead of subscribing
+	SubscribeErr error
+
+	mutex         sync.Mutex
+	nextHandle    uint64
+	subscriptions map[uint64]*fakeSubscription
+	events        map[uint64]*fakeEvent
+	bookmarks     map[uint64]*fakeBookmark
+	history       map[string][]*fakeEvent
+	queries       map[uint64][]*fakeEvent
+	closed        map[uint64]bool
+	templates     map[string]winlog.EventTemplates
+	tables        map[string]*winlog.PublisherTables
+	configs       map[string]winlog.ChannelConfig
+
+	// Size of each record in the history, to report the FileSize of
+	// channels. Defaults to 1024.
+	RecordSize uint64
+}
+
+type fakeSubscription struct {
+	channel  string
+	callback winlog.LogEventCallback
+}
+
+type fakeEvent struct {
+	event winlog.WinLogEvent
+	xml   []byte
+}
+
+type fakeBookmark struct {
+	channel  string
+	recordId uint64
+}
+
+// NewFakeEventLog creates an empty event log.
+func NewFakeEventLog() *FakeEventLog {
+	return &FakeEventLog{
+		subscriptions: make(map[uint64]*fakeSubscription),
+		events:        make(map[uint64]*fakeEvent),
+		bookmarks:     make(map[uint64]*fakeBookmark),
+		history:       make(map[string][]*fakeEvent),
+		queries:       make(map[uint64][]*fakeEvent),
+		closed:        make(map[uint64]bool),
+		templates:     make(map[string]winlog.EventTemplates),
+		tables:        make(map[string]*winlog.PublisherTables),
+		configs:       make(map[string]winlog.ChannelConfig),
+	}
+}
+
+// Must be called with the mutex held
+func (f *FakeEventLog) handle() uint64 {
+	f.nextHandle++
+	return f.nextHandle
+}
+
+// Emit appends an event to `channel` and publishes it to the channel's
+// subscriptions. The event's system fields and localized text are what the
+// watcher renders. If the event has no Xml, it's generated from the fields
+// and EventData. A RecordId is assigned if the event doesn't have one, and
+// Channel is set to `channel` if it's empty. Set Channel to emit an event
+// forwarded from another computer's channel, as on ForwardedEvents.
+//
+// Like a real event log callback, Emit blocks until each subscription's
+// watcher has delivered or discarded the event.
+func (f *FakeEventLog) Emit(channel string, event *winlog.WinLogEvent) {
+	ev := &fakeEvent{event: *event}
+	if ev.event.Channel == "" {
+		ev.event.Channel = channel
+	}
+	f.mutex.Lock()
+	if ev.event.RecordId == 0 {
+		ev.event.RecordId = uint64(len(f.history[channel]) + 1)
+	}
+	ev.xml = ev.event.Xml
+	if ev.xml == nil {
+		ev.xml = eventXml(&ev.event)
+	}
+	f.history[channel] = append(f.history[channel], ev)
+	var subscriptions []*fakeSubscription
+	for handle, subscription := range f.subscriptions {
+		if subscription.channel == channel && !f.closed[handle] {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	f.mutex.Unlock()
+
+	for _, subscription := range subscriptions {
+		f.publish(subscription, ev)
+	}
+}
+
+// EmitXML parses the <System> and <RenderingInfo> elements of an event's
+// XML, as rendered by EvtRender or wevtutil, and emits it on `channel`.
+func (f *FakeEventLog) EmitXML(channel, xml string) error {
+	event, err := parseEventXml([]byte(xml))
+	if err != nil {
+		return err
+	}
+	f.Emit(channel, event)
+	return nil
+}
+
+// SetEventTemplates sets the event templates returned for a provider.
+// Providers without templates have none, as for providers without an
+// instrumentation manifest.
+func (f *FakeEventLog) SetEventTemplates(providerName string, templates winlog.EventTemplates) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.templates[providerName] = templates
+}
+
+// SetPublisherTables sets the level, task, opcode and keyword names
+// returned for a provider.
+func (f *FakeEventLog) SetPublisherTables(providerName string, tables *winlog.PublisherTables) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.tables[providerName] = tables
+}
+
+// SetChannelConfig sets the configuration returned for a channel.
+func (f *FakeEventLog) SetChannelConfig(channel string, config winlog.ChannelConfig) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.configs[channel] = config
+}
+
+// EmitError publishes an error to the subscriptions on `channel`, as the
+// event log does when a subscription fails.
+func (f *FakeEventLog) EmitError(channel string, err error) {
+	f.mutex.Lock()
+	var subscriptions []*fakeSubscription
+	for handle, subscription := range f.subscriptions {
+		if subscription.channel == channel && !f.closed[handle] {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	f.mutex.Unlock()
+	for _, subscription := range subscriptions {
+		subscription.callback.PublishError(err)
+	}
+}
+
+// Subscriptions returns the number of open subscriptions on `channel`.
+func (f *FakeEventLog) Subscriptions(channel string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	count := 0
+	for handle, subscription := range f.subscriptions {
+		if subscription.channel == channel && !f.closed[handle] {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *FakeEventLog) publish(subscription *fakeSubscription, ev *fakeEvent) {
+	f.mutex.Lock()
+	handle := f.handle()
+	f.events[handle] = ev
+	f.mutex.Unlock()
+	subscription.callback.PublishEvent(winlog.EventHandle(handle), subscription.channel)
+	// The event log closes event handles once the callback returns
+	f.mutex.Lock()
+	delete(f.events, handle)
+	f.mutex.Unlock()
+}
+
+func (f *FakeEventLog) event(handle winlog.EventHandle) (*fakeEvent, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ev, ok := f.events[uint64(handle)]
+	if !ok {
+		return nil, fmt.Errorf("Invalid event handle %v", handle)
+	}
+	return ev, nil
+}
+
+func (f *FakeEventLog) CreateRenderContext() (winlog.SysRenderContext, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return winlog.SysRenderContext(f.handle()), nil
+}
+
+// Subscribe registers `callback` for events emitted on `channel`. Events
+// already in the channel are replayed in the background if starting from the
+// oldest record or a bookmark.
+func (f *FakeEventLog) Subscribe(channel, query string, flags winlog.EVT_SUBSCRIBE_FLAGS, bookmark winlog.BookmarkHandle, callback winlog.LogEventCallback) (winlog.ListenerHandle, error) {
+	if f.SubscribeErr != nil {
+		return 0, f.SubscribeErr
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	subscription := &fakeSubscription{channel: channel, callback: callback}
+	handle := f.handle()
+
+	var replay []*fakeEvent
+	switch flags {
+	case winlog.EvtSubscribeStartAtOldestRecord:
+		replay = f.history[channel]
+	case winlog.EvtSubscribeStartAfterBookmark:
+		b, ok := f.bookmarks[uint64(bookmark)]
+		if !ok {
+			return 0, fmt.Errorf("Invalid bookmark handle %v", bookmark)
+		}
+		for _, ev := range f.history[channel] {
+			if b.channel != channel || ev.event.RecordId > b.recordId {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	replay = append([]*fakeEvent(nil), replay...)
+	f.subscriptions[handle] = subscription
+	if len(replay) > 0 {
+		// The watcher holds its lock while subscribing, so events can't be
+		// published until Subscribe returns
+		go func() {
+			for _, ev := range replay {
+				f.mutex.Lock()
+				closed := f.closed[handle]
+				f.mutex.Unlock()
+				if closed {
+					return
+				}
+				f.publish(subscription, ev)
+			}
+		}()
+	}
+	return winlog.ListenerHandle(handle), nil
+}
+
+// Query reads the events emitted on the channel `path`, newest first if
+// `flags` includes EvtQueryReverseDirection. The XPath query is ignored.
+func (f *FakeEventLog) Query(path, query string, flags uint32) (winlog.QueryHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	results := append([]*fakeEvent(nil), f.history[path]...)
+	if flags&winlog.EvtQueryReverseDirection != 0 {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+	handle := f.handle()
+	f.queries[handle] = results
+	return winlog.QueryHandle(handle), nil
+}
+
+// NextEvents returns handles to the query's next events, which stay valid
+// until closed.
+func (f *FakeEventLog) NextEvents(query winlog.QueryHandle, events []winlog.EventHandle, timeout time.Duration) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	results, ok := f.queries[uint64(query)]
+	if !ok {
+		return 0, fmt.Errorf("Invalid query handle %v", query)
+	}
+	if len(results) == 0 {
+		return 0, io.EOF
+	}
+	n := len(events)
+	if len(results) < n {
+		n = len(results)
+	}
+	for i, ev := range results[:n] {
+		handle := f.handle()
+		f.events[handle] = ev
+		events[i] = winlog.EventHandle(handle)
+	}
+	f.queries[uint64(query)] = results[n:]
+	return n, nil
+}
+
+var bookmarkPattern = regexp.MustCompile(`Channel='([^']*)' RecordId='(\d+)'`)
+
+func (f *FakeEventLog) CreateBookmark(xml string) (winlog.BookmarkHandle, error) {
+	b := &fakeBookmark{}
+	if xml != "" {
+		m := bookmarkPattern.FindStringSubmatch(xml)
+		if m == nil {
+			return 0, fmt.Errorf("Invalid bookmark XML %q", xml)
+		}
+		b.channel = m[1]
+		b.recordId, _ = strconv.ParseUint(m[2], 10, 64)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	handle := f.handle()
+	f.bookmarks[handle] = b
+	return winlog.BookmarkHandle(handle), nil
+}
+
+func (f *FakeEventLog) UpdateBookmark(bookmark winlog.BookmarkHandle, event winlog.EventHandle) error {
+	ev, err := f.event(event)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	b, ok := f.bookmarks[uint64(bookmark)]
+	if !ok {
+		return fmt.Errorf("Invalid bookmark handle %v", bookmark)
+	}
+	b.channel = ev.event.Channel
+	b.recordId = ev.event.RecordId
+	return nil
+}
+
+// RenderBookmark renders the bookmark in the same format as the event log.
+func (f *FakeEventLog) RenderBookmark(bookmark winlog.BookmarkHandle) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	b, ok := f.bookmarks[uint64(bookmark)]
+	if !ok {
+		return "", fmt.Errorf("Invalid bookmark handle %v", bookmark)
+	}
+	if b.channel == "" {
+		return "<BookmarkList>\r\n</BookmarkList>", nil
+	}
+	return fmt.Sprintf("<BookmarkList>\r\n  <Bookmark Channel='%s' RecordId='%d' IsCurrent='true'/>\r\n</BookmarkList>", b.channel, b.recordId), nil
+}
+
+func (f *FakeEventLog) RenderValues(renderContext winlog.SysRenderContext, event winlog.EventHandle) (winlog.RenderedValues, error) {
+	ev, err := f.event(event)
+	if err != nil {
+		return nil, err
+	}
+	e := &ev.event
+	return renderedValues{
+		winlog.EvtSystemProviderName:  e.ProviderName,
+		winlog.EvtSystemEventID:       e.EventId,
+		winlog.EvtSystemQualifiers:    e.Qualifiers,
+		winlog.EvtSystemLevel:         e.Level,
+		winlog.EvtSystemTask:          e.Task,
+		winlog.EvtSystemOpcode:        e.Opcode,
+		winlog.EvtSystemTimeCreated:   e.Created,
+		winlog.EvtSystemEventRecordId: e.RecordId,
+		winlog.EvtSystemProcessID:     e.ProcessId,
+		winlog.EvtSystemThreadID:      e.ThreadId,
+		winlog.EvtSystemChannel:       e.Channel,
+		winlog.EvtSystemComputer:      e.ComputerName,
+		winlog.EvtSystemVersion:       e.Version,
+		winlog.EvtSystemKeywords:      e.KeywordsMask,
+	}, nil
+}
+
+func (f *FakeEventLog) RenderXML(event winlog.EventHandle) ([]byte, error) {
+	ev, err := f.event(event)
+	if err != nil {
+		return nil, err
+	}
+	return ev.xml, nil
+}
+
+// RenderRaw returns the event's XML as UTF-16. The fake has no EvtRender
+// buffer of System values, so Values is empty.
+func (f *FakeEventLog) RenderRaw(renderContext winlog.SysRenderContext, event winlog.EventHandle) (*winlog.RenderedEvent, error) {
+	ev, err := f.event(event)
+	if err != nil {
+		return nil, err
+	}
+	wide := utf16.Encode([]rune(string(ev.xml)))
+	xml := make([]byte, 2*len(wide)+2)
+	for i, c := range wide {
+		xml[2*i] = byte(c)
+		xml[2*i+1] = byte(c >> 8)
+	}
+	return &winlog.RenderedEvent{XML: xml}, nil
+}
+
+func (f *FakeEventLog) OpenPublisherMetadata(providerName string) (winlog.PublisherHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return winlog.PublisherHandle(f.handle()), nil
+}
+
+// OpenLocalizedPublisherMetadata ignores the locale: messages are always
+// the fields of the emitted event.
+func (f *FakeEventLog) OpenLocalizedPublisherMetadata(providerName, locale string) (winlog.PublisherHandle, error) {
+	return f.OpenPublisherMetadata(providerName)
+}
+
+// FormatMessage returns the matching localized field of the emitted event.
+func (f *FakeEventLog) FormatMessage(publisher winlog.PublisherHandle, event winlog.EventHandle, flags winlog.EVT_FORMAT_MESSAGE_FLAGS) (string, error) {
+	ev, err := f.event(event)
+	if err != nil {
+		return "", err
+	}
+	e := &ev.event
+	switch flags {
+	case winlog.EvtFormatMessageEvent:
+		return e.Msg, nil
+	case winlog.EvtFormatMessageLevel:
+		return e.LevelText, nil
+	case winlog.EvtFormatMessageTask:
+		return e.TaskText, nil
+	case winlog.EvtFormatMessageOpcode:
+		return e.OpcodeText, nil
+	case winlog.EvtFormatMessageKeyword:
+		return e.Keywords, nil
+	case winlog.EvtFormatMessageChannel:
+		return e.ChannelText, nil
+	case winlog.EvtFormatMessageProvider:
+		return e.ProviderText, nil
+	case winlog.EvtFormatMessageId:
+		return e.IdText, nil
+	}
+	return "", fmt.Errorf("Unsupported format flags %v", flags)
+}
+
+func (f *FakeEventLog) ChannelInfo(channel string) (winlog.ChannelInfo, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	history := f.history[channel]
+	recordSize := f.RecordSize
+	if recordSize == 0 {
+		recordSize = 1024
+	}
+	info := winlog.ChannelInfo{NumberOfRecords: uint64(len(history)), FileSize: uint64(len(history)) * recordSize}
+	if len(history) > 0 {
+		info.OldestRecordId = history[0].event.RecordId
+		info.NewestRecordId = history[len(history)-1].event.RecordId
+	}
+	if config, ok := f.configs[channel]; ok && config.MaxSize > 0 {
+		info.Full = info.FileSize >= config.MaxSize
+	}
+	return info, nil
+}
+
+func (f *FakeEventLog) ChannelConfig(channel string) (winlog.ChannelConfig, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.configs[channel], nil
+}
+
+func (f *FakeEventLog) EventTemplates(providerName string) (winlog.EventTemplates, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.templates[providerName], nil
+}
+
+func (f *FakeEventLog) PublisherTables(providerName string) (*winlog.PublisherTables, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.tables[providerName], nil
+}
+
+func (f *FakeEventLog) Cancel(handle uint64) error {
+	return nil
+}
+
+func (f *FakeEventLog) Close(handle uint64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed[handle] = true
+	delete(f.bookmarks, handle)
+	delete(f.queries, handle)
+	delete(f.events, handle)
+	return nil
+}
+
+// renderedValues implements winlog.RenderedValues over a map of values
+// indexed by EVT_SYSTEM_PROPERTY_ID.
+type renderedValues map[uint32]interface{}
+
+func (v renderedValues) String(index uint32) (string, error) {
+	if s, ok := v[index].(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("Value at index %v is not a string", index)
+}
+
+func (v renderedValues) Uint(index uint32) (uint64, error) {
+	if n, ok := v[index].(uint64); ok {
+		return n, nil
+	}
+	return 0, fmt.Errorf("Value at index %v is not an unsigned integer", index)
+}
@@ -0,0 +1,297 @@
+package testutil
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+const sampleXml = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Microsoft-Windows-Security-Auditing' Guid='{54849625-5478-4994-a5ba-3e3b0328c30d}'/><EventID>4624</EventID><Version>2</Version><Level>0</Level><Task>12544</Task><Opcode>0</Opcode><Keywords>0x8020000000000000</Keywords><TimeCreated SystemTime='2021-03-04T05:06:07.1234567Z'/><EventRecordID>1234</EventRecordID><Correlation/><Execution ProcessID='656' ThreadID='1200'/><Channel>Security</Channel><Computer>host.example.com</Computer><Security/></System><EventData><Data Name='TargetUserName'>alice</Data><Data Name='LogonType'>3</Data></EventData><RenderingInfo Culture='en-US'><Message>An account was successfully logged on.</Message><Level>Information</Level><Task>Logon</Task><Opcode>Info</Opcode><Channel>Security</Channel><Provider>Microsoft Windows security auditing.</Provider><Keywords><Keyword>Audit Success</Keyword></Keywords></RenderingInfo></Event>`
+
+func newWatcher(t *T, fake *FakeEventLog) *winlog.WinLogWatcher {
+	watcher, err := winlog.NewWinLogWatcherWithAPI(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.RenderMessage = true
+	watcher.RenderLevel = true
+	return watcher
+}
+
+func receive(t *T, watcher *winlog.WinLogWatcher) *winlog.WinLogEvent {
+	t.Helper()
+	select {
+	case ev := <-watcher.Event():
+		return ev
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published")
+	}
+	return nil
+}
+
+func TestEmit(t *T) {
+	fake := NewFakeEventLog()
+	watcher := newWatcher(t, fake)
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	go fake.Emit("Application", &winlog.WinLogEvent{
+		ProviderName: "Test",
+		EventId:      7,
+		Level:        winlog.LevelWarning,
+		Msg:          "Something happened",
+		EventData:    map[string]string{"Path": "C:\\a & b"},
+		ActivityId:   "{6d7b1f3a-0c4e-4b8a-9f21-3c5d7e9a1b2c}",
+		UserSid:      "S-1-5-18",
+		UserData:     map[string]string{"PolicyName": "EXE"},
+	})
+	ev := receive(t, watcher)
+	if ev.ProviderName != "Test" || ev.EventId != 7 || ev.Level != winlog.LevelWarning || ev.RecordId != 1 {
+		t.Fatalf("Unexpected system fields: %+v", ev)
+	}
+	if ev.Msg != "Something happened" || ev.EventData["Path"] != "C:\\a & b" {
+		t.Fatalf("Unexpected message or data: %q %v", ev.Msg, ev.EventData)
+	}
+	if ev.ActivityId != "{6d7b1f3a-0c4e-4b8a-9f21-3c5d7e9a1b2c}" || ev.RelatedActivityId != "" {
+		t.Fatalf("Unexpected activity IDs %q %q", ev.ActivityId, ev.RelatedActivityId)
+	}
+	if ev.UserSid != "S-1-5-18" || ev.UserData["PolicyName"] != "EXE" {
+		t.Fatalf("Unexpected user SID or UserData: %q %v", ev.UserSid, ev.UserData)
+	}
+	if ev.Bookmark != "<BookmarkList>\r\n  <Bookmark Channel='Application' RecordId='1' IsCurrent='true'/>\r\n</BookmarkList>" {
+		t.Fatalf("Unexpected bookmark %q", ev.Bookmark)
+	}
+}
+
+func TestEmitXML(t *T) {
+	fake := NewFakeEventLog()
+	watcher := newWatcher(t, fake)
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Security", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- fake.EmitXML("Security", sampleXml) }()
+	ev := receive(t, watcher)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if ev.EventId != 4624 || ev.RecordId != 1234 || ev.ProcessId != 656 || ev.ComputerName != "host.example.com" {
+		t.Fatalf("Unexpected system fields: %+v", ev)
+	}
+	if !ev.Created.Equal(time.Date(2021, 3, 4, 5, 6, 7, 123456700, time.UTC)) {
+		t.Fatalf("Unexpected creation time %v", ev.Created)
+	}
+	if ev.Msg != "An account was successfully logged on." || ev.LevelText != "Information" {
+		t.Fatalf("Unexpected localized fields: %q %q", ev.Msg, ev.LevelText)
+	}
+	if ev.KeywordsMask != 0x8020000000000000 || ev.Outcome != winlog.OutcomeSuccess {
+		t.Fatalf("Unexpected keywords %#x or outcome %q", ev.KeywordsMask, ev.Outcome)
+	}
+	if ev.EventData["TargetUserName"] != "alice" || ev.EventData["LogonType"] != "3" {
+		t.Fatalf("Unexpected EventData %v", ev.EventData)
+	}
+}
+
+func TestNameEventData(t *T) {
+	fake := NewFakeEventLog()
+	fake.SetEventTemplates("Service Control Manager", winlog.EventTemplates{{EventId: 7036}: {{Name: "param1"}, {Name: "param2"}}})
+	watcher := newWatcher(t, fake)
+	defer watcher.Shutdown()
+	watcher.NameEventData = true
+	if err := watcher.SubscribeFromNow("System", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	const classicXml = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='%s'/><EventID Qualifiers='16384'>7036</EventID><Version>0</Version><Level>4</Level><Task>0</Task><Opcode>0</Opcode><Keywords>0x8080000000000000</Keywords><TimeCreated SystemTime='2021-03-04T05:06:07.0000000Z'/><EventRecordID>%d</EventRecordID><Execution ProcessID='612' ThreadID='700'/><Channel>System</Channel><Computer>host.example.com</Computer></System><EventData><Data>Windows Update</Data><Data>running</Data></EventData></Event>`
+	go fake.EmitXML("System", fmt.Sprintf(classicXml, "Service Control Manager", 1))
+	ev := receive(t, watcher)
+	if ev.EventData["param1"] != "Windows Update" || ev.EventData["param2"] != "running" {
+		t.Fatalf("Unexpected EventData %v", ev.EventData)
+	}
+
+	// Providers without templates keep positional names
+	go fake.EmitXML("System", fmt.Sprintf(classicXml, "Legacy Service", 2))
+	ev = receive(t, watcher)
+	if ev.EventData["Data0"] != "Windows Update" {
+		t.Fatalf("Unexpected EventData %v", ev.EventData)
+	}
+}
+
+func TestUsePublisherTables(t *T) {
+	fake := NewFakeEventLog()
+	fake.SetPublisherTables("Provider", &winlog.PublisherTables{
+		Tasks:    map[uint64]string{1: "Table task"},
+		Keywords: []winlog.KeywordName{{Mask: 0x1, Name: "Table keyword"}},
+	})
+	watcher := newWatcher(t, fake)
+	defer watcher.Shutdown()
+	watcher.RenderTask = true
+	watcher.RenderKeywords = true
+	watcher.UsePublisherTables = true
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	go fake.Emit("Application", &winlog.WinLogEvent{
+		ProviderName: "Provider",
+		Level:        winlog.LevelWarning,
+		Task:         1,
+		KeywordsMask: 0x1,
+		LevelText:    "Formatted warning",
+		TaskText:     "Formatted task",
+		Keywords:     "Formatted keyword",
+	})
+	ev := receive(t, watcher)
+	if ev.TaskText != "Table task" || ev.Keywords != "Table keyword" {
+		t.Fatalf("Names not taken from the tables: %q %q", ev.TaskText, ev.Keywords)
+	}
+	// Levels missing from the tables are formatted
+	if ev.LevelText != "Formatted warning" {
+		t.Fatalf("Unexpected level %q", ev.LevelText)
+	}
+
+	// and remembered
+	go fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Provider", Level: winlog.LevelWarning, LevelText: "Changed"})
+	ev = receive(t, watcher)
+	if ev.LevelText != "Formatted warning" {
+		t.Fatalf("Level formatted again: %q", ev.LevelText)
+	}
+}
+
+func TestForwardedEvents(t *T) {
+	fake := NewFakeEventLog()
+	watcher := newWatcher(t, fake)
+	defer watcher.Shutdown()
+	watcher.CollectorName = "wec01"
+	watcher.CollectorChannels = []string{"Collected-Servers"}
+	for _, channel := range []string{"ForwardedEvents", "Collected-Servers", "Application"} {
+		if err := watcher.SubscribeFromNow(channel, "*"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	created := time.Now().Add(-time.Minute)
+	go fake.Emit("ForwardedEvents", &winlog.WinLogEvent{
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4624,
+		Channel:      "Security",
+		ComputerName: "ws1.example.com",
+		Created:      created,
+	})
+	ev := receive(t, watcher)
+	if !ev.Forwarded || ev.SourceComputer != "ws1.example.com" || ev.SourceChannel != "Security" || ev.Collector != "wec01" {
+		t.Fatalf("Unexpected forwarding fields: %v %q %q %q", ev.Forwarded, ev.SourceComputer, ev.SourceChannel, ev.Collector)
+	}
+	if ev.SubscribedChannel != "ForwardedEvents" || ev.ForwardingDelay() < time.Minute {
+		t.Fatalf("Unexpected subscribed channel %q or delay %v", ev.SubscribedChannel, ev.ForwardingDelay())
+	}
+
+	go fake.Emit("Collected-Servers", &winlog.WinLogEvent{ProviderName: "Test", Channel: "System", ComputerName: "srv1"})
+	ev = receive(t, watcher)
+	if !ev.Forwarded || ev.SourceComputer != "srv1" || ev.SourceChannel != "System" {
+		t.Fatalf("Event on a collector channel not marked forwarded: %+v", ev)
+	}
+
+	go fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Test"})
+	ev = receive(t, watcher)
+	if ev.Forwarded || ev.Collector != "" || ev.ForwardingDelay() != 0 {
+		t.Fatalf("Local event marked forwarded: %+v", ev)
+	}
+}
+
+func TestResumeFromBookmark(t *T) {
+	fake := NewFakeEventLog()
+	watcher := newWatcher(t, fake)
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	var bookmark string
+	for id := uint64(1); id <= 3; id++ {
+		go fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Test", EventId: id})
+		ev := receive(t, watcher)
+		if id == 2 {
+			bookmark = ev.Bookmark
+		}
+	}
+	watcher.Shutdown()
+	if n := fake.Subscriptions("Application"); n != 0 {
+		t.Fatalf("%v subscriptions left open", n)
+	}
+
+	watcher = newWatcher(t, fake)
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromBookmark("Application", "*", bookmark); err != nil {
+		t.Fatal(err)
+	}
+	if ev := receive(t, watcher); ev.EventId != 3 {
+		t.Fatalf("Resumed at event %v, expected 3", ev.EventId)
+	}
+}
+
+func TestQueryReverse(t *T) {
+	fake := NewFakeEventLog()
+	system := winlog.SystemEventLogAPI
+	winlog.SystemEventLogAPI = fake
+	defer func() { winlog.SystemEventLogAPI = system }()
+	watcher := newWatcher(t, fake)
+	defer watcher.Shutdown()
+	for eventId := uint64(1); eventId <= 3; eventId++ {
+		fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Test", EventId: eventId})
+	}
+
+	result, err := winlog.QueryChannelReverse("Application", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Close()
+	for recordId := uint64(3); recordId >= 1; recordId-- {
+		handle, err := result.Next(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev, err := watcher.RenderEvent(handle)
+		fake.Close(uint64(handle))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.RecordId != recordId || ev.EventId != recordId {
+			t.Fatalf("Expected record %v, got %+v", recordId, ev)
+		}
+	}
+	if _, err := result.Next(time.Second); err != io.EOF {
+		t.Fatalf("Expected io.EOF after the oldest record, got %v", err)
+	}
+}
+
+func TestEventFromXMLFile(t *T) {
+	ev, err := EventFromXMLFile(filepath.Join("..", "testdata", "xml", "sysmon-3.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEqual(ev.ProviderName, "Microsoft-Windows-Sysmon", t)
+	AssertEqual(ev.EventId, uint64(3), t)
+	AssertEqual(ev.RecordId, uint64(5522), t)
+	AssertEqual(ev.EventData["ProcessId"], "6700", t)
+
+	ev, err = EventFromXMLFile(filepath.Join("..", "testdata", "xml", "security-1102.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEqual(ev.EventId, uint64(1102), t)
+	AssertEqual(ev.EventData == nil, true, t)
+	AssertEqual(ev.UserData["SubjectUserName"], "testadmin", t)
+
+	if _, err := EventFromXMLFile(filepath.Join("..", "testdata", "xml", "missing.xml")); err == nil {
+		t.Fatal("Expected an error reading a missing file")
+	}
+}
@@ -0,0 +1,224 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func (v renderedValues) FileTime(index uint32) (time.Time, error) {
+	if t, ok := v[index].(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Value at index %v is not a time", index)
+}
+
+// Format of SystemTime attributes in event XML
+const systemTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+// eventXml generates the XML the event log would render for the event's
+// system fields, EventData and UserData.
+func eventXml(e *winlog.WinLogEvent) []byte {
+	var buf bytes.Buffer
+	text := func(s string) string {
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(s))
+		return escaped.String()
+	}
+	buf.WriteString("<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System>")
+	fmt.Fprintf(&buf, "<Provider Name='%s'/>", text(e.ProviderName))
+	if e.Qualifiers != 0 {
+		fmt.Fprintf(&buf, "<EventID Qualifiers='%d'>%d</EventID>", e.Qualifiers, e.EventId)
+	} else {
+		fmt.Fprintf(&buf, "<EventID>%d</EventID>", e.EventId)
+	}
+	fmt.Fprintf(&buf, "<Version>%d</Version><Level>%d</Level><Task>%d</Task><Opcode>%d</Opcode>", e.Version, e.Level, e.Task, e.Opcode)
+	fmt.Fprintf(&buf, "<Keywords>0x%x</Keywords>", e.KeywordsMask)
+	fmt.Fprintf(&buf, "<TimeCreated SystemTime='%s'/>", e.Created.UTC().Format(systemTimeFormat))
+	fmt.Fprintf(&buf, "<EventRecordID>%d</EventRecordID>", e.RecordId)
+	if e.ActivityId != "" || e.RelatedActivityId != "" {
+		buf.WriteString("<Correlation")
+		if e.ActivityId != "" {
+			fmt.Fprintf(&buf, " ActivityID='%s'", text(e.ActivityId))
+		}
+		if e.RelatedActivityId != "" {
+			fmt.Fprintf(&buf, " RelatedActivityID='%s'", text(e.RelatedActivityId))
+		}
+		buf.WriteString("/>")
+	}
+	fmt.Fprintf(&buf, "<Execution ProcessID='%d' ThreadID='%d'/>", e.ProcessId, e.ThreadId)
+	fmt.Fprintf(&buf, "<Channel>%s</Channel><Computer>%s</Computer>", text(e.Channel), text(e.ComputerName))
+	if e.UserSid != "" {
+		fmt.Fprintf(&buf, "<Security UserID='%s'/>", text(e.UserSid))
+	}
+	buf.WriteString("</System>")
+	if len(e.EventData) > 0 {
+		names := make([]string, 0, len(e.EventData))
+		for name := range e.EventData {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf.WriteString("<EventData>")
+		for _, name := range names {
+			fmt.Fprintf(&buf, "<Data Name='%s'>%s</Data>", text(name), text(e.EventData[name]))
+		}
+		buf.WriteString("</EventData>")
+	}
+	if len(e.UserData) > 0 {
+		names := make([]string, 0, len(e.UserData))
+		for name := range e.UserData {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf.WriteString("<UserData><EventXML>")
+		for _, name := range names {
+			fmt.Fprintf(&buf, "<%s>%s</%s>", name, text(e.UserData[name]), name)
+		}
+		buf.WriteString("</EventXML></UserData>")
+	}
+	buf.WriteString("</Event>")
+	return buf.Bytes()
+}
+
+type xmlEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		}
+		EventID struct {
+			Qualifiers uint64 `xml:"Qualifiers,attr"`
+			Value      string `xml:",chardata"`
+		}
+		Version     uint64
+		Level       uint64
+		Task        uint64
+		Opcode      uint64
+		Keywords    string
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		}
+		EventRecordID uint64
+		Correlation   struct {
+			ActivityID        string `xml:"ActivityID,attr"`
+			RelatedActivityID string `xml:"RelatedActivityID,attr"`
+		}
+		Execution struct {
+			ProcessID uint64 `xml:"ProcessID,attr"`
+			ThreadID  uint64 `xml:"ThreadID,attr"`
+		}
+		Channel  string
+		Computer string
+		Security struct {
+			UserID string `xml:"UserID,attr"`
+		}
+	}
+	RenderingInfo struct {
+		Message  string
+		Level    string
+		Task     string
+		Opcode   string
+		Channel  string
+		Provider string
+		Keywords struct {
+			Keyword []string
+		}
+	}
+}
+
+// EventFromXMLFile reads an event's XML, as rendered by EvtRender or
+// wevtutil, from a file such as a test fixture, and returns it as a watcher
+// would deliver it, with its system fields, localized text, EventData and
+// UserData.
+func EventFromXMLFile(path string) (*winlog.WinLogEvent, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	event, err := parseEventXml(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if event.EventData, err = winlog.ParseEventData(data); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if event.UserData, err = winlog.ParseUserData(data); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return event, nil
+}
+
+// EventFixture loads testdata/<id>.xml in the test's package with
+// EventFromXMLFile, failing the test if it can't be read.
+func EventFixture(t testing.TB, id uint64) *winlog.WinLogEvent {
+	t.Helper()
+	event, err := EventFromXMLFile(filepath.Join("testdata", strconv.FormatUint(id, 10)+".xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// parseEventXml reads the fields the watcher would render from event XML.
+func parseEventXml(data []byte) (*winlog.WinLogEvent, error) {
+	var parsed xmlEvent
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("Failed to parse event XML: %v", err)
+	}
+	s := &parsed.System
+	eventId, err := strconv.ParseUint(strings.TrimSpace(s.EventID.Value), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid EventID %q", s.EventID.Value)
+	}
+	var keywords uint64
+	if s.Keywords != "" {
+		keywords, err = strconv.ParseUint(strings.TrimSpace(s.Keywords), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Keywords %q", s.Keywords)
+		}
+	}
+	var created time.Time
+	if s.TimeCreated.SystemTime != "" {
+		created, err = time.Parse(time.RFC3339Nano, s.TimeCreated.SystemTime)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid SystemTime %q", s.TimeCreated.SystemTime)
+		}
+	}
+	r := &parsed.RenderingInfo
+	return &winlog.WinLogEvent{
+		Xml:               data,
+		ProviderName:      s.Provider.Name,
+		EventId:           eventId,
+		Qualifiers:        s.EventID.Qualifiers,
+		Level:             s.Level,
+		Task:              s.Task,
+		Opcode:            s.Opcode,
+		Created:           created,
+		RecordId:          s.EventRecordID,
+		ProcessId:         s.Execution.ProcessID,
+		ThreadId:          s.Execution.ThreadID,
+		Channel:           s.Channel,
+		ComputerName:      s.Computer,
+		Version:           s.Version,
+		KeywordsMask:      keywords,
+		Outcome:           winlog.DeriveOutcome(keywords, s.Level),
+		ActivityId:        s.Correlation.ActivityID,
+		RelatedActivityId: s.Correlation.RelatedActivityID,
+		UserSid:           s.Security.UserID,
+		Msg:               r.Message,
+		LevelText:         r.Level,
+		TaskText:          r.Task,
+		OpcodeText:        r.Opcode,
+		ChannelText:       r.Channel,
+		ProviderText:      r.Provider,
+		Keywords:          strings.Join(r.Keywords.Keyword, ","),
+	}, nil
+}
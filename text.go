@@ -0,0 +1,155 @@
+package winlog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the standard levels, used when LevelText wasn't rendered. Level
+// 0 is shown as Information, as Event Viewer does.
+var levelNames = map[uint64]string{
+	LevelLogAlways:   "Information",
+	LevelCritical:    "Critical",
+	LevelError:       "Error",
+	LevelWarning:     "Warning",
+	LevelInformation: "Information",
+	LevelVerbose:     "Verbose",
+}
+
+// levelName returns LevelText, or else the name of a standard level.
+func (ev *WinLogEvent) levelName() string {
+	if ev.LevelText != "" {
+		return ev.LevelText
+	}
+	if name, ok := levelNames[ev.Level]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level %d", ev.Level)
+}
+
+// String summarizes the event on one line, for CLI tails and debug logs:
+//
+//	2021-03-04T05:06:07Z Security Microsoft-Windows-Security-Auditing 4625 Information: An account failed to log on.
+//
+// The summary is the first line of Msg, or the EventData or UserData as
+// sorted name=value pairs if the message wasn't rendered.
+func (ev *WinLogEvent) String() string {
+	return ev.summaryLine(ev.Created.Format(time.RFC3339))
+}
+
+// StringWith summarizes the event as String does, with its creation time
+// written as `ts` says.
+func (ev *WinLogEvent) StringWith(ts Timestamps) string {
+	return ev.summaryLine(ts.Format(ev.Created))
+}
+
+func (ev *WinLogEvent) summaryLine(created string) string {
+	return fmt.Sprintf("%v %v %v %v %v: %v", created, ev.Channel, ev.ProviderName, ev.EventId, ev.levelName(), ev.summary())
+}
+
+func (ev *WinLogEvent) summary() string {
+	for _, line := range strings.Split(ev.Msg, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	data := ev.EventData
+	if len(data) == 0 {
+		data = ev.UserData
+	}
+	pairs := make([]string, 0, len(data))
+	for _, name := range sortedNames(data) {
+		pairs = append(pairs, name+"="+data[name])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// VerboseString renders the event over several lines, one field per line,
+// followed by the full message and the EventData and UserData values.
+// Fields which weren't rendered or which the event doesn't have are left
+// out.
+func (ev *WinLogEvent) VerboseString() string {
+	return ev.verboseString(func(t time.Time) string { return t.Format(time.RFC3339Nano) })
+}
+
+// VerboseStringWith renders the event as VerboseString does, with its times
+// written as `ts` says.
+func (ev *WinLogEvent) VerboseStringWith(ts Timestamps) string {
+	return ev.verboseString(ts.Format)
+}
+
+func (ev *WinLogEvent) verboseString(formatTime func(time.Time) string) string {
+	var b strings.Builder
+	field := func(name string, value interface{}) {
+		if s := fmt.Sprint(value); s != "" {
+			fmt.Fprintf(&b, "%-18s%v\n", name+":", s)
+		}
+	}
+	withText := func(text string, value uint64) string {
+		if text == "" {
+			return fmt.Sprint(value)
+		}
+		return fmt.Sprintf("%v (%v)", text, value)
+	}
+
+	field("Time", formatTime(ev.Created))
+	if !ev.ReceivedAt.IsZero() {
+		field("Received", formatTime(ev.ReceivedAt))
+	}
+	field("Channel", ev.Channel)
+	field("Provider", ev.ProviderName)
+	field("EventId", ev.EventId)
+	field("Version", ev.Version)
+	field("Level", withText(ev.levelName(), ev.Level))
+	field("Task", withText(ev.TaskText, ev.Task))
+	field("Opcode", withText(ev.OpcodeText, ev.Opcode))
+	if ev.Keywords != "" {
+		field("Keywords", fmt.Sprintf("%v (0x%x)", ev.Keywords, ev.KeywordsMask))
+	} else if ev.KeywordsMask != 0 {
+		field("Keywords", fmt.Sprintf("0x%x", ev.KeywordsMask))
+	}
+	field("Outcome", ev.Outcome)
+	field("Severity", ev.Severity)
+	field("RecordId", ev.RecordId)
+	field("Computer", ev.ComputerName)
+	field("ProcessId", ev.ProcessId)
+	field("ThreadId", ev.ThreadId)
+	field("UserSid", ev.UserSid)
+	field("ActivityId", ev.ActivityId)
+	field("RelatedActivityId", ev.RelatedActivityId)
+
+	if msg := strings.TrimSpace(ev.Msg); msg != "" {
+		b.WriteString("Message:\n")
+		for _, line := range strings.Split(msg, "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				b.WriteString("  " + line)
+			}
+			b.WriteByte('\n')
+		}
+	}
+	data := func(title string, values map[string]string) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%v:\n", title)
+		for _, name := range sortedNames(values) {
+			fmt.Fprintf(&b, "  %v: %v\n", name, values[name])
+		}
+	}
+	data("EventData", ev.EventData)
+	data("UserData", ev.UserData)
+	data("Extracted", ev.Extracted)
+	data("Enriched", ev.Enriched)
+	return b.String()
+}
+
+func sortedNames(values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
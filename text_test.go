@@ -0,0 +1,66 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestString(t *T) {
+	ev := &WinLogEvent{
+		Created:      time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Channel:      "Security",
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4625,
+		Level:        LevelInformation,
+		Msg:          "\r\nAn account failed to log on.\r\n\r\nSubject:",
+	}
+	assertEqual(ev.String(), "2021-03-04T05:06:07Z Security Microsoft-Windows-Security-Auditing 4625 Information: An account failed to log on.", t)
+
+	// Without a rendered message, the data is summarized instead
+	ev.Msg = ""
+	ev.LevelText = "Informational"
+	ev.EventData = map[string]string{"b": "2", "a": "1"}
+	assertEqual(ev.String(), "2021-03-04T05:06:07Z Security Microsoft-Windows-Security-Auditing 4625 Informational: a=1 b=2", t)
+
+	ev = &WinLogEvent{Created: ev.Created, Level: 16, UserData: map[string]string{"x": "y"}}
+	assertEqual(ev.String(), "2021-03-04T05:06:07Z   0 Level 16: x=y", t)
+}
+
+func TestVerboseString(t *T) {
+	ev := &WinLogEvent{
+		Created:      time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Channel:      "Security",
+		ProviderName: "Microsoft-Windows-Security-Auditing",
+		EventId:      4625,
+		Level:        LevelLogAlways,
+		Task:         12544,
+		TaskText:     "Logon",
+		KeywordsMask: 0x8010000000000000,
+		Keywords:     "Audit Failure",
+		RecordId:     42,
+		UserSid:      "S-1-5-18",
+		Msg:          "An account failed to log on.\r\n\r\nSubject:",
+		EventData:    map[string]string{"TargetUserName": "alice"},
+	}
+	expected := `Time:             2021-03-04T05:06:07Z
+Channel:          Security
+Provider:         Microsoft-Windows-Security-Auditing
+EventId:          4625
+Version:          0
+Level:            Information (0)
+Task:             Logon (12544)
+Opcode:           0
+Keywords:         Audit Failure (0x8010000000000000)
+RecordId:         42
+ProcessId:        0
+ThreadId:         0
+UserSid:          S-1-5-18
+Message:
+  An account failed to log on.
+
+  Subject:
+EventData:
+  TargetUserName: alice
+`
+	assertEqual(ev.VerboseString(), expected, t)
+}
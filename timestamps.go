@@ -0,0 +1,64 @@
+package winlog
+
+import (
+	"strconv"
+	"time"
+)
+
+// Timestamps controls how the times of events, Created, ReceivedAt and
+// CollectedTime, are written by CreateMapWith, a Transform's JSON and
+// StringWith, rather than in whichever zone each time.Time happens to be.
+// The zero value writes UTC RFC 3339 with nanoseconds.
+type Timestamps struct {
+	// Local time instead of UTC
+	Local bool `json:"local,omitempty"`
+	// Milliseconds since the Unix epoch, as a number in maps and JSON,
+	// instead of text
+	EpochMillis bool `json:"epochMillis,omitempty"`
+	// Layout of text timestamps, as for time.Format. time.RFC3339Nano if
+	// empty.
+	Layout string `json:"layout,omitempty"`
+}
+
+// Value returns the timestamp for a map: an int64 with EpochMillis, or else
+// a string.
+func (ts Timestamps) Value(t time.Time) interface{} {
+	if ts.EpochMillis {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	return ts.Format(t)
+}
+
+// Format returns the timestamp as text, the decimal milliseconds with
+// EpochMillis.
+func (ts Timestamps) Format(t time.Time) string {
+	if ts.EpochMillis {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if ts.Local {
+		t = t.Local()
+	} else {
+		t = t.UTC()
+	}
+	layout := ts.Layout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return t.Format(layout)
+}
+
+// formatTimes replaces the times of the event in its map `m`, keyed as
+// CreateMap does, with their timestamps. Times the map doesn't have are
+// left out.
+func (ts Timestamps) formatTimes(m map[string]interface{}, ev *WinLogEvent) {
+	times := map[string]time.Time{
+		"Created":       ev.Created,
+		"ReceivedAt":    ev.ReceivedAt,
+		"CollectedTime": ev.CollectedTime,
+	}
+	for key, t := range times {
+		if _, ok := m[key]; ok {
+			m[key] = ts.Value(t)
+		}
+	}
+}
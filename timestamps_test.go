@@ -0,0 +1,55 @@
+package winlog
+
+import (
+	"encoding/json"
+	. "testing"
+	"time"
+)
+
+func TestTimestamps(t *T) {
+	at := time.Date(2021, 3, 4, 5, 6, 7, 8000000, time.FixedZone("EST", -5*3600))
+	assertEqual(Timestamps{}.Format(at), "2021-03-04T10:06:07.008Z", t)
+	assertEqual(Timestamps{}.Value(at), "2021-03-04T10:06:07.008Z", t)
+	assertEqual(Timestamps{Layout: time.RFC3339}.Format(at), "2021-03-04T10:06:07Z", t)
+	assertEqual(Timestamps{Local: true}.Format(at), at.Local().Format(time.RFC3339Nano), t)
+	assertEqual(Timestamps{EpochMillis: true}.Value(at), int64(1614852367008), t)
+	assertEqual(Timestamps{EpochMillis: true}.Format(at), "1614852367008", t)
+
+	ev := &WinLogEvent{Created: at, ReceivedAt: at.Add(time.Second), Channel: "Security"}
+	m := ev.CreateMapWith(MapOptions{Timestamps: &Timestamps{EpochMillis: true}, KeyCase: KeyCaseSnake})
+	assertEqual(m["created"], int64(1614852367008), t)
+	assertEqual(m["received_at"], int64(1614852368008), t)
+	_, collected := m["collected_time"]
+	assertEqual(collected, false, t)
+	// Times are left as they are without Timestamps
+	assertEqual(ev.CreateMapWith(MapOptions{})["Created"], at, t)
+
+	assertEqual(ev.StringWith(Timestamps{Layout: time.RFC3339}), "2021-03-04T10:06:07Z Security  0 Information: ", t)
+
+	transform := &Transform{
+		Timestamps: &Timestamps{Layout: "2006-01-02"},
+		Derive:     map[string]string{"Day": "{{.Created}}"},
+	}
+	line, err := transform.JSON(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(decoded["Created"], "2021-03-04", t)
+	assertEqual(decoded["ReceivedAt"], "2021-03-04", t)
+	assertEqual(decoded["Day"], "2021-03-04", t)
+
+	// Without Timestamps, JSON writes times as CanonicalJSON does
+	line, err = (&Transform{}).JSON(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded = nil
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(decoded["ReceivedAt"], "2021-03-04T10:06:08.0080000Z", t)
+}
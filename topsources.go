@@ -0,0 +1,94 @@
+package winlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of intervals the TopSourcesWindow is divided into. Counts expire one
+// interval at a time.
+const topSourcesSlots = 10
+
+// SourceCount is the number of events received from one provider and event
+// ID within the watcher's TopSourcesWindow.
+type SourceCount struct {
+	ProviderName string
+	EventId      uint64
+	Count        uint64
+}
+
+type sourceKey struct {
+	provider string
+	eventId  uint64
+}
+
+// sourceCounter counts events by source over a sliding window, kept as a
+// ring of fixed-length slots.
+type sourceCounter struct {
+	slotLength time.Duration
+	limit      int
+
+	mutex  sync.Mutex
+	slots  [topSourcesSlots]map[sourceKey]uint64
+	epochs [topSourcesSlots]int64
+}
+
+func newSourceCounter(window time.Duration, limit int) *sourceCounter {
+	slotLength := window / topSourcesSlots
+	if slotLength <= 0 {
+		slotLength = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	return &sourceCounter{slotLength: slotLength, limit: limit}
+}
+
+func (c *sourceCounter) add(ev *WinLogEvent, now time.Time) {
+	epoch := now.UnixNano() / int64(c.slotLength)
+	i := epoch % topSourcesSlots
+	key := sourceKey{ev.ProviderName, ev.EventId}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.slots[i] == nil || c.epochs[i] != epoch {
+		c.slots[i] = make(map[sourceKey]uint64)
+		c.epochs[i] = epoch
+	}
+	c.slots[i][key]++
+}
+
+// top returns the sources with the most events in the window ending at `now`,
+// most frequent first.
+func (c *sourceCounter) top(now time.Time) []SourceCount {
+	epoch := now.UnixNano() / int64(c.slotLength)
+	totals := make(map[sourceKey]uint64)
+	c.mutex.Lock()
+	for i, slot := range c.slots {
+		if epoch-c.epochs[i] >= topSourcesSlots {
+			continue
+		}
+		for key, count := range slot {
+			totals[key] += count
+		}
+	}
+	c.mutex.Unlock()
+
+	counts := make([]SourceCount, 0, len(totals))
+	for key, count := range totals {
+		counts = append(counts, SourceCount{ProviderName: key.provider, EventId: key.eventId, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		if counts[i].ProviderName != counts[j].ProviderName {
+			return counts[i].ProviderName < counts[j].ProviderName
+		}
+		return counts[i].EventId < counts[j].EventId
+	})
+	if len(counts) > c.limit {
+		counts = counts[:c.limit]
+	}
+	return counts
+}
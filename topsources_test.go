@@ -0,0 +1,31 @@
+package winlog
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestTopSources(t *T) {
+	c := newSourceCounter(10*time.Minute, 2)
+	now := time.Unix(1600000000, 0)
+	add := func(provider string, id uint64, n int, at time.Time) {
+		for i := 0; i < n; i++ {
+			c.add(&WinLogEvent{ProviderName: provider, EventId: id}, at)
+		}
+	}
+	add("Old", 1, 100, now.Add(-15*time.Minute))
+	add("A", 1, 3, now.Add(-5*time.Minute))
+	add("A", 1, 2, now)
+	add("A", 2, 1, now)
+	add("B", 7, 4, now)
+
+	top := c.top(now)
+	assertEqual(len(top), 2, t)
+	assertEqual(top[0], SourceCount{ProviderName: "A", EventId: 1, Count: 5}, t)
+	assertEqual(top[1], SourceCount{ProviderName: "B", EventId: 7, Count: 4}, t)
+
+	// The earlier events for A expire
+	top = c.top(now.Add(6 * time.Minute))
+	assertEqual(top[0], SourceCount{ProviderName: "B", EventId: 7, Count: 4}, t)
+	assertEqual(top[1], SourceCount{ProviderName: "A", EventId: 1, Count: 2}, t)
+}
@@ -0,0 +1,67 @@
+//go:build windows
+// +build windows
+
+package winlog
+
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+type syscallTracer struct {
+	logger Logger
+}
+
+var tracer atomic.Value
+
+// SetSyscallTracer enables logging of every wevtapi.dll call, with its
+// duration, result and error code, at LogDebug. Only handles, flags and sizes
+// are logged, never the channel names, queries or buffers passed. Pass nil to
+// disable tracing. This is intended for debugging rendering failures on
+// customer hosts and is too verbose to leave enabled.
+func SetSyscallTracer(logger Logger) {
+	tracer.Store(syscallTracer{logger: logger})
+}
+
+func traceLogger() Logger {
+	t, _ := tracer.Load().(syscallTracer)
+	return t.logger
+}
+
+// traceStart returns the start time of a call if tracing is enabled.
+func traceStart() time.Time {
+	if traceLogger() == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func derefUint32(p *uint32) uint32 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// traceCall logs a completed call. `params` are alternating names and
+// values. Callers only call it if `start` isn't zero, so the parameters
+// aren't built for every call when tracing is disabled.
+func traceCall(proc *windows.LazyProc, start time.Time, r1 uintptr, err error, params ...interface{}) {
+	logger := traceLogger()
+	if logger == nil {
+		return
+	}
+	var errno syscall.Errno
+	if r1 == 0 {
+		errno, _ = err.(syscall.Errno)
+	}
+	keyvals := append([]interface{}{"func", proc.Name}, params...)
+	keyvals = append(keyvals, "duration", time.Since(start), "result", r1, "errno", uint32(errno))
+	if errno != 0 {
+		keyvals = append(keyvals, "error", errno.Error())
+	}
+	logger.Log(LogDebug, "wevtapi call", keyvals...)
+}
@@ -0,0 +1,32 @@
+package winlog
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	. "testing"
+)
+
+func TestSyscallTracer(t *T) {
+	var buf bytes.Buffer
+	SetSyscallTracer(NewStdLogger(log.New(&buf, "", 0), LogDebug))
+	defer SetSyscallTracer(nil)
+
+	// Closing an invalid handle fails with ERROR_INVALID_HANDLE
+	if err := EvtClose(0); err == nil {
+		t.Fatal("Expected an error closing handle 0")
+	}
+	line := buf.String()
+	for _, field := range []string{"DEBUG wevtapi call", "func=EvtClose", "object=0", "result=0", "errno=6", "duration="} {
+		if !strings.Contains(line, field) {
+			t.Fatalf("Missing %q in trace %q", field, line)
+		}
+	}
+
+	// Nothing is traced once the tracer is removed
+	SetSyscallTracer(nil)
+	buf.Reset()
+	assertEqual(traceStart().IsZero(), true, t)
+	EvtClose(0)
+	assertEqual(buf.String(), "", t)
+}
@@ -0,0 +1,196 @@
+package winlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Transform shapes the map of an event for output, so each deployment can
+// rename, drop and derive fields from configuration instead of code. It's
+// applied to a map from CreateMap or CreateMapWith, or with JSON, and is
+// usually loaded from JSON:
+//
+//	{
+//		"derive": {"User": "{{.EventData.TargetDomainName}}\\{{.EventData.TargetUserName}}"},
+//		"rename": {"EventId": "event.code", "EventData.IpAddress": "source.ip"},
+//		"drop":   ["Xml", "Bookmark", "EventData.TargetDomainName"]
+//	}
+//
+// Fields are derived first, from the fields as CreateMap produces them, then
+// renamed, then dropped. Keys name fields of the map, or the values of its
+// nested maps as "EventData.TargetUserName". Renamed fields are moved to the
+// top level. The nested maps are copied before they're changed, so the
+// event isn't modified.
+//
+// The fields are read the first time the Transform is used and must not be
+// modified afterwards.
+type Transform struct {
+	// New fields, keyed by name, from text/template templates executed
+	// with the map. A field whose template refers to a key the event
+	// doesn't have is left out.
+	Derive map[string]string `json:"derive,omitempty"`
+	// Fields to rename, from their key to their new name
+	Rename map[string]string `json:"rename,omitempty"`
+	// Fields to remove
+	Drop []string `json:"drop,omitempty"`
+	// How the event's times are written, before fields are derived. As
+	// CanonicalJSON writes them by JSON, and left as they are by Apply, if
+	// nil.
+	Timestamps *Timestamps `json:"timestamps,omitempty"`
+
+	once       sync.Once
+	compileErr error
+	// Derived field names in order, and their templates
+	derived   []string
+	templates map[string]*template.Template
+}
+
+// LoadTransform reads a Transform from a JSON file, and checks its templates.
+func LoadTransform(path string) (*Transform, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Transform{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("Failed to parse transform %v: %v", path, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Transform) compile() error {
+	t.once.Do(func() {
+		t.templates = make(map[string]*template.Template, len(t.Derive))
+		for name, text := range t.Derive {
+			tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+			if err != nil {
+				t.compileErr = fmt.Errorf("Invalid template for derived field %q: %v", name, err)
+				return
+			}
+			t.templates[name] = tmpl
+			t.derived = append(t.derived, name)
+		}
+		sort.Strings(t.derived)
+	})
+	return t.compileErr
+}
+
+// Validate reports whether every template of Derive parses, so a
+// configuration can be checked before events are written.
+func (t *Transform) Validate() error {
+	return t.compile()
+}
+
+// Apply transforms the event map `m` in place. An error is returned if a
+// template is invalid, in which case the map is left untouched.
+func (t *Transform) Apply(m map[string]interface{}) error {
+	if err := t.compile(); err != nil {
+		return err
+	}
+	if t.Timestamps != nil {
+		for key, value := range m {
+			if at, ok := value.(time.Time); ok {
+				m[key] = t.Timestamps.Value(at)
+			}
+		}
+	}
+	derived := make(map[string]interface{}, len(t.derived))
+	for _, name := range t.derived {
+		var value bytes.Buffer
+		if err := t.templates[name].Execute(&value, m); err == nil {
+			derived[name] = value.String()
+		}
+	}
+	for name, value := range derived {
+		m[name] = value
+	}
+
+	// Take every renamed value before setting any, so fields can be swapped
+	renamed := make(map[string]interface{}, len(t.Rename))
+	for from, to := range t.Rename {
+		if value, ok := takeField(m, from); ok {
+			renamed[to] = value
+		}
+	}
+	for to, value := range renamed {
+		m[to] = value
+	}
+
+	for _, key := range t.Drop {
+		takeField(m, key)
+	}
+	return nil
+}
+
+// JSON renders the event as CanonicalJSON does, with its times written by
+// the Transform's Timestamps, transformed, on a single line.
+func (t *Transform) JSON(ev *WinLogEvent) ([]byte, error) {
+	m := canonicalMap(ev)
+	if t.Timestamps != nil {
+		t.Timestamps.formatTimes(m, ev)
+	}
+	if err := t.Apply(m); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// takeField removes the field `key` from `m` and returns it. A key which
+// isn't in `m` is looked up in the nested map named by its part before the
+// first dot, which is copied rather than modified.
+func takeField(m map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := m[key]; ok {
+		delete(m, key)
+		return value, true
+	}
+	dot := strings.Index(key, ".")
+	if dot < 0 {
+		return nil, false
+	}
+	parent, name := key[:dot], key[dot+1:]
+	switch nested := m[parent].(type) {
+	case map[string]string:
+		value, ok := nested[name]
+		if !ok {
+			return nil, false
+		}
+		copied := make(map[string]string, len(nested))
+		for k, v := range nested {
+			if k != name {
+				copied[k] = v
+			}
+		}
+		m[parent] = copied
+		return value, true
+	case map[string]interface{}:
+		value, ok := nested[name]
+		if !ok {
+			return nil, false
+		}
+		copied := make(map[string]interface{}, len(nested))
+		for k, v := range nested {
+			if k != name {
+				copied[k] = v
+			}
+		}
+		m[parent] = copied
+		return value, true
+	}
+	return nil, false
+}
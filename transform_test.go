@@ -0,0 +1,88 @@
+package winlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	. "testing"
+)
+
+func TestTransform(t *T) {
+	ev := &WinLogEvent{
+		EventId:      4624,
+		ComputerName: "host",
+		EventData:    map[string]string{"TargetUserName": "alice", "TargetDomainName": "CORP", "IpAddress": "10.0.0.1"},
+	}
+	transform := &Transform{
+		Derive: map[string]string{
+			"User":    `{{.EventData.TargetDomainName}}\{{.EventData.TargetUserName}}`,
+			"Missing": `{{.EventData.NoSuchField}}`,
+		},
+		Rename: map[string]string{"EventId": "event.code", "EventData.IpAddress": "source.ip", "NotThere": "x"},
+		Drop:   []string{"Xml", "EventData.TargetDomainName"},
+	}
+	m := ev.CreateMap()
+	if err := transform.Apply(m); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m["User"], `CORP\alice`, t)
+	_, missing := m["Missing"]
+	assertEqual(missing, false, t)
+	assertEqual(m["event.code"], uint64(4624), t)
+	_, eventId := m["EventId"]
+	assertEqual(eventId, false, t)
+	assertEqual(m["source.ip"], "10.0.0.1", t)
+	_, xml := m["Xml"]
+	assertEqual(xml, false, t)
+	data := m["EventData"].(map[string]string)
+	assertEqual(len(data), 1, t)
+	assertEqual(data["TargetUserName"], "alice", t)
+	// The event's own EventData is unchanged
+	assertEqual(len(ev.EventData), 3, t)
+
+	// Flattened keys are matched as they are
+	m = ev.CreateMapWith(MapOptions{Flatten: true})
+	if err := transform.Apply(m); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(m["source.ip"], "10.0.0.1", t)
+	_, domain := m["EventData.TargetDomainName"]
+	assertEqual(domain, false, t)
+
+	line, err := transform.JSON(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(decoded["User"], `CORP\alice`, t)
+	assertEqual(decoded["event.code"], float64(4624), t)
+}
+
+func TestLoadTransform(t *T) {
+	dir, err := ioutil.TempDir("", "transform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "transform.json")
+	if err := ioutil.WriteFile(path, []byte(`{"rename": {"EventId": "id"}, "drop": ["Xml"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	transform, err := LoadTransform(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(transform.Rename["EventId"], "id", t)
+	assertEqual(len(transform.Drop), 1, t)
+
+	if err := ioutil.WriteFile(path, []byte(`{"derive": {"Bad": "{{.Unclosed"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTransform(path); err == nil {
+		t.Error("Expected an error loading an invalid template")
+	}
+}
@@ -0,0 +1,73 @@
+package winlog
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// TruncationPolicy limits the size of the large fields of events, such as
+// PowerShell script blocks or base64 payloads in EventData, so a single
+// huge event can't overwhelm a downstream system. Events which were cut
+// have Truncated set and list the fields in TruncatedFields. Limits are in
+// bytes, and a limit of zero leaves the field whole.
+type TruncationPolicy struct {
+	// Longest value in EventData, UserData or Extracted. Values are cut at a UTF-8
+	// character boundary.
+	MaxValueSize int
+	// Longest Msg
+	MaxMessageSize int
+	// Longest Xml. XML over the limit is dropped rather than cut, since
+	// cut XML wouldn't parse, and XmlErr is set.
+	MaxXmlSize int
+}
+
+// Apply truncates the event's fields which are over the policy's limits.
+func (p *TruncationPolicy) Apply(ev *WinLogEvent) {
+	if p.MaxXmlSize > 0 && len(ev.Xml) > p.MaxXmlSize {
+		ev.XmlErr = fmt.Errorf("XML of %v bytes exceeds the truncation limit of %v", len(ev.Xml), p.MaxXmlSize)
+		ev.Xml = nil
+		ev.markTruncated("Xml")
+	}
+	if p.MaxMessageSize > 0 && len(ev.Msg) > p.MaxMessageSize {
+		ev.Msg = truncateUTF8(ev.Msg, p.MaxMessageSize)
+		ev.markTruncated("Msg")
+	}
+	if p.MaxValueSize > 0 {
+		p.truncateValues(ev, "EventData", ev.EventData)
+		p.truncateValues(ev, "UserData", ev.UserData)
+		p.truncateValues(ev, "Extracted", ev.Extracted)
+	}
+}
+
+func (p *TruncationPolicy) truncateValues(ev *WinLogEvent, field string, values map[string]string) {
+	names := make([]string, 0, len(values))
+	for name, value := range values {
+		if len(value) > p.MaxValueSize {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values[name] = truncateUTF8(values[name], p.MaxValueSize)
+		ev.markTruncated(field + "." + name)
+	}
+}
+
+func (ev *WinLogEvent) markTruncated(field string) {
+	ev.Truncated = true
+	ev.TruncatedFields = append(ev.TruncatedFields, field)
+}
+
+// truncateUTF8 cuts `s` to at most `max` bytes without splitting a
+// character.
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	end := max
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
@@ -0,0 +1,34 @@
+package winlog
+
+import (
+	"strings"
+	. "testing"
+)
+
+func TestTruncationPolicy(t *T) {
+	script := strings.Repeat("Write-Host 'é'\n", 10000)
+	ev := &WinLogEvent{
+		Xml:       []byte("<Event>" + script + "</Event>"),
+		Msg:       "Creating Scriptblock text (1 of 1): " + script,
+		EventData: map[string]string{"ScriptBlockText": script, "Path": "C:\\a.ps1"},
+	}
+	policy := &TruncationPolicy{MaxValueSize: 1001, MaxMessageSize: 64 * 1024, MaxXmlSize: 64 * 1024}
+	policy.Apply(ev)
+
+	assertEqual(ev.Truncated, true, t)
+	assertEqual(strings.Join(ev.TruncatedFields, ","), "Xml,Msg,EventData.ScriptBlockText", t)
+	assertEqual(ev.Xml == nil, true, t)
+	assertEqual(ev.XmlErr != nil, true, t)
+	assertEqual(len(ev.Msg) <= 64*1024, true, t)
+	assertEqual(ev.EventData["Path"], "C:\\a.ps1", t)
+	// Not cut in the middle of the two byte 'é'
+	value := ev.EventData["ScriptBlockText"]
+	assertEqual(len(value) <= 1001, true, t)
+	assertEqual(strings.HasPrefix(script, value), true, t)
+	assertEqual(strings.ToValidUTF8(value, "?"), value, t)
+
+	small := &WinLogEvent{Msg: "short", EventData: map[string]string{"a": "b"}}
+	policy.Apply(small)
+	assertEqual(small.Truncated, false, t)
+	assertEqual(len(small.TruncatedFields), 0, t)
+}
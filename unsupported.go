@@ -0,0 +1,261 @@
+//go:build !windows
+// +build !windows
+
+package winlog
+
+import (
+	"time"
+)
+
+/* Stubs for the functions which need the Windows Event Log, so code using
+   gowinlog compiles and can be tested on other platforms. They all fail with
+   ErrUnsupportedPlatform, so NewWinLogWatcher does too. */
+
+func GetSystemRenderContext() (SysRenderContext, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func CreateListener(channel, query string, startpos EVT_SUBSCRIBE_FLAGS, watcher *LogEventCallbackWrapper) (ListenerHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func CreateListenerFromBookmark(channel, query string, watcher *LogEventCallbackWrapper, bookmarkHandle BookmarkHandle) (ListenerHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func FormatMessage(eventPublisherHandle PublisherHandle, eventHandle EventHandle, format EVT_FORMAT_MESSAGE_FLAGS) (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func GetLastError() error {
+	return ErrUnsupportedPlatform
+}
+
+func RenderEventValues(renderContext SysRenderContext, eventHandle EventHandle) (EvtVariant, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func RenderEventXML(eventHandle EventHandle) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func GetEventPublisherHandle(renderedFields EvtVariant) (PublisherHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func OpenPublisherMetadata(providerName string) (PublisherHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func OpenArchivedPublisherMetadata(providerName, logFilePath string) (PublisherHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func RenderRawEvent(renderContext SysRenderContext, eventHandle EventHandle) (*RenderedEvent, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func RenderEventValuesBuf(renderContext SysRenderContext, eventHandle EventHandle, buf []byte) (EvtVariant, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func RenderEventXMLBuf(eventHandle EventHandle, buf []byte) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func FormatMessageBuf(eventPublisherHandle PublisherHandle, eventHandle EventHandle, format EVT_FORMAT_MESSAGE_FLAGS, buf []byte) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func CloseEventHandle(handle uint64) error {
+	return ErrUnsupportedPlatform
+}
+
+func CancelEventHandle(handle uint64) error {
+	return ErrUnsupportedPlatform
+}
+
+func queryEventLog(session SessionHandle, path, query string, flags uint32) (QueryHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func nextEvents(query QueryHandle, events []EventHandle, timeout time.Duration) (int, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func CreateBookmark() (BookmarkHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func CreateBookmarkFromXml(xmlString string) (BookmarkHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func UpdateBookmark(bookmarkHandle BookmarkHandle, eventHandle EventHandle) error {
+	return ErrUnsupportedPlatform
+}
+
+func RenderBookmark(bookmarkHandle BookmarkHandle) (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func GetChannelInfo(channel string) (ChannelInfo, error) {
+	return ChannelInfo{}, ErrUnsupportedPlatform
+}
+
+func GetEventTemplates(providerName string) (EventTemplates, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func GetPublisherTables(providerName string) (*PublisherTables, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// There are no wevtapi.dll calls to trace on other platforms.
+func SetSyscallTracer(logger Logger) {}
+
+func OpenEventSource(source string) (*EventSource, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (s *EventSource) Report(eventType, category uint16, eventId uint32, strings ...string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (s *EventSource) Close() error {
+	return nil
+}
+
+func RegisterProvider(guid string) (*Provider, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (p *Provider) Write(descriptor EventDescriptor, values ...string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (p *Provider) Close() error {
+	return nil
+}
+
+func RegisterEventSource(log, source, messageFile string) error {
+	return ErrUnsupportedPlatform
+}
+
+func UnregisterEventSource(log, source string) error {
+	return ErrUnsupportedPlatform
+}
+
+func InstallManifest(manifest, binary string) error {
+	return ErrUnsupportedPlatform
+}
+
+func UninstallManifest(manifest string) error {
+	return ErrUnsupportedPlatform
+}
+
+func ListChannels() ([]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ListPublishers() ([]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ExportChannel(channel, query, targetPath string) error {
+	return ErrUnsupportedPlatform
+}
+
+func ExportFile(path, query, targetPath string) error {
+	return ErrUnsupportedPlatform
+}
+
+type PipeSink struct {
+	Name string
+}
+
+func NewPipeSink(name, sddl string) (*PipeSink, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (s *PipeSink) Write(ev *WinLogEvent) error {
+	return ErrUnsupportedPlatform
+}
+
+func (s *PipeSink) Close() error {
+	return nil
+}
+
+func OpenLegacyLog(log string) (*LegacyReader, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (r *LegacyReader) Seek(recordId uint64) {}
+
+func (r *LegacyReader) Next() (*WinLogEvent, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (r *LegacyReader) Close() error {
+	return nil
+}
+
+func GetChannelConfig(channel string) (ChannelConfig, error) {
+	return ChannelConfig{}, ErrUnsupportedPlatform
+}
+
+func SetChannelAccess(channel, sddl string) error {
+	return ErrUnsupportedPlatform
+}
+
+func GrantChannelRead(channel, sid string) error {
+	return ErrUnsupportedPlatform
+}
+
+func GetHostInfo() (*HostInfo, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func OpenSession(login RemoteLogin) (SessionHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func CreateSessionListener(session SessionHandle, channel, query string, startpos EVT_SUBSCRIBE_FLAGS, bookmarkHandle BookmarkHandle, watcher *LogEventCallbackWrapper) (ListenerHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func createPullListener(session SessionHandle, channel, query string, startpos EVT_SUBSCRIBE_FLAGS, bookmarkHandle BookmarkHandle) (ListenerHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func waitPullListener(handle ListenerHandle, timeout time.Duration) error {
+	return ErrUnsupportedPlatform
+}
+
+func OpenLocalizedPublisherMetadata(providerName, locale string) (PublisherHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func OpenSessionPublisherMetadata(session SessionHandle, providerName, locale string) (PublisherHandle, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func GetSessionChannelInfo(session SessionHandle, channel string) (ChannelInfo, error) {
+	return ChannelInfo{}, ErrUnsupportedPlatform
+}
+
+func GetSessionChannelConfig(session SessionHandle, channel string) (ChannelConfig, error) {
+	return ChannelConfig{}, ErrUnsupportedPlatform
+}
+
+func GetSessionEventTemplates(session SessionHandle, providerName string) (EventTemplates, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func GetSessionPublisherTables(session SessionHandle, providerName string) (*PublisherTables, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func loadWevtapi() error {
+	return ErrUnsupportedPlatform
+}
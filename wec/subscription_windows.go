@@ -0,0 +1,428 @@
+//go:build windows
+// +build windows
+
+package wec
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ListSubscriptions returns the names of the collector's subscriptions.
+// Wraps EcOpenSubscriptionEnum and EcEnumNextSubscription.
+func ListSubscriptions() ([]string, error) {
+	enum, err := call(ecOpenSubscriptionEnum, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(syscall.Handle(enum))
+
+	var names []string
+	buf := make([]uint16, 256)
+	for {
+		var used uint32
+		_, err := call(ecEnumNextSubscription, enum, uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+		if err == windows.ERROR_INSUFFICIENT_BUFFER {
+			buf = make([]uint16, used)
+			continue
+		} else if err == windows.ERROR_NO_MORE_ITEMS {
+			return names, nil
+		} else if err != nil {
+			return nil, err
+		}
+		names = append(names, syscall.UTF16ToString(buf[:used]))
+	}
+}
+
+func openSubscription(name string, access, flags uint32) (syscall.Handle, error) {
+	wideName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	handle, err := call(ecOpenSubscription, uintptr(unsafe.Pointer(wideName)), uintptr(access), uintptr(flags))
+	if err != nil {
+		return 0, fmt.Errorf("Failed to open subscription %q: %v", name, err)
+	}
+	return syscall.Handle(handle), nil
+}
+
+// propertyReader reads properties with `get`, remembering the first error.
+type propertyReader struct {
+	get func(id uint32) (variant, error)
+	err error
+}
+
+func (r *propertyReader) variant(id uint32) variant {
+	if r.err != nil {
+		return nil
+	}
+	v, err := r.get(id)
+	if err != nil {
+		r.err = fmt.Errorf("Failed to read property %v: %v", id, err)
+	}
+	return v
+}
+
+func (r *propertyReader) str(id uint32) string {
+	v := r.variant(id)
+	if v == nil {
+		return ""
+	}
+	s, err := v.string()
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return s
+}
+
+func (r *propertyReader) uint(id uint32) uint32 {
+	v := r.variant(id)
+	if v == nil {
+		return 0
+	}
+	n, err := v.uint32()
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return n
+}
+
+func (r *propertyReader) boolean(id uint32) bool {
+	v := r.variant(id)
+	if v == nil {
+		return false
+	}
+	b, err := v.boolean()
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return b
+}
+
+func (r *propertyReader) time(id uint32) time.Time {
+	v := r.variant(id)
+	if v == nil {
+		return time.Time{}
+	}
+	t, err := v.dateTime()
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return t
+}
+
+// GetSubscription reads the configuration of a subscription. Wraps
+// EcOpenSubscription and EcGetSubscriptionProperty.
+func GetSubscription(name string) (*Subscription, error) {
+	handle, err := openSubscription(name, ecReadAccess, ecOpenExisting)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(handle)
+
+	r := &propertyReader{get: func(id uint32) (variant, error) {
+		return subscriptionProperty(handle, id)
+	}}
+	sub := &Subscription{
+		Name:                         name,
+		Enabled:                      r.boolean(ecSubscriptionEnabled),
+		Type:                         SubscriptionType(r.uint(ecSubscriptionType)),
+		Description:                  r.str(ecSubscriptionDescription),
+		Query:                        r.str(ecSubscriptionQuery),
+		LogFile:                      r.str(ecSubscriptionLogFile),
+		ReadExistingEvents:           r.boolean(ecSubscriptionReadExistingEvents),
+		ContentFormat:                ContentFormat(r.uint(ecSubscriptionContentFormat)),
+		Locale:                       r.str(ecSubscriptionLocale),
+		ConfigurationMode:            ConfigurationMode(r.uint(ecSubscriptionConfigurationMode)),
+		DeliveryMode:                 DeliveryMode(r.uint(ecSubscriptionDeliveryMode)),
+		MaxItems:                     r.uint(ecSubscriptionDeliveryMaxItems),
+		MaxLatency:                   time.Duration(r.uint(ecSubscriptionDeliveryMaxLatencyTime)) * time.Millisecond,
+		HeartbeatInterval:            time.Duration(r.uint(ecSubscriptionHeartbeatInterval)) * time.Millisecond,
+		AllowedSourceDomainComputers: r.str(ecSubscriptionAllowedSourceDomainComputers),
+		CredentialsType:              CredentialsType(r.uint(ecSubscriptionCredentialsType)),
+		CommonUserName:               r.str(ecSubscriptionCommonUserName),
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("Subscription %q: %v", name, r.err)
+	}
+	if sub.Type == CollectorInitiated {
+		if sub.EventSources, err = getEventSources(handle); err != nil {
+			return nil, fmt.Errorf("Subscription %q: %v", name, err)
+		}
+	}
+	return sub, nil
+}
+
+// eventSourceArray opens the array of a subscription's event sources, which
+// must be closed with closeHandle.
+func eventSourceArray(handle syscall.Handle) (syscall.Handle, uint32, error) {
+	v, err := subscriptionProperty(handle, ecSubscriptionEventSources)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to read event sources: %v", err)
+	}
+	elem, err := v.typed(ecVarTypeHandle)
+	if err != nil {
+		return 0, 0, err
+	}
+	array := syscall.Handle(elem.Data)
+	if array == 0 {
+		return 0, 0, nil
+	}
+	var size uint32
+	if _, err := call(ecGetObjectArraySize, uintptr(array), uintptr(unsafe.Pointer(&size))); err != nil {
+		closeHandle(array)
+		return 0, 0, err
+	}
+	return array, size, nil
+}
+
+func getEventSources(handle syscall.Handle) ([]EventSource, error) {
+	array, size, err := eventSourceArray(handle)
+	if err != nil || array == 0 {
+		return nil, err
+	}
+	defer closeHandle(array)
+
+	sources := make([]EventSource, size)
+	for i := range sources {
+		index := uint32(i)
+		r := &propertyReader{get: func(id uint32) (variant, error) {
+			return arrayProperty(array, id, index)
+		}}
+		sources[i] = EventSource{
+			Address:  r.str(ecSubscriptionEventSourceAddress),
+			Enabled:  r.boolean(ecSubscriptionEventSourceEnabled),
+			UserName: r.str(ecSubscriptionEventSourceUserName),
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	return sources, nil
+}
+
+// propertyWriter sets properties with `set`, remembering the first error.
+type propertyWriter struct {
+	set func(id uint32, value *ecVariant) error
+	err error
+}
+
+func (w *propertyWriter) variant(id uint32, value ecVariant) {
+	if w.err != nil {
+		return
+	}
+	if err := w.set(id, &value); err != nil {
+		w.err = fmt.Errorf("Failed to set property %v: %v", id, err)
+	}
+}
+
+func (w *propertyWriter) str(id uint32, value string) {
+	v, wide, err := stringVariant(value)
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		return
+	}
+	w.variant(id, v)
+	runtime.KeepAlive(wide)
+}
+
+func (w *propertyWriter) uint(id uint32, value uint32) {
+	w.variant(id, uint32Variant(value))
+}
+
+func (w *propertyWriter) boolean(id uint32, value bool) {
+	w.variant(id, boolVariant(value))
+}
+
+// SaveSubscription creates a subscription, or replaces the configuration
+// of an existing one with the same name. Passwords and the Locale are only
+// set if given, and the delivery settings only for the Custom
+// ConfigurationMode. Wraps EcOpenSubscription, EcSetSubscriptionProperty
+// and EcSaveSubscription.
+func SaveSubscription(sub *Subscription) error {
+	handle, err := openSubscription(sub.Name, ecReadAccess|ecWriteAccess, ecOpenAlways)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(handle)
+
+	w := &propertyWriter{set: func(id uint32, value *ecVariant) error {
+		_, err := call(ecSetSubscriptionProperty, uintptr(handle), uintptr(id), 0, uintptr(unsafe.Pointer(value)))
+		return err
+	}}
+	w.uint(ecSubscriptionType, uint32(sub.Type))
+	w.str(ecSubscriptionDescription, sub.Description)
+	w.str(ecSubscriptionQuery, sub.Query)
+	w.str(ecSubscriptionLogFile, sub.LogFile)
+	w.boolean(ecSubscriptionReadExistingEvents, sub.ReadExistingEvents)
+	if sub.ContentFormat != 0 {
+		w.uint(ecSubscriptionContentFormat, uint32(sub.ContentFormat))
+	}
+	if sub.Locale != "" {
+		w.str(ecSubscriptionLocale, sub.Locale)
+	}
+	w.uint(ecSubscriptionConfigurationMode, uint32(sub.ConfigurationMode))
+	if sub.ConfigurationMode == Custom {
+		if sub.DeliveryMode != 0 {
+			w.uint(ecSubscriptionDeliveryMode, uint32(sub.DeliveryMode))
+		}
+		w.uint(ecSubscriptionDeliveryMaxItems, sub.MaxItems)
+		w.uint(ecSubscriptionDeliveryMaxLatencyTime, uint32(sub.MaxLatency/time.Millisecond))
+		w.uint(ecSubscriptionHeartbeatInterval, uint32(sub.HeartbeatInterval/time.Millisecond))
+	}
+	if sub.Type == SourceInitiated {
+		w.str(ecSubscriptionAllowedSourceDomainComputers, sub.AllowedSourceDomainComputers)
+	} else {
+		w.uint(ecSubscriptionCredentialsType, uint32(sub.CredentialsType))
+		if sub.CommonUserName != "" {
+			w.str(ecSubscriptionCommonUserName, sub.CommonUserName)
+		}
+		if sub.CommonPassword != "" {
+			w.str(ecSubscriptionCommonPassword, sub.CommonPassword)
+		}
+	}
+	w.boolean(ecSubscriptionEnabled, sub.Enabled)
+	if w.err != nil {
+		return fmt.Errorf("Subscription %q: %v", sub.Name, w.err)
+	}
+	if sub.Type == CollectorInitiated {
+		if err := setEventSources(handle, sub.EventSources); err != nil {
+			return fmt.Errorf("Subscription %q: %v", sub.Name, err)
+		}
+	}
+	if _, err := call(ecSaveSubscription, uintptr(handle), 0); err != nil {
+		return fmt.Errorf("Failed to save subscription %q: %v", sub.Name, err)
+	}
+	return nil
+}
+
+// setEventSources replaces the event sources of a collector initiated
+// subscription.
+func setEventSources(handle syscall.Handle, sources []EventSource) error {
+	array, size, err := eventSourceArray(handle)
+	if err != nil {
+		return err
+	} else if array == 0 {
+		return fmt.Errorf("Subscription has no event source array")
+	}
+	defer closeHandle(array)
+
+	for i := size; i > 0; i-- {
+		if _, err := call(ecRemoveObjectArrayElement, uintptr(array), uintptr(i-1)); err != nil {
+			return fmt.Errorf("Failed to remove event source: %v", err)
+		}
+	}
+	for i, source := range sources {
+		index := uint32(i)
+		if _, err := call(ecInsertObjectArrayElement, uintptr(array), uintptr(index)); err != nil {
+			return fmt.Errorf("Failed to add event source %q: %v", source.Address, err)
+		}
+		w := &propertyWriter{set: func(id uint32, value *ecVariant) error {
+			_, err := call(ecSetObjectArrayProperty, uintptr(array), uintptr(id), uintptr(index), 0, uintptr(unsafe.Pointer(value)))
+			return err
+		}}
+		w.str(ecSubscriptionEventSourceAddress, source.Address)
+		w.boolean(ecSubscriptionEventSourceEnabled, source.Enabled)
+		if source.UserName != "" {
+			w.str(ecSubscriptionEventSourceUserName, source.UserName)
+		}
+		if source.Password != "" {
+			w.str(ecSubscriptionEventSourcePassword, source.Password)
+		}
+		if w.err != nil {
+			return fmt.Errorf("Event source %q: %v", source.Address, w.err)
+		}
+	}
+	return nil
+}
+
+// DeleteSubscription deletes a subscription. Wraps EcDeleteSubscription.
+func DeleteSubscription(name string) error {
+	wideName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if _, err := call(ecDeleteSubscription, uintptr(unsafe.Pointer(wideName)), 0); err != nil {
+		return fmt.Errorf("Failed to delete subscription %q: %v", name, err)
+	}
+	return nil
+}
+
+// RetrySubscription makes the collector retry connecting to a source of a
+// subscription, or to all of its sources if `source` is "". Wraps
+// EcRetrySubscription.
+func RetrySubscription(name, source string) error {
+	wideName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var wideSource *uint16
+	if source != "" {
+		if wideSource, err = syscall.UTF16PtrFromString(source); err != nil {
+			return err
+		}
+	}
+	if _, err := call(ecRetrySubscription, uintptr(unsafe.Pointer(wideName)), uintptr(unsafe.Pointer(wideSource)), 0); err != nil {
+		return fmt.Errorf("Failed to retry subscription %q: %v", name, err)
+	}
+	return nil
+}
+
+// GetStatus reads the runtime status of a subscription and each of its
+// sources. Wraps EcGetSubscriptionRunTimeStatus.
+func GetStatus(name string) (*Status, error) {
+	wideName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &propertyReader{get: func(id uint32) (variant, error) {
+		return runtimeStatus(wideName, nil, id)
+	}}
+	status := &Status{
+		State:            RuntimeState(r.uint(ecStatusActive)),
+		LastError:        r.uint(ecStatusLastError),
+		LastErrorMessage: r.str(ecStatusLastErrorMessage),
+		LastErrorTime:    r.time(ecStatusLastErrorTime),
+		NextRetryTime:    r.time(ecStatusNextRetryTime),
+	}
+	var sources []string
+	if v := r.variant(ecStatusEventSources); v != nil {
+		sources, err = v.strings()
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("Subscription %q: %v", name, r.err)
+	}
+
+	for _, source := range sources {
+		wideSource, err := syscall.UTF16PtrFromString(source)
+		if err != nil {
+			return nil, err
+		}
+		r := &propertyReader{get: func(id uint32) (variant, error) {
+			return runtimeStatus(wideName, wideSource, id)
+		}}
+		status.Sources = append(status.Sources, SourceStatus{
+			Name:             source,
+			State:            RuntimeState(r.uint(ecStatusActive)),
+			LastError:        r.uint(ecStatusLastError),
+			LastErrorMessage: r.str(ecStatusLastErrorMessage),
+			LastErrorTime:    r.time(ecStatusLastErrorTime),
+			NextRetryTime:    r.time(ecStatusNextRetryTime),
+			LastHeartbeat:    r.time(ecStatusLastHeartbeatTime),
+		})
+		if r.err != nil {
+			return nil, fmt.Errorf("Subscription %q source %q: %v", name, source, r.err)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package wec
+
+import (
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+/* Stubs so code managing a collector compiles on other platforms. They all
+   fail with winlog.ErrUnsupportedPlatform. */
+
+func ListSubscriptions() ([]string, error) {
+	return nil, winlog.ErrUnsupportedPlatform
+}
+
+func GetSubscription(name string) (*Subscription, error) {
+	return nil, winlog.ErrUnsupportedPlatform
+}
+
+func SaveSubscription(sub *Subscription) error {
+	return winlog.ErrUnsupportedPlatform
+}
+
+func DeleteSubscription(name string) error {
+	return winlog.ErrUnsupportedPlatform
+}
+
+func RetrySubscription(name, source string) error {
+	return winlog.ErrUnsupportedPlatform
+}
+
+func GetStatus(name string) (*Status, error) {
+	return nil, winlog.ErrUnsupportedPlatform
+}
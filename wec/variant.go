@@ -0,0 +1,168 @@
+package wec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// Types of an EC_VARIANT
+const (
+	ecVarTypeNull     = 0
+	ecVarTypeBoolean  = 1
+	ecVarTypeUInt32   = 2
+	ecVarTypeDateTime = 3
+	ecVarTypeString   = 4
+	ecVarTypeHandle   = 5
+	// Flag of array types
+	ecVarTypeArray = 128
+)
+
+// ecVariant is an EC_VARIANT structure.
+type ecVariant struct {
+	Data  uint64
+	Count uint32
+	Type  uint32
+}
+
+// Size of an EC_VARIANT structure
+const ecVariantSize = 16
+
+// Seconds between the FILETIME and Unix epochs
+const fileTimeEpoch = 11644473600
+
+// variant is a buffer filled in by EcGetSubscriptionProperty and the other
+// wecapi getters: an EC_VARIANT followed by the strings and arrays it
+// points to.
+type variant []byte
+
+func (v variant) elem() (*ecVariant, error) {
+	if len(v) < ecVariantSize {
+		return nil, fmt.Errorf("EC_VARIANT buffer is too short, %v bytes", len(v))
+	}
+	return (*ecVariant)(unsafe.Pointer(&v[0])), nil
+}
+
+// typed returns the EC_VARIANT if it has the type, or a zero value if it's
+// null, as it is for properties which don't apply or were never set.
+func (v variant) typed(varType uint32) (*ecVariant, error) {
+	elem, err := v.elem()
+	if err != nil {
+		return nil, err
+	}
+	if elem.Type == ecVarTypeNull {
+		return &ecVariant{}, nil
+	} else if elem.Type != varType {
+		return nil, fmt.Errorf("EC_VARIANT has type %v, expected %v", elem.Type, varType)
+	}
+	return elem, nil
+}
+
+func (v variant) boolean() (bool, error) {
+	elem, err := v.typed(ecVarTypeBoolean)
+	if err != nil {
+		return false, err
+	}
+	return uint32(elem.Data) != 0, nil
+}
+
+func (v variant) uint32() (uint32, error) {
+	elem, err := v.typed(ecVarTypeUInt32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(elem.Data), nil
+}
+
+// dateTime converts a FILETIME. Zero FILETIMEs, which wecapi returns for
+// times which haven't happened, are the zero Time.
+func (v variant) dateTime() (time.Time, error) {
+	elem, err := v.typed(ecVarTypeDateTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fileTimeToTime(elem.Data), nil
+}
+
+func fileTimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(ft/10000000)-fileTimeEpoch, int64(ft%10000000)*100)
+}
+
+func (v variant) string() (string, error) {
+	elem, err := v.elem()
+	if err != nil {
+		return "", err
+	}
+	if elem.Type == ecVarTypeNull {
+		return "", nil
+	} else if elem.Type != ecVarTypeString {
+		return "", fmt.Errorf("EC_VARIANT has type %v, expected %v", elem.Type, ecVarTypeString)
+	}
+	return v.stringAt(uintptr(elem.Data))
+}
+
+// strings reads an array of strings, as returned for the event sources of a
+// subscription's runtime status.
+func (v variant) strings() ([]string, error) {
+	elem, err := v.elem()
+	if err != nil {
+		return nil, err
+	}
+	if elem.Type == ecVarTypeNull {
+		return nil, nil
+	} else if elem.Type != ecVarTypeString|ecVarTypeArray {
+		return nil, fmt.Errorf("EC_VARIANT has type %v, expected a string array", elem.Type)
+	}
+	pointerSize := uintptr(unsafe.Sizeof(uintptr(0)))
+	offset, err := v.offset(uintptr(elem.Data), uintptr(elem.Count)*pointerSize)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, elem.Count)
+	for i := range values {
+		at := v[offset+uintptr(i)*pointerSize:]
+		var ptr uintptr
+		if pointerSize == 8 {
+			ptr = uintptr(binary.LittleEndian.Uint64(at))
+		} else {
+			ptr = uintptr(binary.LittleEndian.Uint32(at))
+		}
+		if values[i], err = v.stringAt(ptr); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// offset converts a pointer into the buffer to an index, checking the
+// `size` bytes it points to are within the buffer. Pointers elsewhere
+// aren't followed.
+func (v variant) offset(ptr, size uintptr) (uintptr, error) {
+	start := uintptr(unsafe.Pointer(&v[0]))
+	if ptr < start || ptr+size > start+uintptr(len(v)) {
+		return 0, fmt.Errorf("EC_VARIANT points outside the buffer")
+	}
+	return ptr - start, nil
+}
+
+func (v variant) stringAt(ptr uintptr) (string, error) {
+	offset, err := v.offset(ptr, 0)
+	if err != nil {
+		return "", err
+	}
+	data := v[offset:]
+	wide := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := binary.LittleEndian.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		wide = append(wide, c)
+	}
+	return string(utf16.Decode(wide)), nil
+}
@@ -0,0 +1,250 @@
+// Package wec manages the subscriptions of a Windows Event Collector through
+// wecapi.dll, the API behind wecutil:
+//
+//	err := wec.SaveSubscription(&wec.Subscription{
+//		Name:                         "Servers",
+//		Enabled:                      true,
+//		Type:                         wec.SourceInitiated,
+//		Query:                        `<QueryList><Query Path="Security"><Select>*</Select></Query></QueryList>`,
+//		LogFile:                      "ForwardedEvents",
+//		ContentFormat:                wec.RenderedText,
+//		AllowedSourceDomainComputers: "O:NSG:BAD:P(A;;GA;;;DC)S:",
+//	})
+//	status, err := wec.GetStatus("Servers")
+//	for _, source := range status.Sources {
+//		log.Printf("%v %v, last heartbeat %v", source.Name, source.State, source.LastHeartbeat)
+//	}
+//
+// The Windows Event Collector service must be running, and managing
+// subscriptions requires administrator rights. wecapi.dll is only loaded
+// when first used. On other platforms the functions return
+// winlog.ErrUnsupportedPlatform.
+package wec
+
+import (
+	"fmt"
+	"time"
+)
+
+// SubscriptionType is whether the collector or the event sources start
+// forwarding.
+type SubscriptionType uint32
+
+const (
+	// Sources are configured by group policy to connect to the collector
+	SourceInitiated SubscriptionType = 0
+	// The collector connects to the subscription's EventSources
+	CollectorInitiated SubscriptionType = 1
+)
+
+func (t SubscriptionType) String() string {
+	switch t {
+	case SourceInitiated:
+		return "SourceInitiated"
+	case CollectorInitiated:
+		return "CollectorInitiated"
+	}
+	return fmt.Sprintf("SubscriptionType(%d)", uint32(t))
+}
+
+// ConfigurationMode selects preset delivery settings, or Custom to use the
+// subscription's MaxItems and MaxLatency.
+type ConfigurationMode uint32
+
+const (
+	Normal       ConfigurationMode = 0
+	Custom       ConfigurationMode = 1
+	MinLatency   ConfigurationMode = 2
+	MinBandwidth ConfigurationMode = 3
+)
+
+func (m ConfigurationMode) String() string {
+	switch m {
+	case Normal:
+		return "Normal"
+	case Custom:
+		return "Custom"
+	case MinLatency:
+		return "MinLatency"
+	case MinBandwidth:
+		return "MinBandwidth"
+	}
+	return fmt.Sprintf("ConfigurationMode(%d)", uint32(m))
+}
+
+// DeliveryMode is whether sources push events or the collector pulls them.
+type DeliveryMode uint32
+
+const (
+	Pull DeliveryMode = 1
+	Push DeliveryMode = 2
+)
+
+func (m DeliveryMode) String() string {
+	switch m {
+	case Pull:
+		return "Pull"
+	case Push:
+		return "Push"
+	}
+	return fmt.Sprintf("DeliveryMode(%d)", uint32(m))
+}
+
+// ContentFormat is whether forwarded events include their rendered message
+// and other localized text.
+type ContentFormat uint32
+
+const (
+	Events       ContentFormat = 1
+	RenderedText ContentFormat = 2
+)
+
+func (f ContentFormat) String() string {
+	switch f {
+	case Events:
+		return "Events"
+	case RenderedText:
+		return "RenderedText"
+	}
+	return fmt.Sprintf("ContentFormat(%d)", uint32(f))
+}
+
+// CredentialsType is how a collector initiated subscription authenticates
+// to its sources.
+type CredentialsType uint32
+
+const (
+	DefaultCredentials      CredentialsType = 0
+	NegotiateCredentials    CredentialsType = 1
+	DigestCredentials       CredentialsType = 2
+	BasicCredentials        CredentialsType = 3
+	LocalMachineCredentials CredentialsType = 4
+)
+
+func (c CredentialsType) String() string {
+	switch c {
+	case DefaultCredentials:
+		return "Default"
+	case NegotiateCredentials:
+		return "Negotiate"
+	case DigestCredentials:
+		return "Digest"
+	case BasicCredentials:
+		return "Basic"
+	case LocalMachineCredentials:
+		return "LocalMachine"
+	}
+	return fmt.Sprintf("CredentialsType(%d)", uint32(c))
+}
+
+// RuntimeState is whether a subscription, or one of its sources, is
+// forwarding events.
+type RuntimeState uint32
+
+const (
+	Active   RuntimeState = 1
+	Disabled RuntimeState = 2
+	Inactive RuntimeState = 3
+	// Connecting, or waiting to retry after an error
+	Trying RuntimeState = 4
+)
+
+func (s RuntimeState) String() string {
+	switch s {
+	case Active:
+		return "Active"
+	case Disabled:
+		return "Disabled"
+	case Inactive:
+		return "Inactive"
+	case Trying:
+		return "Trying"
+	}
+	return fmt.Sprintf("RuntimeState(%d)", uint32(s))
+}
+
+// Subscription is the configuration of a subscription, as shown by
+// "wecutil gs".
+type Subscription struct {
+	Name        string
+	Enabled     bool
+	Type        SubscriptionType
+	Description string
+	// Structured XML query selecting the events to forward
+	Query string
+	// Channel the forwarded events are written to, such as
+	// ForwardedEvents
+	LogFile string
+	// Forward the events already in the sources' logs, not just new ones
+	ReadExistingEvents bool
+	ContentFormat      ContentFormat
+	// Locale of the rendered text, such as "en-US"
+	Locale string
+
+	ConfigurationMode ConfigurationMode
+	// Only used with the Custom ConfigurationMode
+	DeliveryMode      DeliveryMode
+	MaxItems          uint32
+	MaxLatency        time.Duration
+	HeartbeatInterval time.Duration
+
+	// For source initiated subscriptions, the SDDL of the domain computers
+	// allowed to forward events
+	AllowedSourceDomainComputers string
+
+	// For collector initiated subscriptions, the computers to collect from
+	// and how to authenticate to them
+	EventSources    []EventSource
+	CredentialsType CredentialsType
+	CommonUserName  string
+	// Only written by SaveSubscription. Never read back.
+	CommonPassword string
+}
+
+// EventSource is a computer a collector initiated subscription collects
+// events from.
+type EventSource struct {
+	Address string
+	Enabled bool
+	// Overrides the subscription's CommonUserName if set
+	UserName string
+	// Only written by SaveSubscription. Never read back.
+	Password string
+}
+
+// Status is the runtime status of a subscription, as shown by "wecutil gr".
+type Status struct {
+	State            RuntimeState
+	LastError        uint32
+	LastErrorMessage string
+	LastErrorTime    time.Time
+	NextRetryTime    time.Time
+	Sources          []SourceStatus
+}
+
+// SourceStatus is the runtime status of one of a subscription's sources.
+// For source initiated subscriptions, sources appear once they first
+// connect.
+type SourceStatus struct {
+	Name             string
+	State            RuntimeState
+	LastError        uint32
+	LastErrorMessage string
+	LastErrorTime    time.Time
+	NextRetryTime    time.Time
+	// Zero if the source hasn't sent a heartbeat
+	LastHeartbeat time.Time
+}
+
+// Stale returns the sources which haven't sent a heartbeat in `maxAge`
+// before `now`, including those which never have, so a collector can alert
+// on sources which stopped forwarding.
+func (s *Status) Stale(now time.Time, maxAge time.Duration) []SourceStatus {
+	var stale []SourceStatus
+	for _, source := range s.Sources {
+		if source.LastHeartbeat.IsZero() || now.Sub(source.LastHeartbeat) > maxAge {
+			stale = append(stale, source)
+		}
+	}
+	return stale
+}
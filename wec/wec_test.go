@@ -0,0 +1,130 @@
+package wec
+
+import (
+	"encoding/binary"
+	. "testing"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+// newVariant builds an EC_VARIANT buffer as wecapi fills it in, with room
+// for `extra` bytes of data after the structure.
+func newVariant(varType, count uint32, data uint64, extra int) variant {
+	v := make(variant, ecVariantSize+extra)
+	binary.LittleEndian.PutUint64(v, data)
+	binary.LittleEndian.PutUint32(v[8:], count)
+	binary.LittleEndian.PutUint32(v[12:], varType)
+	return v
+}
+
+func (v variant) addr(offset int) uint64 {
+	return uint64(uintptr(unsafe.Pointer(&v[offset])))
+}
+
+// putString writes a NUL-terminated UTF-16 string at `offset`.
+func (v variant) putString(offset int, s string) {
+	for i, c := range append(utf16.Encode([]rune(s)), 0) {
+		binary.LittleEndian.PutUint16(v[offset+2*i:], c)
+	}
+}
+
+func TestVariantScalars(t *T) {
+	b, err := newVariant(ecVarTypeBoolean, 0, 1, 0).boolean()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(b, true, t)
+	n, err := newVariant(ecVarTypeUInt32, 0, 30000, 0).uint32()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(n, uint32(30000), t)
+	if _, err := newVariant(ecVarTypeString, 0, 0, 0).uint32(); err == nil {
+		t.Fatal("Expected an error for the wrong type")
+	}
+	if _, err := variant(nil).boolean(); err == nil {
+		t.Fatal("Expected an error for a short buffer")
+	}
+
+	// 2021-03-04T05:06:07Z
+	tm, err := newVariant(ecVarTypeDateTime, 0, 132593079670000000, 0).dateTime()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(tm.UTC(), time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), t)
+
+	// Null properties read as zero values
+	tm, err = newVariant(ecVarTypeNull, 0, 0, 0).dateTime()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(tm.IsZero(), true, t)
+	n, err = newVariant(ecVarTypeNull, 0, 0, 0).uint32()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(n, uint32(0), t)
+}
+
+func TestVariantString(t *T) {
+	v := newVariant(ecVarTypeString, 0, 0, 32)
+	binary.LittleEndian.PutUint64(v, v.addr(ecVariantSize))
+	v.putString(ecVariantSize, "Servers")
+	s, err := v.string()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(s, "Servers", t)
+
+	s, err = newVariant(ecVarTypeNull, 0, 0, 0).string()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(s, "", t)
+
+	// Pointers outside the buffer aren't followed
+	other := newVariant(ecVarTypeString, 0, 0, 32)
+	binary.LittleEndian.PutUint64(v, other.addr(ecVariantSize))
+	if _, err := v.string(); err == nil {
+		t.Fatal("Expected an error for a pointer outside the buffer")
+	}
+}
+
+func TestVariantStrings(t *T) {
+	pointerSize := int(unsafe.Sizeof(uintptr(0)))
+	v := newVariant(ecVarTypeString|ecVarTypeArray, 2, 0, 2*pointerSize+64)
+	array := ecVariantSize
+	binary.LittleEndian.PutUint64(v, v.addr(array))
+	for i, s := range []string{"ws1.example.com", "ws2.example.com"} {
+		offset := array + 2*pointerSize + 32*i
+		if pointerSize == 8 {
+			binary.LittleEndian.PutUint64(v[array+i*pointerSize:], v.addr(offset))
+		} else {
+			binary.LittleEndian.PutUint32(v[array+i*pointerSize:], uint32(v.addr(offset)))
+		}
+		v.putString(offset, s)
+	}
+	values, err := v.strings()
+	testutil.AssertEqual(err, nil, t)
+	testutil.AssertEqual(len(values), 2, t)
+	testutil.AssertEqual(values[0], "ws1.example.com", t)
+	testutil.AssertEqual(values[1], "ws2.example.com", t)
+
+	// The array must fit in the buffer
+	binary.LittleEndian.PutUint32(v[8:], 100)
+	if _, err := v.strings(); err == nil {
+		t.Fatal("Expected an error for an array past the end of the buffer")
+	}
+}
+
+func TestStale(t *T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	status := &Status{Sources: []SourceStatus{
+		{Name: "fresh", LastHeartbeat: now.Add(-time.Minute)},
+		{Name: "stale", LastHeartbeat: now.Add(-time.Hour)},
+		{Name: "never"},
+	}}
+	stale := status.Stale(now, 15*time.Minute)
+	testutil.AssertEqual(len(stale), 2, t)
+	testutil.AssertEqual(stale[0].Name, "stale", t)
+	testutil.AssertEqual(stale[1].Name, "never", t)
+}
+
+func TestStrings(t *T) {
+	testutil.AssertEqual(CollectorInitiated.String(), "CollectorInitiated", t)
+	testutil.AssertEqual(MinLatency.String(), "MinLatency", t)
+	testutil.AssertEqual(Push.String(), "Push", t)
+	testutil.AssertEqual(RenderedText.String(), "RenderedText", t)
+	testutil.AssertEqual(NegotiateCredentials.String(), "Negotiate", t)
+	testutil.AssertEqual(Trying.String(), "Trying", t)
+	testutil.AssertEqual(RuntimeState(9).String(), "RuntimeState(9)", t)
+}
@@ -0,0 +1,169 @@
+//go:build windows
+// +build windows
+
+package wec
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+/* Interop code for wecapi.dll */
+
+var (
+	wecapiDll = windows.NewLazySystemDLL("wecapi.dll")
+
+	ecOpenSubscriptionEnum         = wecapiDll.NewProc("EcOpenSubscriptionEnum")
+	ecEnumNextSubscription         = wecapiDll.NewProc("EcEnumNextSubscription")
+	ecOpenSubscription             = wecapiDll.NewProc("EcOpenSubscription")
+	ecGetSubscriptionProperty      = wecapiDll.NewProc("EcGetSubscriptionProperty")
+	ecSetSubscriptionProperty      = wecapiDll.NewProc("EcSetSubscriptionProperty")
+	ecSaveSubscription             = wecapiDll.NewProc("EcSaveSubscription")
+	ecDeleteSubscription           = wecapiDll.NewProc("EcDeleteSubscription")
+	ecGetSubscriptionRunTimeStatus = wecapiDll.NewProc("EcGetSubscriptionRunTimeStatus")
+	ecRetrySubscription            = wecapiDll.NewProc("EcRetrySubscription")
+	ecClose                        = wecapiDll.NewProc("EcClose")
+	ecGetObjectArraySize           = wecapiDll.NewProc("EcGetObjectArraySize")
+	ecGetObjectArrayProperty       = wecapiDll.NewProc("EcGetObjectArrayProperty")
+	ecSetObjectArrayProperty       = wecapiDll.NewProc("EcSetObjectArrayProperty")
+	ecInsertObjectArrayElement     = wecapiDll.NewProc("EcInsertObjectArrayElement")
+	ecRemoveObjectArrayElement     = wecapiDll.NewProc("EcRemoveObjectArrayElement")
+)
+
+// Access and open flags of EcOpenSubscription
+const (
+	ecReadAccess   = 1
+	ecWriteAccess  = 2
+	ecOpenAlways   = 0
+	ecCreateNew    = 1
+	ecOpenExisting = 2
+)
+
+// EC_SUBSCRIPTION_PROPERTY_ID values
+const (
+	ecSubscriptionEnabled = iota
+	ecSubscriptionEventSources
+	ecSubscriptionEventSourceAddress
+	ecSubscriptionEventSourceEnabled
+	ecSubscriptionEventSourceUserName
+	ecSubscriptionEventSourcePassword
+	ecSubscriptionDescription
+	ecSubscriptionURI
+	ecSubscriptionConfigurationMode
+	ecSubscriptionExpires
+	ecSubscriptionQuery
+	ecSubscriptionTransportName
+	ecSubscriptionTransportPort
+	ecSubscriptionDeliveryMode
+	ecSubscriptionDeliveryMaxItems
+	ecSubscriptionDeliveryMaxLatencyTime
+	ecSubscriptionHeartbeatInterval
+	ecSubscriptionLocale
+	ecSubscriptionContentFormat
+	ecSubscriptionLogFile
+	ecSubscriptionPublisherName
+	ecSubscriptionCredentialsType
+	ecSubscriptionCommonUserName
+	ecSubscriptionCommonPassword
+	ecSubscriptionHostName
+	ecSubscriptionReadExistingEvents
+	ecSubscriptionDialect
+	ecSubscriptionType
+	ecSubscriptionAllowedIssuerCAs
+	ecSubscriptionAllowedSubjects
+	ecSubscriptionDeniedSubjects
+	ecSubscriptionAllowedSourceDomainComputers
+)
+
+// EC_SUBSCRIPTION_RUNTIME_STATUS_INFO_ID values
+const (
+	ecStatusActive = iota
+	ecStatusLastError
+	ecStatusLastErrorMessage
+	ecStatusLastErrorTime
+	ecStatusNextRetryTime
+	ecStatusEventSources
+	ecStatusLastHeartbeatTime
+)
+
+// call calls a wecapi function which returns a BOOL or handle, returning
+// the error if it failed. Returns an error rather than panicking if
+// wecapi.dll or the function is missing.
+func call(proc *windows.LazyProc, args ...uintptr) (uintptr, error) {
+	if err := proc.Find(); err != nil {
+		return 0, err
+	}
+	r1, _, err := proc.Call(args...)
+	if r1 == 0 {
+		return 0, err
+	}
+	return r1, nil
+}
+
+func closeHandle(handle syscall.Handle) error {
+	_, err := call(ecClose, uintptr(handle))
+	return err
+}
+
+// getVariant calls one of the wecapi getters, which fill in an EC_VARIANT,
+// growing the buffer as needed.
+func getVariant(get func(size uint32, buf *byte, used *uint32) error) (variant, error) {
+	buf := make([]byte, 256)
+	var used uint32
+	err := get(uint32(len(buf)), &buf[0], &used)
+	if err == windows.ERROR_INSUFFICIENT_BUFFER {
+		buf = make([]byte, used)
+		err = get(uint32(len(buf)), &buf[0], &used)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return variant(buf[:used]), nil
+}
+
+func subscriptionProperty(subscription syscall.Handle, id uint32) (variant, error) {
+	return getVariant(func(size uint32, buf *byte, used *uint32) error {
+		_, err := call(ecGetSubscriptionProperty, uintptr(subscription), uintptr(id), 0, uintptr(size), uintptr(unsafe.Pointer(buf)), uintptr(unsafe.Pointer(used)))
+		return err
+	})
+}
+
+func arrayProperty(array syscall.Handle, id, index uint32) (variant, error) {
+	return getVariant(func(size uint32, buf *byte, used *uint32) error {
+		_, err := call(ecGetObjectArrayProperty, uintptr(array), uintptr(id), uintptr(index), 0, uintptr(size), uintptr(unsafe.Pointer(buf)), uintptr(unsafe.Pointer(used)))
+		return err
+	})
+}
+
+func runtimeStatus(name, source *uint16, id uint32) (variant, error) {
+	return getVariant(func(size uint32, buf *byte, used *uint32) error {
+		_, err := call(ecGetSubscriptionRunTimeStatus, uintptr(unsafe.Pointer(name)), uintptr(id), uintptr(unsafe.Pointer(source)), 0, uintptr(size), uintptr(unsafe.Pointer(buf)), uintptr(unsafe.Pointer(used)))
+		return err
+	})
+}
+
+// Values to set with EcSetSubscriptionProperty and EcSetObjectArrayProperty
+
+func boolVariant(value bool) ecVariant {
+	v := ecVariant{Type: ecVarTypeBoolean}
+	if value {
+		v.Data = 1
+	}
+	return v
+}
+
+func uint32Variant(value uint32) ecVariant {
+	return ecVariant{Data: uint64(value), Type: ecVarTypeUInt32}
+}
+
+// stringVariant points to `wide`, which must be kept alive until the
+// variant is used.
+func stringVariant(value string) (ecVariant, []uint16, error) {
+	wide, err := syscall.UTF16FromString(value)
+	if err != nil {
+		return ecVariant{}, nil, err
+	}
+	return ecVariant{Data: uint64(uintptr(unsafe.Pointer(&wide[0]))), Type: ecVarTypeString}, wide, nil
+}
@@ -4,7 +4,6 @@
 package winlog
 
 import (
-	"fmt"
 	"syscall"
 	"unsafe"
 
@@ -13,130 +12,62 @@ import (
 
 /* Interop code for wevtapi.dll */
 
+// The procs are found when first called, so a missing wevtapi.dll or
+// function doesn't panic when the package is loaded. loadWevtapi finds all
+// of them, for CheckHealth to report what's missing.
 var (
-	evtCreateBookmark        *windows.LazyProc
-	evtUpdateBookmark        *windows.LazyProc
-	evtRender                *windows.LazyProc
-	evtClose                 *windows.LazyProc
-	evtCancel                *windows.LazyProc
-	evtFormatMessage         *windows.LazyProc
-	evtCreateRenderContext   *windows.LazyProc
-	evtSubscribe             *windows.LazyProc
-	evtQuery                 *windows.LazyProc
-	evtOpenPublisherMetadata *windows.LazyProc
-	evtNext                  *windows.LazyProc
+	wevtapi = windows.NewLazySystemDLL("wevtapi.dll")
+	// Every proc of wevtapi, for loadWevtapi
+	wevtapiProcs []lazyProc
 )
 
-func mustFindProc(mod *windows.LazyDLL, functionName string) *windows.LazyProc {
-	if mod.Load() != nil {
-		panic(fmt.Sprintf("error loading %v", mod.Name))
-	}
-	proc := mod.NewProc(functionName)
-	if proc == nil || proc.Find() != nil {
-		panic(fmt.Sprintf("missing %v from %v", functionName, mod.Name))
-	}
+func wevtapiProc(name string) *windows.LazyProc {
+	proc := wevtapi.NewProc(name)
+	wevtapiProcs = append(wevtapiProcs, proc)
 	return proc
 }
 
-func init() {
-	winevtDll := windows.NewLazySystemDLL("wevtapi.dll")
-	evtCreateBookmark = mustFindProc(winevtDll, "EvtCreateBookmark")
-	evtUpdateBookmark = mustFindProc(winevtDll, "EvtUpdateBookmark")
-	evtRender = mustFindProc(winevtDll, "EvtRender")
-	evtClose = mustFindProc(winevtDll, "EvtClose")
-	evtCancel = mustFindProc(winevtDll, "EvtCancel")
-	evtFormatMessage = mustFindProc(winevtDll, "EvtFormatMessage")
-	evtCreateRenderContext = mustFindProc(winevtDll, "EvtCreateRenderContext")
-	evtSubscribe = mustFindProc(winevtDll, "EvtSubscribe")
-	evtQuery = mustFindProc(winevtDll, "EvtQuery")
-	evtOpenPublisherMetadata = mustFindProc(winevtDll, "EvtOpenPublisherMetadata")
-	evtNext = mustFindProc(winevtDll, "EvtNext")
-}
-
-type EVT_SUBSCRIBE_FLAGS int
-
-const (
-	_ = iota
-	EvtSubscribeToFutureEvents
-	EvtSubscribeStartAtOldestRecord
-	EvtSubscribeStartAfterBookmark
-)
-
-type EVT_SUBSCRIBE_NOTIFY_ACTION int
-
-const (
-	EvtSubscribeActionError = iota
-	EvtSubscribeActionDeliver
-)
-
-/* Fields that can be rendered with GetRendered*Value */
-type EVT_SYSTEM_PROPERTY_ID int
-
-const (
-	EvtSystemProviderName = iota
-	EvtSystemProviderGuid
-	EvtSystemEventID
-	EvtSystemQualifiers
-	EvtSystemLevel
-	EvtSystemTask
-	EvtSystemOpcode
-	EvtSystemKeywords
-	EvtSystemTimeCreated
-	EvtSystemEventRecordId
-	EvtSystemActivityID
-	EvtSystemRelatedActivityID
-	EvtSystemProcessID
-	EvtSystemThreadID
-	EvtSystemChannel
-	EvtSystemComputer
-	EvtSystemUserID
-	EvtSystemVersion
-)
-
-/* Formatting modes for GetFormattedMessage */
-type EVT_FORMAT_MESSAGE_FLAGS int
-
-const (
-	_ = iota
-	EvtFormatMessageEvent
-	EvtFormatMessageLevel
-	EvtFormatMessageTask
-	EvtFormatMessageOpcode
-	EvtFormatMessageKeyword
-	EvtFormatMessageChannel
-	EvtFormatMessageProvider
-	EvtFormatMessageId
-	EvtFormatMessageXml
-)
-
-type EVT_RENDER_FLAGS uint32
-
-const (
-	EvtRenderEventValues = iota
-	EvtRenderEventXml
-	EvtRenderBookmark
-)
-
-type EVT_RENDER_CONTEXT_FLAGS uint32
-
-const (
-	EvtRenderContextValues = iota
-	EvtRenderContextSystem
-	EvtRenderContextUser
-)
-
-type EVT_QUERY_FLAGS uint32
-
-const (
-	EvtQueryChannelPath         = 0x1
-	EvtQueryFilePath            = 0x2
-	EvtQueryForwardDirection    = 0x100
-	EvtQueryReverseDirection    = 0x200
-	EvtQueryTolerateQueryErrors = 0x1000
+var (
+	evtCreateBookmark        = wevtapiProc("EvtCreateBookmark")
+	evtUpdateBookmark        = wevtapiProc("EvtUpdateBookmark")
+	evtRender                = wevtapiProc("EvtRender")
+	evtClose                 = wevtapiProc("EvtClose")
+	evtCancel                = wevtapiProc("EvtCancel")
+	evtFormatMessage         = wevtapiProc("EvtFormatMessage")
+	evtCreateRenderContext   = wevtapiProc("EvtCreateRenderContext")
+	evtSubscribe             = wevtapiProc("EvtSubscribe")
+	evtQuery                 = wevtapiProc("EvtQuery")
+	evtOpenPublisherMetadata = wevtapiProc("EvtOpenPublisherMetadata")
+	evtNext                  = wevtapiProc("EvtNext")
+	evtOpenLog               = wevtapiProc("EvtOpenLog")
+	evtGetLogInfo            = wevtapiProc("EvtGetLogInfo")
+	evtOpenChannelEnum       = wevtapiProc("EvtOpenChannelEnum")
+	evtNextChannelPath       = wevtapiProc("EvtNextChannelPath")
+	evtOpenPublisherEnum     = wevtapiProc("EvtOpenPublisherEnum")
+	evtNextPublisherId       = wevtapiProc("EvtNextPublisherId")
+	evtExportLog             = wevtapiProc("EvtExportLog")
+	evtOpenSession           = wevtapiProc("EvtOpenSession")
+
+	evtOpenEventMetadataEnum    = wevtapiProc("EvtOpenEventMetadataEnum")
+	evtNextEventMetadata        = wevtapiProc("EvtNextEventMetadata")
+	evtGetEventMetadataProperty = wevtapiProc("EvtGetEventMetadataProperty")
+
+	evtGetPublisherMetadataProperty = wevtapiProc("EvtGetPublisherMetadataProperty")
+	evtGetObjectArraySize           = wevtapiProc("EvtGetObjectArraySize")
+	evtGetObjectArrayProperty       = wevtapiProc("EvtGetObjectArrayProperty")
+
+	evtOpenChannelConfig        = wevtapiProc("EvtOpenChannelConfig")
+	evtGetChannelConfigProperty = wevtapiProc("EvtGetChannelConfigProperty")
+	evtSetChannelConfigProperty = wevtapiProc("EvtSetChannelConfigProperty")
+	evtSaveChannelConfig        = wevtapiProc("EvtSaveChannelConfig")
 )
 
 func EvtCreateBookmark(BookmarkXml *uint16) (syscall.Handle, error) {
+	start := traceStart()
 	r1, _, err := evtCreateBookmark.Call(uintptr(unsafe.Pointer(BookmarkXml)))
+	if !start.IsZero() {
+		traceCall(evtCreateBookmark, start, r1, err, "fromXml", BookmarkXml != nil)
+	}
 	if r1 == 0 {
 		return 0, err
 	}
@@ -144,7 +75,11 @@ func EvtCreateBookmark(BookmarkXml *uint16) (syscall.Handle, error) {
 }
 
 func EvtUpdateBookmark(Bookmark, Event syscall.Handle) error {
+	start := traceStart()
 	r1, _, err := evtUpdateBookmark.Call(uintptr(Bookmark), uintptr(Event))
+	if !start.IsZero() {
+		traceCall(evtUpdateBookmark, start, r1, err, "bookmark", Bookmark, "event", Event)
+	}
 	if r1 == 0 {
 		return err
 	}
@@ -152,7 +87,11 @@ func EvtUpdateBookmark(Bookmark, Event syscall.Handle) error {
 }
 
 func EvtRender(Context, Fragment syscall.Handle, Flags, BufferSize uint32, Buffer *uint16, BufferUsed, PropertyCount *uint32) error {
+	start := traceStart()
 	r1, _, err := evtRender.Call(uintptr(Context), uintptr(Fragment), uintptr(Flags), uintptr(BufferSize), uintptr(unsafe.Pointer(Buffer)), uintptr(unsafe.Pointer(BufferUsed)), uintptr(unsafe.Pointer(PropertyCount)))
+	if !start.IsZero() {
+		traceCall(evtRender, start, r1, err, "context", Context, "fragment", Fragment, "flags", Flags, "bufferSize", BufferSize, "bufferUsed", derefUint32(BufferUsed))
+	}
 	if r1 == 0 {
 		return err
 	}
@@ -160,7 +99,11 @@ func EvtRender(Context, Fragment syscall.Handle, Flags, BufferSize uint32, Buffe
 }
 
 func EvtClose(Object syscall.Handle) error {
+	start := traceStart()
 	r1, _, err := evtClose.Call(uintptr(Object))
+	if !start.IsZero() {
+		traceCall(evtClose, start, r1, err, "object", Object)
+	}
 	if r1 == 0 {
 		return err
 	}
@@ -168,7 +111,11 @@ func EvtClose(Object syscall.Handle) error {
 }
 
 func EvtFormatMessage(PublisherMetadata, Event syscall.Handle, MessageId, ValueCount uint32, Values *byte, Flags, BufferSize uint32, Buffer *uint16, BufferUsed *uint32) error {
+	start := traceStart()
 	r1, _, err := evtFormatMessage.Call(uintptr(PublisherMetadata), uintptr(Event), uintptr(MessageId), uintptr(ValueCount), uintptr(unsafe.Pointer(Values)), uintptr(Flags), uintptr(BufferSize), uintptr(unsafe.Pointer(Buffer)), uintptr(unsafe.Pointer(BufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtFormatMessage, start, r1, err, "publisher", PublisherMetadata, "event", Event, "messageId", MessageId, "flags", Flags, "bufferSize", BufferSize, "bufferUsed", derefUint32(BufferUsed))
+	}
 	if r1 == 0 {
 		return err
 	}
@@ -176,7 +123,11 @@ func EvtFormatMessage(PublisherMetadata, Event syscall.Handle, MessageId, ValueC
 }
 
 func EvtCreateRenderContext(ValuePathsCount uint32, ValuePaths uintptr, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
 	r1, _, err := evtCreateRenderContext.Call(uintptr(ValuePathsCount), ValuePaths, uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtCreateRenderContext, start, r1, err, "valuePathsCount", ValuePathsCount, "flags", Flags)
+	}
 	if r1 == 0 {
 		return 0, err
 	}
@@ -184,7 +135,11 @@ func EvtCreateRenderContext(ValuePathsCount uint32, ValuePaths uintptr, Flags ui
 }
 
 func EvtSubscribe(Session, SignalEvent syscall.Handle, ChannelPath, Query *uint16, Bookmark syscall.Handle, context uintptr, Callback uintptr, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
 	r1, _, err := evtSubscribe.Call(uintptr(Session), uintptr(SignalEvent), uintptr(unsafe.Pointer(ChannelPath)), uintptr(unsafe.Pointer(Query)), uintptr(Bookmark), context, Callback, uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtSubscribe, start, r1, err, "session", Session, "bookmark", Bookmark, "flags", Flags)
+	}
 	if r1 == 0 {
 		return 0, err
 	}
@@ -192,7 +147,11 @@ func EvtSubscribe(Session, SignalEvent syscall.Handle, ChannelPath, Query *uint1
 }
 
 func EvtQuery(Session syscall.Handle, Path, Query *uint16, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
 	r1, _, err := evtQuery.Call(uintptr(Session), uintptr(unsafe.Pointer(Path)), uintptr(unsafe.Pointer(Query)), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtQuery, start, r1, err, "session", Session, "flags", Flags)
+	}
 	if r1 == 0 {
 		return 0, err
 	}
@@ -200,7 +159,11 @@ func EvtQuery(Session syscall.Handle, Path, Query *uint16, Flags uint32) (syscal
 }
 
 func EvtOpenPublisherMetadata(Session syscall.Handle, PublisherIdentity, LogFilePath *uint16, Locale, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
 	r1, _, err := evtOpenPublisherMetadata.Call(uintptr(Session), uintptr(unsafe.Pointer(PublisherIdentity)), uintptr(unsafe.Pointer(LogFilePath)), uintptr(Locale), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenPublisherMetadata, start, r1, err, "session", Session, "fromLogFile", LogFilePath != nil, "locale", Locale)
+	}
 	if r1 == 0 {
 		return 0, err
 	}
@@ -208,7 +171,11 @@ func EvtOpenPublisherMetadata(Session syscall.Handle, PublisherIdentity, LogFile
 }
 
 func EvtCancel(handle syscall.Handle) error {
+	start := traceStart()
 	r1, _, err := evtCancel.Call(uintptr(handle))
+	if !start.IsZero() {
+		traceCall(evtCancel, start, r1, err, "handle", handle)
+	}
 	if r1 == 0 {
 		return err
 	}
@@ -216,9 +183,229 @@ func EvtCancel(handle syscall.Handle) error {
 }
 
 func EvtNext(ResultSet syscall.Handle, EventArraySize uint32, EventArray *syscall.Handle, Timeout, Flags uint32, Returned *uint32) error {
+	start := traceStart()
 	r1, _, err := evtNext.Call(uintptr(ResultSet), uintptr(EventArraySize), uintptr(unsafe.Pointer(EventArray)), uintptr(Timeout), uintptr(Flags), uintptr(unsafe.Pointer(Returned)))
+	if !start.IsZero() {
+		traceCall(evtNext, start, r1, err, "resultSet", ResultSet, "arraySize", EventArraySize, "timeout", Timeout, "returned", derefUint32(Returned))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtOpenLog(Session syscall.Handle, Path *uint16, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtOpenLog.Call(uintptr(Session), uintptr(unsafe.Pointer(Path)), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenLog, start, r1, err, "session", Session, "flags", Flags)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
+
+func EvtGetLogInfo(Log syscall.Handle, PropertyId, PropertyValueBufferSize uint32, PropertyValueBuffer *byte, PropertyValueBufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtGetLogInfo.Call(uintptr(Log), uintptr(PropertyId), uintptr(PropertyValueBufferSize), uintptr(unsafe.Pointer(PropertyValueBuffer)), uintptr(unsafe.Pointer(PropertyValueBufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtGetLogInfo, start, r1, err, "log", Log, "propertyId", PropertyId, "bufferSize", PropertyValueBufferSize)
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtOpenChannelEnum(Session syscall.Handle, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtOpenChannelEnum.Call(uintptr(Session), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenChannelEnum, start, r1, err, "session", Session)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
+
+func EvtNextChannelPath(ChannelEnum syscall.Handle, ChannelPathBufferSize uint32, ChannelPathBuffer *uint16, ChannelPathBufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtNextChannelPath.Call(uintptr(ChannelEnum), uintptr(ChannelPathBufferSize), uintptr(unsafe.Pointer(ChannelPathBuffer)), uintptr(unsafe.Pointer(ChannelPathBufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtNextChannelPath, start, r1, err, "enum", ChannelEnum, "bufferSize", ChannelPathBufferSize, "bufferUsed", derefUint32(ChannelPathBufferUsed))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtOpenPublisherEnum(Session syscall.Handle, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtOpenPublisherEnum.Call(uintptr(Session), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenPublisherEnum, start, r1, err, "session", Session)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
+
+func EvtNextPublisherId(PublisherEnum syscall.Handle, PublisherIdBufferSize uint32, PublisherIdBuffer *uint16, PublisherIdBufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtNextPublisherId.Call(uintptr(PublisherEnum), uintptr(PublisherIdBufferSize), uintptr(unsafe.Pointer(PublisherIdBuffer)), uintptr(unsafe.Pointer(PublisherIdBufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtNextPublisherId, start, r1, err, "enum", PublisherEnum, "bufferSize", PublisherIdBufferSize, "bufferUsed", derefUint32(PublisherIdBufferUsed))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtExportLog(Session syscall.Handle, Path, Query, TargetFilePath *uint16, Flags uint32) error {
+	start := traceStart()
+	r1, _, err := evtExportLog.Call(uintptr(Session), uintptr(unsafe.Pointer(Path)), uintptr(unsafe.Pointer(Query)), uintptr(unsafe.Pointer(TargetFilePath)), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtExportLog, start, r1, err, "session", Session, "flags", Flags)
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtOpenEventMetadataEnum(PublisherMetadata syscall.Handle, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtOpenEventMetadataEnum.Call(uintptr(PublisherMetadata), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenEventMetadataEnum, start, r1, err, "publisher", PublisherMetadata)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
+
+func EvtNextEventMetadata(EventMetadataEnum syscall.Handle, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtNextEventMetadata.Call(uintptr(EventMetadataEnum), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtNextEventMetadata, start, r1, err, "enum", EventMetadataEnum)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
+
+func EvtGetEventMetadataProperty(EventMetadata syscall.Handle, PropertyId, Flags, EventMetadataPropertyBufferSize uint32, EventMetadataPropertyBuffer *byte, BufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtGetEventMetadataProperty.Call(uintptr(EventMetadata), uintptr(PropertyId), uintptr(Flags), uintptr(EventMetadataPropertyBufferSize), uintptr(unsafe.Pointer(EventMetadataPropertyBuffer)), uintptr(unsafe.Pointer(BufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtGetEventMetadataProperty, start, r1, err, "metadata", EventMetadata, "propertyId", PropertyId, "bufferSize", EventMetadataPropertyBufferSize, "bufferUsed", derefUint32(BufferUsed))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtGetPublisherMetadataProperty(PublisherMetadata syscall.Handle, PropertyId, Flags, PublisherMetadataPropertyBufferSize uint32, PublisherMetadataPropertyBuffer *byte, PublisherMetadataPropertyBufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtGetPublisherMetadataProperty.Call(uintptr(PublisherMetadata), uintptr(PropertyId), uintptr(Flags), uintptr(PublisherMetadataPropertyBufferSize), uintptr(unsafe.Pointer(PublisherMetadataPropertyBuffer)), uintptr(unsafe.Pointer(PublisherMetadataPropertyBufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtGetPublisherMetadataProperty, start, r1, err, "publisher", PublisherMetadata, "propertyId", PropertyId, "bufferSize", PublisherMetadataPropertyBufferSize, "bufferUsed", derefUint32(PublisherMetadataPropertyBufferUsed))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtGetObjectArraySize(ObjectArray syscall.Handle, ObjectArraySize *uint32) error {
+	start := traceStart()
+	r1, _, err := evtGetObjectArraySize.Call(uintptr(ObjectArray), uintptr(unsafe.Pointer(ObjectArraySize)))
+	if !start.IsZero() {
+		traceCall(evtGetObjectArraySize, start, r1, err, "array", ObjectArray, "size", derefUint32(ObjectArraySize))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtGetObjectArrayProperty(ObjectArray syscall.Handle, PropertyId, ArrayIndex, Flags, PropertyValueBufferSize uint32, PropertyValueBuffer *byte, PropertyValueBufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtGetObjectArrayProperty.Call(uintptr(ObjectArray), uintptr(PropertyId), uintptr(ArrayIndex), uintptr(Flags), uintptr(PropertyValueBufferSize), uintptr(unsafe.Pointer(PropertyValueBuffer)), uintptr(unsafe.Pointer(PropertyValueBufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtGetObjectArrayProperty, start, r1, err, "array", ObjectArray, "propertyId", PropertyId, "index", ArrayIndex, "bufferSize", PropertyValueBufferSize, "bufferUsed", derefUint32(PropertyValueBufferUsed))
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtOpenChannelConfig(Session syscall.Handle, ChannelPath *uint16, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtOpenChannelConfig.Call(uintptr(Session), uintptr(unsafe.Pointer(ChannelPath)), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenChannelConfig, start, r1, err, "session", Session, "flags", Flags)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
+
+func EvtGetChannelConfigProperty(ChannelConfig syscall.Handle, PropertyId, Flags, PropertyValueBufferSize uint32, PropertyValueBuffer *byte, PropertyValueBufferUsed *uint32) error {
+	start := traceStart()
+	r1, _, err := evtGetChannelConfigProperty.Call(uintptr(ChannelConfig), uintptr(PropertyId), uintptr(Flags), uintptr(PropertyValueBufferSize), uintptr(unsafe.Pointer(PropertyValueBuffer)), uintptr(unsafe.Pointer(PropertyValueBufferUsed)))
+	if !start.IsZero() {
+		traceCall(evtGetChannelConfigProperty, start, r1, err, "config", ChannelConfig, "propertyId", PropertyId, "bufferSize", PropertyValueBufferSize, "bufferUsed", derefUint32(PropertyValueBufferUsed))
+	}
 	if r1 == 0 {
 		return err
 	}
 	return nil
 }
+
+func EvtSetChannelConfigProperty(ChannelConfig syscall.Handle, PropertyId, Flags uint32, PropertyValue *byte) error {
+	start := traceStart()
+	r1, _, err := evtSetChannelConfigProperty.Call(uintptr(ChannelConfig), uintptr(PropertyId), uintptr(Flags), uintptr(unsafe.Pointer(PropertyValue)))
+	if !start.IsZero() {
+		traceCall(evtSetChannelConfigProperty, start, r1, err, "config", ChannelConfig, "propertyId", PropertyId)
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtSaveChannelConfig(ChannelConfig syscall.Handle, Flags uint32) error {
+	start := traceStart()
+	r1, _, err := evtSaveChannelConfig.Call(uintptr(ChannelConfig), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtSaveChannelConfig, start, r1, err, "config", ChannelConfig, "flags", Flags)
+	}
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+func EvtOpenSession(LoginClass uint32, Login *byte, Timeout, Flags uint32) (syscall.Handle, error) {
+	start := traceStart()
+	r1, _, err := evtOpenSession.Call(uintptr(LoginClass), uintptr(unsafe.Pointer(Login)), uintptr(Timeout), uintptr(Flags))
+	if !start.IsZero() {
+		traceCall(evtOpenSession, start, r1, err, "loginClass", LoginClass)
+	}
+	if r1 == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r1), nil
+}
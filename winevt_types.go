@@ -0,0 +1,200 @@
+package winlog
+
+/* Types and constants from winevt.h, shared by all platforms */
+
+type EVT_SUBSCRIBE_FLAGS int
+
+const (
+	_ = iota
+	EvtSubscribeToFutureEvents
+	EvtSubscribeStartAtOldestRecord
+	EvtSubscribeStartAfterBookmark
+)
+
+type EVT_SUBSCRIBE_NOTIFY_ACTION int
+
+const (
+	EvtSubscribeActionError = iota
+	EvtSubscribeActionDeliver
+)
+
+/* Fields that can be rendered with GetRendered*Value */
+type EVT_SYSTEM_PROPERTY_ID int
+
+const (
+	EvtSystemProviderName = iota
+	EvtSystemProviderGuid
+	EvtSystemEventID
+	EvtSystemQualifiers
+	EvtSystemLevel
+	EvtSystemTask
+	EvtSystemOpcode
+	EvtSystemKeywords
+	EvtSystemTimeCreated
+	EvtSystemEventRecordId
+	EvtSystemActivityID
+	EvtSystemRelatedActivityID
+	EvtSystemProcessID
+	EvtSystemThreadID
+	EvtSystemChannel
+	EvtSystemComputer
+	EvtSystemUserID
+	EvtSystemVersion
+)
+
+/* Formatting modes for GetFormattedMessage */
+type EVT_FORMAT_MESSAGE_FLAGS int
+
+const (
+	_ = iota
+	EvtFormatMessageEvent
+	EvtFormatMessageLevel
+	EvtFormatMessageTask
+	EvtFormatMessageOpcode
+	EvtFormatMessageKeyword
+	EvtFormatMessageChannel
+	EvtFormatMessageProvider
+	EvtFormatMessageId
+	EvtFormatMessageXml
+)
+
+type EVT_RENDER_FLAGS uint32
+
+const (
+	EvtRenderEventValues = iota
+	EvtRenderEventXml
+	EvtRenderBookmark
+)
+
+type EVT_RENDER_CONTEXT_FLAGS uint32
+
+const (
+	EvtRenderContextValues = iota
+	EvtRenderContextSystem
+	EvtRenderContextUser
+)
+
+type EVT_QUERY_FLAGS uint32
+
+const (
+	EvtQueryChannelPath         = 0x1
+	EvtQueryFilePath            = 0x2
+	EvtQueryForwardDirection    = 0x100
+	EvtQueryReverseDirection    = 0x200
+	EvtQueryTolerateQueryErrors = 0x1000
+)
+
+type EVT_OPEN_LOG_FLAGS uint32
+
+const (
+	EvtOpenChannelPath = 0x1
+	EvtOpenFilePath    = 0x2
+)
+
+/* Login classes for EvtOpenSession */
+type EVT_LOGIN_CLASS uint32
+
+const (
+	EvtRpcLogin = 1
+)
+
+/* Authentication methods for EvtRpcLogin sessions */
+type EVT_RPC_LOGIN_FLAGS uint32
+
+const (
+	EvtRpcLoginAuthDefault = iota
+	EvtRpcLoginAuthNegotiate
+	EvtRpcLoginAuthKerberos
+	EvtRpcLoginAuthNTLM
+)
+
+/* Properties that can be retrieved with EvtGetLogInfo */
+type EVT_LOG_PROPERTY_ID uint32
+
+const (
+	EvtLogCreationTime = iota
+	EvtLogLastAccessTime
+	EvtLogLastWriteTime
+	EvtLogFileSize
+	EvtLogAttributes
+	EvtLogNumberOfLogRecords
+	EvtLogOldestRecordNumber
+	EvtLogFull
+)
+
+/* Properties that can be retrieved with EvtGetEventMetadataProperty */
+type EVT_EVENT_METADATA_PROPERTY_ID uint32
+
+const (
+	EventMetadataEventID = iota
+	EventMetadataEventVersion
+	EventMetadataEventChannel
+	EventMetadataEventLevel
+	EventMetadataEventOpcode
+	EventMetadataEventTask
+	EventMetadataEventKeyword
+	EventMetadataEventMessageID
+	EventMetadataEventTemplate
+)
+
+/* Properties that can be retrieved with EvtGetPublisherMetadataProperty */
+type EVT_PUBLISHER_METADATA_PROPERTY_ID uint32
+
+const (
+	EvtPublisherMetadataPublisherGuid = iota
+	EvtPublisherMetadataResourceFilePath
+	EvtPublisherMetadataParameterFilePath
+	EvtPublisherMetadataMessageFilePath
+	EvtPublisherMetadataHelpLink
+	EvtPublisherMetadataPublisherMessageID
+	EvtPublisherMetadataChannelReferences
+	EvtPublisherMetadataChannelReferencePath
+	EvtPublisherMetadataChannelReferenceIndex
+	EvtPublisherMetadataChannelReferenceID
+	EvtPublisherMetadataChannelReferenceFlags
+	EvtPublisherMetadataChannelReferenceMessageID
+	EvtPublisherMetadataLevels
+	EvtPublisherMetadataLevelName
+	EvtPublisherMetadataLevelValue
+	EvtPublisherMetadataLevelMessageID
+	EvtPublisherMetadataTasks
+	EvtPublisherMetadataTaskName
+	EvtPublisherMetadataTaskEventGuid
+	EvtPublisherMetadataTaskValue
+	EvtPublisherMetadataTaskMessageID
+	EvtPublisherMetadataOpcodes
+	EvtPublisherMetadataOpcodeName
+	EvtPublisherMetadataOpcodeValue
+	EvtPublisherMetadataOpcodeMessageID
+	EvtPublisherMetadataKeywords
+	EvtPublisherMetadataKeywordName
+	EvtPublisherMetadataKeywordValue
+	EvtPublisherMetadataKeywordMessageID
+)
+
+/* Properties that can be retrieved with EvtGetChannelConfigProperty */
+type EVT_CHANNEL_CONFIG_PROPERTY_ID uint32
+
+const (
+	EvtChannelConfigEnabled = iota
+	EvtChannelConfigIsolation
+	EvtChannelConfigType
+	EvtChannelConfigOwningPublisher
+	EvtChannelConfigClassicEventlog
+	EvtChannelConfigAccess
+	EvtChannelLoggingConfigRetention
+	EvtChannelLoggingConfigAutoBackup
+	EvtChannelLoggingConfigMaxSize
+	EvtChannelLoggingConfigLogFilePath
+	EvtChannelPublishingConfigLevel
+	EvtChannelPublishingConfigKeywords
+	EvtChannelPublishingConfigControlGuid
+	EvtChannelPublishingConfigBufferSize
+	EvtChannelPublishingConfigMinBuffers
+	EvtChannelPublishingConfigMaxBuffers
+	EvtChannelPublishingConfigLatency
+	EvtChannelPublishingConfigClockType
+	EvtChannelPublishingConfigSidType
+	EvtChannelPublisherList
+	EvtChannelPublishingConfigFileMax
+)
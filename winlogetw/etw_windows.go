@@ -0,0 +1,348 @@
+//go:build windows && (amd64 || arm64)
+// +build windows
+// +build amd64 arm64
+
+package winlogetw
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+/* Interop code for the ETW controller and consumer functions of advapi32.dll
+   and the TDH decoding functions of tdh.dll. The structures have their
+   64-bit layouts. */
+
+var (
+	advapi32Dll = windows.NewLazySystemDLL("advapi32.dll")
+	tdhDll      = windows.NewLazySystemDLL("tdh.dll")
+
+	procStartTraceW    = advapi32Dll.NewProc("StartTraceW")
+	procControlTraceW  = advapi32Dll.NewProc("ControlTraceW")
+	procEnableTraceEx2 = advapi32Dll.NewProc("EnableTraceEx2")
+	procOpenTraceW     = advapi32Dll.NewProc("OpenTraceW")
+	procProcessTrace   = advapi32Dll.NewProc("ProcessTrace")
+	procCloseTrace     = advapi32Dll.NewProc("CloseTrace")
+
+	procTdhGetEventInformation    = tdhDll.NewProc("TdhGetEventInformation")
+	procTdhGetEventMapInformation = tdhDll.NewProc("TdhGetEventMapInformation")
+	procTdhFormatProperty         = tdhDll.NewProc("TdhFormatProperty")
+)
+
+const (
+	wnodeFlagTracedGuid     = 0x00020000
+	eventTraceRealTimeMode  = 0x00000100
+	eventTraceControlStop   = 1
+	eventControlCodeEnable  = 1
+	processTraceRealTime    = 0x00000100
+	processTraceEventRecord = 0x10000000
+	// ClientContext selecting QueryPerformanceCounter timestamps, which
+	// the consumer converts to system time
+	clientContextQpc = 1
+	// OpenTraceW's INVALID_PROCESSTRACE_HANDLE
+	invalidProcessTraceHandle = 0xFFFFFFFFFFFFFFFF
+	// Returned by CloseTrace when events are still being delivered
+	errorCtxClosePending = 7007
+	// Returned by TdhFormatProperty when a value isn't in its map
+	errorEvtInvalidEventData = 15005
+
+	eventHeaderFlagStringOnly       = 0x0004
+	eventHeaderFlag32BitHeader      = 0x0020
+	eventHeaderExtRelatedActivityId = 1
+)
+
+// Maximum length of session names, in characters
+const maxSessionNameLength = 1024
+
+// wnodeHeader is a WNODE_HEADER structure.
+type wnodeHeader struct {
+	BufferSize        uint32
+	ProviderId        uint32
+	HistoricalContext uint64
+	TimeStamp         int64
+	Guid              windows.GUID
+	ClientContext     uint32
+	Flags             uint32
+}
+
+// eventTraceProperties is an EVENT_TRACE_PROPERTIES structure. The session
+// and log file names follow it in the same buffer.
+type eventTraceProperties struct {
+	Wnode               wnodeHeader
+	BufferSize          uint32
+	MinimumBuffers      uint32
+	MaximumBuffers      uint32
+	MaximumFileSize     uint32
+	LogFileMode         uint32
+	FlushTimer          uint32
+	EnableFlags         uint32
+	AgeLimit            int32
+	NumberOfBuffers     uint32
+	FreeBuffers         uint32
+	EventsLost          uint32
+	BuffersWritten      uint32
+	LogBuffersLost      uint32
+	RealTimeBuffersLost uint32
+	LoggerThreadId      windows.Handle
+	LogFileNameOffset   uint32
+	LoggerNameOffset    uint32
+}
+
+// newTraceProperties allocates an EVENT_TRACE_PROPERTIES with room for a
+// session name, for a real-time session.
+func newTraceProperties() *eventTraceProperties {
+	size := unsafe.Sizeof(eventTraceProperties{}) + 2*maxSessionNameLength*2
+	buf := make([]byte, size)
+	props := (*eventTraceProperties)(unsafe.Pointer(&buf[0]))
+	props.Wnode.BufferSize = uint32(size)
+	props.Wnode.ClientContext = clientContextQpc
+	props.Wnode.Flags = wnodeFlagTracedGuid
+	props.LogFileMode = eventTraceRealTimeMode
+	props.LoggerNameOffset = uint32(unsafe.Sizeof(eventTraceProperties{}))
+	props.LogFileNameOffset = props.LoggerNameOffset + maxSessionNameLength*2
+	return props
+}
+
+// eventTraceHeader is an EVENT_TRACE_HEADER structure.
+type eventTraceHeader struct {
+	Size           uint16
+	FieldTypeFlags uint16
+	Version        uint32
+	ThreadId       uint32
+	ProcessId      uint32
+	TimeStamp      int64
+	Guid           windows.GUID
+	ProcessorTime  uint64
+}
+
+// eventTrace is an EVENT_TRACE structure.
+type eventTrace struct {
+	Header           eventTraceHeader
+	InstanceId       uint32
+	ParentInstanceId uint32
+	ParentGuid       windows.GUID
+	MofData          uintptr
+	MofLength        uint32
+	ClientContext    uint32
+}
+
+// systemTime is a SYSTEMTIME structure.
+type systemTime struct {
+	Year, Month, DayOfWeek, Day, Hour, Minute, Second, Milliseconds uint16
+}
+
+// timeZoneInformation is a TIME_ZONE_INFORMATION structure.
+type timeZoneInformation struct {
+	Bias         int32
+	StandardName [32]uint16
+	StandardDate systemTime
+	StandardBias int32
+	DaylightName [32]uint16
+	DaylightDate systemTime
+	DaylightBias int32
+}
+
+// traceLogfileHeader is a TRACE_LOGFILE_HEADER structure.
+type traceLogfileHeader struct {
+	BufferSize         uint32
+	Version            uint32
+	ProviderVersion    uint32
+	NumberOfProcessors uint32
+	EndTime            int64
+	TimerResolution    uint32
+	MaximumFileSize    uint32
+	LogFileMode        uint32
+	BuffersWritten     uint32
+	LogInstanceGuid    windows.GUID
+	LoggerName         *uint16
+	LogFileName        *uint16
+	TimeZone           timeZoneInformation
+	BootTime           int64
+	PerfFreq           int64
+	StartTime          int64
+	ReservedFlags      uint32
+	BuffersLost        uint32
+}
+
+// eventTraceLogfile is an EVENT_TRACE_LOGFILEW structure.
+type eventTraceLogfile struct {
+	LogFileName         *uint16
+	LoggerName          *uint16
+	CurrentTime         int64
+	BuffersRead         uint32
+	ProcessTraceMode    uint32
+	CurrentEvent        eventTrace
+	LogfileHeader       traceLogfileHeader
+	BufferCallback      uintptr
+	BufferSize          uint32
+	Filled              uint32
+	EventsLost          uint32
+	EventRecordCallback uintptr
+	IsKernelTrace       uint32
+	Context             uintptr
+}
+
+// eventDescriptor is an EVENT_DESCRIPTOR structure.
+type eventDescriptor struct {
+	Id      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+// eventHeader is an EVENT_HEADER structure.
+type eventHeader struct {
+	Size            uint16
+	HeaderType      uint16
+	Flags           uint16
+	EventProperty   uint16
+	ThreadId        uint32
+	ProcessId       uint32
+	TimeStamp       int64
+	ProviderId      windows.GUID
+	EventDescriptor eventDescriptor
+	ProcessorTime   uint64
+	ActivityId      windows.GUID
+}
+
+// eventHeaderExtendedDataItem is an EVENT_HEADER_EXTENDED_DATA_ITEM
+// structure.
+type eventHeaderExtendedDataItem struct {
+	Reserved1 uint16
+	ExtType   uint16
+	Linkage   uint16
+	DataSize  uint16
+	DataPtr   unsafe.Pointer
+}
+
+// eventRecord is an EVENT_RECORD structure, which is only valid during the
+// callback it's passed to.
+type eventRecord struct {
+	EventHeader       eventHeader
+	ProcessorNumber   uint8
+	Alignment         uint8
+	LoggerId          uint16
+	ExtendedDataCount uint16
+	UserDataLength    uint16
+	ExtendedData      unsafe.Pointer
+	UserData          unsafe.Pointer
+	UserContext       uintptr
+}
+
+// Flags of an EVENT_PROPERTY_INFO
+const (
+	propertyStruct          = 0x01
+	propertyParamLength     = 0x02
+	propertyParamCount      = 0x04
+	propertyParamFixedCount = 0x20
+)
+
+// TDH_INTYPE and TDH_OUTTYPE values the decoder handles specially
+const (
+	tdhInTypeInt8     = 3
+	tdhInTypeUInt8    = 4
+	tdhInTypeInt16    = 5
+	tdhInTypeUInt16   = 6
+	tdhInTypeInt32    = 7
+	tdhInTypeUInt32   = 8
+	tdhInTypeInt64    = 9
+	tdhInTypeUInt64   = 10
+	tdhInTypeBinary   = 14
+	tdhInTypeHexInt32 = 20
+	tdhInTypeHexInt64 = 21
+	tdhOutTypeIPv6    = 24
+)
+
+// traceEventInfo is the fixed part of a TRACE_EVENT_INFO structure, which
+// is followed by its EVENT_PROPERTY_INFO array and the strings its offsets
+// refer to.
+type traceEventInfo struct {
+	ProviderGuid          windows.GUID
+	EventGuid             windows.GUID
+	EventDescriptor       eventDescriptor
+	DecodingSource        uint32
+	ProviderNameOffset    uint32
+	LevelNameOffset       uint32
+	ChannelNameOffset     uint32
+	KeywordsNameOffset    uint32
+	TaskNameOffset        uint32
+	OpcodeNameOffset      uint32
+	EventMessageOffset    uint32
+	ProviderMessageOffset uint32
+	BinaryXMLOffset       uint32
+	BinaryXMLSize         uint32
+	EventNameOffset       uint32
+	EventAttributesOffset uint32
+	PropertyCount         uint32
+	TopLevelPropertyCount uint32
+	Flags                 uint32
+}
+
+// eventPropertyInfo is an EVENT_PROPERTY_INFO structure. For struct
+// properties, InType and OutType are the index of the first member and the
+// number of members.
+type eventPropertyInfo struct {
+	Flags         uint32
+	NameOffset    uint32
+	InType        uint16
+	OutType       uint16
+	MapNameOffset uint32
+	Count         uint16
+	Length        uint16
+	Tags          uint32
+}
+
+// call calls a function which returns a Win32 error code. Returns an error
+// rather than panicking if the DLL or the function is missing.
+func call(proc *windows.LazyProc, args ...uintptr) error {
+	if err := proc.Find(); err != nil {
+		return err
+	}
+	r1, _, _ := proc.Call(args...)
+	if r1 != 0 {
+		return syscall.Errno(r1)
+	}
+	return nil
+}
+
+func startTrace(handle *uint64, name *uint16, props *eventTraceProperties) error {
+	return call(procStartTraceW, uintptr(unsafe.Pointer(handle)), uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(props)))
+}
+
+func controlTrace(handle uint64, name *uint16, props *eventTraceProperties, code uint32) error {
+	return call(procControlTraceW, uintptr(handle), uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(props)), uintptr(code))
+}
+
+func enableTrace(handle uint64, provider *windows.GUID, level uint8, matchAny, matchAll uint64) error {
+	return call(procEnableTraceEx2, uintptr(handle), uintptr(unsafe.Pointer(provider)), eventControlCodeEnable, uintptr(level), uintptr(matchAny), uintptr(matchAll), 0, 0)
+}
+
+func openTrace(logfile *eventTraceLogfile) (uint64, error) {
+	if err := procOpenTraceW.Find(); err != nil {
+		return 0, err
+	}
+	r1, _, err := procOpenTraceW.Call(uintptr(unsafe.Pointer(logfile)))
+	if uint64(r1) == invalidProcessTraceHandle {
+		return 0, err
+	}
+	return uint64(r1), nil
+}
+
+// processTrace delivers the session's events to its callback, blocking
+// until the session stops or is closed.
+func processTrace(handle *uint64) error {
+	return call(procProcessTrace, uintptr(unsafe.Pointer(handle)), 1, 0, 0)
+}
+
+func closeTrace(handle uint64) error {
+	err := call(procCloseTrace, uintptr(handle))
+	if err == syscall.Errno(errorCtxClosePending) {
+		return nil
+	}
+	return err
+}
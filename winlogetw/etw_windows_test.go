@@ -0,0 +1,27 @@
+//go:build windows && (amd64 || arm64)
+// +build windows
+// +build amd64 arm64
+
+package winlogetw
+
+import (
+	. "testing"
+	"unsafe"
+
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+func TestStructureSizes(t *T) {
+	// Sizes of the 64-bit structures, from the Windows SDK
+	testutil.AssertEqual(unsafe.Sizeof(eventTraceProperties{}), uintptr(120), t)
+	testutil.AssertEqual(unsafe.Sizeof(eventTraceLogfile{}), uintptr(448), t)
+	testutil.AssertEqual(unsafe.Sizeof(eventHeader{}), uintptr(80), t)
+	testutil.AssertEqual(unsafe.Sizeof(eventRecord{}), uintptr(112), t)
+	testutil.AssertEqual(unsafe.Sizeof(eventHeaderExtendedDataItem{}), uintptr(16), t)
+	testutil.AssertEqual(unsafe.Sizeof(traceEventInfo{}), uintptr(112), t)
+	testutil.AssertEqual(unsafe.Sizeof(eventPropertyInfo{}), uintptr(24), t)
+}
+
+func TestFileTimeToTime(t *T) {
+	testutil.AssertEqual(fileTimeToTime(132593079670000000).UTC().Format("2006-01-02T15:04:05Z"), "2021-03-04T05:06:07Z", t)
+}
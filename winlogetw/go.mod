@@ -0,0 +1,10 @@
+module github.com/huntresslabs/gowinlog/winlogetw
+
+go 1.17
+
+require (
+	github.com/huntresslabs/gowinlog v0.0.0
+	golang.org/x/sys v0.16.0
+)
+
+replace github.com/huntresslabs/gowinlog => ../
@@ -0,0 +1,170 @@
+package winlogetw
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Record is an ETW event decoded with TDH, before it's converted to a
+// WinLogEvent.
+type Record struct {
+	ProviderGuid string
+	// From the provider's manifest or TraceLogging metadata, "" if TDH
+	// couldn't find the provider's schema
+	ProviderName string
+
+	EventId  uint16
+	Version  uint8
+	Channel  uint8
+	Level    uint8
+	Opcode   uint8
+	Task     uint16
+	Keywords uint64
+
+	ProcessId uint32
+	ThreadId  uint32
+	Timestamp time.Time
+
+	// GUIDs in braces, or "" if the provider didn't set them
+	ActivityId        string
+	RelatedActivityId string
+
+	// Names from the provider's schema, "" if it doesn't have them.
+	// TraceLogging events have an EventName rather than a TaskName.
+	EventName    string
+	ChannelName  string
+	LevelName    string
+	TaskName     string
+	OpcodeName   string
+	KeywordsName string
+
+	// The event's message, with "%1" style inserts for its properties, or
+	// the text of string-only events
+	Message string
+
+	// Properties formatted by TDH, in the order of the event's template.
+	// Array elements and struct members are named "Name[0]" and
+	// "Struct.Member".
+	Properties []Property
+}
+
+// Property is a formatted value of an event's payload.
+type Property struct {
+	Name  string
+	Value string
+}
+
+// Inserts in message strings, with optional printf style format
+// specifications, such as "%1" and "%2!s!"
+var insertPattern = regexp.MustCompile(`%(\d+)(?:![^!]*!)?`)
+
+// message replaces the inserts in the Message with the values of the
+// properties they refer to. Inserts for properties the event doesn't have
+// are left as they are.
+func (r *Record) message() string {
+	msg := insertPattern.ReplaceAllStringFunc(r.Message, func(insert string) string {
+		index, err := strconv.Atoi(insertPattern.FindStringSubmatch(insert)[1])
+		if err != nil || index < 1 || index > len(r.Properties) {
+			return insert
+		}
+		return r.Properties[index-1].Value
+	})
+	return strings.TrimSpace(msg)
+}
+
+// Event converts the record to a WinLogEvent. The session name is used as
+// the SubscribedChannel, and as the Channel if the provider didn't declare
+// one, so ETW events can be told apart from event log events.
+func (r *Record) Event(session, computerName string) *winlog.WinLogEvent {
+	ev := &winlog.WinLogEvent{
+		ProviderName:      r.ProviderName,
+		EventId:           uint64(r.EventId),
+		Level:             uint64(r.Level),
+		Task:              uint64(r.Task),
+		Opcode:            uint64(r.Opcode),
+		Created:           r.Timestamp,
+		ProcessId:         uint64(r.ProcessId),
+		ThreadId:          uint64(r.ThreadId),
+		Channel:           r.ChannelName,
+		ComputerName:      computerName,
+		Version:           uint64(r.Version),
+		KeywordsMask:      r.Keywords,
+		ActivityId:        r.ActivityId,
+		RelatedActivityId: r.RelatedActivityId,
+		Outcome:           winlog.DeriveOutcome(r.Keywords, uint64(r.Level)),
+		Msg:               r.message(),
+		LevelText:         r.LevelName,
+		TaskText:          r.TaskName,
+		OpcodeText:        r.OpcodeName,
+		Keywords:          r.KeywordsName,
+		ChannelText:       r.ChannelName,
+		ProviderText:      r.ProviderName,
+		SubscribedChannel: session,
+	}
+	if ev.ProviderName == "" {
+		ev.ProviderName = r.ProviderGuid
+	}
+	if ev.Channel == "" {
+		ev.Channel = session
+	}
+	if ev.TaskText == "" {
+		ev.TaskText = r.EventName
+	}
+	if len(r.Properties) > 0 {
+		ev.EventData = make(map[string]string, len(r.Properties))
+		for _, p := range r.Properties {
+			ev.EventData[p.Name] = p.Value
+		}
+	}
+	ev.Xml = r.xml(ev)
+	return ev
+}
+
+// Format of SystemTime attributes in event XML
+const systemTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+// xml renders the event in the event log's schema, so consumers of
+// WinLogEvent.Xml, and Redactor, work the same for ETW events.
+func (r *Record) xml(ev *winlog.WinLogEvent) []byte {
+	var buf bytes.Buffer
+	text := func(s string) string {
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(s))
+		return escaped.String()
+	}
+	buf.WriteString("<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System>")
+	fmt.Fprintf(&buf, "<Provider Name='%s' Guid='%s'/>", text(ev.ProviderName), text(r.ProviderGuid))
+	fmt.Fprintf(&buf, "<EventID>%d</EventID>", r.EventId)
+	fmt.Fprintf(&buf, "<Version>%d</Version><Level>%d</Level><Task>%d</Task><Opcode>%d</Opcode>", r.Version, r.Level, r.Task, r.Opcode)
+	fmt.Fprintf(&buf, "<Keywords>0x%x</Keywords>", r.Keywords)
+	fmt.Fprintf(&buf, "<TimeCreated SystemTime='%s'/>", r.Timestamp.UTC().Format(systemTimeFormat))
+	if r.ActivityId != "" || r.RelatedActivityId != "" {
+		buf.WriteString("<Correlation")
+		if r.ActivityId != "" {
+			fmt.Fprintf(&buf, " ActivityID='%s'", text(r.ActivityId))
+		}
+		if r.RelatedActivityId != "" {
+			fmt.Fprintf(&buf, " RelatedActivityID='%s'", text(r.RelatedActivityId))
+		}
+		buf.WriteString("/>")
+	}
+	fmt.Fprintf(&buf, "<Execution ProcessID='%d' ThreadID='%d'/>", r.ProcessId, r.ThreadId)
+	fmt.Fprintf(&buf, "<Channel>%s</Channel><Computer>%s</Computer>", text(ev.Channel), text(ev.ComputerName))
+	buf.WriteString("</System>")
+	if len(r.Properties) > 0 {
+		buf.WriteString("<EventData>")
+		for _, p := range r.Properties {
+			fmt.Fprintf(&buf, "<Data Name='%s'>%s</Data>", text(p.Name), text(p.Value))
+		}
+		buf.WriteString("</EventData>")
+	}
+	buf.WriteString("</Event>")
+	return buf.Bytes()
+}
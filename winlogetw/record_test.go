@@ -0,0 +1,93 @@
+package winlogetw
+
+import (
+	"strings"
+	. "testing"
+	"time"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+func testRecord() *Record {
+	return &Record{
+		ProviderGuid: "{1C95126E-7EEA-49A9-A3FE-A378B03DDB4D}",
+		ProviderName: "Microsoft-Windows-DNS-Client",
+		EventId:      3008,
+		Level:        4,
+		Task:         3008,
+		Keywords:     0x8000000000000000,
+		ProcessId:    1234,
+		ThreadId:     5678,
+		Timestamp:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		ActivityId:   "{6D7B5C2A-0000-0000-0000-000000000001}",
+		ChannelName:  "Microsoft-Windows-DNS-Client/Operational",
+		LevelName:    "Information",
+		Message:      "DNS query is completed for the name %1, type %2!d!, query options %3 with status %4",
+		Properties: []Property{
+			{Name: "QueryName", Value: "example.com"},
+			{Name: "QueryType", Value: "1"},
+			{Name: "QueryOptions", Value: "140737488355328"},
+			{Name: "QueryStatus", Value: "0"},
+		},
+	}
+}
+
+func TestRecordEvent(t *T) {
+	ev := testRecord().Event("gowinlog-dns", "host1")
+	testutil.AssertEqual(ev.ProviderName, "Microsoft-Windows-DNS-Client", t)
+	testutil.AssertEqual(ev.EventId, uint64(3008), t)
+	testutil.AssertEqual(ev.Level, uint64(4), t)
+	testutil.AssertEqual(ev.ProcessId, uint64(1234), t)
+	testutil.AssertEqual(ev.Channel, "Microsoft-Windows-DNS-Client/Operational", t)
+	testutil.AssertEqual(ev.SubscribedChannel, "gowinlog-dns", t)
+	testutil.AssertEqual(ev.ComputerName, "host1", t)
+	testutil.AssertEqual(ev.LevelText, "Information", t)
+	testutil.AssertEqual(ev.EventData["QueryName"], "example.com", t)
+	testutil.AssertEqual(ev.Msg, "DNS query is completed for the name example.com, type 1, query options 140737488355328 with status 0", t)
+	testutil.AssertEqual(ev.Created.Equal(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)), true, t)
+
+	xml := string(ev.Xml)
+	for _, part := range []string{
+		"<Provider Name='Microsoft-Windows-DNS-Client' Guid='{1C95126E-7EEA-49A9-A3FE-A378B03DDB4D}'/>",
+		"<EventID>3008</EventID>",
+		"<TimeCreated SystemTime='2021-03-04T05:06:07.0000000Z'/>",
+		"<Correlation ActivityID='{6D7B5C2A-0000-0000-0000-000000000001}'/>",
+		"<Data Name='QueryName'>example.com</Data>",
+	} {
+		if !strings.Contains(xml, part) {
+			t.Fatalf("%v missing from %v", part, xml)
+		}
+	}
+}
+
+func TestRecordEventWithoutSchema(t *T) {
+	// TraceLogging events have names but no channel, and events of
+	// providers without a registered schema don't have a provider name
+	record := &Record{ProviderGuid: "{00000000-0000-0000-0000-000000000001}", EventName: "RequestCompleted"}
+	ev := record.Event("gowinlog-trace", "host1")
+	testutil.AssertEqual(ev.ProviderName, "{00000000-0000-0000-0000-000000000001}", t)
+	testutil.AssertEqual(ev.Channel, "gowinlog-trace", t)
+	testutil.AssertEqual(ev.TaskText, "RequestCompleted", t)
+	testutil.AssertEqual(ev.EventData == nil, true, t)
+}
+
+func TestRecordMessage(t *T) {
+	for _, test := range []struct {
+		message string
+		want    string
+	}{
+		{"Value %1", "Value a"},
+		{"%2!s! then %1", "b then a"},
+		{"Missing %3", "Missing %3"},
+		{"  Padded %1\r\n", "Padded a"},
+	} {
+		record := &Record{Message: test.message, Properties: []Property{{"A", "a"}, {"B", "b"}}}
+		testutil.AssertEqual(record.message(), test.want, t)
+	}
+}
+
+func TestRecordOutcome(t *T) {
+	record := &Record{Level: uint8(winlog.LevelError)}
+	testutil.AssertEqual(record.Event("s", "h").Outcome, winlog.OutcomeFailure, t)
+}
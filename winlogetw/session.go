@@ -0,0 +1,150 @@
+// Package winlogetw consumes ETW real-time sessions, for providers which
+// never write to an event log channel, such as TraceLogging providers and
+// the analytic and debug providers of many Windows components:
+//
+//	session := winlogetw.NewSession("gowinlog-dns")
+//	session.Filter, _ = winlog.CompileFilter("EventId == 3008")
+//	err := session.Start(winlogetw.Provider{
+//		Guid: "{1C95126E-7EEA-49A9-A3FE-A378B03DDB4D}", // Microsoft-Windows-DNS-Client
+//	})
+//	defer session.Close()
+//	for ev := range session.Event() {
+//		log.Println(ev)
+//	}
+//
+// Events are decoded with TDH into WinLogEvents, with their properties in
+// EventData, and pass through the same Filter and Redactor as a watcher's
+// events, so they can be written to any winlog.Sink with ForwardTo.
+//
+// Starting sessions requires administrator rights, or membership of the
+// Performance Log Users group. Sessions are only supported on 64-bit
+// Windows; elsewhere Start and Attach return winlog.ErrUnsupportedPlatform.
+// It is a separate module so the core library doesn't load tdh.dll.
+package winlogetw
+
+import (
+	"os"
+	"sync"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Provider is an ETW provider to enable in a session.
+type Provider struct {
+	// GUID of the provider, such as "{1C95126E-7EEA-49A9-A3FE-A378B03DDB4D}"
+	Guid string
+	// Maximum level of the events to receive, such as winlog.LevelWarning,
+	// or 0 for all levels
+	Level uint8
+	// Only receive events with any of these keywords, and all of
+	// MatchAllKeyword. 0 receives events regardless of their keywords.
+	MatchAnyKeyword uint64
+	MatchAllKeyword uint64
+}
+
+// Session receives the events of an ETW real-time session, which it either
+// starts with Start, and stops when closed, or attaches to with Attach.
+type Session struct {
+	Name string
+	// Optionally only deliver events matching a filter
+	Filter *winlog.Filter
+	// Optionally mask or remove sensitive values from events
+	Redactor *winlog.Redactor
+
+	computerName string
+	events       chan *winlog.WinLogEvent
+	errors       chan error
+	done         chan struct{}
+	closeOnce    sync.Once
+	sequence     uint64
+
+	// Platform state: the session's control and consumer handles, its key
+	// in the callback registry and whether this session started it
+	started   bool
+	control   uint64
+	trace     uint64
+	context   uintptr
+	processed chan struct{}
+}
+
+// NewSession returns a session with the given name, which is neither started
+// nor attached yet.
+func NewSession(name string) *Session {
+	computerName, _ := os.Hostname()
+	return &Session{
+		Name:         name,
+		computerName: computerName,
+		events:       make(chan *winlog.WinLogEvent),
+		errors:       make(chan error),
+		done:         make(chan struct{}),
+	}
+}
+
+// Event returns a channel of the session's events, which is closed when the
+// session is closed.
+func (s *Session) Event() <-chan *winlog.WinLogEvent {
+	return s.events
+}
+
+// Error returns a channel of errors consuming the session, which is closed
+// when the session is closed. Errors decoding an event are reported in its
+// EventDataErr instead.
+func (s *Session) Error() <-chan error {
+	return s.errors
+}
+
+// ForwardTo writes the session's events to a sink until the session is
+// closed, returning the first error writing an event. The sink isn't
+// closed.
+func (s *Session) ForwardTo(sink winlog.Sink) error {
+	for ev := range s.events {
+		if err := sink.Write(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops consuming the session, and stops the session if Start started
+// it. The Event and Error channels are closed once events being delivered
+// have been dropped.
+func (s *Session) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.close()
+		close(s.events)
+		close(s.errors)
+	})
+	return err
+}
+
+// deliver filters, redacts and publishes a decoded record. Events block
+// until they're read, so a slow consumer makes ETW drop events once the
+// session's buffers are full.
+func (s *Session) deliver(record *Record, decodeErr error) {
+	ev := record.Event(s.Name, s.computerName)
+	ev.EventDataErr = decodeErr
+	if s.Filter != nil && !s.Filter.Match(ev) {
+		return
+	}
+	if s.Redactor != nil {
+		if err := s.Redactor.Redact(ev); err != nil {
+			s.publishError(err)
+			return
+		}
+	}
+	s.sequence++
+	ev.Sequence = s.sequence
+	select {
+	case s.events <- ev:
+	case <-s.done:
+	}
+}
+
+func (s *Session) publishError(err error) {
+	select {
+	case s.errors <- err:
+	case <-s.done:
+	}
+}
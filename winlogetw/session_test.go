@@ -0,0 +1,68 @@
+package winlogetw
+
+import (
+	"errors"
+	. "testing"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+type memorySink struct {
+	events []*winlog.WinLogEvent
+	err    error
+}
+
+func (s *memorySink) Write(ev *winlog.WinLogEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func TestDeliver(t *T) {
+	session := NewSession("gowinlog-test")
+	filter, err := winlog.CompileFilter("EventId == 3008")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Filter = filter
+	session.Redactor = &winlog.Redactor{MaskFields: []string{"QueryName"}}
+
+	go func() {
+		other := testRecord()
+		other.EventId = 3006
+		session.deliver(other, nil)
+		session.deliver(testRecord(), errors.New("Partly decoded"))
+		session.deliver(testRecord(), nil)
+		session.Close()
+	}()
+	sink := &memorySink{}
+	if err := session.ForwardTo(sink); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.AssertEqual(len(sink.events), 2, t)
+	ev := sink.events[0]
+	testutil.AssertEqual(ev.EventId, uint64(3008), t)
+	testutil.AssertEqual(ev.Sequence, uint64(1), t)
+	testutil.AssertEqual(ev.EventDataErr.Error(), "Partly decoded", t)
+	if ev.EventData["QueryName"] == "example.com" {
+		t.Fatal("QueryName wasn't masked")
+	}
+	testutil.AssertEqual(sink.events[1].Sequence, uint64(2), t)
+}
+
+func TestForwardToError(t *T) {
+	session := NewSession("gowinlog-test")
+	go session.deliver(testRecord(), nil)
+	sink := &memorySink{err: errors.New("Sink failed")}
+	err := session.ForwardTo(sink)
+	testutil.AssertEqual(err.Error(), "Sink failed", t)
+	session.Close()
+}
@@ -0,0 +1,163 @@
+//go:build windows && (amd64 || arm64)
+// +build windows
+// +build amd64 arm64
+
+package winlogetw
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Provider of the header event ETW sends consumers when they open a
+// session, which isn't passed on
+var eventTraceGuid = windows.GUID{Data1: 0x68fdd900, Data2: 0x4a3e, Data3: 0x11d1, Data4: [8]byte{0x84, 0xf4, 0x00, 0x00, 0xf8, 0x04, 0x64, 0xe3}}
+
+// Sessions being consumed, by the context passed to the event callback.
+// Callbacks made with syscall.NewCallback are never freed, so all sessions
+// share one.
+var (
+	sessionsMutex  sync.Mutex
+	sessions       = make(map[uintptr]*Session)
+	nextContext    uintptr
+	callbackOnce   sync.Once
+	recordCallback uintptr
+)
+
+func eventRecordCallback() uintptr {
+	callbackOnce.Do(func() {
+		recordCallback = syscall.NewCallback(onEventRecord)
+	})
+	return recordCallback
+}
+
+func onEventRecord(r *eventRecord) uintptr {
+	sessionsMutex.Lock()
+	s := sessions[r.UserContext]
+	sessionsMutex.Unlock()
+	if s == nil || r.EventHeader.ProviderId == eventTraceGuid {
+		return 0
+	}
+	record, err := decodeRecord(r)
+	s.deliver(record, err)
+	return 0
+}
+
+// Start starts a real-time session with the session's name, enables the
+// providers in it and starts consuming its events. Fails if a session with
+// the name already exists: use Attach to consume it, or StopSession to stop
+// one left behind by a process which exited without closing it. The
+// session is closed if Start fails.
+func (s *Session) Start(providers ...Provider) error {
+	guids := make([]windows.GUID, len(providers))
+	for i, provider := range providers {
+		guid, err := windows.GUIDFromString(provider.Guid)
+		if err != nil {
+			return fmt.Errorf("Invalid provider GUID %q: %v", provider.Guid, err)
+		}
+		guids[i] = guid
+	}
+	name, err := syscall.UTF16PtrFromString(s.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := startTrace(&s.control, name, newTraceProperties()); err != nil {
+		return fmt.Errorf("Failed to start session %q: %v", s.Name, err)
+	}
+	s.started = true
+	for i, provider := range providers {
+		if err := enableTrace(s.control, &guids[i], provider.Level, provider.MatchAnyKeyword, provider.MatchAllKeyword); err != nil {
+			s.Close()
+			return fmt.Errorf("Failed to enable provider %v: %v", provider.Guid, err)
+		}
+	}
+	if err := s.Attach(); err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+// Attach starts consuming the events of an existing real-time session with
+// the session's name, which is left running when the session is closed.
+func (s *Session) Attach() error {
+	name, err := syscall.UTF16PtrFromString(s.Name)
+	if err != nil {
+		return err
+	}
+
+	sessionsMutex.Lock()
+	nextContext++
+	s.context = nextContext
+	sessions[s.context] = s
+	sessionsMutex.Unlock()
+
+	logfile := &eventTraceLogfile{
+		LoggerName:          name,
+		ProcessTraceMode:    processTraceRealTime | processTraceEventRecord,
+		EventRecordCallback: eventRecordCallback(),
+		Context:             s.context,
+	}
+	if s.trace, err = openTrace(logfile); err != nil {
+		s.unregister()
+		return fmt.Errorf("Failed to open session %q: %v", s.Name, err)
+	}
+
+	s.processed = make(chan struct{})
+	go func() {
+		defer close(s.processed)
+		if err := processTrace(&s.trace); err != nil {
+			select {
+			case <-s.done:
+			default:
+				s.publishError(fmt.Errorf("Failed to process session %q: %v", s.Name, err))
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Session) unregister() {
+	sessionsMutex.Lock()
+	delete(sessions, s.context)
+	sessionsMutex.Unlock()
+}
+
+// close stops the session if it was started, which ends ProcessTrace, then
+// closes the consumer handle and waits for the last event to be delivered.
+func (s *Session) close() error {
+	var err error
+	if s.started {
+		err = controlTrace(s.control, nil, newTraceProperties(), eventTraceControlStop)
+		if err != nil {
+			err = fmt.Errorf("Failed to stop session %q: %v", s.Name, err)
+		}
+	}
+	if s.trace != 0 {
+		if closeErr := closeTrace(s.trace); closeErr != nil && err == nil {
+			err = fmt.Errorf("Failed to close session %q: %v", s.Name, closeErr)
+		}
+	}
+	if s.processed != nil {
+		<-s.processed
+	}
+	s.unregister()
+	return err
+}
+
+// StopSession stops a real-time session by name, such as one left running
+// by a process which exited without closing it.
+func StopSession(name string) error {
+	wide, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if err := controlTrace(0, wide, newTraceProperties(), eventTraceControlStop); err != nil {
+		return fmt.Errorf("Failed to stop session %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+//go:build windows && (amd64 || arm64)
+// +build windows
+// +build amd64 arm64
+
+package winlogetw
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Seconds between the FILETIME and Unix epochs
+const fileTimeEpoch = 11644473600
+
+func fileTimeToTime(ft int64) time.Time {
+	return time.Unix(ft/10000000-fileTimeEpoch, ft%10000000*100)
+}
+
+// decodeRecord converts an EVENT_RECORD during its callback. The header
+// fields are always decoded; an error is returned with them if the event's
+// properties couldn't be, such as when the provider's schema isn't
+// registered.
+func decodeRecord(r *eventRecord) (*Record, error) {
+	header := &r.EventHeader
+	record := &Record{
+		ProviderGuid: header.ProviderId.String(),
+		EventId:      header.EventDescriptor.Id,
+		Version:      header.EventDescriptor.Version,
+		Channel:      header.EventDescriptor.Channel,
+		Level:        header.EventDescriptor.Level,
+		Opcode:       header.EventDescriptor.Opcode,
+		Task:         header.EventDescriptor.Task,
+		Keywords:     header.EventDescriptor.Keyword,
+		ProcessId:    header.ProcessId,
+		ThreadId:     header.ThreadId,
+		Timestamp:    fileTimeToTime(header.TimeStamp),
+	}
+	if header.ActivityId != (windows.GUID{}) {
+		record.ActivityId = header.ActivityId.String()
+	}
+	for i := 0; i < int(r.ExtendedDataCount); i++ {
+		item := (*eventHeaderExtendedDataItem)(unsafe.Add(r.ExtendedData, uintptr(i)*unsafe.Sizeof(eventHeaderExtendedDataItem{})))
+		if item.ExtType == eventHeaderExtRelatedActivityId && item.DataSize >= 16 {
+			record.RelatedActivityId = (*windows.GUID)(item.DataPtr).String()
+		}
+	}
+
+	if header.Flags&eventHeaderFlagStringOnly != 0 {
+		if r.UserDataLength >= 2 {
+			record.Message = windows.UTF16ToString(unsafe.Slice((*uint16)(r.UserData), r.UserDataLength/2))
+		}
+		return record, nil
+	}
+
+	info, err := eventInformation(r)
+	if err != nil {
+		return record, fmt.Errorf("Failed to get event information: %v", err)
+	}
+	record.ProviderName = info.string(info.header().ProviderNameOffset)
+	record.EventName = info.string(info.header().EventNameOffset)
+	record.ChannelName = info.string(info.header().ChannelNameOffset)
+	record.LevelName = info.string(info.header().LevelNameOffset)
+	record.TaskName = info.string(info.header().TaskNameOffset)
+	record.OpcodeName = info.string(info.header().OpcodeNameOffset)
+	record.KeywordsName = info.keywords()
+	record.Message = info.string(info.header().EventMessageOffset)
+
+	d := &decoder{
+		record: r,
+		info:   info,
+		data:   r.UserData,
+		end:    unsafe.Add(r.UserData, r.UserDataLength),
+		ints:   make(map[int]uint64),
+	}
+	d.pointerSize = 8
+	if header.Flags&eventHeaderFlag32BitHeader != 0 {
+		d.pointerSize = 4
+	}
+	err = d.decode(0, int(info.header().TopLevelPropertyCount), "")
+	record.Properties = d.properties
+	if err != nil {
+		return record, fmt.Errorf("Failed to decode event properties: %v", err)
+	}
+	return record, nil
+}
+
+// eventInfo is a buffer filled in by TdhGetEventInformation.
+type eventInfo []byte
+
+func eventInformation(r *eventRecord) (eventInfo, error) {
+	size := uint32(4096)
+	for {
+		buf := make([]byte, size)
+		err := call(procTdhGetEventInformation, uintptr(unsafe.Pointer(r)), 0, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+		if err == windows.ERROR_INSUFFICIENT_BUFFER {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return eventInfo(buf), nil
+	}
+}
+
+func (info eventInfo) header() *traceEventInfo {
+	return (*traceEventInfo)(unsafe.Pointer(&info[0]))
+}
+
+func (info eventInfo) property(index int) *eventPropertyInfo {
+	offset := unsafe.Sizeof(traceEventInfo{}) + uintptr(index)*unsafe.Sizeof(eventPropertyInfo{})
+	return (*eventPropertyInfo)(unsafe.Pointer(&info[offset]))
+}
+
+// wide returns the NUL terminated UTF-16 string at an offset.
+func (info eventInfo) wide(offset uint32) []uint16 {
+	var s []uint16
+	for i := int(offset); i+1 < len(info); i += 2 {
+		c := uint16(info[i]) | uint16(info[i+1])<<8
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+// string returns the string at an offset, or "" for offset 0, which TDH
+// uses for names the event doesn't have. Manifest names are padded with
+// spaces.
+func (info eventInfo) string(offset uint32) string {
+	if offset == 0 {
+		return ""
+	}
+	return strings.TrimSpace(windows.UTF16ToString(info.wide(offset)))
+}
+
+// keywords returns the names of the event's keywords, which TDH lists as
+// consecutive strings ending with an empty one.
+func (info eventInfo) keywords() string {
+	offset := info.header().KeywordsNameOffset
+	if offset == 0 {
+		return ""
+	}
+	var names []string
+	for {
+		wide := info.wide(offset)
+		if len(wide) == 0 {
+			break
+		}
+		names = append(names, strings.TrimSpace(windows.UTF16ToString(wide)))
+		offset += uint32(len(wide)+1) * 2
+	}
+	return strings.Join(names, ", ")
+}
+
+// decoder formats an event's properties with TdhFormatProperty, walking
+// its user data.
+type decoder struct {
+	record    *eventRecord
+	info      eventInfo
+	data, end unsafe.Pointer
+	// Size of pointers in the event, which is 4 for 32-bit processes
+	pointerSize uintptr
+	// Values of integer properties, by index, for the lengths and counts
+	// of later properties
+	ints       map[int]uint64
+	properties []Property
+	buf        []uint16
+}
+
+func (d *decoder) remaining() uintptr {
+	return uintptr(d.end) - uintptr(d.data)
+}
+
+// decode decodes the properties with indexes [start, end), prefixing their
+// names with the name of the struct they're members of.
+func (d *decoder) decode(start, end int, prefix string) error {
+	for i := start; i < end; i++ {
+		p := d.info.property(i)
+		name := prefix + d.info.string(p.NameOffset)
+		count := uint64(p.Count)
+		if p.Flags&propertyParamCount != 0 {
+			count = d.ints[int(p.Count)]
+		}
+		array := p.Flags&(propertyParamCount|propertyParamFixedCount) != 0 || count != 1
+		for j := uint64(0); j < count; j++ {
+			elemName := name
+			if array {
+				elemName = fmt.Sprintf("%v[%d]", name, j)
+			}
+			if p.Flags&propertyStruct != 0 {
+				first := int(p.InType)
+				if err := d.decode(first, first+int(p.OutType), elemName+"."); err != nil {
+					return err
+				}
+				continue
+			}
+			value, err := d.format(i, p, !array)
+			if err != nil {
+				return fmt.Errorf("%v: %v", elemName, err)
+			}
+			d.properties = append(d.properties, Property{Name: elemName, Value: value})
+		}
+	}
+	return nil
+}
+
+// format formats the next value of a property, remembering it if it's an
+// integer another property's length or count could refer to.
+func (d *decoder) format(index int, p *eventPropertyInfo, scalar bool) (string, error) {
+	length := uint64(p.Length)
+	if p.Flags&propertyParamLength != 0 {
+		length = d.ints[int(p.Length)]
+	}
+	// IPv6 addresses are declared as binary without a length
+	if p.InType == tdhInTypeBinary && p.OutType == tdhOutTypeIPv6 {
+		length = 16
+	}
+	if scalar {
+		if value, ok := d.integer(p.InType); ok {
+			d.ints[index] = value
+		}
+	}
+
+	mapInfo, err := d.mapInfo(p.MapNameOffset)
+	if err != nil {
+		return "", err
+	}
+	if d.buf == nil {
+		d.buf = make([]uint16, 256)
+	}
+	for {
+		size := uint32(len(d.buf) * 2)
+		var consumed uint16
+		err := call(procTdhFormatProperty,
+			uintptr(unsafe.Pointer(&d.info[0])), uintptr(unsafe.Pointer(mapInfo)), d.pointerSize,
+			uintptr(p.InType), uintptr(p.OutType), uintptr(length),
+			d.remaining(), uintptr(d.data),
+			uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&d.buf[0])), uintptr(unsafe.Pointer(&consumed)))
+		if err == windows.ERROR_INSUFFICIENT_BUFFER {
+			d.buf = make([]uint16, size/2+1)
+			continue
+		} else if err == syscall.Errno(errorEvtInvalidEventData) && mapInfo != nil {
+			// The value isn't in the map, so format it as a number
+			mapInfo = nil
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		d.data = unsafe.Add(d.data, consumed)
+		return windows.UTF16ToString(d.buf), nil
+	}
+}
+
+// integer reads the next value if it's an unsigned integer.
+func (d *decoder) integer(inType uint16) (uint64, bool) {
+	var size uintptr
+	switch inType {
+	case tdhInTypeInt8, tdhInTypeUInt8:
+		size = 1
+	case tdhInTypeInt16, tdhInTypeUInt16:
+		size = 2
+	case tdhInTypeInt32, tdhInTypeUInt32, tdhInTypeHexInt32:
+		size = 4
+	case tdhInTypeInt64, tdhInTypeUInt64, tdhInTypeHexInt64:
+		size = 8
+	default:
+		return 0, false
+	}
+	if d.remaining() < size {
+		return 0, false
+	}
+	switch size {
+	case 1:
+		return uint64(*(*uint8)(d.data)), true
+	case 2:
+		return uint64(*(*uint16)(d.data)), true
+	case 4:
+		return uint64(*(*uint32)(d.data)), true
+	}
+	return *(*uint64)(d.data), true
+}
+
+// mapInfo returns the EVENT_MAP_INFO of a property's value map, or nil if
+// it hasn't one.
+func (d *decoder) mapInfo(nameOffset uint32) (*byte, error) {
+	if nameOffset == 0 {
+		return nil, nil
+	}
+	name := &d.info[nameOffset]
+	size := uint32(256)
+	for {
+		buf := make([]byte, size)
+		err := call(procTdhGetEventMapInformation, uintptr(unsafe.Pointer(d.record)), uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+		if err == windows.ERROR_INSUFFICIENT_BUFFER {
+			continue
+		} else if err == windows.ERROR_NOT_FOUND {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to get value map: %v", err)
+		}
+		return &buf[0], nil
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows || !(amd64 || arm64)
+// +build !windows !amd64,!arm64
+
+package winlogetw
+
+import (
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+/* Stubs for platforms without ETW, and for 32-bit Windows, whose structure
+   layouts the interop code doesn't handle. */
+
+func (s *Session) Start(providers ...Provider) error {
+	return winlog.ErrUnsupportedPlatform
+}
+
+func (s *Session) Attach() error {
+	return winlog.ErrUnsupportedPlatform
+}
+
+func (s *Session) close() error {
+	return nil
+}
+
+func StopSession(name string) error {
+	return winlog.ErrUnsupportedPlatform
+}
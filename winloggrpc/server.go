@@ -0,0 +1,125 @@
+// Package winloggrpc streams events from a gowinlog watcher over gRPC, so
+// processes written in other languages can consume them. It is a separate
+// module so the core library doesn't depend on gRPC.
+package winloggrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative winlogpb/winlog.proto
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/winloggrpc/winlogpb"
+)
+
+// Server implements the EventLog service. Each Subscribe call gets its own
+// watcher, so clients can use different channels, filters and bookmarks.
+// Register it with
+//
+//	winlogpb.RegisterEventLogServer(grpcServer, winloggrpc.NewServer())
+type Server struct {
+	winlogpb.UnimplementedEventLogServer
+
+	// NewWatcher creates the watcher for a Subscribe call. The server sets
+	// the watcher's Filter from the request.
+	NewWatcher func() (*winlog.WinLogWatcher, error)
+}
+
+// NewServer creates a server whose watchers render every localized field.
+func NewServer() *Server {
+	return &Server{NewWatcher: newRenderingWatcher}
+}
+
+func newRenderingWatcher() (*winlog.WinLogWatcher, error) {
+	return winlog.NewWinLogWatcherWithOptions(winlog.WatcherOptions{Render: winlog.RenderAllFields})
+}
+
+// Subscribe streams events until the client cancels the call. If the watcher
+// reports an error the stream ends with codes.Unavailable, and the client
+// should resubscribe from the bookmark of the last event it received.
+func (s *Server) Subscribe(req *winlogpb.SubscribeRequest, stream winlogpb.EventLog_SubscribeServer) error {
+	if req.Channel == "" {
+		return status.Error(codes.InvalidArgument, "channel is required")
+	}
+	query := req.Query
+	if query == "" {
+		query = "*"
+	}
+	var filter *winlog.Filter
+	if req.Filter != "" {
+		var err error
+		if filter, err = winlog.CompileFilter(req.Filter); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	watcher, err := s.NewWatcher()
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to create watcher: %v", err)
+	}
+	defer watcher.Shutdown()
+	if filter != nil {
+		watcher.Filter = filter
+	}
+	switch {
+	case req.Bookmark != "":
+		err = watcher.SubscribeFromBookmark(req.Channel, query, req.Bookmark)
+	case req.FromBeginning:
+		err = watcher.SubscribeFromBeginning(req.Channel, query)
+	default:
+		err = watcher.SubscribeFromNow(req.Channel, query)
+	}
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "Failed to subscribe to %q: %v", req.Channel, err)
+	}
+
+	for {
+		select {
+		case ev := <-watcher.Event():
+			if err := stream.Send(EventToProto(ev)); err != nil {
+				return err
+			}
+		case err := <-watcher.Error():
+			return status.Error(codes.Unavailable, err.Error())
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+// EventToProto converts an event to its protobuf form.
+func EventToProto(ev *winlog.WinLogEvent) *winlogpb.Event {
+	pb := &winlogpb.Event{
+		ProviderName:      ev.ProviderName,
+		EventId:           ev.EventId,
+		Qualifiers:        ev.Qualifiers,
+		Level:             ev.Level,
+		Task:              ev.Task,
+		Opcode:            ev.Opcode,
+		RecordId:          ev.RecordId,
+		ProcessId:         ev.ProcessId,
+		ThreadId:          ev.ThreadId,
+		Channel:           ev.Channel,
+		ComputerName:      ev.ComputerName,
+		Version:           ev.Version,
+		Msg:               ev.Msg,
+		LevelText:         ev.LevelText,
+		TaskText:          ev.TaskText,
+		OpcodeText:        ev.OpcodeText,
+		Keywords:          ev.Keywords,
+		ChannelText:       ev.ChannelText,
+		ProviderText:      ev.ProviderText,
+		IdText:            ev.IdText,
+		EventData:         ev.EventData,
+		Xml:               string(ev.Xml),
+		Bookmark:          ev.Bookmark,
+		SubscribedChannel: ev.SubscribedChannel,
+		Sequence:          ev.Sequence,
+	}
+	if !ev.Created.IsZero() {
+		pb.Created = timestamppb.New(ev.Created)
+	}
+	return pb
+}
@@ -0,0 +1,93 @@
+package winloggrpc
+
+import (
+	"context"
+	"net"
+	. "testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+	"github.com/huntresslabs/gowinlog/winloggrpc/winlogpb"
+)
+
+func startServer(t *T, fake *testutil.FakeEventLog) winlogpb.EventLogClient {
+	listener := bufconn.Listen(1 << 20)
+	server := &Server{NewWatcher: func() (*winlog.WinLogWatcher, error) {
+		watcher, err := winlog.NewWinLogWatcherWithAPI(fake)
+		if err != nil {
+			return nil, err
+		}
+		watcher.RenderMessage = true
+		return watcher, nil
+	}}
+	grpcServer := grpc.NewServer()
+	winlogpb.RegisterEventLogServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	dial := func(context.Context, string) (net.Conn, error) { return listener.Dial() }
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dial), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return winlogpb.NewEventLogClient(conn)
+}
+
+func TestSubscribe(t *T) {
+	fake := testutil.NewFakeEventLog()
+	client := startServer(t, fake)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Subscribe(ctx, &winlogpb.SubscribeRequest{Channel: "Application", Filter: "EventId != 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the server to subscribe before emitting
+	for fake.Subscriptions("Application") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	go func() {
+		for id := uint64(1); id <= 3; id++ {
+			fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Test", EventId: id, Created: created, Msg: "Hello", EventData: map[string]string{"Id": "x"}})
+		}
+	}()
+	for _, want := range []uint64{1, 3} {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.EventId != want || ev.ProviderName != "Test" || ev.Msg != "Hello" || ev.EventData["Id"] != "x" {
+			t.Fatalf("Unexpected event %v", ev)
+		}
+		if !ev.Created.AsTime().Equal(created) {
+			t.Fatalf("Unexpected creation time %v", ev.Created.AsTime())
+		}
+	}
+	cancel()
+	for i := 0; i < 100 && fake.Subscriptions("Application") != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := fake.Subscriptions("Application"); n != 0 {
+		t.Fatalf("%v subscriptions left open after the call was cancelled", n)
+	}
+}
+
+func TestSubscribeInvalidFilter(t *T) {
+	client := startServer(t, testutil.NewFakeEventLog())
+	stream, err := client.Subscribe(context.Background(), &winlogpb.SubscribeRequest{Channel: "Application", Filter: "EventId =="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/huntresslabs/gowinlog/winlogkafka
+
+go 1.17
+
+require (
+	github.com/huntresslabs/gowinlog v0.0.0
+	github.com/segmentio/kafka-go v0.4.47
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+)
+
+replace github.com/huntresslabs/gowinlog => ../
@@ -0,0 +1,145 @@
+// Package winlogkafka produces events from a gowinlog watcher to Kafka. It is
+// a separate module so the core library doesn't depend on a Kafka client.
+package winlogkafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Keys which decide an event's partition
+const (
+	KeyComputerName = "ComputerName"
+	KeyChannel      = "Channel"
+)
+
+// messageWriter is the part of *kafka.Writer the sink uses.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Sink produces events as JSON messages. Bookmarks are saved to Store only
+// once the brokers have acknowledged the events, so after a crash the
+// watcher resumes before any event which may not have been written: events
+// are delivered at least once.
+type Sink struct {
+	// Topic for each event. "{Channel}", "{ComputerName}" and "{Provider}"
+	// are replaced with the event's values, with characters Kafka doesn't
+	// allow in topic names replaced by "_".
+	Topic string
+	// Field used as the message key, and so to choose the partition:
+	// KeyComputerName or KeyChannel.
+	Key string
+	// Where bookmarks are saved, keyed by SubscribedChannel. May be nil.
+	Store winlog.BookmarkStore
+	// Time allowed for each write, including retries.
+	Timeout time.Duration
+
+	writer messageWriter
+}
+
+// NewSink creates a sink producing to `brokers`, waiting for every in-sync
+// replica to acknowledge each write.
+func NewSink(brokers []string, topic string, store winlog.BookmarkStore) *Sink {
+	return newSink(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}, topic, store)
+}
+
+func newSink(writer messageWriter, topic string, store winlog.BookmarkStore) *Sink {
+	return &Sink{Topic: topic, Key: KeyComputerName, Store: store, Timeout: 30 * time.Second, writer: writer}
+}
+
+// Write produces a single event and saves its bookmark.
+func (s *Sink) Write(ev *winlog.WinLogEvent) error {
+	return s.WriteEvents([]*winlog.WinLogEvent{ev})
+}
+
+// WriteEvents produces the events in one request, then saves the bookmark of
+// the last event from each subscription. Batching events is much faster
+// than writing them one at a time.
+func (s *Sink) WriteEvents(events []*winlog.WinLogEvent) error {
+	messages := make([]kafka.Message, len(events))
+	for i, ev := range events {
+		message, err := s.message(ev)
+		if err != nil {
+			return err
+		}
+		messages[i] = message
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	if err := s.writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("Failed to produce %v events: %v", len(messages), err)
+	}
+
+	if s.Store == nil {
+		return nil
+	}
+	bookmarks := make(map[string]string)
+	var channels []string
+	for _, ev := range events {
+		if ev.Bookmark == "" {
+			continue
+		}
+		if _, ok := bookmarks[ev.SubscribedChannel]; !ok {
+			channels = append(channels, ev.SubscribedChannel)
+		}
+		bookmarks[ev.SubscribedChannel] = ev.Bookmark
+	}
+	for _, channel := range channels {
+		if err := s.Store.Save(channel, bookmarks[channel]); err != nil {
+			return fmt.Errorf("Failed to save bookmark for %q: %v", channel, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sink) message(ev *winlog.WinLogEvent) (kafka.Message, error) {
+	m := ev.CreateMap()
+	m["Xml"] = string(ev.Xml)
+	value, err := json.Marshal(m)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	key := ev.ComputerName
+	if s.Key == KeyChannel {
+		key = ev.Channel
+	}
+	return kafka.Message{Topic: s.topic(ev), Key: []byte(key), Value: value}, nil
+}
+
+func (s *Sink) topic(ev *winlog.WinLogEvent) string {
+	replacer := strings.NewReplacer(
+		"{Channel}", topicName(ev.Channel),
+		"{ComputerName}", topicName(ev.ComputerName),
+		"{Provider}", topicName(ev.ProviderName),
+	)
+	return replacer.Replace(s.Topic)
+}
+
+// topicName replaces characters which aren't allowed in Kafka topic names.
+func topicName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// Close flushes and closes the producer. It doesn't close Store.
+func (s *Sink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,77 @@
+package winlogkafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "testing"
+
+	"github.com/segmentio/kafka-go"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+type fakeWriter struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+type memoryStore map[string]string
+
+func (m memoryStore) Load(channel string) (string, error) { return m[channel], nil }
+func (m memoryStore) Save(channel, bookmark string) error { m[channel] = bookmark; return nil }
+func (m memoryStore) Close() error                        { return nil }
+
+func TestWriteEvents(t *T) {
+	writer := &fakeWriter{}
+	store := memoryStore{}
+	sink := newSink(writer, "winlog.{Channel}", store)
+	events := []*winlog.WinLogEvent{
+		{EventId: 1, Channel: "Microsoft-Windows-Sysmon/Operational", SubscribedChannel: "Sysmon", ComputerName: "a", Bookmark: "1"},
+		{EventId: 2, Channel: "Security", SubscribedChannel: "Security", ComputerName: "b", Bookmark: "2"},
+		{EventId: 3, Channel: "Security", SubscribedChannel: "Security", ComputerName: "b", Bookmark: "3"},
+	}
+	if err := sink.WriteEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.messages) != 3 {
+		t.Fatalf("Produced %v messages, expected 3", len(writer.messages))
+	}
+	if topic := writer.messages[0].Topic; topic != "winlog.Microsoft-Windows-Sysmon_Operational" {
+		t.Fatalf("Unexpected topic %q", topic)
+	}
+	if key := string(writer.messages[1].Key); key != "b" {
+		t.Fatalf("Unexpected key %q", key)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(writer.messages[2].Value, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["EventId"] != float64(3) {
+		t.Fatalf("Unexpected EventId %v", decoded["EventId"])
+	}
+	if store["Security"] != "3" || store["Sysmon"] != "1" {
+		t.Fatalf("Unexpected bookmarks %v", store)
+	}
+
+	// Bookmarks aren't saved if the write fails
+	writer.err = errors.New("no brokers")
+	if err := sink.Write(&winlog.WinLogEvent{SubscribedChannel: "Security", Bookmark: "4"}); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if store["Security"] != "3" {
+		t.Fatalf("Bookmark saved after a failed write: %v", store["Security"])
+	}
+}
@@ -0,0 +1,124 @@
+// Package winlogparquet writes gowinlog events to Parquet files for analysis
+// in tools such as Spark, DuckDB or pandas. It is a separate module so the
+// core library doesn't depend on a Parquet implementation.
+package winlogparquet
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/writer"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Number of goroutines encoding each row group
+const writerParallelism = 4
+
+// Export columns stored as integers rather than strings
+var integerColumns = map[string]bool{
+	"EventId":         true,
+	"Qualifiers":      true,
+	"Level":           true,
+	"Task":            true,
+	"Opcode":          true,
+	"RecordId":        true,
+	"ProcessId":       true,
+	"ThreadId":        true,
+	"Version":         true,
+	"SampleRate":      true,
+	"SuppressedCount": true,
+	"Sequence":        true,
+}
+
+// Writer writes events as rows of a Parquet file. Every column is optional,
+// so events missing a value, such as an EventData field, are written as
+// nulls. Created is a millisecond timestamp, the numeric system fields are
+// INT64 and everything else is a UTF-8 string.
+type Writer struct {
+	Columns []string
+
+	writer *writer.CSVWriter
+}
+
+// NewWriter writes the columns of each event to `w`, which must not be
+// written to by anything else until the Writer is closed. Columns are named
+// as for winlog.ExportValue; if columns is nil, winlog.DefaultExportColumns
+// is used.
+func NewWriter(w io.Writer, columns []string) (*Writer, error) {
+	if columns == nil {
+		columns = winlog.DefaultExportColumns
+	}
+	pw, err := writer.NewCSVWriterFromWriter(Schema(columns), w, writerParallelism)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Parquet writer: %v", err)
+	}
+	return &Writer{Columns: columns, writer: pw}, nil
+}
+
+// FieldName returns the Parquet field name of an export column. Parquet
+// separates nested fields with dots, so "EventData.TargetUserName" is
+// written as "EventData_TargetUserName".
+func FieldName(column string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, column)
+}
+
+// Schema returns the Parquet schema of the columns, in the metadata form
+// used by parquet-go.
+func Schema(columns []string) []string {
+	schema := make([]string, len(columns))
+	for i, column := range columns {
+		name := FieldName(column)
+		switch {
+		case column == "Created":
+			schema[i] = fmt.Sprintf("name=%v, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL", name)
+		case integerColumns[column]:
+			schema[i] = fmt.Sprintf("name=%v, type=INT64, repetitiontype=OPTIONAL", name)
+		default:
+			schema[i] = fmt.Sprintf("name=%v, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", name)
+		}
+	}
+	return schema
+}
+
+func (w *Writer) Write(ev *winlog.WinLogEvent) error {
+	row := make([]*string, len(w.Columns))
+	for i, column := range w.Columns {
+		var value string
+		switch {
+		case column == "Created":
+			if ev.Created.IsZero() {
+				continue
+			}
+			value = strconv.FormatInt(ev.Created.UnixNano()/1e6, 10)
+		case strings.HasPrefix(column, winlog.EventDataColumnPrefix):
+			var ok bool
+			if value, ok = ev.EventData[strings.TrimPrefix(column, winlog.EventDataColumnPrefix)]; !ok {
+				continue
+			}
+		default:
+			value = winlog.ExportValue(ev, column)
+			if value == "" && integerColumns[column] {
+				continue
+			}
+		}
+		row[i] = &value
+	}
+	if err := w.writer.WriteString(row); err != nil {
+		return fmt.Errorf("Failed to write Parquet row: %v", err)
+	}
+	return nil
+}
+
+// Close writes the remaining rows and the file footer. It doesn't close the
+// underlying writer.
+func (w *Writer) Close() error {
+	return w.writer.WriteStop()
+}
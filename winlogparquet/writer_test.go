@@ -0,0 +1,72 @@
+package winlogparquet
+
+import (
+	"bytes"
+	. "testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+func TestWriter(t *T) {
+	events := []*winlog.WinLogEvent{
+		{EventId: 4624, Created: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), EventData: map[string]string{"TargetUserName": "alice"}},
+		{EventId: 4625, Msg: "Failed", EventData: map[string]string{"TargetUserName": "bob", "Status": "0xc000006d"}},
+	}
+	columns := append([]string{"EventId", "Created", "Msg"}, winlog.EventDataColumns(events)...)
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if err := w.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if pr.GetNumRows() != 2 {
+		t.Fatalf("Expected 2 rows, got %v", pr.GetNumRows())
+	}
+	read := func(column string) []interface{} {
+		values, _, _, err := pr.ReadColumnByPath(common.ReformPathStr("parquet_go_root."+FieldName(column)), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	ids := read("EventId")
+	if ids[0] != int64(4624) || ids[1] != int64(4625) {
+		t.Fatalf("Unexpected EventIds %v", ids)
+	}
+	created := read("Created")
+	if len(created) != 2 || created[0] != events[0].Created.UnixNano()/1e6 || created[1] != nil {
+		t.Fatalf("Unexpected Created %v", created)
+	}
+	status := read("EventData.Status")
+	if len(status) != 2 || status[0] != nil || status[1] != "0xc000006d" {
+		t.Fatalf("Unexpected EventData.Status %v", status)
+	}
+	users := read("EventData.TargetUserName")
+	if users[0] != "alice" || users[1] != "bob" {
+		t.Fatalf("Unexpected EventData.TargetUserName %v", users)
+	}
+}
@@ -0,0 +1,166 @@
+// Package winlogprom exposes the counters of a gowinlog watcher as Prometheus
+// metrics. It is a separate module so the core library doesn't depend on the
+// Prometheus client.
+package winlogprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	winlog "github.com/huntresslabs/gowinlog"
+)
+
+// Collector is a prometheus.Collector reporting WinLogWatcher.Stats for each
+// subscription, labelled by server and channel, and for watchers reading
+// another computer through a RemoteAPI, its RemoteHostStats labelled by
+// server. The server label is empty for watchers reading the local event
+// log. Register one collector for all of a process's watchers with
+//
+//	prometheus.MustRegister(winlogprom.NewCollector(local, dc01, dc02))
+//
+// since a second collector's metrics would be registered twice.
+type Collector struct {
+	watchers []*winlog.WinLogWatcher
+
+	events           *prometheus.Desc
+	filtered         *prometheus.Desc
+	dropped          *prometheus.Desc
+	expired          *prometheus.Desc
+	enrichErrors     *prometheus.Desc
+	enrichOverBudget *prometheus.Desc
+	renderErrors     *prometheus.Desc
+	formatErrors     *prometheus.Desc
+	lostRecords      *prometheus.Desc
+	backlog          *prometheus.Desc
+	lastEvent        *prometheus.Desc
+	bookmarkLag      *prometheus.Desc
+	renderSeconds    *prometheus.Desc
+	deliverySeconds  *prometheus.Desc
+
+	remoteConnected   *prometheus.Desc
+	remoteRate        *prometheus.Desc
+	remoteLag         *prometheus.Desc
+	remoteLastContact *prometheus.Desc
+	remoteFailures    *prometheus.Desc
+}
+
+// NewCollector creates a collector for the watchers. Metric names are
+// prefixed with "gowinlog_". No two of the watchers may subscribe to the same
+// channel of the same computer.
+func NewCollector(watchers ...*winlog.WinLogWatcher) *Collector {
+	labels := []string{"server", "channel"}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("gowinlog_"+name, help, labels, nil)
+	}
+	hostDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("gowinlog_remote_"+name, help, []string{"server"}, nil)
+	}
+	return &Collector{
+		watchers:         watchers,
+		events:           desc("events_total", "Events delivered to the consumer."),
+		filtered:         desc("filtered_total", "Events removed by filters, sampling or duplicate suppression."),
+		dropped:          desc("dropped_total", "Events dropped by rate limiting or load shedding."),
+		expired:          desc("expired_total", "Events discarded for being older than the expiry TTL."),
+		enrichErrors:     desc("enrich_errors_total", "Failed calls to the watcher's enrichers."),
+		enrichOverBudget: desc("enrich_over_budget_total", "Events not enriched by every enricher within the enrich budget."),
+		renderErrors:     desc("render_errors_total", "Events whose system values or XML failed to render."),
+		formatErrors:     desc("format_errors_total", "Failed EvtFormatMessage calls."),
+		lostRecords:      desc("lost_records_total", "Records overwritten before the subscription read them, found from gaps in RecordIds."),
+		backlog:          desc("backlog", "Events received from the event log but not yet delivered."),
+		lastEvent:        desc("last_event_timestamp_seconds", "Creation time of the last delivered event."),
+		bookmarkLag:      desc("bookmark_lag_records", "Records between the bookmark and the newest record in the channel."),
+		renderSeconds:    desc("render_seconds", "Time taken to render and format events."),
+		deliverySeconds:  desc("delivery_latency_seconds", "Time from event creation until delivery to the consumer."),
+
+		remoteConnected:   hostDesc("connected", "Whether the session to the remote computer is connected."),
+		remoteRate:        hostDesc("events_per_second", "Events delivered per second from the remote computer over the last minute."),
+		remoteLag:         hostDesc("bookmark_lag_records", "Largest bookmark lag of the subscriptions to the remote computer."),
+		remoteLastContact: hostDesc("last_contact_timestamp_seconds", "When the remote computer was last reached."),
+		remoteFailures:    hostDesc("consecutive_failures", "Failed probes and reconnection attempts since the remote computer was last reached."),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.events
+	ch <- c.filtered
+	ch <- c.dropped
+	ch <- c.expired
+	ch <- c.enrichErrors
+	ch <- c.enrichOverBudget
+	ch <- c.renderErrors
+	ch <- c.formatErrors
+	ch <- c.lostRecords
+	ch <- c.backlog
+	ch <- c.lastEvent
+	ch <- c.bookmarkLag
+	ch <- c.renderSeconds
+	ch <- c.deliverySeconds
+	ch <- c.remoteConnected
+	ch <- c.remoteRate
+	ch <- c.remoteLag
+	ch <- c.remoteLastContact
+	ch <- c.remoteFailures
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, watcher := range c.watchers {
+		c.collect(ch, watcher)
+	}
+}
+
+func (c *Collector) collect(ch chan<- prometheus.Metric, watcher *winlog.WinLogWatcher) {
+	host := watcher.RemoteHostStats()
+	var server string
+	if host != nil {
+		server = host.Server
+	}
+	for channel, stats := range watcher.Stats() {
+		counter := func(desc *prometheus.Desc, value uint64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), server, channel)
+		}
+		counter(c.events, stats.Delivered)
+		counter(c.filtered, stats.Filtered)
+		counter(c.dropped, stats.Dropped)
+		counter(c.expired, stats.Expired)
+		counter(c.enrichErrors, stats.EnrichErrors)
+		counter(c.enrichOverBudget, stats.EnrichOverBudget)
+		counter(c.renderErrors, stats.RenderErrors)
+		counter(c.formatErrors, stats.FormatErrors)
+		counter(c.lostRecords, stats.LostRecords)
+		ch <- prometheus.MustNewConstMetric(c.backlog, prometheus.GaugeValue, float64(stats.Backlog), server, channel)
+		if !stats.LastEventCreated.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastEvent, prometheus.GaugeValue,
+				float64(stats.LastEventCreated.UnixNano())/1e9, server, channel)
+		}
+		if stats.ChannelHeadRecordId > 0 {
+			ch <- prometheus.MustNewConstMetric(c.bookmarkLag, prometheus.GaugeValue, float64(stats.BookmarkLag), server, channel)
+		}
+		ch <- renderHistogram(c.renderSeconds, stats.RenderLatency, server, channel)
+		ch <- renderHistogram(c.deliverySeconds, stats.DeliveryLatency, server, channel)
+	}
+	if host != nil {
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, host.Server)
+		}
+		connected := 0.0
+		if host.State == winlog.RemoteConnected {
+			connected = 1
+		}
+		gauge(c.remoteConnected, connected)
+		gauge(c.remoteRate, host.EventsPerSecond)
+		gauge(c.remoteLag, float64(host.BookmarkLag))
+		if !host.LastContact.IsZero() {
+			gauge(c.remoteLastContact, float64(host.LastContact.UnixNano())/1e9)
+		}
+		gauge(c.remoteFailures, float64(host.ConsecutiveFailures))
+	}
+}
+
+func renderHistogram(desc *prometheus.Desc, h winlog.LatencyHistogram, server, channel string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Bounds))
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		buckets[bound.Seconds()] = cumulative
+	}
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum.Seconds(), buckets, server, channel)
+}
@@ -0,0 +1,139 @@
+package winlogprom
+
+import (
+	"strings"
+	. "testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+
+	winlog "github.com/huntresslabs/gowinlog"
+	"github.com/huntresslabs/gowinlog/testutil"
+)
+
+// histogramCollector collects a single rendered histogram.
+type histogramCollector struct {
+	desc      *prometheus.Desc
+	histogram winlog.LatencyHistogram
+}
+
+func (c histogramCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c histogramCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- renderHistogram(c.desc, c.histogram, "", "Application")
+}
+
+func newWatcher(t *T, fake *testutil.FakeEventLog) *winlog.WinLogWatcher {
+	watcher, err := winlog.NewWinLogWatcherWithAPI(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(watcher.Shutdown)
+	return watcher
+}
+
+// Emit events on `channel` and wait for the watcher to deliver them.
+func emit(t *T, fake *testutil.FakeEventLog, watcher *winlog.WinLogWatcher, channel string, count int) {
+	t.Helper()
+	go func() {
+		for i := 0; i < count; i++ {
+			fake.Emit(channel, &winlog.WinLogEvent{ProviderName: "Test", EventId: 1, Created: time.Now()})
+		}
+	}()
+	for i := 0; i < count; i++ {
+		select {
+		case <-watcher.Event():
+		case err := <-watcher.Error():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("No event published")
+		}
+	}
+}
+
+func TestRenderHistogramCumulative(t *T) {
+	c := histogramCollector{
+		desc: prometheus.NewDesc("gowinlog_render_seconds", "Time taken to render and format events.",
+			[]string{"server", "channel"}, nil),
+		histogram: winlog.LatencyHistogram{
+			Bounds: []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond},
+			Counts: []uint64{2, 3, 0, 1},
+			Sum:    1500 * time.Millisecond,
+			Count:  6,
+		},
+	}
+	expected := `
+# HELP gowinlog_render_seconds Time taken to render and format events.
+# TYPE gowinlog_render_seconds histogram
+gowinlog_render_seconds_bucket{channel="Application",server="",le="0.001"} 2
+gowinlog_render_seconds_bucket{channel="Application",server="",le="0.01"} 5
+gowinlog_render_seconds_bucket{channel="Application",server="",le="0.1"} 5
+gowinlog_render_seconds_bucket{channel="Application",server="",le="+Inf"} 6
+gowinlog_render_seconds_sum{channel="Application",server=""} 1.5
+gowinlog_render_seconds_count{channel="Application",server=""} 6
+`
+	if err := promtest.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorLocalWatcher(t *T) {
+	fake := testutil.NewFakeEventLog()
+	watcher := newWatcher(t, fake)
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	emit(t, fake, watcher, "Application", 2)
+
+	// Without BookmarkLagInterval the channel head is unknown, so there is
+	// no bookmark lag, and a local watcher has no remote host metrics
+	expected := `
+# HELP gowinlog_backlog Events received from the event log but not yet delivered.
+# TYPE gowinlog_backlog gauge
+gowinlog_backlog{channel="Application",server=""} 0
+# HELP gowinlog_events_total Events delivered to the consumer.
+# TYPE gowinlog_events_total counter
+gowinlog_events_total{channel="Application",server=""} 2
+`
+	c := NewCollector(watcher)
+	if err := promtest.CollectAndCompare(c, strings.NewReader(expected),
+		"gowinlog_backlog", "gowinlog_events_total", "gowinlog_bookmark_lag_records",
+		"gowinlog_remote_connected"); err != nil {
+		t.Fatal(err)
+	}
+	if count := promtest.CollectAndCount(c, "gowinlog_render_seconds", "gowinlog_delivery_latency_seconds"); count != 2 {
+		t.Fatalf("Expected a render and delivery histogram, got %v metrics", count)
+	}
+}
+
+func TestCollectorBookmarkLag(t *T) {
+	fake := testutil.NewFakeEventLog()
+	watcher := newWatcher(t, fake)
+	watcher.BookmarkLagInterval = 10 * time.Millisecond
+	// A record written before the subscription isn't delivered
+	fake.Emit("Application", &winlog.WinLogEvent{ProviderName: "Test", EventId: 1})
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	emit(t, fake, watcher, "Application", 2)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for watcher.Stats()["Application"].ChannelHeadRecordId != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("Channel head wasn't tracked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expected := `
+# HELP gowinlog_bookmark_lag_records Records between the bookmark and the newest record in the channel.
+# TYPE gowinlog_bookmark_lag_records gauge
+gowinlog_bookmark_lag_records{channel="Application",server=""} 0
+`
+	if err := promtest.CollectAndCompare(NewCollector(watcher), strings.NewReader(expected),
+		"gowinlog_bookmark_lag_records"); err != nil {
+		t.Fatal(err)
+	}
+}
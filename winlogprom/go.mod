@@ -0,0 +1,22 @@
+module github.com/huntresslabs/gowinlog/winlogprom
+
+go 1.17
+
+require (
+	github.com/huntresslabs/gowinlog v0.0.0
+	github.com/prometheus/client_golang v1.11.1
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.26.0 // indirect
+	github.com/prometheus/procfs v0.6.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	google.golang.org/protobuf v1.26.0-rc.1 // indirect
+)
+
+replace github.com/huntresslabs/gowinlog => ../
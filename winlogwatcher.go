@@ -1,11 +1,11 @@
-//go:build windows
-// +build windows
-
 // Winlog hooks into the Windows Event Log and streams events through channels
 package winlog
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,83 +21,248 @@ func (wlw *WinLogWatcher) Error() <-chan error {
 	return wlw.errChan
 }
 
-// NewWinLogWatcher creates a new watcher
+// NewWinLogWatcher creates a new watcher with the default options, which
+// renders none of the localized fields.
+//
+// Deprecated: Use NewWinLogWatcherWithOptions, which also configures the
+// watcher.
 func NewWinLogWatcher() (*WinLogWatcher, error) {
-	cHandle, err := GetSystemRenderContext()
+	return NewWinLogWatcherWithOptions(WatcherOptions{})
+}
+
+// NewWinLogWatcherWithAPI creates a watcher which makes its event log calls
+// through `api`, such as a fake for tests.
+func NewWinLogWatcherWithAPI(api EventLogAPI) (*WinLogWatcher, error) {
+	cHandle, err := api.CreateRenderContext()
 	if err != nil {
 		return nil, err
 	}
-	return &WinLogWatcher{
+	watcher := &WinLogWatcher{
 		shutdown:      make(chan interface{}),
 		errChan:       make(chan error),
 		eventChan:     make(chan *WinLogEvent),
+		notifyChan:    make(chan Notification, NotificationBufferSize),
+		api:           api,
 		renderContext: cHandle,
 		watches:       make(map[string]*channelWatcher),
-	}, nil
+	}
+	if remote, ok := api.(remoteStateNotifier); ok {
+		remote.addStateListener(watcher.remoteStateChanged)
+	}
+	return watcher, nil
 }
 
 // Subscribe to a Windows Event Log channel, starting with the first event
 // in the log. `query` is an XPath expression for filtering events: to recieve
-// all events on the channel, use "*" as the query.
+// all events on the channel, use "*" as the query. Queries with more than
+// MaxQueryExpressions expressions are split across several subscriptions
+// where possible. Events from all of them are published under `channel`, with
+// the bookmark of the subscription furthest behind, so resuming from it may
+// read some records again but skips none. Subscriptions which haven't read
+// an event yet are at the channel's oldest record.
 func (self *WinLogWatcher) SubscribeFromBeginning(channel, query string) error {
-	return self.subscribeWithoutBookmark(channel, query, EvtSubscribeStartAtOldestRecord)
+	return self.subscribeWithoutBookmark(channel, query, EvtSubscribeStartAtOldestRecord, time.Time{})
 }
 
 // Subscribe to a Windows Event Log channel, starting with the next event
 // that arrives. `query` is an XPath expression for filtering events: to recieve
 // all events on the channel, use "*" as the query.
 func (self *WinLogWatcher) SubscribeFromNow(channel, query string) error {
-	return self.subscribeWithoutBookmark(channel, query, EvtSubscribeToFutureEvents)
+	return self.subscribeWithoutBookmark(channel, query, EvtSubscribeToFutureEvents, time.Time{})
 }
 
-func (self *WinLogWatcher) subscribeWithoutBookmark(channel, query string, flags EVT_SUBSCRIBE_FLAGS) error {
+func (self *WinLogWatcher) subscribeWithoutBookmark(channel, query string, flags EVT_SUBSCRIBE_FLAGS, since time.Time) error {
 	self.watchMutex.Lock()
 	defer self.watchMutex.Unlock()
 	if _, ok := self.watches[channel]; ok {
 		return fmt.Errorf("A watcher for channel %q already exists", channel)
 	}
-	newBookmark, err := CreateBookmark()
+	newBookmark, err := self.api.CreateBookmark("")
 	if err != nil {
 		return fmt.Errorf("Failed to create new bookmark handle: %v", err)
 	}
-	callback := &LogEventCallbackWrapper{callback: self, subscribedChannel: channel}
-	subscription, err := CreateListener(channel, query, flags, callback)
+	splits, err := self.newSplitBookmarks(channel, query, flags, "")
 	if err != nil {
-		CloseEventHandle(uint64(newBookmark))
+		self.api.Close(uint64(newBookmark))
 		return err
 	}
+	pulls := &sync.WaitGroup{}
+	subscriptions, err := self.createListeners(channel, query, flags, newBookmark, since, nil, pulls)
+	if err != nil {
+		self.api.Close(uint64(newBookmark))
+		closeSplitBookmarks(self.api, splits)
+		self.log(LogError, "Failed to subscribe", "channel", channel, "query", query, "error", err)
+		return err
+	}
+	self.log(LogInfo, "Subscribed", "channel", channel, "flags", flags, "subscriptions", len(subscriptions))
+	self.notify(Notification{Kind: NotifySubscribed, Channel: channel, Message: fmt.Sprintf("Subscribed with %v", flags)})
+	self.startBookmarkLagTracking()
+	self.startStatsPersistence()
+	self.startSlowConsumerDetection()
+	self.startDropNotifications()
+	stats := self.newSubscriptionStats()
+	self.restoreStats(channel, stats)
 	self.watches[channel] = &channelWatcher{
-		bookmark:     newBookmark,
-		subscription: subscription,
-		callback:     callback,
+		bookmark:      newBookmark,
+		splits:        splits,
+		bookmarked:    splits != nil && splits[0].positioned,
+		subscriptions: subscriptions,
+		query:         query,
+		flags:         flags,
+		since:         since,
+		stats:         stats,
+		queue:         self.newQueue(stats),
+		workers:       newRenderPool(self.subscriptionWorkers(channel)),
+		pulls:         pulls,
+		filter:        self.subscriptionFilter(channel),
 	}
 	return nil
 }
 
+func (self *WinLogWatcher) startBookmarkLagTracking() {
+	if self.BookmarkLagInterval > 0 {
+		self.lagOnce.Do(func() { go self.trackBookmarkLag() })
+	}
+}
+
+// createListeners subscribes to `query`, split into several queries if it's
+// too large, closing any subscriptions already made if one of them fails.
+// Events created before `since`, if set, are suppressed. When resuming a
+// split query, `splits` holds the bookmarks of its subscriptions, and each
+// one with a position starts after its own bookmark. If the channel has
+// SubscriptionWorkers, the subscriptions are read by goroutines added to
+// `pulls` rather than by callbacks; they stop once the subscriptions are no
+// longer the channel's. Must be called with watchMutex held.
+func (self *WinLogWatcher) createListeners(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle, since time.Time, splits []splitBookmark, pulls *sync.WaitGroup) ([]ListenerHandle, error) {
+	queries := splitQuery(query)
+	subscriptions := make([]ListenerHandle, 0, len(queries))
+	puller, pull := self.api.(pullSubscriber)
+	pull = pull && self.subscriptionWorkers(channel) > 1
+	for i, query := range queries {
+		if !since.IsZero() {
+			query = sinceQuery(channel, query, since)
+		}
+		var callback LogEventCallback = self
+		if len(queries) > 1 {
+			callback = splitCallback{watcher: self, split: i}
+		}
+		flags, bookmark := flags, bookmark
+		if i < len(splits) && splits[i].positioned {
+			flags, bookmark = EvtSubscribeStartAfterBookmark, splits[i].bookmark
+		}
+		var subscription ListenerHandle
+		var err error
+		if pull {
+			subscription, err = puller.SubscribePull(channel, query, flags, bookmark)
+		} else {
+			subscription, err = self.api.Subscribe(channel, query, flags, bookmark, callback)
+		}
+		if err != nil {
+			for _, s := range subscriptions {
+				self.api.Cancel(uint64(s))
+				self.api.Close(uint64(s))
+			}
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if pull {
+		for i, subscription := range subscriptions {
+			split := i
+			if len(subscriptions) == 1 {
+				split = -1
+			}
+			pulls.Add(1)
+			go self.pullEvents(puller, pulls, channel, subscription, split)
+		}
+	}
+	return subscriptions, nil
+}
+
 // Subscribe to a Windows Event Log channel, starting with the first event in the log
 // after the bookmarked event. There may be a gap if events have been purged. `query`
 // is an XPath expression for filtering events: to recieve all events on the channel,
 // use "*" as the query
 func (self *WinLogWatcher) SubscribeFromBookmark(channel, query string, xmlString string) error {
+	return self.subscribeFromBookmark(channel, query, xmlString, time.Time{})
+}
+
+// subscribeFromBookmark subscribes after a bookmark, suppressing events
+// created before `since` if it's set, as when resuming a subscription made
+// with SubscribeFromTime.
+func (self *WinLogWatcher) subscribeFromBookmark(channel, query string, xmlString string, since time.Time) error {
 	self.watchMutex.Lock()
 	defer self.watchMutex.Unlock()
 	if _, ok := self.watches[channel]; ok {
 		return fmt.Errorf("A watcher for channel %q already exists", channel)
 	}
-	callback := &LogEventCallbackWrapper{callback: self, subscribedChannel: channel}
-	bookmark, err := CreateBookmarkFromXml(xmlString)
+	bookmark, err := self.api.CreateBookmark(xmlString)
 	if err != nil {
 		return fmt.Errorf("Failed to create new bookmark handle: %v", err)
 	}
-	subscription, err := CreateListenerFromBookmark(channel, query, callback, bookmark)
+	splits, err := self.newSplitBookmarks(channel, query, EvtSubscribeStartAfterBookmark, xmlString)
 	if err != nil {
-		CloseEventHandle(uint64(bookmark))
+		self.api.Close(uint64(bookmark))
+		return err
+	}
+	pulls := &sync.WaitGroup{}
+	subscriptions, err := self.createListeners(channel, query, EvtSubscribeStartAfterBookmark, bookmark, since, nil, pulls)
+	if err != nil {
+		self.api.Close(uint64(bookmark))
+		closeSplitBookmarks(self.api, splits)
+		self.log(LogError, "Failed to subscribe from bookmark", "channel", channel, "query", query, "error", err)
 		return fmt.Errorf("Failed to add listener: %v", err)
 	}
+	self.log(LogInfo, "Subscribed from bookmark", "channel", channel, "subscriptions", len(subscriptions))
+	self.notify(Notification{Kind: NotifySubscribed, Channel: channel, Message: "Subscribed from bookmark"})
+	self.startBookmarkLagTracking()
+	self.startStatsPersistence()
+	self.startSlowConsumerDetection()
+	self.startDropNotifications()
+	stats := self.newSubscriptionStats()
+	self.restoreStats(channel, stats)
 	self.watches[channel] = &channelWatcher{
-		bookmark:     bookmark,
-		subscription: subscription,
-		callback:     callback,
+		bookmark:      bookmark,
+		splits:        splits,
+		subscriptions: subscriptions,
+		query:         query,
+		flags:         EvtSubscribeStartAfterBookmark,
+		since:         since,
+		bookmarked:    splits == nil || splits[0].positioned,
+		stats:         stats,
+		queue:         self.newQueue(stats),
+		workers:       newRenderPool(self.subscriptionWorkers(channel)),
+		pulls:         pulls,
+		filter:        self.subscriptionFilter(channel),
+	}
+	return nil
+}
+
+// Set a filter applied only to events from the subscription on `channel`, in
+// addition to the watcher's Filter. Pass nil to remove it. Events the
+// subscription reads before it's set aren't filtered by it; to filter from
+// the first event, set it in SubscriptionFilters before subscribing.
+func (self *WinLogWatcher) SetSubscriptionFilter(channel string, filter EventFilter) error {
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	watch, ok := self.watches[channel]
+	if !ok {
+		return fmt.Errorf("No watcher for channel %q", channel)
+	}
+	watch.filter = filter
+	return nil
+}
+
+// subscriptionFilter returns the filter for `channel` in
+// SubscriptionFilters, matched case-insensitively, or nil if it has none.
+func (self *WinLogWatcher) subscriptionFilter(channel string) EventFilter {
+	if filter, ok := self.SubscriptionFilters[channel]; ok {
+		return filter
+	}
+	for name, filter := range self.SubscriptionFilters {
+		if strings.EqualFold(name, channel) {
+			return filter
+		}
 	}
 	return nil
 }
@@ -109,12 +274,36 @@ func (self *WinLogWatcher) RemoveSubscription(channel string) error {
 
 	var cancelErr, closeErr error
 	if watch, ok := self.watches[channel]; ok {
-		cancelErr = CancelEventHandle(uint64(watch.subscription))
-		closeErr = CloseEventHandle(uint64(watch.subscription))
-		CloseEventHandle(uint64(watch.bookmark))
+		for _, subscription := range watch.subscriptions {
+			if err := self.api.Cancel(uint64(subscription)); err != nil && cancelErr == nil {
+				cancelErr = err
+			}
+			if err := self.api.Close(uint64(subscription)); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+		self.api.Close(uint64(watch.bookmark))
+		closeSplitBookmarks(self.api, watch.splits)
+		// Closing the subscriptions waited for their callbacks, but not
+		// for the goroutines reading pull subscriptions, which stop once
+		// they see it's removed. Only then is nothing else sent to the
+		// queue, and Shutdown waits for them before closing Event.
+		self.drains.Add(1)
+		go func(watch *channelWatcher) {
+			defer self.drains.Done()
+			watch.pulls.Wait()
+			if watch.queue != nil {
+				close(watch.queue)
+			}
+		}(watch)
+		self.log(LogInfo, "Removed subscription", "channel", channel, "subscriptions", len(watch.subscriptions))
+		self.notify(Notification{Kind: NotifyUnsubscribed, Channel: channel, Message: "Removed subscription"})
 	}
 
 	delete(self.watches, channel)
+	if cancelErr != nil || closeErr != nil {
+		self.log(LogWarn, "Failed to close subscription", "channel", channel, "cancelError", cancelErr, "closeError", closeErr)
+	}
 	if cancelErr != nil {
 		return cancelErr
 	}
@@ -123,39 +312,72 @@ func (self *WinLogWatcher) RemoveSubscription(channel string) error {
 
 // Remove all subscriptions from this watcher and shut down.
 func (self *WinLogWatcher) Shutdown() {
+	self.log(LogInfo, "Shutting down", "subscriptions", len(self.watches))
 	close(self.shutdown)
+	self.saveStats()
 	for channel := range self.watches {
 		self.RemoveSubscription(channel)
 	}
-	CloseEventHandle(uint64(self.renderContext))
+	self.drains.Wait()
+	self.closeEnrichers()
+	self.api.Close(uint64(self.renderContext))
 	close(self.errChan)
 	close(self.eventChan)
+	self.closeNotifications()
 }
 
 /* Publish the received error to the errChan, but discard if shutdown is in progress */
 func (self *WinLogWatcher) PublishError(err error) {
+	self.recordError(err)
+	self.log(LogError, "Publishing error", "error", err)
 	select {
 	case self.errChan <- err:
 	case <-self.shutdown:
 	}
 }
 
+// RenderEvent converts an event handle from a query, such as QueryChannel or
+// QueryFile, using the watcher's Render settings. The handle isn't closed.
+func (self *WinLogWatcher) RenderEvent(handle EventHandle) (*WinLogEvent, error) {
+	return self.convertEvent(handle, "")
+}
+
 func (self *WinLogWatcher) convertEvent(handle EventHandle, subscribedChannel string) (*WinLogEvent, error) {
 	// Rendered values
 	var computerName, providerName, channel string
-	var level, task, opcode, recordId, qualifiers, eventId, processId, threadId, version uint64
+	var level, task, opcode, recordId, qualifiers, eventId, processId, threadId, version, keywords uint64
 	var created time.Time
 
 	// Localized fields
 	var keywordsText, msgText, lvlText, taskText, providerText, opcodeText, channelText, idText string
+	var localizedMsg, localizedLevelText map[string]string
+
+	// Parsed from the XML
+	var eventData, userData map[string]string
+	var dataNames []string
+	var eventDataErr error
+	var activityId, relatedActivityId, userSid string
+	var binary []byte
+	var binaryErr error
 
 	// Publisher fields
-	var publisherHandle PublisherHandle
 	var publisherHandleErr error
+	var formatErrors int
 
 	// Render the values
-	renderedFields, renderedFieldsErr := RenderEventValues(self.renderContext, handle)
-	xml, xmlErr := RenderEventXML(handle)
+	renderedFields, renderedFieldsErr := self.api.RenderValues(self.renderContext, handle)
+	xml, xmlErr := self.api.RenderXML(handle)
+	var parsed *eventDataXml
+	if xmlErr == nil {
+		if parsed, eventDataErr = parseEventXml(xml); eventDataErr == nil {
+			eventData = parsed.eventData()
+			userData = parsed.userData()
+			userSid = parsed.Security.UserID
+			binary, binaryErr = parsed.binary()
+			activityId = parsed.Correlation.ActivityID
+			relatedActivityId = parsed.Correlation.RelatedActivityID
+		}
+	}
 
 	if renderedFieldsErr == nil {
 		// If fields don't exist we include the nil value
@@ -171,46 +393,94 @@ func (self *WinLogWatcher) convertEvent(handle EventHandle, subscribedChannel st
 		processId, _ = renderedFields.Uint(EvtSystemProcessID)
 		threadId, _ = renderedFields.Uint(EvtSystemThreadID)
 		version, _ = renderedFields.Uint(EvtSystemVersion)
-		created, _ = renderedFields.FileTime(EvtSystemTimeCreated)
-
-		// Render localized fields
-		publisherHandle, publisherHandleErr = GetEventPublisherHandle(renderedFields)
-		if publisherHandleErr == nil {
+		keywords, _ = renderedFields.Uint(EvtSystemKeywords)
 
-			if self.RenderKeywords {
-				keywordsText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageKeyword)
+		if self.NameEventData && parsed != nil && parsed.unnamedData() {
+			names, err := self.templates.names(self.api, providerName, eventId, version)
+			if err != nil {
+				self.log(LogWarn, "Failed to read event templates", "provider", providerName, "error", err)
 			}
-
-			if self.RenderMessage {
-				msgText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageEvent)
+			if names != nil {
+				eventData = parsed.eventDataNamed(names)
+				dataNames = names
 			}
+		}
+		created, _ = renderedFields.FileTime(EvtSystemTimeCreated)
 
-			if self.RenderLevel {
-				lvlText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageLevel)
+		// Render localized fields, in the first of the Locales the
+		// provider has resources for. With publisher tables, the publisher
+		// metadata is only opened if a value isn't in the tables.
+		locales := self.Locales
+		if len(locales) == 0 {
+			locales = defaultLocales
+		}
+		publishers := newEventPublishers(self.api, providerName)
+		if !self.UsePublisherTables {
+			publishers.open(locales[0])
+		}
+		format := func(flags EVT_FORMAT_MESSAGE_FLAGS) string {
+			return publishers.format(handle, flags, locales)
+		}
+		// Formats a level, task, opcode or keywords value, looking it up in
+		// the publisher tables first if they're used
+		formatValue := func(flags EVT_FORMAT_MESSAGE_FLAGS, value uint64) string {
+			if !self.UsePublisherTables {
+				return format(flags)
 			}
-
-			if self.RenderTask {
-				taskText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageTask)
+			text, err := self.publisherTables.text(self.api, providerName, flags, task, value, func() (string, bool) {
+				failed := publishers.formatErrors
+				text := format(flags)
+				return text, publishers.formatErrors == failed
+			})
+			if err != nil {
+				self.log(LogWarn, "Failed to read publisher tables", "provider", providerName, "error", err)
 			}
+			return text
+		}
 
-			if self.RenderProvider {
-				providerText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageProvider)
-			}
+		if self.RenderKeywords {
+			keywordsText = formatValue(EvtFormatMessageKeyword, keywords)
+		}
 
-			if self.RenderOpcode {
-				opcodeText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageOpcode)
-			}
+		if self.RenderMessage {
+			msgText = format(EvtFormatMessageEvent)
+			localizedMsg = publishers.formatEach(handle, EvtFormatMessageEvent, self.RenderLocales)
+		}
 
-			if self.RenderChannel {
-				channelText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageChannel)
-			}
+		if self.RenderLevel {
+			lvlText = formatValue(EvtFormatMessageLevel, level)
+			localizedLevelText = publishers.formatEach(handle, EvtFormatMessageLevel, self.RenderLocales)
+		}
 
-			if self.RenderId {
-				idText, _ = FormatMessage(publisherHandle, handle, EvtFormatMessageId)
-			}
+		if self.RenderTask {
+			taskText = formatValue(EvtFormatMessageTask, task)
+		}
 
-			CloseEventHandle(uint64(publisherHandle))
+		if self.RenderProvider {
+			providerText = format(EvtFormatMessageProvider)
 		}
+
+		if self.RenderOpcode {
+			opcodeText = formatValue(EvtFormatMessageOpcode, opcode)
+		}
+
+		if self.RenderChannel {
+			channelText = format(EvtFormatMessageChannel)
+		}
+
+		if self.RenderId {
+			idText = format(EvtFormatMessageId)
+		}
+
+		publishers.close()
+		publisherHandleErr = publishers.openErr()
+		formatErrors = publishers.formatErrors
+	}
+
+	var raw *RenderedEvent
+	var rawErr error
+	if self.RetainRawRender {
+		raw, rawErr = self.api.RenderRaw(self.renderContext, handle)
 	}
 
 	event := WinLogEvent{
@@ -229,11 +499,19 @@ func (self *WinLogWatcher) convertEvent(handle EventHandle, subscribedChannel st
 		Channel:           channel,
 		ComputerName:      computerName,
 		Version:           version,
+		KeywordsMask:      keywords,
 		RenderedFieldsErr: renderedFieldsErr,
 
+		ActivityId:        activityId,
+		RelatedActivityId: relatedActivityId,
+		UserSid:           userSid,
+		Outcome:           DeriveOutcome(keywords, level),
+
 		Keywords:           keywordsText,
 		Msg:                msgText,
 		LevelText:          lvlText,
+		LocalizedMsg:       localizedMsg,
+		LocalizedLevelText: localizedLevelText,
 		TaskText:           taskText,
 		OpcodeText:         opcodeText,
 		ChannelText:        channelText,
@@ -241,46 +519,323 @@ func (self *WinLogWatcher) convertEvent(handle EventHandle, subscribedChannel st
 		IdText:             idText,
 		PublisherHandleErr: publisherHandleErr,
 
+		EventData:    eventData,
+		EventDataErr: eventDataErr,
+		dataNames:    dataNames,
+		UserData:     userData,
+		Binary:       binary,
+		BinaryErr:    binaryErr,
+
+		Raw:    raw,
+		RawErr: rawErr,
+
 		SubscribedChannel: subscribedChannel,
+
+		formatErrors: formatErrors,
+	}
+	if subscribedChannel != "" && self.isCollectorChannel(subscribedChannel) {
+		event.markForwarded(self.collectorName(), time.Now())
+	}
+	event.Host = self.host()
+	if remote, ok := self.api.(remoteSourcer); ok {
+		event.Remote = remote.RemoteSource()
+	}
+	event.Severity = self.SeverityMap.Severity(&event)
+	event.addBinaryData(self.BinaryFormat, self.MaxBinarySize)
+	if self.Extractor != nil {
+		if err := self.Extractor.Extract(&event); err != nil {
+			return nil, fmt.Errorf("Failed to extract fields: %v", err)
+		}
+	}
+	if self.Truncation != nil {
+		self.Truncation.Apply(&event)
 	}
 	return &event, nil
 }
 
 /* Publish a new event */
 func (self *WinLogWatcher) PublishEvent(handle EventHandle, subscribedChannel string) {
+	self.publishEvent(handle, subscribedChannel, -1)
+}
 
-	// Convert the event from the event log schema
-	event, err := self.convertEvent(handle, subscribedChannel)
-	if err != nil {
-		self.PublishError(err)
-		return
-	}
+// publishEvent publishes an event from a subscription, or from one of the
+// subscriptions of a split query if `split` isn't negative.
+func (self *WinLogWatcher) publishEvent(handle EventHandle, subscribedChannel string, split int) {
 
 	// Get the bookmark for the channel
 	self.watchMutex.Lock()
 	watch, ok := self.watches[subscribedChannel]
-	self.watchMutex.Unlock()
 	if !ok {
-		self.errChan <- fmt.Errorf("No handle for channel bookmark %q", subscribedChannel)
+		self.watchMutex.Unlock()
+		self.PublishError(fmt.Errorf("No handle for channel bookmark %q", subscribedChannel))
+		return
+	}
+	ev := self.admitEvent(watch, handle, subscribedChannel, split)
+	if ev == nil {
 		return
 	}
+	defer atomic.AddInt64(&watch.stats.backlog, -1)
+	self.renderAdmitted(ev)
+	self.publishRendered(ev)
+}
 
-	// Update the bookmark with the current event
-	UpdateBookmark(watch.bookmark, handle)
+// admittedEvent is an event a subscription has read and not paused or
+// dropped for its rate limit, on its way to being rendered and published.
+type admittedEvent struct {
+	handle            EventHandle
+	subscribedChannel string
+	split             int
+	watch             *channelWatcher
+	filter            EventFilter
+	queue             chan *WinLogEvent
+	workers           renderPool
+	// Events from split queries interleave, and forwarded events carry
+	// the RecordIds of their source channels
+	ordered  bool
+	complete bool
+
+	event *WinLogEvent
+	err   error
+	start time.Time
+}
+
+// ordered reports whether a subscription reads the records of one channel
+// in RecordId order. Events from split queries interleave, and forwarded
+// events carry the RecordIds of their source channels. Must be called with
+// watchMutex held.
+func (self *WinLogWatcher) ordered(subscribedChannel string, watch *channelWatcher) bool {
+	return len(watch.subscriptions) == 1 && !self.isCollectorChannel(subscribedChannel)
+}
+
+// admitEvent applies the subscription's rate limit to an event, returning
+// nil if it's skipped. Admitted events are counted in the subscription's
+// backlog until they've been published. Must be called with watchMutex
+// held, which it releases.
+func (self *WinLogWatcher) admitEvent(watch *channelWatcher, handle EventHandle, subscribedChannel string, split int) *admittedEvent {
+	ev := &admittedEvent{
+		handle:            handle,
+		subscribedChannel: subscribedChannel,
+		split:             split,
+		watch:             watch,
+		filter:            watch.filter,
+		queue:             watch.queue,
+		workers:           watch.workers,
+	}
+	stats := watch.stats
+	ev.ordered = self.ordered(subscribedChannel, watch)
+	ev.complete = ev.ordered && (watch.query == "*" || watch.query == "")
+	if ev.workers == nil {
+		ev.workers = self.sharedRenderPool()
+	}
+
+	// Rate limit before doing any rendering work. While paused, events are
+	// skipped without updating the bookmark so they are redelivered when
+	// the subscription resumes.
+	if watch.paused {
+		self.watchMutex.Unlock()
+		return nil
+	}
+	if watch.bucket != nil {
+		if allowed, wait := watch.bucket.take(time.Now()); !allowed {
+			if watch.rateLimit.Policy == RateLimitPause {
+				self.pauseSubscription(subscribedChannel, watch, split, handle, wait)
+				self.watchMutex.Unlock()
+				return nil
+			}
+			self.watchMutex.Unlock()
+			atomic.AddUint64(&stats.dropped, 1)
+			atomic.AddUint64(&stats.skipped, 1)
+			self.skipSequence()
+			// The RecordIds of split queries' events aren't known until
+			// they're rendered, so their bookmarks are left behind
+			if watch.splits == nil {
+				watch.bookmarkMutex.Lock()
+				self.api.UpdateBookmark(watch.bookmark, handle)
+				watch.bookmarked = true
+				watch.bookmarkMutex.Unlock()
+			}
+			return nil
+		}
+	}
+	atomic.AddInt64(&stats.backlog, 1)
+	readSplit(watch, split)
+	self.watchMutex.Unlock()
+	return ev
+}
+
+// renderAdmitted converts an admitted event from the event log schema,
+// holding one of its subscription's render workers. It's safe to call for
+// several events at once; they're published in order afterwards.
+func (self *WinLogWatcher) renderAdmitted(ev *admittedEvent) {
+	ev.workers.acquire()
+	ev.start = time.Now()
+	ev.event, ev.err = self.convertEvent(ev.handle, ev.subscribedChannel)
+	ev.workers.release()
+}
+
+// publishRendered advances the subscription's bookmark past a rendered
+// event, checks for gaps and cleared logs, and publishes it unless it's
+// filtered. Events must be published in the order their subscription read
+// them.
+func (self *WinLogWatcher) publishRendered(ev *admittedEvent) {
+	watch, subscribedChannel, stats := ev.watch, ev.subscribedChannel, ev.watch.stats
+	if ev.err != nil {
+		settleSplit(watch, ev.split)
+		self.PublishError(ev.err)
+		return
+	}
+	event, start := ev.event, ev.start
+	event.ReceivedAt = start
+	stats.recordRender(event, time.Since(start))
+	stats.recordSource(event, time.Now())
+	self.checkClockSkew(subscribedChannel, stats, event, start)
+	if event.RenderedFieldsErr != nil || event.XmlErr != nil {
+		self.log(LogDebug, "Failed to render event", "channel", subscribedChannel, "valuesError", event.RenderedFieldsErr, "xmlError", event.XmlErr)
+	}
 
-	// Serialize the boomark as XML and include it in the event
-	bookmarkXml, err := RenderBookmark(watch.bookmark)
+	// Update the bookmark with the current event. This is done even for
+	// filtered events so the next published bookmark skips past them. It's
+	// serialized in the same critical section, so another subscription
+	// sharing the bookmark can't move it before it's included in the event.
+	watch.bookmarkMutex.Lock()
+	if err := self.advanceBookmark(watch, ev.split, ev.handle, event.RecordId); err != nil {
+		self.log(LogWarn, "Failed to update bookmark", "channel", subscribedChannel, "error", err)
+	}
+	settleSplit(watch, ev.split)
+	bookmarkXml, err := self.renderBookmark(watch)
+	if err == nil && bookmarkXml != "" {
+		watch.lastBookmark = bookmarkXml
+	}
+	watch.bookmarkMutex.Unlock()
 	if err != nil {
 		self.PublishError(fmt.Errorf("Error rendering bookmark for event - %v", err))
 		return
 	}
+	previous, skipped := stats.recordPosition(event.RecordId)
+	self.checkCleared(subscribedChannel, event, previous, ev.ordered)
+	self.checkGap(subscribedChannel, stats, event, previous, skipped, ev.complete)
+
+	if (self.Filter != nil && !self.Filter.Match(event)) ||
+		(ev.filter != nil && !ev.filter.Match(event)) ||
+		(self.Sampler != nil && !self.Sampler.Sample(event)) {
+		atomic.AddUint64(&stats.filtered, 1)
+		return
+	}
+
+	// Redact before the event goes anywhere else. If redaction fails the
+	// event is dropped rather than risk publishing sensitive values.
+	if self.Redactor != nil {
+		if err := self.Redactor.Redact(event); err != nil {
+			self.PublishError(fmt.Errorf("Failed to redact event: %v", err))
+			return
+		}
+	}
+
+	self.enrich(event, stats)
+
 	event.Bookmark = bookmarkXml
 
+	if self.Deduplicator != nil {
+		self.dedupeOnce.Do(func() { go self.flushDuplicates() })
+		if !self.Deduplicator.Admit(event, time.Now()) {
+			atomic.AddUint64(&stats.filtered, 1)
+			return
+		}
+	}
+	if self.LoadShedder != nil && self.LoadShedder.shed(event, time.Now()) {
+		atomic.AddUint64(&stats.dropped, 1)
+		self.skipSequence()
+		return
+	}
+	if self.Reorderer != nil {
+		self.reorderOnce.Do(func() { go self.flushReordered() })
+		self.deliverPending(self.Reorderer.add(event, stats, time.Now()))
+		return
+	}
+	if ev.queue != nil {
+		atomic.AddInt64(&stats.backlog, 1)
+		select {
+		case ev.queue <- event:
+		case <-self.shutdown:
+			atomic.AddInt64(&stats.backlog, -1)
+		}
+		return
+	}
+	if self.deliver(event, stats) {
+		stats.recordDelivered(event, time.Now())
+	}
+}
+
+// Use a sequence number for a dropped event, leaving a gap in the sequence.
+func (self *WinLogWatcher) skipSequence() {
+	atomic.AddUint64(&self.sequence, 1)
+}
+
+// Send the event to the consumer, recording how long it took for load
+// shedding. Returns false if the watcher was shut down first, or the event
+// expired, which is counted in `stats` if it isn't nil.
+func (self *WinLogWatcher) deliver(event *WinLogEvent, stats *subscriptionStats) bool {
+	self.deliverMutex.Lock()
+	defer self.deliverMutex.Unlock()
+	deadline := self.Expiry.deadline(event)
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		self.skipSequence()
+		self.expire(event, stats)
+		return false
+	}
+	event.Sequence = atomic.AddUint64(&self.sequence, 1)
+	if self.HashChain != nil {
+		if err := self.HashChain.Link(event); err != nil {
+			self.PublishError(fmt.Errorf("Failed to hash event: %v", err))
+		}
+	}
+
+	select {
+	case self.eventChan <- event:
+		now := time.Now()
+		atomic.StoreInt64(&self.lastRead, now.UnixNano())
+		if self.LoadShedder != nil {
+			self.LoadShedder.observe(0, now)
+		}
+		return true
+	default:
+	}
+
 	// Don't block when shutting down if the consumer has gone away
+	start := time.Now()
+	received := event.ReceivedAt
+	if received.IsZero() {
+		received = start
+	}
+	atomic.StoreInt64(&self.pendingSince, received.UnixNano())
+	atomic.StoreInt64(&self.offeredAt, start.UnixNano())
+	defer func() {
+		atomic.StoreInt64(&self.offeredAt, 0)
+		atomic.StoreInt64(&self.pendingSince, 0)
+	}()
+	var expiry <-chan time.Time
+	if !deadline.IsZero() && self.HashChain == nil {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expiry = timer.C
+	}
 	select {
 	case self.eventChan <- event:
+		now := time.Now()
+		atomic.StoreInt64(&self.lastRead, now.UnixNano())
+		if self.LoadShedder != nil {
+			self.LoadShedder.observe(now.Sub(start), now)
+		}
+		return true
+	case <-expiry:
+		self.expire(event, stats)
+		return false
 	case <-self.shutdown:
-		return
+		// Nobody received the event, so a chain continued from Last after
+		// a restart mustn't include it
+		if self.HashChain != nil {
+			self.HashChain.unlink(event)
+		}
+		return false
 	}
-
 }
@@ -0,0 +1,208 @@
+package winlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// renderPool limits how many events are rendered and formatted at once by
+// the callbacks and goroutines holding it. It runs nothing itself. A nil
+// pool doesn't limit them.
+type renderPool chan struct{}
+
+func newRenderPool(workers int) renderPool {
+	if workers <= 0 {
+		return nil
+	}
+	return make(renderPool, workers)
+}
+
+func (p renderPool) acquire() {
+	if p != nil {
+		p <- struct{}{}
+	}
+}
+
+func (p renderPool) release() {
+	if p != nil {
+		<-p
+	}
+}
+
+// sharedRenderPool is the pool of RenderWorkers used by subscriptions
+// without their own, created the first time it's needed.
+func (self *WinLogWatcher) sharedRenderPool() renderPool {
+	self.workersOnce.Do(func() {
+		self.sharedWorkers = newRenderPool(self.RenderWorkers)
+	})
+	return self.sharedWorkers
+}
+
+// subscriptionWorkers returns the workers configured for `channel` in
+// SubscriptionWorkers, matched case-insensitively, or 0 if it has none.
+func (self *WinLogWatcher) subscriptionWorkers(channel string) int {
+	if workers, ok := self.SubscriptionWorkers[channel]; ok {
+		return workers
+	}
+	for name, workers := range self.SubscriptionWorkers {
+		if strings.EqualFold(name, channel) {
+			return workers
+		}
+	}
+	return 0
+}
+
+// SetSubscriptionWorkers gives the subscription on `channel` its own cap of
+// `workers` events rendered at once, or returns it to the watcher's shared
+// RenderWorkers if `workers` is 0. Events being rendered keep the cap they
+// started with. A subscription made with more than one of SubscriptionWorkers
+// reads batches of the new size from then on; one made with a callback keeps
+// rendering one event at a time until it's subscribed again.
+func (self *WinLogWatcher) SetSubscriptionWorkers(channel string, workers int) error {
+	if workers < 0 {
+		return fmt.Errorf("Invalid number of workers %v", workers)
+	}
+	self.watchMutex.Lock()
+	defer self.watchMutex.Unlock()
+	watch, ok := self.watches[channel]
+	if !ok {
+		return fmt.Errorf("No watcher for channel %q", channel)
+	}
+	watch.workers = newRenderPool(workers)
+	return nil
+}
+
+// pullSubscriber is implemented by EventLogAPIs which can subscribe without
+// a callback, such as SystemEventLogAPI and RemoteAPI. An event log callback
+// is passed one event at a time, whose handle is only valid until it
+// returns, so events can't be rendered on other goroutines. Events read from
+// a pull subscription with NextEvents stay valid until they're closed, so
+// subscriptions with SubscriptionWorkers use them to render several at once.
+type pullSubscriber interface {
+	// Subscribe like Subscribe, but read events with
+	// NextEvents(QueryHandle(subscription), ...), which returns io.EOF
+	// when there are none to read yet
+	SubscribePull(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle) (ListenerHandle, error)
+	// Wait up to `timeout` for a pull subscription to have events to read,
+	// returning io.EOF once it's closed
+	WaitEvents(subscription ListenerHandle, timeout time.Duration) error
+}
+
+// How long a pull subscription waits for events before checking it's still
+// the channel's
+const pullWait = time.Second
+
+// pullEvents reads a pull subscription's events in batches of as many as it
+// has render workers, renders each batch at once, and publishes them in the
+// order they were read, so bookmarks, gaps and cleared logs are tracked as
+// they would be from a callback. It stops once the subscription is no longer
+// the channel's, having been removed, paused or replaced.
+func (self *WinLogWatcher) pullEvents(api pullSubscriber, pulls *sync.WaitGroup, channel string, subscription ListenerHandle, split int) {
+	defer pulls.Done()
+	var handles []EventHandle
+	for {
+		self.watchMutex.Lock()
+		watch := self.currentPull(channel, subscription)
+		var workers renderPool
+		if watch != nil {
+			if workers = watch.workers; workers == nil {
+				workers = self.sharedRenderPool()
+			}
+		}
+		self.watchMutex.Unlock()
+		if watch == nil {
+			return
+		}
+		batch := cap(workers)
+		if batch == 0 {
+			batch = 1
+		}
+		if len(handles) != batch {
+			handles = make([]EventHandle, batch)
+		}
+
+		returned, err := self.api.NextEvents(QueryHandle(subscription), handles, 0)
+		if err == io.EOF {
+			if err := api.WaitEvents(subscription, pullWait); err != nil && err != io.EOF {
+				self.publishPullError(channel, subscription, err)
+				return
+			}
+			continue
+		} else if err != nil {
+			self.publishPullError(channel, subscription, err)
+			return
+		}
+		self.publishEvents(handles[:returned], channel, subscription, split)
+		for _, handle := range handles[:returned] {
+			self.api.Close(uint64(handle))
+		}
+	}
+}
+
+// currentPull returns the watch of `channel` if `subscription` is still one
+// of its subscriptions, or nil. Must be called with watchMutex held.
+func (self *WinLogWatcher) currentPull(channel string, subscription ListenerHandle) *channelWatcher {
+	select {
+	case <-self.shutdown:
+		return nil
+	default:
+	}
+	watch, ok := self.watches[channel]
+	if !ok {
+		return nil
+	}
+	for _, s := range watch.subscriptions {
+		if s == subscription {
+			return watch
+		}
+	}
+	return nil
+}
+
+// publishPullError publishes an error reading a pull subscription, unless
+// it's because the subscription was closed.
+func (self *WinLogWatcher) publishPullError(channel string, subscription ListenerHandle, err error) {
+	self.watchMutex.Lock()
+	current := self.currentPull(channel, subscription) != nil
+	self.watchMutex.Unlock()
+	if current {
+		self.PublishError(fmt.Errorf("Failed to read events from %q: %v", channel, err))
+	}
+}
+
+// publishEvents publishes a batch of events read from a pull subscription,
+// rendering them at once and then publishing them in order.
+func (self *WinLogWatcher) publishEvents(handles []EventHandle, channel string, subscription ListenerHandle, split int) {
+	admitted := make([]*admittedEvent, 0, len(handles))
+	for _, handle := range handles {
+		self.watchMutex.Lock()
+		watch := self.currentPull(channel, subscription)
+		if watch == nil {
+			// Paused by the rate limit, or removed
+			self.watchMutex.Unlock()
+			break
+		}
+		if ev := self.admitEvent(watch, handle, channel, split); ev != nil {
+			admitted = append(admitted, ev)
+		}
+	}
+
+	var rendering sync.WaitGroup
+	for _, ev := range admitted {
+		rendering.Add(1)
+		go func(ev *admittedEvent) {
+			defer rendering.Done()
+			self.renderAdmitted(ev)
+		}(ev)
+	}
+	rendering.Wait()
+
+	for _, ev := range admitted {
+		self.publishRendered(ev)
+		atomic.AddInt64(&ev.watch.stats.backlog, -1)
+	}
+}
@@ -0,0 +1,268 @@
+package winlog
+
+import (
+	"fmt"
+	"io"
+	. "testing"
+	"time"
+)
+
+func TestRenderPoolLimitsWorkers(t *T) {
+	pool := newRenderPool(2)
+	pool.acquire()
+	pool.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		pool.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquired a third worker")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pool.release()
+	<-acquired
+
+	// A nil pool is unlimited
+	var unlimited renderPool
+	unlimited.acquire()
+	unlimited.release()
+}
+
+func TestSubscriptionWorkers(t *T) {
+	api := newFakeAPI()
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{
+		API:                 api,
+		RenderWorkers:       1,
+		SubscriptionWorkers: map[string]int{"security": 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	for _, channel := range []string{"Security", "Application"} {
+		if err := watcher.SubscribeFromNow(channel, "*"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(cap(watcher.watches["Security"].workers), 4, t)
+	assertEqual(watcher.watches["Application"].workers == nil, true, t)
+	assertEqual(cap(watcher.sharedRenderPool()), 1, t)
+
+	if err := watcher.SetSubscriptionWorkers("Application", 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(cap(watcher.watches["Application"].workers), 2, t)
+	if err := watcher.SetSubscriptionWorkers("Security", 0); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(watcher.watches["Security"].workers == nil, true, t)
+	if err := watcher.SetSubscriptionWorkers("System", 1); err == nil {
+		t.Fatal("Expected an error for a channel without a subscription")
+	}
+
+	// Events from the shared pool are still rendered and delivered
+	go api.emit("Security", fakeValues{
+		EvtSystemProviderName:  "Provider",
+		EvtSystemChannel:       "Security",
+		EvtSystemEventRecordId: uint64(1),
+	})
+	select {
+	case ev := <-watcher.Event():
+		assertEqual(ev.RecordId, uint64(1), t)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
+
+// pullAPI is a fakeAPI with pull subscriptions, which takes `delay` to
+// render each event and records how many were rendered at once.
+type pullAPI struct {
+	*fakeAPI
+	delay   func(recordId uint64) time.Duration
+	pending map[ListenerHandle][]fakeValues
+	signals map[ListenerHandle]chan struct{}
+
+	rendering    int
+	maxRendering int
+}
+
+func newPullAPI() *pullAPI {
+	return &pullAPI{
+		fakeAPI: newFakeAPI(),
+		delay:   func(uint64) time.Duration { return 0 },
+		pending: make(map[ListenerHandle][]fakeValues),
+		signals: make(map[ListenerHandle]chan struct{}),
+	}
+}
+
+// emitPull queues an event for every open pull subscription on the channel.
+func (p *pullAPI) emitPull(channel string, values fakeValues) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for subscription, signal := range p.signals {
+		if p.channels[uint64(subscription)] == channel {
+			p.pending[subscription] = append(p.pending[subscription], values)
+			select {
+			case signal <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (p *pullAPI) SubscribePull(channel, query string, flags EVT_SUBSCRIBE_FLAGS, bookmark BookmarkHandle) (ListenerHandle, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	handle := ListenerHandle(p.handle())
+	p.channels[uint64(handle)] = channel
+	p.queries[uint64(handle)] = query
+	p.flags[uint64(handle)] = flags
+	p.signals[handle] = make(chan struct{}, 1)
+	return handle, nil
+}
+
+func (p *pullAPI) NextEvents(query QueryHandle, events []EventHandle, timeout time.Duration) (int, error) {
+	p.mutex.Lock()
+	if _, ok := p.signals[ListenerHandle(query)]; !ok {
+		p.mutex.Unlock()
+		if p.closed[uint64(query)] {
+			return 0, fmt.Errorf("Invalid subscription handle %v", query)
+		}
+		return p.fakeAPI.NextEvents(query, events, timeout)
+	}
+	defer p.mutex.Unlock()
+	pending := p.pending[ListenerHandle(query)]
+	if len(pending) == 0 {
+		return 0, io.EOF
+	}
+	n := len(events)
+	if len(pending) < n {
+		n = len(pending)
+	}
+	for i, values := range pending[:n] {
+		handle := p.handle()
+		p.events[handle] = values
+		events[i] = EventHandle(handle)
+	}
+	p.pending[ListenerHandle(query)] = pending[n:]
+	return n, nil
+}
+
+func (p *pullAPI) WaitEvents(subscription ListenerHandle, timeout time.Duration) error {
+	p.mutex.Lock()
+	signal, ok := p.signals[subscription]
+	p.mutex.Unlock()
+	if !ok {
+		return io.EOF
+	}
+	select {
+	case _, ok := <-signal:
+		if !ok {
+			return io.EOF
+		}
+	case <-time.After(timeout):
+	}
+	return nil
+}
+
+func (p *pullAPI) RenderValues(renderContext SysRenderContext, event EventHandle) (RenderedValues, error) {
+	p.mutex.Lock()
+	if p.closed[uint64(event)] {
+		p.mutex.Unlock()
+		return nil, fmt.Errorf("Event handle %v was closed", event)
+	}
+	values := p.events[uint64(event)]
+	p.rendering++
+	if p.rendering > p.maxRendering {
+		p.maxRendering = p.rendering
+	}
+	p.mutex.Unlock()
+
+	recordId, _ := values.Uint(EvtSystemEventRecordId)
+	time.Sleep(p.delay(recordId))
+
+	p.mutex.Lock()
+	p.rendering--
+	p.mutex.Unlock()
+	return values, nil
+}
+
+func (p *pullAPI) Close(handle uint64) error {
+	p.mutex.Lock()
+	if signal, ok := p.signals[ListenerHandle(handle)]; ok {
+		close(signal)
+		delete(p.signals, ListenerHandle(handle))
+	}
+	p.mutex.Unlock()
+	return p.fakeAPI.Close(handle)
+}
+
+func TestSubscriptionWorkersRenderInParallel(t *T) {
+	api := newPullAPI()
+	// Later events render faster, so they finish first
+	api.delay = func(recordId uint64) time.Duration { return time.Duration(9-recordId) * 5 * time.Millisecond }
+	watcher, err := NewWinLogWatcherWithOptions(WatcherOptions{
+		API:                 api,
+		SubscriptionWorkers: map[string]int{"Security": 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Shutdown()
+	if err := watcher.SubscribeFromNow("Security", "*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.SubscribeFromNow("Application", "*"); err != nil {
+		t.Fatal(err)
+	}
+	// Only the channel with its own workers is read without a callback
+	api.mutex.Lock()
+	assertEqual(len(api.signals), 1, t)
+	assertEqual(len(api.callbacks), 1, t)
+	api.mutex.Unlock()
+
+	for recordId := uint64(1); recordId <= 8; recordId++ {
+		api.emitPull("Security", fakeValues{
+			EvtSystemProviderName:  "Microsoft-Windows-Security-Auditing",
+			EvtSystemChannel:       "Security",
+			EvtSystemEventRecordId: recordId,
+		})
+	}
+	// Published in the order they were read, each with its own bookmark
+	for recordId := uint64(1); recordId <= 8; recordId++ {
+		select {
+		case ev := <-watcher.Event():
+			assertEqual(ev.RecordId, recordId, t)
+			assertEqual(ev.Bookmark, fmt.Sprint(recordId), t)
+		case err := <-watcher.Error():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for event")
+		}
+	}
+	api.mutex.Lock()
+	maxRendering := api.maxRendering
+	api.mutex.Unlock()
+	if maxRendering < 2 || maxRendering > 4 {
+		t.Fatalf("Expected 2 to 4 events rendered at once, got %v", maxRendering)
+	}
+	stats := watcher.Stats()["Security"]
+	assertEqual(stats.LostRecords, uint64(0), t)
+
+	// Removing the subscription stops reading it
+	if err := watcher.RemoveSubscription("Security"); err != nil {
+		t.Fatal(err)
+	}
+	api.emitPull("Security", fakeValues{EvtSystemEventRecordId: uint64(9)})
+	select {
+	case ev := <-watcher.Event():
+		t.Fatalf("Unexpected event %+v", ev)
+	case err := <-watcher.Error():
+		t.Fatal(err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,57 @@
+package winlog
+
+// Types of event written by EventSource.Report
+const (
+	EventTypeSuccess      = 0
+	EventTypeError        = 1
+	EventTypeWarning      = 2
+	EventTypeInformation  = 4
+	EventTypeAuditSuccess = 8
+	EventTypeAuditFailure = 16
+)
+
+// Message file for RegisterEventSource which renders the first insertion
+// string of events 1 to 1000.
+const DefaultEventMessageFile = `%SystemRoot%\System32\EventCreate.exe`
+
+// EventSource writes events to a classic event log, usually Application,
+// with ReportEventW. Messages are only rendered cleanly if the source is
+// registered with a message file; see RegisterEventSource.
+type EventSource struct {
+	Name   string
+	handle uintptr
+}
+
+// Info writes an information event with the given insertion strings.
+func (s *EventSource) Info(eventId uint32, strings ...string) error {
+	return s.Report(EventTypeInformation, 0, eventId, strings...)
+}
+
+// Warning writes a warning event with the given insertion strings.
+func (s *EventSource) Warning(eventId uint32, strings ...string) error {
+	return s.Report(EventTypeWarning, 0, eventId, strings...)
+}
+
+// Error writes an error event with the given insertion strings.
+func (s *EventSource) Error(eventId uint32, strings ...string) error {
+	return s.Report(EventTypeError, 0, eventId, strings...)
+}
+
+// EventDescriptor identifies an event defined in a provider's
+// instrumentation manifest. It has the layout of EVENT_DESCRIPTOR.
+type EventDescriptor struct {
+	Id      uint16
+	Version uint8
+	Channel uint8
+	// One of the Level constants
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+// Provider writes manifest-based events with EventWrite.
+type Provider struct {
+	Guid   string
+	handle uint64
+}
@@ -0,0 +1,137 @@
+package winlog
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+/* Interop code for writing events with advapi32.dll */
+
+var (
+	advapi32        = windows.NewLazySystemDLL("advapi32.dll")
+	eventRegister   = advapi32.NewProc("EventRegister")
+	eventUnregister = advapi32.NewProc("EventUnregister")
+	eventWrite      = advapi32.NewProc("EventWrite")
+)
+
+// OpenEventSource opens a handle for writing events as `source` to the
+// Application log, or to the log the source is registered in.
+func OpenEventSource(source string) (*EventSource, error) {
+	wideSource, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.RegisterEventSource(nil, wideSource)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to register event source %q: %v", source, err)
+	}
+	return &EventSource{Name: source, handle: uintptr(handle)}, nil
+}
+
+// Report writes an event of the given EventType constant with insertion
+// strings for the message.
+func (s *EventSource) Report(eventType, category uint16, eventId uint32, strings ...string) error {
+	if s.handle == 0 {
+		return fmt.Errorf("Event source %q is closed", s.Name)
+	}
+	wideStrings := make([]*uint16, len(strings))
+	for i, str := range strings {
+		wide, err := syscall.UTF16PtrFromString(str)
+		if err != nil {
+			return err
+		}
+		wideStrings[i] = wide
+	}
+	var stringsPtr **uint16
+	if len(wideStrings) > 0 {
+		stringsPtr = &wideStrings[0]
+	}
+	return windows.ReportEvent(windows.Handle(s.handle), eventType, category, eventId, 0, uint16(len(wideStrings)), 0, stringsPtr, nil)
+}
+
+// Close deregisters the event source.
+func (s *EventSource) Close() error {
+	if s.handle == 0 {
+		return nil
+	}
+	err := windows.DeregisterEventSource(windows.Handle(s.handle))
+	s.handle = 0
+	return err
+}
+
+// RegisterProvider opens a handle for writing events as the provider with the
+// given GUID, such as "{54849625-5478-4994-a5ba-3e3b0328c30d}".
+func RegisterProvider(guid string) (*Provider, error) {
+	providerId, err := windows.GUIDFromString(guid)
+	if err != nil {
+		return nil, err
+	}
+	var handle uint64
+	r1, _, _ := eventRegister.Call(uintptr(unsafe.Pointer(&providerId)), 0, 0, uintptr(unsafe.Pointer(&handle)))
+	if r1 != 0 {
+		return nil, fmt.Errorf("Failed to register provider %v: %v", guid, syscall.Errno(r1))
+	}
+	return &Provider{Guid: guid, handle: handle}, nil
+}
+
+// Layout of EVENT_DATA_DESCRIPTOR
+type eventDataDescriptor struct {
+	ptr      uint64
+	size     uint32
+	reserved uint32
+}
+
+// Write writes an event with the given values for its template, which must
+// all be strings (win:UnicodeString).
+func (p *Provider) Write(descriptor EventDescriptor, values ...string) error {
+	if p.handle == 0 {
+		return fmt.Errorf("Provider %v is closed", p.Guid)
+	}
+	wideValues := make([][]uint16, len(values))
+	data := make([]eventDataDescriptor, len(values))
+	for i, value := range values {
+		wide, err := syscall.UTF16FromString(value)
+		if err != nil {
+			return err
+		}
+		wideValues[i] = wide
+		data[i] = eventDataDescriptor{ptr: uint64(uintptr(unsafe.Pointer(&wide[0]))), size: uint32(2 * len(wide))}
+	}
+	var dataPtr uintptr
+	if len(data) > 0 {
+		dataPtr = uintptr(unsafe.Pointer(&data[0]))
+	}
+	args := append(regHandleArgs(p.handle), uintptr(unsafe.Pointer(&descriptor)), uintptr(len(data)), dataPtr)
+	r1, _, _ := eventWrite.Call(args...)
+	// The values must stay alive until EventWrite returns
+	runtime.KeepAlive(wideValues)
+	if r1 != 0 {
+		return fmt.Errorf("Failed to write event %v: %v", descriptor.Id, syscall.Errno(r1))
+	}
+	return nil
+}
+
+// Close unregisters the provider.
+func (p *Provider) Close() error {
+	if p.handle == 0 {
+		return nil
+	}
+	r1, _, _ := eventUnregister.Call(regHandleArgs(p.handle)...)
+	p.handle = 0
+	if r1 != 0 {
+		return syscall.Errno(r1)
+	}
+	return nil
+}
+
+// regHandleArgs passes a REGHANDLE, which is 64 bits on every architecture.
+func regHandleArgs(handle uint64) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		return []uintptr{uintptr(handle)}
+	}
+	return []uintptr{uintptr(handle), uintptr(handle >> 32)}
+}
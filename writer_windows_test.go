@@ -0,0 +1,69 @@
+package winlog
+
+import (
+	"fmt"
+	"strings"
+	. "testing"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func TestReportEvent(t *T) {
+	source, err := OpenEventSource("gowinlog-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	message := fmt.Sprintf("gowinlog test event %v", time.Now().UnixNano())
+	if err := source.Warning(1000, message); err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := QueryChannel("Application", "*[System[Provider[@Name='gowinlog-test'] and EventID=1000]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer query.Close()
+	found := false
+	for !found {
+		event, err := query.Next(time.Second)
+		if err != nil {
+			break
+		}
+		xml, err := RenderEventXML(event)
+		CloseEventHandle(uint64(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = strings.Contains(string(xml), message)
+	}
+	if !found {
+		t.Fatal("Written event not found in the Application log")
+	}
+}
+
+func TestRegisterEventSource(t *T) {
+	if err := RegisterEventSource("Application", "gowinlog-register-test", ""); err != nil {
+		t.Fatal(err)
+	}
+	path := eventLogKey + `\Application\gowinlog-register-test`
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageFile, _, err := key.GetStringValue("EventMessageFile")
+	key.Close()
+	assertEqual(err, nil, t)
+	assertEqual(messageFile, DefaultEventMessageFile, t)
+
+	if err := UnregisterEventSource("Application", "gowinlog-register-test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE); err != registry.ErrNotExist {
+		t.Fatalf("Expected the source's key to be removed, got %v", err)
+	}
+	if err := UnregisterEventSource("Application", "gowinlog-register-test"); err != nil {
+		t.Fatal(err)
+	}
+}
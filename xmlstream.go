@@ -0,0 +1,101 @@
+package winlog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How long QueryXMLStream waits for the event log to return the next event
+const xmlStreamTimeout = 10 * time.Second
+
+const (
+	xmlDocumentHeader = "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<Events>\n"
+	xmlDocumentFooter = "</Events>\n"
+)
+
+// QueryXMLStream returns the rendered XML of each event matching `query`,
+// one <Event> element per line. Events are read from the event log as the
+// stream is read, so the results don't have to fit in memory. If
+// `channelOrFile` ends in .evtx it is read as an exported log file,
+// otherwise as a channel name.
+func QueryXMLStream(channelOrFile, query string) (io.ReadCloser, error) {
+	return queryXMLStream(channelOrFile, query, "", "")
+}
+
+// QueryXMLDocument is QueryXMLStream wrapped in an <Events> root element,
+// giving a well-formed XML document, as written by wevtutil query-events /e.
+func QueryXMLDocument(channelOrFile, query string) (io.ReadCloser, error) {
+	return queryXMLStream(channelOrFile, query, xmlDocumentHeader, xmlDocumentFooter)
+}
+
+// queryChannelOrFile queries an exported log file through `api` if
+// `channelOrFile` ends in .evtx, otherwise a channel.
+func queryChannelOrFile(api EventLogAPI, channelOrFile, query string) (*QueryResult, error) {
+	flags := uint32(EvtQueryChannelPath)
+	if strings.EqualFold(filepath.Ext(channelOrFile), ".evtx") {
+		flags = EvtQueryFilePath
+	}
+	return queryPath(api, channelOrFile, query, flags)
+}
+
+func queryXMLStream(channelOrFile, query, header, footer string) (io.ReadCloser, error) {
+	result, err := queryChannelOrFile(SystemEventLogAPI, channelOrFile, query)
+	if err != nil {
+		return nil, err
+	}
+	next := func() ([]byte, error) {
+		handle, err := result.Next(xmlStreamTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer CloseEventHandle(uint64(handle))
+		return RenderEventXML(handle)
+	}
+	return newXMLStream(next, result.Close, header, footer), nil
+}
+
+// xmlStream is an io.ReadCloser over the XML of events returned one at a
+// time by `next`, which returns io.EOF after the last event.
+type xmlStream struct {
+	next   func() ([]byte, error)
+	close  func() error
+	buf    []byte
+	footer []byte
+	err    error
+}
+
+func newXMLStream(next func() ([]byte, error), close func() error, header, footer string) *xmlStream {
+	return &xmlStream{next: next, close: close, buf: []byte(header), footer: []byte(footer)}
+}
+
+func (s *xmlStream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		xml, err := s.next()
+		if err == io.EOF {
+			s.buf, s.footer = s.footer, nil
+			s.err = io.EOF
+		} else if err != nil {
+			s.err = err
+		} else {
+			s.buf = append(xml, '\n')
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// Close releases the query. Reading after Close is an error.
+func (s *xmlStream) Close() error {
+	s.buf, s.footer = nil, nil
+	if s.err == nil {
+		s.err = os.ErrClosed
+	}
+	return s.close()
+}
@@ -0,0 +1,65 @@
+package winlog
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	. "testing"
+	"testing/iotest"
+)
+
+func fakeXMLEvents(events ...string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(events) == 0 {
+			return nil, io.EOF
+		}
+		ev := events[0]
+		events = events[1:]
+		return []byte(ev), nil
+	}
+}
+
+func TestXMLStream(t *T) {
+	closed := false
+	closer := func() error { closed = true; return nil }
+	stream := newXMLStream(fakeXMLEvents("<Event>1</Event>", "<Event>2</Event>"), closer, "", "")
+	data, err := ioutil.ReadAll(iotest.OneByteReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(string(data), "<Event>1</Event>\n<Event>2</Event>\n", t)
+	stream.Close()
+	assertEqual(closed, true, t)
+}
+
+func TestXMLDocument(t *T) {
+	stream := newXMLStream(fakeXMLEvents("<Event><EventID>1</EventID></Event>", "<Event><EventID>2</EventID></Event>"), func() error { return nil }, xmlDocumentHeader, xmlDocumentFooter)
+	defer stream.Close()
+	var doc struct {
+		Events []struct {
+			EventID int
+		} `xml:"Event"`
+	}
+	if err := xml.NewDecoder(stream).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(len(doc.Events), 2, t)
+	assertEqual(doc.Events[1].EventID, 2, t)
+}
+
+func TestXMLStreamError(t *T) {
+	failure := errors.New("RPC failed")
+	next := fakeXMLEvents("<Event>1</Event>")
+	stream := newXMLStream(func() ([]byte, error) {
+		data, err := next()
+		if err == io.EOF {
+			return nil, failure
+		}
+		return data, err
+	}, func() error { return nil }, xmlDocumentHeader, xmlDocumentFooter)
+	data, err := ioutil.ReadAll(stream)
+	assertEqual(err, failure, t)
+	assertEqual(string(data), xmlDocumentHeader+"<Event>1</Event>\n", t)
+	stream.Close()
+}